@@ -0,0 +1,262 @@
+package sls
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rsb/failure"
+)
+
+const (
+	GoNMCmdName      = "nm"
+	GoVersionCmdName = "version"
+	StdlibModule     = "std"
+)
+
+// ModuleInfo is a module compiled into a binary as reported by `go version -m`
+type ModuleInfo struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Replace string `json:"replace,omitempty"`
+}
+
+// SizeContribution is the size of the symbols a package or module adds to
+// the binary
+type SizeContribution struct {
+	Name    string `json:"name"`
+	Size    int64  `json:"size"`
+	Symbols int    `json:"symbols"`
+}
+
+// BinaryReport explains where the size of a lambda binary comes from.
+// Symbol sizes come from `go tool nm -size`, which needs the symbol table:
+// when the binary was linked with -s, Stripped is set and only the modules
+// are reported.
+type BinaryReport struct {
+	Path       string             `json:"path"`
+	Size       int64              `json:"size"`
+	GoVersion  string             `json:"go_version"`
+	Main       string             `json:"main"`
+	Modules    []ModuleInfo       `json:"modules"`
+	Stripped   bool               `json:"stripped"`
+	SymbolSize int64              `json:"symbol_size"`
+	ByModule   []SizeContribution `json:"by_module"`
+	ByPackage  []SizeContribution `json:"by_package"`
+}
+
+// Top returns the n packages contributing the most to the binary
+func (r BinaryReport) Top(n int) []SizeContribution {
+	if n <= 0 || n > len(r.ByPackage) {
+		return r.ByPackage
+	}
+
+	return r.ByPackage[:n]
+}
+
+// AnalyzeBinary reports the modules and per package symbol sizes of a go
+// binary using the go toolchain
+func AnalyzeBinary(binPath string) (BinaryReport, error) {
+	report := BinaryReport{Path: binPath}
+
+	info, err := os.Stat(binPath)
+	if err != nil {
+		return report, failure.ToSystem(err, "os.Stat failed for (%s)", binPath)
+	}
+	report.Size = info.Size()
+
+	goExec, err := exec.LookPath(GoBinaryName)
+	if err != nil {
+		return report, failure.ToSystem(err, "exec.LookPath failed")
+	}
+
+	out, err := exec.Command(goExec, GoVersionCmdName, "-m", binPath).Output()
+	if err != nil {
+		return report, failure.ToSystem(err, "go version -m failed for (%s)", binPath)
+	}
+	report.GoVersion, report.Main, report.Modules = ParseGoVersionM(out)
+
+	var stderr bytes.Buffer
+	nm := exec.Command(goExec, "tool", GoNMCmdName, "-size", "-sort", "size", binPath)
+	nm.Stderr = &stderr
+	out, err = nm.Output()
+	if err != nil {
+		if strings.Contains(stderr.String(), "no symbols") {
+			report.Stripped = true
+			return report, nil
+		}
+		return report, failure.ToSystem(err, "go tool nm failed for (%s): %s", binPath, strings.TrimSpace(stderr.String()))
+	}
+
+	packages := ParseGoNM(out)
+	if len(packages) == 0 {
+		report.Stripped = true
+		return report, nil
+	}
+
+	modules := map[string]*SizeContribution{}
+	for _, p := range packages {
+		report.SymbolSize += p.Size
+		name := PackageModule(p.Name, report.Main, report.Modules)
+		m, ok := modules[name]
+		if !ok {
+			m = &SizeContribution{Name: name}
+			modules[name] = m
+		}
+		m.Size += p.Size
+		m.Symbols += p.Symbols
+	}
+
+	report.ByPackage = packages
+	for _, m := range modules {
+		report.ByModule = append(report.ByModule, *m)
+	}
+	sortContributions(report.ByModule)
+
+	return report, nil
+}
+
+// ParseGoVersionM parses the output of `go version -m <binary>` into the go
+// version, the main module path and the dependencies
+func ParseGoVersionM(out []byte) (string, string, []ModuleInfo) {
+	var goVersion, main string
+	var modules []ModuleInfo
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "\t") {
+			if idx := strings.LastIndex(line, ": "); idx >= 0 {
+				goVersion = strings.TrimSpace(line[idx+2:])
+			}
+			continue
+		}
+
+		fields := strings.Split(strings.TrimPrefix(line, "\t"), "\t")
+		switch fields[0] {
+		case "mod":
+			if len(fields) > 1 {
+				main = fields[1]
+			}
+		case "dep":
+			if len(fields) > 2 {
+				modules = append(modules, ModuleInfo{Path: fields[1], Version: fields[2]})
+			}
+		case "=>":
+			if len(modules) > 0 && len(fields) > 1 {
+				modules[len(modules)-1].Replace = strings.Join(fields[1:], " ")
+			}
+		}
+	}
+
+	return goVersion, main, modules
+}
+
+// ParseGoNM parses the output of `go tool nm -size` and sums the symbol sizes
+// per package, largest first
+func ParseGoNM(out []byte) []SizeContribution {
+	packages := map[string]*SizeContribution{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		// address size type name, undefined symbols have no address
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || size == 0 {
+			continue
+		}
+
+		// bss symbols take memory at runtime, not space in the binary
+		if kind := strings.ToUpper(fields[2]); kind != "T" && kind != "R" && kind != "D" {
+			continue
+		}
+
+		name := SymbolPackage(strings.Join(fields[3:], " "))
+		if name == "" {
+			continue
+		}
+
+		p, ok := packages[name]
+		if !ok {
+			p = &SizeContribution{Name: name}
+			packages[name] = p
+		}
+		p.Size += size
+		p.Symbols++
+	}
+
+	result := make([]SizeContribution, 0, len(packages))
+	for _, p := range packages {
+		result = append(result, *p)
+	}
+	sortContributions(result)
+
+	return result
+}
+
+// SymbolPackage is the import path of the package a symbol belongs to, ex
+// `github.com/rsb/sls/pstore.(*Client).Put` is `github.com/rsb/sls/pstore`
+func SymbolPackage(symbol string) string {
+	for _, prefix := range []string{"type:", "type.", "go:itab.", "go.itab.", "go:func.", "go.func.", "go:", "go."} {
+		symbol = strings.TrimPrefix(symbol, prefix)
+	}
+	symbol = strings.TrimLeft(symbol, "*")
+	// type parameters and receivers can reference other packages
+	if idx := strings.IndexAny(symbol, "[("); idx >= 0 {
+		symbol = symbol[:idx]
+	}
+
+	// the path of a package can contain dots, the name after the last slash
+	// cannot
+	slash := strings.LastIndex(symbol, "/")
+	dot := strings.Index(symbol[slash+1:], ".")
+	if dot < 0 {
+		return ""
+	}
+
+	// the linker escapes the dots of the last path element, gopkg.in/yaml%2ev3
+	return strings.ReplaceAll(symbol[:slash+1+dot], "%2e", ".")
+}
+
+// PackageModule finds the module providing pkg, packages outside every
+// module are the standard library
+func PackageModule(pkg, main string, modules []ModuleInfo) string {
+	best := ""
+	candidates := []string{main}
+	for _, m := range modules {
+		candidates = append(candidates, m.Path)
+	}
+
+	for _, path := range candidates {
+		if path == "" || len(path) <= len(best) {
+			continue
+		}
+		if pkg == path || strings.HasPrefix(pkg, path+"/") {
+			best = path
+		}
+	}
+
+	if best == "" {
+		return StdlibModule
+	}
+
+	return best
+}
+
+func sortContributions(c []SizeContribution) {
+	sort.Slice(c, func(a, b int) bool {
+		if c[a].Size != c[b].Size {
+			return c[a].Size > c[b].Size
+		}
+		return c[a].Name < c[b].Name
+	})
+}
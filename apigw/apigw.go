@@ -11,6 +11,7 @@ import (
 
 	"github.com/rsb/sls"
 	"github.com/rsb/sls/logging"
+	"github.com/rsb/sls/slsctx"
 
 	"go.uber.org/zap"
 
@@ -28,7 +29,7 @@ const (
 
 func LambdaStart(config RestHandlerConfig, h Handler, logger *zap.SugaredLogger) {
 	runner := NewRestRunner(config, h, logger)
-	lambda.Start(runner.Handle)
+	lambda.Start(sls.WithControl(runner.Handle, sls.HealthChecksFrom(h)))
 }
 
 type RestHandlerConfig struct {
@@ -43,6 +44,7 @@ type RestRunner struct {
 	feature Handler
 	logger  *zap.SugaredLogger
 	timeout sls.TimeoutCapturing
+	meta    slsctx.Metadata
 }
 
 func NewRestRunner(c RestHandlerConfig, h Handler, l *zap.SugaredLogger) *RestRunner {
@@ -50,6 +52,7 @@ func NewRestRunner(c RestHandlerConfig, h Handler, l *zap.SugaredLogger) *RestRu
 		feature: h,
 		logger:  l,
 		timeout: sls.NewTimeout(c.TimeoutConfig),
+		meta:    slsctx.FromEnvironment(),
 	}
 }
 
@@ -58,6 +61,7 @@ func (h *RestRunner) Handle(ctx context.Context, req events.APIGatewayProxyReque
 	var resp events.APIGatewayProxyResponse
 
 	start := time.Now()
+	ctx = slsctx.Set(ctx, h.meta)
 	ctx = InitializeRequestContext(ctx, req)
 
 	logger := RequestLogger(ctx, h.logger, req)
@@ -68,7 +72,10 @@ func (h *RestRunner) Handle(ctx context.Context, req events.APIGatewayProxyReque
 		return out, err
 	}
 
-	_, err = h.timeout.WithTimeConstraint(ctx, handlerFn)
+	// a feature that failed to load its config never runs
+	if err = sls.StartupError(); err == nil {
+		_, err = h.timeout.WithTimeConstraint(ctx, handlerFn)
+	}
 	elapsed := time.Since(start) / time.Millisecond
 
 	if err != nil {
@@ -94,18 +101,26 @@ func (h *RestRunner) Handle(ctx context.Context, req events.APIGatewayProxyReque
 
 func ProcessFailure(err error, l *zap.SugaredLogger, req events.APIGatewayProxyRequest, elapsed time.Duration) (events.APIGatewayProxyResponse, error) {
 	resp := FailureToGatewayResponse(err)
-	l.With(
+	l = l.With(
 		"status", resp.StatusCode,
 		"body", req.Body,
 		"elapsed_ms", elapsed,
 	)
 
 	if failure.IsTimeout(err) {
-		l.With("timeout", true)
+		l = l.With("timeout", true)
+	}
+
+	if sls.IsMisconfigured(err) {
+		l = l.With("misconfigured", true, sls.MisconfiguredMetric, 1)
 	}
 
+	p, isPanic := sls.PanicDetails(err)
 	if failure.IsPanic(err) {
-		l.With("panic", true)
+		l = l.With("panic", true)
+		if isPanic {
+			l = l.With("panic_id", p.ID)
+		}
 	}
 
 	if resp.StatusCode >= http.StatusInternalServerError {
@@ -128,6 +143,12 @@ func ProcessFailure(err error, l *zap.SugaredLogger, req events.APIGatewayProxyR
 		Message: msg,
 	}
 
+	// the panic id lets the caller's error be matched to every other
+	// invocation that failed with the same panic
+	if isPanic {
+		failed.ID = fmt.Sprintf("%s:%s", failed.ID, p.ID)
+	}
+
 	if failure.IsRestAPI(err) {
 		if value, ok := failure.RestMessage(err); ok {
 			failed.Message = value
@@ -6,6 +6,7 @@ import (
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/rsb/failure"
+	"github.com/rsb/sls"
 	"go.uber.org/zap"
 )
 
@@ -68,6 +69,8 @@ func BadRequest(err error, body interface{}) events.APIGatewayProxyResponse {
 func FailureToGatewayResponse(err error) events.APIGatewayProxyResponse {
 	var resp = events.APIGatewayProxyResponse{}
 	switch {
+	case sls.IsMisconfigured(err):
+		resp.StatusCode = http.StatusServiceUnavailable
 	case failure.IsPanic(err):
 		resp.StatusCode = http.StatusBadGateway
 	case failure.IsTimeout(err):
@@ -0,0 +1,147 @@
+package sls
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rsb/failure"
+)
+
+// AppSyncResolverEvent is the event appsync sends a direct lambda resolver.
+// A batch resolver receives a slice of them, one per source object.
+type AppSyncResolverEvent struct {
+	Arguments json.RawMessage        `json:"arguments"`
+	Source    json.RawMessage        `json:"source"`
+	Identity  AppSyncIdentity        `json:"identity"`
+	Request   AppSyncRequest         `json:"request"`
+	Info      AppSyncInfo            `json:"info"`
+	Prev      json.RawMessage        `json:"prev,omitempty"`
+	Stash     map[string]interface{} `json:"stash,omitempty"`
+}
+
+type AppSyncRequest struct {
+	Headers    map[string]string `json:"headers"`
+	DomainName string            `json:"domainName,omitempty"`
+}
+
+// AppSyncInfo describes the graphql field being resolved
+type AppSyncInfo struct {
+	FieldName           string                 `json:"fieldName"`
+	ParentTypeName      string                 `json:"parentTypeName"`
+	Variables           map[string]interface{} `json:"variables"`
+	SelectionSetList    []string               `json:"selectionSetList"`
+	SelectionSetGraphQL string                 `json:"selectionSetGraphQL"`
+}
+
+// AppSyncIdentity is the caller, its shape depends on the authorization mode
+// of the api so it is kept raw and decoded by IAM, Cognito and Lambda. It is
+// empty for api key authorization.
+type AppSyncIdentity json.RawMessage
+
+func (i AppSyncIdentity) MarshalJSON() ([]byte, error) {
+	if len(i) == 0 {
+		return []byte("null"), nil
+	}
+	return i, nil
+}
+
+func (i *AppSyncIdentity) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*i = nil
+		return nil
+	}
+	*i = append((*i)[0:0], data...)
+	return nil
+}
+
+func (i AppSyncIdentity) IsEmpty() bool {
+	return len(i) == 0
+}
+
+func (i AppSyncIdentity) keys() map[string]json.RawMessage {
+	var keys map[string]json.RawMessage
+	if i.IsEmpty() || json.Unmarshal(i, &keys) != nil {
+		return nil
+	}
+	return keys
+}
+
+// IAM is the caller when the api uses iam authorization
+func (i AppSyncIdentity) IAM() (events.AppSyncIAMIdentity, bool) {
+	var id events.AppSyncIAMIdentity
+	if _, ok := i.keys()["userArn"]; !ok {
+		return id, false
+	}
+
+	return id, json.Unmarshal(i, &id) == nil
+}
+
+// Cognito is the caller when the api uses cognito user pools, or oidc,
+// authorization
+func (i AppSyncIdentity) Cognito() (events.AppSyncCognitoIdentity, bool) {
+	var id events.AppSyncCognitoIdentity
+	keys := i.keys()
+	if _, ok := keys["sub"]; !ok {
+		return id, false
+	}
+	if _, ok := keys["userArn"]; ok {
+		return id, false
+	}
+
+	return id, json.Unmarshal(i, &id) == nil
+}
+
+// Lambda is the resolver context set by a lambda authorizer
+func (i AppSyncIdentity) Lambda() (map[string]interface{}, bool) {
+	raw, ok := i.keys()["resolverContext"]
+	if !ok {
+		return nil, false
+	}
+
+	var result map[string]interface{}
+	return result, json.Unmarshal(raw, &result) == nil
+}
+
+// Field is the graphql field being resolved, ex `Query.getUser`
+func (e AppSyncResolverEvent) Field() string {
+	return fmt.Sprintf("%s.%s", e.Info.ParentTypeName, e.Info.FieldName)
+}
+
+// DecodeArguments unmarshals the arguments of the field into v
+func (e AppSyncResolverEvent) DecodeArguments(v interface{}) error {
+	if len(e.Arguments) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(e.Arguments, v); err != nil {
+		return failure.ToInvalidParam(err, "arguments of (%s) could not be decoded", e.Field())
+	}
+
+	return nil
+}
+
+// DecodeSource unmarshals the parent object of the field into v, it is
+// empty for the fields of Query, Mutation and Subscription
+func (e AppSyncResolverEvent) DecodeSource(v interface{}) error {
+	if len(e.Source) == 0 || string(e.Source) == "null" {
+		return nil
+	}
+
+	if err := json.Unmarshal(e.Source, v); err != nil {
+		return failure.ToInvalidParam(err, "source of (%s) could not be decoded", e.Field())
+	}
+
+	return nil
+}
+
+// Argument is a single argument of the field, false when it was not given
+func (e AppSyncResolverEvent) Argument(name string) (json.RawMessage, bool) {
+	var args map[string]json.RawMessage
+	if err := json.Unmarshal(e.Arguments, &args); err != nil {
+		return nil, false
+	}
+
+	value, ok := args[name]
+	return value, ok
+}
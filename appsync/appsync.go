@@ -0,0 +1,211 @@
+// Package appsync is the lambda handling front controller for microservice
+// features that resolve graphql fields through an appsync direct lambda
+// resolver. It provides logging, timeout and panic handling for single and
+// batch resolvers.
+package appsync
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/rsb/failure"
+	"go.uber.org/zap"
+
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/logging"
+	"github.com/rsb/sls/slsctx"
+)
+
+// Error types reported to appsync, they become the errorType of the graphql
+// error
+const (
+	BadRequestError     = "BadRequest"
+	NotFoundError       = "NotFound"
+	UnauthorizedError   = "Unauthorized"
+	ForbiddenError      = "Forbidden"
+	TimeoutError        = "Timeout"
+	MisconfiguredError  = "Misconfigured"
+	InternalFailedError = "InternalFailure"
+)
+
+type HandlerConfig struct {
+	sls.TimeoutConfig
+}
+
+// Handler resolves one graphql field, the result is marshalled to json and
+// becomes the value of the field
+type Handler interface {
+	Run(ctx context.Context, e sls.AppSyncResolverEvent) (interface{}, error)
+}
+
+// HandlerFunc adapts a func to a Handler
+type HandlerFunc func(ctx context.Context, e sls.AppSyncResolverEvent) (interface{}, error)
+
+func (fn HandlerFunc) Run(ctx context.Context, e sls.AppSyncResolverEvent) (interface{}, error) {
+	return fn(ctx, e)
+}
+
+// Resolvers routes an event to the handler registered for its field, the
+// key is `<ParentType>.<field>` like `Query.getUser`
+type Resolvers map[string]Handler
+
+func (r Resolvers) Run(ctx context.Context, e sls.AppSyncResolverEvent) (interface{}, error) {
+	h, ok := r[e.Field()]
+	if !ok || h == nil {
+		return nil, failure.NotFound("no resolver for field (%s)", e.Field())
+	}
+
+	return h.Run(ctx, e)
+}
+
+// BatchItemResult is the result of one event of a batch. appsync reports
+// ErrorMessage and ErrorType as the graphql error of that item only.
+type BatchItemResult struct {
+	Data         interface{} `json:"data"`
+	ErrorMessage string      `json:"errorMessage,omitempty"`
+	ErrorType    string      `json:"errorType,omitempty"`
+}
+
+func LambdaStart(config HandlerConfig, h Handler, logger *zap.SugaredLogger) {
+	runner := NewResolverRunner(config, h, logger)
+	lambda.Start(sls.WithControl(runner.Handle, sls.HealthChecksFrom(h)))
+}
+
+// LambdaStartBatch starts a batch resolver, the handler is run once for
+// every event of the batch
+func LambdaStartBatch(config HandlerConfig, h Handler, logger *zap.SugaredLogger) {
+	runner := NewResolverRunner(config, h, logger)
+	lambda.Start(sls.WithControl(runner.HandleBatch, sls.HealthChecksFrom(h)))
+}
+
+type ResolverRunner struct {
+	feature Handler
+	logger  *zap.SugaredLogger
+	timeout sls.TimeoutCapturing
+	meta    slsctx.Metadata
+}
+
+func NewResolverRunner(c HandlerConfig, h Handler, l *zap.SugaredLogger) *ResolverRunner {
+	return &ResolverRunner{
+		feature: h,
+		logger:  l,
+		timeout: sls.NewTimeout(c.TimeoutConfig),
+		meta:    slsctx.FromEnvironment(),
+	}
+}
+
+// Handle resolves a single field. A failure is returned as a lambda error
+// whose type is one of the error types above.
+func (r *ResolverRunner) Handle(ctx context.Context, e sls.AppSyncResolverEvent) (interface{}, error) {
+	ctx = slsctx.Set(ctx, r.meta)
+	out, err := r.resolve(ctx, e)
+	if err != nil {
+		return nil, messages.InvokeResponse_Error{
+			Message: err.Error(),
+			Type:    ErrorType(err),
+		}
+	}
+
+	return out, nil
+}
+
+// HandleBatch resolves every event of a batch, in order. A failed event does
+// not fail the batch, it is reported in its own result.
+func (r *ResolverRunner) HandleBatch(ctx context.Context, batch []sls.AppSyncResolverEvent) ([]BatchItemResult, error) {
+	ctx = slsctx.Set(ctx, r.meta)
+	results := make([]BatchItemResult, len(batch))
+	for idx, e := range batch {
+		out, err := r.resolve(ctx, e)
+		if err != nil {
+			results[idx] = BatchItemResult{ErrorMessage: err.Error(), ErrorType: ErrorType(err)}
+			continue
+		}
+		results[idx] = BatchItemResult{Data: out}
+	}
+
+	return results, nil
+}
+
+func (r *ResolverRunner) resolve(ctx context.Context, e sls.AppSyncResolverEvent) (interface{}, error) {
+	var result interface{}
+
+	ctx = setEvent(ctx, e)
+	logger := ResolverLogger(ctx, r.logger, e)
+	ctx = logging.SetInvocationLogger(ctx, logger)
+
+	start := time.Now()
+	handlerFn := func() (out interface{}, err error) {
+		result, err = r.feature.Run(ctx, e)
+		return out, err
+	}
+
+	err := sls.StartupError()
+	if err == nil {
+		_, err = r.timeout.WithTimeConstraint(ctx, handlerFn)
+	}
+	elapsed := time.Since(start) / time.Millisecond
+
+	logger = logger.With("elapsed_ms", elapsed, "error_type", ErrorType(err))
+	if err != nil {
+		switch {
+		case sls.IsMisconfigured(err):
+			logger.With("misconfigured", true, sls.MisconfiguredMetric, 1).Error("[ResolverRunner MISCONFIGURED]", err.Error())
+		case failure.IsTimeout(err):
+			logger.With("timeout", true).Error("[ResolverRunner TIMEOUT]", err.Error())
+		case failure.IsPanic(err):
+			logger = logger.With("panic", true)
+			if p, ok := sls.PanicDetails(err); ok {
+				logger = logger.With("panic_id", p.ID)
+			}
+			logger.Error("[ResolverRunner PANIC]", err.Error())
+		default:
+			logger.Warn("[ResolverRunner FAILED]", err.Error())
+		}
+
+		return nil, err
+	}
+
+	logger.Info(e.Field())
+	return result, nil
+}
+
+// ErrorType maps a failure to the errorType appsync reports to the caller,
+// empty when err is nil
+func ErrorType(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case sls.IsMisconfigured(err):
+		return MisconfiguredError
+	case failure.IsTimeout(err):
+		return TimeoutError
+	case failure.IsNotFound(err):
+		return NotFoundError
+	case failure.IsValidation(err), failure.IsInvalidParam(err):
+		return BadRequestError
+	case failure.IsNotAuthenticated(err):
+		return UnauthorizedError
+	case failure.IsNotAuthorized(err), failure.IsForbidden(err):
+		return ForbiddenError
+	default:
+		return InternalFailedError
+	}
+}
+
+// DecodeArguments is the generic form of AppSyncResolverEvent.DecodeArguments
+//
+//	args, err := appsync.DecodeArguments[GetUserArgs](e)
+func DecodeArguments[T any](e sls.AppSyncResolverEvent) (T, error) {
+	var args T
+	err := e.DecodeArguments(&args)
+	return args, err
+}
+
+// DecodeSource is the generic form of AppSyncResolverEvent.DecodeSource
+func DecodeSource[T any](e sls.AppSyncResolverEvent) (T, error) {
+	var source T
+	err := e.DecodeSource(&source)
+	return source, err
+}
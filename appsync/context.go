@@ -0,0 +1,68 @@
+package appsync
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rsb/sls"
+)
+
+const (
+	invocationEvent ctxKey = "event"
+)
+
+// contextKey is an internal type used for context keys to restrict access
+// to the context values via the various Get methods.
+type ctxKey string
+
+// setEvent sets the resolver event in the context.
+func setEvent(ctx context.Context, e sls.AppSyncResolverEvent) context.Context {
+	return context.WithValue(ctx, invocationEvent, e)
+}
+
+// GetEvent gets the resolver event from the context. Defaults to a new empty instance if not set.
+func GetEvent(ctx context.Context) sls.AppSyncResolverEvent {
+	val := ctx.Value(invocationEvent)
+	event, ok := val.(sls.AppSyncResolverEvent)
+	if !ok {
+		event = sls.AppSyncResolverEvent{}
+	}
+	return event
+}
+
+// GetField gets the graphql field being resolved from the context, ex `Query.getUser`. Defaults to an empty string if not set.
+func GetField(ctx context.Context) string {
+	e := GetEvent(ctx)
+	if e.Info.FieldName == "" {
+		return ""
+	}
+	return e.Field()
+}
+
+// GetIAMIdentity gets the iam caller from the context, false when the api does not use iam authorization.
+func GetIAMIdentity(ctx context.Context) (events.AppSyncIAMIdentity, bool) {
+	return GetEvent(ctx).Identity.IAM()
+}
+
+// GetCognitoIdentity gets the cognito caller from the context, false when the api does not use cognito or oidc authorization.
+func GetCognitoIdentity(ctx context.Context) (events.AppSyncCognitoIdentity, bool) {
+	return GetEvent(ctx).Identity.Cognito()
+}
+
+// GetResolverContext gets the resolver context set by a lambda authorizer from the context, false when there is none.
+func GetResolverContext(ctx context.Context) (map[string]interface{}, bool) {
+	return GetEvent(ctx).Identity.Lambda()
+}
+
+// GetUserID gets the id of the caller from the context, the cognito sub or the iam user arn. Defaults to an empty string if not set.
+func GetUserID(ctx context.Context) string {
+	if id, ok := GetCognitoIdentity(ctx); ok {
+		return id.Sub
+	}
+
+	if id, ok := GetIAMIdentity(ctx); ok {
+		return id.UserARN
+	}
+
+	return ""
+}
@@ -0,0 +1,17 @@
+package appsync
+
+import (
+	"context"
+
+	"github.com/rsb/sls"
+	"go.uber.org/zap"
+)
+
+func ResolverLogger(ctx context.Context, l *zap.SugaredLogger, e sls.AppSyncResolverEvent) *zap.SugaredLogger {
+	l = sls.InvocationLogger(ctx, l, sls.AppSyncTrigger)
+
+	return l.With(
+		"field", e.Field(),
+		"selection_set", e.Info.SelectionSetList,
+	)
+}
@@ -65,6 +65,13 @@ func Zip(zf, binary string) error {
 //  binaryName - is the name of the binary, this is used in the -o flag
 //  targetDir  - is the directory which contains the source code to build
 func NewGoBuildCmd(buildDir, binaryName, targetDir string) (*exec.Cmd, error) {
+	return NewGoArchBuildCmd(buildDir, binaryName, targetDir, "")
+}
+
+// NewGoArchBuildCmd is NewGoBuildCmd for the goarch instruction set, amd64 or
+// arm64. An empty goarch is the GOARCH of the environment, the arch of the
+// host when it is not set.
+func NewGoArchBuildCmd(buildDir, binaryName, targetDir, goarch string) (*exec.Cmd, error) {
 	if targetDir == "" {
 		return nil, failure.System("[targetDir] is empty")
 	}
@@ -87,9 +94,13 @@ func NewGoBuildCmd(buildDir, binaryName, targetDir string) (*exec.Cmd, error) {
 	}
 
 	outputPath := fmt.Sprintf("%s/%s", buildDir, binaryName)
+	env := append(os.Environ(), "GOOS=linux")
+	if goarch != "" {
+		env = append(env, "GOARCH="+goarch)
+	}
 
 	cmd := exec.Cmd{
-		Env:  append(os.Environ(), "GOOS=linux"),
+		Env:  env,
 		Dir:  targetDir,
 		Path: goExec,
 		Args: []string{
@@ -10,8 +10,10 @@ import (
 	"github.com/rsb/failure"
 
 	"github.com/rsb/sls/logging"
+	"github.com/rsb/sls/slsctx"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/rsb/sls"
 	"go.uber.org/zap"
 )
@@ -30,9 +32,25 @@ type PreSignupRunner struct {
 	feature PreSignupHandler
 	logger  *zap.SugaredLogger
 	timeout sls.TimeoutCapturing
+	meta    slsctx.Metadata
+}
+
+func LambdaStart(config HandlerConfig, h PreSignupHandler, logger *zap.SugaredLogger) {
+	runner := NewPreSignupRunner(config, h, logger)
+	lambda.Start(sls.WithControl(runner.Handle, sls.HealthChecksFrom(h)))
+}
+
+func NewPreSignupRunner(c HandlerConfig, h PreSignupHandler, l *zap.SugaredLogger) *PreSignupRunner {
+	return &PreSignupRunner{
+		feature: h,
+		logger:  l,
+		timeout: sls.NewTimeout(c.TimeoutConfig),
+		meta:    slsctx.FromEnvironment(),
+	}
 }
 
 func (p *PreSignupRunner) Handle(ctx context.Context, e events.CognitoEventUserPoolsPreSignup) (err error) {
+	ctx = slsctx.Set(ctx, p.meta)
 	ctx = setPreSignupEvent(ctx, e)
 
 	logger := PreSignupLogger(ctx, p.logger, e)
@@ -44,17 +62,24 @@ func (p *PreSignupRunner) Handle(ctx context.Context, e events.CognitoEventUserP
 		return out, err
 	}
 
-	_, err = p.timeout.WithTimeConstraint(ctx, handlerFn)
+	if err = sls.StartupError(); err == nil {
+		_, err = p.timeout.WithTimeConstraint(ctx, handlerFn)
+	}
 	elapsed := time.Since(start) / time.Millisecond
 
-	logger.With("elapsed_ms", elapsed)
+	logger = logger.With("elapsed_ms", elapsed)
 
 	if err != nil {
 		switch {
+		case sls.IsMisconfigured(err):
+			logger.With("misconfigured", true, sls.MisconfiguredMetric, 1).Error("[PreSignupRunner MISCONFIGURED]", err.Error())
 		case failure.IsTimeout(err):
-			logger.With("timeout", true)
+			logger = logger.With("timeout", true)
 		case failure.IsPanic(err):
-			logger.With("panic", true)
+			logger = logger.With("panic", true)
+			if p, ok := sls.PanicDetails(err); ok {
+				logger.With("panic_id", p.ID).Error("[PreSignupRunner PANIC]", p.Recovered)
+			}
 
 		default:
 			logger.Error("[PreSignupRunner FAILED]", err.Error())
@@ -0,0 +1,207 @@
+package sls
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rsb/conf"
+	"github.com/rsb/failure"
+	"go.uber.org/zap"
+)
+
+const (
+	// MisconfiguredMetric is the log field set on every invocation rejected
+	// because of a startup config error, alarm on a metric filter for it
+	MisconfiguredMetric = "sls_misconfigured"
+	RedactedValue       = "********"
+)
+
+// SensitiveMarkers are the parts of an env var name whose value is never
+// logged, ex `DB_PASSWORD` or `STRIPE_API_KEY`
+var SensitiveMarkers = []string{"SECRET", "TOKEN", "PASSWORD", "PASSWD", "KEY", "CREDENTIAL", "PRIVATE", "DSN"}
+
+var (
+	startupMu  sync.RWMutex
+	startupErr error
+)
+
+// ConfigError is the error produced when a feature's conf can not be loaded
+// at cold start. It satisfies failure.IsConfig, Missing are the env vars of
+// required fields that have no value.
+type ConfigError struct {
+	Feature string
+	Missing []string
+	err     error
+}
+
+func NewConfigError(feature string, missing []string, err error) *ConfigError {
+	if err == nil {
+		err = failure.Config("(%s) is missing required env vars (%s)", feature, strings.Join(missing, ", "))
+	} else if !failure.IsConfig(err) {
+		err = failure.ToConfig(err, "(%s) conf could not be loaded", feature)
+	}
+
+	return &ConfigError{
+		Feature: feature,
+		Missing: missing,
+		err:     err,
+	}
+}
+
+func (e *ConfigError) Error() string {
+	return e.err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.err
+}
+
+// ConfigDetails finds the ConfigError anywhere in the chain of err
+func ConfigDetails(err error) (*ConfigError, bool) {
+	var c *ConfigError
+	if !errors.As(err, &c) {
+		return nil, false
+	}
+
+	return c, true
+}
+
+// IsMisconfigured reports whether err comes from a startup config error
+func IsMisconfigured(err error) bool {
+	_, ok := ConfigDetails(err)
+	return ok
+}
+
+// LoadConfig processes the env of a feature's conf and verifies every
+// required field has a value. The resolved config is returned with the
+// sensitive values redacted so it is safe to log.
+func LoadConfig(feature string, c Configurable) (map[string]string, error) {
+	if c == nil {
+		return nil, NewConfigError(feature, nil, failure.System("c is nil, a Configurable is required"))
+	}
+
+	missing, err := MissingRequired(c)
+	if err != nil {
+		return nil, NewConfigError(feature, nil, err)
+	}
+
+	if len(missing) > 0 {
+		return nil, NewConfigError(feature, missing, nil)
+	}
+
+	if err = c.ProcessEnv(); err != nil {
+		return nil, NewConfigError(feature, nil, err)
+	}
+
+	env, err := c.EnvToMap()
+	if err != nil {
+		return nil, NewConfigError(feature, nil, err)
+	}
+
+	return RedactConfig(env), nil
+}
+
+// MustLoadConfig is LoadConfig for the init of a lambda. It never exits, a
+// failure is logged and remembered instead so the runners answer every
+// invocation with an explicit misconfigured response, without calling the
+// feature handler, see StartupError. Without a failure the redacted config
+// is logged.
+//
+//	func main() {
+//		sls.MustLoadConfig("create-user", conf, logger)
+//		apigw.LambdaStart(config, handler, logger)
+//	}
+func MustLoadConfig(feature string, c Configurable, l *zap.SugaredLogger) {
+	env, err := LoadConfig(feature, c)
+	if err != nil {
+		setStartupError(err)
+		if l != nil {
+			l.With(
+				"feature", feature,
+				"misconfigured", true,
+				MisconfiguredMetric, 1,
+			).Error(err.Error())
+		}
+		return
+	}
+
+	if l != nil {
+		l.With("feature", feature, "config", env).Info("config loaded")
+	}
+}
+
+// StartupError is the error recorded by MustLoadConfig, nil when the
+// feature started cleanly
+func StartupError() error {
+	startupMu.RLock()
+	defer startupMu.RUnlock()
+	return startupErr
+}
+
+func setStartupError(err error) {
+	startupMu.Lock()
+	defer startupMu.Unlock()
+	startupErr = err
+}
+
+// MissingRequired are the env vars of the required fields that are not set
+// and have no default. Only configurations built with conf.NewConfig expose
+// their fields, others rely on ProcessEnv to fail.
+func MissingRequired(c Configurable) ([]string, error) {
+	cc, ok := c.(*conf.Config)
+	if !ok {
+		return nil, nil
+	}
+
+	var prefix []string
+	if cc.IsPrefixEnabled() {
+		prefix = append(prefix, cc.GetPrefix())
+	}
+
+	fields, err := conf.Fields(cc.Data, prefix...)
+	if err != nil {
+		return nil, failure.Wrap(err, "conf.Fields failed")
+	}
+
+	var missing []string
+	for _, field := range fields {
+		if !field.IsRequired() || field.IsDefault() {
+			continue
+		}
+
+		if value, ok := os.LookupEnv(field.EnvVariable()); !ok || value == "" {
+			missing = append(missing, field.EnvVariable())
+		}
+	}
+	sort.Strings(missing)
+
+	return missing, nil
+}
+
+// IsSensitive reports whether the value of the env var must not be logged
+func IsSensitive(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, marker := range SensitiveMarkers {
+		if strings.Contains(upper, marker) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RedactConfig returns a copy of env with the sensitive values replaced
+func RedactConfig(env map[string]string) map[string]string {
+	result := make(map[string]string, len(env))
+	for name, value := range env {
+		if IsSensitive(name) && value != "" {
+			value = RedactedValue
+		}
+		result[name] = value
+	}
+
+	return result
+}
@@ -0,0 +1,45 @@
+package sls
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ConsoleURL is the aws web console page of service in the region, the
+// fragment selects the view inside the page, ex
+// `https://console.aws.amazon.com/lambda/home?region=us-east-1#/functions/orders`
+func (r Region) ConsoleURL(service, fragment string) string {
+	u := fmt.Sprintf("https://%s/%s/home?region=%s", r.Partition().ConsoleHost(), service, r)
+	if fragment != "" {
+		u += "#" + fragment
+	}
+
+	return u
+}
+
+// LambdaConsoleURL is the console page of the function, with a tab like
+// `configure` or `monitoring` the page opens on it
+func LambdaConsoleURL(r Region, functionName string, tab ...string) string {
+	fragment := "/functions/" + url.PathEscape(functionName)
+	if len(tab) > 0 && tab[0] != "" {
+		fragment += "?tab=" + url.QueryEscape(tab[0])
+	}
+
+	return r.ConsoleURL("lambda", fragment)
+}
+
+// LogGroupConsoleURL is the cloudwatch console page of the log group. The
+// logs console escapes the group twice with $ in place of %, so the slashes
+// of `/aws/lambda/orders` become $252F.
+func LogGroupConsoleURL(r Region, group string) string {
+	escaped := strings.ReplaceAll(url.QueryEscape(group), "%", "$25")
+	return r.ConsoleURL("cloudwatch", "logsV2:log-groups/log-group/"+escaped)
+}
+
+// ParamPathConsoleURL is the systems manager console page listing the
+// params under path
+func ParamPathConsoleURL(r Region, path string) string {
+	return fmt.Sprintf("https://%s/systems-manager/parameters/?region=%s&tab=Table#list_parameter_filters=Path:Recursive:%s",
+		r.Partition().ConsoleHost(), r, url.QueryEscape(path))
+}
@@ -0,0 +1,233 @@
+package sls
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/rsb/failure"
+)
+
+const (
+	ControlPayloadKey = "sls"
+	// StartupCheck is the selftest result of the config loaded by
+	// MustLoadConfig, only reported when it failed
+	StartupCheck = "startup"
+)
+
+// ControlKind is the reserved value of the `sls` key in a control payload.
+// Control payloads are answered by the runner itself, the feature handler
+// is never called.
+type ControlKind string
+
+const (
+	// WarmupControl keeps an instance warm, `{"sls":"warmup"}`
+	WarmupControl ControlKind = "warmup"
+	// SelfTestControl runs the registered health checks, `{"sls":"selftest"}`
+	SelfTestControl ControlKind = "selftest"
+)
+
+func (k ControlKind) String() string {
+	return string(k)
+}
+
+func (k ControlKind) IsValid() bool {
+	return k == WarmupControl || k == SelfTestControl
+}
+
+// ControlPayload is the payload sent by `infra warm` and friends. Checks
+// limits a selftest to the named health checks, all checks run when it is
+// empty.
+type ControlPayload struct {
+	Kind   ControlKind `json:"sls"`
+	Checks []string    `json:"checks,omitempty"`
+}
+
+// ControlResponse is what the runner answers a control payload with
+type ControlResponse struct {
+	Kind      ControlKind       `json:"sls"`
+	Healthy   bool              `json:"healthy"`
+	Function  string            `json:"function,omitempty"`
+	Version   string            `json:"version,omitempty"`
+	Checks    map[string]string `json:"checks,omitempty"`
+	ElapsedMS int64             `json:"elapsed_ms"`
+}
+
+// HealthCheck verifies one dependency of a feature, like a table or a
+// downstream api, a nil error means the dependency is healthy
+type HealthCheck func(ctx context.Context) error
+
+type HealthChecks map[string]HealthCheck
+
+// HealthChecker is implemented by feature handlers that want their
+// dependencies verified by a selftest
+type HealthChecker interface {
+	HealthChecks() HealthChecks
+}
+
+// HealthChecksFrom returns the checks of a feature handler, or no checks
+// when it does not implement HealthChecker
+func HealthChecksFrom(feature interface{}) HealthChecks {
+	hc, ok := feature.(HealthChecker)
+	if !ok {
+		return HealthChecks{}
+	}
+
+	return hc.HealthChecks()
+}
+
+// Names are the health check names sorted
+func (h HealthChecks) Names() []string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Run runs the named checks or all checks when no names are given. The
+// result maps each check to "ok" or its error message.
+func (h HealthChecks) Run(ctx context.Context, names ...string) (map[string]string, bool) {
+	if len(names) == 0 {
+		names = h.Names()
+	}
+
+	isHealthy := true
+	result := map[string]string{}
+	for _, name := range names {
+		check, ok := h[name]
+		if !ok || check == nil {
+			isHealthy = false
+			result[name] = "health check is not registered"
+			continue
+		}
+
+		if err := check(ctx); err != nil {
+			isHealthy = false
+			result[name] = err.Error()
+			continue
+		}
+		result[name] = "ok"
+	}
+
+	return result, isHealthy
+}
+
+// NewWarmupPayload is the payload a scheduler sends to keep a lambda warm
+func NewWarmupPayload() ControlPayload {
+	return ControlPayload{Kind: WarmupControl}
+}
+
+// IsWarmupEvent is whether the payload is the one `infra warm` sends. A
+// runner that is not wrapped by WithControl returns early on it, before the
+// event is decoded for the feature.
+func IsWarmupEvent(payload []byte) bool {
+	p, ok := ParseControlPayload(payload)
+	return ok && p.Kind == WarmupControl
+}
+
+// NewSelfTestPayload is the payload used to run the health checks of a
+// deployed lambda
+func NewSelfTestPayload(checks ...string) ControlPayload {
+	return ControlPayload{Kind: SelfTestControl, Checks: checks}
+}
+
+func (p ControlPayload) Marshal() ([]byte, error) {
+	if !p.Kind.IsValid() {
+		return nil, failure.InvalidParam("control kind (%s) is not valid", p.Kind)
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, failure.ToSystem(err, "json.Marshal failed")
+	}
+
+	return data, nil
+}
+
+// ParseControlPayload reports whether payload is a control payload. Only a
+// json object with a valid `sls` kind qualifies, so regular events are never
+// mistaken for one.
+func ParseControlPayload(payload []byte) (ControlPayload, bool) {
+	var p ControlPayload
+	if len(payload) == 0 || payload[0] != '{' {
+		return p, false
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &keys); err != nil {
+		return p, false
+	}
+
+	if _, ok := keys[ControlPayloadKey]; !ok {
+		return p, false
+	}
+
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return p, false
+	}
+
+	return p, p.Kind.IsValid()
+}
+
+// ControlHandler answers control payloads and forwards every other
+// payload to the wrapped handler
+type ControlHandler struct {
+	next   lambda.Handler
+	checks HealthChecks
+}
+
+// WithControl wraps a lambda handler function, like a runner's Handle, so it
+// understands control payloads
+func WithControl(handlerFn interface{}, checks HealthChecks) *ControlHandler {
+	if checks == nil {
+		checks = HealthChecks{}
+	}
+
+	return &ControlHandler{
+		next:   lambda.NewHandler(handlerFn),
+		checks: checks,
+	}
+}
+
+func (h *ControlHandler) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	p, ok := ParseControlPayload(payload)
+	if !ok {
+		return h.next.Invoke(ctx, payload)
+	}
+
+	resp := h.Control(ctx, p)
+	out, err := json.Marshal(resp)
+	if err != nil {
+		return nil, failure.ToSystem(err, "json.Marshal failed")
+	}
+
+	return out, nil
+}
+
+// Control builds the response for a control payload
+func (h *ControlHandler) Control(ctx context.Context, p ControlPayload) ControlResponse {
+	start := time.Now()
+	resp := ControlResponse{
+		Kind:     p.Kind,
+		Healthy:  true,
+		Function: lambdacontext.FunctionName,
+		Version:  lambdacontext.FunctionVersion,
+	}
+
+	if p.Kind == SelfTestControl {
+		resp.Checks, resp.Healthy = h.checks.Run(ctx, p.Checks...)
+		if err := StartupError(); err != nil {
+			resp.Healthy = false
+			resp.Checks[StartupCheck] = err.Error()
+		}
+	}
+
+	resp.ElapsedMS = time.Since(start).Milliseconds()
+	return resp
+}
@@ -0,0 +1,173 @@
+// Package cwlogs implements a cloudwatch logs client used to manage the log
+// groups that belong to microservice features
+package cwlogs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	LambdaLogGroupPrefix = "/aws/lambda"
+	DefaultRetentionDays = 30
+)
+
+// ValidRetentionDays are the only values cloudwatch accepts for a log group
+// retention policy.
+var ValidRetentionDays = []int32{
+	1, 3, 5, 7, 14, 30, 60, 90, 120, 150, 180, 365, 400, 545, 731, 1096, 1827, 2192, 2557, 2922, 3288, 3653,
+}
+
+type AdapterAPI interface {
+	CreateLogGroup(ctx context.Context, params *cloudwatchlogs.CreateLogGroupInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogGroupOutput, error)
+	PutRetentionPolicy(ctx context.Context, params *cloudwatchlogs.PutRetentionPolicyInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutRetentionPolicyOutput, error)
+	AssociateKmsKey(ctx context.Context, params *cloudwatchlogs.AssociateKmsKeyInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.AssociateKmsKeyOutput, error)
+	FilterLogEvents(ctx context.Context, params *cloudwatchlogs.FilterLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.FilterLogEventsOutput, error)
+	DeleteLogGroup(ctx context.Context, params *cloudwatchlogs.DeleteLogGroupInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DeleteLogGroupOutput, error)
+}
+
+// LogGroupSettings describes the log group a feature should have. A zero
+// RetentionDays leaves the retention policy untouched and an empty KMSKeyARN
+// leaves the encryption untouched.
+type LogGroupSettings struct {
+	Name          string
+	RetentionDays int32
+	KMSKeyARN     string
+}
+
+type LogGroupReport struct {
+	Name          string
+	Created       bool
+	RetentionDays int32
+	KMSKeyARN     string
+}
+
+type Client struct {
+	api         AdapterAPI
+	retryPolicy *retry.Policy
+}
+
+func NewClientWithConfig(cfg aws.Config) *Client {
+	api := cloudwatchlogs.NewFromConfig(retry.NoSDKRetries(cfg))
+	return NewClient(api)
+}
+
+func NewClient(api AdapterAPI) *Client {
+	return &Client{api: api}
+}
+
+// RetryPolicy is the policy the client retries its calls with
+func (c *Client) RetryPolicy() retry.Policy {
+	if c.retryPolicy == nil {
+		return retry.Default()
+	}
+	return *c.retryPolicy
+}
+
+// SetRetryPolicy replaces retry.Default for the calls made by the client
+func (c *Client) SetRetryPolicy(p retry.Policy) {
+	c.retryPolicy = &p
+}
+
+// FeatureLogGroup is the log group aws lambda writes to for a function
+// with the given qualified name
+func FeatureLogGroup(qualifiedName string) string {
+	return fmt.Sprintf("%s/%s", LambdaLogGroupPrefix, qualifiedName)
+}
+
+// EnsureLogGroup will create the log group when it does not exist and then
+// apply the retention policy and kms key from the settings.
+func (c *Client) EnsureLogGroup(ctx context.Context, s LogGroupSettings) (*LogGroupReport, error) {
+	if s.Name == "" {
+		return nil, failure.InvalidParam("[s.Name] log group name is empty")
+	}
+
+	if s.RetentionDays != 0 && !IsValidRetention(s.RetentionDays) {
+		return nil, failure.InvalidParam("[s.RetentionDays] (%d) is not a valid retention, must be one of (%v)", s.RetentionDays, ValidRetentionDays)
+	}
+
+	report := LogGroupReport{Name: s.Name}
+
+	in := cloudwatchlogs.CreateLogGroupInput{
+		LogGroupName: aws.String(s.Name),
+	}
+
+	if s.KMSKeyARN != "" {
+		in.KmsKeyId = aws.String(s.KMSKeyARN)
+	}
+
+	_, err := retry.Call(ctx, c.RetryPolicy(), c.api.CreateLogGroup, &in)
+	switch {
+	case err == nil:
+		report.Created = true
+		report.KMSKeyARN = s.KMSKeyARN
+	case isAlreadyExists(err):
+		if s.KMSKeyARN != "" {
+			kms := cloudwatchlogs.AssociateKmsKeyInput{
+				LogGroupName: aws.String(s.Name),
+				KmsKeyId:     aws.String(s.KMSKeyARN),
+			}
+			if _, err = retry.Call(ctx, c.RetryPolicy(), c.api.AssociateKmsKey, &kms); err != nil {
+				return nil, failure.ToSystem(err, "c.api.AssociateKmsKey failed (%s)", s.Name)
+			}
+			report.KMSKeyARN = s.KMSKeyARN
+		}
+	default:
+		return nil, failure.ToSystem(err, "c.api.CreateLogGroup failed (%s)", s.Name)
+	}
+
+	if s.RetentionDays != 0 {
+		policy := cloudwatchlogs.PutRetentionPolicyInput{
+			LogGroupName:    aws.String(s.Name),
+			RetentionInDays: aws.Int32(s.RetentionDays),
+		}
+
+		if _, err = retry.Call(ctx, c.RetryPolicy(), c.api.PutRetentionPolicy, &policy); err != nil {
+			return nil, failure.ToSystem(err, "c.api.PutRetentionPolicy failed (%s)", s.Name)
+		}
+		report.RetentionDays = s.RetentionDays
+	}
+
+	return &report, nil
+}
+
+// DeleteLogGroup removes the log group and every event in it, a group that
+// does not exist is reported as NotFound
+func (c *Client) DeleteLogGroup(ctx context.Context, name string) error {
+	if name == "" {
+		return failure.InvalidParam("[name] log group name is empty")
+	}
+
+	in := cloudwatchlogs.DeleteLogGroupInput{
+		LogGroupName: aws.String(name),
+	}
+
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.DeleteLogGroup, &in); err != nil {
+		return handleAPIError(err, "c.api.DeleteLogGroup failed (%s)", name)
+	}
+
+	return nil
+}
+
+func IsValidRetention(days int32) bool {
+	for _, d := range ValidRetentionDays {
+		if d == days {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isAlreadyExists(err error) bool {
+	var exists *types.ResourceAlreadyExistsException
+	return errors.As(err, &exists)
+}
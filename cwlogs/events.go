@@ -0,0 +1,146 @@
+package cwlogs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	DefaultTailSince    = 10 * time.Minute
+	DefaultPollInterval = 2 * time.Second
+)
+
+type LogEvent struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Stream    string    `json:"stream"`
+	Message   string    `json:"message"`
+}
+
+// TailSettings selects the events of a log group. Pattern uses the
+// cloudwatch filter pattern syntax, an empty pattern matches everything.
+// With IsFollow Tail keeps polling every PollInterval until ctx is done.
+type TailSettings struct {
+	Group        string
+	Since        time.Duration
+	Pattern      string
+	IsFollow     bool
+	PollInterval time.Duration
+}
+
+// Events returns the events of the group since start that match pattern,
+// oldest first
+func (c *Client) Events(ctx context.Context, group, pattern string, start time.Time) ([]LogEvent, error) {
+	if group == "" {
+		return nil, failure.InvalidParam("[group] log group name is empty")
+	}
+
+	in := cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName: aws.String(group),
+		StartTime:    aws.Int64(start.UnixMilli()),
+	}
+
+	if pattern != "" {
+		in.FilterPattern = aws.String(pattern)
+	}
+
+	var result []LogEvent
+	for {
+		out, err := retry.Call(ctx, c.RetryPolicy(), c.api.FilterLogEvents, &in)
+		if err != nil {
+			return result, handleAPIError(err, "c.api.FilterLogEvents failed (%s)", group)
+		}
+
+		for _, e := range out.Events {
+			result = append(result, LogEvent{
+				ID:        aws.ToString(e.EventId),
+				Timestamp: time.UnixMilli(aws.ToInt64(e.Timestamp)),
+				Stream:    aws.ToString(e.LogStreamName),
+				Message:   aws.ToString(e.Message),
+			})
+		}
+
+		if out.NextToken == nil || aws.ToString(out.NextToken) == aws.ToString(in.NextToken) {
+			break
+		}
+		in.NextToken = out.NextToken
+	}
+
+	return result, nil
+}
+
+// Tail calls fn with every event matching the settings, when following it
+// polls for new events until ctx is done, which is not an error
+func (c *Client) Tail(ctx context.Context, s TailSettings, fn func(e LogEvent) error) error {
+	since := s.Since
+	if since <= 0 {
+		since = DefaultTailSince
+	}
+
+	poll := s.PollInterval
+	if poll <= 0 {
+		poll = DefaultPollInterval
+	}
+
+	start := time.Now().Add(-since)
+	// events sharing the last timestamp come back on the next poll
+	seen := map[string]bool{}
+	for {
+		events, err := c.Events(ctx, s.Group, s.Pattern, start)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return failure.Wrap(err, "c.Events failed")
+		}
+
+		last := map[string]bool{}
+		for _, e := range events {
+			if seen[e.ID] {
+				continue
+			}
+
+			if err = fn(e); err != nil {
+				return err
+			}
+
+			if e.Timestamp.After(start) {
+				start = e.Timestamp
+				last = map[string]bool{}
+			}
+			last[e.ID] = true
+		}
+		if len(last) > 0 {
+			seen = last
+		}
+
+		if !s.IsFollow {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(poll):
+		}
+	}
+}
+
+// handleAPIError reports a missing log group as NotFound, a feature that was
+// never invoked has no log group yet
+func handleAPIError(err error, msg string, a ...interface{}) error {
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return failure.ToNotFound(err, msg, a...)
+	}
+
+	return failure.ToSystem(err, msg, a...)
+}
@@ -0,0 +1,231 @@
+// Package cwmetrics implements a cloudwatch metrics client used to watch the
+// health of microservice features, like the errors of a canary version
+package cwmetrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	LambdaNamespace          = "AWS/Lambda"
+	ErrorsMetric             = "Errors"
+	InvocationsMetric        = "Invocations"
+	ThrottlesMetric          = "Throttles"
+	DurationMetric           = "Duration"
+	ConcurrencyMetric        = "ConcurrentExecutions"
+	FunctionNameDimension    = "FunctionName"
+	ResourceDimension        = "Resource"
+	ExecutedVersionDimension = "ExecutedVersion"
+	DefaultPeriod            = time.Minute
+	MaxDatapoints            = 1440
+
+	// InsightsNamespace is only published for functions with the lambda
+	// insights extension
+	InsightsNamespace         = "LambdaInsights"
+	UsedMemoryMetric          = "used_memory_max"
+	InsightsFunctionDimension = "function_name"
+)
+
+type AdapterAPI interface {
+	GetMetricStatistics(ctx context.Context, params *cloudwatch.GetMetricStatisticsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.GetMetricStatisticsOutput, error)
+}
+
+type Client struct {
+	api         AdapterAPI
+	retryPolicy *retry.Policy
+}
+
+func NewClientWithConfig(cfg aws.Config) *Client {
+	api := cloudwatch.NewFromConfig(retry.NoSDKRetries(cfg))
+	return NewClient(api)
+}
+
+func NewClient(api AdapterAPI) *Client {
+	return &Client{api: api}
+}
+
+// RetryPolicy is the policy the client retries its calls with
+func (c *Client) RetryPolicy() retry.Policy {
+	if c.retryPolicy == nil {
+		return retry.Default()
+	}
+	return *c.retryPolicy
+}
+
+// SetRetryPolicy replaces retry.Default for the calls made by the client
+func (c *Client) SetRetryPolicy(p retry.Policy) {
+	c.retryPolicy = &p
+}
+
+// MetricQuery selects the datapoints of one metric between Start and End.
+// A zero Period is the DefaultPeriod, or coarser when the window has more
+// than MaxDatapoints periods.
+type MetricQuery struct {
+	Namespace  string
+	Name       string
+	Dimensions map[string]string
+	Start      time.Time
+	End        time.Time
+	Period     time.Duration
+}
+
+func (q MetricQuery) Validate() error {
+	if q.Namespace == "" {
+		return failure.InvalidParam("[Namespace] metric namespace is empty")
+	}
+
+	if q.Name == "" {
+		return failure.InvalidParam("[Name] metric name is empty")
+	}
+
+	if !q.End.After(q.Start) {
+		return failure.InvalidParam("[End] (%s) must be after [Start] (%s)", q.End, q.Start)
+	}
+
+	return nil
+}
+
+// LambdaVersionQuery selects the metric of the version of a function that an
+// alias routed its invocations to
+func LambdaVersionQuery(name, functionName, alias, version string, start, end time.Time) MetricQuery {
+	return MetricQuery{
+		Namespace: LambdaNamespace,
+		Name:      name,
+		Dimensions: map[string]string{
+			FunctionNameDimension:    functionName,
+			ResourceDimension:        functionName + ":" + alias,
+			ExecutedVersionDimension: version,
+		},
+		Start: start,
+		End:   end,
+	}
+}
+
+// LambdaFunctionQuery selects the metric of every version of a function
+func LambdaFunctionQuery(name, functionName string, start, end time.Time) MetricQuery {
+	return MetricQuery{
+		Namespace:  LambdaNamespace,
+		Name:       name,
+		Dimensions: map[string]string{FunctionNameDimension: functionName},
+		Start:      start,
+		End:        end,
+	}
+}
+
+// InsightsQuery selects a lambda insights metric of a function
+func InsightsQuery(name, functionName string, start, end time.Time) MetricQuery {
+	return MetricQuery{
+		Namespace:  InsightsNamespace,
+		Name:       name,
+		Dimensions: map[string]string{InsightsFunctionDimension: functionName},
+		Start:      start,
+		End:        end,
+	}
+}
+
+// Sum adds up every datapoint of the query, no datapoints is a sum of 0
+func (c *Client) Sum(ctx context.Context, q MetricQuery) (float64, error) {
+	points, err := c.datapoints(ctx, q, string(types.StatisticSum))
+	if err != nil {
+		return 0, failure.Wrap(err, "c.datapoints failed")
+	}
+
+	var sum float64
+	for _, p := range points {
+		sum += aws.ToFloat64(p.Sum)
+	}
+
+	return sum, nil
+}
+
+// Peak is the highest value of stat across the datapoints of the query. Stat
+// is a statistic like Maximum or a percentile like p99, ok is false when the
+// query has no datapoints.
+func (c *Client) Peak(ctx context.Context, q MetricQuery, stat string) (float64, bool, error) {
+	points, err := c.datapoints(ctx, q, stat)
+	if err != nil {
+		return 0, false, failure.Wrap(err, "c.datapoints failed")
+	}
+
+	var peak float64
+	for idx, p := range points {
+		v := statValue(p, stat)
+		if idx == 0 || v > peak {
+			peak = v
+		}
+	}
+
+	return peak, len(points) > 0, nil
+}
+
+func (c *Client) datapoints(ctx context.Context, q MetricQuery, stat string) ([]types.Datapoint, error) {
+	if err := q.Validate(); err != nil {
+		return nil, failure.Wrap(err, "q.Validate failed")
+	}
+
+	period := q.Period
+	if period <= 0 {
+		period = DefaultPeriod
+		// a query returns at most MaxDatapoints, long windows need a
+		// coarser period
+		if span := q.End.Sub(q.Start); span/period > MaxDatapoints {
+			period = (span/MaxDatapoints/time.Minute + 1) * time.Minute
+		}
+	}
+
+	in := cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String(q.Namespace),
+		MetricName: aws.String(q.Name),
+		StartTime:  aws.Time(q.Start),
+		EndTime:    aws.Time(q.End),
+		Period:     aws.Int32(int32(period / time.Second)),
+	}
+	if isExtended(stat) {
+		in.ExtendedStatistics = []string{stat}
+	} else {
+		in.Statistics = []types.Statistic{types.Statistic(stat)}
+	}
+	for name, value := range q.Dimensions {
+		in.Dimensions = append(in.Dimensions, types.Dimension{Name: aws.String(name), Value: aws.String(value)})
+	}
+
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetMetricStatistics, &in)
+	if err != nil {
+		return nil, failure.ToSystem(err, "c.api.GetMetricStatistics failed (%s/%s)", q.Namespace, q.Name)
+	}
+
+	return out.Datapoints, nil
+}
+
+// isExtended reports whether stat is a percentile, like p99 or p99.9
+func isExtended(stat string) bool {
+	return strings.HasPrefix(stat, "p")
+}
+
+func statValue(p types.Datapoint, stat string) float64 {
+	if isExtended(stat) {
+		return p.ExtendedStatistics[stat]
+	}
+
+	switch types.Statistic(stat) {
+	case types.StatisticMaximum:
+		return aws.ToFloat64(p.Maximum)
+	case types.StatisticMinimum:
+		return aws.ToFloat64(p.Minimum)
+	case types.StatisticAverage:
+		return aws.ToFloat64(p.Average)
+	case types.StatisticSampleCount:
+		return aws.ToFloat64(p.SampleCount)
+	default:
+		return aws.ToFloat64(p.Sum)
+	}
+}
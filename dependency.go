@@ -0,0 +1,207 @@
+package sls
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rsb/failure"
+)
+
+// DependencyKind is the type of resource a feature depends on
+type DependencyKind string
+
+const (
+	TableDependency   DependencyKind = "table"
+	QueueDependency   DependencyKind = "queue"
+	TopicDependency   DependencyKind = "topic"
+	BucketDependency  DependencyKind = "bucket"
+	ParamDependency   DependencyKind = "param"
+	ServiceDependency DependencyKind = "service"
+)
+
+func (k DependencyKind) String() string {
+	return string(k)
+}
+
+// Dependency is a resource outside the feature's code that it needs to run,
+// like a dynamodb table or a downstream service
+type Dependency struct {
+	Kind DependencyKind `json:"kind"`
+	Name string         `json:"name"`
+}
+
+func NewDependency(kind DependencyKind, name string) Dependency {
+	return Dependency{Kind: kind, Name: name}
+}
+
+func Table(name string) Dependency {
+	return NewDependency(TableDependency, name)
+}
+
+func Queue(name string) Dependency {
+	return NewDependency(QueueDependency, name)
+}
+
+func Topic(name string) Dependency {
+	return NewDependency(TopicDependency, name)
+}
+
+func Bucket(name string) Dependency {
+	return NewDependency(BucketDependency, name)
+}
+
+func Param(name string) Dependency {
+	return NewDependency(ParamDependency, name)
+}
+
+func DownstreamService(name string) Dependency {
+	return NewDependency(ServiceDependency, name)
+}
+
+// ID uniquely identifies the dependency in a graph, ex `table:users`
+func (d Dependency) ID() string {
+	return fmt.Sprintf("%s:%s", d.Kind, d.Name)
+}
+
+// DependsOn returns a copy of the feature with the dependencies added
+func (l Feature) DependsOn(deps ...Dependency) Feature {
+	l.Dependencies = append(append([]Dependency{}, l.Dependencies...), deps...)
+	return l
+}
+
+// AllDependencies are the declared dependencies plus a param dependency for
+// every env var of the feature's configuration. The params of a feature
+// scoped feature are named `<feature>/<KEY>` since no one else reads them.
+func (l Feature) AllDependencies() ([]Dependency, error) {
+	deps := append([]Dependency{}, l.Dependencies...)
+	if l.Conf == nil {
+		return deps, nil
+	}
+
+	names, err := l.Conf.EnvNames()
+	if err != nil {
+		return nil, failure.Wrap(err, "l.Conf.EnvNames failed (%s)", l.Name)
+	}
+
+	for _, name := range names {
+		if l.IsFeatureScoped() {
+			name = fmt.Sprintf("%s/%s", l.Name, name)
+		}
+		deps = append(deps, Param(name))
+	}
+
+	return deps, nil
+}
+
+type DependencyEdge struct {
+	Feature    string     `json:"feature"`
+	Dependency Dependency `json:"dependency"`
+}
+
+// DependencyGraph connects the features of a service to the resources they
+// depend on
+type DependencyGraph struct {
+	Service   string           `json:"service"`
+	Features  []string         `json:"features"`
+	Resources []Dependency     `json:"resources"`
+	Edges     []DependencyEdge `json:"edges"`
+}
+
+func NewDependencyGraph(m *MicroService) (DependencyGraph, error) {
+	var g DependencyGraph
+	if m == nil {
+		return g, failure.System("m is nil, a MicroService is required")
+	}
+	g.Service = m.Name.AppTitle()
+
+	resources := map[string]Dependency{}
+	for name, feature := range m.Features {
+		g.Features = append(g.Features, name)
+
+		deps, err := feature.AllDependencies()
+		if err != nil {
+			return g, failure.Wrap(err, "feature.AllDependencies failed")
+		}
+
+		seen := map[string]bool{}
+		for _, d := range deps {
+			if seen[d.ID()] {
+				continue
+			}
+			seen[d.ID()] = true
+			resources[d.ID()] = d
+			g.Edges = append(g.Edges, DependencyEdge{Feature: name, Dependency: d})
+		}
+	}
+
+	for _, d := range resources {
+		g.Resources = append(g.Resources, d)
+	}
+
+	sort.Strings(g.Features)
+	sort.Slice(g.Resources, func(a, b int) bool {
+		return g.Resources[a].ID() < g.Resources[b].ID()
+	})
+	sort.Slice(g.Edges, func(a, b int) bool {
+		if g.Edges[a].Feature != g.Edges[b].Feature {
+			return g.Edges[a].Feature < g.Edges[b].Feature
+		}
+		return g.Edges[a].Dependency.ID() < g.Edges[b].Dependency.ID()
+	})
+
+	return g, nil
+}
+
+// Dependents are the features that depend on d
+func (g DependencyGraph) Dependents(d Dependency) []string {
+	var features []string
+	for _, e := range g.Edges {
+		if e.Dependency == d {
+			features = append(features, e.Feature)
+		}
+	}
+
+	return features
+}
+
+// SharedWith reports, for every dependency of feature, the other features
+// that also depend on it. Removing something from one feature affects them.
+func (g DependencyGraph) SharedWith(feature string) map[string][]string {
+	result := map[string][]string{}
+	for _, e := range g.Edges {
+		if e.Feature != feature {
+			continue
+		}
+
+		for _, other := range g.Dependents(e.Dependency) {
+			if other != feature {
+				result[e.Dependency.ID()] = append(result[e.Dependency.ID()], other)
+			}
+		}
+	}
+
+	return result
+}
+
+// DOT renders the graph in graphviz format
+func (g DependencyGraph) DOT() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("digraph %q {\n", g.Service))
+	b.WriteString("\trankdir=LR;\n")
+
+	for _, f := range g.Features {
+		b.WriteString(fmt.Sprintf("\t%q [shape=box];\n", f))
+	}
+
+	for _, d := range g.Resources {
+		b.WriteString(fmt.Sprintf("\t%q [shape=ellipse, label=%q];\n", d.ID(), d.Kind.String()+"\\n"+d.Name))
+	}
+
+	for _, e := range g.Edges {
+		b.WriteString(fmt.Sprintf("\t%q -> %q;\n", e.Feature, e.Dependency.ID()))
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -33,7 +33,7 @@ func NewKey(org, cat, domain string) (Key, error) {
 		return key, err
 	}
 
-	cat, err = validateKeyProperty("domain", domain, sep)
+	domain, err = validateKeyProperty("domain", domain, sep)
 	if err != nil {
 		return key, err
 	}
@@ -68,6 +68,32 @@ func (k Key) Separator() string {
 	return k.sep
 }
 
+// IsZero is true for a key that was not built with NewKey
+func (k Key) IsZero() bool {
+	return k.org == "" && k.cat == "" && k.domain == ""
+}
+
+// Path is the parameter store path of the key under root, like
+// `/root/org/cat/domain`. An empty root starts the path at the org.
+func (k Key) Path(root string) string {
+	parts := []string{k.org, k.cat, k.domain}
+	if root = strings.Trim(root, "/"); root != "" {
+		parts = append([]string{root}, parts...)
+	}
+
+	return "/" + strings.Join(parts, "/")
+}
+
+// PartitionKey is the dynamodb hash key of the key with a prefix, like
+// `prefix:org:cat:domain`. An empty prefix is the key itself.
+func (k Key) PartitionKey(prefix string) string {
+	if prefix == "" {
+		return k.String()
+	}
+
+	return prefix + k.sep + k.String()
+}
+
 func validateKeyProperty(name, value, sep string) (string, error) {
 	var safe string
 	if value == "" {
@@ -0,0 +1,162 @@
+package domain
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/rsb/failure"
+)
+
+// KeySet is a collection of unique keys, kept in the order they were added
+type KeySet struct {
+	keys  []Key
+	index map[string]struct{}
+}
+
+func NewKeySet(keys ...Key) *KeySet {
+	s := KeySet{index: map[string]struct{}{}}
+	s.Add(keys...)
+	return &s
+}
+
+// Add puts the keys in the set, keys already in it are ignored. It reports
+// how many keys were added.
+func (s *KeySet) Add(keys ...Key) int {
+	if s.index == nil {
+		s.index = map[string]struct{}{}
+	}
+
+	var added int
+	for _, k := range keys {
+		id := k.String()
+		if _, ok := s.index[id]; ok {
+			continue
+		}
+		s.index[id] = struct{}{}
+		s.keys = append(s.keys, k)
+		added++
+	}
+
+	return added
+}
+
+func (s *KeySet) Remove(k Key) bool {
+	id := k.String()
+	if _, ok := s.index[id]; !ok {
+		return false
+	}
+	delete(s.index, id)
+
+	for idx, item := range s.keys {
+		if item.String() == id {
+			s.keys = append(s.keys[:idx], s.keys[idx+1:]...)
+			break
+		}
+	}
+
+	return true
+}
+
+func (s *KeySet) Has(k Key) bool {
+	_, ok := s.index[k.String()]
+	return ok
+}
+
+func (s *KeySet) Len() int {
+	return len(s.keys)
+}
+
+// Keys is a copy of the keys in the order they were added
+func (s *KeySet) Keys() []Key {
+	keys := make([]Key, len(s.keys))
+	copy(keys, s.keys)
+	return keys
+}
+
+// Strings are the keys as strings, sorted
+func (s *KeySet) Strings() []string {
+	result := make([]string, 0, len(s.keys))
+	for _, k := range s.keys {
+		result = append(result, k.String())
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ByCategory groups the keys by their category
+func (s *KeySet) ByCategory() map[string]*KeySet {
+	return s.group(Key.Category)
+}
+
+// ByOrg groups the keys by their org
+func (s *KeySet) ByOrg() map[string]*KeySet {
+	return s.group(Key.Org)
+}
+
+func (s *KeySet) group(by func(Key) string) map[string]*KeySet {
+	result := map[string]*KeySet{}
+	for _, k := range s.keys {
+		name := by(k)
+		if _, ok := result[name]; !ok {
+			result[name] = NewKeySet()
+		}
+		result[name].Add(k)
+	}
+
+	return result
+}
+
+// Org is the org shared by every key, it fails when the set is empty or the
+// keys belong to more than one org
+func (s *KeySet) Org() (string, error) {
+	if err := s.Validate(); err != nil {
+		return "", err
+	}
+
+	return s.keys[0].Org(), nil
+}
+
+// Validate makes sure the set is not empty, has no zero keys and that all of
+// its keys share an org
+func (s *KeySet) Validate() error {
+	if s.Len() == 0 {
+		return failure.InvalidParam("key set is empty")
+	}
+
+	for _, k := range s.keys {
+		if k.IsZero() {
+			return failure.InvalidParam("key set has a zero key, keys must be built with NewKey")
+		}
+	}
+
+	orgs := s.ByOrg()
+	if len(orgs) > 1 {
+		names := make([]string, 0, len(orgs))
+		for name := range orgs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return failure.InvalidParam("keys belong to (%d) orgs (%s), only one is allowed", len(orgs), strings.Join(names, ", "))
+	}
+
+	return nil
+}
+
+// Paths are the parameter store paths of the keys under root, see Key.Path
+func (s *KeySet) Paths(root string) []string {
+	result := make([]string, 0, len(s.keys))
+	for _, k := range s.keys {
+		result = append(result, k.Path(root))
+	}
+	return result
+}
+
+// PartitionKeys are the dynamodb hash keys of the keys with a prefix, see
+// Key.PartitionKey
+func (s *KeySet) PartitionKeys(prefix string) []string {
+	result := make([]string, 0, len(s.keys))
+	for _, k := range s.keys {
+		result = append(result, k.PartitionKey(prefix))
+	}
+	return result
+}
@@ -0,0 +1,135 @@
+// Package dotenv reads and writes the KEY=value format used by .env files,
+// so params and env vars can be sourced by shells and docker compose
+package dotenv
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rsb/failure"
+)
+
+// Marshal writes one KEY=value line per entry, sorted by key. Values are
+// double-quoted whenever a shell would otherwise split or expand them.
+func Marshal(in map[string]string) []byte {
+	keys := make([]string, 0, len(in))
+	for k := range in {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, k := range keys {
+		buf.WriteString(fmt.Sprintf("%s=%s\n", k, Quote(in[k])))
+	}
+
+	return buf.Bytes()
+}
+
+// Quote returns the value as it should appear on the right side of the `=`
+func Quote(v string) string {
+	if v == "" {
+		return `""`
+	}
+
+	if !strings.ContainsAny(v, " \t\n\r\"'`$\\#=") {
+		return v
+	}
+
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"$", `\$`,
+		"`", "\\`",
+		"\n", `\n`,
+		"\r", `\r`,
+	)
+
+	return `"` + r.Replace(v) + `"`
+}
+
+// Unmarshal parses .env data. Blank lines, # comments and an optional
+// `export ` prefix are supported. Double-quoted values are unescaped,
+// single-quoted values are taken literally and unquoted values have any
+// trailing ` #comment` removed.
+func Unmarshal(data []byte) (map[string]string, error) {
+	result := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	num := 0
+	for scanner.Scan() {
+		num++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		idx := strings.Index(line, "=")
+		if idx < 1 {
+			return nil, failure.Validation("line (%d) is not in the form of KEY=value", num)
+		}
+
+		key := strings.TrimSpace(line[:idx])
+		value, err := unquote(strings.TrimSpace(line[idx+1:]))
+		if err != nil {
+			return nil, failure.Wrap(err, "unquote failed on line (%d)", num)
+		}
+
+		result[key] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, failure.ToSystem(err, "scanner.Scan failed")
+	}
+
+	return result, nil
+}
+
+func unquote(v string) (string, error) {
+	if v == "" {
+		return v, nil
+	}
+
+	switch v[0] {
+	case '\'':
+		end := strings.LastIndex(v, "'")
+		if end == 0 {
+			return "", failure.Validation("single quoted value is not terminated")
+		}
+		return v[1:end], nil
+	case '"':
+		var out strings.Builder
+		for i := 1; i < len(v); i++ {
+			c := v[i]
+			switch {
+			case c == '"':
+				return out.String(), nil
+			case c == '\\' && i+1 < len(v):
+				i++
+				switch v[i] {
+				case 'n':
+					out.WriteByte('\n')
+				case 'r':
+					out.WriteByte('\r')
+				case 't':
+					out.WriteByte('\t')
+				default:
+					out.WriteByte(v[i])
+				}
+			default:
+				out.WriteByte(c)
+			}
+		}
+		return "", failure.Validation("double quoted value is not terminated")
+	}
+
+	if idx := strings.Index(v, " #"); idx >= 0 {
+		v = strings.TrimSpace(v[:idx])
+	}
+
+	return v, nil
+}
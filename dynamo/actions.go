@@ -5,6 +5,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
 )
 
 func (c *Client) Item(ctx context.Context, key Keyable) (map[string]types.AttributeValue, error) {
@@ -14,7 +15,7 @@ func (c *Client) Item(ctx context.Context, key Keyable) (map[string]types.Attrib
 		in.ExpressionAttributeNames = names
 	}
 
-	out, err := c.api.GetItem(ctx, in)
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetItem, in)
 	if err != nil {
 		return nil, failure.ToSystem(err, "c.api.GetItem failed (%+v)", in)
 	}
@@ -29,7 +30,7 @@ func (c *Client) Item(ctx context.Context, key Keyable) (map[string]types.Attrib
 func (c *Client) Put(ctx context.Context, item map[string]types.AttributeValue) error {
 	in := c.NewPutInput(item)
 
-	if _, err := c.api.PutItem(ctx, in); err != nil {
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.PutItem, in); err != nil {
 		return failure.ToSystem(err, "c.api.PutItem failed")
 	}
 
@@ -50,7 +51,7 @@ func (c *Client) Write(ctx context.Context, row Writable) error {
 		}
 	}
 
-	if _, err = c.api.PutItem(ctx, in); err != nil {
+	if _, err = retry.Call(ctx, c.RetryPolicy(), c.api.PutItem, in); err != nil {
 		return failure.Wrap(err, "c.api.PutItem failed")
 	}
 
@@ -60,7 +61,7 @@ func (c *Client) Write(ctx context.Context, row Writable) error {
 func (c *Client) Delete(ctx context.Context, key Keyable) error {
 	in := c.NewDeleteInput(key.Full())
 
-	if _, err := c.api.DeleteItem(ctx, in); err != nil {
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.DeleteItem, in); err != nil {
 		return failure.ToSystem(err, "c.api.DeleteItem failed (%s)", key.FormatForError())
 	}
 
@@ -0,0 +1,186 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rsb/failure"
+
+	"github.com/rsb/sls/clock"
+	"github.com/rsb/sls/domain"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	// DedupPrefix starts the hash key of every dedup row so they never
+	// collide with the domain rows of a shared table
+	DedupPrefix = "dedup"
+	// TTLKeyName must be set as the time to live attribute of the table,
+	// dynamodb deletes a row some time after it expires
+	TTLKeyName      = "ttl"
+	DefaultDedupTTL = 24 * time.Hour
+)
+
+// Dedup drops the duplicates of at least once deliveries (sns, sqs). A
+// message is recorded under the domain key of its feature the first time it
+// is seen, any delivery of the same message id before the row expires is a
+// duplicate. Unlike an idempotency wrapper nothing about the result is
+// stored, which makes it a cheap fit for read only handlers.
+type Dedup struct {
+	client *Client
+	ttl    time.Duration
+	clock  *clock.Clock
+}
+
+// NewDedup records messages in the table of c for ttl, a ttl of 0 is the
+// DefaultDedupTTL. The ttl should outlive the retention of the queue.
+func NewDedup(c *Client, ttl time.Duration) (*Dedup, error) {
+	if c == nil {
+		return nil, failure.InvalidParam("[c] dynamo client is nil")
+	}
+
+	if ttl < 0 {
+		return nil, failure.InvalidParam("[ttl] (%s) must be >= 0", ttl)
+	}
+
+	if ttl == 0 {
+		ttl = DefaultDedupTTL
+	}
+
+	return &Dedup{client: c, ttl: ttl}, nil
+}
+
+// WithClock is used to control the expiry of rows in tests
+func (d *Dedup) WithClock(c *clock.Clock) *Dedup {
+	d.clock = c
+	return d
+}
+
+// DedupKey is the row of message id under the domain key
+func DedupKey(key domain.Key, id string) *Key {
+	return &Key{
+		Hash:   key.PartitionKey(DedupPrefix),
+		Sort:   id,
+		Domain: key.String(),
+	}
+}
+
+// IsDuplicate records the message and reports whether it was already seen.
+// A row that expired but was not yet deleted by dynamodb is replaced, so
+// the message counts as new again.
+func (d *Dedup) IsDuplicate(ctx context.Context, key domain.Key, id string) (bool, error) {
+	if id == "" {
+		return false, failure.InvalidParam("[id] message id is empty")
+	}
+
+	now := d.clock.Now()
+	k := DedupKey(key, id)
+	item := k.Full()
+	item[DomainKeyName] = &types.AttributeValueMemberS{Value: k.Domain}
+	item[TTLKeyName] = &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(d.ttl).Unix(), 10)}
+
+	in := d.client.NewPutInput(item, "attribute_not_exists(#pk) OR #ttl < :now")
+	in.ExpressionAttributeNames = map[string]string{
+		"#pk":  HashKeyName,
+		"#ttl": TTLKeyName,
+	}
+	in.ExpressionAttributeValues = map[string]types.AttributeValue{
+		":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+	}
+
+	_, err := retry.Call(ctx, d.client.RetryPolicy(), d.client.api.PutItem, in)
+	if err == nil {
+		return false, nil
+	}
+
+	var exists *types.ConditionalCheckFailedException
+	if errors.As(err, &exists) {
+		return true, nil
+	}
+
+	return false, failure.ToSystem(err, "c.api.PutItem failed (%s)", k.FormatForError())
+}
+
+// Forget removes the record of a message, call it when the handler failed
+// so the redelivery is not dropped as a duplicate
+func (d *Dedup) Forget(ctx context.Context, key domain.Key, id string) error {
+	k := DedupKey(key, id)
+	in := d.client.NewDeleteInput(k.Full())
+	if _, err := retry.Call(ctx, d.client.RetryPolicy(), d.client.api.DeleteItem, in); err != nil {
+		return failure.ToSystem(err, "c.api.DeleteItem failed (%s)", k.FormatForError())
+	}
+
+	return nil
+}
+
+// Once runs fn unless the message is a duplicate and reports whether it ran.
+// When fn fails the message is forgotten and the error of fn is returned.
+func (d *Dedup) Once(ctx context.Context, key domain.Key, id string, fn func(context.Context) error) (bool, error) {
+	isDuplicate, err := d.IsDuplicate(ctx, key, id)
+	if err != nil {
+		return false, failure.Wrap(err, "d.IsDuplicate failed")
+	}
+
+	if isDuplicate {
+		return false, nil
+	}
+
+	if err = fn(ctx); err != nil {
+		if e := d.Forget(ctx, key, id); e != nil {
+			return true, failure.Wrap(err, "fn failed and d.Forget failed: %s", e)
+		}
+		return true, err
+	}
+
+	return true, nil
+}
+
+// FilterSQS drops the duplicates of a batch, the messages are returned in
+// the order received
+func (d *Dedup) FilterSQS(ctx context.Context, key domain.Key, event events.SQSEvent) ([]events.SQSMessage, error) {
+	result := make([]events.SQSMessage, 0, len(event.Records))
+	for _, msg := range event.Records {
+		isDuplicate, err := d.IsDuplicate(ctx, key, SQSMessageID(msg))
+		if err != nil {
+			return nil, failure.Wrap(err, "d.IsDuplicate failed (%s)", msg.MessageId)
+		}
+
+		if !isDuplicate {
+			result = append(result, msg)
+		}
+	}
+
+	return result, nil
+}
+
+// FilterSNS drops the duplicates of an sns delivery
+func (d *Dedup) FilterSNS(ctx context.Context, key domain.Key, event events.SNSEvent) ([]events.SNSEventRecord, error) {
+	result := make([]events.SNSEventRecord, 0, len(event.Records))
+	for _, r := range event.Records {
+		isDuplicate, err := d.IsDuplicate(ctx, key, r.SNS.MessageID)
+		if err != nil {
+			return nil, failure.Wrap(err, "d.IsDuplicate failed (%s)", r.SNS.MessageID)
+		}
+
+		if !isDuplicate {
+			result = append(result, r)
+		}
+	}
+
+	return result, nil
+}
+
+// SQSMessageID is the id a message is deduplicated by. For a fifo queue
+// the deduplication id is used, for every other queue the message id, which
+// sqs keeps for every delivery of the same message.
+func SQSMessageID(msg events.SQSMessage) string {
+	if id, ok := msg.Attributes["MessageDeduplicationId"]; ok && id != "" {
+		return id
+	}
+
+	return msg.MessageId
+}
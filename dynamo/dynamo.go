@@ -9,6 +9,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
 )
 
 const (
@@ -51,8 +52,9 @@ type Writable interface {
 }
 
 type Client struct {
-	api APIBehavior
-	tbl Table
+	api         APIBehavior
+	tbl         Table
+	retryPolicy *retry.Policy
 }
 
 func NewClient(api APIBehavior, tbl Table) (*Client, error) {
@@ -72,6 +74,19 @@ func NewClient(api APIBehavior, tbl Table) (*Client, error) {
 	return &client, nil
 }
 
+// RetryPolicy is the policy the client retries its calls with
+func (c *Client) RetryPolicy() retry.Policy {
+	if c.retryPolicy == nil {
+		return retry.Default()
+	}
+	return *c.retryPolicy
+}
+
+// SetRetryPolicy replaces retry.Default for the calls made by the client
+func (c *Client) SetRetryPolicy(p retry.Policy) {
+	c.retryPolicy = &p
+}
+
 func (c *Client) TableName() string {
 	return c.tbl.Name()
 }
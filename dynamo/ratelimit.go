@@ -0,0 +1,96 @@
+package dynamo
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/rsb/sls/ratelimit"
+)
+
+// LimitedAPI waits on a rate limiter before every call to api, use it to
+// cap the request rate of backfills
+type LimitedAPI struct {
+	api     APIBehavior
+	limiter *ratelimit.Limiter
+}
+
+func NewLimitedAPI(api APIBehavior, l *ratelimit.Limiter) APIBehavior {
+	return &LimitedAPI{api: api, limiter: l}
+}
+
+func (a *LimitedAPI) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.GetItem(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.BatchGetItem(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.BatchWriteItem(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.DeleteItem(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.PutItem(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.Query(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.Scan(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) TransactGetItems(ctx context.Context, params *dynamodb.TransactGetItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactGetItemsOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.TransactGetItems(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.TransactWriteItems(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.UpdateItem(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.UpdateTimeToLive(ctx, params, optFns...)
+}
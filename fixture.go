@@ -0,0 +1,191 @@
+package sls
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rsb/failure"
+)
+
+const (
+	// FixtureAccount and FixtureRegion fill the arns of generated events
+	FixtureAccount = "123456789012"
+	FixtureRegion  = "us-east-1"
+)
+
+// FixtureTime is the time of every generated event so fixtures are stable
+var FixtureTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// EventFixture is a skeleton of the event a feature of the trigger receives,
+// the names of the queue, topic, bucket, table or stream and the values are
+// made up from name. Edit it before invoking with it. CognitoTrigger has too
+// many shapes to guess one and is an error.
+func EventFixture(trigger InvokeTrigger, name string) (interface{}, error) {
+	arn := func(service, resource string) string {
+		return fmt.Sprintf("arn:aws:%s:%s:%s:%s", service, FixtureRegion, FixtureAccount, resource)
+	}
+
+	switch trigger {
+	case APIGWProxyTrigger:
+		return events.APIGatewayProxyRequest{
+			Resource:   "/" + name,
+			Path:       "/" + name,
+			HTTPMethod: "GET",
+			Headers:    map[string]string{"Content-Type": "application/json"},
+			RequestContext: events.APIGatewayProxyRequestContext{
+				AccountID:  FixtureAccount,
+				Stage:      "dev",
+				RequestID:  "fixture-request-id",
+				HTTPMethod: "GET",
+				Identity:   events.APIGatewayRequestIdentity{SourceIP: "127.0.0.1"},
+			},
+		}, nil
+	case APIGWCustomAuthTrigger:
+		return events.APIGatewayCustomAuthorizerRequest{
+			Type:               "TOKEN",
+			AuthorizationToken: "Bearer fixture-token",
+			MethodArn:          arn("execute-api", "abcdef1234/dev/GET/"+name),
+		}, nil
+	case FunctionURLTrigger:
+		return events.LambdaFunctionURLRequest{
+			Version: "2.0",
+			RawPath: "/" + name,
+			Headers: map[string]string{"content-type": "application/json"},
+			RequestContext: events.LambdaFunctionURLRequestContext{
+				AccountID: FixtureAccount,
+				RequestID: "fixture-request-id",
+				HTTP: events.LambdaFunctionURLRequestContextHTTPDescription{
+					Method:   "GET",
+					Path:     "/" + name,
+					SourceIP: "127.0.0.1",
+				},
+			},
+		}, nil
+	case SQSTrigger:
+		return events.SQSEvent{Records: []events.SQSMessage{{
+			MessageId:      "fixture-message-id",
+			ReceiptHandle:  "fixture-receipt-handle",
+			Body:           "{}",
+			Attributes:     map[string]string{"ApproximateReceiveCount": "1"},
+			EventSource:    "aws:sqs",
+			EventSourceARN: arn("sqs", name),
+			AWSRegion:      FixtureRegion,
+		}}}, nil
+	case SNSTrigger:
+		return events.SNSEvent{Records: []events.SNSEventRecord{{
+			EventVersion: "1.0",
+			EventSource:  "aws:sns",
+			SNS: events.SNSEntity{
+				MessageID: "fixture-message-id",
+				Type:      "Notification",
+				TopicArn:  arn("sns", name),
+				Message:   "{}",
+				Timestamp: FixtureTime,
+			},
+		}}}, nil
+	case S3Trigger:
+		return events.S3Event{Records: []events.S3EventRecord{{
+			EventVersion: "2.1",
+			EventSource:  "aws:s3",
+			AWSRegion:    FixtureRegion,
+			EventTime:    FixtureTime,
+			EventName:    "ObjectCreated:Put",
+			S3: events.S3Entity{
+				Bucket: events.S3Bucket{Name: name, Arn: "arn:aws:s3:::" + name},
+				Object: events.S3Object{Key: "fixture.json", Size: 2},
+			},
+		}}}, nil
+	case DDBTrigger, DDBStreamTrigger:
+		return events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{{
+			EventID:        "fixture-event-id",
+			EventName:      "INSERT",
+			EventSource:    "aws:dynamodb",
+			EventVersion:   "1.1",
+			AWSRegion:      FixtureRegion,
+			EventSourceArn: arn("dynamodb", "table/"+name+"/stream/2024-01-01T00:00:00.000"),
+			Change: events.DynamoDBStreamRecord{
+				Keys:           map[string]events.DynamoDBAttributeValue{"id": events.NewStringAttribute("fixture-id")},
+				NewImage:       map[string]events.DynamoDBAttributeValue{"id": events.NewStringAttribute("fixture-id")},
+				SequenceNumber: "1",
+				StreamViewType: "NEW_IMAGE",
+			},
+		}}}, nil
+	case KinesisStreamTrigger:
+		return events.KinesisEvent{Records: []events.KinesisEventRecord{{
+			EventID:        "shardId-000000000000:1",
+			EventName:      "aws:kinesis:record",
+			EventSource:    "aws:kinesis",
+			EventVersion:   "1.0",
+			AwsRegion:      FixtureRegion,
+			EventSourceArn: arn("kinesis", "stream/"+name),
+			Kinesis: events.KinesisRecord{
+				Data:                 []byte("{}"),
+				PartitionKey:         "fixture",
+				SequenceNumber:       "1",
+				KinesisSchemaVersion: "1.0",
+			},
+		}}}, nil
+	case CloudWatchEventTrigger:
+		return events.CloudWatchEvent{
+			Version:    "0",
+			ID:         "fixture-event-id",
+			DetailType: "Scheduled Event",
+			Source:     "aws.events",
+			AccountID:  FixtureAccount,
+			Time:       FixtureTime,
+			Region:     FixtureRegion,
+			Resources:  []string{arn("events", "rule/"+name)},
+			Detail:     json.RawMessage("{}"),
+		}, nil
+	case CloudWatchLogsTrigger:
+		return cloudwatchLogsFixture(name)
+	case AppSyncTrigger:
+		return AppSyncResolverEvent{
+			Arguments: json.RawMessage("{}"),
+			Request:   AppSyncRequest{Headers: map[string]string{}},
+			Info:      AppSyncInfo{FieldName: name, ParentTypeName: "Query", Variables: map[string]interface{}{}},
+		}, nil
+	case DirectTrigger, StepTrigger:
+		return map[string]interface{}{}, nil
+	default:
+		return nil, failure.InvalidParam("trigger (%s) has no event fixture", trigger)
+	}
+}
+
+// cloudwatchLogsFixture is a subscription event, the log events are gzipped
+// and base64 encoded like cloudwatch sends them
+func cloudwatchLogsFixture(name string) (interface{}, error) {
+	data := events.CloudwatchLogsData{
+		Owner:               FixtureAccount,
+		LogGroup:            "/aws/lambda/" + name,
+		LogStream:           "fixture-stream",
+		SubscriptionFilters: []string{name},
+		MessageType:         "DATA_MESSAGE",
+		LogEvents: []events.CloudwatchLogsLogEvent{
+			{ID: "1", Timestamp: FixtureTime.UnixMilli(), Message: "fixture log line"},
+		},
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, failure.ToSystem(err, "json.Marshal failed")
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err = zw.Write(raw); err != nil {
+		return nil, failure.ToSystem(err, "zw.Write failed")
+	}
+	if err = zw.Close(); err != nil {
+		return nil, failure.ToSystem(err, "zw.Close failed")
+	}
+
+	return events.CloudwatchLogsEvent{
+		AWSLogs: events.CloudwatchLogsRawData{Data: base64.StdEncoding.EncodeToString(buf.Bytes())},
+	}, nil
+}
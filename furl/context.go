@@ -0,0 +1,30 @@
+package furl
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	invocationEvent ctxKey = "event"
+)
+
+// contextKey is an internal type used for context keys to restrict access
+// to the context values via the various Get methods.
+type ctxKey string
+
+// setEvent sets the function url request in the context.
+func setEvent(ctx context.Context, req events.LambdaFunctionURLRequest) context.Context {
+	return context.WithValue(ctx, invocationEvent, req)
+}
+
+// GetEvent gets the function url request from the context. Defaults to a new empty instance if not set.
+func GetEvent(ctx context.Context) events.LambdaFunctionURLRequest {
+	val := ctx.Value(invocationEvent)
+	req, ok := val.(events.LambdaFunctionURLRequest)
+	if !ok {
+		req = events.LambdaFunctionURLRequest{}
+	}
+	return req
+}
@@ -0,0 +1,188 @@
+// Package furl is the lambda handling front controller for microservice
+// features exposed through a lambda function url with the RESPONSE_STREAM
+// invoke mode. The feature writes its body in chunks, which makes it a fit
+// for large exports and server sent events. Response streaming requires the
+// provided.al2 runtime, or building with `-tags lambda.norpc`.
+package furl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/rsb/failure"
+	"go.uber.org/zap"
+
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/apigw"
+	"github.com/rsb/sls/logging"
+	"github.com/rsb/sls/slsctx"
+)
+
+const (
+	HeaderContentType    = "Content-Type"
+	JsonMediaType        = "application/json"
+	NDJsonMediaType      = "application/x-ndjson"
+	EventStreamMediaType = "text/event-stream"
+)
+
+type HandlerConfig struct {
+	sls.TimeoutConfig
+}
+
+// StreamHandler writes the response of a function url request to w. An error
+// returned before anything was written becomes a json error response, after
+// that the stream is aborted and the client sees a truncated body.
+type StreamHandler interface {
+	Run(ctx context.Context, req events.LambdaFunctionURLRequest, w *StreamWriter) error
+}
+
+func LambdaStart(config HandlerConfig, h StreamHandler, logger *zap.SugaredLogger) {
+	runner := NewStreamRunner(config, h, logger)
+	// the control handler buffers the response, so the runner answers
+	// control payloads itself and is started directly
+	lambda.Start(runner.Handle)
+}
+
+type StreamRunner struct {
+	feature StreamHandler
+	logger  *zap.SugaredLogger
+	timeout sls.TimeoutCapturing
+	meta    slsctx.Metadata
+	control *sls.ControlHandler
+}
+
+func NewStreamRunner(c HandlerConfig, h StreamHandler, l *zap.SugaredLogger) *StreamRunner {
+	r := &StreamRunner{
+		feature: h,
+		logger:  l,
+		timeout: sls.NewTimeout(c.TimeoutConfig),
+		meta:    slsctx.FromEnvironment(),
+	}
+	// only Control is used, the payload never reaches the wrapped Handle
+	r.control = sls.WithControl(r.Handle, sls.HealthChecksFrom(h))
+
+	return r
+}
+
+// Handle returns as soon as the feature sent the prologue, the body keeps
+// streaming from the feature's goroutine while the runtime reads it
+func (r *StreamRunner) Handle(ctx context.Context, payload json.RawMessage) (*events.LambdaFunctionURLStreamingResponse, error) {
+	if p, ok := sls.ParseControlPayload(payload); ok {
+		return jsonResponse(http.StatusOK, r.control.Control(ctx, p))
+	}
+
+	var req events.LambdaFunctionURLRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, failure.ToInvalidParam(err, "payload is not a function url request")
+	}
+
+	ctx = slsctx.Set(ctx, r.meta)
+	ctx = setEvent(ctx, req)
+	logger := StreamLogger(ctx, r.logger, req)
+	ctx = logging.SetInvocationLogger(ctx, logger)
+
+	pr, pw := io.Pipe()
+	w := newStreamWriter(pw)
+	failed := make(chan error, 1)
+
+	start := time.Now()
+	go func() {
+		handlerFn := func() (out interface{}, err error) {
+			return out, r.feature.Run(ctx, req, w)
+		}
+
+		err := sls.StartupError()
+		if err == nil {
+			_, err = r.timeout.WithTimeConstraint(ctx, handlerFn)
+		}
+		elapsed := time.Since(start) / time.Millisecond
+
+		if err == nil {
+			// a feature that wrote nothing still sends its prologue
+			w.WriteHeader(http.StatusOK)
+			_ = pw.Close()
+			logger.With("elapsed_ms", elapsed).Info(req.RawPath)
+			return
+		}
+
+		l := logger.With("elapsed_ms", elapsed)
+		if sls.IsMisconfigured(err) {
+			l = l.With("misconfigured", true, sls.MisconfiguredMetric, 1)
+		}
+		if failure.IsTimeout(err) {
+			l = l.With("timeout", true)
+		}
+		if p, ok := sls.PanicDetails(err); ok {
+			l = l.With("panic", true, "panic_id", p.ID)
+		}
+
+		if w.abort() {
+			failed <- err
+			l.Error(err.Error())
+			return
+		}
+
+		l.With("aborted", true).Error(err.Error())
+		_ = pw.CloseWithError(err)
+	}()
+
+	select {
+	case <-w.committed:
+		status, headers, cookies := w.prologue()
+		return &events.LambdaFunctionURLStreamingResponse{
+			StatusCode: status,
+			Headers:    headers,
+			Cookies:    cookies,
+			Body:       pr,
+		}, nil
+	case err := <-failed:
+		_ = pr.Close()
+		return failureResponse(err, req)
+	}
+}
+
+// failureResponse is the same error body the apigw runner answers with
+func failureResponse(err error, req events.LambdaFunctionURLRequest) (*events.LambdaFunctionURLStreamingResponse, error) {
+	status := apigw.FailureToGatewayResponse(err).StatusCode
+	failed := apigw.ErrorResponse{
+		ID:      req.RequestContext.RequestID,
+		Status:  status,
+		Message: http.StatusText(status),
+	}
+
+	if p, ok := sls.PanicDetails(err); ok {
+		failed.ID = fmt.Sprintf("%s:%s", failed.ID, p.ID)
+	}
+
+	if failure.IsRestAPI(err) {
+		if value, ok := failure.RestMessage(err); ok {
+			failed.Message = value
+		}
+
+		if fields, ok := failure.GetInvalidFields(err); ok {
+			failed.Fields = fields
+		}
+	}
+
+	return jsonResponse(status, failed)
+}
+
+func jsonResponse(status int, v interface{}) (*events.LambdaFunctionURLStreamingResponse, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, failure.ToSystem(err, "json.Marshal failed (%v)", v)
+	}
+
+	return &events.LambdaFunctionURLStreamingResponse{
+		StatusCode: status,
+		Headers:    map[string]string{HeaderContentType: JsonMediaType},
+		Body:       bytes.NewReader(body),
+	}, nil
+}
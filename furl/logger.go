@@ -0,0 +1,19 @@
+package furl
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rsb/sls"
+	"go.uber.org/zap"
+)
+
+func StreamLogger(ctx context.Context, l *zap.SugaredLogger, req events.LambdaFunctionURLRequest) *zap.SugaredLogger {
+	l = sls.InvocationLogger(ctx, l, sls.FunctionURLTrigger)
+
+	return l.With(
+		"method", req.RequestContext.HTTP.Method,
+		"path", req.RawPath,
+		"request_id", req.RequestContext.RequestID,
+	)
+}
@@ -0,0 +1,155 @@
+package furl
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rsb/failure"
+)
+
+// StreamWriter streams the body of a function url response. The status and
+// headers are sent in the prologue, before the first byte of the body, so
+// they can only be changed until WriteHeader or the first Write. Every Write
+// is sent to the client as soon as the runtime reads it.
+type StreamWriter struct {
+	mu          sync.Mutex
+	status      int
+	headers     map[string]string
+	cookies     []string
+	pipe        *io.PipeWriter
+	committed   chan struct{}
+	isCommitted bool
+	isAborted   bool
+}
+
+func newStreamWriter(pipe *io.PipeWriter) *StreamWriter {
+	return &StreamWriter{
+		status:    http.StatusOK,
+		headers:   map[string]string{},
+		pipe:      pipe,
+		committed: make(chan struct{}),
+	}
+}
+
+// SetHeader sets a header of the prologue, it is ignored once the prologue
+// was sent
+func (w *StreamWriter) SetHeader(name, value string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.isCommitted {
+		w.headers[name] = value
+	}
+}
+
+func (w *StreamWriter) SetContentType(mediaType string) {
+	w.SetHeader(HeaderContentType, mediaType)
+}
+
+func (w *StreamWriter) AddCookie(cookie string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.isCommitted {
+		w.cookies = append(w.cookies, cookie)
+	}
+}
+
+// WriteHeader sends the prologue with status, only the first call counts
+func (w *StreamWriter) WriteHeader(status int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.isCommitted || w.isAborted {
+		return
+	}
+
+	w.status = status
+	w.commit()
+}
+
+// Write sends the prologue, when it was not sent yet, then the chunk
+func (w *StreamWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	if w.isAborted {
+		w.mu.Unlock()
+		return 0, failure.InvalidState("the response was already aborted")
+	}
+	w.commit()
+	w.mu.Unlock()
+
+	n, err := w.pipe.Write(p)
+	if err != nil {
+		return n, failure.ToSystem(err, "w.pipe.Write failed")
+	}
+
+	return n, nil
+}
+
+func (w *StreamWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Event is one server sent event, ID and Name are optional
+type Event struct {
+	ID   string
+	Name string
+	Data string
+}
+
+// WriteEvent writes a server sent event, the content type should be set to
+// EventStreamMediaType before the first event
+func (w *StreamWriter) WriteEvent(e Event) error {
+	var b strings.Builder
+	if e.ID != "" {
+		b.WriteString(fmt.Sprintf("id: %s\n", e.ID))
+	}
+	if e.Name != "" {
+		b.WriteString(fmt.Sprintf("event: %s\n", e.Name))
+	}
+	for _, line := range strings.Split(e.Data, "\n") {
+		b.WriteString(fmt.Sprintf("data: %s\n", line))
+	}
+	b.WriteString("\n")
+
+	if _, err := w.WriteString(b.String()); err != nil {
+		return failure.Wrap(err, "w.WriteString failed")
+	}
+
+	return nil
+}
+
+// commit must be called with the lock held
+func (w *StreamWriter) commit() {
+	if w.isCommitted {
+		return
+	}
+
+	w.isCommitted = true
+	close(w.committed)
+}
+
+// abort reports whether the failure can still become the response, which
+// is only true while the prologue was not sent
+func (w *StreamWriter) abort() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.isCommitted {
+		return false
+	}
+
+	w.isAborted = true
+	return true
+}
+
+func (w *StreamWriter) prologue() (int, map[string]string, []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	headers := make(map[string]string, len(w.headers))
+	for k, v := range w.headers {
+		headers[k] = v
+	}
+
+	return w.status, headers, append([]string{}, w.cookies...)
+}
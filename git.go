@@ -0,0 +1,118 @@
+package sls
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/rsb/failure"
+)
+
+const (
+	GitBinaryName = "git"
+	// GitTokenEnv is the token used for https sources, ex in ci
+	GitTokenEnv = "GITHUB_TOKEN"
+)
+
+// GitHead is the sha of the commit checked out in dir. It fails when git is
+// not installed or dir is not in a git repo.
+func GitHead(ctx context.Context, dir string) (string, error) {
+	gitExec, err := exec.LookPath(GitBinaryName)
+	if err != nil {
+		return "", failure.ToSystem(err, "exec.LookPath failed")
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, gitExec, "rev-parse", "HEAD")
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", failure.ToSystem(err, "git rev-parse HEAD failed in (%s): %s", dir, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// CheckoutRef clones source, a url or the path of a local repo, into dir
+// and checks out ref, a tag, branch, or commit sha. The sha of the commit
+// checked out is returned. A ssh source is authenticated with the ssh agent
+// and a https source with GITHUB_TOKEN when it is set.
+func CheckoutRef(ctx context.Context, source, ref, dir string) (string, error) {
+	if ref == "" {
+		return "", failure.InvalidParam("ref is empty, a tag, branch or commit is required")
+	}
+
+	auth, err := gitAuth(source)
+	if err != nil {
+		return "", failure.Wrap(err, "gitAuth failed (%s)", source)
+	}
+
+	repo, err := git.PlainCloneContext(ctx, dir, false, &git.CloneOptions{
+		URL:        source,
+		Auth:       auth,
+		NoCheckout: true,
+		Tags:       git.AllTags,
+	})
+	if err != nil {
+		return "", failure.ToSystem(err, "git.PlainCloneContext failed (%s)", source)
+	}
+
+	hash, err := resolveRef(repo, ref)
+	if err != nil {
+		return "", failure.Wrap(err, "resolveRef failed")
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return "", failure.ToSystem(err, "repo.Worktree failed")
+	}
+
+	if err = wt.Checkout(&git.CheckoutOptions{Hash: hash, Force: true}); err != nil {
+		return "", failure.ToSystem(err, "wt.Checkout failed (%s)", ref)
+	}
+
+	return hash.String(), nil
+}
+
+// resolveRef finds the commit of ref, a branch of the clone is only known
+// as a remote branch of origin
+func resolveRef(repo *git.Repository, ref string) (plumbing.Hash, error) {
+	for _, rev := range []string{ref, "origin/" + ref} {
+		hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+		if err == nil {
+			return *hash, nil
+		}
+	}
+
+	return plumbing.ZeroHash, failure.NotFound("ref (%s) is not a tag, branch or commit of the repo", ref)
+}
+
+func gitAuth(source string) (transport.AuthMethod, error) {
+	endpoint, err := transport.NewEndpoint(source)
+	if err != nil {
+		return nil, failure.ToInvalidParam(err, "source (%s) is not a git url or path", source)
+	}
+
+	switch endpoint.Protocol {
+	case "ssh":
+		auth, err := ssh.NewSSHAgentAuth(endpoint.User)
+		if err != nil {
+			return nil, failure.ToConfig(err, "ssh agent is not available, start one or clone over https")
+		}
+		return auth, nil
+	case "https", "http":
+		if token := os.Getenv(GitTokenEnv); token != "" {
+			return &http.BasicAuth{Username: "x-access-token", Password: token}, nil
+		}
+	}
+
+	return nil, nil
+}
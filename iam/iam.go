@@ -0,0 +1,193 @@
+// Package iam implements the read only iam calls used to inspect the
+// policies of a lambda execution role
+package iam
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsIAM "github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+type AdapterAPI interface {
+	ListAttachedRolePolicies(ctx context.Context, params *awsIAM.ListAttachedRolePoliciesInput, optFns ...func(*awsIAM.Options)) (*awsIAM.ListAttachedRolePoliciesOutput, error)
+	ListRolePolicies(ctx context.Context, params *awsIAM.ListRolePoliciesInput, optFns ...func(*awsIAM.Options)) (*awsIAM.ListRolePoliciesOutput, error)
+	GetRolePolicy(ctx context.Context, params *awsIAM.GetRolePolicyInput, optFns ...func(*awsIAM.Options)) (*awsIAM.GetRolePolicyOutput, error)
+	GetPolicy(ctx context.Context, params *awsIAM.GetPolicyInput, optFns ...func(*awsIAM.Options)) (*awsIAM.GetPolicyOutput, error)
+	GetPolicyVersion(ctx context.Context, params *awsIAM.GetPolicyVersionInput, optFns ...func(*awsIAM.Options)) (*awsIAM.GetPolicyVersionOutput, error)
+}
+
+// PolicyReport is one policy of a role, ARN is empty for an inline policy
+type PolicyReport struct {
+	Name       string      `json:"name"`
+	ARN        string      `json:"arn,omitempty"`
+	IsInline   bool        `json:"is_inline"`
+	Statements []Statement `json:"statements"`
+}
+
+// Statements are the statements of every policy
+func Statements(policies []PolicyReport) []Statement {
+	var result []Statement
+	for _, p := range policies {
+		result = append(result, p.Statements...)
+	}
+	return result
+}
+
+// RoleName is the name of the role in its arn, without the path, ex
+// `arn:aws:iam::123456789012:role/service-role/orders` is `orders`
+func RoleName(arn string) (string, error) {
+	_, resource, ok := strings.Cut(arn, ":role/")
+	if !ok || resource == "" {
+		return "", failure.InvalidParam("(%s) is not the arn of an iam role", arn)
+	}
+
+	return resource[strings.LastIndexByte(resource, '/')+1:], nil
+}
+
+type Client struct {
+	api         AdapterAPI
+	retryPolicy *retry.Policy
+}
+
+func NewClientWithConfig(cfg aws.Config) *Client {
+	api := awsIAM.NewFromConfig(retry.NoSDKRetries(cfg))
+	return NewClient(api)
+}
+
+func NewClient(api AdapterAPI) *Client {
+	return &Client{api: api}
+}
+
+// RetryPolicy is the policy the client retries its calls with
+func (c *Client) RetryPolicy() retry.Policy {
+	if c.retryPolicy == nil {
+		return retry.Default()
+	}
+	return *c.retryPolicy
+}
+
+// SetRetryPolicy replaces retry.Default for the calls made by the client
+func (c *Client) SetRetryPolicy(p retry.Policy) {
+	c.retryPolicy = &p
+}
+
+// RolePolicies are the inline policies of the role followed by its attached
+// managed policies, read at their default version
+func (c *Client) RolePolicies(ctx context.Context, roleName string) ([]PolicyReport, error) {
+	if roleName == "" {
+		return nil, failure.InvalidParam("roleName is empty, the role name is required")
+	}
+
+	inline, err := c.inlinePolicies(ctx, roleName)
+	if err != nil {
+		return nil, failure.Wrap(err, "c.inlinePolicies failed")
+	}
+
+	attached, err := c.attachedPolicies(ctx, roleName)
+	if err != nil {
+		return nil, failure.Wrap(err, "c.attachedPolicies failed")
+	}
+
+	return append(inline, attached...), nil
+}
+
+func (c *Client) inlinePolicies(ctx context.Context, roleName string) ([]PolicyReport, error) {
+	in := awsIAM.ListRolePoliciesInput{RoleName: aws.String(roleName)}
+	var result []PolicyReport
+	for {
+		out, err := retry.Call(ctx, c.RetryPolicy(), c.api.ListRolePolicies, &in)
+		if err != nil {
+			return nil, toError(err, "c.api.ListRolePolicies failed (%s)", roleName)
+		}
+
+		for _, name := range out.PolicyNames {
+			pin := awsIAM.GetRolePolicyInput{RoleName: aws.String(roleName), PolicyName: aws.String(name)}
+			policy, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetRolePolicy, &pin)
+			if err != nil {
+				return nil, toError(err, "c.api.GetRolePolicy failed (%s)", name)
+			}
+
+			doc, err := ParsePolicyDocument(aws.ToString(policy.PolicyDocument))
+			if err != nil {
+				return nil, failure.Wrap(err, "ParsePolicyDocument failed (%s)", name)
+			}
+			result = append(result, PolicyReport{Name: name, IsInline: true, Statements: doc.Statement})
+		}
+
+		if !out.IsTruncated {
+			break
+		}
+		in.Marker = out.Marker
+	}
+
+	return result, nil
+}
+
+func (c *Client) attachedPolicies(ctx context.Context, roleName string) ([]PolicyReport, error) {
+	in := awsIAM.ListAttachedRolePoliciesInput{RoleName: aws.String(roleName)}
+	var result []PolicyReport
+	for {
+		out, err := retry.Call(ctx, c.RetryPolicy(), c.api.ListAttachedRolePolicies, &in)
+		if err != nil {
+			return nil, toError(err, "c.api.ListAttachedRolePolicies failed (%s)", roleName)
+		}
+
+		for _, p := range out.AttachedPolicies {
+			policyARN := aws.ToString(p.PolicyArn)
+			doc, err := c.policyDocument(ctx, policyARN)
+			if err != nil {
+				return nil, failure.Wrap(err, "c.policyDocument failed (%s)", policyARN)
+			}
+			result = append(result, PolicyReport{Name: aws.ToString(p.PolicyName), ARN: policyARN, Statements: doc.Statement})
+		}
+
+		if !out.IsTruncated {
+			break
+		}
+		in.Marker = out.Marker
+	}
+
+	return result, nil
+}
+
+func (c *Client) policyDocument(ctx context.Context, policyARN string) (PolicyDocument, error) {
+	policy, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetPolicy, &awsIAM.GetPolicyInput{PolicyArn: aws.String(policyARN)})
+	if err != nil {
+		return PolicyDocument{}, toError(err, "c.api.GetPolicy failed")
+	}
+	if policy.Policy == nil {
+		return PolicyDocument{}, failure.System("c.api.GetPolicy returned no policy for (%s)", policyARN)
+	}
+
+	versionID := aws.ToString(policy.Policy.DefaultVersionId)
+	in := awsIAM.GetPolicyVersionInput{PolicyArn: aws.String(policyARN), VersionId: aws.String(versionID)}
+	version, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetPolicyVersion, &in)
+	if err != nil {
+		return PolicyDocument{}, toError(err, "c.api.GetPolicyVersion failed (%s)", versionID)
+	}
+	if version.PolicyVersion == nil {
+		return PolicyDocument{}, failure.System("c.api.GetPolicyVersion returned no version for (%s, %s)", policyARN, versionID)
+	}
+
+	doc, err := ParsePolicyDocument(aws.ToString(version.PolicyVersion.Document))
+	if err != nil {
+		return doc, failure.Wrap(err, "ParsePolicyDocument failed")
+	}
+
+	return doc, nil
+}
+
+func toError(err error, msg string, a ...interface{}) error {
+	var notFound *types.NoSuchEntityException
+	if errors.As(err, &notFound) {
+		return failure.ToNotFound(err, msg, a...)
+	}
+	return failure.ToSystem(err, msg, a...)
+}
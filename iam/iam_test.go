@@ -0,0 +1,112 @@
+package iam_test
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsIAM "github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/iam"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockAPI serves one role, attached policies are returned one per page
+type MockAPI struct {
+	Inline   map[string]string
+	Attached []types.AttachedPolicy
+	Managed  map[string]string
+	Err      error
+	Markers  []string
+}
+
+func (m *MockAPI) ListAttachedRolePolicies(_ context.Context, params *awsIAM.ListAttachedRolePoliciesInput, _ ...func(*awsIAM.Options)) (*awsIAM.ListAttachedRolePoliciesOutput, error) {
+	m.Markers = append(m.Markers, aws.ToString(params.Marker))
+	idx, _ := strconv.Atoi(aws.ToString(params.Marker))
+	if idx >= len(m.Attached) {
+		return &awsIAM.ListAttachedRolePoliciesOutput{}, nil
+	}
+
+	out := awsIAM.ListAttachedRolePoliciesOutput{AttachedPolicies: m.Attached[idx : idx+1]}
+	if idx+1 < len(m.Attached) {
+		out.IsTruncated = true
+		out.Marker = aws.String(strconv.Itoa(idx + 1))
+	}
+	return &out, nil
+}
+
+func (m *MockAPI) ListRolePolicies(_ context.Context, _ *awsIAM.ListRolePoliciesInput, _ ...func(*awsIAM.Options)) (*awsIAM.ListRolePoliciesOutput, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+
+	var out awsIAM.ListRolePoliciesOutput
+	for name := range m.Inline {
+		out.PolicyNames = append(out.PolicyNames, name)
+	}
+	return &out, nil
+}
+
+func (m *MockAPI) GetRolePolicy(_ context.Context, params *awsIAM.GetRolePolicyInput, _ ...func(*awsIAM.Options)) (*awsIAM.GetRolePolicyOutput, error) {
+	doc := url.QueryEscape(m.Inline[aws.ToString(params.PolicyName)])
+	return &awsIAM.GetRolePolicyOutput{PolicyName: params.PolicyName, PolicyDocument: aws.String(doc)}, nil
+}
+
+func (m *MockAPI) GetPolicy(_ context.Context, params *awsIAM.GetPolicyInput, _ ...func(*awsIAM.Options)) (*awsIAM.GetPolicyOutput, error) {
+	return &awsIAM.GetPolicyOutput{Policy: &types.Policy{Arn: params.PolicyArn, DefaultVersionId: aws.String("v2")}}, nil
+}
+
+func (m *MockAPI) GetPolicyVersion(_ context.Context, params *awsIAM.GetPolicyVersionInput, _ ...func(*awsIAM.Options)) (*awsIAM.GetPolicyVersionOutput, error) {
+	doc := url.QueryEscape(m.Managed[aws.ToString(params.PolicyArn)+":"+aws.ToString(params.VersionId)])
+	return &awsIAM.GetPolicyVersionOutput{PolicyVersion: &types.PolicyVersion{Document: aws.String(doc)}}, nil
+}
+
+func TestClient_RolePolicies(t *testing.T) {
+	api := MockAPI{
+		Inline: map[string]string{
+			"params": `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Action":"ssm:GetParameter","Resource":"*"}}`,
+		},
+		Attached: []types.AttachedPolicy{
+			{PolicyName: aws.String("logs"), PolicyArn: aws.String("arn:aws:iam::123456789012:policy/logs")},
+			{PolicyName: aws.String("tables"), PolicyArn: aws.String("arn:aws:iam::123456789012:policy/tables")},
+		},
+		Managed: map[string]string{
+			"arn:aws:iam::123456789012:policy/logs:v2":   `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["logs:PutLogEvents"],"Resource":"*"}]}`,
+			"arn:aws:iam::123456789012:policy/tables:v2": `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["dynamodb:GetItem"],"Resource":"*"}]}`,
+		},
+	}
+
+	policies, err := iam.NewClient(&api).RolePolicies(context.TODO(), "orders")
+	require.NoError(t, err, "RolePolicies is not expected to fail")
+	require.Len(t, policies, 3)
+
+	assert.Equal(t, "params", policies[0].Name)
+	assert.True(t, policies[0].IsInline)
+	assert.Empty(t, policies[0].ARN)
+
+	assert.Equal(t, "logs", policies[1].Name)
+	assert.Equal(t, "arn:aws:iam::123456789012:policy/logs", policies[1].ARN)
+	assert.Equal(t, "tables", policies[2].Name)
+	assert.Equal(t, []string{"", "1"}, api.Markers, "the marker of a truncated page is sent with the next list")
+
+	statements := iam.Statements(policies)
+	require.Len(t, statements, 3)
+	assert.True(t, statements[0].Matches("ssm:GetParameter", "arn:aws:ssm:us-east-1:123456789012:parameter/orders"))
+	assert.True(t, statements[2].Matches("dynamodb:GetItem", "arn:aws:dynamodb:us-east-1:123456789012:table/orders"))
+}
+
+func TestClient_RolePolicies_NotFound(t *testing.T) {
+	api := MockAPI{Err: &types.NoSuchEntityException{Message: aws.String("role orders not found")}}
+
+	_, err := iam.NewClient(&api).RolePolicies(context.TODO(), "orders")
+	require.Error(t, err)
+	assert.True(t, failure.IsNotFound(err), "expected a not found error, got %v", err)
+
+	_, err = iam.NewClient(&api).RolePolicies(context.TODO(), "")
+	require.Error(t, err)
+	assert.True(t, failure.IsInvalidParam(err))
+}
@@ -0,0 +1,181 @@
+package iam
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/rsb/failure"
+)
+
+const (
+	AllowEffect = "Allow"
+	DenyEffect  = "Deny"
+)
+
+// StringList is a policy field that is either one string or a list of them
+type StringList []string
+
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*l = StringList{one}
+		return nil
+	}
+
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return failure.ToInvalidParam(err, "policy field (%s) is not a string or a list of strings", string(data))
+	}
+	*l = many
+
+	return nil
+}
+
+// Statement is one statement of a policy document. Condition is kept as
+// it is written, it is not evaluated.
+type Statement struct {
+	Sid         string          `json:"Sid,omitempty"`
+	Effect      string          `json:"Effect"`
+	Action      StringList      `json:"Action,omitempty"`
+	NotAction   StringList      `json:"NotAction,omitempty"`
+	Resource    StringList      `json:"Resource,omitempty"`
+	NotResource StringList      `json:"NotResource,omitempty"`
+	Condition   json.RawMessage `json:"Condition,omitempty"`
+}
+
+// PolicyDocument is an identity policy, Statement is a list even when the
+// document has a single statement object
+type PolicyDocument struct {
+	Version   string      `json:"Version"`
+	Statement []Statement `json:"Statement"`
+}
+
+func (d *PolicyDocument) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Version   string          `json:"Version"`
+		Statement json.RawMessage `json:"Statement"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return failure.ToInvalidParam(err, "policy document is not json")
+	}
+	d.Version = raw.Version
+
+	if len(raw.Statement) > 0 && raw.Statement[0] == '{' {
+		var s Statement
+		if err := json.Unmarshal(raw.Statement, &s); err != nil {
+			return failure.Wrap(err, "policy statement is not valid")
+		}
+		d.Statement = []Statement{s}
+		return nil
+	}
+
+	if err := json.Unmarshal(raw.Statement, &d.Statement); err != nil {
+		return failure.Wrap(err, "policy statements are not valid")
+	}
+
+	return nil
+}
+
+// ParsePolicyDocument reads a policy document as iam returns it, url
+// encoded, or as plain json
+func ParsePolicyDocument(doc string) (PolicyDocument, error) {
+	var d PolicyDocument
+	if !strings.HasPrefix(strings.TrimSpace(doc), "{") {
+		decoded, err := url.QueryUnescape(doc)
+		if err != nil {
+			return d, failure.ToInvalidParam(err, "url.QueryUnescape failed for the policy document")
+		}
+		doc = decoded
+	}
+
+	if err := json.Unmarshal([]byte(doc), &d); err != nil {
+		return d, failure.Wrap(err, "json.Unmarshal failed for the policy document")
+	}
+
+	return d, nil
+}
+
+// Matches is whether the statement applies to action on resource, without
+// looking at its effect or condition. Actions match without case.
+func (s Statement) Matches(action, resource string) bool {
+	switch {
+	case len(s.Action) > 0 && !matchAny(s.Action, action, true):
+		return false
+	case len(s.NotAction) > 0 && matchAny(s.NotAction, action, true):
+		return false
+	case len(s.Resource) > 0 && !matchAny(s.Resource, resource, false):
+		return false
+	case len(s.NotResource) > 0 && matchAny(s.NotResource, resource, false):
+		return false
+	}
+
+	return len(s.Action) > 0 || len(s.NotAction) > 0
+}
+
+// IsAllowed is whether the statements allow action on resource: one Allow
+// statement matches and no Deny statement does. Conditions are not
+// evaluated, a statement with a condition is taken to apply.
+func IsAllowed(statements []Statement, action, resource string) bool {
+	isAllowed := false
+	for _, s := range statements {
+		if !s.Matches(action, resource) {
+			continue
+		}
+
+		if s.Effect == DenyEffect {
+			return false
+		}
+		if s.Effect == AllowEffect {
+			isAllowed = true
+		}
+	}
+
+	return isAllowed
+}
+
+func matchAny(patterns []string, value string, isFold bool) bool {
+	for _, p := range patterns {
+		if isFold {
+			if Match(strings.ToLower(p), strings.ToLower(value)) {
+				return true
+			}
+			continue
+		}
+
+		if Match(p, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Match is the wildcard match of iam, * is any run of characters, including
+// none and /, and ? is any one character
+func Match(pattern, value string) bool {
+	p, v := 0, 0
+	star, next := -1, 0
+	for v < len(value) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == value[v]):
+			p++
+			v++
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, v
+			p++
+		case star >= 0:
+			p = star + 1
+			next++
+			v = next
+		default:
+			return false
+		}
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+
+	return p == len(pattern)
+}
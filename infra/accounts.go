@@ -0,0 +1,285 @@
+package infra
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/cwlogs"
+	"github.com/rsb/sls/cwmetrics"
+	"github.com/rsb/sls/iam"
+	"github.com/rsb/sls/kms"
+	"github.com/rsb/sls/lambda"
+	"github.com/rsb/sls/pstore"
+	"github.com/rsb/sls/restapi"
+	"github.com/rsb/sls/retry"
+	"github.com/rsb/sls/s3"
+	"github.com/rsb/sls/scaling"
+	"github.com/rsb/sls/scheduler"
+	"github.com/rsb/sls/secrets"
+	"github.com/rsb/sls/sqs"
+	"github.com/rsb/sls/sts"
+	"github.com/rsb/sls/telemetry"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// MultiAccountAnnotation marks the commands that can run against more
+	// than one --target-account, they loop with Infra.ForEachAccount
+	MultiAccountAnnotation = "sls-multi-account"
+)
+
+// AccountTarget is implemented by configs that can point a command at
+// another aws account
+type AccountTarget interface {
+	TargetAccountIDs() []string
+	TargetRoleName() string
+}
+
+func (c CmdConfig) TargetAccountIDs() []string {
+	var accounts []string
+	for _, account := range strings.Split(c.TargetAccount, ",") {
+		if account = strings.TrimSpace(account); account != "" {
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts
+}
+
+func (c CmdConfig) TargetRoleName() string {
+	return c.TargetRole
+}
+
+// AccountClients are the clients of the infra commands for one account
+type AccountClients struct {
+	PStoreAPI    ParamStorage
+	LambdaAPI    LambdaDeployments
+	LogsAPI      LogGroupManagement
+	KMSAPI       EnvEncryption
+	ScalingAPI   ConcurrencyScheduling
+	SchedulerAPI ScheduleManagement
+	IAMAPI       RolePolicyReading
+	QueueAPI     QueueRedriving
+	MetricsAPI   MetricReading
+	GatewayAPI   StageDeployment
+	// ArtifactsAPI uploads the code of deploys made with --via-s3
+	ArtifactsAPI ArtifactUploading
+	SecretsAPI   SecretManagement
+}
+
+// NewAccountClients is the default AccountConstructor, it builds the
+// sls clients from cfg
+func NewAccountClients(cfg aws.Config) (AccountClients, error) {
+	store, err := pstore.NewClientWithConfig(cfg, true)
+	if err != nil {
+		return AccountClients{}, failure.Wrap(err, "pstore.NewClientWithConfig failed")
+	}
+
+	return AccountClients{
+		PStoreAPI:    store,
+		LambdaAPI:    lambda.NewClientWithConfig(cfg),
+		LogsAPI:      cwlogs.NewClientWithConfig(cfg),
+		KMSAPI:       kms.NewClientWithConfig(cfg),
+		ScalingAPI:   scaling.NewClientWithConfig(cfg),
+		SchedulerAPI: scheduler.NewClientWithConfig(cfg),
+		IAMAPI:       iam.NewClientWithConfig(cfg),
+		QueueAPI:     sqs.NewClientWithConfig(cfg),
+		MetricsAPI:   cwmetrics.NewClientWithConfig(cfg),
+		GatewayAPI:   restapi.NewClientWithConfig(cfg),
+		ArtifactsAPI: s3.NewClientWithConfig(cfg),
+		SecretsAPI:   secrets.NewClientWithConfig(cfg),
+	}, nil
+}
+
+func (i *Infra) clients() AccountClients {
+	return AccountClients{
+		PStoreAPI:    i.PStoreAPI,
+		LambdaAPI:    i.LambdaAPI,
+		LogsAPI:      i.LogsAPI,
+		KMSAPI:       i.KMSAPI,
+		ScalingAPI:   i.ScalingAPI,
+		SchedulerAPI: i.SchedulerAPI,
+		IAMAPI:       i.IAMAPI,
+		QueueAPI:     i.QueueAPI,
+		MetricsAPI:   i.MetricsAPI,
+		GatewayAPI:   i.GatewayAPI,
+		ArtifactsAPI: i.ArtifactsAPI,
+		SecretsAPI:   i.SecretsAPI,
+	}
+}
+
+// retryPolicySetting is implemented by the sls clients
+type retryPolicySetting interface {
+	SetRetryPolicy(p retry.Policy)
+}
+
+// setRetryPolicy gives the clients that retry with a retry.Policy the
+// policy of the infra, so each infra only counts its own retries
+func (i *Infra) setRetryPolicy(c AccountClients) {
+	apis := []interface{}{
+		c.PStoreAPI,
+		c.LambdaAPI,
+		c.LogsAPI,
+		c.KMSAPI,
+		c.ScalingAPI,
+		c.SchedulerAPI,
+		c.IAMAPI,
+		c.QueueAPI,
+		c.MetricsAPI,
+		c.GatewayAPI,
+		c.ArtifactsAPI,
+		c.SecretsAPI,
+	}
+
+	policy := i.RetryPolicy()
+	for _, api := range apis {
+		if s, ok := api.(retryPolicySetting); ok {
+			s.SetRetryPolicy(policy)
+		}
+	}
+}
+
+func (i *Infra) setClients(c AccountClients) {
+	i.PStoreAPI = c.PStoreAPI
+	i.LambdaAPI = c.LambdaAPI
+	i.LogsAPI = c.LogsAPI
+	i.KMSAPI = c.KMSAPI
+	i.ScalingAPI = c.ScalingAPI
+	i.SchedulerAPI = c.SchedulerAPI
+	i.IAMAPI = c.IAMAPI
+	i.QueueAPI = c.QueueAPI
+	i.MetricsAPI = c.MetricsAPI
+	i.GatewayAPI = c.GatewayAPI
+	i.ArtifactsAPI = c.ArtifactsAPI
+	i.SecretsAPI = c.SecretsAPI
+}
+
+// AccountRoleARN resolves the role assumed for account. i.AccountRoles maps
+// an account id or alias to a role name or arn, anything else assumes the
+// --target-role in the account.
+func (i *Infra) AccountRoleARN(account, role string) (string, error) {
+	if mapped, ok := i.AccountRoles[account]; ok {
+		if _, err := sts.AccountFromARN(mapped); err == nil {
+			return mapped, nil
+		}
+		role = mapped
+	}
+
+	if !isAccountID(account) {
+		return "", failure.InvalidParam("account (%s) is not an account id and has no role in i.AccountRoles", account)
+	}
+
+	partition := sls.AWSPartition
+	if i.AWSConfig != nil && i.AWSConfig.Region != "" {
+		partition = sls.RegionPartition(i.AWSConfig.Region)
+	}
+
+	return sts.PartitionRoleARN(partition, account, role), nil
+}
+
+// UseAccount swaps the clients of the infra for clients acting in account
+// through the assumed role. The clients are built once per account.
+func (i *Infra) UseAccount(account, role string) error {
+	if i.AWSConfig == nil {
+		return failure.System("i.AWSConfig is not initialized, it is required by --target-account")
+	}
+
+	if c, ok := i.accounts[account]; ok {
+		i.setClients(c)
+		return nil
+	}
+
+	roleARN, err := i.AccountRoleARN(account, role)
+	if err != nil {
+		return failure.Wrap(err, "i.AccountRoleARN failed")
+	}
+
+	cfg, err := sts.NewClientWithConfig(*i.AWSConfig).AssumeRoleConfig(*i.AWSConfig, sts.AssumeRoleSettings{RoleARN: roleARN})
+	if err != nil {
+		return failure.Wrap(err, "AssumeRoleConfig failed (%s)", roleARN)
+	}
+	cfg = telemetry.WithRecorder(cfg, i.Telemetry)
+
+	construct := i.AccountConstructor
+	if construct == nil {
+		construct = NewAccountClients
+	}
+
+	c, err := construct(cfg)
+	if err != nil {
+		return failure.Wrap(err, "i.AccountConstructor failed (%s)", account)
+	}
+
+	i.setRetryPolicy(c)
+	if i.accounts == nil {
+		i.accounts = map[string]AccountClients{}
+	}
+	i.accounts[account] = c
+	i.setClients(c)
+
+	return nil
+}
+
+// ForEachAccount runs fn once per --target-account with the clients of that
+// account, or once with the current clients when there is none. The
+// original clients are restored when it returns.
+func (i *Infra) ForEachAccount(c CmdConfig, fn func(account string) error) error {
+	accounts := c.TargetAccountIDs()
+	if len(accounts) == 0 {
+		return fn("")
+	}
+
+	home := i.clients()
+	defer i.setClients(home)
+
+	for _, account := range accounts {
+		if err := i.UseAccount(account, c.TargetRoleName()); err != nil {
+			return failure.Wrap(err, "i.UseAccount failed (%s)", account)
+		}
+
+		if err := fn(account); err != nil {
+			return failure.Wrap(err, "account (%s) failed", account)
+		}
+	}
+
+	return nil
+}
+
+// targetAccount points single account commands at their --target-account,
+// commands annotated with MultiAccountAnnotation loop on their own
+func (i *Infra) targetAccount(cmd *cobra.Command, c interface{}) error {
+	t, ok := c.(AccountTarget)
+	if !ok {
+		return nil
+	}
+
+	accounts := t.TargetAccountIDs()
+	if _, multi := cmd.Annotations[MultiAccountAnnotation]; multi || len(accounts) == 0 {
+		return nil
+	}
+
+	if len(accounts) > 1 {
+		return failure.InvalidParam("(%s) only supports one --target-account", cmd.Name())
+	}
+
+	if err := i.UseAccount(accounts[0], t.TargetRoleName()); err != nil {
+		return failure.Wrap(err, "i.UseAccount failed (%s)", accounts[0])
+	}
+
+	return nil
+}
+
+func isAccountID(account string) bool {
+	if len(account) != 12 {
+		return false
+	}
+
+	for _, r := range account {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,365 @@
+package infra
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/lambda"
+	"github.com/spf13/cobra"
+)
+
+func SetupAliasCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.AliasCmd == nil {
+		in.AliasCmd = AliasCmd
+	}
+	in.ParentCmd.AddCommand(in.AliasCmd)
+
+	if in.AliasCreateCmd == nil {
+		in.AliasCreateCmd = AliasCreateCmd
+	}
+	in.AliasCreateCmd.RunE = in.RunAliasCreate
+	in.AliasCmd.AddCommand(in.AliasCreateCmd)
+
+	if in.AliasUpdateCmd == nil {
+		in.AliasUpdateCmd = AliasUpdateCmd
+	}
+	in.AliasUpdateCmd.RunE = in.RunAliasUpdate
+	in.AliasCmd.AddCommand(in.AliasUpdateCmd)
+
+	if in.AliasListCmd == nil {
+		in.AliasListCmd = AliasListCmd
+	}
+	in.AliasListCmd.RunE = in.RunAliasList
+	in.AliasCmd.AddCommand(in.AliasListCmd)
+
+	var cb AliasCreateBind
+	if err := Bind(in.AliasCreateCmd, in.Viper, &cb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.AliasCreateCmd")
+	}
+
+	var ub AliasUpdateBind
+	if err := Bind(in.AliasUpdateCmd, in.Viper, &ub); err != nil {
+		return failure.Wrap(err, "Bind failed for in.AliasUpdateCmd")
+	}
+
+	return nil
+}
+
+var AliasCmd = &cobra.Command{
+	Use:   "alias",
+	Short: "manage the aliases (live, canary, previous) of a lambda",
+}
+
+var AliasCreateCmd = &cobra.Command{
+	Use:   "create <FEATURE>",
+	Short: "create an alias pointing at a version of the lambda",
+	Args:  cobra.ExactArgs(1),
+}
+
+var AliasUpdateCmd = &cobra.Command{
+	Use:   "update <FEATURE>",
+	Short: "pin an alias to a version, optionally routing part of its traffic to another one",
+	Args:  cobra.ExactArgs(1),
+}
+
+var AliasListCmd = &cobra.Command{
+	Use:   "list <FEATURE>",
+	Short: "list the aliases of the lambda and the versions they point at",
+	Args:  cobra.ExactArgs(1),
+}
+
+type AliasCreateBind struct {
+	Name         string `conf:"default:live, cli:name, cli-u: Name of the alias (ex live or canary or previous)"`
+	Version      string `conf:"cli:version, cli-u: Version the alias points at"`
+	Description  string `conf:"cli:description, cli-u: Description of the alias"`
+	RouteVersion string `conf:"cli:route-version, cli-u: Additional version that gets --route-percent of the traffic"`
+	RoutePercent int    `conf:"cli:route-percent, cli-u: Percent of the traffic sent to --route-version from 1 to 99"`
+}
+
+type AliasCreateConfig struct {
+	CmdConfig
+	AliasCreateBind
+}
+
+type AliasUpdateBind struct {
+	Name           string `conf:"default:live, cli:name, cli-u: Name of the alias (ex live or canary or previous)"`
+	Version        string `conf:"cli:version, cli-u: Version the alias points at"`
+	Description    string `conf:"cli:description, cli-u: Description of the alias"`
+	RouteVersion   string `conf:"cli:route-version, cli-u: Additional version that gets --route-percent of the traffic"`
+	RoutePercent   int    `conf:"cli:route-percent, cli-u: Percent of the traffic sent to --route-version from 1 to 99"`
+	IsClearRouting bool   `conf:"cli:clear-routing, cli-u: Send every invocation to --version"`
+	IsKeepPrevious bool   `conf:"cli:keep-previous, cli-u: Point the previous alias at the version the alias leaves"`
+}
+
+type AliasUpdateConfig struct {
+	CmdConfig
+	AliasUpdateBind
+}
+
+// AliasChangeReport is the outcome of `alias create` and `alias update`,
+// Previous is only set when --keep-previous moved the previous alias
+type AliasChangeReport struct {
+	Feature  string              `json:"feature"`
+	Alias    *lambda.AliasReport `json:"alias"`
+	Previous *lambda.AliasReport `json:"previous,omitempty"`
+}
+
+func (r AliasChangeReport) TableRows() ([]string, [][]string) {
+	reports := AliasListReport{Feature: r.Feature, Aliases: []lambda.AliasReport{*r.Alias}}
+	if r.Previous != nil {
+		reports.Aliases = append(reports.Aliases, *r.Previous)
+	}
+
+	return reports.TableRows()
+}
+
+type AliasListReport struct {
+	Feature string               `json:"feature"`
+	Aliases []lambda.AliasReport `json:"aliases"`
+}
+
+func (r AliasListReport) TableRows() ([]string, [][]string) {
+	header := []string{"FEATURE", "ALIAS", "VERSION", "ROUTING"}
+	rows := make([][]string, 0, len(r.Aliases))
+	for _, a := range r.Aliases {
+		rows = append(rows, []string{r.Feature, a.Name, a.Version, formatWeights(a.Weights)})
+	}
+
+	return header, rows
+}
+
+// formatWeights renders routing weights as version=percent
+func formatWeights(weights map[string]float64) string {
+	versions := make([]string, 0, len(weights))
+	for v := range weights {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+
+	parts := make([]string, 0, len(versions))
+	for _, v := range versions {
+		parts = append(parts, fmt.Sprintf("%s=%g%%", v, weights[v]*100))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// routeWeights turns --route-version and --route-percent into the routing
+// weights of an alias, nil when no route is given
+func routeWeights(version string, percent int) (map[string]float64, error) {
+	if version == "" && percent == 0 {
+		return nil, nil
+	}
+
+	if version == "" {
+		return nil, failure.InvalidParam("--route-version is required by --route-percent")
+	}
+
+	if percent < 1 || percent > 99 {
+		return nil, failure.InvalidParam("--route-percent (%d) must be a percent from 1 to 99", percent)
+	}
+
+	return map[string]float64{version: float64(percent) / 100}, nil
+}
+
+// RunAliasCreate runs `<service> infra alias create <FEATURE>` which creates
+// an alias on a published version of the lambda.
+// `<service> infra alias create <FEATURE> --version 12 [--name canary] [--route-version 13 --route-percent 10]`
+func (i *Infra) RunAliasCreate(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	api, ok := i.LambdaAPI.(LambdaAliasing)
+	if !ok {
+		return failure.System("i.LambdaAPI is not initialized or does not implement LambdaAliasing")
+	}
+
+	var config AliasCreateConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if config.Version == "" {
+		return failure.InvalidParam("--version is required")
+	}
+
+	weights, err := routeWeights(config.RouteVersion, config.RoutePercent)
+	if err != nil {
+		return failure.Wrap(err, "routeWeights failed")
+	}
+
+	if err = i.Writable("alias create"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	settings := lambda.AliasSettings{
+		QualifiedName: feature.QualifiedName,
+		Name:          config.Name,
+		Version:       config.Version,
+		Description:   config.Description,
+		Weights:       weights,
+	}
+	if i.DryRun("CreateAlias", feature.QualifiedName+":"+config.Name, fmt.Sprintf("to version (%s)", config.Version)) {
+		return nil
+	}
+
+	defer i.Step("alias create")()
+	report, err := api.CreateAlias(ctx, settings)
+	if err != nil {
+		return failure.Wrap(err, "api.CreateAlias failed (%s)", feature.QualifiedName)
+	}
+
+	return i.DisplayFormatted(AliasChangeReport{Feature: feature.Name, Alias: report})
+}
+
+// RunAliasUpdate runs `<service> infra alias update <FEATURE>` which moves
+// an alias to another version. Without a route the routing config of the
+// alias is kept, --clear-routing sends all of its traffic to --version.
+// `<service> infra alias update <FEATURE> --version 12 [--name live] [--keep-previous] [--clear-routing]`
+func (i *Infra) RunAliasUpdate(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	api, ok := i.LambdaAPI.(LambdaAliasing)
+	if !ok {
+		return failure.System("i.LambdaAPI is not initialized or does not implement LambdaAliasing")
+	}
+
+	var config AliasUpdateConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if config.Version == "" {
+		return failure.InvalidParam("--version is required")
+	}
+
+	if config.IsKeepPrevious && config.Name == lambda.PreviousAlias {
+		return failure.InvalidParam("--keep-previous can not be used when updating the (%s) alias itself", lambda.PreviousAlias)
+	}
+
+	weights, err := routeWeights(config.RouteVersion, config.RoutePercent)
+	if err != nil {
+		return failure.Wrap(err, "routeWeights failed")
+	}
+
+	if config.IsClearRouting {
+		if weights != nil {
+			return failure.InvalidParam("--clear-routing can not be combined with --route-version")
+		}
+		weights = map[string]float64{}
+	}
+
+	if err = i.Writable("alias update"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	defer i.Step("alias update")()
+	result := AliasChangeReport{Feature: feature.Name}
+	// the alias is only moved when it still points where previous was set
+	// from, otherwise previous would not be what it pointed at before
+	var revision string
+	if config.IsKeepPrevious {
+		current, err := api.Alias(ctx, feature.QualifiedName, config.Name)
+		if err != nil {
+			return failure.Wrap(err, "api.Alias failed (%s)", feature.QualifiedName)
+		}
+		revision = current.RevisionID
+
+		if current.Version != config.Version {
+			previous := lambda.AliasSettings{
+				QualifiedName: feature.QualifiedName,
+				Name:          lambda.PreviousAlias,
+				Version:       current.Version,
+				Weights:       map[string]float64{},
+			}
+			if !i.DryRun("UpdateAlias", feature.QualifiedName+":"+lambda.PreviousAlias, fmt.Sprintf("to version (%s)", current.Version)) {
+				result.Previous, err = api.UpdateAlias(ctx, previous)
+				if failure.IsNotFound(err) {
+					previous.Weights = nil
+					result.Previous, err = api.CreateAlias(ctx, previous)
+				}
+				if err != nil {
+					return failure.Wrap(err, "moving alias (%s) failed", lambda.PreviousAlias)
+				}
+			}
+		}
+	}
+
+	if i.DryRun("UpdateAlias", feature.QualifiedName+":"+config.Name, fmt.Sprintf("to version (%s)", config.Version)) {
+		return nil
+	}
+
+	result.Alias, err = api.UpdateAlias(ctx, lambda.AliasSettings{
+		QualifiedName: feature.QualifiedName,
+		Name:          config.Name,
+		Version:       config.Version,
+		Description:   config.Description,
+		Weights:       weights,
+		RevisionID:    revision,
+	})
+	if err != nil {
+		return failure.Wrap(err, "api.UpdateAlias failed (%s)", feature.QualifiedName)
+	}
+
+	return i.DisplayFormatted(result)
+}
+
+// RunAliasList runs `<service> infra alias list <FEATURE>` which shows
+// every alias of the lambda with its version and routing
+// `<service> infra alias list <FEATURE>`
+func (i *Infra) RunAliasList(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	api, ok := i.LambdaAPI.(LambdaAliasing)
+	if !ok {
+		return failure.System("i.LambdaAPI is not initialized or does not implement LambdaAliasing")
+	}
+
+	var config CmdConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	_, feature, err := i.LoadFeature(config, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	defer i.Step("alias list")()
+	aliases, err := api.ListAliases(ctx, feature.QualifiedName)
+	if err != nil {
+		return failure.Wrap(err, "api.ListAliases failed (%s)", feature.QualifiedName)
+	}
+
+	return i.DisplayFormatted(AliasListReport{Feature: feature.Name, Aliases: aliases})
+}
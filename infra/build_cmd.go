@@ -0,0 +1,126 @@
+package infra
+
+import (
+	"fmt"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/lambda"
+	"github.com/spf13/cobra"
+)
+
+func SetupBuildCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.BuildCmd == nil {
+		in.BuildCmd = BuildCmd
+	}
+	in.BuildCmd.RunE = in.RunBuild
+	in.ParentCmd.AddCommand(in.BuildCmd)
+
+	var bb BuildBind
+	if err := Bind(in.BuildCmd, in.Viper, &bb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.BuildCmd")
+	}
+
+	return nil
+}
+
+var BuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "compile a feature without deploying it",
+	Args:  cobra.ExactArgs(1),
+}
+
+type BuildBind struct {
+	IsAnalyze        bool   `conf:"cli:analyze, cli-u: Report which packages and modules contribute to the binary size"`
+	Top              int    `conf:"default:20, cli:top, cli-u: Number of packages shown by --analyze (0 shows all)"`
+	SkipZipping      bool   `conf:"cli:skip-zip, cli-u: Only compile the binary"`
+	PackageFormat    string `conf:"cli:package-format, cli-u: Package format zip or container (default the feature's)"`
+	CompressionLevel int    `conf:"cli:compression-level, cli-u: Deflate level from 1 (fastest) to 9 (smallest)"`
+	Arch             string `conf:"cli:arch, cli-u: GOARCH amd64 or arm64 the feature is built for (default the GOARCH of the env)"`
+}
+
+type BuildConfig struct {
+	CmdConfig
+	BuildBind
+}
+
+type BuildReport struct {
+	Feature  string            `json:"feature"`
+	BinPath  string            `json:"bin_path"`
+	ZipName  string            `json:"zip_name,omitempty"`
+	ZipSize  int               `json:"zip_size,omitempty"`
+	Analysis *sls.BinaryReport `json:"analysis,omitempty"`
+	Package  *sls.Package      `json:"package,omitempty"`
+}
+
+// RunBuild runs `<service> infra build <feature> [--analyze]`, with
+// --analyze the report lists the packages bloating the lambda binary
+func (i *Infra) RunBuild(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	if i.LambdaAPI == nil {
+		return failure.System("i.LambdaAPI is not initialized")
+	}
+
+	var config BuildConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	service, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	settings := service.NewBuildSettings(feature)
+	settings.IsAnalyze = config.IsAnalyze
+	settings.SkipZipping = config.SkipZipping
+	if config.PackageFormat != "" {
+		settings.Packaging.Format = sls.PackageFormat(config.PackageFormat)
+	}
+	if config.CompressionLevel != 0 {
+		settings.Packaging.CompressionLevel = config.CompressionLevel
+	}
+	if config.Arch != "" {
+		if lambda.GoArchArchitecture(config.Arch) == "" {
+			return failure.InvalidParam("--arch (%s) must be amd64 or arm64", config.Arch)
+		}
+		settings.Architecture = config.Arch
+	}
+
+	ctx, cancel := i.Context(cmd)
+	defer cancel()
+
+	stop := i.Step("build")
+	result, err := i.compile(ctx, settings)
+	stop()
+	if err != nil {
+		return failure.Wrap(err, "i.compile failed")
+	}
+
+	report := BuildReport{
+		Feature:  feature.Name,
+		BinPath:  settings.BinPath,
+		Analysis: result.Analysis,
+		Package:  result.Package,
+	}
+	if !config.SkipZipping {
+		report.ZipName = result.ZipName
+		report.ZipSize = len(result.ZipData)
+	}
+
+	if report.Analysis != nil {
+		report.Analysis.ByPackage = report.Analysis.Top(config.Top)
+		if report.Analysis.Stripped {
+			_, _ = fmt.Fprintln(i.Stderr, "[infra] warning: binary has no symbol table, package sizes are unavailable")
+		}
+	}
+
+	return i.DisplayFormatted(report)
+}
@@ -0,0 +1,129 @@
+package infra
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rsb/failure"
+)
+
+type BulkStatus string
+
+const (
+	BulkOK      BulkStatus = "ok"
+	BulkFailed  BulkStatus = "failed"
+	BulkSkipped BulkStatus = "skipped"
+)
+
+// BulkItem is the outcome of one item of a bulk operation, like a param of
+// an import or a feature of `deploy --all`
+type BulkItem struct {
+	Name       string        `json:"name"`
+	Status     BulkStatus    `json:"status"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"-"`
+	DurationMS int64         `json:"duration_ms"`
+}
+
+// BulkReport collects the outcome of every item of a bulk operation, a
+// failed item does not stop the others. It is safe to record items from
+// many goroutines. Err returns the errors of the failed items so library
+// users can inspect them with the failure package.
+type BulkReport struct {
+	Operation string     `json:"operation"`
+	Items     []BulkItem `json:"items"`
+
+	mu   sync.Mutex
+	errs []error
+}
+
+func NewBulkReport(operation string) *BulkReport {
+	return &BulkReport{Operation: operation, Items: []BulkItem{}}
+}
+
+// Record adds the item with the time since start, a nil err is a success
+func (r *BulkReport) Record(name string, start time.Time, err error) {
+	item := BulkItem{Name: name, Status: BulkOK, Duration: time.Since(start)}
+	item.DurationMS = item.Duration.Milliseconds()
+	if err != nil {
+		item.Status = BulkFailed
+		item.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Items = append(r.Items, item)
+	if err != nil {
+		r.errs = append(r.errs, failure.Wrap(err, "(%s) failed", name))
+	}
+}
+
+// Skip adds an item that was left untouched, reason is reported as its error
+func (r *BulkReport) Skip(name, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Items = append(r.Items, BulkItem{Name: name, Status: BulkSkipped, Error: reason})
+}
+
+// Sort orders the items by name, items recorded concurrently arrive in the
+// order they finished
+func (r *BulkReport) Sort() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sort.SliceStable(r.Items, func(a, b int) bool {
+		return r.Items[a].Name < r.Items[b].Name
+	})
+}
+
+// Names are the items with the status, in the order they were recorded
+func (r *BulkReport) Names(status BulkStatus) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var names []string
+	for _, item := range r.Items {
+		if item.Status == status {
+			names = append(names, item.Name)
+		}
+	}
+	return names
+}
+
+func (r *BulkReport) Failed() []string {
+	return r.Names(BulkFailed)
+}
+
+func (r *BulkReport) IsFailed() bool {
+	return len(r.Failed()) > 0
+}
+
+// Err is a failure.Multi of every failed item, nil when none failed
+func (r *BulkReport) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.errs) == 0 {
+		return nil
+	}
+
+	return failure.Multiple(r.errs)
+}
+
+func (r *BulkReport) TableRows() ([]string, [][]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	header := []string{"NAME", "STATUS", "DURATION MS", "ERROR"}
+	rows := make([][]string, 0, len(r.Items))
+	for _, item := range r.Items {
+		rows = append(rows, []string{
+			item.Name,
+			string(item.Status),
+			strconv.FormatInt(item.DurationMS, 10),
+			item.Error,
+		})
+	}
+
+	return header, rows
+}
@@ -0,0 +1,174 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/cwmetrics"
+)
+
+const (
+	// CanaryCheckInterval is how often the errors of a canary are read,
+	// lambda metrics land in cloudwatch about a minute late
+	CanaryCheckInterval = time.Minute
+
+	CanaryPromoted   = "promoted"
+	CanaryRolledBack = "rolled_back"
+	CanaryCreated    = "created"
+	CanaryUnchanged  = "unchanged"
+)
+
+// CanaryReport is the outcome of `deploy --canary`. When the alias did not
+// exist it is created on the new version and nothing is baked. When the
+// alias already points at the published version, because the code and
+// config did not change, nothing is shifted and the outcome is unchanged.
+type CanaryReport struct {
+	Feature     string  `json:"feature"`
+	Alias       string  `json:"alias"`
+	FromVersion string  `json:"from_version"`
+	ToVersion   string  `json:"to_version"`
+	Weight      float64 `json:"weight"`
+	Errors      float64 `json:"errors"`
+	Invocations float64 `json:"invocations"`
+	Outcome     string  `json:"outcome"`
+}
+
+// DeployCanary deploys the feature like a plain deploy, with --with-env,
+// --tracing and its tags, publishes it as a new version and sends --canary
+// percent of the alias traffic to it. The errors of the new version
+// are checked every CanaryCheckInterval during --bake, more than
+// --max-errors, or ctx being cancelled, rolls the alias back to its current
+// version. Otherwise the alias is promoted to the new version.
+func (i *Infra) DeployCanary(ctx context.Context, service *sls.MicroService, feature sls.Feature, config DeployConfig) (*CanaryReport, error) {
+	if config.Canary < 1 || config.Canary > 99 {
+		return nil, failure.InvalidParam("--canary (%d) must be a percent from 1 to 99", config.Canary)
+	}
+
+	if config.IsEnvOnly {
+		return nil, failure.InvalidParam("--canary publishes new code, it can not be combined with --env-only")
+	}
+
+	if config.Alias == "" {
+		return nil, failure.InvalidParam("--alias is required by --canary")
+	}
+
+	api, ok := i.LambdaAPI.(LambdaTrafficShifting)
+	if !ok {
+		return nil, failure.System("i.LambdaAPI is not initialized or does not implement LambdaTrafficShifting")
+	}
+
+	if i.MetricsAPI == nil {
+		return nil, failure.System("i.MetricsAPI is not initialized, required by --canary")
+	}
+
+	if err := i.Writable("deploy canary"); err != nil {
+		return nil, failure.Wrap(err, "i.Writable failed")
+	}
+
+	if i.DryRun("DeployCanary", feature.QualifiedName, fmt.Sprintf("new version on alias (%s) with (%d%%) of the traffic", config.Alias, config.Canary)) {
+		return nil, nil
+	}
+
+	config.IsPublish = true
+	result, err := i.DeployFeature(ctx, service, feature, service.NewBuildSettings(feature), config)
+	if err != nil {
+		return nil, failure.Wrap(err, "i.DeployFeature failed")
+	}
+
+	report := CanaryReport{
+		Feature:   feature.Name,
+		Alias:     config.Alias,
+		ToVersion: result.Code.Version,
+		Weight:    float64(config.Canary) / 100,
+	}
+
+	alias, err := api.ShiftTraffic(ctx, feature.QualifiedName, config.Alias, report.ToVersion, report.Weight)
+	if err != nil {
+		return &report, failure.Wrap(err, "api.ShiftTraffic failed")
+	}
+	report.FromVersion = alias.Version
+
+	switch {
+	case alias.IsCreated:
+		report.Outcome = CanaryCreated
+		report.Weight = 1
+		return &report, nil
+	case alias.Version == report.ToVersion:
+		_, _ = fmt.Fprintf(i.Stderr, "[infra] alias (%s) already points at version (%s), nothing new to canary, use --force to deploy the code again\n", config.Alias, report.ToVersion)
+		report.Outcome = CanaryUnchanged
+		report.Weight = 0
+		return &report, nil
+	}
+
+	start := time.Now()
+	stop := i.Step("bake canary")
+	err = i.bakeCanary(ctx, feature, &report, start, config)
+	stop()
+
+	if err != nil {
+		// the rollback must happen even when ctx was cancelled
+		if _, e := api.ResetRouting(context.Background(), feature.QualifiedName, config.Alias); e != nil {
+			return &report, failure.Wrap(err, "api.ResetRouting failed: %s", e)
+		}
+		report.Outcome = CanaryRolledBack
+		return &report, failure.Wrap(err, "canary rolled back to version (%s)", report.FromVersion)
+	}
+
+	if _, err = api.PromoteAlias(ctx, feature.QualifiedName, config.Alias, report.ToVersion); err != nil {
+		return &report, failure.Wrap(err, "api.PromoteAlias failed")
+	}
+	report.Outcome = CanaryPromoted
+
+	return &report, nil
+}
+
+// bakeCanary waits out the bake time, reading the metrics of the canary
+// version at every check and once more at the end
+func (i *Infra) bakeCanary(ctx context.Context, feature sls.Feature, report *CanaryReport, start time.Time, config DeployConfig) error {
+	deadline := time.NewTimer(config.Bake)
+	defer deadline.Stop()
+	ticker := time.NewTicker(CanaryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		isDone := false
+		select {
+		case <-ctx.Done():
+			return failure.ToTimeout(ctx.Err(), "canary was interrupted")
+		case <-ticker.C:
+		case <-deadline.C:
+			isDone = true
+		}
+
+		if err := i.canaryMetrics(ctx, feature, report, start); err != nil {
+			return failure.Wrap(err, "i.canaryMetrics failed")
+		}
+
+		if report.Errors > float64(config.MaxErrors) {
+			return failure.InvalidState("version (%s) had (%g) errors in (%g) invocations, more than --max-errors (%d)", report.ToVersion, report.Errors, report.Invocations, config.MaxErrors)
+		}
+
+		if isDone {
+			return nil
+		}
+	}
+}
+
+func (i *Infra) canaryMetrics(ctx context.Context, feature sls.Feature, report *CanaryReport, start time.Time) error {
+	end := time.Now()
+	var err error
+	q := cwmetrics.LambdaVersionQuery(cwmetrics.ErrorsMetric, feature.QualifiedName, report.Alias, report.ToVersion, start, end)
+	if report.Errors, err = i.MetricsAPI.Sum(ctx, q); err != nil {
+		return failure.Wrap(err, "i.MetricsAPI.Sum failed (%s)", q.Name)
+	}
+
+	q = cwmetrics.LambdaVersionQuery(cwmetrics.InvocationsMetric, feature.QualifiedName, report.Alias, report.ToVersion, start, end)
+	if report.Invocations, err = i.MetricsAPI.Sum(ctx, q); err != nil {
+		return failure.Wrap(err, "i.MetricsAPI.Sum failed (%s)", q.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,213 @@
+package infra
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/cwlogs"
+	"github.com/rsb/sls/pstore"
+	"github.com/spf13/cobra"
+)
+
+func SetupCloneEnvCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.CloneEnvCmd == nil {
+		in.CloneEnvCmd = CloneEnvCmd
+	}
+	in.CloneEnvCmd.RunE = in.RunCloneEnv
+	in.ParentCmd.AddCommand(in.CloneEnvCmd)
+
+	var cb CloneEnvBind
+	if err := Bind(in.CloneEnvCmd, in.Viper, &cb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.CloneEnvCmd")
+	}
+
+	return nil
+}
+
+var CloneEnvCmd = &cobra.Command{
+	Use:   "clone-env",
+	Short: "clone the params and lambdas of an env into a new env, like a preview env for a pull request",
+	Args:  cobra.NoArgs,
+}
+
+type CloneEnvBind struct {
+	From        string `conf:"cli:from, cli-u: Env the params are copied from"`
+	To          string `conf:"cli:to, cli-u: New env the params and lambdas are created in"`
+	IsOverwrite bool   `conf:"cli:overwrite, cli-u: Overwrite the params that already exist in --to"`
+	SkipParams  bool   `conf:"cli:skip-params, cli-u: Do not copy the params"`
+	IsApplyTF   bool   `conf:"cli:apply-tf, cli-u: Apply the terraform of the service in --to which creates its lambdas"`
+	TFBinary    string `conf:"default:terraform, cli:tf-binary, cli-u: Path or name of the terraform binary used by --apply-tf"`
+	IsDeploy    bool   `conf:"cli:deploy, cli-u: Deploy the code and env vars of every feature into --to"`
+	Concurrency int    `conf:"default:4, cli:concurrency, cli-u: How many features --deploy builds and deploys at once"`
+}
+
+type CloneEnvConfig struct {
+	CmdConfig
+	CloneEnvBind
+}
+
+// CloneEnvReport lists what `clone-env` did. IsParamsShared is true when
+// both envs read the same param path, there was nothing to copy.
+type CloneEnvReport struct {
+	From           string             `json:"from"`
+	To             string             `json:"to"`
+	Params         *pstore.CopyReport `json:"params,omitempty"`
+	IsParamsShared bool               `json:"is_params_shared"`
+	IsTFApplied    bool               `json:"is_tf_applied"`
+	Code           *DeployAllReport   `json:"code,omitempty"`
+	Config         *DeployAllReport   `json:"config,omitempty"`
+}
+
+// RunCloneEnv runs `<service> infra clone-env --from qa --to qa2` which
+// seeds a new env from an existing one. The params are copied with the
+// naming prefix of --from rewritten to the one of --to, so values that name
+// resources, like `use1-qa-orders`, point at the resources of the new env.
+// --apply-tf creates the lambdas with the terraform of the service and
+// --deploy ships their code and env vars.
+// `<service> infra clone-env --from qa --to qa2 [--apply-tf] [--deploy]`
+func (i *Infra) RunCloneEnv(cmd *cobra.Command, _ []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	var config CloneEnvConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if config.From == "" || config.To == "" {
+		return failure.InvalidParam("--from and --to are required")
+	}
+
+	if config.From == config.To {
+		return failure.InvalidParam("--from and --to are the same env (%s)", config.From)
+	}
+
+	if err := i.Writable("clone-env"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	from := config.CmdConfig
+	from.Env = config.From
+	src, err := i.LoadService(from)
+	if err != nil {
+		return failure.Wrap(err, "i.LoadService failed (%s)", from.Env)
+	}
+
+	to := config.CmdConfig
+	to.Env = config.To
+	dst, err := i.LoadService(to)
+	if err != nil {
+		return failure.Wrap(err, "i.LoadService failed (%s)", to.Env)
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	report := CloneEnvReport{From: config.From, To: config.To}
+	err = i.CloneEnv(ctx, src, dst, to, config.CloneEnvBind, &report)
+	dErr := i.DisplayFormatted(report)
+	if err != nil {
+		return failure.Wrap(err, "i.CloneEnv failed")
+	}
+
+	return dErr
+}
+
+// CloneEnv runs the steps of clone-env in order, stopping at the first one
+// that fails. to is the config of the new env.
+func (i *Infra) CloneEnv(ctx context.Context, src, dst *sls.MicroService, to CmdConfig, b CloneEnvBind, report *CloneEnvReport) error {
+	if !b.SkipParams {
+		if err := i.cloneParams(ctx, src, dst, to, b, report); err != nil {
+			return failure.Wrap(err, "i.cloneParams failed")
+		}
+	}
+
+	if b.IsApplyTF {
+		tb := TFBind{Binary: b.TFBinary}
+		tf, err := i.NewTerraform(dst.Resource, tb)
+		if err != nil {
+			return failure.Wrap(err, "i.NewTerraform failed")
+		}
+
+		if err = i.TFInit(ctx, tf, dst.Resource, tb); err != nil {
+			return failure.Wrap(err, "i.TFInit failed")
+		}
+
+		if err = i.TFApply(ctx, tf, dst.Resource, tb, false); err != nil {
+			return failure.Wrap(err, "i.TFApply failed")
+		}
+		report.IsTFApplied = true
+	}
+
+	if !b.IsDeploy {
+		return nil
+	}
+
+	// a new function has no env vars yet, so the code and the config are
+	// both deployed
+	deploy := DeployConfig{
+		CmdConfig: to,
+		DeployBind: DeployBind{
+			LogRetention: cwlogs.DefaultRetentionDays,
+			Concurrency:  b.Concurrency,
+		},
+	}
+
+	code := i.DeployAll(ctx, dst, deploy)
+	report.Code = &code
+	if code.IsFailed() {
+		return failure.System("(%d) features failed to deploy their code", len(code.Failed))
+	}
+
+	deploy.IsEnvOnly = true
+	env := i.DeployAll(ctx, dst, deploy)
+	report.Config = &env
+	if env.IsFailed() {
+		return failure.System("(%d) features failed to deploy their env vars", len(env.Failed))
+	}
+
+	return nil
+}
+
+func (i *Infra) cloneParams(ctx context.Context, src, dst *sls.MicroService, to CmdConfig, b CloneEnvBind, report *CloneEnvReport) error {
+	if src.Name.AppTitle() == dst.Name.AppTitle() {
+		report.IsParamsShared = true
+		return nil
+	}
+
+	store, err := i.ParamStoreFor(to)
+	if err != nil {
+		return failure.Wrap(err, "i.ParamStoreFor failed (%s)", to.Env)
+	}
+
+	api, ok := store.(ParamRewriting)
+	if !ok {
+		return failure.System("the param store of (%s) does not implement ParamRewriting", to.Env)
+	}
+
+	defer i.Step("clone params")()
+	copied, err := api.CopyPathRewrite(ctx, src.Name.AppTitle(), dst.Name.AppTitle(), b.IsOverwrite, PrefixRewriter(src.Name.Prefix, dst.Name.Prefix))
+	report.Params = &copied
+	if err != nil {
+		return failure.Wrap(err, "api.CopyPathRewrite failed")
+	}
+
+	return nil
+}
+
+// PrefixRewriter replaces the naming prefix of one env with the one of
+// another. The prefix must end at a word boundary so `use1-qa` is not
+// rewritten inside `use1-qa2`.
+func PrefixRewriter(from, to sls.Prefix) func(string) string {
+	re := regexp.MustCompile(regexp.QuoteMeta(from.String()) + `\b`)
+	return func(value string) string {
+		return re.ReplaceAllLiteralString(value, to.String())
+	}
+}
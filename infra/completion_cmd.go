@@ -0,0 +1,160 @@
+package infra
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/spf13/cobra"
+)
+
+func SetupCompletionCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.CompletionCmd == nil {
+		in.CompletionCmd = CompletionCmd
+	}
+	in.CompletionCmd.RunE = in.RunCompletion
+	in.ParentCmd.AddCommand(in.CompletionCmd)
+
+	// commands given a feature as their first arg, a command that already
+	// has a completion function keeps it
+	featureCmds := []*cobra.Command{
+		in.DeployCmd,
+		in.DeployRollbackCmd,
+		in.DestroyCmd,
+		in.EnvCmd,
+		in.EnvDiffCmd,
+		in.InvokeCmd,
+		in.LogsCmd,
+		in.PStoreCmd,
+		in.PStoreMigrateCmd,
+		in.TuneCmd,
+		in.ValidateCmd,
+		in.ConcurrencyScheduleCmd,
+		in.ConcurrencySetCmd,
+		in.ConcurrencyShowCmd,
+		in.URLCmd,
+		in.ESMListCmd,
+		in.ESMCreateCmd,
+		in.ESMUpdateCmd,
+		in.ESMPauseCmd,
+		in.ESMResumeCmd,
+		in.AliasCreateCmd,
+		in.AliasUpdateCmd,
+		in.AliasListCmd,
+		in.VersionPublishCmd,
+		in.VersionListCmd,
+		in.ConsoleCmd,
+		in.ReportCmd,
+		in.ScheduleListCmd,
+		in.SchedulePutCmd,
+		in.ScheduleDeleteCmd,
+		in.WarmCmd,
+		in.PermissionsCmd,
+		in.MetricsCmd,
+	}
+	for _, cmd := range featureCmds {
+		if cmd != nil && cmd.ValidArgsFunction == nil {
+			cmd.ValidArgsFunction = in.CompleteFeatures
+		}
+	}
+
+	return nil
+}
+
+var CompletionCmd = &cobra.Command{
+	Use:       "completion <bash|zsh|fish>",
+	Short:     "generate the shell completion script of the cli",
+	Long:      "generate the shell completion script of the cli, for bash: source <(<service> infra completion bash)",
+	ValidArgs: []string{"bash", "zsh", "fish"},
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+}
+
+// RunCompletion runs `<service> infra completion <bash|zsh|fish>` which
+// writes the completion script of the whole cli, not only infra, to stdout
+// `<service> infra completion <bash|zsh|fish>`
+func (i *Infra) RunCompletion(cmd *cobra.Command, args []string) error {
+	root := cmd.Root()
+	var err error
+	switch args[0] {
+	case "bash":
+		err = root.GenBashCompletionV2(i.Stdout, true)
+	case "zsh":
+		err = root.GenZshCompletion(i.Stdout)
+	case "fish":
+		err = root.GenFishCompletion(i.Stdout, true)
+	default:
+		return failure.InvalidParam("shell (%s) is not supported, use bash or zsh or fish", args[0])
+	}
+
+	if err != nil {
+		return failure.ToSystem(err, "completion script for (%s) failed", args[0])
+	}
+
+	return nil
+}
+
+// CompleteFeatures completes the first arg with the features found in the
+// lambdas dir of the service. Completion must never fail, so any error
+// leaves the shell without suggestions.
+func (i *Infra) CompleteFeatures(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var config CmdConfig
+	if err := Process(cmd, i.Viper, &config, i.Prefix...); err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	names, err := i.FeatureNames(config)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var result []string
+	for _, name := range names {
+		if strings.HasPrefix(name, toComplete) {
+			result = append(result, name)
+		}
+	}
+
+	return result, cobra.ShellCompDirectiveNoFileComp
+}
+
+// FeatureNames are the names of the features of the service, sorted. They
+// include the trigger when the config names features with it.
+func (i *Infra) FeatureNames(config CmdConfig) ([]string, error) {
+	service := i.Service
+	if service == nil {
+		var err error
+		if service, err = i.LoadService(config); err != nil {
+			return nil, failure.Wrap(err, "i.LoadService failed")
+		}
+	}
+
+	if len(service.Features) == 0 {
+		if err := service.LoadFeaturesFromFilesystem(); err != nil {
+			return nil, failure.Wrap(err, "service.LoadFeaturesFromFilesystem failed")
+		}
+	}
+
+	names := make([]string, 0, len(service.Features))
+	for title, feature := range service.Features {
+		names = append(names, featureCompletionName(title, feature, config))
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func featureCompletionName(title string, feature sls.Feature, config CmdConfig) string {
+	if config.NameIncludesTrigger() {
+		return feature.NameWithTrigger()
+	}
+	return title
+}
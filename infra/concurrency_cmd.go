@@ -0,0 +1,360 @@
+package infra
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/lambda"
+	"github.com/rsb/sls/scaling"
+	"github.com/spf13/cobra"
+)
+
+func SetupConcurrencyCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.ConcurrencyCmd == nil {
+		in.ConcurrencyCmd = ConcurrencyCmd
+	}
+	in.ParentCmd.AddCommand(in.ConcurrencyCmd)
+
+	if in.ConcurrencyScheduleCmd == nil {
+		in.ConcurrencyScheduleCmd = ConcurrencyScheduleCmd
+	}
+	in.ConcurrencyScheduleCmd.RunE = in.RunConcurrencySchedule
+	in.ConcurrencyCmd.AddCommand(in.ConcurrencyScheduleCmd)
+
+	var sb ConcurrencyScheduleBind
+	if err := Bind(in.ConcurrencyScheduleCmd, in.Viper, &sb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.ConcurrencyScheduleCmd")
+	}
+
+	if in.ConcurrencySetCmd == nil {
+		in.ConcurrencySetCmd = ConcurrencySetCmd
+	}
+	in.ConcurrencySetCmd.RunE = in.RunConcurrencySet
+	in.ConcurrencyCmd.AddCommand(in.ConcurrencySetCmd)
+
+	var setb ConcurrencySetBind
+	if err := Bind(in.ConcurrencySetCmd, in.Viper, &setb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.ConcurrencySetCmd")
+	}
+
+	if in.ConcurrencyShowCmd == nil {
+		in.ConcurrencyShowCmd = ConcurrencyShowCmd
+	}
+	in.ConcurrencyShowCmd.RunE = in.RunConcurrencyShow
+	in.ConcurrencyCmd.AddCommand(in.ConcurrencyShowCmd)
+
+	var showb ConcurrencyShowBind
+	if err := Bind(in.ConcurrencyShowCmd, in.Viper, &showb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.ConcurrencyShowCmd")
+	}
+
+	return nil
+}
+
+var ConcurrencyCmd = &cobra.Command{
+	Use:   "concurrency",
+	Short: "manage the concurrency of a lambda",
+}
+
+var ConcurrencyScheduleCmd = &cobra.Command{
+	Use:   "schedule <FEATURE>",
+	Short: "schedule provisioned concurrency on an alias (ex business hours only)",
+	Args:  cobra.ExactArgs(1),
+}
+
+var ConcurrencySetCmd = &cobra.Command{
+	Use:   "set <FEATURE>",
+	Short: "set the reserved concurrency of a lambda or the provisioned concurrency of an alias",
+	Args:  cobra.ExactArgs(1),
+}
+
+var ConcurrencyShowCmd = &cobra.Command{
+	Use:   "show <FEATURE>",
+	Short: "show the reserved concurrency of a lambda and the provisioned concurrency of an alias",
+	Args:  cobra.ExactArgs(1),
+}
+
+type ConcurrencyScheduleBind struct {
+	Alias     string `conf:"default:live, cli:alias, cli-u: Lambda alias that holds the provisioned concurrency"`
+	ScaleUp   string `conf:"default:cron(0 8 ? * MON-FRI *), cli:scale-up, cli-u: Schedule expression that scales up to --provisioned"`
+	ScaleDown string `conf:"default:cron(0 18 ? * MON-FRI *), cli:scale-down, cli-u: Schedule expression that scales down to --idle"`
+	Timezone  string `conf:"default:UTC, cli:timezone, cli-u: Timezone used by the schedule expressions"`
+	Amount    int32  `conf:"default:1, cli:provisioned, cli-u: Provisioned concurrency while scaled up"`
+	Idle      int32  `conf:"default:0, cli:idle, cli-u: Provisioned concurrency while scaled down"`
+	IsList    bool   `conf:"cli:list, cli-u: Only list the existing schedules"`
+}
+
+type ConcurrencyScheduleConfig struct {
+	CmdConfig
+	ConcurrencyScheduleBind
+}
+
+// ConcurrencyPlan is every change `infra concurrency schedule` makes
+type ConcurrencyPlan struct {
+	Target  scaling.ScalableTarget
+	Actions []scaling.ScheduledAction
+}
+
+// RunConcurrencySchedule runs `<service> infra concurrency schedule <FEATURE>`
+// which registers the alias with application auto scaling and attaches a
+// scale up and scale down scheduled action to it.
+// `<service> infra concurrency schedule <FEATURE> [--list | --dry-run]`
+func (i *Infra) RunConcurrencySchedule(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	if i.ScalingAPI == nil {
+		return failure.System("i.ScalingAPI is not initialized")
+	}
+
+	var config ConcurrencyScheduleConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+	target := scaling.ScalableTarget{
+		FunctionName: feature.QualifiedName,
+		Alias:        config.Alias,
+		Min:          config.Idle,
+		Max:          config.Amount,
+	}
+
+	if config.IsList {
+		result, err := i.ScalingAPI.Schedules(ctx, target)
+		if err != nil {
+			return failure.Wrap(err, "i.ScalingAPI.Schedules failed")
+		}
+
+		return i.DisplayFormatted(result)
+	}
+
+	plan := NewConcurrencyPlan(target, config)
+	for _, a := range plan.Actions {
+		if err = a.Validate(); err != nil {
+			return failure.Wrap(err, "a.Validate failed")
+		}
+	}
+
+	if config.DryRun {
+		return i.DisplayFormatted(plan)
+	}
+
+	if err = i.Writable("concurrency schedule"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	if err = i.ScalingAPI.RegisterTarget(ctx, plan.Target); err != nil {
+		return failure.Wrap(err, "i.ScalingAPI.RegisterTarget failed")
+	}
+
+	for _, a := range plan.Actions {
+		if err = i.ScalingAPI.PutSchedule(ctx, a); err != nil {
+			return failure.Wrap(err, "i.ScalingAPI.PutSchedule failed")
+		}
+	}
+
+	return i.DisplayFormatted(plan)
+}
+
+func NewConcurrencyPlan(target scaling.ScalableTarget, config ConcurrencyScheduleConfig) ConcurrencyPlan {
+	name := fmt.Sprintf("%s-%s", target.FunctionName, target.Alias)
+	return ConcurrencyPlan{
+		Target: target,
+		Actions: []scaling.ScheduledAction{
+			{
+				Name:     name + "-scale-up",
+				Target:   target,
+				Schedule: config.ScaleUp,
+				Timezone: config.Timezone,
+				Min:      config.Amount,
+				Max:      config.Amount,
+			},
+			{
+				Name:     name + "-scale-down",
+				Target:   target,
+				Schedule: config.ScaleDown,
+				Timezone: config.Timezone,
+				Min:      config.Idle,
+				Max:      config.Idle,
+			},
+		},
+	}
+}
+
+// ConcurrencySetBind only changes what is given, --reserved and
+// --provisioned are told apart from their zero value by whether the flag
+// was set
+type ConcurrencySetBind struct {
+	Reserved     int32  `conf:"cli:reserved, cli-u: Concurrency reserved for the lambda and its cap (0 throttles every invocation)"`
+	IsNoReserved bool   `conf:"cli:no-reserved, cli-u: Remove the reserved concurrency of the lambda"`
+	Provisioned  int32  `conf:"cli:provisioned, cli-u: Environments kept initialized for --alias (0 removes them)"`
+	Alias        string `conf:"default:live, cli:alias, cli-u: Lambda alias that holds the provisioned concurrency"`
+}
+
+type ConcurrencySetConfig struct {
+	CmdConfig
+	ConcurrencySetBind
+}
+
+type ConcurrencyShowBind struct {
+	Alias string `conf:"default:live, cli:alias, cli-u: Lambda alias whose provisioned concurrency is shown"`
+}
+
+type ConcurrencyShowConfig struct {
+	CmdConfig
+	ConcurrencyShowBind
+}
+
+// ConcurrencyReport is the concurrency of the lambda of a feature
+type ConcurrencyReport struct {
+	Feature string `json:"feature"`
+	*lambda.ConcurrencyReport
+}
+
+func (r ConcurrencyReport) TableRows() ([]string, [][]string) {
+	header := []string{"FEATURE", "RESERVED", "ALIAS", "PROVISIONED", "ALLOCATED", "STATUS"}
+	reserved := "none"
+	if r.Reserved != nil {
+		reserved = strconv.Itoa(int(*r.Reserved))
+	}
+
+	provisioned, allocated := "", ""
+	if r.Alias != "" {
+		provisioned = strconv.Itoa(int(r.Requested))
+		allocated = strconv.Itoa(int(r.Allocated))
+	}
+
+	return header, [][]string{{r.Feature, reserved, r.Alias, provisioned, allocated, r.Status}}
+}
+
+// RunConcurrencySet runs `<service> infra concurrency set <FEATURE>` which
+// reserves concurrency for the lambda and provisions environments for an
+// alias, latency critical apigw features keep them warm this way. Only the
+// flags that are given change anything.
+// `<service> infra concurrency set <FEATURE> [--reserved 50 | --no-reserved] [--provisioned 5 [--alias live]]`
+func (i *Infra) RunConcurrencySet(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	if i.LambdaAPI == nil {
+		return failure.System("i.LambdaAPI is not initialized")
+	}
+
+	var config ConcurrencySetConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	isReserved := cmd.Flags().Changed("reserved")
+	isProvisioned := cmd.Flags().Changed("provisioned")
+	if isReserved && config.IsNoReserved {
+		return failure.InvalidParam("--reserved and --no-reserved can not be combined")
+	}
+	if !isReserved && !config.IsNoReserved && !isProvisioned {
+		return failure.InvalidParam("one of --reserved, --no-reserved or --provisioned is required")
+	}
+
+	if err := i.Writable("concurrency set"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	settings := lambda.FeatureSettings{QualifiedName: feature.QualifiedName}
+	var changes []string
+	switch {
+	case config.IsNoReserved:
+		none := lambda.NoReservedConcurrency
+		settings.ReservedConcurrency = &none
+		changes = append(changes, "no reserved concurrency")
+	case isReserved:
+		settings.ReservedConcurrency = &config.Reserved
+		changes = append(changes, fmt.Sprintf("reserved concurrency of (%d)", config.Reserved))
+	}
+
+	if isProvisioned {
+		settings.ProvisionedConcurrency = &lambda.ProvisionedConcurrencySettings{Alias: config.Alias, Amount: config.Provisioned}
+		if err = settings.ProvisionedConcurrency.Validate(); err != nil {
+			return failure.Wrap(err, "settings.ProvisionedConcurrency.Validate failed")
+		}
+		changes = append(changes, fmt.Sprintf("provisioned concurrency of (%d) on (%s)", config.Provisioned, config.Alias))
+	}
+
+	if i.DryRun("PutFunctionConcurrency", feature.QualifiedName, strings.Join(changes, ", ")) {
+		return nil
+	}
+
+	defer i.Step("concurrency set")()
+	if _, err = i.LambdaAPI.UpdateConfig(ctx, settings); err != nil {
+		return failure.Wrap(err, "i.LambdaAPI.UpdateConfig failed (%s)", feature.QualifiedName)
+	}
+
+	api, ok := i.LambdaAPI.(LambdaConcurrency)
+	if !ok {
+		return nil
+	}
+
+	report, err := api.Concurrency(ctx, feature.QualifiedName, config.Alias)
+	if err != nil {
+		return failure.Wrap(err, "api.Concurrency failed (%s)", feature.QualifiedName)
+	}
+
+	return i.DisplayFormatted(ConcurrencyReport{Feature: feature.Name, ConcurrencyReport: report})
+}
+
+// RunConcurrencyShow runs `<service> infra concurrency show <FEATURE>` which
+// shows the reserved concurrency of the lambda and how many environments
+// are provisioned for the alias
+// `<service> infra concurrency show <FEATURE> [--alias live]`
+func (i *Infra) RunConcurrencyShow(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	api, ok := i.LambdaAPI.(LambdaConcurrency)
+	if !ok {
+		return failure.System("i.LambdaAPI is not initialized or does not implement LambdaConcurrency")
+	}
+
+	var config ConcurrencyShowConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	defer i.Step("concurrency show")()
+	report, err := api.Concurrency(ctx, feature.QualifiedName, config.Alias)
+	if err != nil {
+		return failure.Wrap(err, "api.Concurrency failed (%s)", feature.QualifiedName)
+	}
+
+	return i.DisplayFormatted(ConcurrencyReport{Feature: feature.Name, ConcurrencyReport: report})
+}
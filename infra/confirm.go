@@ -0,0 +1,63 @@
+package infra
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/rsb/failure"
+)
+
+// Confirm asks a yes/no question on stderr and reads the answer from
+// i.Stdin, anything but y or yes is a no. With --yes it answers yes without
+// asking. A closed stdin, like in ci, is a no so destructive commands there
+// need --yes. A dry run changes nothing so it is not asked either.
+func (i *Infra) Confirm(c CmdConfig, prompt string) (bool, error) {
+	if c.IsYes || c.DryRun {
+		return true, nil
+	}
+
+	if i.Stdin == nil {
+		return false, failure.System("i.Stdin is not initialized, use --yes to skip the confirmation")
+	}
+
+	if _, err := fmt.Fprintf(i.Stderr, "%s [y/N] ", prompt); err != nil {
+		return false, failure.ToSystem(err, "fmt.Fprintf failed")
+	}
+
+	answer, err := i.stdinReader().ReadString('\n')
+	if err != nil && answer == "" {
+		return false, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// stdinReader is the one reader of i.Stdin shared by every prompt, a new
+// reader could buffer the answers of the prompts that follow
+func (i *Infra) stdinReader() *bufio.Reader {
+	if i.answers == nil {
+		i.answers = bufio.NewReader(i.Stdin)
+	}
+	return i.answers
+}
+
+// RequireConfirm is Confirm for commands that stop when the answer is no, a
+// no is reported as InvalidState
+func (i *Infra) RequireConfirm(c CmdConfig, prompt string) error {
+	ok, err := i.Confirm(c, prompt)
+	if err != nil {
+		return failure.Wrap(err, "i.Confirm failed")
+	}
+
+	if !ok {
+		return failure.InvalidState("not confirmed: %s", prompt)
+	}
+
+	return nil
+}
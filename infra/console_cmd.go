@@ -0,0 +1,162 @@
+package infra
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/cwlogs"
+	"github.com/spf13/cobra"
+)
+
+func SetupConsoleCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.ConsoleCmd == nil {
+		in.ConsoleCmd = ConsoleCmd
+	}
+	in.ConsoleCmd.RunE = in.RunConsole
+	in.ParentCmd.AddCommand(in.ConsoleCmd)
+
+	var cb ConsoleBind
+	if err := Bind(in.ConsoleCmd, in.Viper, &cb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.ConsoleCmd")
+	}
+
+	return nil
+}
+
+var ConsoleCmd = &cobra.Command{
+	Use:   "console <FEATURE>",
+	Short: "print links to the aws console for the lambda and its log group and params",
+	Args:  cobra.ExactArgs(1),
+}
+
+type ConsoleBind struct {
+	IsLogs   bool `conf:"cli:logs, cli-u: Only the link to the log group of the lambda"`
+	IsConfig bool `conf:"cli:config, cli-u: Only the link to the configuration of the lambda"`
+	IsOpen   bool `conf:"cli:open, cli-u: Open the link in the browser (the lambda page when every link is printed)"`
+}
+
+type ConsoleConfig struct {
+	CmdConfig
+	ConsoleBind
+}
+
+// ConsoleLinks are the aws console pages of a feature, only the ones asked
+// for are set
+type ConsoleLinks struct {
+	Feature string `json:"feature"`
+	Lambda  string `json:"lambda,omitempty"`
+	Config  string `json:"config,omitempty"`
+	Logs    string `json:"logs,omitempty"`
+	Params  string `json:"params,omitempty"`
+}
+
+func (l ConsoleLinks) TableRows() ([]string, [][]string) {
+	header := []string{"FEATURE", "PAGE", "URL"}
+	var rows [][]string
+	for _, link := range [][2]string{{"lambda", l.Lambda}, {"config", l.Config}, {"logs", l.Logs}, {"params", l.Params}} {
+		if link[1] != "" {
+			rows = append(rows, []string{l.Feature, link[0], link[1]})
+		}
+	}
+
+	return header, rows
+}
+
+// NewConsoleLinks builds every console link of the feature from the region
+// of the service and the qualified name of the feature
+func NewConsoleLinks(service *sls.MicroService, feature sls.Feature) ConsoleLinks {
+	region := service.Account.Region
+	if region.IsEmpty() {
+		region = sls.DefaultRegion
+	}
+
+	return ConsoleLinks{
+		Feature: feature.Name,
+		Lambda:  sls.LambdaConsoleURL(region, feature.QualifiedName),
+		Config:  sls.LambdaConsoleURL(region, feature.QualifiedName, "configure"),
+		Logs:    sls.LogGroupConsoleURL(region, cwlogs.FeatureLogGroup(feature.QualifiedName)),
+		Params:  sls.ParamPathConsoleURL(region, "/"+feature.ParamTitle(service.Name.AppTitle())),
+	}
+}
+
+// RunConsole runs `<service> infra console <FEATURE>` which prints the aws
+// console links of the lambda, its log group and its param path. --logs or
+// --config narrow it to one link and --open opens it in the browser.
+// `<service> infra console <FEATURE> [--logs | --config] [--open]`
+func (i *Infra) RunConsole(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	var config ConsoleConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if config.IsLogs && config.IsConfig {
+		return failure.InvalidParam("--logs and --config each select one link, use only one of them")
+	}
+
+	service, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	links := NewConsoleLinks(service, feature)
+	open := links.Lambda
+	switch {
+	case config.IsLogs:
+		links = ConsoleLinks{Feature: links.Feature, Logs: links.Logs}
+		open = links.Logs
+	case config.IsConfig:
+		links = ConsoleLinks{Feature: links.Feature, Config: links.Config}
+		open = links.Config
+	}
+
+	if err := i.DisplayFormatted(links); err != nil {
+		return failure.Wrap(err, "i.DisplayFormatted failed")
+	}
+
+	if config.IsOpen {
+		ctx, stop := i.Context(cmd)
+		defer stop()
+
+		if err = OpenBrowser(ctx, open); err != nil {
+			return failure.Wrap(err, "OpenBrowser failed")
+		}
+	}
+
+	return nil
+}
+
+// OpenBrowser opens u with the default browser of the desktop
+func OpenBrowser(ctx context.Context, u string) error {
+	var name string
+	var args []string
+	switch runtime.GOOS {
+	case "darwin":
+		name = "open"
+	case "windows":
+		name, args = "rundll32", []string{"url.dll,FileProtocolHandler"}
+	default:
+		name = "xdg-open"
+	}
+
+	bin, err := exec.LookPath(name)
+	if err != nil {
+		return failure.ToSystem(err, "exec.LookPath failed (%s), open the link by hand", name)
+	}
+
+	if err = exec.CommandContext(ctx, bin, append(args, u)...).Run(); err != nil {
+		return failure.ToSystem(err, "(%s) failed to open (%s)", name, u)
+	}
+
+	return nil
+}
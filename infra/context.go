@@ -0,0 +1,50 @@
+package infra
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rsb/sls"
+	"github.com/spf13/cobra"
+)
+
+// CancelSignals are the signals that cancel a running command
+var CancelSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// ContextCompiling is implemented by lambda clients whose builds stop when
+// ctx is done, like lambda.Client
+type ContextCompiling interface {
+	CompileContext(ctx context.Context, data sls.BuildSettings) (sls.BuildResult, error)
+}
+
+// Context is the context of a running command. It is built on the context
+// the root command was executed with and is cancelled on CancelSignals, so
+// a Ctrl-C aborts in flight aws calls and builds. Once cancelled the
+// signals are released, a second Ctrl-C kills the process right away.
+func (i *Infra) Context(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	parent := context.Background()
+	if cmd != nil && cmd.Context() != nil {
+		parent = cmd.Context()
+	}
+
+	ctx, stop := signal.NotifyContext(parent, CancelSignals...)
+	context.AfterFunc(ctx, stop)
+
+	return ctx, stop
+}
+
+// compile builds the feature with CompileContext when i.LambdaAPI supports
+// it, otherwise the build runs to the end even when ctx is done
+func (i *Infra) compile(ctx context.Context, settings sls.BuildSettings) (sls.BuildResult, error) {
+	if c, ok := i.LambdaAPI.(ContextCompiling); ok {
+		return c.CompileContext(ctx, settings)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return sls.BuildResult{Settings: settings}, err
+	}
+
+	return i.LambdaAPI.Compile(settings)
+}
@@ -0,0 +1,146 @@
+package infra
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/lambda"
+)
+
+// FeatureDeployResult is the outcome of deploying one feature, Code is empty
+// with --env-only and Config is empty otherwise
+type FeatureDeployResult struct {
+	Feature string                      `json:"feature"`
+	Code    *lambda.FeatureUpdateReport `json:"code,omitempty"`
+	Config  *lambda.FeatureUpdateReport `json:"config,omitempty"`
+	Error   string                      `json:"error,omitempty"`
+	// Settings are the build settings of the code, nil with --env-only
+	Settings *sls.BuildSettings `json:"-"`
+}
+
+// DeployAllReport aggregates `deploy --all`, Features is sorted by name and
+// Failed lists the features that have an Error. Summary has the status and
+// duration of every feature.
+type DeployAllReport struct {
+	Features  []FeatureDeployResult `json:"features"`
+	Failed    []string              `json:"failed,omitempty"`
+	Summary   *BulkReport           `json:"summary"`
+	IsEnvOnly bool                  `json:"env_only,omitempty"`
+}
+
+// TableRows is the summary, with --env-only it also has the update status
+// lambda reported for the new environment of each feature
+func (r DeployAllReport) TableRows() ([]string, [][]string) {
+	header, rows := r.Summary.TableRows()
+	if !r.IsEnvOnly {
+		return header, rows
+	}
+
+	status := map[string]string{}
+	for _, f := range r.Features {
+		if f.Config != nil {
+			status[f.Feature] = f.Config.LastUpdateStatus
+		}
+	}
+
+	header = append(header[:2:2], append([]string{"UPDATE STATUS"}, header[2:]...)...)
+	for idx, row := range rows {
+		rows[idx] = append(row[:2:2], append([]string{status[row[0]]}, row[2:]...)...)
+	}
+
+	return header, rows
+}
+
+func (r DeployAllReport) IsFailed() bool {
+	return len(r.Failed) > 0
+}
+
+// DeployAll deploys every feature of the service with at most
+// config.Concurrency features in flight. With --env-only nothing is built,
+// the environment of every lambda is refreshed from pstore, ex after a
+// secret was rotated. A failed feature does not stop the
+// others, every failure is collected in the report.
+func (i *Infra) DeployAll(ctx context.Context, service *sls.MicroService, config DeployConfig) DeployAllReport {
+	names := make([]string, 0, len(service.Features))
+	for name := range service.Features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	limit := config.Concurrency
+	if limit < 1 {
+		limit = 1
+	}
+
+	operation := "deploy"
+	if config.IsEnvOnly {
+		operation = "deploy env"
+	}
+
+	summary := NewBulkReport(operation)
+	progress := i.StartProgress(operation, len(names))
+	defer progress.Done()
+	results := make([]FeatureDeployResult, len(names))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for idx, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int, feature sls.Feature) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			start := time.Now()
+			result, err := i.deployIsolated(ctx, service, feature, config)
+			if err == nil && result.Code != nil && result.Code.IsUnchanged {
+				summary.Skip(feature.Name, "unchanged")
+			} else {
+				summary.Record(feature.Name, start, err)
+			}
+			progress.Add(1, feature.Name)
+			if err != nil {
+				result.Error = err.Error()
+			}
+			results[idx] = result
+		}(idx, service.Features[name])
+	}
+	wg.Wait()
+
+	summary.Sort()
+	report := DeployAllReport{Features: results, Summary: summary, IsEnvOnly: config.IsEnvOnly}
+	for _, r := range results {
+		if r.Error != "" {
+			report.Failed = append(report.Failed, r.Feature)
+		}
+	}
+
+	return report
+}
+
+// deployIsolated builds the feature in its own directory under the build
+// dir, every feature compiles to the same binary and zip names so concurrent
+// builds would overwrite each other
+func (i *Infra) deployIsolated(ctx context.Context, service *sls.MicroService, feature sls.Feature, config DeployConfig) (FeatureDeployResult, error) {
+	settings := service.NewBuildSettings(feature)
+	settings.BuildDir = filepath.Join(settings.BuildDir, feature.Name)
+	settings.BinPath = filepath.Join(settings.BuildDir, settings.BinName)
+
+	if !config.IsEnvOnly {
+		if err := os.MkdirAll(settings.BuildDir, 0o755); err != nil {
+			return FeatureDeployResult{Feature: feature.Name}, failure.ToSystem(err, "os.MkdirAll failed (%s)", settings.BuildDir)
+		}
+	}
+
+	result, err := i.DeployFeature(ctx, service, feature, settings, config)
+	if err != nil {
+		return result, failure.Wrap(err, "i.DeployFeature failed (%s)", feature.Name)
+	}
+
+	return result, nil
+}
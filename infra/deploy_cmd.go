@@ -2,10 +2,19 @@ package infra
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/rsb/failure"
 	"github.com/rsb/sls"
+	"github.com/rsb/sls/cwlogs"
 	"github.com/rsb/sls/lambda"
+	"github.com/rsb/sls/restapi"
+	"github.com/rsb/sls/s3"
+	"github.com/rsb/sls/slsctx"
+	"github.com/rsb/sls/sts"
 	"github.com/spf13/cobra"
 )
 
@@ -27,17 +36,131 @@ func SetupDeployCmd(in *Infra) error {
 		return failure.Wrap(err, "Bind failed for in.Deploy.Cmd")
 	}
 
+	if in.DeployRollbackCmd == nil {
+		in.DeployRollbackCmd = DeployRollbackCmd
+	}
+	in.DeployRollbackCmd.RunE = in.RunDeployRollback
+	in.DeployCmd.AddCommand(in.DeployRollbackCmd)
+
+	var rb DeployRollbackBind
+	if err := Bind(in.DeployRollbackCmd, in.Viper, &rb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.DeployRollbackCmd")
+	}
+
 	return nil
 }
 
 var DeployCmd = &cobra.Command{
-	Use:   "deploy",
-	Short: "deploy lambdas to aws",
-	Args:  cobra.MinimumNArgs(1),
+	Use:         "deploy [FEATURE]",
+	Short:       "deploy lambdas to aws",
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: map[string]string{MultiAccountAnnotation: "true"},
 }
 
 type DeployBind struct {
-	IsEnvOnly bool `conf:"cli:env-only, cli-u: Only update environment variables"`
+	IsEnvOnly    bool          `conf:"cli:env-only, cli-u: Only update environment variables"`
+	LogRetention int32         `conf:"default:30, cli:log-retention, cli-u: Retention in days for the feature's log group"`
+	LogKMSKey    string        `conf:"cli:log-kms-key, cli-u: KMS key arn used to encrypt the feature's log group"`
+	SkipLogGroup bool          `conf:"cli:skip-log-group, cli-u: Do not create or update the feature's log group"`
+	EnvKMSKey    string        `conf:"cli:env-kms-key, cli-u: KMS key arn the lambda uses for its environment variables"`
+	EncryptVars  []string      `conf:"cli:encrypt-vars, cli-u: Comma separated env var names to encrypt client side with --env-kms-key"`
+	Set          []string      `conf:"cli:set, cli-u: Comma separated KEY=VALUE env vars that override parameter store"`
+	Concurrency  int           `conf:"default:4, cli:concurrency, cli-u: How many features --all deploys at once"`
+	IsPublish    bool          `conf:"cli:publish, cli-u: Publish a version of the new code described by its git sha so it can be rolled back to"`
+	Compression  int           `conf:"cli:compression-level, cli-u: Deflate level of the zip from 1 (fastest) to 9 (smallest)"`
+	Canary       int           `conf:"cli:canary, cli-u: Percent of the alias traffic sent to the new version before it is promoted"`
+	Bake         time.Duration `conf:"default:5m, cli:bake, cli-u: How long the canary runs before it is promoted (ex 5m)"`
+	Alias        string        `conf:"default:live, cli:alias, cli-u: Alias whose traffic the canary shifts"`
+	MaxErrors    int           `conf:"default:0, cli:max-errors, cli-u: Errors of the canary version that trigger a rollback"`
+	RestAPI      string        `conf:"cli:rest-api, cli-u: Id or name of the rest api redeployed after its apigw features"`
+	APIStage     string        `conf:"cli:api-stage, cli-u: Stage of --rest-api the new deployment is made on"`
+	IsFlushCache bool          `conf:"cli:flush-cache, cli-u: Flush the cache of --api-stage after it is redeployed"`
+	IsViaS3      bool          `conf:"cli:via-s3, cli-u: Upload the zip to the lambda deploy bucket instead of sending it to lambda"`
+	DeployBucket string        `conf:"cli:deploy-bucket, cli-u: Bucket the zip is uploaded to (default <prefix>-lambda-deploy-bucket)"`
+	IsWatch      bool          `conf:"cli:watch, cli-u: Redeploy the code of the feature every time its code dir changes"`
+	Report       Filepath      `conf:"cli:report, cli-u: Write a json report of the build and update of every feature to this file (ex for ci)"`
+	Ref          string        `conf:"cli:ref, cli-u: Git tag or branch or commit to build and deploy instead of the working copy (ex v1.4.2)"`
+	RepoURL      string        `conf:"cli:repo-url, cli-u: Url or path of the repo --ref is cloned from (default the repo of the service)"`
+	IsCreate     bool          `conf:"cli:create, cli-u: Create the function when it does not exist yet"`
+	Role         string        `conf:"cli:role, cli-u: Name or arn of the execution role a function made by --create runs with"`
+	IsWithEnv    bool          `conf:"cli:with-env, cli-u: Also update the environment variables once the code is updated"`
+	UpdateWait   time.Duration `conf:"default:5m, cli:update-timeout, cli-u: How long to wait for lambda to apply the code update (ex 5m)"`
+	Tracing      string        `conf:"cli:tracing, cli-u: X-Ray tracing mode Active or PassThrough of the lambda"`
+	IsForce      bool          `conf:"cli:force, cli-u: Update the code even when the built zip is the one the function runs"`
+	Arch         string        `conf:"cli:arch, cli-u: GOARCH amd64 or arm64 the feature is built for and the function is switched to"`
+}
+
+// Validate refuses the flag combinations every deploy rejects, RunDeploy and
+// the InfraService deploys check them before anything is written
+func (b DeployBind) Validate() error {
+	if err := b.ValidateEnvOnly(); err != nil {
+		return failure.Wrap(err, "b.ValidateEnvOnly failed")
+	}
+
+	if b.IsCreate && b.Role == "" {
+		return failure.InvalidParam("--role is required by --create, a function can not be created without an execution role")
+	}
+
+	if mode := b.TracingMode(); mode != "" && mode != lambda.ActiveTracing && mode != lambda.PassThroughTracing {
+		return failure.InvalidParam("--tracing (%s) must be %s or %s", b.Tracing, lambda.ActiveTracing, lambda.PassThroughTracing)
+	}
+
+	return nil
+}
+
+// ValidateEnvOnly refuses the flags that only apply to a code deploy when
+// --env-only is given, they would be silently ignored otherwise
+func (b DeployBind) ValidateEnvOnly() error {
+	if !b.IsEnvOnly {
+		return nil
+	}
+
+	flags := map[string]bool{
+		"--canary":            b.Canary > 0,
+		"--watch":             b.IsWatch,
+		"--publish":           b.IsPublish,
+		"--via-s3":            b.IsViaS3,
+		"--compression-level": b.Compression != 0,
+		"--ref":               b.Ref != "",
+		"--create":            b.IsCreate,
+		"--arch":              b.Arch != "",
+		"--force":             b.IsForce,
+	}
+
+	var given []string
+	for flag, isGiven := range flags {
+		if isGiven {
+			given = append(given, flag)
+		}
+	}
+	sort.Strings(given)
+
+	if len(given) > 0 {
+		return failure.InvalidParam("--env-only does not deploy code, it can not be combined with %s", strings.Join(given, ", "))
+	}
+
+	return nil
+}
+
+// TracingMode is --tracing spelled the way lambda expects it, any case of
+// active or passthrough is accepted
+func (b DeployBind) TracingMode() string {
+	switch {
+	case strings.EqualFold(b.Tracing, lambda.ActiveTracing):
+		return lambda.ActiveTracing
+	case strings.EqualFold(b.Tracing, lambda.PassThroughTracing):
+		return lambda.PassThroughTracing
+	default:
+		return b.Tracing
+	}
+}
+
+// S3Bucket is --deploy-bucket or the lambda deploy bucket of the env
+func (b DeployBind) S3Bucket(service *sls.MicroService) string {
+	if b.DeployBucket != "" {
+		return b.DeployBucket
+	}
+	return sls.LambdaDeployBucketName(service.Name.Prefix)
 }
 
 type DeployConfig struct {
@@ -45,6 +168,22 @@ type DeployConfig struct {
 	CmdConfig
 }
 
+var DeployRollbackCmd = &cobra.Command{
+	Use:   "rollback <FEATURE>",
+	Short: "flip a lambda or its alias back to a previous published version",
+	Args:  cobra.ExactArgs(1),
+}
+
+type DeployRollbackBind struct {
+	Version string `conf:"cli:version, cli-u: Published version to roll back to (default the one before the current)"`
+	Alias   string `conf:"cli:alias, cli-u: Point this alias at the version instead of redeploying its code"`
+}
+
+type DeployRollbackConfig struct {
+	CmdConfig
+	DeployRollbackBind
+}
+
 func (i *Infra) RunDeploy(cmd *cobra.Command, args []string) error {
 	if err := i.Validate(); err != nil {
 		return failure.Wrap(err, "i.Validate failed")
@@ -56,69 +195,792 @@ func (i *Infra) RunDeploy(cmd *cobra.Command, args []string) error {
 		return failure.Wrap(err, "i.Configure failed")
 	}
 
+	if err := config.DeployBind.Validate(); err != nil {
+		return failure.Wrap(err, "config.DeployBind.Validate failed")
+	}
+
+	if err := i.Writable("deploy"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	if config.Ref != "" {
+		cleanup, err := i.CheckoutDeployRef(ctx, config)
+		if err != nil {
+			return failure.Wrap(err, "i.CheckoutDeployRef failed")
+		}
+		defer cleanup()
+	}
+
+	if config.CmdConfig.IsAll {
+		if config.IsWatch {
+			return failure.InvalidParam("--watch follows one feature, it can not be combined with --all")
+		}
+		if config.Canary > 0 {
+			return failure.InvalidParam("--canary deploys one feature, it can not be combined with --all")
+		}
+		if len(args) > 0 {
+			return failure.InvalidParam("--all deploys every feature, (%s) should not be given", args[0])
+		}
+
+		service, err := i.LoadService(config.CmdConfig)
+		if err != nil {
+			return failure.Wrap(err, "i.LoadService failed")
+		}
+
+		evidence := NewDeployReport(ctx, service, config)
+		err = i.ForEachAccount(config.CmdConfig, func(account string) error {
+			report := i.DeployAll(ctx, service, config)
+			evidence.AddAll(account, service, report)
+			if err := i.DisplayFormatted(report); err != nil {
+				return failure.Wrap(err, "i.DisplayFormatted failed")
+			}
+			if report.IsFailed() {
+				return failure.System("(%d) of (%d) features failed to deploy: %s", len(report.Failed), len(report.Features), strings.Join(report.Failed, ", "))
+			}
+
+			features := make([]sls.Feature, 0, len(service.Features))
+			for _, f := range service.Features {
+				features = append(features, f)
+			}
+			if err := i.DeployStage(ctx, config, features...); err != nil {
+				return failure.Wrap(err, "i.DeployStage failed")
+			}
+			return nil
+		})
+		return i.writeDeployReport(config, evidence, err)
+	}
+
+	if len(args) == 0 {
+		return failure.InvalidParam("a feature is required, or --all to deploy every feature")
+	}
+
+	if config.Canary > 0 {
+		service, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+		if err != nil {
+			return failure.Wrap(err, "i.LoadFeature failed")
+		}
+
+		// with --target-account the canary runs in every account in turn, a
+		// rolled back or failed canary stops the accounts after it
+		evidence := NewDeployReport(ctx, service, config)
+		err = i.ForEachAccount(config.CmdConfig, func(account string) error {
+			report, err := i.DeployCanary(ctx, service, feature, config)
+			evidence.AddCanary(account, feature, report)
+			if err != nil {
+				err = failure.Wrap(err, "i.DeployCanary failed")
+			} else if err = i.DeployStage(ctx, config, feature); err != nil {
+				err = failure.Wrap(err, "i.DeployStage failed")
+			}
+			if report != nil {
+				if dErr := i.DisplayFormatted(report); dErr != nil && err == nil {
+					err = failure.Wrap(dErr, "i.DisplayFormatted failed")
+				}
+			}
+			return err
+		})
+		return i.writeDeployReport(config, evidence, err)
+	}
+
 	service, feature, err := i.LoadFeature(config.CmdConfig, args[0])
 	if err != nil {
 		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
 	}
-	ctx := context.Background()
 
+	if config.IsWatch {
+		if !config.Report.IsEmpty() {
+			return failure.InvalidParam("--watch deploys until it is stopped, it can not be combined with --report")
+		}
+		return i.WatchFeature(ctx, service, feature, config)
+	}
+
+	// with --target-account the feature is deployed into every account, each
+	// one reading its own params
+	evidence := NewDeployReport(ctx, service, config)
+	err = i.ForEachAccount(config.CmdConfig, func(account string) error {
+		result, err := i.DeployFeature(ctx, service, feature, service.NewBuildSettings(feature), config)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		evidence.Add(account, feature, result)
+		if config.CmdConfig.Verbose {
+			for _, report := range []*lambda.FeatureUpdateReport{result.Code, result.Config} {
+				if report == nil {
+					continue
+				}
+				if dErr := i.DisplayFormatted(report); dErr != nil && err == nil {
+					err = failure.Wrap(dErr, "i.DisplayFormatted failed")
+				}
+			}
+		}
+		if err != nil {
+			return failure.Wrap(err, "i.DeployFeature failed")
+		}
+
+		if err = i.DeployStage(ctx, config, feature); err != nil {
+			return failure.Wrap(err, "i.DeployStage failed")
+		}
+
+		return nil
+	})
+	return i.writeDeployReport(config, evidence, err)
+}
+
+// DeployFeature deploys the code and log group of the feature, or only its
+// env vars with --env-only. The reports of the steps that ran are returned
+// even when a later step fails.
+func (i *Infra) DeployFeature(ctx context.Context, service *sls.MicroService, feature sls.Feature, settings sls.BuildSettings, config DeployConfig) (FeatureDeployResult, error) {
+	result := FeatureDeployResult{Feature: feature.Name}
+	var err error
 	if config.IsEnvOnly {
-		if err = i.DeployFeatureConfig(ctx, service.Name.AppTitle(), feature, config); err != nil {
-			return failure.Wrap(err, "i.DeployFeatureConfig failed")
+		result.Config, err = i.DeployFeatureConfig(ctx, service, feature, config)
+		if err != nil {
+			return result, failure.Wrap(err, "i.DeployFeatureConfig failed")
+		}
+		return result, nil
+	}
+
+	config.DeployBucket = config.S3Bucket(service)
+	result.Settings = &settings
+	result.Code, err = i.DeployFeatureCode(ctx, service, feature, settings, config)
+	if err != nil {
+		return result, failure.Wrap(err, "i.DeployFeatureCode failed")
+	}
+
+	if config.IsWithEnv {
+		result.Config, err = i.DeployFeatureConfig(ctx, service, feature, config)
+		if err != nil {
+			return result, failure.Wrap(err, "i.DeployFeatureConfig failed")
+		}
+	} else {
+		if err = i.TagFeature(ctx, service, feature, config); err != nil {
+			return result, failure.Wrap(err, "i.TagFeature failed")
+		}
+
+		if config.Tracing != "" {
+			if result.Config, err = i.DeployFeatureTracing(ctx, feature, config); err != nil {
+				return result, failure.Wrap(err, "i.DeployFeatureTracing failed")
+			}
 		}
+	}
+
+	if err = i.publishDeploy(ctx, service, feature, result, config); err != nil {
+		return result, failure.Wrap(err, "i.publishDeploy failed")
+	}
+
+	if err = i.DeployFeatureLogGroup(ctx, feature, config); err != nil {
+		return result, failure.Wrap(err, "i.DeployFeatureLogGroup failed")
+	}
+
+	return result, nil
+}
+
+// DeployStage creates a new deployment of --rest-api on --api-stage when one
+// of the features is triggered by api gateway, so the changes to the api
+// take effect. Without --rest-api, or with --env-only, nothing is deployed.
+func (i *Infra) DeployStage(ctx context.Context, config DeployConfig, features ...sls.Feature) error {
+	if config.RestAPI == "" || config.IsEnvOnly {
 		return nil
 	}
 
-	settings := service.NewBuildSettings(feature)
-	if err = i.DeployFeatureCode(ctx, feature, settings, config); err != nil {
-		return failure.Wrap(err, "i.DeployFeature failed")
+	var names []string
+	for _, f := range features {
+		if f.Trigger == sls.APIGWProxyTrigger || f.Trigger == sls.APIGWCustomAuthTrigger {
+			names = append(names, f.Name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	if i.GatewayAPI == nil {
+		return failure.System("i.GatewayAPI is not initialized, required by --rest-api")
+	}
+
+	if err := i.Writable("deploy api stage"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+	defer i.Step("api stage")()
+
+	sort.Strings(names)
+	in := restapi.StageSettings{
+		RestAPI:      config.RestAPI,
+		Stage:        config.APIStage,
+		Description:  fmt.Sprintf("deploy of %s", strings.Join(names, ", ")),
+		IsFlushCache: config.IsFlushCache,
+	}
+
+	if i.DryRun("CreateDeployment", in.RestAPI+"/"+in.Stage, in.Description) {
+		return nil
+	}
+
+	report, err := i.GatewayAPI.DeployStage(ctx, in)
+	if err != nil {
+		return failure.Wrap(err, "i.GatewayAPI.DeployStage failed (%s, %s)", in.RestAPI, in.Stage)
+	}
+
+	if config.CmdConfig.Verbose {
+		if err := i.DisplayFormatted(report); err != nil {
+			return failure.Wrap(err, "i.DisplayFormatted failed")
+		}
 	}
 
 	return nil
 }
 
-func (i *Infra) DeployFeatureConfig(ctx context.Context, appTitle string, feature sls.Feature, config DeployConfig) error {
-	vars, err := i.FeatureParams(ctx, appTitle, feature)
+// DeployFeatureLogGroup makes sure the feature's log group exists with the
+// configured retention and kms key. Lambda creates its log group lazily with
+// no expiration, so we take ownership of it during deploy.
+func (i *Infra) DeployFeatureLogGroup(ctx context.Context, feature sls.Feature, config DeployConfig) error {
+	if i.LogsAPI == nil || config.SkipLogGroup {
+		return nil
+	}
+	if err := i.Writable("ensure log group"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+	defer i.Step("log group")()
+
+	in := cwlogs.LogGroupSettings{
+		Name:          cwlogs.FeatureLogGroup(feature.QualifiedName),
+		RetentionDays: config.LogRetention,
+		KMSKeyARN:     config.LogKMSKey,
+	}
+
+	if i.DryRun("EnsureLogGroup", in.Name, fmt.Sprintf("retention of (%d) days", in.RetentionDays)) {
+		return nil
+	}
+
+	report, err := i.LogsAPI.EnsureLogGroup(ctx, in)
 	if err != nil {
-		return failure.Wrap(err, "i.FeatureParams failed")
+		return failure.Wrap(err, "i.LogsAPI.EnsureLogGroup failed (%s)", in.Name)
 	}
 
-	vars = i.StripAppTitle(appTitle, vars)
-	settings := lambda.FeatureSettings{
-		QualifiedName: feature.QualifiedName,
-		EnvVars:       vars,
+	if config.CmdConfig.Verbose {
+		if err := i.DisplayFormatted(report); err != nil {
+			return failure.Wrap(err, "i.DisplayFormatted failed")
+		}
+	}
+
+	return nil
+}
+
+func (i *Infra) DeployFeatureConfig(ctx context.Context, service *sls.MicroService, feature sls.Feature, config DeployConfig) (*lambda.FeatureUpdateReport, error) {
+	if err := i.Writable("update config"); err != nil {
+		return nil, failure.Wrap(err, "i.Writable failed")
+	}
+	defer i.Step("update config")()
+
+	settings, err := i.featureSettings(ctx, service, feature, config)
+	if err != nil {
+		return nil, failure.Wrap(err, "i.featureSettings failed")
+	}
+
+	if i.DryRun("UpdateFunctionConfiguration", feature.QualifiedName, fmt.Sprintf("(%d) env vars", len(settings.EnvVars))) {
+		return nil, nil
 	}
 
 	report, err := i.LambdaAPI.UpdateConfig(ctx, settings)
 	if err != nil {
-		return failure.Wrap(err, "i.LambdaAPI.UpdateConfig failed")
+		return nil, failure.Wrap(err, "i.LambdaAPI.UpdateConfig failed")
 	}
 
-	if config.CmdConfig.Verbose {
-		i.DisplayJson(report)
+	return report, nil
+}
+
+// DeployFeatureTracing only sets the x-ray tracing mode of --tracing, the
+// config update of DeployFeatureConfig sets it along with the env vars
+func (i *Infra) DeployFeatureTracing(ctx context.Context, feature sls.Feature, config DeployConfig) (*lambda.FeatureUpdateReport, error) {
+	if err := i.Writable("update config"); err != nil {
+		return nil, failure.Wrap(err, "i.Writable failed")
+	}
+	defer i.Step("update tracing")()
+
+	mode := config.TracingMode()
+	if i.DryRun("UpdateFunctionConfiguration", feature.QualifiedName, "tracing mode "+mode) {
+		return nil, nil
+	}
+
+	report, err := i.LambdaAPI.UpdateConfig(ctx, lambda.FeatureSettings{QualifiedName: feature.QualifiedName, TracingMode: &mode})
+	if err != nil {
+		return nil, failure.Wrap(err, "i.LambdaAPI.UpdateConfig failed")
+	}
+
+	return report, nil
+}
+
+// TagFeature tags the function of the feature with DeployTags, the config
+// update of DeployFeatureConfig tags it on its own. Lambda clients that can
+// not tag are not tagged.
+func (i *Infra) TagFeature(ctx context.Context, service *sls.MicroService, feature sls.Feature, config DeployConfig) error {
+	api, ok := i.LambdaAPI.(LambdaTagging)
+	if !ok {
+		return nil
+	}
+
+	if err := i.Writable("tag function"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+	defer i.Step("tag function")()
+
+	tags := DeployTags(ctx, service, feature, config)
+	if i.DryRun("TagResource", feature.QualifiedName, fmt.Sprintf("(%d) tags", len(tags))) {
+		return nil
+	}
+
+	if err := api.Tag(ctx, feature.QualifiedName, tags); err != nil {
+		return failure.Wrap(err, "api.Tag failed (%s)", feature.QualifiedName)
 	}
 
 	return nil
 }
 
-func (i *Infra) DeployFeatureCode(ctx context.Context, feature sls.Feature, settings sls.BuildSettings, config DeployConfig) error {
-	result, err := i.LambdaAPI.Compile(settings)
+// the tags DeployTags puts on every deployed function
+const (
+	ServiceTag = "sls:service"
+	EnvTag     = "sls:env"
+	FeatureTag = "sls:feature"
+	CommitTag  = "sls:commit"
+)
+
+// DeployTags are the tags of every deployed function, sls:commit is the git
+// sha of the service and is left out when it is not in a git repo
+func DeployTags(ctx context.Context, service *sls.MicroService, feature sls.Feature, config DeployConfig) map[string]string {
+	tags := map[string]string{
+		ServiceTag: service.Name.AppTitle(),
+		EnvTag:     config.EnvName(),
+		FeatureTag: feature.Name,
+	}
+
+	if sha, err := sls.GitHead(ctx, service.RootDir()); err == nil && sha != "" {
+		tags[CommitTag] = sha
+	}
+
+	return tags
+}
+
+// featureSettings resolves the env vars of the feature, adds the metadata
+// the runners read at startup and encrypts the --encrypt-vars
+func (i *Infra) featureSettings(ctx context.Context, service *sls.MicroService, feature sls.Feature, config DeployConfig) (lambda.FeatureSettings, error) {
+	appTitle := service.Name.AppTitle()
+	overrides, err := ParseOverrides(config.Set)
 	if err != nil {
-		return failure.Wrap(err, "i.LambdaAPI.Compile failed")
+		return lambda.FeatureSettings{}, failure.Wrap(err, "ParseOverrides failed")
+	}
+
+	resolution, err := i.NewEnvResolver(appTitle, config.CmdConfig, PStoreStage, OverridesStage).
+		WithOverrides(overrides).
+		Strict().
+		Resolve(ctx, feature)
+	if err != nil {
+		return lambda.FeatureSettings{}, failure.Wrap(err, "resolver.Resolve failed")
+	}
+	vars := resolution.Map()
+	if config.CmdConfig.Verbose {
+		if err = i.DisplayFormatted(resolution.Sources()); err != nil {
+			return lambda.FeatureSettings{}, failure.Wrap(err, "i.DisplayFormatted failed")
+		}
+	}
+
+	// the runners read their identity from these at startup, see slsctx.FromEnvironment
+	meta := slsctx.Metadata{
+		Service: appTitle,
+		Env:     config.EnvName(),
+		Feature: feature.Name,
+		Trigger: feature.Trigger,
+	}
+	for k, v := range meta.EnvVars() {
+		if _, ok := vars[k]; !ok {
+			vars[k] = v
+		}
+	}
+
+	settings := lambda.FeatureSettings{
+		QualifiedName: feature.QualifiedName,
+		EnvVars:       vars,
+		Tags:          DeployTags(ctx, service, feature, config),
+	}
+
+	if config.EnvKMSKey != "" {
+		settings.KMSKeyARN = &config.EnvKMSKey
+	}
+
+	if config.Tracing != "" {
+		mode := config.TracingMode()
+		settings.TracingMode = &mode
+	}
+
+	if len(config.EncryptVars) > 0 {
+		if i.KMSAPI == nil {
+			return lambda.FeatureSettings{}, failure.System("i.KMSAPI is not initialized, required by --encrypt-vars")
+		}
+
+		if config.EnvKMSKey == "" {
+			return lambda.FeatureSettings{}, failure.InvalidParam("--env-kms-key is required by --encrypt-vars")
+		}
+
+		settings.EnvVars, err = i.KMSAPI.EncryptEnv(ctx, config.EnvKMSKey, feature.QualifiedName, vars, config.EncryptVars...)
+		if err != nil {
+			return lambda.FeatureSettings{}, failure.Wrap(err, "i.KMSAPI.EncryptEnv failed")
+		}
+	}
+
+	return settings, nil
+}
+
+// DeployFeatureCode builds the feature and updates the code of its function.
+// With --create a function that does not exist yet is created instead, with
+// the env vars a deploy with --env-only would give it. A function that
+// already runs the built zip is not updated unless --force is given. With
+// --publish only clients that can not publish on their own publish here,
+// the others are published by publishDeploy once the config is applied.
+func (i *Infra) DeployFeatureCode(ctx context.Context, service *sls.MicroService, feature sls.Feature, settings sls.BuildSettings, config DeployConfig) (*lambda.FeatureUpdateReport, error) {
+	if err := i.Writable("update code"); err != nil {
+		return nil, failure.Wrap(err, "i.Writable failed")
+	}
+
+	if config.Compression != 0 {
+		settings.Packaging.CompressionLevel = config.Compression
+	}
+
+	if config.Arch != "" {
+		settings.Architecture = config.Arch
+	}
+	arch := lambda.GoArchArchitecture(settings.Architecture)
+	if settings.Architecture != "" && arch == "" {
+		return nil, failure.InvalidParam("--arch (%s) must be amd64 or arm64", settings.Architecture)
+	}
+
+	stop := i.Step("build")
+	result, err := i.compile(ctx, settings)
+	stop()
+	if err != nil {
+		return nil, failure.Wrap(err, "i.compile failed")
+	}
+
+	if result.Package != nil && result.Package.Format != sls.ZipFormat {
+		return nil, failure.InvalidParam("(%s) is packaged as (%s), push the image in (%s) with docker, deploy only ships zips", feature.Name, result.Package.Format, result.Package.Path)
+	}
+
+	// clients that can publish on their own publish in publishDeploy, once
+	// the config is applied, so the version is described with the git sha
+	_, publisher := i.LambdaAPI.(LambdaVersionPublishing)
+	if !config.IsForce {
+		report, err := i.unchangedCode(ctx, feature, result.ZipData, arch)
+		if err != nil {
+			return nil, failure.Wrap(err, "i.unchangedCode failed")
+		}
+
+		if report != nil {
+			_, _ = fmt.Fprintf(i.Stderr, "[infra] (%s) already runs code (%s), not updated, use --force to update it\n", feature.Name, report.CodeSHA256)
+			return report, nil
+		}
+	}
+
+	if i.DryRun("UpdateFunctionCode", feature.QualifiedName, fmt.Sprintf("zip of (%d) bytes", len(result.ZipData))) {
+		return nil, nil
 	}
 
 	in := lambda.CodePayload{
 		QualifiedName: feature.QualifiedName,
 		ZipFile:       result.ZipData,
+		Publish:       config.IsPublish && !publisher,
+		Architecture:  arch,
 	}
+
+	// zips over the direct upload limit can only be deployed from s3
+	if config.IsViaS3 || len(result.ZipData) > lambda.MaxDirectUploadSize {
+		if err = i.uploadCode(ctx, feature, config, &in); err != nil {
+			return nil, failure.Wrap(err, "i.uploadCode failed")
+		}
+	}
+
+	stop = i.Step("update code")
 	report, err := i.LambdaAPI.UpdateCode(ctx, in)
+	stop()
+	switch {
+	case err != nil && failure.IsNotFound(err) && config.IsCreate:
+		if report, err = i.createFeature(ctx, service, feature, in, config); err != nil {
+			return nil, failure.Wrap(err, "i.createFeature failed")
+		}
+	case err != nil:
+		return nil, failure.Wrap(err, "i.LambdaAPI.UpdateCode failed")
+	}
+
+	if err = i.waitForUpdate(ctx, feature, report, config); err != nil {
+		return report, failure.Wrap(err, "i.waitForUpdate failed")
+	}
+
+	return report, nil
+}
+
+// unchangedCode is the report of a skipped update when the function already
+// runs the zip, on arch when it is not empty, and nil when the code has to
+// be updated. Lambda clients that can not describe functions and functions
+// not deployed yet are always updated.
+func (i *Infra) unchangedCode(ctx context.Context, feature sls.Feature, zip []byte, arch string) (*lambda.FeatureUpdateReport, error) {
+	api, ok := i.LambdaAPI.(LambdaDescribing)
+	if !ok || len(zip) == 0 {
+		return nil, nil
+	}
+	defer i.Step("compare code")()
+
+	info, err := api.Function(ctx, feature.QualifiedName)
+	switch {
+	case err != nil && failure.IsNotFound(err):
+		return nil, nil
+	case err != nil:
+		return nil, failure.Wrap(err, "api.Function failed (%s)", feature.QualifiedName)
+	}
+
+	if info.CodeSHA256 != lambda.ZipSHA256(zip) || (arch != "" && info.Architecture != arch) {
+		return nil, nil
+	}
+
+	report := lambda.FeatureUpdateReport{
+		CodeSHA256:       info.CodeSHA256,
+		CodeSize:         info.CodeSize,
+		Description:      info.Description,
+		LambdaARN:        info.ARN,
+		LambdaName:       info.Name,
+		LastModified:     info.LastModified,
+		LastUpdateStatus: info.LastUpdateStatus,
+		PackageType:      info.PackageType,
+		RevisionID:       info.RevisionID,
+		Role:             info.Role,
+		State:            info.State,
+		Timeout:          info.Timeout,
+		Version:          info.Version,
+		IsUnchanged:      true,
+	}
+
+	return &report, nil
+}
+
+// publishDeploy publishes the version of a deploy with --publish once its
+// code, env vars and tracing mode are applied, so rolling back to the version
+// restores the config it was deployed with. Clients that can not publish on
+// their own already published with the code update.
+func (i *Infra) publishDeploy(ctx context.Context, service *sls.MicroService, feature sls.Feature, result FeatureDeployResult, config DeployConfig) error {
+	if _, ok := i.LambdaAPI.(LambdaVersionPublishing); !ok || !config.IsPublish || result.Code == nil || i.IsDryRun {
+		return nil
+	}
+
+	if err := i.waitForUpdate(ctx, feature, result.Config, config); err != nil {
+		return failure.Wrap(err, "i.waitForUpdate failed")
+	}
+
+	if err := i.publishFeature(ctx, service, feature, result.Code); err != nil {
+		return failure.Wrap(err, "i.publishFeature failed")
+	}
+
+	return nil
+}
+
+// publishFeature publishes the code of the report as a version described by
+// the git sha of the service and sets report.Version to it. Lambda refuses
+// to publish when $LATEST no longer holds that code.
+func (i *Infra) publishFeature(ctx context.Context, service *sls.MicroService, feature sls.Feature, report *lambda.FeatureUpdateReport) error {
+	api, ok := i.LambdaAPI.(LambdaVersionPublishing)
+	if !ok || report == nil {
+		return nil
+	}
+	defer i.Step("publish version")()
+
+	sha, err := sls.GitHead(ctx, service.RootDir())
 	if err != nil {
-		return failure.Wrap(err, "i.LambdaAPI.UpdateCode failed")
+		_, _ = fmt.Fprintf(i.Stderr, "[infra] (%s) is not in a git repo, the version of (%s) has no description: %v\n", service.RootDir(), feature.Name, err)
 	}
 
+	version, err := api.PublishVersion(ctx, feature.QualifiedName, sha, report.CodeSHA256)
+	if err != nil {
+		return failure.Wrap(err, "api.PublishVersion failed (%s)", feature.QualifiedName)
+	}
+	report.Version = version.Version
+
+	return nil
+}
+
+// waitForUpdate blocks until lambda applied the update of the report, the
+// next update, version publish or invoke of the function fails until then.
+// Lambda clients that can not wait are not waited on.
+func (i *Infra) waitForUpdate(ctx context.Context, feature sls.Feature, report *lambda.FeatureUpdateReport, config DeployConfig) error {
+	api, ok := i.LambdaAPI.(LambdaUpdateWaiting)
+	if !ok || report == nil {
+		return nil
+	}
+	defer i.Step("wait for update")()
+
+	if report.IsCreated {
+		if err := api.WaitUntilActive(ctx, feature.QualifiedName, config.UpdateWait); err != nil {
+			return failure.Wrap(err, "api.WaitUntilActive failed")
+		}
+		return nil
+	}
+
+	if err := api.WaitUntilUpdated(ctx, feature.QualifiedName, config.UpdateWait); err != nil {
+		return failure.Wrap(err, "api.WaitUntilUpdated failed")
+	}
+
+	return nil
+}
+
+// createFeature creates the function of the feature with the code of the
+// payload. A role given by name is in the account of the credentials.
+func (i *Infra) createFeature(ctx context.Context, service *sls.MicroService, feature sls.Feature, code lambda.CodePayload, config DeployConfig) (*lambda.FeatureUpdateReport, error) {
+	creator, ok := i.LambdaAPI.(LambdaCreation)
+	if !ok {
+		return nil, failure.System("i.LambdaAPI does not implement LambdaCreation, required by --create")
+	}
+
+	if err := i.Writable("create function"); err != nil {
+		return nil, failure.Wrap(err, "i.Writable failed")
+	}
+	defer i.Step("create function")()
+
+	settings, err := i.featureSettings(ctx, service, feature, config)
+	if err != nil {
+		return nil, failure.Wrap(err, "i.featureSettings failed")
+	}
+
+	role := config.Role
+	if !strings.HasPrefix(role, "arn:") {
+		identity, region, err := i.functionLocation(ctx, service, feature)
+		if err != nil {
+			return nil, failure.Wrap(err, "i.functionLocation failed")
+		}
+		role = sts.PartitionRoleARN(region.Partition(), identity.Account, role)
+	}
+
+	in := lambda.FeatureCreateInput{
+		QualifiedName:   feature.QualifiedName,
+		Role:            role,
+		ZipFile:         code.ZipFile,
+		S3Bucket:        code.S3Bucket,
+		S3Key:           code.S3Key,
+		S3ObjectVersion: code.S3ObjectVersion,
+		EnvVars:         settings.EnvVars,
+		Publish:         code.Publish,
+		Architecture:    code.Architecture,
+		TracingMode:     config.TracingMode(),
+		Tags:            settings.Tags,
+	}
+	if settings.KMSKeyARN != nil {
+		in.KMSKeyARN = *settings.KMSKeyARN
+	}
+
+	_, _ = fmt.Fprintf(i.Stderr, "[infra] (%s) is not deployed, creating it with role (%s)\n", feature.QualifiedName, role)
+	report, err := creator.Create(ctx, in)
+	if err != nil {
+		return nil, failure.Wrap(err, "creator.Create failed")
+	}
+
+	return report, nil
+}
+
+// uploadCode puts the zip of the payload in the deploy bucket, under a key
+// named after its sha256, and points the payload at the object instead
+func (i *Infra) uploadCode(ctx context.Context, feature sls.Feature, config DeployConfig, in *lambda.CodePayload) error {
+	if i.ArtifactsAPI == nil {
+		return failure.System("i.ArtifactsAPI is not initialized, required to deploy (%s) from s3", feature.Name)
+	}
+
+	if config.DeployBucket == "" {
+		return failure.InvalidParam("the deploy bucket is empty, use --deploy-bucket")
+	}
+
+	if err := i.Writable("upload code"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+	defer i.Step("upload code")()
+
+	upload := s3.UploadSettings{
+		Bucket: config.DeployBucket,
+		Key:    s3.ContentKey(feature.QualifiedName, in.ZipFile, ".zip"),
+		Body:   in.ZipFile,
+	}
+
+	if i.DryRun("PutObject", upload.Bucket+"/"+upload.Key, fmt.Sprintf("zip of (%d) bytes", len(upload.Body))) {
+		return nil
+	}
+
+	report, err := i.ArtifactsAPI.Upload(ctx, upload)
+	if err != nil {
+		return failure.Wrap(err, "i.ArtifactsAPI.Upload failed (%s)", upload.Bucket)
+	}
+
+	in.ZipFile = nil
+	in.S3Bucket = report.Bucket
+	in.S3Key = report.Key
+	in.S3ObjectVersion = report.VersionID
+
 	if config.CmdConfig.Verbose {
-		i.DisplayJson(report)
+		if err := i.DisplayFormatted(report); err != nil {
+			return failure.Wrap(err, "i.DisplayFormatted failed")
+		}
 	}
 
 	return nil
 }
+
+// RunDeployRollback runs `<service> infra deploy rollback <FEATURE>`. With
+// --alias the alias is pointed at the version, otherwise the code of the
+// version is deployed again, the env vars are left as they are. Versions only
+// exist for deploys made with --publish.
+// `<service> infra deploy rollback <FEATURE> [--version N] [--alias live]`
+func (i *Infra) RunDeployRollback(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	api, ok := i.LambdaAPI.(LambdaVersioning)
+	if !ok {
+		return failure.System("i.LambdaAPI is not initialized or does not implement LambdaVersioning")
+	}
+
+	var config DeployRollbackConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if err := i.Writable("deploy rollback"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	in := lambda.RollbackSettings{
+		QualifiedName: feature.QualifiedName,
+		Version:       config.Version,
+		Alias:         config.Alias,
+	}
+
+	version := in.Version
+	if version == "" {
+		version = "the one before the current"
+	}
+
+	call, target := "UpdateFunctionCode", in.QualifiedName
+	if in.Alias != "" {
+		call, target = "UpdateAlias", in.QualifiedName+":"+in.Alias
+	}
+	if i.DryRun(call, target, fmt.Sprintf("to version (%s)", version)) {
+		return nil
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	defer i.Step("rollback")()
+	report, err := api.Rollback(ctx, in)
+	if err != nil {
+		return failure.Wrap(err, "api.Rollback failed (%s)", feature.QualifiedName)
+	}
+
+	return i.DisplayFormatted(report)
+}
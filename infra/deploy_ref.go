@@ -0,0 +1,106 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+)
+
+// CheckoutDeployRef clones the repo of the service at --ref into a temp dir
+// and points i.ServiceConstructor at that tree, so the features are built
+// from the ref and not from the working copy. The service is rooted at the
+// same path in the clone as it is in the working copy. The returned func
+// restores the constructor and removes the clone.
+func (i *Infra) CheckoutDeployRef(ctx context.Context, config DeployConfig) (func(), error) {
+	if config.IsWatch {
+		return nil, failure.InvalidParam("--watch follows the working copy, it can not be combined with --ref")
+	}
+
+	service, err := i.LoadService(config.CmdConfig)
+	if err != nil {
+		return nil, failure.Wrap(err, "i.LoadService failed")
+	}
+
+	source := config.RepoURL
+	if source == "" {
+		if service.Repo.Owner == "" || service.Repo.Name == "" {
+			return nil, failure.Config("the service has no repo owner and name, --repo-url is required with --ref")
+		}
+		source = service.Repo.URI()
+	}
+
+	dir, err := os.MkdirTemp("", "sls-ref-")
+	if err != nil {
+		return nil, failure.ToSystem(err, "os.MkdirTemp failed")
+	}
+	remove := func() { _ = os.RemoveAll(dir) }
+
+	defer i.Step("checkout " + config.Ref)()
+	commit, err := sls.CheckoutRef(ctx, source, config.Ref, dir)
+	if err != nil {
+		remove()
+		return nil, failure.Wrap(err, "sls.CheckoutRef failed (%s)", source)
+	}
+	_, _ = fmt.Fprintf(i.Stderr, "[infra] deploying (%s) at commit (%s) from (%s)\n", config.Ref, commit, source)
+
+	root := filepath.Join(dir, repoRelDir(service.RootDir()))
+	constructor := i.ServiceConstructor
+	restore := func() {
+		i.ServiceConstructor = constructor
+		remove()
+	}
+
+	if i.Project != nil {
+		project, err := sls.LoadProjectConfig(filepath.Join(root, filepath.Base(i.Project.Path)))
+		if err != nil {
+			remove()
+			return nil, failure.Wrap(err, "sls.LoadProjectConfig failed, the project file is not in (%s)", config.Ref)
+		}
+
+		i.ServiceConstructor = func(c EnvIdentity) (*sls.MicroService, error) {
+			return i.projectService(project, c)
+		}
+		return restore, nil
+	}
+
+	i.ServiceConstructor = func(c EnvIdentity) (*sls.MicroService, error) {
+		s, err := constructor(c)
+		if err != nil {
+			return nil, err
+		}
+		s.Root = root
+		s.Resource = sls.NewTFResource(s.TerraformDir(), s.Name.Prefix, s.Repo.Name)
+		return s, nil
+	}
+
+	return restore, nil
+}
+
+// repoRelDir is the path of dir inside its git repo, the first parent with
+// a .git entry, or . when dir is not in a repo or is its root
+func repoRelDir(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "."
+	}
+
+	for cur := abs; ; {
+		if _, err := os.Stat(filepath.Join(cur, ".git")); err == nil {
+			rel, err := filepath.Rel(cur, abs)
+			if err != nil {
+				return "."
+			}
+			return rel
+		}
+
+		parent := filepath.Dir(cur)
+		if parent == cur {
+			return "."
+		}
+		cur = parent
+	}
+}
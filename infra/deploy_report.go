@@ -0,0 +1,193 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+)
+
+const (
+	DeploySucceeded = "succeeded"
+	DeployFailed    = "failed"
+)
+
+// DeployReport is what `deploy --report out.json` writes, the evidence of a
+// deploy a ci pipeline can archive. It is written when the deploy fails too,
+// with the features that were deployed before the failure.
+type DeployReport struct {
+	Service    string                  `json:"service"`
+	Env        string                  `json:"env"`
+	Commit     string                  `json:"commit,omitempty"`
+	Ref        string                  `json:"ref,omitempty"`
+	IsEnvOnly  bool                    `json:"is_env_only"`
+	IsDryRun   bool                    `json:"is_dry_run,omitempty"`
+	StartedAt  time.Time               `json:"started_at"`
+	FinishedAt time.Time               `json:"finished_at"`
+	Status     string                  `json:"status"`
+	Error      string                  `json:"error,omitempty"`
+	Features   []FeatureDeployEvidence `json:"features"`
+}
+
+// FeatureDeployEvidence is one feature of the DeployReport. CodeSHA256 is
+// the base64 sha256 lambda computed of the zip, Build is empty with
+// --env-only since nothing is built.
+type FeatureDeployEvidence struct {
+	Feature      string         `json:"feature"`
+	Function     string         `json:"function"`
+	Account      string         `json:"account,omitempty"`
+	Build        *BuildEvidence `json:"build,omitempty"`
+	CodeSHA256   string         `json:"code_sha256,omitempty"`
+	CodeSize     int64          `json:"code_size,omitempty"`
+	Version      string         `json:"version,omitempty"`
+	UpdateStatus string         `json:"update_status,omitempty"`
+	UpdateReason string         `json:"update_reason,omitempty"`
+	State        string         `json:"state,omitempty"`
+	IsUnchanged  bool           `json:"unchanged,omitempty"`
+	Canary       *CanaryReport  `json:"canary,omitempty"`
+	Error        string         `json:"error,omitempty"`
+}
+
+// BuildEvidence are the build settings the zip of a feature was made with
+type BuildEvidence struct {
+	CodeDir          string            `json:"code_dir"`
+	BinName          string            `json:"bin_name"`
+	ZipName          string            `json:"zip_name"`
+	Format           sls.PackageFormat `json:"format,omitempty"`
+	CompressionLevel int               `json:"compression_level"`
+	Assets           []string          `json:"assets,omitempty"`
+}
+
+func NewBuildEvidence(s sls.BuildSettings) *BuildEvidence {
+	return &BuildEvidence{
+		CodeDir:          s.CodeDir,
+		BinName:          s.BinName,
+		ZipName:          s.ZipName,
+		Format:           s.Packaging.Format,
+		CompressionLevel: s.Packaging.CompressionLevel,
+		Assets:           s.Packaging.Assets,
+	}
+}
+
+// NewDeployReport starts the report of a deploy of the service. With
+// --report the commit is the git sha of the service, when it is in a git repo,
+// which is the commit of --ref when one is deployed
+func NewDeployReport(ctx context.Context, service *sls.MicroService, config DeployConfig) *DeployReport {
+	report := &DeployReport{
+		Service:   service.Name.AppTitle(),
+		Env:       config.EnvName(),
+		IsEnvOnly: config.IsEnvOnly,
+		IsDryRun:  config.DryRun,
+		Ref:       config.Ref,
+		StartedAt: time.Now().UTC(),
+		Features:  []FeatureDeployEvidence{},
+	}
+
+	if !config.Report.IsEmpty() {
+		report.Commit, _ = sls.GitHead(ctx, service.RootDir())
+	}
+
+	return report
+}
+
+// Add records the result of deploying the feature into account, empty for
+// the account of the current credentials
+func (r *DeployReport) Add(account string, feature sls.Feature, result FeatureDeployResult) {
+	e := FeatureDeployEvidence{
+		Feature:  feature.Name,
+		Function: feature.QualifiedName,
+		Account:  account,
+		Error:    result.Error,
+	}
+
+	if result.Settings != nil {
+		e.Build = NewBuildEvidence(*result.Settings)
+	}
+
+	report := result.Code
+	if report == nil {
+		report = result.Config
+	}
+	if report != nil {
+		e.CodeSHA256 = report.CodeSHA256
+		e.CodeSize = report.CodeSize
+		e.Version = report.Version
+		e.UpdateStatus = report.LastUpdateStatus
+		e.UpdateReason = report.LastUpdateReason
+		e.State = report.State
+		e.IsUnchanged = report.IsUnchanged
+	}
+
+	r.Features = append(r.Features, e)
+}
+
+// AddCanary records a canary deploy, the version is the one the canary
+// published
+func (r *DeployReport) AddCanary(account string, feature sls.Feature, canary *CanaryReport) {
+	e := FeatureDeployEvidence{Feature: feature.Name, Function: feature.QualifiedName, Account: account, Canary: canary}
+	if canary != nil {
+		e.Version = canary.ToVersion
+	}
+
+	r.Features = append(r.Features, e)
+}
+
+// Finish sets the status of the deploy from err
+func (r *DeployReport) Finish(err error) {
+	r.FinishedAt = time.Now().UTC()
+	r.Status = DeploySucceeded
+	if err != nil {
+		r.Status = DeployFailed
+		r.Error = err.Error()
+	}
+}
+
+// WriteFile writes the report as indented json to path
+func (r *DeployReport) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return failure.ToSystem(err, "json.MarshalIndent failed")
+	}
+
+	if err = os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return failure.ToSystem(err, "os.WriteFile failed (%s)", path)
+	}
+
+	return nil
+}
+
+// AddAll records every feature of `deploy --all` into account
+func (r *DeployReport) AddAll(account string, service *sls.MicroService, all DeployAllReport) {
+	byName := make(map[string]sls.Feature, len(service.Features))
+	for _, f := range service.Features {
+		byName[f.Name] = f
+	}
+
+	for _, result := range all.Features {
+		r.Add(account, byName[result.Feature], result)
+	}
+}
+
+// writeDeployReport finishes the report with the outcome of the deploy and
+// writes it to --report. err is returned as it is, a failure to write the
+// report only fails a deploy that succeeded.
+func (i *Infra) writeDeployReport(config DeployConfig, report *DeployReport, err error) error {
+	if config.Report.IsEmpty() || report == nil {
+		return err
+	}
+
+	report.Finish(err)
+	if wErr := report.WriteFile(config.Report.Path); wErr != nil {
+		if err != nil {
+			_ = i.DisplayError(fmt.Sprintf("[infra] report.WriteFile failed: %v\n", wErr))
+			return err
+		}
+		return failure.Wrap(wErr, "report.WriteFile failed")
+	}
+
+	return err
+}
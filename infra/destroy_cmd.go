@@ -0,0 +1,277 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/cwlogs"
+	"github.com/spf13/cobra"
+)
+
+func SetupDestroyCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.DestroyCmd == nil {
+		in.DestroyCmd = DestroyCmd
+	}
+	in.DestroyCmd.RunE = in.RunDestroy
+	in.ParentCmd.AddCommand(in.DestroyCmd)
+
+	var db DestroyBind
+	if err := Bind(in.DestroyCmd, in.Viper, &db); err != nil {
+		return failure.Wrap(err, "Bind failed for in.DestroyCmd")
+	}
+
+	return nil
+}
+
+var DestroyCmd = &cobra.Command{
+	Use:   "destroy [FEATURE]",
+	Short: "delete the params, lambda function and log group of a feature or, with --all, the whole service",
+	Args:  cobra.MaximumNArgs(1),
+}
+
+type DestroyBind struct {
+	KeepParams   bool `conf:"cli:keep-params, cli-u: Do not delete the parameters"`
+	KeepFunction bool `conf:"cli:keep-function, cli-u: Do not delete the lambda function"`
+	KeepLogGroup bool `conf:"cli:keep-log-group, cli-u: Do not delete the log group"`
+}
+
+type DestroyConfig struct {
+	CmdConfig
+	DestroyBind
+}
+
+// DestroyReport is what was deleted for one feature. Shared are the params
+// kept because a feature that is not destroyed still reads them.
+type DestroyReport struct {
+	Feature  string              `json:"feature"`
+	Skipped  bool                `json:"skipped,omitempty"`
+	Function string              `json:"function,omitempty"`
+	LogGroup string              `json:"log_group,omitempty"`
+	Params   []string            `json:"params,omitempty"`
+	Shared   map[string][]string `json:"shared,omitempty"`
+	IsDryRun bool                `json:"is_dry_run,omitempty"`
+}
+
+// RunDestroy runs `<service> infra destroy <FEATURE>` which tears down a
+// feature in the env: the lambda function, its log group and its params.
+// Each feature is confirmed before anything is deleted unless --yes is given.
+// `<service> infra destroy [FEATURE] [--all] [--yes] [--keep-params] [--keep-function] [--keep-log-group]`
+func (i *Infra) RunDestroy(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	var config DestroyConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if !config.IsAll && len(args) == 0 {
+		return failure.InvalidParam("a feature or --all is required")
+	}
+
+	if config.IsAll && len(args) > 0 {
+		return failure.InvalidParam("a feature (%s) can not be given with --all", args[0])
+	}
+
+	if err := i.Writable("destroy"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	service, err := i.LoadService(config.CmdConfig)
+	if err != nil {
+		return failure.Wrap(err, "i.LoadService failed")
+	}
+
+	var features []sls.Feature
+	if config.IsAll {
+		names := make([]string, 0, len(service.Features))
+		for name := range service.Features {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			features = append(features, service.Features[name])
+		}
+	} else {
+		_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+		if err != nil {
+			return failure.Wrap(err, "i.LoadFeature failed")
+		}
+		features = append(features, feature)
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+	var reports []DestroyReport
+	for _, feature := range features {
+		report, err := i.DestroyFeature(ctx, service, feature, features, config)
+		reports = append(reports, report)
+		if err != nil {
+			// the reports so far show what was destroyed before the failure
+			_ = i.DisplayFormatted(reports)
+			return failure.Wrap(err, "i.DestroyFeature failed (%s)", feature.Name)
+		}
+	}
+
+	return i.DisplayFormatted(reports)
+}
+
+// DestroyFeature deletes the function first so nothing runs without its
+// params, then the log group and last the params. Params shared with a
+// feature outside of destroyed are kept. Resources that are already gone
+// are not an error.
+func (i *Infra) DestroyFeature(ctx context.Context, service *sls.MicroService, feature sls.Feature, destroyed []sls.Feature, config DestroyConfig) (DestroyReport, error) {
+	report := DestroyReport{Feature: feature.Name, IsDryRun: i.IsDryRun}
+	if err := i.Writable("destroy"); err != nil {
+		return report, failure.Wrap(err, "i.Writable failed")
+	}
+
+	appTitle := service.Name.AppTitle()
+	var keys []string
+	if !config.KeepParams {
+		var err error
+		keys, report.Shared, err = i.destroyableParams(ctx, service, feature, destroyed, config.CmdConfig)
+		if err != nil {
+			return report, failure.Wrap(err, "i.destroyableParams failed")
+		}
+	}
+
+	group := cwlogs.FeatureLogGroup(feature.QualifiedName)
+	if !config.IsYes {
+		var parts []string
+		if !config.KeepFunction {
+			parts = append(parts, fmt.Sprintf("function %s", feature.QualifiedName))
+		}
+		if !config.KeepLogGroup {
+			parts = append(parts, fmt.Sprintf("log group %s", group))
+		}
+		if !config.KeepParams {
+			parts = append(parts, fmt.Sprintf("%d params", len(keys)))
+		}
+
+		prompt := fmt.Sprintf("destroy (%s) in env (%s): %s?", feature.Name, config.Env, strings.Join(parts, ", "))
+		ok, err := i.Confirm(config.CmdConfig, prompt)
+		if err != nil {
+			return report, failure.Wrap(err, "i.Confirm failed")
+		}
+		if !ok {
+			report.Skipped = true
+			return report, nil
+		}
+	}
+
+	if !config.KeepFunction {
+		api, ok := i.LambdaAPI.(LambdaDeletion)
+		if !ok {
+			return report, failure.System("i.LambdaAPI is not initialized or does not implement LambdaDeletion")
+		}
+
+		var err error
+		if !i.DryRun("DeleteFunction", feature.QualifiedName, "") {
+			err = api.Delete(ctx, feature.QualifiedName)
+		}
+		if err != nil && !failure.IsNotFound(err) {
+			return report, failure.Wrap(err, "api.Delete failed (%s)", feature.QualifiedName)
+		}
+		if err == nil {
+			report.Function = feature.QualifiedName
+		}
+	}
+
+	if !config.KeepLogGroup {
+		api, ok := i.LogsAPI.(LogGroupDeletion)
+		if !ok {
+			return report, failure.System("i.LogsAPI is not initialized or does not implement LogGroupDeletion")
+		}
+
+		var err error
+		if !i.DryRun("DeleteLogGroup", group, "") {
+			err = api.DeleteLogGroup(ctx, group)
+		}
+		if err != nil && !failure.IsNotFound(err) {
+			return report, failure.Wrap(err, "api.DeleteLogGroup failed (%s)", group)
+		}
+		if err == nil {
+			report.LogGroup = group
+		}
+	}
+
+	for _, key := range keys {
+		if i.DryRun("DeleteParameter", key, "") {
+			report.Params = append(report.Params, key)
+			continue
+		}
+
+		if _, err := i.PStoreAPI.Delete(ctx, key); err != nil {
+			if failure.IsNotFound(err) {
+				continue
+			}
+			return report, failure.Wrap(err, "i.PStoreAPI.Delete failed (%s, %s)", appTitle, key)
+		}
+		report.Params = append(report.Params, key)
+	}
+
+	return report, nil
+}
+
+// destroyableParams are the stored param keys of the feature that no
+// feature outside of destroyed depends on, the others are returned as shared
+func (i *Infra) destroyableParams(ctx context.Context, service *sls.MicroService, feature sls.Feature, destroyed []sls.Feature, c CmdConfig) ([]string, map[string][]string, error) {
+	if i.PStoreAPI == nil {
+		return nil, nil, failure.System("i.PStoreAPI is not initialized")
+	}
+
+	graph, err := sls.NewDependencyGraph(service)
+	if err != nil {
+		return nil, nil, failure.Wrap(err, "sls.NewDependencyGraph failed")
+	}
+
+	isDestroyed := map[string]bool{}
+	for _, f := range destroyed {
+		isDestroyed[f.Name] = true
+	}
+
+	appTitle := service.Name.AppTitle()
+	resolution, err := NewEnvResolver(appTitle, i.PStoreAPI, PStoreStage).WithDefaults(ExcludeDefaults).Resolve(ctx, feature)
+	if err != nil && !failure.IsConfig(err) {
+		return nil, nil, failure.Wrap(err, "resolver.Resolve failed (%s, %s)", appTitle, feature.Name)
+	}
+
+	sharedWith := graph.SharedWith(feature.Name)
+	shared := map[string][]string{}
+	var keys []string
+	for _, name := range resolution.Names() {
+		v := resolution.Vars[name]
+		if !v.IsSet || v.Key == "" {
+			continue
+		}
+
+		if feature.IsFeatureScoped() {
+			name = fmt.Sprintf("%s/%s", feature.Name, name)
+		}
+
+		var others []string
+		for _, other := range sharedWith[sls.Param(name).ID()] {
+			if !isDestroyed[other] {
+				others = append(others, other)
+			}
+		}
+
+		if len(others) > 0 {
+			shared[v.Key] = others
+			continue
+		}
+		keys = append(keys, v.Key)
+	}
+
+	return keys, shared, nil
+}
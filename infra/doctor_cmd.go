@@ -0,0 +1,244 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/sts"
+	"github.com/spf13/cobra"
+)
+
+type DoctorStatus string
+
+const (
+	DoctorPass DoctorStatus = "pass"
+	DoctorFail DoctorStatus = "fail"
+	DoctorSkip DoctorStatus = "skip"
+)
+
+func SetupDoctorCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.DoctorCmd == nil {
+		in.DoctorCmd = DoctorCmd
+	}
+	in.DoctorCmd.RunE = in.RunDoctor
+	in.ParentCmd.AddCommand(in.DoctorCmd)
+
+	var db DoctorBind
+	if err := Bind(in.DoctorCmd, in.Viper, &db); err != nil {
+		return failure.Wrap(err, "Bind failed for in.DoctorCmd")
+	}
+
+	return nil
+}
+
+var DoctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "check the go toolchain and build dir and aws credentials and terraform the cli relies on",
+	Args:  cobra.NoArgs,
+}
+
+type DoctorBind struct {
+	ExpectedAccount string `conf:"cli:expected-account, cli-u: Account id the aws credentials must belong to"`
+}
+
+type DoctorConfig struct {
+	CmdConfig
+	DoctorBind
+}
+
+// DoctorCheck is the outcome of one check of `infra doctor`, Detail says
+// what was found or why it failed
+type DoctorCheck struct {
+	Name   string       `json:"name"`
+	Status DoctorStatus `json:"status"`
+	Detail string       `json:"detail"`
+}
+
+type DoctorReport []DoctorCheck
+
+func (r DoctorReport) TableRows() ([]string, [][]string) {
+	header := []string{"CHECK", "STATUS", "DETAIL"}
+	rows := make([][]string, 0, len(r))
+	for _, c := range r {
+		rows = append(rows, []string{c.Name, string(c.Status), c.Detail})
+	}
+
+	return header, rows
+}
+
+func (r DoctorReport) Failed() []string {
+	var names []string
+	for _, c := range r {
+		if c.Status == DoctorFail {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// RunDoctor runs `<service> infra doctor` which checks everything the other
+// commands need before they can run and reports a pass or fail for each.
+// A failed check does not stop the others.
+// `<service> infra doctor [--expected-account 123456789012]`
+func (i *Infra) RunDoctor(cmd *cobra.Command, _ []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	var config DoctorConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	report := i.Doctor(ctx, config)
+	if err := i.DisplayFormatted(report); err != nil {
+		return failure.Wrap(err, "i.DisplayFormatted failed")
+	}
+
+	if failed := report.Failed(); len(failed) > 0 {
+		return failure.InvalidState("(%d) checks failed: %s", len(failed), strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// Doctor runs every check, checks that depend on a failed one are skipped
+func (i *Infra) Doctor(ctx context.Context, config DoctorConfig) DoctorReport {
+	var report DoctorReport
+	add := func(name string, status DoctorStatus, format string, a ...interface{}) {
+		report = append(report, DoctorCheck{Name: name, Status: status, Detail: fmt.Sprintf(format, a...)})
+	}
+
+	if out, err := commandOutput(ctx, sls.GoBinaryName, "version"); err != nil {
+		add("go toolchain", DoctorFail, "%v", err)
+	} else {
+		add("go toolchain", DoctorPass, "%s", out)
+	}
+
+	service, err := i.LoadService(config.CmdConfig)
+	if err != nil {
+		add("service", DoctorFail, "%v", err)
+		add("build dir", DoctorSkip, "the service did not load")
+	} else {
+		add("service", DoctorPass, "%s in env (%s)", service.Name.AppTitle(), config.EnvName())
+		if err = checkWritable(service.BuildDir()); err != nil {
+			add("build dir", DoctorFail, "%v", err)
+		} else {
+			add("build dir", DoctorPass, "(%s) is writable", service.BuildDir())
+		}
+	}
+
+	region := ""
+	if i.AWSConfig != nil {
+		region = i.AWSConfig.Region
+	}
+	if region == "" && service != nil {
+		region = service.Account.Region.String()
+	}
+	if _, err = sls.ToRegion(region); err != nil {
+		add("region", DoctorFail, "region (%s) is not valid: %v", region, err)
+	} else {
+		add("region", DoctorPass, "%s", region)
+	}
+
+	identity, err := i.callerIdentity(ctx)
+	switch {
+	case err != nil:
+		add("aws credentials", DoctorFail, "%v", err)
+	case service != nil && service.Account.Profile != "":
+		add("aws credentials", DoctorPass, "%s (profile %s)", identity.ARN, service.Account.Profile)
+	default:
+		add("aws credentials", DoctorPass, "%s", identity.ARN)
+	}
+
+	switch {
+	case config.ExpectedAccount == "":
+		add("account", DoctorSkip, "no --expected-account given")
+	case err != nil:
+		add("account", DoctorSkip, "the aws credentials failed")
+	case identity.Account != config.ExpectedAccount:
+		add("account", DoctorFail, "credentials belong to (%s), expected (%s)", identity.Account, config.ExpectedAccount)
+	default:
+		add("account", DoctorPass, "%s", identity.Account)
+	}
+
+	if path, err := i.terraformBinary(TFBind{Binary: sls.TerraformName}); err != nil {
+		add("terraform", DoctorFail, "%v", err)
+	} else if out, err := commandOutput(ctx, path, "version"); err != nil {
+		add("terraform", DoctorFail, "%v", err)
+	} else {
+		add("terraform", DoctorPass, "%s", firstLine(out))
+	}
+
+	return report
+}
+
+func (i *Infra) callerIdentity(ctx context.Context) (sts.Identity, error) {
+	if i.AWSConfig == nil {
+		return sts.Identity{}, failure.System("i.AWSConfig is not initialized, no aws credentials to check")
+	}
+
+	identity, err := sts.NewClientWithConfig(*i.AWSConfig).CallerIdentity(ctx)
+	if err != nil {
+		return identity, failure.Wrap(err, "CallerIdentity failed")
+	}
+
+	return identity, nil
+}
+
+// checkWritable creates, and removes, a file in dir. A missing dir is
+// created since the build makes it as well.
+func checkWritable(dir string) error {
+	if dir == "" {
+		return failure.Config("the build dir of the service is empty")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return failure.ToSystem(err, "os.MkdirAll failed (%s)", dir)
+	}
+
+	f, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		return failure.ToSystem(err, "(%s) is not writable", dir)
+	}
+	_ = f.Close()
+
+	if err = os.Remove(f.Name()); err != nil {
+		return failure.ToSystem(err, "os.Remove failed (%s)", f.Name())
+	}
+
+	return nil
+}
+
+func commandOutput(ctx context.Context, name string, args ...string) (string, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", failure.ToNotFound(err, "(%s) is not in the PATH", name)
+	}
+
+	out, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	if err != nil {
+		return "", failure.ToSystem(err, "%s %s failed: %s", name, strings.Join(args, " "), strings.TrimSpace(string(out)))
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+func firstLine(s string) string {
+	if idx := strings.IndexByte(s, '\n'); idx >= 0 {
+		return s[:idx]
+	}
+	return s
+}
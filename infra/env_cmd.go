@@ -1,11 +1,15 @@
 package infra
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/rsb/failure"
 	"github.com/rsb/sls"
+	"github.com/rsb/sls/pstore"
 	"github.com/spf13/cobra"
 )
 
@@ -30,6 +34,12 @@ func SetupEnvCmd(in *Infra) error {
 	in.EnvExportCmd.RunE = in.RunEnvExport
 	in.EnvCmd.AddCommand(EnvExportCmd)
 
+	if in.EnvDiffCmd == nil {
+		in.EnvDiffCmd = EnvDiffCmd
+	}
+	in.EnvDiffCmd.RunE = in.RunEnvDiff
+	in.EnvCmd.AddCommand(in.EnvDiffCmd)
+
 	var eb EnvBind
 	if err := Bind(in.EnvCmd, in.Viper, &eb); err != nil {
 		return failure.Wrap(err, "Bind failed for (in.EnvBind)")
@@ -40,23 +50,38 @@ func SetupEnvCmd(in *Infra) error {
 		return failure.Wrap(err, "Bind failed for (in.EnvExportBind)")
 	}
 
+	var db EnvDiffBind
+	if err := Bind(in.EnvDiffCmd, in.Viper, &db); err != nil {
+		return failure.Wrap(err, "Bind failed for (in.EnvDiffBind)")
+	}
+
 	return nil
 }
 
 var EnvCmd = &cobra.Command{
-	Use:   "env [FEATURE]",
-	Short: "display environment variables for a lambda",
-	Args:  cobra.MinimumNArgs(0),
+	Use:         "env [FEATURE]",
+	Short:       "display environment variables for a lambda",
+	Args:        cobra.MinimumNArgs(0),
+	Annotations: map[string]string{MultiEnvAnnotation: "true"},
 }
 
 var EnvExportCmd = &cobra.Command{
-	Use:   "export",
-	Short: "exports all env vars for this service",
-	Args:  cobra.MinimumNArgs(0),
+	Use:         "export",
+	Short:       "exports all env vars for this service",
+	Args:        cobra.MinimumNArgs(0),
+	Annotations: map[string]string{MultiEnvAnnotation: "true"},
+}
+
+var EnvDiffCmd = &cobra.Command{
+	Use:         "diff <FEATURE>",
+	Short:       "compare the local env vars of a lambda with the ones it is deployed with",
+	Args:        cobra.ExactArgs(1),
+	Annotations: map[string]string{MultiEnvAnnotation: "true"},
 }
 
 type EnvBind struct {
 	NamesOnly bool `conf:"cli:names-only, cli-u: Only display the env var names for a given feature"`
+	IsSources bool `conf:"cli:sources, cli-u: Display where each value comes from (default env pstore or override)"`
 }
 
 type EnvConfig struct {
@@ -92,51 +117,31 @@ func (i *Infra) RunEnvExport(cmd *cobra.Command, args []string) error {
 		return failure.Wrap(err, "i.Configure failed")
 	}
 
-	if config.IsAll {
-		service, err := i.LoadService(config.CmdConfig)
-		if err != nil {
-			return failure.Wrap(err, "[env, report-all] i.LoadService failed")
-		}
-
-		result, invalid, err := i.ServiceEnvReport(service, config.CmdConfig, config.NamesOnly)
-		if err != nil {
-			return failure.Wrap(err, "i.ServiceEnvReport failed")
-		}
+	if !config.IsAll && len(args) == 0 {
+		return failure.InvalidParam("feature or --all flag is required")
+	}
 
-		if len(invalid) > 0 {
-			i.DisplayErrorJson(invalid)
+	result, err := i.PerEnv(config.CmdConfig, func(c CmdConfig) (interface{}, error) {
+		if config.IsAll {
+			return i.serviceEnvReport(c, config.NamesOnly)
 		}
 
-		if !config.File.IsEmpty() {
-			i.WriteJson(config.File.Path, result)
-			return nil
+		_, feature, err := i.LoadFeature(c, args[0])
+		if err != nil {
+			return nil, failure.Wrap(err, "i.LoadFeatureFromFirstArg")
 		}
 
-		i.DisplayJson(result)
-		return nil
-	}
-
-	if len(args) == 0 {
-		return failure.InvalidParam("feature or --all flag is required")
-	}
-
-	_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
-	if err != nil {
-		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
-	}
-
-	result, err := i.FeatureEnvReport(feature.Conf, config.CmdConfig, config.NamesOnly)
+		return i.FeatureEnvReport(feature.Conf, c, config.NamesOnly)
+	})
 	if err != nil {
 		return failure.Wrap(err, "i.FeatureEnvReport failed")
 	}
 
 	if !config.File.IsEmpty() {
-		i.WriteJson(config.File.Path, result)
-		return nil
+		return i.WriteJson(config.File.Path, result)
 	}
 
-	i.DisplayJson(result)
-	return nil
+	return i.DisplayFormatted(result)
 }
 
 func (i *Infra) RunEnv(cmd *cobra.Command, args []string) error {
@@ -149,41 +154,76 @@ func (i *Infra) RunEnv(cmd *cobra.Command, args []string) error {
 		return failure.Wrap(err, "i.Configure failed")
 	}
 
-	if config.CmdConfig.IsAll {
-		service, err := i.LoadService(config.CmdConfig)
-		if err != nil {
-			return failure.Wrap(err, "[env, report-all] i.LoadService failed")
+	if !config.CmdConfig.IsAll && len(args) == 0 {
+		return failure.InvalidParam("feature or --all flag is required")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	result, err := i.PerEnv(config.CmdConfig, func(c CmdConfig) (interface{}, error) {
+		if c.IsAll {
+			return i.serviceEnvReport(c, config.NamesOnly)
 		}
 
-		result, invalid, err := i.ServiceEnvReport(service, config.CmdConfig, config.NamesOnly)
+		service, feature, err := i.LoadFeature(c, args[0])
 		if err != nil {
-			return failure.Wrap(err, "i.ServiceEnvReport failed")
+			return nil, failure.Wrap(err, "i.LoadFeatureFromFirstArg")
 		}
 
-		if len(invalid) > 0 {
-			i.DisplayErrorJson(invalid)
+		if config.IsSources {
+			resolution, err := i.NewEnvResolver(service.Name.AppTitle(), c, DefaultEnvStages...).
+				Resolve(ctx, feature)
+			if err != nil {
+				return nil, failure.Wrap(err, "resolver.Resolve failed")
+			}
+			return resolution, nil
 		}
 
-		i.DisplayJson(result)
-		return nil
+		return i.FeatureEnvReport(feature.Conf, c, config.NamesOnly)
+	})
+	if err != nil {
+		return failure.Wrap(err, "i.FeatureEnvReport failed")
 	}
 
-	if len(args) == 0 {
-		return failure.InvalidParam("feature or --all flag is required")
-	}
+	return i.DisplayFormatted(result)
+}
 
-	_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+// serviceEnvReport is the env report of every feature of the service in the
+// env of c, the features that disagree on a value are printed to stderr
+func (i *Infra) serviceEnvReport(c CmdConfig, isNamesOnly bool) (map[string]string, error) {
+	service, err := i.LoadService(c)
 	if err != nil {
-		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+		return nil, failure.Wrap(err, "[env, report-all] i.LoadService failed")
 	}
 
-	result, err := i.FeatureEnvReport(feature.Conf, config.CmdConfig, config.NamesOnly)
+	result, invalid, err := i.ServiceEnvReport(service, c, isNamesOnly)
 	if err != nil {
-		return failure.Wrap(err, "i.FeatureEnvReport failed")
+		return nil, failure.Wrap(err, "i.ServiceEnvReport failed")
 	}
 
-	i.DisplayJson(result)
-	return nil
+	if len(invalid) > 0 {
+		if err := i.DisplayErrorJson(invalid); err != nil {
+			return nil, failure.Wrap(err, "i.DisplayErrorJson failed")
+		}
+	}
+
+	return result, nil
+}
+
+// NewEnvResolver creates the resolver used by the commands, the pstore stage
+// is dropped when there is no param store. Defaults follow c.Defaults(), the
+// defaults stage is added first unless --skip-defaults is set.
+func (i *Infra) NewEnvResolver(appTitle string, c CmdConfig, stages ...EnvStage) *EnvResolver {
+	var keep []EnvStage
+	for _, stage := range stages {
+		if stage == PStoreStage && i.PStoreAPI == nil {
+			continue
+		}
+		keep = append(keep, stage)
+	}
+
+	return NewEnvResolver(appTitle, i.PStoreAPI, keep...).WithDefaults(c.Defaults())
 }
 
 func (i *Infra) ServiceEnvReport(service *sls.MicroService, c CmdConfig, isNamesOnly ...bool) (map[string]string, map[string]map[string]string, error) {
@@ -215,10 +255,11 @@ func (i *Infra) ServiceEnvReport(service *sls.MicroService, c CmdConfig, isNames
 	return result, invalid, nil
 }
 
+// FeatureEnvReport resolves the env vars of a configuration from its
+// defaults and the local environment
 func (i *Infra) FeatureEnvReport(config sls.Configurable, c CmdConfig, isNamesOnly ...bool) (map[string]string, error) {
 	var result = map[string]string{}
 
-	config.SetExcludeDefaults(c.SkipDefaults)
 	if len(isNamesOnly) > 0 && isNamesOnly[0] {
 		out, err := config.EnvNames()
 		if err != nil {
@@ -231,12 +272,14 @@ func (i *Infra) FeatureEnvReport(config sls.Configurable, c CmdConfig, isNamesOn
 		return result, nil
 	}
 
-	result, err := config.EnvToMap()
+	// the app title is only used for param keys, which the local stages ignore
+	resolution, err := i.NewEnvResolver("", c, LocalEnvStage).
+		Resolve(context.Background(), sls.Feature{Conf: config})
 	if err != nil {
-		return nil, failure.Wrap(err, "config.EnvToMap failed")
+		return nil, failure.Wrap(err, "resolver.Resolve failed")
 	}
 
-	return result, nil
+	return resolution.Map(), nil
 }
 
 func EnvMap(feature sls.Feature, config EnvConfig) (*EnvResult, error) {
@@ -279,3 +322,153 @@ func EnvMap(feature sls.Feature, config EnvConfig) (*EnvResult, error) {
 
 	return &result, nil
 }
+
+type EnvDiffBind struct {
+	IsFailOnDrift bool `conf:"cli:fail-on-drift, cli-u: Fail when the deployed env differs (ex in ci before a deploy)"`
+}
+
+type EnvDiffConfig struct {
+	CmdConfig
+	EnvDiffBind
+}
+
+// EnvDiffReport compares the local env of a feature with the deployed one.
+// Missing vars are only set locally, a deploy adds them. Extra vars are only
+// set on the function, a deploy removes them.
+type EnvDiffReport struct {
+	Feature   string                   `json:"feature"`
+	Function  string                   `json:"function"`
+	Missing   map[string]string        `json:"missing"`
+	Extra     map[string]string        `json:"extra"`
+	Changed   map[string]pstore.Change `json:"changed"`
+	IsDrifted bool                     `json:"is_drifted"`
+}
+
+// TableRows lists one row per key that differs, sorted by key
+func (r EnvDiffReport) TableRows() ([]string, [][]string) {
+	var rows [][]string
+	for k, v := range r.Missing {
+		rows = append(rows, []string{k, "missing", v, ""})
+	}
+	for k, v := range r.Extra {
+		rows = append(rows, []string{k, "extra", "", v})
+	}
+	for k, c := range r.Changed {
+		rows = append(rows, []string{k, "changed", c.Left, c.Right})
+	}
+	sort.Slice(rows, func(a, b int) bool {
+		return rows[a][0] < rows[b][0]
+	})
+
+	return []string{"KEY", "STATUS", "LOCAL", "DEPLOYED"}, rows
+}
+
+// RunEnvDiff runs `<service> infra env diff <FEATURE>` which compares the env
+// vars computed by FeatureEnvReport with the ones set on the deployed lambda.
+// Vars encrypted on deploy always show as changed. With --text each key is
+// printed with `+` (missing), `-` (extra) or `~` (changed). With several
+// envs in --env each env is compared and the reports are keyed by env.
+// `<service> infra env diff <FEATURE> [--fail-on-drift] [--text] [--env qa,staging]`
+func (i *Infra) RunEnvDiff(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	api, ok := i.LambdaAPI.(LambdaInspection)
+	if !ok {
+		return failure.System("i.LambdaAPI is not initialized or does not implement LambdaInspection")
+	}
+
+	var config EnvDiffConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	var drifted []string
+	reports := EnvResults{}
+	err := i.ForEachEnv(config.CmdConfig, func(c CmdConfig) error {
+		report, err := i.EnvDiff(ctx, api, c, args[0])
+		if err != nil {
+			return failure.Wrap(err, "i.EnvDiff failed")
+		}
+
+		if report.IsDrifted {
+			drifted = append(drifted, fmt.Sprintf("(%s) is deployed with a different env in (%s), (%d) missing (%d) extra (%d) changed", report.Feature, c.Env, len(report.Missing), len(report.Extra), len(report.Changed)))
+		}
+
+		if config.IsText {
+			prefix := ""
+			if len(config.EnvNames()) > 1 {
+				prefix = c.Env + " "
+			}
+
+			_, rows := report.TableRows()
+			marks := map[string]string{"missing": "+", "extra": "-", "changed": "~"}
+			for _, row := range rows {
+				if err := i.Display(fmt.Sprintf("%s%s %s\n", prefix, marks[row[1]], row[0])); err != nil {
+					return failure.Wrap(err, "i.Display failed")
+				}
+			}
+		}
+
+		reports[c.Env] = report
+		return nil
+	})
+	if err != nil {
+		return failure.Wrap(err, "i.ForEachEnv failed")
+	}
+
+	if !config.IsText {
+		var out interface{} = reports
+		if envs := config.EnvNames(); len(envs) == 1 {
+			out = reports[envs[0]]
+		}
+		if err := i.DisplayFormatted(out); err != nil {
+			return failure.Wrap(err, "i.DisplayFormatted failed")
+		}
+	}
+
+	if config.IsFailOnDrift && len(drifted) > 0 {
+		return failure.InvalidState("%s", strings.Join(drifted, "; "))
+	}
+
+	return nil
+}
+
+// EnvDiff compares the env vars of the feature computed by FeatureEnvReport
+// with the ones of the lambda deployed in the env of c
+func (i *Infra) EnvDiff(ctx context.Context, api LambdaInspection, c CmdConfig, name string) (EnvDiffReport, error) {
+	var report EnvDiffReport
+	_, feature, err := i.LoadFeature(c, name)
+	if err != nil {
+		return report, failure.Wrap(err, "i.LoadFeature failed")
+	}
+
+	local, err := i.FeatureEnvReport(feature.Conf, c)
+	if err != nil {
+		return report, failure.Wrap(err, "i.FeatureEnvReport failed")
+	}
+
+	names, err := feature.Conf.EnvNames()
+	if err != nil {
+		return report, failure.Wrap(err, "feature.Conf.EnvNames failed")
+	}
+
+	deployed, err := DeployedEnv(ctx, api, feature, names)
+	if err != nil {
+		return report, failure.Wrap(err, "DeployedEnv failed")
+	}
+
+	diff := pstore.DiffMaps(local, deployed)
+	return EnvDiffReport{
+		Feature:   feature.Name,
+		Function:  feature.QualifiedName,
+		Missing:   diff.Removed,
+		Extra:     diff.Added,
+		Changed:   diff.Changed,
+		IsDrifted: !diff.IsEmpty(),
+	}, nil
+}
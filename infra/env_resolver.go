@@ -0,0 +1,328 @@
+package infra
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rsb/conf"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+)
+
+// EnvStage is one step of the env var pipeline, every stage can replace the
+// value set by the stages before it
+type EnvStage string
+
+const (
+	DefaultsStage  EnvStage = "default"
+	LocalEnvStage  EnvStage = "env"
+	PStoreStage    EnvStage = "pstore"
+	OverridesStage EnvStage = "override"
+)
+
+// DefaultEnvStages is the full pipeline, in order
+var DefaultEnvStages = []EnvStage{DefaultsStage, LocalEnvStage, PStoreStage, OverridesStage}
+
+// DefaultsPolicy decides whether the defaults of the conf tags are resolved.
+// Excluded defaults are not written to the lambda env, the feature falls back
+// on its conf tags at runtime instead. Deploy, env and pstore all decide
+// through EnvResolver.WithDefaults so they never disagree on a default.
+type DefaultsPolicy string
+
+const (
+	IncludeDefaults DefaultsPolicy = "include"
+	ExcludeDefaults DefaultsPolicy = "exclude"
+)
+
+func (p DefaultsPolicy) IsExcluded() bool {
+	return p == ExcludeDefaults
+}
+
+// ResolvedVar is the value of one env var and where it came from. Key is the
+// parameter store key of the var, empty when the var is not stored there.
+type ResolvedVar struct {
+	Name   string   `json:"name"`
+	Value  string   `json:"value"`
+	Source EnvStage `json:"source"`
+	Key    string   `json:"key,omitempty"`
+	IsSet  bool     `json:"is_set"`
+}
+
+// EnvResolution is the result of resolving the env vars of a feature
+type EnvResolution struct {
+	Feature string                 `json:"feature"`
+	Vars    map[string]ResolvedVar `json:"vars"`
+}
+
+// Map is the env var name to value of every var that was set
+func (r EnvResolution) Map() map[string]string {
+	result := map[string]string{}
+	for name, v := range r.Vars {
+		if v.IsSet {
+			result[name] = v.Value
+		}
+	}
+	return result
+}
+
+// Params is the parameter store key to value of every var that has a key
+func (r EnvResolution) Params() map[string]string {
+	result := map[string]string{}
+	for _, v := range r.Vars {
+		if v.IsSet && v.Key != "" {
+			result[v.Key] = v.Value
+		}
+	}
+	return result
+}
+
+// Names are the env var names of the feature, sorted
+func (r EnvResolution) Names() []string {
+	names := make([]string, 0, len(r.Vars))
+	for name := range r.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Sources is the env var name to the stage its value came from
+func (r EnvResolution) Sources() map[string]EnvStage {
+	result := map[string]EnvStage{}
+	for name, v := range r.Vars {
+		if v.IsSet {
+			result[name] = v.Source
+		}
+	}
+	return result
+}
+
+// EnvResolver computes the env vars of a feature by running the stages in
+// order: defaults from the conf tags, the local environment, parameter store
+// and explicit overrides. The env, pstore and deploy commands all resolve
+// through it so they always agree on the value of a var.
+type EnvResolver struct {
+	AppTitle  string
+	Store     ParamStorage
+	Stages    []EnvStage
+	Overrides map[string]string
+	// IsStrict fails when any var has no value after every stage, otherwise
+	// only required vars fail and the rest are reported with IsSet false
+	IsStrict bool
+	// Defaults is set by WithDefaults, empty leaves the conf as it is
+	Defaults DefaultsPolicy
+}
+
+// NewEnvResolver runs the given stages, or DefaultEnvStages when none are
+// given. store is only required by PStoreStage.
+func NewEnvResolver(appTitle string, store ParamStorage, stages ...EnvStage) *EnvResolver {
+	if len(stages) == 0 {
+		stages = DefaultEnvStages
+	}
+
+	return &EnvResolver{
+		AppTitle:  appTitle,
+		Store:     store,
+		Stages:    stages,
+		Overrides: map[string]string{},
+	}
+}
+
+func (r *EnvResolver) WithOverrides(overrides map[string]string) *EnvResolver {
+	for k, v := range overrides {
+		r.Overrides[k] = v
+	}
+	return r
+}
+
+// WithDefaults runs DefaultsStage first when defaults are included and never
+// when they are excluded
+func (r *EnvResolver) WithDefaults(p DefaultsPolicy) *EnvResolver {
+	stages := []EnvStage{}
+	if !p.IsExcluded() {
+		stages = append(stages, DefaultsStage)
+	}
+
+	for _, stage := range r.Stages {
+		if stage != DefaultsStage {
+			stages = append(stages, stage)
+		}
+	}
+
+	r.Stages = stages
+	r.Defaults = p
+	return r
+}
+
+func (r *EnvResolver) Strict() *EnvResolver {
+	r.IsStrict = true
+	return r
+}
+
+func (r *EnvResolver) Resolve(ctx context.Context, feature sls.Feature) (EnvResolution, error) {
+	result := EnvResolution{Feature: feature.Name, Vars: map[string]ResolvedVar{}}
+	if feature.Conf == nil {
+		return result, failure.System("[%s] feature.Conf is nil, not initialized", feature.Name)
+	}
+
+	// keep the conf's own view of its defaults in line with the resolver
+	if r.Defaults != "" {
+		feature.Conf.SetExcludeDefaults(r.Defaults.IsExcluded())
+	}
+
+	names, err := feature.Conf.EnvNames()
+	if err != nil {
+		return result, failure.Wrap(err, "feature.Conf.EnvNames failed (%s)", feature.Name)
+	}
+
+	fields, err := envFields(feature.Conf)
+	if err != nil {
+		return result, failure.Wrap(err, "envFields failed (%s)", feature.Name)
+	}
+
+	title := feature.ParamTitle(r.AppTitle)
+	for _, name := range names {
+		v := ResolvedVar{Name: name, Key: feature.ParamKey(r.AppTitle, name)}
+		if field, ok := fields[name]; ok {
+			v.Key = conf.PStoreKey(field, title, name)
+			if v.Key == "-" {
+				v.Key = ""
+			}
+		}
+		result.Vars[name] = v
+	}
+
+	for _, stage := range r.Stages {
+		if err = r.run(ctx, stage, fields, result.Vars); err != nil {
+			return result, failure.Wrap(err, "stage (%s) failed for (%s)", stage, feature.Name)
+		}
+	}
+
+	var missing []string
+	for _, name := range result.Names() {
+		if result.Vars[name].IsSet {
+			continue
+		}
+
+		if field, ok := fields[name]; r.IsStrict || (ok && field.IsRequired()) {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return result, failure.Config("(%s) has no value for (%s)", feature.Name, strings.Join(missing, ", "))
+	}
+
+	return result, nil
+}
+
+func (r *EnvResolver) run(ctx context.Context, stage EnvStage, fields map[string]conf.Field, vars map[string]ResolvedVar) error {
+	set := func(v ResolvedVar, value string) {
+		v.Value = value
+		v.Source = stage
+		v.IsSet = true
+		vars[v.Name] = v
+	}
+
+	switch stage {
+	case DefaultsStage:
+		for name, v := range vars {
+			if field, ok := fields[name]; ok && field.IsDefault() {
+				set(v, field.DefaultValue())
+			}
+		}
+	case LocalEnvStage:
+		for name, v := range vars {
+			if value, ok := os.LookupEnv(name); ok {
+				set(v, value)
+			}
+		}
+	case PStoreStage:
+		if r.Store == nil {
+			return failure.System("r.Store is nil, param storage is required by the pstore stage")
+		}
+
+		// one Collect instead of a Param call per var, the store splits the
+		// keys into GetParameters chunks and resolves them concurrently. The
+		// keys it reports as invalid have no value in pstore.
+		byKey := map[string][]string{}
+		for name, v := range vars {
+			if v.Key != "" {
+				byKey[v.Key] = append(byKey[v.Key], name)
+			}
+		}
+		if len(byKey) == 0 {
+			break
+		}
+
+		keys := make([]string, 0, len(byKey))
+		for key := range byKey {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		values, _, err := r.Store.Collect(ctx, keys...)
+		if err != nil {
+			return failure.Wrap(err, "r.Store.Collect failed for (%d) keys", len(keys))
+		}
+
+		for key, value := range values {
+			for _, name := range byKey[key] {
+				set(vars[name], value)
+			}
+		}
+	case OverridesStage:
+		for name, value := range r.Overrides {
+			if v, ok := vars[name]; ok {
+				set(v, value)
+			}
+		}
+	default:
+		return failure.InvalidParam("unknown env stage (%s)", stage)
+	}
+
+	return nil
+}
+
+// envFields indexes the conf fields by env var, only configurations built
+// with conf.NewConfig expose their fields, others resolve without defaults
+func envFields(c sls.Configurable) (map[string]conf.Field, error) {
+	result := map[string]conf.Field{}
+	cc, ok := c.(*conf.Config)
+	if !ok {
+		return result, nil
+	}
+
+	var prefix []string
+	if cc.IsPrefixEnabled() {
+		prefix = append(prefix, cc.GetPrefix())
+	}
+
+	fields, err := conf.Fields(cc.Data, prefix...)
+	if err != nil {
+		return nil, failure.Wrap(err, "conf.Fields failed")
+	}
+
+	for _, field := range fields {
+		result[field.EnvVariable()] = field
+	}
+
+	return result, nil
+}
+
+// ParseOverrides turns `KEY=VALUE` items, like the ones given to --set, into
+// a map
+func ParseOverrides(items []string) (map[string]string, error) {
+	result := map[string]string{}
+	for _, item := range items {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok || name == "" {
+			return nil, failure.InvalidParam("override (%s) is not in the form KEY=VALUE", item)
+		}
+		result[name] = value
+	}
+
+	return result, nil
+}
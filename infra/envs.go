@@ -0,0 +1,137 @@
+package infra
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/rsb/failure"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// MultiEnvAnnotation marks the commands that accept more than one env in
+	// --env (ex --env qa,staging), they loop with Infra.ForEachEnv
+	MultiEnvAnnotation = "sls-multi-env"
+)
+
+// EnvTarget is implemented by configs that can point a command at more than
+// one env
+type EnvTarget interface {
+	EnvNames() []string
+}
+
+// EnvNames are the envs of the comma separated --env, in the order given
+// with duplicates removed
+func (c CmdConfig) EnvNames() []string {
+	var envs []string
+	seen := map[string]bool{}
+	for _, env := range strings.Split(c.Env, ",") {
+		if env = strings.TrimSpace(env); env != "" && !seen[env] {
+			seen[env] = true
+			envs = append(envs, env)
+		}
+	}
+	return envs
+}
+
+// EnvResults are the results of a command run in several envs keyed by env
+type EnvResults map[string]interface{}
+
+// TableRows are the rows of every env, each prefixed with its env
+func (r EnvResults) TableRows() ([]string, [][]string) {
+	envs := make([]string, 0, len(r))
+	for env := range r {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+
+	header := []string{"ENV"}
+	var rows [][]string
+	for _, env := range envs {
+		h, envRows, err := tableRows(r[env])
+		if err != nil {
+			rows = append(rows, []string{env, err.Error()})
+			continue
+		}
+
+		if len(header) == 1 {
+			header = append(header, h...)
+		}
+		for _, row := range envRows {
+			rows = append(rows, append([]string{env}, row...))
+		}
+	}
+
+	return header, rows
+}
+
+// ForEachEnv runs fn once per env of --env with a copy of c for that env.
+// While fn runs i.PStoreAPI is the param store of that env, resolved with
+// ParamStoreFor, the original store is restored when it returns.
+func (i *Infra) ForEachEnv(c CmdConfig, fn func(c CmdConfig) error) error {
+	envs := c.EnvNames()
+	if len(envs) == 0 {
+		return failure.InvalidParam("--env is required")
+	}
+
+	home := i.PStoreAPI
+	defer func() { i.PStoreAPI = home }()
+
+	for _, env := range envs {
+		envConfig := c
+		envConfig.Env = env
+
+		store, err := i.ParamStoreFor(envConfig)
+		if err != nil {
+			return failure.Wrap(err, "i.ParamStoreFor failed (%s)", env)
+		}
+		i.PStoreAPI = store
+
+		if err = fn(envConfig); err != nil {
+			return failure.Wrap(err, "env (%s) failed", env)
+		}
+	}
+
+	return nil
+}
+
+// PerEnv collects the result of fn for every env of --env. With one env the
+// result is returned as it is so the output does not change, with more the
+// results are keyed by env in EnvResults.
+func (i *Infra) PerEnv(c CmdConfig, fn func(c CmdConfig) (interface{}, error)) (interface{}, error) {
+	if envs := c.EnvNames(); len(envs) == 1 {
+		c.Env = envs[0]
+		return fn(c)
+	}
+
+	results := EnvResults{}
+	err := i.ForEachEnv(c, func(c CmdConfig) error {
+		result, err := fn(c)
+		if err != nil {
+			return err
+		}
+
+		results[c.Env] = result
+		return nil
+	})
+	if err != nil {
+		return nil, failure.Wrap(err, "i.ForEachEnv failed")
+	}
+
+	return results, nil
+}
+
+// singleEnv rejects a list of envs for the commands that work in one env,
+// commands annotated with MultiEnvAnnotation loop on their own
+func (i *Infra) singleEnv(cmd *cobra.Command, c interface{}) error {
+	t, ok := c.(EnvTarget)
+	if !ok {
+		return nil
+	}
+
+	if _, multi := cmd.Annotations[MultiEnvAnnotation]; multi || len(t.EnvNames()) <= 1 {
+		return nil
+	}
+
+	return failure.InvalidParam("(%s) only supports one --env, (%d) were given", cmd.Name(), len(t.EnvNames()))
+}
@@ -0,0 +1,156 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/lambda"
+	"github.com/rsb/sls/pstore"
+	"github.com/rsb/sls/retry"
+	"github.com/spf13/cobra"
+)
+
+// ErrorCategory groups the failures of a command by what the caller can do
+// about them, each category has its own exit code
+type ErrorCategory string
+
+const (
+	UnknownCategory      ErrorCategory = "unknown"
+	SystemCategory       ErrorCategory = "system"
+	InvalidInputCategory ErrorCategory = "invalid_input"
+	NotFoundCategory     ErrorCategory = "not_found"
+	AuthCategory         ErrorCategory = "auth"
+	ConflictCategory     ErrorCategory = "conflict"
+	TimeoutCategory      ErrorCategory = "timeout"
+	ThrottledCategory    ErrorCategory = "throttled"
+	InterruptedCategory  ErrorCategory = "interrupted"
+
+	TextErrorFormat = "text"
+	JSONErrorFormat = "json"
+)
+
+// ExitCodes are the exit codes of each category, 130 is what a shell
+// reports for a command stopped by ctrl-c
+var ExitCodes = map[ErrorCategory]int{
+	UnknownCategory:      1,
+	SystemCategory:       1,
+	InvalidInputCategory: 2,
+	NotFoundCategory:     3,
+	AuthCategory:         4,
+	ConflictCategory:     5,
+	TimeoutCategory:      6,
+	ThrottledCategory:    7,
+	InterruptedCategory:  130,
+}
+
+func (c ErrorCategory) ExitCode() int {
+	if code, ok := ExitCodes[c]; ok {
+		return code
+	}
+	return ExitCodes[UnknownCategory]
+}
+
+// Categorize finds the category of err from the failure it wraps
+func Categorize(err error) ErrorCategory {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.Canceled):
+		return InterruptedCategory
+	case failure.IsTimeout(err), errors.Is(err, context.DeadlineExceeded):
+		return TimeoutCategory
+	case failure.IsInvalidParam(err), failure.IsValidation(err), failure.IsConfig(err):
+		return InvalidInputCategory
+	case failure.IsNotFound(err):
+		return NotFoundCategory
+	case failure.IsAnyAuthFailure(err):
+		return AuthCategory
+	case pstore.IsThrottled(err), retry.IsThrottle(err):
+		return ThrottledCategory
+	case failure.IsAlreadyExists(err), failure.IsInvalidState(err), lambda.IsBusy(err), pstore.IsConflict(err):
+		return ConflictCategory
+	case failure.IsSystem(err), failure.IsServer(err):
+		return SystemCategory
+	default:
+		return UnknownCategory
+	}
+}
+
+// ErrorReport is the json printed to stderr with --error-format json.
+// Errors has each failure of a bulk operation.
+type ErrorReport struct {
+	Command  string        `json:"command,omitempty"`
+	Category ErrorCategory `json:"category"`
+	ExitCode int           `json:"exit_code"`
+	Message  string        `json:"message"`
+	Errors   []string      `json:"errors,omitempty"`
+}
+
+func NewErrorReport(cmd *cobra.Command, err error) ErrorReport {
+	category := Categorize(err)
+	report := ErrorReport{
+		Category: category,
+		ExitCode: category.ExitCode(),
+		Message:  err.Error(),
+	}
+
+	if cmd != nil {
+		report.Command = cmd.CommandPath()
+	}
+
+	if errs, ok := failure.MultiResult(err); ok {
+		for _, e := range errs {
+			report.Errors = append(report.Errors, e.Error())
+		}
+	}
+
+	return report
+}
+
+// Execute runs the root command and returns the exit code, main is
+//
+//	os.Exit(i.Execute())
+//
+// cobra does not print the error, HandleError does.
+func (i *Infra) Execute() int {
+	root := i.ParentCmd.Root()
+	root.SilenceErrors = true
+
+	cmd, err := root.ExecuteC()
+	return i.HandleError(cmd, err)
+}
+
+// HandleError prints the --timing summary and err to stderr, as json with
+// --error-format json, and returns the exit code of its category. A nil err
+// is exit code 0.
+func (i *Infra) HandleError(cmd *cobra.Command, err error) int {
+	if tErr := i.WriteTimingSummary(cmd); tErr != nil {
+		fmt.Println("[infra:HandleError] failed to print the timing summary:", tErr.Error())
+	}
+
+	if err == nil {
+		return 0
+	}
+
+	report := NewErrorReport(cmd, err)
+	format := i.peekFlag(cmd, "error-format", "SLS_ERROR_FORMAT")
+
+	var pErr error
+	if format == JSONErrorFormat {
+		var data []byte
+		if data, pErr = json.Marshal(report); pErr == nil {
+			pErr = i.DisplayError(string(data) + "\n")
+		}
+	} else {
+		pErr = i.DisplayError(fmt.Sprintf("[infra] %+v\n", err))
+	}
+
+	if pErr != nil {
+		fmt.Println("[infra:HandleError] failed to print:", pErr.Error(), ":", err.Error())
+	}
+
+	return report.ExitCode
+}
@@ -0,0 +1,135 @@
+package infra_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/infra"
+	"github.com/rsb/sls/lambda"
+	"github.com/rsb/sls/pstore"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Stream is the stderr of the infra under test
+type Stream struct {
+	bytes.Buffer
+}
+
+func (s *Stream) Close() error {
+	return nil
+}
+
+func newErrorCmd(t *testing.T, format string) *cobra.Command {
+	cmd := &cobra.Command{Use: "deploy"}
+	cmd.Flags().String("error-format", infra.TextErrorFormat, "")
+	require.NoError(t, cmd.Flags().Set("error-format", format))
+
+	return cmd
+}
+
+func TestInfra_HandleError(t *testing.T) {
+	throttled := &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+	busy := &lambda.BusyError{
+		Function: "app-dev-feature",
+		Msg:      "c.api.UpdateFunctionCode failed",
+		Cause:    &smithy.GenericAPIError{Code: "ResourceConflictException", Message: "update in progress"},
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		category infra.ErrorCategory
+		exitCode int
+		errors   []string
+	}{
+		{
+			name:     "not found",
+			err:      failure.Wrap(failure.NotFound("feature (api)"), "i.LoadFeature failed"),
+			category: infra.NotFoundCategory,
+			exitCode: 3,
+		},
+		{
+			name:     "invalid param",
+			err:      failure.InvalidParam("--canary (%d) is not between 1 and 99", 120),
+			category: infra.InvalidInputCategory,
+			exitCode: 2,
+		},
+		{
+			name:     "busy",
+			err:      failure.Wrap(busy, "i.DeployFeatureCode failed"),
+			category: infra.ConflictCategory,
+			exitCode: 5,
+		},
+		{
+			name:     "throttled",
+			err:      failure.Wrap(&pstore.ThrottledError{Msg: "c.api.GetParameters failed ([/app/dev/db])", Cause: throttled}, "i.FeatureParams failed"),
+			category: infra.ThrottledCategory,
+			exitCode: 7,
+		},
+		{
+			name:     "canceled",
+			err:      failure.Wrap(context.Canceled, "i.WatchFeature failed"),
+			category: infra.InterruptedCategory,
+			exitCode: 130,
+		},
+		{
+			name:     "multi",
+			err:      failure.Append(nil, failure.NotFound("feature (api)"), failure.NotFound("feature (worker)")),
+			category: infra.NotFoundCategory,
+			exitCode: 3,
+			errors:   []string{failure.NotFound("feature (api)").Error(), failure.NotFound("feature (worker)").Error()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stderr Stream
+			i := infra.Infra{Stderr: &stderr}
+
+			code := i.HandleError(newErrorCmd(t, infra.JSONErrorFormat), tt.err)
+			assert.Equal(t, tt.exitCode, code)
+
+			var report infra.ErrorReport
+			require.NoError(t, json.Unmarshal(stderr.Bytes(), &report), "stderr is expected to be json: %s", stderr.String())
+			assert.Equal(t, "deploy", report.Command)
+			assert.Equal(t, tt.category, report.Category)
+			assert.Equal(t, tt.exitCode, report.ExitCode)
+			assert.Equal(t, tt.err.Error(), report.Message)
+			assert.Equal(t, tt.errors, report.Errors)
+
+			stderr.Reset()
+			code = i.HandleError(newErrorCmd(t, infra.TextErrorFormat), tt.err)
+			assert.Equal(t, tt.exitCode, code)
+			assert.Contains(t, stderr.String(), "[infra] ")
+			assert.Contains(t, stderr.String(), tt.err.Error())
+		})
+	}
+}
+
+func TestInfra_HandleError_Nil(t *testing.T) {
+	var stderr Stream
+	i := infra.Infra{Stderr: &stderr}
+
+	assert.Equal(t, 0, i.HandleError(newErrorCmd(t, infra.JSONErrorFormat), nil))
+	assert.Empty(t, stderr.String())
+}
+
+func TestInfra_HandleError_EnvFormat(t *testing.T) {
+	t.Setenv("SLS_ERROR_FORMAT", infra.JSONErrorFormat)
+
+	var stderr Stream
+	i := infra.Infra{Stderr: &stderr}
+
+	cmd := &cobra.Command{Use: "deploy"}
+	assert.Equal(t, 2, i.HandleError(cmd, failure.InvalidParam("feature name is missing")))
+
+	var report infra.ErrorReport
+	require.NoError(t, json.Unmarshal(stderr.Bytes(), &report), "stderr is expected to be json: %s", stderr.String())
+	assert.Equal(t, infra.InvalidInputCategory, report.Category)
+}
@@ -0,0 +1,459 @@
+package infra
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/lambda"
+	"github.com/spf13/cobra"
+)
+
+func SetupESMCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.ESMCmd == nil {
+		in.ESMCmd = ESMCmd
+	}
+	in.ParentCmd.AddCommand(in.ESMCmd)
+
+	if in.ESMListCmd == nil {
+		in.ESMListCmd = ESMListCmd
+	}
+	in.ESMListCmd.RunE = in.RunESMList
+	in.ESMCmd.AddCommand(in.ESMListCmd)
+
+	if in.ESMCreateCmd == nil {
+		in.ESMCreateCmd = ESMCreateCmd
+	}
+	in.ESMCreateCmd.RunE = in.RunESMCreate
+	in.ESMCmd.AddCommand(in.ESMCreateCmd)
+
+	var cb ESMCreateBind
+	if err := Bind(in.ESMCreateCmd, in.Viper, &cb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.ESMCreateCmd")
+	}
+
+	if in.ESMUpdateCmd == nil {
+		in.ESMUpdateCmd = ESMUpdateCmd
+	}
+	in.ESMUpdateCmd.RunE = in.RunESMUpdate
+	in.ESMCmd.AddCommand(in.ESMUpdateCmd)
+
+	var ub ESMUpdateBind
+	if err := Bind(in.ESMUpdateCmd, in.Viper, &ub); err != nil {
+		return failure.Wrap(err, "Bind failed for in.ESMUpdateCmd")
+	}
+
+	if in.ESMPauseCmd == nil {
+		in.ESMPauseCmd = ESMPauseCmd
+	}
+	in.ESMPauseCmd.RunE = in.RunESMPause
+	in.ESMCmd.AddCommand(in.ESMPauseCmd)
+
+	var pb ESMSelectBind
+	if err := Bind(in.ESMPauseCmd, in.Viper, &pb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.ESMPauseCmd")
+	}
+
+	if in.ESMResumeCmd == nil {
+		in.ESMResumeCmd = ESMResumeCmd
+	}
+	in.ESMResumeCmd.RunE = in.RunESMResume
+	in.ESMCmd.AddCommand(in.ESMResumeCmd)
+
+	var rb ESMSelectBind
+	if err := Bind(in.ESMResumeCmd, in.Viper, &rb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.ESMResumeCmd")
+	}
+
+	return nil
+}
+
+var ESMCmd = &cobra.Command{
+	Use:   "esm",
+	Short: "manage the event source mappings (sqs, dynamodb and kinesis) of a lambda",
+}
+
+var ESMListCmd = &cobra.Command{
+	Use:   "list <FEATURE>",
+	Short: "list the event source mappings of a lambda",
+	Args:  cobra.ExactArgs(1),
+}
+
+var ESMCreateCmd = &cobra.Command{
+	Use:   "create <FEATURE>",
+	Short: "map a queue or stream to a lambda",
+	Args:  cobra.ExactArgs(1),
+}
+
+var ESMUpdateCmd = &cobra.Command{
+	Use:   "update <FEATURE>",
+	Short: "change the batching or filters of an event source mapping",
+	Args:  cobra.ExactArgs(1),
+}
+
+var ESMPauseCmd = &cobra.Command{
+	Use:   "pause <FEATURE>",
+	Short: "disable the event source mappings of a lambda, the records wait in the source",
+	Args:  cobra.ExactArgs(1),
+}
+
+var ESMResumeCmd = &cobra.Command{
+	Use:   "resume <FEATURE>",
+	Short: "enable the event source mappings of a lambda again",
+	Args:  cobra.ExactArgs(1),
+}
+
+// ESMSelectBind picks one mapping of the feature, by the arn of its source
+// or its uuid
+type ESMSelectBind struct {
+	Source string `conf:"cli:source, cli-u: Arn of the queue or stream of the mapping"`
+	UUID   string `conf:"cli:uuid, cli-u: UUID of the mapping"`
+}
+
+// ESMBatchBind is a single json filter pattern since the filter patterns
+// themselves have commas
+type ESMBatchBind struct {
+	BatchSize int32         `conf:"cli:batch-size, cli-u: Most records in a batch sent to the lambda"`
+	Window    time.Duration `conf:"cli:window, cli-u: Longest the records are gathered for a batch (max 5m)"`
+	Filter    string        `conf:"cli:filter, cli-u: Json filter pattern the records must match to invoke the lambda"`
+}
+
+type ESMCreateBind struct {
+	ESMBatchBind
+	Source           string `conf:"cli:source, cli-u: Arn of the sqs queue or dynamodb or kinesis stream"`
+	StartingPosition string `conf:"cli:starting-position, cli-u: TRIM_HORIZON or LATEST where a stream is read from"`
+	IsDisabled       bool   `conf:"cli:disabled, cli-u: Create the mapping disabled"`
+}
+
+type ESMUpdateBind struct {
+	ESMSelectBind
+	ESMBatchBind
+	IsNoFilter bool `conf:"cli:no-filter, cli-u: Remove the filters so every record invokes the lambda"`
+}
+
+type ESMSelectConfig struct {
+	CmdConfig
+	ESMSelectBind
+}
+
+type ESMCreateConfig struct {
+	CmdConfig
+	ESMCreateBind
+}
+
+type ESMUpdateConfig struct {
+	CmdConfig
+	ESMUpdateBind
+}
+
+// ESMReport is the event source mappings of the lambda of a feature
+type ESMReport struct {
+	Feature  string                     `json:"feature"`
+	Mappings []lambda.EventSourceReport `json:"mappings"`
+}
+
+func (r ESMReport) TableRows() ([]string, [][]string) {
+	header := []string{"FEATURE", "UUID", "TYPE", "SOURCE", "STATE", "BATCH", "WINDOW", "FILTERS"}
+	var rows [][]string
+	for _, m := range r.Mappings {
+		rows = append(rows, []string{
+			r.Feature,
+			m.UUID,
+			m.SourceType,
+			m.SourceARN,
+			m.State,
+			strconv.Itoa(int(m.BatchSize)),
+			fmt.Sprintf("%ds", m.MaxBatchingWindow),
+			strconv.Itoa(len(m.Filters)),
+		})
+	}
+
+	return header, rows
+}
+
+// RunESMList runs `<service> infra esm list <FEATURE>` which shows the
+// queues and streams mapped to the lambda and whether they are enabled
+func (i *Infra) RunESMList(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	api, ok := i.LambdaAPI.(LambdaEventSources)
+	if !ok {
+		return failure.System("i.LambdaAPI is not initialized or does not implement LambdaEventSources")
+	}
+
+	var config CmdConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	_, feature, err := i.LoadFeature(config, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	mappings, err := api.EventSources(ctx, feature.QualifiedName)
+	if err != nil {
+		return failure.Wrap(err, "api.EventSources failed (%s)", feature.QualifiedName)
+	}
+
+	return i.DisplayFormatted(ESMReport{Feature: feature.Name, Mappings: mappings})
+}
+
+// RunESMCreate runs `<service> infra esm create <FEATURE> --source <ARN>`
+// which maps the queue or stream to the lambda. A stream needs a
+// --starting-position.
+// `<service> infra esm create <FEATURE> --source <ARN> [--batch-size 10] [--window 5s] [--filter '{"body":{"type":["order"]}}'] [--starting-position LATEST] [--disabled]`
+func (i *Infra) RunESMCreate(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	api, ok := i.LambdaAPI.(LambdaEventSources)
+	if !ok {
+		return failure.System("i.LambdaAPI is not initialized or does not implement LambdaEventSources")
+	}
+
+	var config ESMCreateConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if config.Source == "" {
+		return failure.InvalidParam("--source is required, the arn of the queue or stream")
+	}
+
+	if err := i.Writable("esm create"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	settings := config.ESMBatchBind.settings(cmd)
+	settings.QualifiedName = feature.QualifiedName
+	settings.SourceARN = config.Source
+	settings.StartingPosition = strings.ToUpper(config.StartingPosition)
+	if config.IsDisabled {
+		settings.Enabled = aws.Bool(false)
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	if i.DryRun("CreateEventSourceMapping", feature.QualifiedName, config.Source) {
+		return nil
+	}
+
+	defer i.Step("esm create")()
+	report, err := api.CreateEventSource(ctx, settings)
+	if err != nil {
+		return failure.Wrap(err, "api.CreateEventSource failed (%s)", feature.QualifiedName)
+	}
+
+	return i.DisplayFormatted(ESMReport{Feature: feature.Name, Mappings: []lambda.EventSourceReport{*report}})
+}
+
+// RunESMUpdate runs `<service> infra esm update <FEATURE>` which changes
+// only what the flags give on the mapping picked by --source or --uuid, or
+// the only mapping of the lambda.
+// `<service> infra esm update <FEATURE> [--source <ARN> | --uuid <UUID>] [--batch-size 10] [--window 5s] [--filter '{...}' | --no-filter]`
+func (i *Infra) RunESMUpdate(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	api, ok := i.LambdaAPI.(LambdaEventSources)
+	if !ok {
+		return failure.System("i.LambdaAPI is not initialized or does not implement LambdaEventSources")
+	}
+
+	var config ESMUpdateConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if config.IsNoFilter && config.Filter != "" {
+		return failure.InvalidParam("--no-filter can not be combined with --filter")
+	}
+
+	settings := config.ESMBatchBind.settings(cmd)
+	if config.IsNoFilter {
+		settings.Filters = []string{}
+	}
+	if settings.BatchSize == nil && settings.MaxBatchingWindow == nil && settings.Filters == nil {
+		return failure.InvalidParam("nothing to update, --batch-size, --window, --filter or --no-filter is required")
+	}
+
+	if err := i.Writable("esm update"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	mappings, err := api.EventSources(ctx, feature.QualifiedName)
+	if err != nil {
+		return failure.Wrap(err, "api.EventSources failed (%s)", feature.QualifiedName)
+	}
+
+	selected, err := config.ESMSelectBind.selectMappings(mappings)
+	if err != nil {
+		return failure.Wrap(err, "selectMappings failed (%s)", feature.Name)
+	}
+	if len(selected) > 1 {
+		return failure.InvalidParam("(%s) has (%d) mappings, --source or --uuid picks the one to update", feature.Name, len(selected))
+	}
+	settings.UUID = selected[0].UUID
+	settings.SourceARN = selected[0].SourceARN
+
+	if i.DryRun("UpdateEventSourceMapping", selected[0].SourceARN, settings.UUID) {
+		return nil
+	}
+
+	defer i.Step("esm update")()
+	report, err := api.UpdateEventSource(ctx, settings)
+	if err != nil {
+		return failure.Wrap(err, "api.UpdateEventSource failed (%s)", settings.UUID)
+	}
+
+	return i.DisplayFormatted(ESMReport{Feature: feature.Name, Mappings: []lambda.EventSourceReport{*report}})
+}
+
+// RunESMPause runs `<service> infra esm pause <FEATURE>` which disables the
+// mappings of the lambda, or the one picked by --source or --uuid, so it
+// stops consuming during an incident. The records stay in the queue or
+// stream until `esm resume`, or until they expire.
+func (i *Infra) RunESMPause(cmd *cobra.Command, args []string) error {
+	return i.setESMEnabled(cmd, args, false)
+}
+
+// RunESMResume runs `<service> infra esm resume <FEATURE>` which enables
+// the mappings `esm pause` disabled
+func (i *Infra) RunESMResume(cmd *cobra.Command, args []string) error {
+	return i.setESMEnabled(cmd, args, true)
+}
+
+func (i *Infra) setESMEnabled(cmd *cobra.Command, args []string, isEnabled bool) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	api, ok := i.LambdaAPI.(LambdaEventSources)
+	if !ok {
+		return failure.System("i.LambdaAPI is not initialized or does not implement LambdaEventSources")
+	}
+
+	var config ESMSelectConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	op := "esm pause"
+	if isEnabled {
+		op = "esm resume"
+	}
+	if err := i.Writable(op); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	mappings, err := api.EventSources(ctx, feature.QualifiedName)
+	if err != nil {
+		return failure.Wrap(err, "api.EventSources failed (%s)", feature.QualifiedName)
+	}
+
+	selected, err := config.ESMSelectBind.selectMappings(mappings)
+	if err != nil {
+		return failure.Wrap(err, "selectMappings failed (%s)", feature.Name)
+	}
+
+	defer i.Step(op)()
+	result := ESMReport{Feature: feature.Name}
+	for _, m := range selected {
+		if m.IsEnabled() == isEnabled {
+			_, _ = fmt.Fprintf(i.Stderr, "[infra] (%s) is already %s\n", m.SourceARN, m.State)
+			result.Mappings = append(result.Mappings, m)
+			continue
+		}
+
+		if i.DryRun("UpdateEventSourceMapping", m.SourceARN, fmt.Sprintf("enabled: %t", isEnabled)) {
+			continue
+		}
+
+		settings := lambda.EventSourceSettings{UUID: m.UUID, SourceARN: m.SourceARN, Enabled: aws.Bool(isEnabled)}
+		report, err := api.UpdateEventSource(ctx, settings)
+		if err != nil {
+			return failure.Wrap(err, "api.UpdateEventSource failed (%s)", m.SourceARN)
+		}
+		result.Mappings = append(result.Mappings, *report)
+	}
+
+	if i.IsDryRun {
+		return nil
+	}
+
+	return i.DisplayFormatted(result)
+}
+
+// settings only has the batching and filter of the flags that were given
+func (b ESMBatchBind) settings(cmd *cobra.Command) lambda.EventSourceSettings {
+	var s lambda.EventSourceSettings
+	if cmd.Flags().Changed("batch-size") {
+		s.BatchSize = aws.Int32(b.BatchSize)
+	}
+	if cmd.Flags().Changed("window") {
+		window := b.Window
+		s.MaxBatchingWindow = &window
+	}
+	if b.Filter != "" {
+		s.Filters = []string{b.Filter}
+	}
+
+	return s
+}
+
+// selectMappings is the mapping picked by --source or --uuid, or all of
+// them when neither is given
+func (b ESMSelectBind) selectMappings(mappings []lambda.EventSourceReport) ([]lambda.EventSourceReport, error) {
+	if len(mappings) == 0 {
+		return nil, failure.NotFound("the lambda has no event source mappings")
+	}
+
+	if b.Source == "" && b.UUID == "" {
+		return mappings, nil
+	}
+
+	for _, m := range mappings {
+		if (b.UUID != "" && m.UUID == b.UUID) || (b.UUID == "" && m.SourceARN == b.Source) {
+			return []lambda.EventSourceReport{m}, nil
+		}
+	}
+
+	return nil, failure.NotFound("no mapping of the lambda has --source (%s) or --uuid (%s)", b.Source, b.UUID)
+}
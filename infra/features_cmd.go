@@ -0,0 +1,249 @@
+package infra
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/spf13/cobra"
+)
+
+func SetupFeaturesCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.FeaturesCmd == nil {
+		in.FeaturesCmd = FeaturesCmd
+	}
+	in.FeaturesCmd.RunE = in.RunFeatures
+	in.ParentCmd.AddCommand(in.FeaturesCmd)
+
+	var fb FeaturesBind
+	if err := Bind(in.FeaturesCmd, in.Viper, &fb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.FeaturesCmd")
+	}
+
+	return nil
+}
+
+var FeaturesCmd = &cobra.Command{
+	Use:   "features",
+	Short: "list the features of the service",
+	Args:  cobra.NoArgs,
+}
+
+type FeaturesBind struct {
+	Trigger       string   `conf:"cli:trigger, cli-u: Only list features with this trigger (ex sqs)"`
+	IsDeployed    bool     `conf:"cli:deployed, cli-u: Compare the features with the functions deployed in the env"`
+	IsFailOnDrift bool     `conf:"cli:fail-on-drift, cli-u: Fail when --deployed finds features not deployed or functions with no feature"`
+	Tags          []string `conf:"cli:tag, cli-u: Only count the deployed functions with these KEY=VALUE tags (ex sls:env=prod)"`
+}
+
+type FeaturesConfig struct {
+	CmdConfig
+	FeaturesBind
+}
+
+type FeatureInfo struct {
+	Name          string `json:"name"`
+	Trigger       string `json:"trigger"`
+	QualifiedName string `json:"qualified_name"`
+	CodeDir       string `json:"code_dir"`
+	BinaryName    string `json:"binary_name"`
+}
+
+// RunFeatures runs `<service> infra features` which lists every feature the
+// service found in its lambdas dir, sorted by trigger then name. With
+// --deployed they are compared with the functions named after the service
+// in the env instead.
+// `<service> infra features [--trigger sqs] [--deployed [--fail-on-drift] [--tag sls:env=prod]] [--format table|json]`
+func (i *Infra) RunFeatures(cmd *cobra.Command, _ []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	var config FeaturesConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if config.IsFailOnDrift && !config.IsDeployed {
+		return failure.InvalidParam("--fail-on-drift compares with the deployed functions, it requires --deployed")
+	}
+
+	tags, err := ParseOverrides(config.Tags)
+	if err != nil {
+		return failure.Wrap(err, "ParseOverrides failed for --tag")
+	}
+	if len(tags) > 0 && !config.IsDeployed {
+		return failure.InvalidParam("--tag filters the deployed functions, it requires --deployed")
+	}
+
+	var trigger sls.InvokeTrigger
+	if config.Trigger != "" {
+		if trigger, err = sls.InvokeTriggerFromString(config.Trigger); err != nil {
+			return failure.Wrap(err, "sls.InvokeTriggerFromString failed (%s)", config.Trigger)
+		}
+	}
+
+	service, err := i.LoadService(config.CmdConfig)
+	if err != nil {
+		return failure.Wrap(err, "i.LoadService failed")
+	}
+
+	features := ServiceFeatures(service, trigger)
+	if config.IsDeployed {
+		ctx, stop := i.Context(cmd)
+		defer stop()
+
+		report, err := i.FeatureDrift(ctx, service, trigger, tags, features)
+		if err != nil {
+			return failure.Wrap(err, "i.FeatureDrift failed")
+		}
+
+		if err = i.DisplayFormatted(report); err != nil {
+			return failure.Wrap(err, "i.DisplayFormatted failed")
+		}
+
+		if config.IsFailOnDrift && report.IsDrifted {
+			return failure.InvalidState("(%d) features are not deployed and (%d) functions have no feature", len(report.Missing), len(report.Orphaned))
+		}
+		return nil
+	}
+
+	// humans run this one the most, it is a table unless --format says otherwise
+	if config.Format == "" {
+		return TableFormatter{}.Format(i.Stdout, features)
+	}
+
+	return i.DisplayFormatted(features)
+}
+
+// FeatureInfos is a list of features, as a table it keeps the columns
+// `infra features` always had
+type FeatureInfos []FeatureInfo
+
+func (f FeatureInfos) TableRows() ([]string, [][]string) {
+	header := []string{"NAME", "TRIGGER", "QUALIFIED NAME", "CODE DIR", "BINARY"}
+	rows := make([][]string, 0, len(f))
+	for _, info := range f {
+		rows = append(rows, []string{info.Name, info.Trigger, info.QualifiedName, info.CodeDir, info.BinaryName})
+	}
+
+	return header, rows
+}
+
+// ServiceFeatures describes the features of the service, only the ones with
+// trigger when it is not empty
+func ServiceFeatures(service *sls.MicroService, trigger sls.InvokeTrigger) FeatureInfos {
+	var result FeatureInfos
+	for _, f := range service.Features {
+		if !trigger.IsEmpty() && f.Trigger != trigger {
+			continue
+		}
+
+		result = append(result, FeatureInfo{
+			Name:          f.Name,
+			Trigger:       f.Trigger.String(),
+			QualifiedName: f.QualifiedName,
+			CodeDir:       filepath.Join(service.LambdasDir(), f.CodeDir()),
+			BinaryName:    f.BinaryName,
+		})
+	}
+
+	sort.Slice(result, func(a, b int) bool {
+		if result[a].Trigger != result[b].Trigger {
+			return result[a].Trigger < result[b].Trigger
+		}
+		return result[a].Name < result[b].Name
+	})
+
+	return result
+}
+
+// FeatureDriftReport compares the features of the service with the functions
+// deployed under its name. Missing features are not deployed, a deploy with
+// --create makes them. Orphaned functions have no feature, they were renamed
+// or removed from the lambdas dir without being destroyed.
+type FeatureDriftReport struct {
+	Prefix    string   `json:"prefix"`
+	Deployed  []string `json:"deployed"`
+	Missing   []string `json:"missing"`
+	Orphaned  []string `json:"orphaned"`
+	IsDrifted bool     `json:"is_drifted"`
+}
+
+// TableRows lists one row per function, sorted by name
+func (r FeatureDriftReport) TableRows() ([]string, [][]string) {
+	var rows [][]string
+	for _, name := range r.Deployed {
+		rows = append(rows, []string{name, "deployed"})
+	}
+	for _, name := range r.Missing {
+		rows = append(rows, []string{name, "missing"})
+	}
+	for _, name := range r.Orphaned {
+		rows = append(rows, []string{name, "orphaned"})
+	}
+	sort.Slice(rows, func(a, b int) bool {
+		return rows[a][0] < rows[b][0]
+	})
+
+	return []string{"FUNCTION", "STATUS"}, rows
+}
+
+// FeatureDrift lists the functions named after the service, only the ones
+// with trigger when it is not empty and with all of tags, and compares them
+// with its features. A feature whose function lacks the tags is missing.
+func (i *Infra) FeatureDrift(ctx context.Context, service *sls.MicroService, trigger sls.InvokeTrigger, tags map[string]string, features FeatureInfos) (FeatureDriftReport, error) {
+	report := FeatureDriftReport{
+		Prefix:   service.Name.QualifiedName() + "-",
+		Deployed: []string{},
+		Missing:  []string{},
+		Orphaned: []string{},
+	}
+	if !trigger.IsEmpty() {
+		report.Prefix += trigger.String() + "_"
+	}
+
+	api, ok := i.LambdaAPI.(LambdaListing)
+	if !ok {
+		return report, failure.System("i.LambdaAPI is not initialized or does not implement LambdaListing")
+	}
+
+	defer i.Step("list functions")()
+	functions, err := api.ListByPrefix(ctx, report.Prefix, tags)
+	if err != nil {
+		return report, failure.Wrap(err, "api.ListByPrefix failed (%s)", report.Prefix)
+	}
+
+	deployed := map[string]bool{}
+	for _, f := range functions {
+		deployed[f.Name] = true
+	}
+
+	local := map[string]bool{}
+	for _, f := range features {
+		local[f.QualifiedName] = true
+		if deployed[f.QualifiedName] {
+			report.Deployed = append(report.Deployed, f.QualifiedName)
+		} else {
+			report.Missing = append(report.Missing, f.QualifiedName)
+		}
+	}
+
+	for _, f := range functions {
+		if !local[f.Name] {
+			report.Orphaned = append(report.Orphaned, f.Name)
+		}
+	}
+
+	sort.Strings(report.Deployed)
+	sort.Strings(report.Missing)
+	report.IsDrifted = len(report.Missing) > 0 || len(report.Orphaned) > 0
+
+	return report, nil
+}
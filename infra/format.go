@@ -0,0 +1,382 @@
+package infra
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rsb/failure"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rsb/sls/dotenv"
+)
+
+// OutputFormat is how the commands print their results, selected with the
+// global --format flag
+type OutputFormat string
+
+const (
+	JSONFormat   OutputFormat = "json"
+	YAMLFormat   OutputFormat = "yaml"
+	TableFormat  OutputFormat = "table"
+	DotenvFormat OutputFormat = "dotenv"
+)
+
+var OutputFormats = []OutputFormat{JSONFormat, YAMLFormat, TableFormat, DotenvFormat}
+
+func (f OutputFormat) String() string {
+	return string(f)
+}
+
+func (f OutputFormat) IsValid() bool {
+	for _, valid := range OutputFormats {
+		if f == valid {
+			return true
+		}
+	}
+	return false
+}
+
+func ToOutputFormat(s string) (OutputFormat, error) {
+	f := OutputFormat(strings.ToLower(strings.TrimSpace(s)))
+	if !f.IsValid() {
+		return f, failure.InvalidParam("--format (%s) must be one of (%v)", s, OutputFormats)
+	}
+
+	return f, nil
+}
+
+// Formatter writes a command result to w
+type Formatter interface {
+	Format(w io.Writer, v interface{}) error
+}
+
+// TableMarshaler is implemented by results that know their own columns, the
+// table formatter falls back on flattening the json of any other result
+type TableMarshaler interface {
+	TableRows() (header []string, rows [][]string)
+}
+
+// DotenvMarshaler is implemented by results that know their own env vars,
+// the dotenv formatter falls back on flattening the json of any other result
+type DotenvMarshaler interface {
+	DotenvMap() map[string]string
+}
+
+// OutputFormatting is implemented by configs that select an output format,
+// like CmdConfig with --format
+type OutputFormatting interface {
+	OutputFormat() string
+}
+
+func (c CmdConfig) OutputFormat() string {
+	return c.Format
+}
+
+func NewFormatter(f OutputFormat) (Formatter, error) {
+	switch f {
+	case JSONFormat, "":
+		return JSONFormatter{}, nil
+	case YAMLFormat:
+		return YAMLFormatter{}, nil
+	case TableFormat:
+		return TableFormatter{}, nil
+	case DotenvFormat:
+		return DotenvFormatter{}, nil
+	default:
+		return nil, failure.InvalidParam("output format (%s) is not supported", f)
+	}
+}
+
+// outputFormat selects the formatter of the config, the json formatter is
+// kept when the config does not choose one
+func (i *Infra) outputFormat(c interface{}) error {
+	m, ok := c.(OutputFormatting)
+	if !ok || m.OutputFormat() == "" {
+		return nil
+	}
+
+	f, err := ToOutputFormat(m.OutputFormat())
+	if err != nil {
+		return failure.Wrap(err, "ToOutputFormat failed")
+	}
+
+	if i.Formatter, err = NewFormatter(f); err != nil {
+		return failure.Wrap(err, "NewFormatter failed")
+	}
+
+	return nil
+}
+
+// DisplayFormatted prints d with the formatter selected by --format, json
+// when none was selected
+func (i *Infra) DisplayFormatted(d interface{}) error {
+	f := i.Formatter
+	if f == nil {
+		f = JSONFormatter{}
+	}
+
+	if err := f.Format(i.Stdout, d); err != nil {
+		return failure.Wrap(err, "f.Format failed")
+	}
+
+	return nil
+}
+
+type JSONFormatter struct{}
+
+func (JSONFormatter) Format(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return failure.ToSystem(err, "json.Marshal failed")
+	}
+
+	if _, err = w.Write(data); err != nil {
+		return failure.ToSystem(err, "w.Write failed")
+	}
+
+	return nil
+}
+
+// YAMLFormatter writes the json form of the result as yaml, so the field
+// names match the json output
+type YAMLFormatter struct{}
+
+func (YAMLFormatter) Format(w io.Writer, v interface{}) error {
+	generic, err := toGeneric(v)
+	if err != nil {
+		return failure.Wrap(err, "toGeneric failed")
+	}
+
+	data, err := yaml.Marshal(generic)
+	if err != nil {
+		return failure.ToSystem(err, "yaml.Marshal failed")
+	}
+
+	if _, err = w.Write(data); err != nil {
+		return failure.ToSystem(err, "w.Write failed")
+	}
+
+	return nil
+}
+
+// TableFormatter writes a list of objects with one column per field and
+// anything else as KEY VALUE rows, nested fields are joined with a dot
+type TableFormatter struct{}
+
+func (TableFormatter) Format(w io.Writer, v interface{}) error {
+	header, rows, err := tableRows(v)
+	if err != nil {
+		return failure.Wrap(err, "tableRows failed")
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if len(header) > 0 {
+		_, _ = fmt.Fprintln(tw, strings.Join(header, "\t"))
+	}
+	for _, row := range rows {
+		_, _ = fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	if err = tw.Flush(); err != nil {
+		return failure.ToSystem(err, "tw.Flush failed")
+	}
+
+	return nil
+}
+
+// DotenvFormatter writes KEY=value lines that docker compose and shells can
+// source. The keys of a map, like env vars or param keys, become the var
+// names, a param key keeps its feature so `featA/DB_HOST` is `FEATA_DB_HOST`.
+// The fields of any other result are joined with an underscore. Two keys
+// that end up with the same var name are an error.
+type DotenvFormatter struct{}
+
+func (DotenvFormatter) Format(w io.Writer, v interface{}) error {
+	vars, err := dotenvVars(v)
+	if err != nil {
+		return failure.Wrap(err, "dotenvVars failed")
+	}
+
+	if _, err = w.Write(dotenv.Marshal(vars)); err != nil {
+		return failure.ToSystem(err, "w.Write failed")
+	}
+
+	return nil
+}
+
+func tableRows(v interface{}) ([]string, [][]string, error) {
+	if t, ok := v.(TableMarshaler); ok {
+		header, rows := t.TableRows()
+		return header, rows, nil
+	}
+
+	generic, err := toGeneric(v)
+	if err != nil {
+		return nil, nil, failure.Wrap(err, "toGeneric failed")
+	}
+
+	if list, ok := generic.([]interface{}); ok && isObjectList(list) {
+		columns := map[string]bool{}
+		for _, item := range list {
+			for k := range item.(map[string]interface{}) {
+				columns[k] = true
+			}
+		}
+
+		var names []string
+		for k := range columns {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+
+		header := make([]string, len(names))
+		for idx, name := range names {
+			header[idx] = strings.ToUpper(name)
+		}
+
+		var rows [][]string
+		for _, item := range list {
+			obj := item.(map[string]interface{})
+			row := make([]string, len(names))
+			for idx, name := range names {
+				row[idx] = cell(obj[name])
+			}
+			rows = append(rows, row)
+		}
+
+		return header, rows, nil
+	}
+
+	var rows [][]string
+	for _, l := range flatten(nil, generic) {
+		rows = append(rows, []string{strings.Join(l.path, "."), l.value})
+	}
+
+	return []string{"KEY", "VALUE"}, rows, nil
+}
+
+func dotenvVars(v interface{}) (map[string]string, error) {
+	if d, ok := v.(DotenvMarshaler); ok {
+		return d.DotenvMap(), nil
+	}
+
+	generic, err := toGeneric(v)
+	if err != nil {
+		return nil, failure.Wrap(err, "toGeneric failed")
+	}
+
+	vars := map[string]string{}
+	keys := map[string]string{}
+	for _, l := range flatten(nil, generic) {
+		key := strings.TrimPrefix(strings.Join(l.path, "_"), "/")
+		name := DotenvName(key)
+		if other, ok := keys[name]; ok {
+			return nil, failure.InvalidParam("(%s) and (%s) are both written as (%s)", other, key, name)
+		}
+		keys[name] = key
+		vars[name] = l.value
+	}
+
+	return vars, nil
+}
+
+// DotenvName turns a key into a valid env var name, ex `code.sha256` is
+// `CODE_SHA256`
+func DotenvName(key string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(key) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			b.WriteRune(r)
+			continue
+		}
+		b.WriteRune('_')
+	}
+
+	name := b.String()
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+
+	return name
+}
+
+type leaf struct {
+	path  []string
+	value string
+}
+
+// flatten lists every scalar of a decoded json value with the keys leading
+// to it, sorted by path
+func flatten(path []string, v interface{}) []leaf {
+	var result []leaf
+	switch value := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			next := append(append([]string{}, path...), k)
+			result = append(result, flatten(next, value[k])...)
+		}
+	case []interface{}:
+		for idx, item := range value {
+			next := append(append([]string{}, path...), fmt.Sprintf("%d", idx))
+			result = append(result, flatten(next, item)...)
+		}
+	default:
+		result = append(result, leaf{path: path, value: cell(value)})
+	}
+
+	return result
+}
+
+func cell(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(value)
+		return string(data)
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+func isObjectList(list []interface{}) bool {
+	if len(list) == 0 {
+		return false
+	}
+
+	for _, item := range list {
+		if _, ok := item.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// toGeneric round trips v through json so every formatter sees the same
+// field names as the json output
+func toGeneric(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, failure.ToSystem(err, "json.Marshal failed")
+	}
+
+	var generic interface{}
+	if err = json.Unmarshal(data, &generic); err != nil {
+		return nil, failure.ToSystem(err, "json.Unmarshal failed")
+	}
+
+	return generic, nil
+}
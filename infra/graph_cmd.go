@@ -0,0 +1,115 @@
+package infra
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/spf13/cobra"
+)
+
+func SetupGraphCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.GraphCmd == nil {
+		in.GraphCmd = GraphCmd
+	}
+	in.GraphCmd.RunE = in.RunGraph
+	in.ParentCmd.AddCommand(in.GraphCmd)
+
+	var gb GraphBind
+	if err := Bind(in.GraphCmd, in.Viper, &gb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.GraphCmd")
+	}
+
+	return nil
+}
+
+var GraphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "show the dependency graph of the service features",
+}
+
+type GraphBind struct {
+	IsDOT bool `conf:"cli:dot, cli-u: Render the graph in graphviz DOT format instead of json"`
+}
+
+type GraphConfig struct {
+	CmdConfig
+	GraphBind
+}
+
+// RunGraph runs `<service> infra graph` which connects every feature to the
+// tables, queues, params and services it depends on.
+// `<service> infra graph [--dot]`
+func (i *Infra) RunGraph(cmd *cobra.Command, _ []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	var config GraphConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	service, err := i.LoadService(config.CmdConfig)
+	if err != nil {
+		return failure.Wrap(err, "i.LoadService failed")
+	}
+
+	graph, err := sls.NewDependencyGraph(service)
+	if err != nil {
+		return failure.Wrap(err, "sls.NewDependencyGraph failed")
+	}
+
+	if config.IsDOT {
+		if _, err = fmt.Fprint(i.Stdout, graph.DOT()); err != nil {
+			return failure.ToSystem(err, "fmt.Fprint failed")
+		}
+		return nil
+	}
+
+	return i.DisplayFormatted(graph)
+}
+
+// WarnImpact prints a warning to stderr for every dependency that features
+// still depend on. Warnings never fail the command, the graph is only as
+// good as what the features declare.
+func (i *Infra) WarnImpact(service *sls.MicroService, deps ...sls.Dependency) {
+	graph, err := sls.NewDependencyGraph(service)
+	if err != nil {
+		_, _ = fmt.Fprintf(i.Stderr, "[infra] warning: dependency graph unavailable: %v\n", err)
+		return
+	}
+
+	for _, d := range deps {
+		if features := graph.Dependents(d); len(features) > 0 {
+			_, _ = fmt.Fprintf(i.Stderr, "[infra] warning: %s is used by (%s)\n", d.ID(), strings.Join(features, ", "))
+		}
+	}
+}
+
+// WarnFeatureImpact warns about the dependencies of feature that other
+// features share
+func (i *Infra) WarnFeatureImpact(service *sls.MicroService, feature string) {
+	graph, err := sls.NewDependencyGraph(service)
+	if err != nil {
+		_, _ = fmt.Fprintf(i.Stderr, "[infra] warning: dependency graph unavailable: %v\n", err)
+		return
+	}
+
+	shared := graph.SharedWith(feature)
+	ids := make([]string, 0, len(shared))
+	for id := range shared {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		_, _ = fmt.Fprintf(i.Stderr, "[infra] warning: %s is also used by (%s)\n", id, strings.Join(shared[id], ", "))
+	}
+}
@@ -3,13 +3,17 @@
 package infra
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/mitchellh/go-homedir"
 
 	"github.com/rsb/conf"
@@ -18,7 +22,19 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/rsb/sls"
+	"github.com/rsb/sls/cwlogs"
+	"github.com/rsb/sls/cwmetrics"
+	"github.com/rsb/sls/iam"
 	"github.com/rsb/sls/lambda"
+	"github.com/rsb/sls/pstore"
+	"github.com/rsb/sls/restapi"
+	"github.com/rsb/sls/retry"
+	"github.com/rsb/sls/s3"
+	"github.com/rsb/sls/scaling"
+	"github.com/rsb/sls/scheduler"
+	"github.com/rsb/sls/secrets"
+	"github.com/rsb/sls/sqs"
+	"github.com/rsb/sls/telemetry"
 	"github.com/spf13/cobra"
 )
 
@@ -28,13 +44,243 @@ type ParamStorage interface {
 	Collect(ctx context.Context, key ...string) (map[string]string, []string, error)
 	Delete(ctx context.Context, key string) (string, error)
 	Put(ctx context.Context, key, value string, overwrite ...bool) (string, error)
+	CopyPath(ctx context.Context, srcPath, dstPath string, overwrite bool) (pstore.CopyReport, error)
 	EnsurePathPrefix(path string) string
 }
 
+// ParamMetadata is implemented by param stores that can report the version,
+// type and last modified date of their params
+type ParamMetadata interface {
+	ParamMeta(ctx context.Context, key string) (pstore.ParamRecord, error)
+	PathRecords(ctx context.Context, path string, recursive ...bool) (map[string]pstore.ParamRecord, error)
+}
+
+// ParamDecryption is implemented by param stores that can read a param
+// without decrypting it, like pstore.Client
+type ParamDecryption interface {
+	ParamWithDecryption(ctx context.Context, key string, decrypt bool) (string, error)
+}
+
+// ParamRewriting is implemented by param stores that can change the values
+// of the params they copy, like pstore.Client
+type ParamRewriting interface {
+	CopyPathRewrite(ctx context.Context, srcPath, dstPath string, overwrite bool, rewrite func(value string) string) (pstore.CopyReport, error)
+}
+
 type LambdaDeployments interface {
 	Compile(data sls.BuildSettings) (sls.BuildResult, error)
 	UpdateCode(ctx context.Context, payload lambda.CodePayload) (*lambda.FeatureUpdateReport, error)
 	UpdateConfig(ctx context.Context, in lambda.FeatureSettings) (*lambda.FeatureUpdateReport, error)
+	Invoke(ctx context.Context, in lambda.InvokePayload) (*lambda.InvokeReport, error)
+}
+
+type LogGroupManagement interface {
+	EnsureLogGroup(ctx context.Context, in cwlogs.LogGroupSettings) (*cwlogs.LogGroupReport, error)
+}
+
+// ArtifactUploading is implemented by storage clients that can upload the
+// code of a feature, like s3.Client
+type ArtifactUploading interface {
+	Upload(ctx context.Context, s s3.UploadSettings) (s3.UploadReport, error)
+}
+
+// LambdaInspection is implemented by lambda clients that can read the
+// configuration of a deployed function, like lambda.Client
+type LambdaInspection interface {
+	EnvVars(ctx context.Context, qualifiedName string) (map[string]string, error)
+}
+
+// LambdaDescribing is implemented by lambda clients that can read the
+// deployed state and configuration of a function, like lambda.Client
+type LambdaDescribing interface {
+	Function(ctx context.Context, qualifiedName string) (*lambda.FeatureInfo, error)
+}
+
+// LambdaCreation is implemented by lambda clients that can create a
+// function, like lambda.Client
+type LambdaCreation interface {
+	Create(ctx context.Context, in lambda.FeatureCreateInput) (*lambda.FeatureUpdateReport, error)
+}
+
+// LambdaUpdateWaiting is implemented by lambda clients that can wait for
+// an update or a create of a function to be applied, like lambda.Client
+type LambdaUpdateWaiting interface {
+	WaitUntilUpdated(ctx context.Context, qualifiedName string, timeout time.Duration) error
+	WaitUntilActive(ctx context.Context, qualifiedName string, timeout time.Duration) error
+}
+
+// LambdaListing is implemented by lambda clients that can list the deployed
+// functions, like lambda.Client
+type LambdaListing interface {
+	ListByPrefix(ctx context.Context, prefix string, tags map[string]string) ([]lambda.FeatureInfo, error)
+}
+
+// LambdaConcurrency is implemented by lambda clients that can read the
+// reserved and provisioned concurrency of a function, like lambda.Client
+type LambdaConcurrency interface {
+	Concurrency(ctx context.Context, qualifiedName, alias string) (*lambda.ConcurrencyReport, error)
+}
+
+// LambdaLayerPublishing is implemented by lambda clients that can publish
+// the versions of a layer, like lambda.Client
+type LambdaLayerPublishing interface {
+	PublishLayer(ctx context.Context, name string, zip []byte, compatibleRuntimes []string) (*lambda.LayerReport, error)
+}
+
+// LambdaURLManagement is implemented by lambda clients that can manage the
+// function urls of a function, like lambda.Client
+type LambdaURLManagement interface {
+	FunctionURL(ctx context.Context, qualifiedName, alias string) (*lambda.URLReport, error)
+	CreateFunctionURL(ctx context.Context, s lambda.URLSettings) (*lambda.URLReport, error)
+	UpdateFunctionURL(ctx context.Context, s lambda.URLSettings) (*lambda.URLReport, error)
+	AllowPublicURL(ctx context.Context, qualifiedName, alias string) error
+}
+
+// LambdaRulePermission is implemented by lambda clients that can allow an
+// eventbridge rule to invoke a function, like lambda.Client
+type LambdaRulePermission interface {
+	AllowRule(ctx context.Context, qualifiedName, ruleARN string) error
+}
+
+// LambdaTagging is implemented by lambda clients that can tag a function,
+// like lambda.Client
+type LambdaTagging interface {
+	Tag(ctx context.Context, qualifiedName string, tags map[string]string) error
+}
+
+// LambdaEventSources is implemented by lambda clients that can manage the
+// event source mappings of a function, like lambda.Client
+type LambdaEventSources interface {
+	EventSources(ctx context.Context, qualifiedName string) ([]lambda.EventSourceReport, error)
+	CreateEventSource(ctx context.Context, s lambda.EventSourceSettings) (*lambda.EventSourceReport, error)
+	UpdateEventSource(ctx context.Context, s lambda.EventSourceSettings) (*lambda.EventSourceReport, error)
+}
+
+// LambdaVersioning is implemented by lambda clients that can roll a function
+// back to a published version, like lambda.Client
+type LambdaVersioning interface {
+	Rollback(ctx context.Context, s lambda.RollbackSettings) (*lambda.RollbackReport, error)
+}
+
+// LambdaDeletion is implemented by lambda clients that can delete a
+// function, like lambda.Client
+type LambdaDeletion interface {
+	Delete(ctx context.Context, qualifiedName string) error
+}
+
+// LogGroupDeletion is implemented by log clients that can delete a log
+// group, like cwlogs.Client
+type LogGroupDeletion interface {
+	DeleteLogGroup(ctx context.Context, name string) error
+}
+
+// LogTailing is implemented by log clients that can read log events, like
+// cwlogs.Client
+type LogTailing interface {
+	Tail(ctx context.Context, s cwlogs.TailSettings, fn func(e cwlogs.LogEvent) error) error
+}
+
+// QueueRedriving is implemented by queue clients that can move the messages
+// of a dead letter queue back to their source, like sqs.Client
+type QueueRedriving interface {
+	QueueURL(ctx context.Context, name string) (string, error)
+	DeadLetterSources(ctx context.Context, dlqURL string) ([]string, error)
+	Redrive(ctx context.Context, s sqs.RedriveSettings) (sqs.RedriveReport, error)
+}
+
+// LambdaTrafficShifting is implemented by lambda clients that can split the
+// traffic of an alias between two versions, like lambda.Client
+type LambdaTrafficShifting interface {
+	ShiftTraffic(ctx context.Context, qualifiedName, alias, version string, weight float64) (*lambda.AliasReport, error)
+	PromoteAlias(ctx context.Context, qualifiedName, alias, version string) (*lambda.AliasReport, error)
+	ResetRouting(ctx context.Context, qualifiedName, alias string) (*lambda.AliasReport, error)
+}
+
+// LambdaAliasing is implemented by lambda clients that can manage the
+// aliases of a function, like lambda.Client
+type LambdaAliasing interface {
+	Alias(ctx context.Context, qualifiedName, alias string) (*lambda.AliasReport, error)
+	CreateAlias(ctx context.Context, s lambda.AliasSettings) (*lambda.AliasReport, error)
+	UpdateAlias(ctx context.Context, s lambda.AliasSettings) (*lambda.AliasReport, error)
+	ListAliases(ctx context.Context, qualifiedName string) ([]lambda.AliasReport, error)
+}
+
+// LambdaVersionPublishing is implemented by lambda clients that can
+// publish and list the versions of a function, like lambda.Client
+type LambdaVersionPublishing interface {
+	Versions(ctx context.Context, qualifiedName string) ([]lambda.VersionReport, error)
+	PublishVersion(ctx context.Context, qualifiedName, description, codeSHA256 string) (*lambda.VersionReport, error)
+}
+
+// LambdaTuning is implemented by lambda clients that can read and change
+// the memory size and timeout of a function, like lambda.Client
+type LambdaTuning interface {
+	Limits(ctx context.Context, qualifiedName string) (lambda.FunctionLimits, error)
+	UpdateLimits(ctx context.Context, qualifiedName string, l lambda.FunctionLimits) (*lambda.FeatureUpdateReport, error)
+}
+
+// LambdaFootprint is implemented by lambda clients that can read the code
+// size, limits and architecture of a function, like lambda.Client
+type LambdaFootprint interface {
+	Footprint(ctx context.Context, qualifiedName string) (lambda.FunctionFootprint, error)
+}
+
+// LambdaRoleReading is implemented by lambda clients that can read the
+// execution role of a function, like lambda.Client
+type LambdaRoleReading interface {
+	ExecutionRole(ctx context.Context, qualifiedName string) (lambda.FunctionRole, error)
+}
+
+type MetricReading interface {
+	Sum(ctx context.Context, q cwmetrics.MetricQuery) (float64, error)
+	Peak(ctx context.Context, q cwmetrics.MetricQuery, stat string) (float64, bool, error)
+}
+
+// StageDeployment is implemented by api gateway clients that can redeploy
+// the stage of a rest api, like restapi.Client
+type StageDeployment interface {
+	DeployStage(ctx context.Context, s restapi.StageSettings) (*restapi.StageReport, error)
+}
+
+type EnvEncryption interface {
+	EncryptEnv(ctx context.Context, keyARN, functionName string, vars map[string]string, names ...string) (map[string]string, error)
+}
+
+type ConcurrencyScheduling interface {
+	RegisterTarget(ctx context.Context, t scaling.ScalableTarget) error
+	PutSchedule(ctx context.Context, a scaling.ScheduledAction) error
+	Schedules(ctx context.Context, t scaling.ScalableTarget) ([]scaling.ScheduleReport, error)
+}
+
+// ScheduleManagement is implemented by clients that manage the eventbridge
+// scheduler schedules invoking a function, like scheduler.Client
+type ScheduleManagement interface {
+	PutSchedule(ctx context.Context, s scheduler.ScheduleSettings) (*scheduler.ScheduleReport, error)
+	FunctionSchedules(ctx context.Context, group, functionName string) ([]scheduler.ScheduleReport, error)
+	DeleteSchedule(ctx context.Context, group, name string) error
+}
+
+// RuleManagement is implemented by schedule clients that also manage the
+// scheduled eventbridge rules invoking a function, like scheduler.Client
+type RuleManagement interface {
+	PutRule(ctx context.Context, s scheduler.RuleSettings) (*scheduler.ScheduleReport, error)
+	FunctionRules(ctx context.Context, bus, functionName string) ([]scheduler.ScheduleReport, error)
+	DeleteRule(ctx context.Context, bus, name string) error
+}
+
+// SecretManagement is implemented by clients that manage secrets manager
+// secrets and their rotation, like secrets.Client
+type SecretManagement interface {
+	Value(ctx context.Context, name, stage string) (*secrets.SecretValue, error)
+	Put(ctx context.Context, s secrets.PutSettings) (*secrets.PutReport, error)
+	Rotate(ctx context.Context, s secrets.RotationSettings) (*secrets.SecretReport, error)
+	List(ctx context.Context, prefix string) ([]secrets.SecretReport, error)
+}
+
+// RolePolicyReading is implemented by clients that read the policies of an
+// iam role, like iam.Client
+type RolePolicyReading interface {
+	RolePolicies(ctx context.Context, roleName string) ([]iam.PolicyReport, error)
 }
 
 type EnvIdentity interface {
@@ -48,13 +294,31 @@ type CmdConfig struct {
 	WithTrigger     bool   `conf:"          global-flag, env:-,               cli:name-includes-trigger, cli-s:t,   cli-u:For when feature name is given with the trigger (ex apigw_feature)"`
 	IsText          bool   `conf:"          global-flag, env:CLI_FORMAT_TEXT, cli:text,           cli-u:Use plain text instead of json'"`
 	IsQualifiedName bool   `conf:"          global-flag, env:QUALIFIED_NAMES, cli:qualified-name, cli-u:Display names as fully qualified"`
-	Env             string `conf:"required, global-flag, env:ENV,             cli:env, cli-s:e,   cli-u:Application env"`
+	IsTiming        bool   `conf:"          global-flag, env:SLS_CLI_TIMING,  cli:timing,         cli-u:Print step durations and aws call counts when the command ends"`
+	Env             string `conf:"required, global-flag, env:ENV,             cli:env, cli-s:e,   cli-u:Application env (pstore export and diff and env take comma separated envs)"`
+	TargetAccount   string `conf:"          global-flag, env:SLS_TARGET_ACCOUNT, cli:target-account, cli-u:Comma separated account ids or aliases to operate in through an assumed role"`
+	TargetRole      string `conf:"          global-flag, env:SLS_TARGET_ROLE, default:OrganizationAccountAccessRole, cli:target-role, cli-u:Role name assumed in the target accounts"`
+	IsReadOnly      bool   `conf:"          global-flag, env:SLS_READ_ONLY,   cli:read-only,      cli-u:Refuse every operation that changes aws resources"`
+	MetricsAddr     string `conf:"          global-flag, env:SLS_METRICS_ADDR, cli:metrics-addr,  cli-u:Serve prometheus metrics on this address (ex localhost:9090) while long running commands run"`
+	Format          string `conf:"          global-flag, env:SLS_CLI_FORMAT,  cli:format,         cli-u:Output format json or yaml or table or dotenv"`
+	IsYes           bool   `conf:"          global-flag, env:SLS_YES,         cli:yes, cli-s:y,   cli-u:Answer yes to every confirmation prompt"`
+	ErrorFormat     string `conf:"          global-flag, env:SLS_ERROR_FORMAT, cli:error-format, cli-u:Print errors to stderr as text or json"`
+	DryRun          bool   `conf:"          global-flag, env:SLS_DRY_RUN,     cli:dry-run,        cli-u:Print the aws changes deploy and pstore and secrets and destroy and alias would make without making them"`
 }
 
 func (c CmdConfig) EnvName() string {
 	return c.Env
 }
 
+// Defaults is whether the defaults of the conf tags are resolved, every
+// command excludes them with --skip-defaults
+func (c CmdConfig) Defaults() DefaultsPolicy {
+	if c.SkipDefaults {
+		return ExcludeDefaults
+	}
+	return IncludeDefaults
+}
+
 func (c CmdConfig) NameIncludesTrigger() bool {
 	return c.WithTrigger
 }
@@ -64,24 +328,112 @@ func (c CmdConfig) IsFullyQualifiedName() bool {
 }
 
 type Infra struct {
+	Stdin              io.Reader
 	Stdout             io.ReadWriteCloser
 	Stderr             io.ReadWriteCloser
 	Viper              *viper.Viper
 	PStoreAPI          ParamStorage
 	LambdaAPI          LambdaDeployments
+	LogsAPI            LogGroupManagement
+	KMSAPI             EnvEncryption
+	ScalingAPI         ConcurrencyScheduling
+	SchedulerAPI       ScheduleManagement
+	IAMAPI             RolePolicyReading
+	QueueAPI           QueueRedriving
+	MetricsAPI         MetricReading
+	GatewayAPI         StageDeployment
+	ArtifactsAPI       ArtifactUploading
+	SecretsAPI         SecretManagement
+	Progress           ProgressReporting
+	Terraform          *sls.Terraform
 	Service            *sls.MicroService
 	ServiceConstructor func(config EnvIdentity) (*sls.MicroService, error)
+	// Project is the project file of the service, found from the working dir
+	// when nil. ServiceSetup runs on the service built from it.
+	Project            *sls.ProjectConfig
+	ServiceSetup       func(service *sls.MicroService) error
+	PStoreConstructor  func(config EnvIdentity) (ParamStorage, error)
+	Telemetry          *telemetry.Recorder
+	AWSConfig          *aws.Config
+	AccountRoles       map[string]string
+	AccountConstructor func(cfg aws.Config) (AccountClients, error)
+	IsReadOnly         bool
+	IsDryRun           bool
+	Formatter          Formatter
 	ParentCmd          *cobra.Command
 	Prefix             []string
 
 	DeployCmd       *cobra.Command
+	BuildCmd        *cobra.Command
 	EnvCmd          *cobra.Command
 	EnvExportCmd    *cobra.Command
+	EnvDiffCmd      *cobra.Command
 	PStoreCmd       *cobra.Command
 	PStoreImportCmd *cobra.Command
 	PStoreDeleteCmd *cobra.Command
 	PStoreExportCmd *cobra.Command
+	PStoreDiffCmd   *cobra.Command
+	PStoreCopyCmd   *cobra.Command
+	PStoreGetCmd    *cobra.Command
 	InvokeCmd       *cobra.Command
+
+	ConcurrencyCmd         *cobra.Command
+	ConcurrencyScheduleCmd *cobra.Command
+	ConcurrencySetCmd      *cobra.Command
+	ConcurrencyShowCmd     *cobra.Command
+	GraphCmd               *cobra.Command
+	PStoreMigrateCmd       *cobra.Command
+	PStorePruneCmd         *cobra.Command
+	LogsCmd                *cobra.Command
+	DeployRollbackCmd      *cobra.Command
+	FeaturesCmd            *cobra.Command
+	DestroyCmd             *cobra.Command
+	SQSCmd                 *cobra.Command
+	SQSRedriveCmd          *cobra.Command
+	TuneCmd                *cobra.Command
+	TFCmd                  *cobra.Command
+	TFPlanCmd              *cobra.Command
+	TFApplyCmd             *cobra.Command
+	TFDestroyCmd           *cobra.Command
+	CloneEnvCmd            *cobra.Command
+	ValidateCmd            *cobra.Command
+	CompletionCmd          *cobra.Command
+	UICmd                  *cobra.Command
+	AliasCmd               *cobra.Command
+	AliasCreateCmd         *cobra.Command
+	AliasUpdateCmd         *cobra.Command
+	AliasListCmd           *cobra.Command
+	VersionCmd             *cobra.Command
+	VersionPublishCmd      *cobra.Command
+	VersionListCmd         *cobra.Command
+	ConsoleCmd             *cobra.Command
+	DoctorCmd              *cobra.Command
+	ReportCmd              *cobra.Command
+	ScheduleCmd            *cobra.Command
+	ScheduleListCmd        *cobra.Command
+	SchedulePutCmd         *cobra.Command
+	ScheduleDeleteCmd      *cobra.Command
+	WarmCmd                *cobra.Command
+	PermissionsCmd         *cobra.Command
+	MetricsCmd             *cobra.Command
+	SecretsCmd             *cobra.Command
+	SecretsGetCmd          *cobra.Command
+	SecretsPutCmd          *cobra.Command
+	SecretsRotateCmd       *cobra.Command
+	SecretsListCmd         *cobra.Command
+	LayerCmd               *cobra.Command
+	LayerPublishCmd        *cobra.Command
+	URLCmd                 *cobra.Command
+	ESMCmd                 *cobra.Command
+	ESMListCmd             *cobra.Command
+	ESMCreateCmd           *cobra.Command
+	ESMUpdateCmd           *cobra.Command
+	ESMPauseCmd            *cobra.Command
+	ESMResumeCmd           *cobra.Command
+
+	accounts    map[string]AccountClients
+	projectKeys map[string]bool
+	answers     *bufio.Reader
 }
 
 func SetupCommands(i *Infra) error {
@@ -97,10 +449,119 @@ func SetupCommands(i *Infra) error {
 		return failure.Wrap(err, "SetupDeployCmd failed")
 	}
 
+	if err := SetupBuildCmd(i); err != nil {
+		return failure.Wrap(err, "SetupBuildCmd failed")
+	}
+
 	if err := SetupParamStoreCmd(i); err != nil {
 		return failure.Wrap(err, "SetupParamStoreCmd failed")
 	}
 
+	if err := SetupConcurrencyCmd(i); err != nil {
+		return failure.Wrap(err, "SetupConcurrencyCmd failed")
+	}
+
+	if err := SetupGraphCmd(i); err != nil {
+		return failure.Wrap(err, "SetupGraphCmd failed")
+	}
+
+	if err := SetupInvokeCmd(i); err != nil {
+		return failure.Wrap(err, "SetupInvokeCmd failed")
+	}
+
+	if err := SetupLogsCmd(i); err != nil {
+		return failure.Wrap(err, "SetupLogsCmd failed")
+	}
+
+	if err := SetupFeaturesCmd(i); err != nil {
+		return failure.Wrap(err, "SetupFeaturesCmd failed")
+	}
+
+	if err := SetupDestroyCmd(i); err != nil {
+		return failure.Wrap(err, "SetupDestroyCmd failed")
+	}
+
+	if err := SetupSQSCmd(i); err != nil {
+		return failure.Wrap(err, "SetupSQSCmd failed")
+	}
+
+	if err := SetupTuneCmd(i); err != nil {
+		return failure.Wrap(err, "SetupTuneCmd failed")
+	}
+
+	if err := SetupTFCmd(i); err != nil {
+		return failure.Wrap(err, "SetupTFCmd failed")
+	}
+
+	if err := SetupCloneEnvCmd(i); err != nil {
+		return failure.Wrap(err, "SetupCloneEnvCmd failed")
+	}
+
+	if err := SetupValidateCmd(i); err != nil {
+		return failure.Wrap(err, "SetupValidateCmd failed")
+	}
+
+	if err := SetupUICmd(i); err != nil {
+		return failure.Wrap(err, "SetupUICmd failed")
+	}
+
+	if err := SetupAliasCmd(i); err != nil {
+		return failure.Wrap(err, "SetupAliasCmd failed")
+	}
+
+	if err := SetupVersionCmd(i); err != nil {
+		return failure.Wrap(err, "SetupVersionCmd failed")
+	}
+
+	if err := SetupConsoleCmd(i); err != nil {
+		return failure.Wrap(err, "SetupConsoleCmd failed")
+	}
+
+	if err := SetupDoctorCmd(i); err != nil {
+		return failure.Wrap(err, "SetupDoctorCmd failed")
+	}
+
+	if err := SetupReportCmd(i); err != nil {
+		return failure.Wrap(err, "SetupReportCmd failed")
+	}
+
+	if err := SetupScheduleCmd(i); err != nil {
+		return failure.Wrap(err, "SetupScheduleCmd failed")
+	}
+
+	if err := SetupWarmCmd(i); err != nil {
+		return failure.Wrap(err, "SetupWarmCmd failed")
+	}
+
+	if err := SetupPermissionsCmd(i); err != nil {
+		return failure.Wrap(err, "SetupPermissionsCmd failed")
+	}
+
+	if err := SetupMetricsCmd(i); err != nil {
+		return failure.Wrap(err, "SetupMetricsCmd failed")
+	}
+
+	if err := SetupSecretsCmd(i); err != nil {
+		return failure.Wrap(err, "SetupSecretsCmd failed")
+	}
+
+	if err := SetupLayerCmd(i); err != nil {
+		return failure.Wrap(err, "SetupLayerCmd failed")
+	}
+
+	if err := SetupURLCmd(i); err != nil {
+		return failure.Wrap(err, "SetupURLCmd failed")
+	}
+
+	if err := SetupESMCmd(i); err != nil {
+		return failure.Wrap(err, "SetupESMCmd failed")
+	}
+
+	// registered last, it adds feature completion to the commands above
+	if err := SetupCompletionCmd(i); err != nil {
+		return failure.Wrap(err, "SetupCompletionCmd failed")
+	}
+
 	return nil
 }
 
@@ -125,6 +586,12 @@ func (i *Infra) Validate() error {
 }
 
 func SetupInfraCmd(i *Infra) error {
+	if i != nil && i.Viper != nil {
+		if err := i.initProject(); err != nil {
+			return failure.Wrap(err, "i.initProject failed")
+		}
+	}
+
 	if err := i.Validate(); err != nil {
 		return failure.Wrap(err, "i.Validate failed")
 	}
@@ -133,7 +600,14 @@ func SetupInfraCmd(i *Infra) error {
 	if err := Bind(i.ParentCmd, i.Viper, &config); err != nil {
 		return failure.Wrap(err, "Bind failed for in.ParentCmd")
 	}
+	i.initDefaults()
 
+	return nil
+}
+
+// initDefaults fills the streams, progress and telemetry the commands and
+// InfraService rely on
+func (i *Infra) initDefaults() {
 	if i.Stdout == nil {
 		i.Stdout = os.Stdout
 	}
@@ -141,9 +615,79 @@ func SetupInfraCmd(i *Infra) error {
 	if i.Stderr == nil {
 		i.Stderr = os.Stderr
 	}
+
+	if i.Stdin == nil {
+		i.Stdin = os.Stdin
+	}
+
+	if i.Progress == nil {
+		i.Progress = NopProgress{}
+		if isTerminal(i.Stderr) {
+			i.Progress = NewTerminalProgress(i.Stderr)
+		}
+	}
+
+	if i.Telemetry == nil {
+		i.Telemetry = telemetry.NewRecorder()
+	}
+	i.setRetryPolicy(i.clients())
+}
+
+// RetryPolicy is retry.Default reporting its retries to i.Telemetry, the
+// clients of the infra are given it when the infra is set up
+func (i *Infra) RetryPolicy() retry.Policy {
+	policy := retry.Default()
+	if i.Telemetry != nil {
+		policy = policy.WithObserver(i.Telemetry.ObserveRetry)
+	}
+	return policy
+}
+
+// WriteTimingSummary prints the telemetry summary to stderr when --timing is
+// on. HandleError calls it however the command ended, so a failed command
+// still shows where its time went. Steps are always timed, aws calls are
+// only counted when the APIs were built from an aws.Config wrapped with
+// telemetry.WithRecorder(cfg, i.Telemetry)
+func (i *Infra) WriteTimingSummary(cmd *cobra.Command) error {
+	if isTiming, _ := strconv.ParseBool(i.peekFlag(cmd, "timing", "SLS_CLI_TIMING")); !isTiming || i.Telemetry == nil {
+		return nil
+	}
+
+	if err := i.Telemetry.Summary().Write(i.Stderr); err != nil {
+		return failure.ToSystem(err, "Summary().Write failed")
+	}
+
+	return nil
+}
+
+// ServeMetrics exposes the telemetry of the infra as prometheus metrics on
+// --metrics-addr until ctx is done. Only long running commands, like
+// `logs --follow` and `deploy --watch`, call it, short commands are better
+// served by --timing.
+func (i *Infra) ServeMetrics(ctx context.Context, c CmdConfig) error {
+	if c.MetricsAddr == "" {
+		return nil
+	}
+
+	addr, err := telemetry.Serve(ctx, c.MetricsAddr, i.Telemetry)
+	if err != nil {
+		return failure.ToSystem(err, "telemetry.Serve failed (%s)", c.MetricsAddr)
+	}
+
+	if c.Verbose {
+		_, _ = fmt.Fprintf(i.Stderr, "[infra] metrics on http://%s%s\n", addr, telemetry.MetricsPath)
+	}
+
 	return nil
 }
 
+// Step times a step of a command for the timing summary
+//
+//	defer i.Step("deploy code")()
+func (i *Infra) Step(name string) func() {
+	return i.Telemetry.Step(name)
+}
+
 func (i *Infra) LoadService(config CmdConfig) (*sls.MicroService, error) {
 	var service *sls.MicroService
 
@@ -155,6 +699,24 @@ func (i *Infra) LoadService(config CmdConfig) (*sls.MicroService, error) {
 	return service, nil
 }
 
+// ParamStoreFor resolves the param store for the env in config. When no
+// PStoreConstructor is configured every env shares i.PStoreAPI
+func (i *Infra) ParamStoreFor(config EnvIdentity) (ParamStorage, error) {
+	if i.PStoreConstructor == nil {
+		if i.PStoreAPI == nil {
+			return nil, failure.System("i.PStoreAPI is not initialized")
+		}
+		return i.PStoreAPI, nil
+	}
+
+	store, err := i.PStoreConstructor(config)
+	if err != nil {
+		return nil, failure.Wrap(err, "i.PStoreConstructor failed (%s)", config.EnvName())
+	}
+
+	return store, nil
+}
+
 func (i *Infra) LoadFeature(config CmdConfig, name string) (*sls.MicroService, sls.Feature, error) {
 	var feature sls.Feature
 
@@ -205,10 +767,27 @@ func (i *Infra) LoadFeature(config CmdConfig, name string) (*sls.MicroService, s
 }
 
 func (i *Infra) Process(cmd *cobra.Command, c interface{}) error {
+	if err := i.projectEnvDefaults(cmd); err != nil {
+		return failure.Wrap(err, "i.projectEnvDefaults failed")
+	}
+
 	if err := Process(cmd, i.Viper, c, i.Prefix...); err != nil {
 		return failure.Wrap(err, "Process failed for (DeployConfig)")
 	}
 
+	if err := i.targetAccount(cmd, c); err != nil {
+		return failure.Wrap(err, "i.targetAccount failed")
+	}
+
+	if err := i.singleEnv(cmd, c); err != nil {
+		return failure.Wrap(err, "i.singleEnv failed")
+	}
+	i.readOnly(c)
+
+	if err := i.outputFormat(c); err != nil {
+		return failure.Wrap(err, "i.outputFormat failed")
+	}
+
 	return nil
 }
 
@@ -228,67 +807,72 @@ func Bind(c *cobra.Command, v *viper.Viper, config interface{}) error {
 	return nil
 }
 
+// CheckFailure prints err and exits with the code of its category, it is
+// meant for main only, commands return their errors through RunE
 func (i *Infra) CheckFailure(err error) {
 	if err == nil {
 		return
 	}
 
-	if _, fErr := fmt.Fprintf(i.Stderr, "[infra] %+v\n", err); fErr != nil {
-		fmt.Println("[infra:CheckFailure]:fmt.Fprintf failed:", fErr.Error(), ":", err.Error())
-	}
-	os.Exit(1)
+	os.Exit(i.HandleError(nil, err))
 }
 
-func (i *Infra) WriteJson(p string, d interface{}) {
+func (i *Infra) WriteJson(p string, d interface{}) error {
 	data, err := json.Marshal(d)
 	if err != nil {
-		i.CheckFailure(failure.ToSystem(err, "json.Marshal failed in WriteJson"))
+		return failure.ToSystem(err, "json.Marshal failed in WriteJson")
 	}
 
 	fp := Filepath{}
 	if err = fp.Decode(p); err != nil {
-		i.CheckFailure(failure.ToSystem(err, "fp.Decode failed (%s) in WriteJson", p))
+		return failure.ToSystem(err, "fp.Decode failed (%s) in WriteJson", p)
 	}
 
 	file, err := os.Create(fp.Path)
 	if err != nil {
-		i.CheckFailure(failure.ToSystem(err, "os.Create failed (%s) in WriteJson", p))
+		return failure.ToSystem(err, "os.Create failed (%s) in WriteJson", p)
 	}
 	defer func() { _ = file.Close() }()
 
 	if _, err = file.Write(data); err != nil {
-		i.CheckFailure(failure.ToSystem(err, "file.Write failed (%s) in WriteJSON", p))
+		return failure.ToSystem(err, "file.Write failed (%s) in WriteJSON", p)
 	}
+
+	return nil
 }
 
-func (i *Infra) DisplayJson(d interface{}) {
+func (i *Infra) DisplayJson(d interface{}) error {
 	data, err := json.Marshal(d)
-	i.CheckFailure(err)
+	if err != nil {
+		return failure.ToSystem(err, "json.Marshal failed in DisplayJson")
+	}
 
-	i.Display(string(data))
-	return
+	return i.Display(string(data))
 }
 
-func (i *Infra) DisplayErrorJson(d interface{}) {
+func (i *Infra) DisplayErrorJson(d interface{}) error {
 	data, err := json.Marshal(d)
-	i.CheckFailure(err)
+	if err != nil {
+		return failure.ToSystem(err, "json.Marshal failed in DisplayErrorJson")
+	}
 
-	i.DisplayError(string(data))
-	return
+	return i.DisplayError(string(data))
 }
 
-func (i *Infra) DisplayError(data string) {
-	_, err := fmt.Fprintf(i.Stderr, data)
-	i.CheckFailure(err)
+func (i *Infra) DisplayError(data string) error {
+	if _, err := fmt.Fprint(i.Stderr, data); err != nil {
+		return failure.ToSystem(err, "fmt.Fprint failed for i.Stderr")
+	}
 
-	return
+	return nil
 }
 
-func (i *Infra) Display(data string) {
-	_, err := fmt.Fprintf(i.Stdout, data)
-	i.CheckFailure(err)
+func (i *Infra) Display(data string) error {
+	if _, err := fmt.Fprint(i.Stdout, data); err != nil {
+		return failure.ToSystem(err, "fmt.Fprint failed for i.Stdout")
+	}
 
-	return
+	return nil
 }
 
 // Filepath is used as a custom decoder which will take a configuration string
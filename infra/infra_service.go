@@ -0,0 +1,231 @@
+package infra
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/cwlogs"
+	"github.com/rsb/sls/lambda"
+)
+
+// InfraService runs the infra operations without cobra or viper, so tools
+// like bots, ci scripts or a tui drive the same code as the cli. The aws
+// clients are the ones of Infra and Config plays the role of the global
+// flags, the service is loaded once for the env of Config.
+type InfraService struct {
+	Infra   *Infra
+	Config  CmdConfig
+	Service *sls.MicroService
+}
+
+// NewInfraService loads the service with in.Service or in.ServiceConstructor,
+// in.ParentCmd and in.Viper are not required
+func NewInfraService(in *Infra, config CmdConfig) (*InfraService, error) {
+	if in == nil {
+		return nil, failure.InvalidParam("in is nil, Infra is required")
+	}
+
+	if in.Service == nil && in.ServiceConstructor == nil {
+		return nil, failure.InvalidParam("in.Service and in.ServiceConstructor are nil, one is required")
+	}
+
+	in.initDefaults()
+	in.readOnly(config)
+
+	service := in.Service
+	if service == nil {
+		var err error
+		if service, err = in.LoadService(config); err != nil {
+			return nil, failure.Wrap(err, "in.LoadService failed")
+		}
+	}
+
+	return &InfraService{Infra: in, Config: config, Service: service}, nil
+}
+
+// Feature finds a feature of the service by name, with the trigger when
+// Config names features with it
+func (s *InfraService) Feature(name string) (sls.Feature, error) {
+	feature, err := s.Service.Feature(name)
+	if err == nil || !s.Config.NameIncludesTrigger() {
+		return feature, err
+	}
+
+	for _, f := range s.Service.Features {
+		if f.NameWithTrigger() == name {
+			return f, nil
+		}
+	}
+
+	return feature, failure.NotFound("feature (%s)", name)
+}
+
+// FeatureNames are the names of the features of the service, sorted
+func (s *InfraService) FeatureNames() []string {
+	names := make([]string, 0, len(s.Service.Features))
+	for title, feature := range s.Service.Features {
+		names = append(names, featureCompletionName(title, feature, s.Config))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FeatureEnv resolves the env vars deploy would give the feature, with the
+// source of every value
+func (s *InfraService) FeatureEnv(ctx context.Context, name string) (EnvResolution, error) {
+	feature, err := s.Feature(name)
+	if err != nil {
+		return EnvResolution{}, failure.Wrap(err, "s.Feature failed")
+	}
+
+	resolver := s.Infra.NewEnvResolver(s.Service.Name.AppTitle(), s.Config, DefaultEnvStages...)
+	resolution, err := resolver.Resolve(ctx, feature)
+	if err != nil {
+		return resolution, failure.Wrap(err, "resolver.Resolve failed")
+	}
+
+	return resolution, nil
+}
+
+// TailLogs is `logs <FEATURE>`, fn is called with every event until ctx is
+// done or, without IsFollow, the events since in.Since are read
+func (s *InfraService) TailLogs(ctx context.Context, name string, in cwlogs.TailSettings, fn func(e cwlogs.LogEvent) error) error {
+	api, ok := s.Infra.LogsAPI.(LogTailing)
+	if !ok {
+		return failure.System("s.Infra.LogsAPI is not initialized or does not implement LogTailing")
+	}
+
+	feature, err := s.Feature(name)
+	if err != nil {
+		return failure.Wrap(err, "s.Feature failed")
+	}
+
+	in.Group = cwlogs.FeatureLogGroup(feature.QualifiedName)
+	if err = api.Tail(ctx, in, fn); err != nil {
+		return failure.Wrap(err, "api.Tail failed (%s)", in.Group)
+	}
+
+	return nil
+}
+
+func (s *InfraService) pstore() error {
+	if s.Infra.PStoreAPI == nil {
+		return failure.System("s.Infra.PStoreAPI is not initialized")
+	}
+	return nil
+}
+
+// ImportParams is `pstore import`, keys without the app title get it
+func (s *InfraService) ImportParams(ctx context.Context, params map[string]string, overwrite bool) (ImportReport, error) {
+	if err := s.pstore(); err != nil {
+		return ImportReport{}, err
+	}
+
+	report := s.Infra.ImportParams(ctx, s.Service.Name.AppTitle(), params, overwrite)
+	if err := report.Results.Err(); err != nil {
+		return report, failure.Wrap(err, "(%d) params failed to import", len(report.Results.Failed()))
+	}
+
+	return report, nil
+}
+
+// PlanImport is `pstore import --dry-run`, nothing is written
+func (s *InfraService) PlanImport(ctx context.Context, params map[string]string, overwrite bool) (ImportPlan, error) {
+	if err := s.pstore(); err != nil {
+		return ImportPlan{}, err
+	}
+
+	return s.Infra.PlanImport(ctx, s.Service.Name.AppTitle(), params, overwrite)
+}
+
+// ExportParams is `pstore export`, an empty feature exports the whole
+// service
+func (s *InfraService) ExportParams(ctx context.Context, feature string) (map[string]string, error) {
+	if err := s.pstore(); err != nil {
+		return nil, err
+	}
+
+	if feature == "" {
+		return s.Infra.ExportParams(ctx, s.Service, nil)
+	}
+
+	f, err := s.Feature(feature)
+	if err != nil {
+		return nil, failure.Wrap(err, "s.Feature failed")
+	}
+
+	return s.Infra.ExportParams(ctx, s.Service, &f)
+}
+
+// DefaultDeployBind has the defaults of the deploy flags, the starting point
+// of the options given to DeployFeature and DeployAll
+func DefaultDeployBind() DeployBind {
+	return DeployBind{
+		LogRetention: cwlogs.DefaultRetentionDays,
+		Concurrency:  4,
+		Bake:         5 * time.Minute,
+		Alias:        lambda.LiveAlias,
+	}
+}
+
+// DeployFeature is `deploy <FEATURE>`, the api gateway stage is redeployed
+// when b names a rest api
+func (s *InfraService) DeployFeature(ctx context.Context, name string, b DeployBind) (FeatureDeployResult, error) {
+	result := FeatureDeployResult{Feature: name}
+	if err := b.Validate(); err != nil {
+		return result, failure.Wrap(err, "b.Validate failed")
+	}
+
+	if err := s.Infra.Writable("deploy"); err != nil {
+		return result, failure.Wrap(err, "s.Infra.Writable failed")
+	}
+
+	feature, err := s.Feature(name)
+	if err != nil {
+		return result, failure.Wrap(err, "s.Feature failed")
+	}
+
+	config := DeployConfig{CmdConfig: s.Config, DeployBind: b}
+	result, err = s.Infra.DeployFeature(ctx, s.Service, feature, s.Service.NewBuildSettings(feature), config)
+	if err != nil {
+		return result, failure.Wrap(err, "s.Infra.DeployFeature failed")
+	}
+
+	if err = s.Infra.DeployStage(ctx, config, feature); err != nil {
+		return result, failure.Wrap(err, "s.Infra.DeployStage failed")
+	}
+
+	return result, nil
+}
+
+// DeployAll is `deploy --all`, the report has every feature even when some
+// of them failed
+func (s *InfraService) DeployAll(ctx context.Context, b DeployBind) (DeployAllReport, error) {
+	if err := b.Validate(); err != nil {
+		return DeployAllReport{}, failure.Wrap(err, "b.Validate failed")
+	}
+
+	if err := s.Infra.Writable("deploy"); err != nil {
+		return DeployAllReport{}, failure.Wrap(err, "s.Infra.Writable failed")
+	}
+
+	config := DeployConfig{CmdConfig: s.Config, DeployBind: b}
+	report := s.Infra.DeployAll(ctx, s.Service, config)
+	if err := report.Summary.Err(); err != nil {
+		return report, failure.Wrap(err, "(%d) features failed to deploy", len(report.Failed))
+	}
+
+	features := make([]sls.Feature, 0, len(s.Service.Features))
+	for _, f := range s.Service.Features {
+		features = append(features, f)
+	}
+
+	if err := s.Infra.DeployStage(ctx, config, features...); err != nil {
+		return report, failure.Wrap(err, "s.Infra.DeployStage failed")
+	}
+
+	return report, nil
+}
@@ -0,0 +1,165 @@
+package infra
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/lambda"
+	"github.com/spf13/cobra"
+)
+
+func SetupInvokeCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.InvokeCmd == nil {
+		in.InvokeCmd = InvokeCmd
+	}
+	in.InvokeCmd.RunE = in.RunInvoke
+	in.ParentCmd.AddCommand(in.InvokeCmd)
+
+	var ib InvokeBind
+	if err := Bind(in.InvokeCmd, in.Viper, &ib); err != nil {
+		return failure.Wrap(err, "Bind failed for in.InvokeCmd")
+	}
+
+	return nil
+}
+
+var InvokeCmd = &cobra.Command{
+	Use:   "invoke <FEATURE>",
+	Short: "invoke a deployed lambda and print its response",
+	Args:  cobra.ExactArgs(1),
+}
+
+type InvokeBind struct {
+	Payload    Filepath `conf:"cli:payload, cli-s:p, cli-u: Json file sent as the event"`
+	Fixture    Filepath `conf:"cli:fixture, cli-u: Event fixture file sent as the event or written by --generate"`
+	IsGenerate bool     `conf:"cli:generate, cli-u: Write a skeleton event for the trigger of the feature to --fixture or stdout instead of invoking"`
+	IsAsync    bool     `conf:"cli:async, cli-u: Invoke asynchronously without waiting for the response"`
+	IsTailLogs bool     `conf:"cli:tail-logs, cli-u: Print the last 4KB of the invocation logs to stderr"`
+}
+
+type InvokeConfig struct {
+	CmdConfig
+	InvokeBind
+}
+
+// RunInvoke runs `<service> infra invoke <FEATURE>`, the response payload is
+// printed to stdout and the log tail to stderr. A function error fails the
+// command after the payload is printed. With --generate nothing is invoked,
+// a skeleton event of the trigger of the feature is written instead.
+// `<service> infra invoke <FEATURE> [--payload file.json] [--async] [--tail-logs]`
+// `<service> infra invoke <FEATURE> --fixture payload.json --generate`
+func (i *Infra) RunInvoke(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	if i.LambdaAPI == nil {
+		return failure.System("i.LambdaAPI is not initialized")
+	}
+
+	var config InvokeConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	in := lambda.InvokePayload{
+		QualifiedName: feature.QualifiedName,
+		IsAsync:       config.IsAsync,
+		IsTailLogs:    config.IsTailLogs,
+	}
+
+	if config.IsGenerate {
+		return i.GenerateFixture(feature, config.Fixture)
+	}
+
+	if !config.Payload.IsEmpty() && !config.Fixture.IsEmpty() {
+		return failure.InvalidParam("--payload and --fixture are both the event, only one can be given")
+	}
+
+	event := config.Payload
+	if !config.Fixture.IsEmpty() {
+		event = config.Fixture
+	}
+
+	if !event.IsEmpty() {
+		in.Payload, err = ioutil.ReadFile(event.Path)
+		if err != nil {
+			return failure.ToSystem(err, "ioutil.ReadFile failed (%s)", event.Path)
+		}
+	}
+
+	// the feature can write anything, invoking it is not an inspection
+	if err = i.Writable("invoke"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	defer i.Step("invoke")()
+	report, err := i.LambdaAPI.Invoke(ctx, in)
+	if err != nil {
+		return failure.Wrap(err, "i.LambdaAPI.Invoke failed")
+	}
+
+	if report.LogTail != "" {
+		_, _ = fmt.Fprint(i.Stderr, report.LogTail)
+	}
+
+	if config.CmdConfig.Verbose {
+		if err := i.DisplayFormatted(report); err != nil {
+			return failure.Wrap(err, "i.DisplayFormatted failed")
+		}
+	} else if len(report.Payload) > 0 {
+		_, _ = fmt.Fprintln(i.Stdout, string(report.Payload))
+	}
+
+	if report.IsFunctionError() {
+		return failure.System("feature (%s) returned a function error (%s)", feature.Name, report.FunctionError)
+	}
+
+	return nil
+}
+
+// GenerateFixture writes the sls.EventFixture of the trigger of the feature
+// to fixture, or to stdout when it is empty. An existing fixture is never
+// overwritten.
+func (i *Infra) GenerateFixture(feature sls.Feature, fixture Filepath) error {
+	event, err := sls.EventFixture(feature.Trigger, feature.Name)
+	if err != nil {
+		return failure.Wrap(err, "sls.EventFixture failed (%s)", feature.Name)
+	}
+
+	data, err := json.MarshalIndent(event, "", "  ")
+	if err != nil {
+		return failure.ToSystem(err, "json.MarshalIndent failed")
+	}
+	data = append(data, '\n')
+
+	if fixture.IsEmpty() {
+		return i.Display(string(data))
+	}
+
+	if _, err = os.Stat(fixture.Path); err == nil {
+		return failure.AlreadyExists("fixture (%s) exists, remove it to generate a new one", fixture.Path)
+	}
+
+	if err = os.WriteFile(fixture.Path, data, 0o644); err != nil {
+		return failure.ToSystem(err, "os.WriteFile failed (%s)", fixture.Path)
+	}
+
+	return i.DisplayError(fmt.Sprintf("wrote the (%s) event fixture of (%s) to (%s)\n", feature.Trigger, feature.Name, fixture.Path))
+}
@@ -0,0 +1,178 @@
+package infra
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/lambda"
+	"github.com/spf13/cobra"
+)
+
+func SetupLayerCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.LayerCmd == nil {
+		in.LayerCmd = LayerCmd
+	}
+	in.ParentCmd.AddCommand(in.LayerCmd)
+
+	if in.LayerPublishCmd == nil {
+		in.LayerPublishCmd = LayerPublishCmd
+	}
+	in.LayerPublishCmd.RunE = in.RunLayerPublish
+	in.LayerCmd.AddCommand(in.LayerPublishCmd)
+
+	var pb LayerPublishBind
+	if err := Bind(in.LayerPublishCmd, in.Viper, &pb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.LayerPublishCmd")
+	}
+
+	return nil
+}
+
+var LayerCmd = &cobra.Command{
+	Use:   "layer",
+	Short: "publish lambda layers shared by the features",
+}
+
+var LayerPublishCmd = &cobra.Command{
+	Use:   "publish <NAME>",
+	Short: "publish a zip as a new version of a layer and attach it to the features",
+	Args:  cobra.ExactArgs(1),
+}
+
+type LayerPublishBind struct {
+	Zip      Filepath `conf:"cli:zip, cli-u: Zip of the layer (its content is extracted into /opt)"`
+	Runtimes []string `conf:"default:provided.al2023, cli:runtimes, cli-u: Comma separated runtimes the layer is compatible with"`
+	IsAttach bool     `conf:"cli:attach, cli-u: Attach the new version to every feature of the service"`
+}
+
+type LayerPublishConfig struct {
+	CmdConfig
+	LayerPublishBind
+}
+
+// LayerPublishReport is the published version and the functions it was
+// attached to, the ones that already had it are left out
+type LayerPublishReport struct {
+	Layer    *lambda.LayerReport `json:"layer"`
+	Attached []string            `json:"attached"`
+}
+
+func (r LayerPublishReport) TableRows() ([]string, [][]string) {
+	header := []string{"LAYER", "VERSION", "CODE SHA256", "ATTACHED TO"}
+	version := strconv.FormatInt(r.Layer.Version, 10)
+	if len(r.Attached) == 0 {
+		return header, [][]string{{r.Layer.Name, version, r.Layer.CodeSHA256, ""}}
+	}
+
+	rows := make([][]string, 0, len(r.Attached))
+	for _, name := range r.Attached {
+		rows = append(rows, []string{r.Layer.Name, version, r.Layer.CodeSHA256, name})
+	}
+
+	return header, rows
+}
+
+// RunLayerPublish runs `<service> infra layer publish <NAME>` which publishes
+// the zip as a new version of the layer. With --attach every feature of the
+// service is moved to the new version, an older version of the layer is
+// replaced and the other layers of the feature are kept.
+// `<service> infra layer publish <NAME> --zip certs.zip [--runtimes provided.al2023] [--attach]`
+func (i *Infra) RunLayerPublish(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	api, ok := i.LambdaAPI.(LambdaLayerPublishing)
+	if !ok {
+		return failure.System("i.LambdaAPI is not initialized or does not implement LambdaLayerPublishing")
+	}
+
+	var config LayerPublishConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if config.Zip.IsEmpty() {
+		return failure.InvalidParam("--zip is required")
+	}
+
+	var describer LambdaDescribing
+	if config.IsAttach {
+		if describer, ok = i.LambdaAPI.(LambdaDescribing); !ok {
+			return failure.System("i.LambdaAPI does not implement LambdaDescribing, required by --attach")
+		}
+	}
+
+	if err := i.Writable("layer publish"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	zip, err := os.ReadFile(config.Zip.Path)
+	if err != nil {
+		return failure.ToSystem(err, "os.ReadFile failed (%s)", config.Zip.Path)
+	}
+
+	name := args[0]
+	if i.DryRun("PublishLayerVersion", name, fmt.Sprintf("zip of (%d) bytes", len(zip))) {
+		return nil
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	stopStep := i.Step("layer publish")
+	layer, err := api.PublishLayer(ctx, name, zip, config.Runtimes)
+	stopStep()
+	if err != nil {
+		return failure.Wrap(err, "api.PublishLayer failed (%s)", name)
+	}
+
+	report := LayerPublishReport{Layer: layer, Attached: []string{}}
+	if !config.IsAttach {
+		return i.DisplayFormatted(report)
+	}
+
+	service, err := i.LoadService(config.CmdConfig)
+	if err != nil {
+		return failure.Wrap(err, "i.LoadService failed")
+	}
+
+	names := make([]string, 0, len(service.Features))
+	for n := range service.Features {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	defer i.Step("layer attach")()
+	for _, n := range names {
+		feature := service.Features[n]
+		info, err := describer.Function(ctx, feature.QualifiedName)
+		if failure.IsNotFound(err) {
+			_, _ = fmt.Fprintf(i.Stderr, "[infra] (%s) is not deployed, layer (%s) is not attached to it\n", feature.QualifiedName, name)
+			continue
+		}
+		if err != nil {
+			return failure.Wrap(err, "describer.Function failed (%s)", feature.QualifiedName)
+		}
+
+		layers, changed := lambda.AttachLayer(info.Layers, layer.VersionARN)
+		if !changed {
+			continue
+		}
+
+		settings := lambda.FeatureSettings{QualifiedName: feature.QualifiedName, Layers: layers}
+		if _, err = i.LambdaAPI.UpdateConfig(ctx, settings); err != nil {
+			return failure.Wrap(err, "i.LambdaAPI.UpdateConfig failed (%s), attached to (%d) features before it", feature.QualifiedName, len(report.Attached))
+		}
+		report.Attached = append(report.Attached, feature.QualifiedName)
+	}
+
+	return i.DisplayFormatted(report)
+}
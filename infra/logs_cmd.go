@@ -0,0 +1,107 @@
+package infra
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/cwlogs"
+	"github.com/spf13/cobra"
+)
+
+func SetupLogsCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.LogsCmd == nil {
+		in.LogsCmd = LogsCmd
+	}
+	in.LogsCmd.RunE = in.RunLogs
+	in.ParentCmd.AddCommand(in.LogsCmd)
+
+	var lb LogsBind
+	if err := Bind(in.LogsCmd, in.Viper, &lb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.LogsCmd")
+	}
+
+	return nil
+}
+
+var LogsCmd = &cobra.Command{
+	Use:   "logs <FEATURE>",
+	Short: "print the cloudwatch logs of a lambda",
+	Args:  cobra.ExactArgs(1),
+}
+
+type LogsBind struct {
+	IsFollow bool          `conf:"cli:follow, cli-s:f, cli-u: Keep polling for new events until interrupted"`
+	Since    time.Duration `conf:"default:10m, cli:since, cli-u: How far back to start (ex 1h or 30m)"`
+	Filter   string        `conf:"cli:filter, cli-u: Cloudwatch filter pattern the events must match"`
+}
+
+type LogsConfig struct {
+	CmdConfig
+	LogsBind
+}
+
+// RunLogs runs `<service> infra logs <FEATURE>` which prints the events of
+// the feature's log group, one per line. With --text only the message is
+// printed, otherwise each event is a json line.
+// `<service> infra logs <FEATURE> [--follow] [--since 1h] [--filter pattern]`
+func (i *Infra) RunLogs(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	api, ok := i.LogsAPI.(LogTailing)
+	if !ok {
+		return failure.System("i.LogsAPI is not initialized or does not implement LogTailing")
+	}
+
+	var config LogsConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	if config.IsFollow {
+		if err = i.ServeMetrics(ctx, config.CmdConfig); err != nil {
+			return failure.Wrap(err, "i.ServeMetrics failed")
+		}
+	}
+
+	in := cwlogs.TailSettings{
+		Group:    cwlogs.FeatureLogGroup(feature.QualifiedName),
+		Since:    config.Since,
+		Pattern:  config.Filter,
+		IsFollow: config.IsFollow,
+	}
+
+	err = api.Tail(ctx, in, func(e cwlogs.LogEvent) error {
+		if config.IsText {
+			_, err := fmt.Fprintf(i.Stdout, "%s %s", e.Timestamp.Format(time.RFC3339), e.Message)
+			return err
+		}
+
+		data, err := json.Marshal(e)
+		if err != nil {
+			return failure.ToSystem(err, "json.Marshal failed")
+		}
+		_, err = fmt.Fprintln(i.Stdout, string(data))
+		return err
+	})
+	if err != nil {
+		return failure.Wrap(err, "api.Tail failed (%s)", in.Group)
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package infra
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/cwmetrics"
+	"github.com/spf13/cobra"
+)
+
+func SetupMetricsCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.MetricsCmd == nil {
+		in.MetricsCmd = MetricsCmd
+	}
+	in.MetricsCmd.RunE = in.RunMetrics
+	in.ParentCmd.AddCommand(in.MetricsCmd)
+
+	var mb MetricsBind
+	if err := Bind(in.MetricsCmd, in.Viper, &mb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.MetricsCmd")
+	}
+
+	return nil
+}
+
+var MetricsCmd = &cobra.Command{
+	Use:   "metrics <FEATURE>",
+	Short: "show the invocations, errors, throttles, durations and concurrency of the lambda",
+	Args:  cobra.ExactArgs(1),
+}
+
+type MetricsBind struct {
+	Period         time.Duration `conf:"default:5m, cli:period, cli-u: How far back the metrics are read (ex 15m)"`
+	IsFailOnErrors bool          `conf:"cli:fail-on-errors, cli-u: Exit with an error when the period has errors or throttles"`
+}
+
+type MetricsConfig struct {
+	CmdConfig
+	MetricsBind
+}
+
+// FeatureMetricsReport is the health of the function of a feature over a
+// period. P50 and P99 are durations in milliseconds over every invocation of
+// the period, Concurrency is the peak of concurrent executions. HasData is
+// false when the function was not invoked in the period.
+type FeatureMetricsReport struct {
+	Feature     string    `json:"feature"`
+	Function    string    `json:"function"`
+	Period      string    `json:"period"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Invocations float64   `json:"invocations"`
+	Errors      float64   `json:"errors"`
+	Throttles   float64   `json:"throttles"`
+	P50         float64   `json:"p50_ms"`
+	P99         float64   `json:"p99_ms"`
+	Concurrency float64   `json:"concurrent_executions"`
+	HasData     bool      `json:"has_data"`
+}
+
+func (r FeatureMetricsReport) TableRows() ([]string, [][]string) {
+	header := []string{"FEATURE", "PERIOD", "INVOCATIONS", "ERRORS", "THROTTLES", "P50", "P99", "CONCURRENCY"}
+	count := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	ms := func(v float64) string {
+		if !r.HasData {
+			return "-"
+		}
+		return strconv.FormatFloat(v, 'f', 1, 64) + " ms"
+	}
+
+	row := []string{
+		r.Feature,
+		r.Period,
+		count(r.Invocations),
+		count(r.Errors),
+		count(r.Throttles),
+		ms(r.P50),
+		ms(r.P99),
+		count(r.Concurrency),
+	}
+
+	return header, [][]string{row}
+}
+
+// IsHealthy is whether the period has no errors and no throttles
+func (r FeatureMetricsReport) IsHealthy() bool {
+	return r.Errors == 0 && r.Throttles == 0
+}
+
+// RunMetrics runs `<service> infra metrics <FEATURE>` which shows the
+// invocations, errors, throttles, p50 and p99 duration and peak concurrent
+// executions of the lambda over the last --period. With --fail-on-errors
+// the report is still shown but any error or throttle fails the command,
+// so a deploy can be verified from a script.
+// `<service> infra metrics <FEATURE> [--period 5m] [--fail-on-errors] [--format json]`
+func (i *Infra) RunMetrics(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	if i.MetricsAPI == nil {
+		return failure.System("i.MetricsAPI is not initialized")
+	}
+
+	var config MetricsConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if config.Period < time.Minute {
+		return failure.InvalidParam("--period (%s) must be at least 1m, lambda metrics are published every minute", config.Period)
+	}
+
+	_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeature failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	defer i.Step("metrics")()
+	end := time.Now()
+	report, err := i.FeatureMetrics(ctx, feature, end.Add(-config.Period), end)
+	if err != nil {
+		return failure.Wrap(err, "i.FeatureMetrics failed (%s)", feature.Name)
+	}
+	report.Period = config.Period.String()
+
+	if err = i.DisplayFormatted(report); err != nil {
+		return failure.Wrap(err, "i.DisplayFormatted failed")
+	}
+
+	if config.IsFailOnErrors && !report.IsHealthy() {
+		return failure.InvalidState("(%s) had %.0f errors and %.0f throttles in the last %s", feature.Name, report.Errors, report.Throttles, config.Period)
+	}
+
+	return nil
+}
+
+// FeatureMetrics reads the metrics of the function of the feature between
+// start and end. The percentiles are read with one period over the whole
+// window so they cover every invocation, not the worst minute.
+func (i *Infra) FeatureMetrics(ctx context.Context, feature sls.Feature, start, end time.Time) (FeatureMetricsReport, error) {
+	name := feature.QualifiedName
+	report := FeatureMetricsReport{Feature: feature.Name, Function: name, Start: start, End: end}
+
+	for _, m := range []struct {
+		metric string
+		dst    *float64
+	}{
+		{cwmetrics.InvocationsMetric, &report.Invocations},
+		{cwmetrics.ErrorsMetric, &report.Errors},
+		{cwmetrics.ThrottlesMetric, &report.Throttles},
+	} {
+		q := cwmetrics.LambdaFunctionQuery(m.metric, name, start, end)
+		v, err := i.MetricsAPI.Sum(ctx, q)
+		if err != nil {
+			return report, failure.Wrap(err, "i.MetricsAPI.Sum failed (%s)", q.Name)
+		}
+		*m.dst = v
+	}
+
+	window := cwmetrics.LambdaFunctionQuery(cwmetrics.DurationMetric, name, start, end)
+	window.Period = (end.Sub(start)/time.Minute + 1) * time.Minute
+	for _, p := range []struct {
+		stat string
+		dst  *float64
+	}{
+		{"p50", &report.P50},
+		{"p99", &report.P99},
+	} {
+		v, ok, err := i.MetricsAPI.Peak(ctx, window, p.stat)
+		if err != nil {
+			return report, failure.Wrap(err, "i.MetricsAPI.Peak failed (%s %s)", window.Name, p.stat)
+		}
+		*p.dst = v
+		report.HasData = report.HasData || ok
+	}
+
+	q := cwmetrics.LambdaFunctionQuery(cwmetrics.ConcurrencyMetric, name, start, end)
+	v, _, err := i.MetricsAPI.Peak(ctx, q, "Maximum")
+	if err != nil {
+		return report, failure.Wrap(err, "i.MetricsAPI.Peak failed (%s)", q.Name)
+	}
+	report.Concurrency = v
+
+	return report, nil
+}
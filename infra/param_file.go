@@ -0,0 +1,127 @@
+package infra
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/dotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// ParamFileFormat is the format of a file given to `pstore import --file`
+type ParamFileFormat string
+
+const (
+	JSONParamFile   ParamFileFormat = "json"
+	DotenvParamFile ParamFileFormat = "dotenv"
+	YAMLParamFile   ParamFileFormat = "yaml"
+)
+
+func ToParamFileFormat(f string) (ParamFileFormat, error) {
+	switch ParamFileFormat(strings.ToLower(f)) {
+	case JSONParamFile:
+		return JSONParamFile, nil
+	case DotenvParamFile, "env":
+		return DotenvParamFile, nil
+	case YAMLParamFile, "yml":
+		return YAMLParamFile, nil
+	default:
+		return "", failure.InvalidParam("param file format (%s) is not supported, use json or dotenv or yaml", f)
+	}
+}
+
+// DetectParamFileFormat picks the format from the name of the file, `.env`
+// and names like `prod.env` are dotenv. Without a known extension data that
+// starts with { is json, data whose first line is KEY=value is dotenv and
+// anything else is read as yaml.
+func DetectParamFileFormat(name string, data []byte) ParamFileFormat {
+	base := strings.ToLower(filepath.Base(name))
+	switch {
+	case strings.HasSuffix(base, ".json"):
+		return JSONParamFile
+	case base == ".env" || strings.HasSuffix(base, ".env") || strings.HasPrefix(base, ".env."):
+		return DotenvParamFile
+	case strings.HasSuffix(base, ".yaml") || strings.HasSuffix(base, ".yml"):
+		return YAMLParamFile
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return JSONParamFile
+	}
+
+	for _, line := range strings.Split(string(trimmed), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		eq := strings.Index(line, "=")
+		colon := strings.Index(line, ":")
+		if eq > 0 && (colon < 0 || eq < colon) {
+			return DotenvParamFile
+		}
+		break
+	}
+
+	return YAMLParamFile
+}
+
+// ParseParamFile reads the param names and values of a param file. Yaml
+// numbers and booleans are kept as they are written, nested values are
+// rejected since a param holds a single string.
+func ParseParamFile(format ParamFileFormat, data []byte) (map[string]string, error) {
+	switch format {
+	case JSONParamFile:
+		var result map[string]string
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, failure.ToValidation(err, "json.Unmarshal failed")
+		}
+		return result, nil
+	case DotenvParamFile:
+		result, err := dotenv.Unmarshal(data)
+		if err != nil {
+			return nil, failure.Wrap(err, "dotenv.Unmarshal failed")
+		}
+		return result, nil
+	case YAMLParamFile:
+		return parseYAMLParams(data)
+	default:
+		return nil, failure.InvalidParam("param file format (%s) is not supported", format)
+	}
+}
+
+func parseYAMLParams(data []byte) (map[string]string, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, failure.ToValidation(err, "yaml.Unmarshal failed")
+	}
+
+	result := make(map[string]string, len(doc))
+	var nested []string
+	for k, v := range doc {
+		switch value := v.(type) {
+		case nil:
+			result[k] = ""
+		case string:
+			result[k] = value
+		case map[string]interface{}, []interface{}:
+			nested = append(nested, k)
+		default:
+			result[k] = fmt.Sprint(value)
+		}
+	}
+
+	if len(nested) > 0 {
+		sort.Strings(nested)
+		return nil, failure.Validation("yaml keys (%s) are not scalar values", strings.Join(nested, ", "))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,222 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/iam"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// ParamActions and TableActions are what a feature needs on each of its
+	// param and table dependencies
+	ParamActions = []string{"ssm:GetParameter", "ssm:GetParameters"}
+	TableActions = []string{"dynamodb:GetItem", "dynamodb:PutItem", "dynamodb:UpdateItem", "dynamodb:DeleteItem", "dynamodb:Query"}
+)
+
+func SetupPermissionsCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.PermissionsCmd == nil {
+		in.PermissionsCmd = PermissionsCmd
+	}
+	in.PermissionsCmd.RunE = in.RunPermissions
+	in.ParentCmd.AddCommand(in.PermissionsCmd)
+
+	var pb PermissionsBind
+	if err := Bind(in.PermissionsCmd, in.Viper, &pb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.PermissionsCmd")
+	}
+
+	return nil
+}
+
+var PermissionsCmd = &cobra.Command{
+	Use:   "permissions <FEATURE>",
+	Short: "show the policies of the lambda execution role and the permissions it is missing",
+	Args:  cobra.ExactArgs(1),
+}
+
+type PermissionsBind struct {
+	IsMissingOnly bool `conf:"cli:missing-only, cli-u: Only show the permissions the feature is missing"`
+}
+
+type PermissionsConfig struct {
+	CmdConfig
+	PermissionsBind
+}
+
+// PermissionCheck is whether the role allows Actions on the Resource of a
+// dependency, Missing are the actions it does not allow
+type PermissionCheck struct {
+	Dependency string   `json:"dependency"`
+	Resource   string   `json:"resource"`
+	Actions    []string `json:"actions"`
+	Missing    []string `json:"missing,omitempty"`
+}
+
+type PermissionsReport struct {
+	Feature  string             `json:"feature"`
+	Role     string             `json:"role"`
+	Policies []iam.PolicyReport `json:"policies,omitempty"`
+	Checks   []PermissionCheck  `json:"checks"`
+}
+
+func (r PermissionsReport) Missing() []PermissionCheck {
+	var result []PermissionCheck
+	for _, c := range r.Checks {
+		if len(c.Missing) > 0 {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (r PermissionsReport) TableRows() ([]string, [][]string) {
+	header := []string{"SOURCE", "EFFECT", "ACTION", "RESOURCE"}
+	var rows [][]string
+	for _, p := range r.Policies {
+		for _, s := range p.Statements {
+			rows = append(rows, []string{p.Name, s.Effect, listCell(s.Action, s.NotAction), listCell(s.Resource, s.NotResource)})
+		}
+	}
+
+	for _, c := range r.Missing() {
+		rows = append(rows, []string{c.Dependency, "MISSING", strings.Join(c.Missing, " "), c.Resource})
+	}
+
+	return header, rows
+}
+
+func listCell(list, not []string) string {
+	if len(not) > 0 {
+		return "not " + strings.Join(not, " ")
+	}
+	return strings.Join(list, " ")
+}
+
+// RunPermissions runs `<service> infra permissions <FEATURE>` which resolves
+// the execution role of the lambda and shows the statements of its inline
+// and attached policies. The params and tables the feature depends on are
+// checked against them and the actions the role does not allow are flagged.
+// Conditions are not evaluated.
+// `<service> infra permissions <FEATURE> [--missing-only] [--format table]`
+func (i *Infra) RunPermissions(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	api, ok := i.LambdaAPI.(LambdaRoleReading)
+	if !ok {
+		return failure.System("i.LambdaAPI is not initialized or does not implement LambdaRoleReading")
+	}
+
+	if i.IAMAPI == nil {
+		return failure.System("i.IAMAPI is not initialized")
+	}
+
+	var config PermissionsConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	service, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	defer i.Step("permissions")()
+	report, err := i.FeaturePermissions(ctx, api, service, feature)
+	if err != nil {
+		return failure.Wrap(err, "i.FeaturePermissions failed")
+	}
+
+	if config.IsMissingOnly {
+		report.Policies = nil
+		report.Checks = report.Missing()
+	}
+
+	return i.DisplayFormatted(report)
+}
+
+// FeaturePermissions reads the policies of the execution role of the
+// feature and checks them against its dependencies
+func (i *Infra) FeaturePermissions(ctx context.Context, api LambdaRoleReading, service *sls.MicroService, feature sls.Feature) (PermissionsReport, error) {
+	report := PermissionsReport{Feature: feature.Name}
+	role, err := api.ExecutionRole(ctx, feature.QualifiedName)
+	if err != nil {
+		return report, failure.Wrap(err, "api.ExecutionRole failed")
+	}
+	report.Role = role.RoleARN
+
+	roleName, err := iam.RoleName(role.RoleARN)
+	if err != nil {
+		return report, failure.Wrap(err, "iam.RoleName failed")
+	}
+
+	if report.Policies, err = i.IAMAPI.RolePolicies(ctx, roleName); err != nil {
+		return report, failure.Wrap(err, "i.IAMAPI.RolePolicies failed (%s)", roleName)
+	}
+
+	checks, err := RequiredPermissions(service, feature, role.FunctionARN)
+	if err != nil {
+		return report, failure.Wrap(err, "RequiredPermissions failed")
+	}
+
+	statements := iam.Statements(report.Policies)
+	for idx, c := range checks {
+		for _, action := range c.Actions {
+			if !iam.IsAllowed(statements, action, c.Resource) {
+				checks[idx].Missing = append(checks[idx].Missing, action)
+			}
+		}
+	}
+	report.Checks = checks
+
+	return report, nil
+}
+
+// RequiredPermissions are the ssm actions on every param of the feature and
+// the dynamodb actions on every table it depends on, the resources are in
+// the region and account of functionARN
+func RequiredPermissions(service *sls.MicroService, feature sls.Feature, functionARN string) ([]PermissionCheck, error) {
+	parts := strings.Split(functionARN, ":")
+	if len(parts) < 6 || parts[2] != "lambda" {
+		return nil, failure.InvalidParam("(%s) is not the arn of a lambda function", functionARN)
+	}
+	region, account := sls.Region(parts[3]), parts[4]
+
+	deps, err := feature.AllDependencies()
+	if err != nil {
+		return nil, failure.Wrap(err, "feature.AllDependencies failed")
+	}
+
+	appTitle := service.Name.AppTitle()
+	seen := map[string]bool{}
+	var checks []PermissionCheck
+	for _, d := range deps {
+		if seen[d.ID()] {
+			continue
+		}
+		seen[d.ID()] = true
+
+		switch d.Kind {
+		case sls.ParamDependency:
+			key := fmt.Sprintf("/%s/%s", appTitle, d.Name)
+			checks = append(checks, PermissionCheck{Dependency: d.ID(), Resource: region.ARN("ssm", account, "parameter"+key), Actions: ParamActions})
+		case sls.TableDependency:
+			checks = append(checks, PermissionCheck{Dependency: d.ID(), Resource: region.ARN("dynamodb", account, "table/"+d.Name), Actions: TableActions})
+		}
+	}
+
+	return checks, nil
+}
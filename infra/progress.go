@@ -0,0 +1,157 @@
+package infra
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProgressReporting is implemented by anything that shows how far a long
+// operation is, like TerminalProgress. total is how many items the task
+// has, zero when it is not known up front.
+type ProgressReporting interface {
+	Start(task string, total int) Progress
+}
+
+// Progress is one running task, Add is safe to call from many goroutines
+type Progress interface {
+	// Add marks n more items as done, item names the last one
+	Add(n int, item string)
+	Done()
+}
+
+// NopProgress reports nothing, it is used when stderr is not a terminal so
+// ci logs are not filled with status lines
+type NopProgress struct{}
+
+func (NopProgress) Start(string, int) Progress { return nopTask{} }
+
+type nopTask struct{}
+
+func (nopTask) Add(int, string) {}
+func (nopTask) Done()           {}
+
+// TerminalProgress redraws one status line on W, a percentage when the total
+// is known and a spinner otherwise. Redraws are at most every Interval.
+type TerminalProgress struct {
+	W        io.Writer
+	Interval time.Duration
+}
+
+func NewTerminalProgress(w io.Writer) *TerminalProgress {
+	return &TerminalProgress{W: w, Interval: 100 * time.Millisecond}
+}
+
+func (p *TerminalProgress) Start(task string, total int) Progress {
+	t := &terminalTask{
+		w:        p.W,
+		interval: p.Interval,
+		task:     task,
+		total:    total,
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+type terminalTask struct {
+	w        io.Writer
+	interval time.Duration
+	task     string
+	total    int
+
+	mu    sync.Mutex
+	done  int
+	item  string
+	frame int
+	width int
+
+	once    sync.Once
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func (t *terminalTask) Add(n int, item string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.done += n
+	t.item = item
+}
+
+// Done clears the status line, it is safe to call more than once
+func (t *terminalTask) Done() {
+	t.once.Do(func() {
+		close(t.stop)
+		<-t.stopped
+	})
+}
+
+func (t *terminalTask) run() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.draw()
+		case <-t.stop:
+			t.mu.Lock()
+			_, _ = fmt.Fprintf(t.w, "\r%s\r", strings.Repeat(" ", t.width))
+			t.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (t *terminalTask) draw() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var line string
+	if t.total > 0 {
+		line = fmt.Sprintf("[%s] %d/%d (%d%%) %s", t.task, t.done, t.total, t.done*100/t.total, t.item)
+	} else {
+		line = fmt.Sprintf("[%s] %s %d %s", t.task, spinnerFrames[t.frame%len(spinnerFrames)], t.done, t.item)
+		t.frame++
+	}
+
+	// pad over what is left of a longer previous line
+	pad := ""
+	if n := t.width - len(line); n > 0 {
+		pad = strings.Repeat(" ", n)
+	}
+	t.width = len(line)
+
+	_, _ = fmt.Fprintf(t.w, "\r%s%s", line, pad)
+}
+
+// isTerminal is true when w is a character device, like an interactive
+// shell, and false for pipes and files
+func isTerminal(w interface{}) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// StartProgress starts a task on i.Progress, NopProgress when none is set
+func (i *Infra) StartProgress(task string, total int) Progress {
+	if i.Progress == nil {
+		return NopProgress{}.Start(task, total)
+	}
+	return i.Progress.Start(task, total)
+}
@@ -0,0 +1,166 @@
+package infra
+
+import (
+	"os"
+	"strings"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/spf13/cobra"
+)
+
+// initProject uses the project file, sls.yaml or .slsrc, found from the
+// working dir when i.Project is not set. Its defaults are merged into viper
+// and, without a ServiceConstructor, the service is built from it.
+func (i *Infra) initProject() error {
+	if i.Project == nil {
+		wd, err := os.Getwd()
+		if err != nil {
+			return failure.ToSystem(err, "os.Getwd failed")
+		}
+
+		path, err := sls.FindProjectFile(wd)
+		if err != nil {
+			return failure.Wrap(err, "sls.FindProjectFile failed (%s)", wd)
+		}
+		if path == "" {
+			return nil
+		}
+
+		if i.Project, err = sls.LoadProjectConfig(path); err != nil {
+			return failure.Wrap(err, "sls.LoadProjectConfig failed")
+		}
+	}
+
+	if i.ServiceConstructor == nil {
+		i.ServiceConstructor = i.ProjectServiceConstructor
+	}
+
+	if err := i.mergeProject(""); err != nil {
+		return failure.Wrap(err, "i.mergeProject failed")
+	}
+
+	return nil
+}
+
+// ProjectServiceConstructor builds the service of i.Project in the env of
+// config and runs i.ServiceSetup on it, ex to set the Conf of each feature
+func (i *Infra) ProjectServiceConstructor(config EnvIdentity) (*sls.MicroService, error) {
+	if i.Project == nil {
+		return nil, failure.System("i.Project is nil, no sls.yaml or .slsrc was found")
+	}
+
+	return i.projectService(i.Project, config)
+}
+
+func (i *Infra) projectService(project *sls.ProjectConfig, config EnvIdentity) (*sls.MicroService, error) {
+	service, err := project.NewMicroService(config.EnvName())
+	if err != nil {
+		return nil, failure.Wrap(err, "project.NewMicroService failed")
+	}
+
+	if i.ServiceSetup != nil {
+		if err = i.ServiceSetup(service); err != nil {
+			return nil, failure.Wrap(err, "i.ServiceSetup failed (%s)", service.Name.AppTitle())
+		}
+	}
+
+	return service, nil
+}
+
+// projectEnvDefaults merges the project defaults of the env the command
+// runs in, read the way conf reads --env, before the command is processed.
+// Nothing is merged when several envs are given.
+func (i *Infra) projectEnvDefaults(cmd *cobra.Command) error {
+	if i.Project == nil || len(i.Project.Envs) == 0 {
+		return nil
+	}
+
+	env := i.peekFlag(cmd, "env", "ENV")
+	if env == "" || strings.Contains(env, ",") {
+		return nil
+	}
+
+	return i.mergeProject(env)
+}
+
+// peekFlag reads a CmdConfig flag before the command is processed, in the
+// order conf does: the flag, the env var and then the viper config
+func (i *Infra) peekFlag(cmd *cobra.Command, flag, env string) string {
+	if cmd != nil {
+		if f := cmd.Flags().Lookup(flag); f != nil && f.Changed {
+			return f.Value.String()
+		}
+	}
+
+	if len(i.Prefix) > 0 && i.Prefix[0] != "" {
+		env = i.Prefix[0] + "_" + env
+	}
+	if value, ok := os.LookupEnv(env); ok {
+		return value
+	}
+
+	if i.Viper == nil {
+		return ""
+	}
+	return i.Viper.GetString("cmdconfig." + flag)
+}
+
+// mergeProject merges the project defaults of env into viper. A key the
+// viper config already has is kept unless the project set it, so a config
+// file of the user always wins over the project file.
+func (i *Infra) mergeProject(env string) error {
+	if i.projectKeys == nil {
+		i.projectKeys = map[string]bool{}
+	}
+
+	merge := map[string]interface{}{}
+	for key, value := range flattenValues("", i.Project.EnvDefaults(env)) {
+		if i.Viper.InConfig(key) && !i.projectKeys[key] {
+			continue
+		}
+		i.projectKeys[key] = true
+
+		parent := merge
+		parts := strings.Split(key, ".")
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := parent[part].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				parent[part] = next
+			}
+			parent = next
+		}
+		parent[parts[len(parts)-1]] = value
+	}
+
+	if len(merge) == 0 {
+		return nil
+	}
+
+	if err := i.Viper.MergeConfigMap(merge); err != nil {
+		return failure.ToConfig(err, "i.Viper.MergeConfigMap failed for the project file")
+	}
+
+	return nil
+}
+
+func flattenValues(prefix string, values map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for k, v := range values {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+
+		if sub, ok := v.(map[string]interface{}); ok {
+			for sk, sv := range flattenValues(key, sub) {
+				result[sk] = sv
+			}
+			continue
+		}
+		result[key] = v
+	}
+
+	return result
+}
@@ -2,13 +2,17 @@ package infra
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/rsb/failure"
 	"github.com/rsb/sls"
+	"github.com/rsb/sls/pstore"
+	"github.com/rsb/sls/slsctx"
 	"github.com/spf13/cobra"
 )
 
@@ -42,6 +46,36 @@ func SetupParamStoreCmd(in *Infra) error {
 	in.PStoreDeleteCmd.RunE = in.RunPStoreDelete
 	in.PStoreCmd.AddCommand(in.PStoreDeleteCmd)
 
+	if in.PStoreDiffCmd == nil {
+		in.PStoreDiffCmd = PStoreDiffCmd
+	}
+	in.PStoreDiffCmd.RunE = in.RunPStoreDiff
+	in.PStoreCmd.AddCommand(in.PStoreDiffCmd)
+
+	if in.PStoreCopyCmd == nil {
+		in.PStoreCopyCmd = PStoreCopyCmd
+	}
+	in.PStoreCopyCmd.RunE = in.RunPStoreCopy
+	in.PStoreCmd.AddCommand(in.PStoreCopyCmd)
+
+	if in.PStoreGetCmd == nil {
+		in.PStoreGetCmd = PStoreGetCmd
+	}
+	in.PStoreGetCmd.RunE = in.RunPStoreGet
+	in.PStoreCmd.AddCommand(in.PStoreGetCmd)
+
+	if in.PStoreMigrateCmd == nil {
+		in.PStoreMigrateCmd = PStoreMigrateCmd
+	}
+	in.PStoreMigrateCmd.RunE = in.RunPStoreMigrate
+	in.PStoreCmd.AddCommand(in.PStoreMigrateCmd)
+
+	if in.PStorePruneCmd == nil {
+		in.PStorePruneCmd = PStorePruneCmd
+	}
+	in.PStorePruneCmd.RunE = in.RunPStorePrune
+	in.PStoreCmd.AddCommand(in.PStorePruneCmd)
+
 	var pa PStoreImportBind
 	if err := Bind(in.PStoreImportCmd, in.Viper, &pa); err != nil {
 		return failure.Wrap(err, "Bind failed for in.PStoreImportCmd")
@@ -52,6 +86,31 @@ func SetupParamStoreCmd(in *Infra) error {
 		return failure.Wrap(err, "Bind failed for in.PStoreExportCmd")
 	}
 
+	var pd PStoreDiffBind
+	if err := Bind(in.PStoreDiffCmd, in.Viper, &pd); err != nil {
+		return failure.Wrap(err, "Bind failed for in.PStoreDiffCmd")
+	}
+
+	var pc PStoreCopyBind
+	if err := Bind(in.PStoreCopyCmd, in.Viper, &pc); err != nil {
+		return failure.Wrap(err, "Bind failed for in.PStoreCopyCmd")
+	}
+
+	var pg PStoreGetBind
+	if err := Bind(in.PStoreGetCmd, in.Viper, &pg); err != nil {
+		return failure.Wrap(err, "Bind failed for in.PStoreGetCmd")
+	}
+
+	var pm PStoreMigrateBind
+	if err := Bind(in.PStoreMigrateCmd, in.Viper, &pm); err != nil {
+		return failure.Wrap(err, "Bind failed for in.PStoreMigrateCmd")
+	}
+
+	var pp PStorePruneBind
+	if err := Bind(in.PStorePruneCmd, in.Viper, &pp); err != nil {
+		return failure.Wrap(err, "Bind failed for in.PStorePruneCmd")
+	}
+
 	return nil
 }
 
@@ -67,9 +126,10 @@ var PStoreImportCmd = &cobra.Command{
 }
 
 var PStoreExportCmd = &cobra.Command{
-	Use:   "export",
-	Short: "exports all params for this service",
-	Args:  cobra.MinimumNArgs(0),
+	Use:         "export",
+	Short:       "exports all params for this service",
+	Args:        cobra.MinimumNArgs(0),
+	Annotations: map[string]string{MultiEnvAnnotation: "true"},
 }
 
 var PStoreDeleteCmd = &cobra.Command{
@@ -78,6 +138,115 @@ var PStoreDeleteCmd = &cobra.Command{
 	Args:  cobra.MaximumNArgs(1),
 }
 
+var PStoreDiffCmd = &cobra.Command{
+	Use:         "diff <ENV_A> <ENV_B> | diff <FEATURE>",
+	Short:       "display params that were added, removed or changed between two envs or between pstore and a deployed feature",
+	Args:        cobra.RangeArgs(1, 2),
+	Annotations: map[string]string{MultiEnvAnnotation: "true"},
+}
+
+type PStoreDiffBind struct {
+	Feature string `conf:"cli:feature, cli-u: only compare params for the given feature"`
+}
+
+type PStoreDiffConfig struct {
+	CmdConfig
+	PStoreDiffBind
+}
+
+var PStoreCopyCmd = &cobra.Command{
+	Use:   "copy <FROM_ENV> <TO_ENV>",
+	Short: "copy all params of the service from one env to another",
+	Args:  cobra.ExactArgs(2),
+}
+
+type PStoreCopyBind struct {
+	Overwrite bool `conf:"cli:overwrite, cli-u: Used to replace values that already exist in the destination env"`
+}
+
+type PStoreCopyConfig struct {
+	CmdConfig
+	PStoreCopyBind
+}
+
+var PStoreGetCmd = &cobra.Command{
+	Use:   "get <KEY>",
+	Short: "display the value of a single param, the key is relative to the service path unless it starts with it",
+	Args:  cobra.ExactArgs(1),
+}
+
+type PStoreGetBind struct {
+	IsNoDecrypt bool `conf:"cli:no-decrypt, cli-u: Return a SecureString as its ciphertext"`
+	IsRaw       bool `conf:"cli:raw, cli-u: Print only the value so it can be used in shell substitution"`
+}
+
+type PStoreGetConfig struct {
+	CmdConfig
+	PStoreGetBind
+}
+
+var PStoreMigrateCmd = &cobra.Command{
+	Use:   "migrate <FEATURE>",
+	Short: "move the params of a feature from the service path to its own feature path",
+	Args:  cobra.ExactArgs(1),
+}
+
+type PStoreMigrateBind struct {
+	Overwrite bool `conf:"cli:overwrite, cli-u: Used to replace values that already exist under the feature path"`
+	DeleteOld bool `conf:"cli:delete-old, cli-u: Delete the service path params no other feature reads"`
+}
+
+type PStoreMigrateConfig struct {
+	CmdConfig
+	PStoreMigrateBind
+}
+
+var PStorePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "list the params under the service path no feature reads anymore, --apply deletes them",
+	Args:  cobra.NoArgs,
+}
+
+type PStorePruneBind struct {
+	IsApply bool `conf:"cli:apply, cli-u: Delete the unreferenced params instead of only listing them"`
+}
+
+type PStorePruneConfig struct {
+	CmdConfig
+	PStorePruneBind
+}
+
+// PruneReport are the params of the service path no feature references,
+// Deleted is only set with --apply
+type PruneReport struct {
+	Unreferenced []string    `json:"unreferenced"`
+	Deleted      *BulkReport `json:"deleted,omitempty"`
+}
+
+func (r PruneReport) TableRows() ([]string, [][]string) {
+	if r.Deleted != nil {
+		return r.Deleted.TableRows()
+	}
+
+	rows := make([][]string, 0, len(r.Unreferenced))
+	for _, key := range r.Unreferenced {
+		rows = append(rows, []string{key})
+	}
+
+	return []string{"UNREFERENCED"}, rows
+}
+
+// ParamMigrationReport describes a move to FeatureParamScope. Moved and
+// Skipped map the new key to the old one, Shared are old keys kept because
+// the features listed still read them.
+type ParamMigrationReport struct {
+	Moved   map[string]string   `json:"moved"`
+	Skipped map[string]string   `json:"skipped"`
+	Missing []string            `json:"missing"`
+	Deleted []string            `json:"deleted"`
+	Shared  map[string][]string `json:"shared"`
+}
+
 type PStoreDeleteBind struct {
 	IsAll     bool   `conf:"cli:all, cli-s: a, cli-u: delete all parameters for this micro-service"`
 	IsEncrypt bool   `conf:"default:true, cli:encrypt, cli-u: use encryption for pstore"`
@@ -90,20 +259,34 @@ type PStoreDeleteConfig struct {
 }
 
 type PStoreImportBind struct {
-	File          Filepath `conf:"cli:file, cli-s:f, cli-u:Importing values from a json file"`
+	File          Filepath `conf:"cli:file, cli-s:f, cli-u:Importing values from a json or .env or yaml file"`
+	FileFormat    string   `conf:"cli:file-format, cli-u: Format of --file (json or dotenv or yaml) when it can not be told from the file"`
 	ImportFromEnv bool     `conf:"cli:env, cli-u: Importing values from env vars on you machine"`
 	IsEncrypt     bool     `conf:"default:true, cli:encrypt, cli-u: use encryption for pstore"`
 	Overwrite     bool     `conf:"cli:overwrite, cli-u: Used to replace values that already exist in parameter store"`
 }
 
+// ImportPlan is what an import would do to every key. Overwritten keys
+// report their Left (before) and Right (after) values, Skipped keys exist
+// with a different value and --overwrite is not set, Unchanged keys already
+// have the imported value.
+type ImportPlan struct {
+	Create    map[string]string        `json:"create"`
+	Overwrite map[string]pstore.Change `json:"overwrite"`
+	Skipped   map[string]string        `json:"skipped"`
+	Unchanged []string                 `json:"unchanged"`
+}
+
 type PStoreExportBind struct {
 	File      Filepath `conf:"cli:file, cli-s:f, cli-u:Export to a json file"`
 	IsEncrypt bool     `conf:"default:true, cli:encrypt, cli-u: use encryption for pstore"`
 	StdOut    bool     `conf:"cli:stdout, cli-u: Importing values from env vars on you machine"`
+	IsDotenv  bool     `conf:"cli:dotenv, cli-u: Write quoted KEY=value lines that can be sourced by a shell or docker compose"`
 }
 
 type PStoreBind struct {
 	IsEncrypt bool `conf:"default:true, cli:encrypt, cli-u: use encryption for pstore"`
+	IsMeta    bool `conf:"cli:meta, cli-u: Include the version and type and last modified date of each param"`
 }
 
 type PStoreConfig struct {
@@ -133,12 +316,22 @@ func (i *Infra) RunPStore(cmd *cobra.Command, args []string) error {
 		return failure.System("i.PStoreAPI is not initialized")
 	}
 
-	ctx := context.Background()
+	ctx, stop := i.Context(cmd)
+	defer stop()
 	var config PStoreConfig
 	if err := i.Process(cmd, &config); err != nil {
 		return failure.Wrap(err, "i.Configure failed")
 	}
 
+	if config.IsMeta {
+		result, err := i.PStoreRecords(ctx, config, args)
+		if err != nil {
+			return failure.Wrap(err, "i.PStoreRecords failed")
+		}
+
+		return i.DisplayFormatted(result)
+	}
+
 	if config.CmdConfig.IsAll {
 		service, err := i.LoadService(config.CmdConfig)
 		if err != nil {
@@ -150,8 +343,7 @@ func (i *Infra) RunPStore(cmd *cobra.Command, args []string) error {
 			return failure.Wrap(err, "i.ServiceParams failed")
 		}
 
-		i.DisplayJson(result)
-		return nil
+		return i.DisplayFormatted(result)
 	}
 
 	if len(args) == 0 || args[0] == "" {
@@ -163,13 +355,12 @@ func (i *Infra) RunPStore(cmd *cobra.Command, args []string) error {
 		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
 	}
 
-	result, err := i.FeatureParams(ctx, service.Name.AppTitle(), feature)
+	result, err := i.FeatureParams(ctx, service.Name.AppTitle(), feature, ExcludeDefaults)
 	if err != nil {
 		return failure.Wrap(err, "i.FeatureParams")
 	}
 
-	i.DisplayJson(result)
-	return nil
+	return i.DisplayFormatted(result)
 }
 
 // infra env export --
@@ -179,6 +370,8 @@ func (i *Infra) RunPStore(cmd *cobra.Command, args []string) error {
 // 1) `<service> infra pstore export`
 // 2) `<service> infra pstore export [-f --file]`
 // 3) `<service> infra pstore <FEATURE> [-f --file]` - export for that feature
+// 4) `<service> infra pstore export --all --dotenv [-f .env]` - KEY=value lines
+// 5) `<service> infra pstore export --all --env qa,staging` - keyed by env
 func (i *Infra) RunPStoreExport(cmd *cobra.Command, args []string) error {
 	if err := i.Validate(); err != nil {
 		return failure.Wrap(err, "i.Validate failed")
@@ -186,62 +379,101 @@ func (i *Infra) RunPStoreExport(cmd *cobra.Command, args []string) error {
 	if i.PStoreAPI == nil {
 		return failure.System("i.PStoreAPI is not initialized")
 	}
-	ctx := context.Background()
+	ctx, stop := i.Context(cmd)
+	defer stop()
 
 	var config PStoreExportConfig
 	if err := i.Process(cmd, &config); err != nil {
 		return failure.Wrap(err, "i.Configure failed")
 	}
 
-	if config.IsAll {
-		service, err := i.LoadService(config.CmdConfig)
+	if !config.IsAll && (len(args) == 0 || args[0] == "") {
+		return failure.System("parameter name is missing")
+	}
+
+	if config.IsDotenv && len(config.EnvNames()) > 1 {
+		return failure.InvalidParam("--dotenv writes the params of one env, (%d) envs were given", len(config.EnvNames()))
+	}
+
+	result, err := i.PerEnv(config.CmdConfig, func(c CmdConfig) (interface{}, error) {
+		if config.IsAll {
+			service, err := i.LoadService(c)
+			if err != nil {
+				return nil, failure.Wrap(err, "i.LoadService failed")
+			}
+
+			return i.ExportParams(ctx, service, nil)
+		}
+
+		service, feature, err := i.LoadFeature(c, args[0])
 		if err != nil {
-			return failure.Wrap(err, "i.LoadService failed")
+			return nil, failure.Wrap(err, "i.LoadFeatureFromFirstArg")
 		}
 
-		appTitle := service.Name.AppTitle()
+		return i.ExportParams(ctx, service, &feature)
+	})
+	if err != nil {
+		return failure.Wrap(err, "i.ExportParams failed")
+	}
+
+	return i.exportParams(config, result)
+}
+
+// ExportParams reads the params of the feature, or of the whole service when
+// feature is nil, with the app title or feature title stripped from the keys
+func (i *Infra) ExportParams(ctx context.Context, service *sls.MicroService, feature *sls.Feature) (map[string]string, error) {
+	appTitle := service.Name.AppTitle()
+	if feature == nil {
 		result, err := i.ServiceParams(ctx, appTitle)
 		if err != nil {
-			return failure.Wrap(err, "i.ServiceParams failed")
+			return nil, failure.Wrap(err, "i.ServiceParams failed")
 		}
 
-		result = i.StripAppTitle(appTitle, result)
+		return i.StripAppTitle(appTitle, result), nil
+	}
+
+	result, err := i.FeatureParams(ctx, appTitle, *feature, ExcludeDefaults)
+	if err != nil {
+		return nil, failure.Wrap(err, "i.FeatureParams")
+	}
+
+	return i.StripAppTitle(feature.ParamTitle(appTitle), result), nil
+}
+
+// exportParams writes the params to --file or stdout, as json or with
+// --dotenv as KEY=value lines named after each key
+func (i *Infra) exportParams(config PStoreExportConfig, result interface{}) error {
+	if !config.IsDotenv {
 		if !config.File.IsEmpty() {
-			i.WriteJson(config.File.Path, result)
-			return nil
+			return i.WriteJson(config.File.Path, result)
 		}
 
-		i.DisplayJson(result)
-		return nil
+		return i.DisplayFormatted(result)
 	}
 
-	if len(args) == 0 || args[0] == "" {
-		return failure.System("parameter name is missing")
+	if config.File.IsEmpty() {
+		if err := (DotenvFormatter{}).Format(i.Stdout, result); err != nil {
+			return failure.Wrap(err, "DotenvFormatter.Format failed")
+		}
+		return nil
 	}
 
-	service, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	file, err := os.Create(config.File.Path)
 	if err != nil {
-		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+		return failure.ToSystem(err, "os.Create failed (%s)", config.File.Path)
 	}
+	defer func() { _ = file.Close() }()
 
-	appTitle := service.Name.AppTitle()
-	result, err := i.FeatureParams(ctx, appTitle, feature)
-	if err != nil {
-		return failure.Wrap(err, "i.FeatureParams")
+	if err = (DotenvFormatter{}).Format(file, result); err != nil {
+		return failure.Wrap(err, "DotenvFormatter.Format failed (%s)", config.File.Path)
 	}
 
-	result = i.StripAppTitle(appTitle, result)
-	if !config.File.IsEmpty() {
-		i.WriteJson(config.File.Path, result)
-		return nil
-	}
-
-	i.DisplayJson(result)
 	return nil
 }
 
 // RunPStoreDelete runs `<service> infra pstore delete` which will return parameter
 // values for the service or feature
+// Deleting every param of the service or a feature is confirmed unless --yes.
 // `<service> infra pstore delete <[FEATURE] | [--all]>`
 func (i *Infra) RunPStoreDelete(cmd *cobra.Command, args []string) error {
 	if err := i.Validate(); err != nil {
@@ -256,7 +488,8 @@ func (i *Infra) RunPStoreDelete(cmd *cobra.Command, args []string) error {
 		return failure.Wrap(err, "i.Configure failed")
 	}
 
-	ctx := context.Background()
+	ctx, stop := i.Context(cmd)
+	defer stop()
 
 	if config.CmdConfig.IsAll {
 		if config.Feature == "" {
@@ -265,23 +498,45 @@ func (i *Infra) RunPStoreDelete(cmd *cobra.Command, args []string) error {
 				return failure.Wrap(err, "i.LoadService failed")
 			}
 
+			prompt := fmt.Sprintf("delete every param of (%s) in env (%s)?", service.Name.AppTitle(), config.Env)
+			if err = i.RequireConfirm(config.CmdConfig, prompt); err != nil {
+				return failure.Wrap(err, "i.RequireConfirm failed")
+			}
+
 			result, err := i.DeleteAllServiceParams(ctx, service.Name.AppTitle())
 			if err != nil {
 				return failure.Wrap(err, "i.DeleteAllServiceParams failed")
 			}
 
-			i.DisplayJson(result)
+			if err := i.DisplayFormatted(result); err != nil {
+				return failure.Wrap(err, "i.DisplayFormatted failed")
+			}
+			if err = result.Err(); err != nil {
+				return failure.Wrap(err, "(%d) params failed to delete", len(result.Failed()))
+			}
 		} else {
 			service, feature, err := i.LoadFeature(config.CmdConfig, args[0])
 			if err != nil {
 				return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
 			}
+			i.WarnFeatureImpact(service, feature.Name)
+
+			prompt := fmt.Sprintf("delete every param of (%s) in env (%s)?", feature.Name, config.Env)
+			if err = i.RequireConfirm(config.CmdConfig, prompt); err != nil {
+				return failure.Wrap(err, "i.RequireConfirm failed")
+			}
+
 			result, err := i.DeleteAllFeatureParams(ctx, service.Name.AppTitle(), feature)
 			if err != nil {
 				return failure.Wrap(err, "i.DeleteAllFeatureParams failed")
 			}
 
-			i.DisplayJson(result)
+			if err := i.DisplayFormatted(result); err != nil {
+				return failure.Wrap(err, "i.DisplayFormatted failed")
+			}
+			if err = result.Err(); err != nil {
+				return failure.Wrap(err, "(%d) params failed to delete", len(result.Failed()))
+			}
 		}
 		return nil
 	}
@@ -295,13 +550,473 @@ func (i *Infra) RunPStoreDelete(cmd *cobra.Command, args []string) error {
 		return failure.Wrap(err, "i.LoadService failed")
 	}
 
+	i.WarnImpact(service, sls.Param(args[0]))
+
 	appTitle := service.Name.AppTitle()
 	result, err := i.DeleteParam(ctx, appTitle, args[0])
 
-	i.DisplayJson(result)
+	return i.DisplayFormatted(result)
+}
+
+// RunPStoreGet runs `<service> infra pstore get <KEY>` which displays a
+// single param of the service. With --raw only the value is printed, ex
+// `DB_URL=$(<service> infra pstore get db-url --raw)`
+// `<service> infra pstore get <KEY> [--no-decrypt] [--raw]`
+func (i *Infra) RunPStoreGet(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+	if i.PStoreAPI == nil {
+		return failure.System("i.PStoreAPI is not initialized")
+	}
+
+	var config PStoreGetConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	service, err := i.LoadService(config.CmdConfig)
+	if err != nil {
+		return failure.Wrap(err, "i.LoadService failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	path := i.serviceParamPath(service.Name.AppTitle(), args[0])
+	var value string
+	if config.IsNoDecrypt {
+		store, ok := i.PStoreAPI.(ParamDecryption)
+		if !ok {
+			return failure.System("i.PStoreAPI does not implement ParamDecryption, required by --no-decrypt")
+		}
+		value, err = store.ParamWithDecryption(ctx, path, false)
+	} else {
+		value, err = i.PStoreAPI.Param(ctx, path)
+	}
+	if err != nil {
+		return failure.Wrap(err, "param (%s) could not be read", path)
+	}
+
+	if config.IsRaw {
+		if _, err = fmt.Fprintln(i.Stdout, value); err != nil {
+			return failure.ToSystem(err, "fmt.Fprintln failed")
+		}
+		return nil
+	}
+
+	return i.DisplayFormatted(map[string]string{path: value})
+}
+
+// RunPStoreDiff runs `<service> infra pstore diff <ENV_A> <ENV_B>` which compares
+// the service params of two envs. Keys are reported without the app title so
+// envs with different app titles can be compared. Given a single feature it
+// compares the env vars deploy would set from pstore with the env vars of
+// the deployed function instead, see DeployedEnvDiff, once per env when
+// --env has several.
+// `<service> infra pstore diff <ENV_A> <ENV_B> [--feature]`
+// `<service> infra pstore diff <FEATURE> [--env qa,staging]`
+func (i *Infra) RunPStoreDiff(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	var config PStoreDiffConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+	if len(args) == 1 {
+		result, err := i.PerEnv(config.CmdConfig, func(c CmdConfig) (interface{}, error) {
+			service, feature, err := i.LoadFeature(c, args[0])
+			if err != nil {
+				return nil, failure.Wrap(err, "i.LoadFeature failed")
+			}
+
+			return i.DeployedEnvDiff(ctx, service.Name.AppTitle(), feature, c)
+		})
+		if err != nil {
+			return failure.Wrap(err, "i.DeployedEnvDiff failed")
+		}
+
+		return i.DisplayFormatted(result)
+	}
+
+	var paths []pstore.Path
+	var names []string
+	for _, env := range args {
+		c := config.CmdConfig
+		c.Env = env
+
+		service, err := i.LoadService(c)
+		if err != nil {
+			return failure.Wrap(err, "i.LoadService failed (%s)", env)
+		}
+
+		store, err := i.ParamStoreFor(c)
+		if err != nil {
+			return failure.Wrap(err, "i.ParamStoreFor failed (%s)", env)
+		}
+
+		if config.Feature != "" && names == nil {
+			feature, err := service.Feature(config.Feature)
+			if err != nil {
+				return failure.Wrap(err, "service.Feature failed (%s)", config.Feature)
+			}
+
+			names, err = feature.Conf.EnvNames()
+			if err != nil {
+				return failure.Wrap(err, "feature.Conf.EnvNames failed (%s)", config.Feature)
+			}
+
+			// diff keys are relative to the app title
+			if feature.IsFeatureScoped() {
+				for idx, n := range names {
+					names[idx] = feature.Name + "/" + n
+				}
+			}
+		}
+
+		paths = append(paths, pstore.Path{Store: store, Name: service.Name.AppTitle()})
+	}
+
+	result, err := pstore.Diff(ctx, paths[0], paths[1])
+	if err != nil {
+		return failure.Wrap(err, "pstore.Diff failed")
+	}
+
+	if names != nil {
+		result = filterDiff(result, names)
+	}
+
+	return i.DisplayFormatted(result)
+}
+
+// DeployedEnvDiff compares, by env var name, the vars a deploy would set from
+// the defaults and pstore (Left) with the environment of the deployed
+// function (Right). Vars only in the function are Added, vars it is missing
+// are Removed. The SLS_* vars set by deploy itself are ignored. A drift
+// usually means a param changed without a `deploy --env-only`.
+func (i *Infra) DeployedEnvDiff(ctx context.Context, appTitle string, feature sls.Feature, c CmdConfig) (pstore.DiffResult, error) {
+	var result pstore.DiffResult
+	api, ok := i.LambdaAPI.(LambdaInspection)
+	if !ok {
+		return result, failure.System("i.LambdaAPI is not initialized or does not implement LambdaInspection")
+	}
+
+	if i.PStoreAPI == nil {
+		return result, failure.System("i.PStoreAPI is not initialized")
+	}
+
+	resolution, err := i.NewEnvResolver(appTitle, c, PStoreStage).Resolve(ctx, feature)
+	if err != nil {
+		return result, failure.Wrap(err, "resolver.Resolve failed for (%s)", feature.Name)
+	}
+
+	deployed, err := DeployedEnv(ctx, api, feature, resolution.Names())
+	if err != nil {
+		return result, failure.Wrap(err, "DeployedEnv failed")
+	}
+
+	return pstore.DiffMaps(resolution.Map(), deployed), nil
+}
+
+// DeployedEnv is the env of the deployed function without the SLS_* vars
+// deploy sets itself, unless they are in declared
+func DeployedEnv(ctx context.Context, api LambdaInspection, feature sls.Feature, declared []string) (map[string]string, error) {
+	deployed, err := api.EnvVars(ctx, feature.QualifiedName)
+	if err != nil {
+		return nil, failure.Wrap(err, "api.EnvVars failed (%s)", feature.QualifiedName)
+	}
+
+	keep := map[string]bool{}
+	for _, name := range declared {
+		keep[name] = true
+	}
+
+	// a version is set so every metadata var is listed
+	meta := slsctx.Metadata{Version: "-"}
+	for k := range meta.EnvVars() {
+		if !keep[k] {
+			delete(deployed, k)
+		}
+	}
+
+	return deployed, nil
+}
+
+func filterDiff(d pstore.DiffResult, names []string) pstore.DiffResult {
+	keep := map[string]bool{}
+	for _, n := range names {
+		keep[n] = true
+	}
+
+	result := pstore.DiffResult{
+		Added:   map[string]string{},
+		Removed: map[string]string{},
+		Changed: map[string]pstore.Change{},
+	}
+	for k, v := range d.Added {
+		if keep[k] {
+			result.Added[k] = v
+		}
+	}
+	for k, v := range d.Removed {
+		if keep[k] {
+			result.Removed[k] = v
+		}
+	}
+	for k, v := range d.Changed {
+		if keep[k] {
+			result.Changed[k] = v
+		}
+	}
+
+	return result
+}
+
+// RunPStoreCopy runs `<service> infra pstore copy <FROM_ENV> <TO_ENV>` which seeds
+// the service params of one env from another. The copy happens inside the
+// param store of TO_ENV so both envs must share it.
+// `<service> infra pstore copy <FROM_ENV> <TO_ENV> [--overwrite]`
+func (i *Infra) RunPStoreCopy(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	var config PStoreCopyConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	from := config.CmdConfig
+	from.Env = args[0]
+	src, err := i.LoadService(from)
+	if err != nil {
+		return failure.Wrap(err, "i.LoadService failed (%s)", from.Env)
+	}
+
+	to := config.CmdConfig
+	to.Env = args[1]
+	dst, err := i.LoadService(to)
+	if err != nil {
+		return failure.Wrap(err, "i.LoadService failed (%s)", to.Env)
+	}
+
+	store, err := i.ParamStoreFor(to)
+	if err != nil {
+		return failure.Wrap(err, "i.ParamStoreFor failed (%s)", to.Env)
+	}
+
+	if err = i.Writable("pstore copy"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+	report, err := store.CopyPath(ctx, src.Name.AppTitle(), dst.Name.AppTitle(), config.Overwrite)
+	if err != nil {
+		return failure.Wrap(err, "store.CopyPath failed")
+	}
+
+	return i.DisplayFormatted(report)
+}
+
+// RunPStoreMigrate runs `<service> infra pstore migrate <FEATURE>` which copies the
+// feature's params from `/<service>/<KEY>` to `/<service>/<feature>/<KEY>`. Set
+// the feature's ParamScope to sls.FeatureParamScope once it is done.
+// `<service> infra pstore migrate <FEATURE> [--overwrite] [--delete-old]`
+func (i *Infra) RunPStoreMigrate(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+	if i.PStoreAPI == nil {
+		return failure.System("i.PStoreAPI is not initialized")
+	}
+
+	var config PStoreMigrateConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	service, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+	report, err := i.MigrateFeatureParams(ctx, service, feature, config.Overwrite, config.DeleteOld)
+	if err != nil {
+		return failure.Wrap(err, "i.MigrateFeatureParams failed")
+	}
+
+	return i.DisplayFormatted(report)
+}
+
+// RunPStorePrune runs `<service> infra pstore prune` which lists the params
+// under the service path that no config struct of a feature references, the
+// leftovers of renamed or removed env vars. --apply deletes them once
+// confirmed, or with --yes.
+// `<service> infra pstore prune [--apply] [--yes]`
+func (i *Infra) RunPStorePrune(cmd *cobra.Command, _ []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+	if i.PStoreAPI == nil {
+		return failure.System("i.PStoreAPI is not initialized")
+	}
+
+	var config PStorePruneConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if config.IsApply {
+		if err := i.Writable("pstore delete"); err != nil {
+			return failure.Wrap(err, "i.Writable failed")
+		}
+	}
+
+	service, err := i.LoadService(config.CmdConfig)
+	if err != nil {
+		return failure.Wrap(err, "i.LoadService failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	defer i.Step("pstore prune")()
+	unreferenced, err := i.UnreferencedParams(ctx, service)
+	if err != nil {
+		return failure.Wrap(err, "i.UnreferencedParams failed")
+	}
+
+	report := PruneReport{Unreferenced: make([]string, 0, len(unreferenced))}
+	for key := range unreferenced {
+		report.Unreferenced = append(report.Unreferenced, key)
+	}
+	sort.Strings(report.Unreferenced)
+
+	if config.IsApply && len(unreferenced) > 0 {
+		prompt := fmt.Sprintf("delete (%d) unreferenced params of (%s) in env (%s)?", len(unreferenced), service.Name.AppTitle(), config.Env)
+		if err = i.RequireConfirm(config.CmdConfig, prompt); err != nil {
+			return failure.Wrap(err, "i.RequireConfirm failed")
+		}
+
+		report.Deleted = i.deleteParams(ctx, unreferenced)
+	}
+
+	if err = i.DisplayFormatted(report); err != nil {
+		return failure.Wrap(err, "i.DisplayFormatted failed")
+	}
+
+	if report.Deleted != nil {
+		if err = report.Deleted.Err(); err != nil {
+			return failure.Wrap(err, "(%d) params failed to delete", len(report.Deleted.Failed()))
+		}
+	}
+
 	return nil
 }
 
+// UnreferencedParams are the params stored under the service path, feature
+// paths included, that are not one of the service.ReferencedParamKeys
+func (i *Infra) UnreferencedParams(ctx context.Context, service *sls.MicroService) (map[string]string, error) {
+	referenced, err := service.ReferencedParamKeys()
+	if err != nil {
+		return nil, failure.Wrap(err, "service.ReferencedParamKeys failed")
+	}
+
+	stored, err := i.ServiceParams(ctx, service.Name.AppTitle())
+	if err != nil {
+		return nil, failure.Wrap(err, "i.ServiceParams failed")
+	}
+
+	result := map[string]string{}
+	for key, value := range stored {
+		if _, ok := referenced[key]; !ok {
+			result[key] = value
+		}
+	}
+
+	return result, nil
+}
+
+// MigrateFeatureParams copies the flat params of feature to its feature
+// path. With deleteOld the flat params are removed unless other service
+// scoped features still read them.
+func (i *Infra) MigrateFeatureParams(ctx context.Context, service *sls.MicroService, feature sls.Feature, overwrite, deleteOld bool) (ParamMigrationReport, error) {
+	report := ParamMigrationReport{
+		Moved:   map[string]string{},
+		Skipped: map[string]string{},
+		Shared:  map[string][]string{},
+	}
+
+	if err := i.Writable("pstore migrate"); err != nil {
+		return report, failure.Wrap(err, "i.Writable failed")
+	}
+
+	appTitle := service.Name.AppTitle()
+	migration, err := feature.ParamMigration(appTitle)
+	if err != nil {
+		return report, failure.Wrap(err, "feature.ParamMigration failed")
+	}
+
+	shared, err := service.SharedParamKeys(feature)
+	if err != nil {
+		return report, failure.Wrap(err, "service.SharedParamKeys failed")
+	}
+
+	for old, key := range migration {
+		value, err := i.PStoreAPI.Param(ctx, old)
+		if err != nil {
+			if failure.IsNotFound(err) {
+				report.Missing = append(report.Missing, old)
+				continue
+			}
+			return report, failure.Wrap(err, "i.PStoreAPI.Param failed (%s)", old)
+		}
+
+		if !overwrite {
+			if _, err = i.PStoreAPI.Param(ctx, key); err == nil {
+				report.Skipped[key] = old
+				continue
+			} else if !failure.IsNotFound(err) {
+				return report, failure.Wrap(err, "i.PStoreAPI.Param failed (%s)", key)
+			}
+		}
+
+		if _, err = i.PStoreAPI.Put(ctx, key, value, overwrite); err != nil {
+			return report, failure.Wrap(err, "i.PStoreAPI.Put failed (%s)", key)
+		}
+		report.Moved[key] = old
+	}
+
+	for old := range migration {
+		if features, ok := shared[old]; ok {
+			report.Shared[old] = features
+			continue
+		}
+
+		if _, moved := report.Moved[migration[old]]; !deleteOld || !moved {
+			continue
+		}
+
+		if _, err = i.PStoreAPI.Delete(ctx, old); err != nil {
+			return report, failure.Wrap(err, "i.PStoreAPI.Delete failed (%s)", old)
+		}
+		report.Deleted = append(report.Deleted, old)
+	}
+
+	sort.Strings(report.Missing)
+	sort.Strings(report.Deleted)
+
+	return report, nil
+}
+
 // RunPStoreImport runs `<service> infra pstore import` which will import all parameters
 // `<service> infra pstore import <[--file | --env]>`
 func (i *Infra) RunPStoreImport(cmd *cobra.Command, _ []string) error {
@@ -323,58 +1038,151 @@ func (i *Infra) RunPStoreImport(cmd *cobra.Command, _ []string) error {
 	}
 
 	appTitle := service.Name.AppTitle()
+	ctx, stop := i.Context(cmd)
+	defer stop()
 
 	params := map[string]string{}
 	if !config.File.IsEmpty() {
-		params, err = readPStoreParamsFromFile(appTitle, config.File.Path)
+		params, err = readPStoreParamsFromFile(config.File.Path, config.FileFormat)
 		if err != nil {
 			return failure.Wrap(err, "readPStoreParamsFromFile failed")
 		}
 	} else {
-		params, err = readPStoreParamsFromService(service)
+		params, err = readPStoreParamsFromService(ctx, service)
 		if err != nil {
 			return failure.Wrap(err, "readPStorePramsFromService failed")
 		}
 	}
 
-	var errs []error
-	ctx := context.Background()
-	backup := map[string]string{}
-	overwrite := config.Overwrite
-	for k, v := range params {
-		old, err := i.PutParam(ctx, appTitle, k, v, overwrite)
-		if err != nil && failure.IsNotFound(err) {
-			errs = append(errs, err)
+	// the plan shows the keys that would be created or overwritten or skipped
+	if config.DryRun {
+		plan, err := i.PlanImport(ctx, appTitle, params, config.Overwrite)
+		if err != nil {
+			return failure.Wrap(err, "i.PlanImport failed")
+		}
+
+		return i.DisplayFormatted(plan)
+	}
+
+	// only the overwrites are confirmed, creating a param loses nothing
+	if config.Overwrite && !config.IsYes {
+		plan, err := i.PlanImport(ctx, appTitle, params, config.Overwrite)
+		if err != nil {
+			return failure.Wrap(err, "i.PlanImport failed")
+		}
+
+		if len(plan.Overwrite) > 0 {
+			prompt := fmt.Sprintf("overwrite (%d) params of (%s) in env (%s)?", len(plan.Overwrite), appTitle, config.Env)
+			if err = i.RequireConfirm(config.CmdConfig, prompt); err != nil {
+				return failure.Wrap(err, "i.RequireConfirm failed")
+			}
+		}
+	}
+
+	report := i.ImportParams(ctx, appTitle, params, config.Overwrite)
+	if err := i.DisplayFormatted(report); err != nil {
+		return failure.Wrap(err, "i.DisplayFormatted failed")
+	}
+	if err = report.Results.Err(); err != nil {
+		return failure.Wrap(err, "(%d) params failed to import", len(report.Results.Failed()))
+	}
+
+	return nil
+}
+
+// ImportReport is the outcome of every imported key, Backup has the values
+// the overwritten keys had before the import so they can be restored
+type ImportReport struct {
+	Results *BulkReport       `json:"results"`
+	Backup  map[string]string `json:"backup"`
+}
+
+func (r ImportReport) TableRows() ([]string, [][]string) {
+	return r.Results.TableRows()
+}
+
+// ImportParams puts every param, a key that fails does not stop the others
+func (i *Infra) ImportParams(ctx context.Context, appTitle string, params map[string]string, overwrite bool) ImportReport {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	report := ImportReport{Results: NewBulkReport("pstore import"), Backup: map[string]string{}}
+	progress := i.StartProgress("pstore import", len(keys))
+	defer progress.Done()
+	for _, k := range keys {
+		start := time.Now()
+		old, err := i.PutParam(ctx, appTitle, k, params[k], overwrite)
+		report.Results.Record(k, start, err)
+		progress.Add(1, k)
+		if err != nil {
 			continue
 		}
 
 		// Add old values to be sent back
 		for bk, bv := range old {
-			backup[bk] = bv
+			report.Backup[bk] = bv
 		}
 	}
 
-	if len(errs) > 0 {
-		_, _ = fmt.Fprintf(i.Stderr, "%+v\n", errs)
+	return report
+}
+
+// PlanImport compares the params to import with parameter store, keys get
+// the same app title prefix PutParam gives them. Only reads are made.
+func (i *Infra) PlanImport(ctx context.Context, appTitle string, params map[string]string, overwrite bool) (ImportPlan, error) {
+	plan := ImportPlan{
+		Create:    map[string]string{},
+		Overwrite: map[string]pstore.Change{},
+		Skipped:   map[string]string{},
 	}
 
-	i.DisplayJson(backup)
-	return nil
+	if appTitle == "" {
+		return plan, failure.System("appTitle is empty. should be the terraform name of the mico-service")
+	}
+
+	for k, v := range params {
+		if !strings.HasPrefix(k, appTitle) {
+			k = fmt.Sprintf("%s/%s", appTitle, k)
+		}
+		key := i.PStoreAPI.EnsurePathPrefix(k)
+
+		old, err := i.PStoreAPI.Param(ctx, key)
+		switch {
+		case failure.IsNotFound(err):
+			plan.Create[key] = v
+		case err != nil:
+			return plan, failure.Wrap(err, "i.PStoreAPI.Param failed (%s)", key)
+		case old == v:
+			plan.Unchanged = append(plan.Unchanged, key)
+		case overwrite:
+			plan.Overwrite[key] = pstore.Change{Left: old, Right: v}
+		default:
+			plan.Skipped[key] = old
+		}
+	}
+	sort.Strings(plan.Unchanged)
+
+	return plan, nil
 }
 
-func readPStoreParamsFromService(service *sls.MicroService) (map[string]string, error) {
+// readPStoreParamsFromService collects the params of every feature from the
+// local environment, defaults are left out of parameter store
+func readPStoreParamsFromService(ctx context.Context, service *sls.MicroService) (map[string]string, error) {
 	params := map[string]string{}
-	appTitle := service.Name.AppTitle()
+	resolver := NewEnvResolver(service.Name.AppTitle(), nil, LocalEnvStage)
 	for title, feature := range service.Features {
 		if feature.Conf == nil {
 			return nil, failure.System("[%s] feature.Conf is nil, not initialized", title)
 		}
-		result, err := feature.Conf.CollectParamsFromEnv(appTitle)
+		resolution, err := resolver.Resolve(ctx, feature)
 		if err != nil {
-			return params, failure.Wrap(err, "feature.Conf.ProcessParamStore failed (%s)", title)
+			return params, failure.Wrap(err, "resolver.Resolve failed (%s)", title)
 		}
 
-		for k, v := range result {
+		for k, v := range resolution.Params() {
 			existing, ok := params[k]
 			if ok {
 				if existing != v {
@@ -388,15 +1196,24 @@ func readPStoreParamsFromService(service *sls.MicroService) (map[string]string,
 	return params, nil
 }
 
-func readPStoreParamsFromFile(appTitle, f string) (map[string]string, error) {
+// readPStoreParamsFromFile reads a json, dotenv or yaml file. An empty
+// format is detected from the file, see DetectParamFileFormat.
+func readPStoreParamsFromFile(f, format string) (map[string]string, error) {
 	file, err := ioutil.ReadFile(f)
 	if err != nil {
 		return nil, failure.Wrap(err, "ioutil.ReadFile failed, (%s)", f)
 	}
 
-	var data map[string]string
-	if err = json.Unmarshal(file, &data); err != nil {
-		return nil, failure.Wrap(err, "json.Unmarshal failed")
+	pf := DetectParamFileFormat(f, file)
+	if format != "" {
+		if pf, err = ToParamFileFormat(format); err != nil {
+			return nil, failure.Wrap(err, "ToParamFileFormat failed")
+		}
+	}
+
+	data, err := ParseParamFile(pf, file)
+	if err != nil {
+		return nil, failure.Wrap(err, "ParseParamFile failed (%s as %s)", f, pf)
 	}
 
 	return data, nil
@@ -430,7 +1247,14 @@ func (i *Infra) PutParam(ctx context.Context, appTitle, key, value string, overw
 		key = fmt.Sprintf("%s/%s", appTitle, key)
 	}
 
+	if err := i.Writable("pstore put"); err != nil {
+		return nil, failure.Wrap(err, "i.Writable failed")
+	}
+
 	path := i.PStoreAPI.EnsurePathPrefix(key)
+	if i.DryRun("PutParameter", path, fmt.Sprintf("overwrite (%t)", overwrite)) {
+		return map[string]string{}, nil
+	}
 
 	old, err := i.PStoreAPI.Put(ctx, path, value, overwrite)
 	if err != nil {
@@ -440,12 +1264,27 @@ func (i *Infra) PutParam(ctx context.Context, appTitle, key, value string, overw
 	return map[string]string{path: old}, nil
 }
 
-func (i *Infra) DeleteParam(ctx context.Context, appTitle, key string) (map[string]string, error) {
+// serviceParamPath is the pstore path of a key of the service, a key that
+// already starts with the app title is only given its leading slash
+func (i *Infra) serviceParamPath(appTitle, key string) string {
+	key = strings.TrimPrefix(key, "/")
 	if !strings.HasPrefix(key, appTitle) {
 		key = fmt.Sprintf("%s/%s", appTitle, key)
 	}
 
-	path := i.PStoreAPI.EnsurePathPrefix(key)
+	return i.PStoreAPI.EnsurePathPrefix(key)
+}
+
+func (i *Infra) DeleteParam(ctx context.Context, appTitle, key string) (map[string]string, error) {
+	if err := i.Writable("pstore delete"); err != nil {
+		return nil, failure.Wrap(err, "i.Writable failed")
+	}
+
+	path := i.serviceParamPath(appTitle, key)
+	if i.DryRun("DeleteParameter", path, "") {
+		return map[string]string{}, nil
+	}
+
 	value, err := i.PStoreAPI.Delete(ctx, path)
 	if err != nil {
 		return nil, failure.Wrap(err, "i.PStoreAPI.Delete failed (%s, %s)", appTitle, key)
@@ -457,44 +1296,69 @@ func (i *Infra) DeleteParam(ctx context.Context, appTitle, key string) (map[stri
 	return result, nil
 }
 
-func (i *Infra) DeleteAllFeatureParams(ctx context.Context, appTitle string, feature sls.Feature) (map[string]string, error) {
+func (i *Infra) DeleteAllFeatureParams(ctx context.Context, appTitle string, feature sls.Feature) (*BulkReport, error) {
 	if appTitle == "" {
 		return nil, failure.System("appTitle is empty. should be the terraform name of the mico-service")
 	}
 
-	result, err := i.FeatureParams(ctx, appTitle, feature)
-	if err != nil {
-		return nil, failure.Wrap(err, "i.FeatureParams failed for (%s)", appTitle)
+	if err := i.Writable("pstore delete"); err != nil {
+		return nil, failure.Wrap(err, "i.Writable failed")
 	}
 
-	for key := range result {
-
-		if _, err := i.PStoreAPI.Delete(ctx, key); err != nil {
-			return result, failure.ToSystem(err, "i.PStoreAPI.Delete failed")
-		}
+	result, err := i.FeatureParams(ctx, appTitle, feature, ExcludeDefaults)
+	if err != nil {
+		return nil, failure.Wrap(err, "i.FeatureParams failed for (%s)", appTitle)
 	}
 
-	return result, nil
+	return i.deleteParams(ctx, result), nil
 }
 
-func (i *Infra) DeleteAllServiceParams(ctx context.Context, appTitle string) (map[string]string, error) {
+func (i *Infra) DeleteAllServiceParams(ctx context.Context, appTitle string) (*BulkReport, error) {
 	if appTitle == "" {
 		return nil, failure.System("appTitle is empty. should be the terraform name of the mico-service")
 	}
 
+	if err := i.Writable("pstore delete"); err != nil {
+		return nil, failure.Wrap(err, "i.Writable failed")
+	}
+
 	result, err := i.ServiceParams(ctx, appTitle)
 	if err != nil {
 		return nil, failure.Wrap(err, "pstoreGetAllFromService failed for (%s)", appTitle)
 	}
 
-	for key := range result {
+	return i.deleteParams(ctx, result), nil
+}
+
+// deleteParams deletes every key of params, a key that fails does not stop
+// the others
+func (i *Infra) deleteParams(ctx context.Context, params map[string]string) *BulkReport {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	report := NewBulkReport("pstore delete")
+	progress := i.StartProgress("pstore delete", len(keys))
+	defer progress.Done()
+	for _, key := range keys {
+		start := time.Now()
+		if i.DryRun("DeleteParameter", key, "") {
+			report.Record(key, start, nil)
+			progress.Add(1, key)
+			continue
+		}
 
-		if _, err := i.PStoreAPI.Delete(ctx, key); err != nil {
-			return result, failure.ToSystem(err, "i.PStoreAPI.Delete failed")
+		_, err := i.PStoreAPI.Delete(ctx, key)
+		if err != nil {
+			err = failure.Wrap(err, "i.PStoreAPI.Delete failed")
 		}
+		report.Record(key, start, err)
+		progress.Add(1, key)
 	}
 
-	return result, nil
+	return report
 }
 
 func (i *Infra) ServiceParams(ctx context.Context, appTitle string) (map[string]string, error) {
@@ -502,7 +1366,10 @@ func (i *Infra) ServiceParams(ctx context.Context, appTitle string) (map[string]
 		return nil, failure.System("appTitle is empty. should be the terraform name of the mico-service")
 	}
 
+	// the path is read page by page in one call, so only a spinner is shown
+	progress := i.StartProgress("pstore scan", 0)
 	result, err := i.PStoreAPI.Path(ctx, appTitle)
+	progress.Done()
 	if err != nil {
 		return nil, failure.Wrap(err, "i.PStoreAPI.Path failed")
 	}
@@ -510,26 +1377,66 @@ func (i *Infra) ServiceParams(ctx context.Context, appTitle string) (map[string]
 	return result, nil
 }
 
-func (i *Infra) FeatureParams(ctx context.Context, appTitle string, feature sls.Feature) (map[string]string, error) {
+// FeatureParams are the param store key to value of the feature's params.
+// With ExcludeDefaults only the values stored in pstore are returned, with
+// IncludeDefaults the conf tag defaults fill the keys pstore has no value for,
+// which is what deploy writes to the lambda env.
+func (i *Infra) FeatureParams(ctx context.Context, appTitle string, feature sls.Feature, defaults DefaultsPolicy) (map[string]string, error) {
 	if appTitle == "" {
 		return nil, failure.System("appTitle is empty. should be the terraform name of the mico-service")
 	}
 
-	config := feature.Conf
-	config.MarkDefaultsAsExcluded()
+	resolution, err := NewEnvResolver(appTitle, i.PStoreAPI, PStoreStage).WithDefaults(defaults).Resolve(ctx, feature)
+	if err != nil {
+		return nil, failure.Wrap(err, "resolver.Resolve failed for (%s, %s)", appTitle, feature.Name)
+	}
+
+	return resolution.Params(), nil
+}
+
+// PStoreRecords resolves the params of `infra pstore --meta`, either every
+// param of the service or only the params of the feature in args
+func (i *Infra) PStoreRecords(ctx context.Context, config PStoreConfig, args []string) (map[string]pstore.ParamRecord, error) {
+	api, ok := i.PStoreAPI.(ParamMetadata)
+	if !ok {
+		return nil, failure.System("i.PStoreAPI does not implement ParamMetadata")
+	}
+
+	if config.IsAll {
+		service, err := i.LoadService(config.CmdConfig)
+		if err != nil {
+			return nil, failure.Wrap(err, "i.LoadService failed")
+		}
+
+		result, err := api.PathRecords(ctx, service.Name.AppTitle())
+		if err != nil {
+			return nil, failure.Wrap(err, "api.PathRecords failed")
+		}
+		return result, nil
+	}
+
+	if len(args) == 0 || args[0] == "" {
+		return nil, failure.System("parameter name is missing")
+	}
+
+	service, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return nil, failure.Wrap(err, "i.LoadFeature failed")
+	}
+
 	keys, err := feature.Conf.EnvNames()
 	if err != nil {
-		return nil, failure.Wrap(err, "feature.Conf.EnvNames failed for (%s, %s)", appTitle, feature.Name)
+		return nil, failure.Wrap(err, "feature.Conf.EnvNames failed (%s)", feature.Name)
 	}
 
-	var result = map[string]string{}
+	result := map[string]pstore.ParamRecord{}
 	for _, key := range keys {
-		key = fmt.Sprintf("/%s/%s", appTitle, key)
-		value, err := i.PStoreAPI.Param(ctx, key)
+		key = feature.ParamKey(service.Name.AppTitle(), key)
+		record, err := api.ParamMeta(ctx, key)
 		if err != nil {
-			return nil, failure.Wrap(err, "i.PStoreAPI.Param failed (%s, %s, %s)", appTitle, feature.Name, key)
+			return nil, failure.Wrap(err, "api.ParamMeta failed (%s)", key)
 		}
-		result[key] = value
+		result[key] = record
 	}
 
 	return result, nil
@@ -0,0 +1,110 @@
+package infra
+
+import (
+	"fmt"
+
+	"github.com/rsb/failure"
+)
+
+// DryRunOps are the operations that honor --dry-run, they show the changes
+// they would make with DryRun instead of making them. Writable refuses the
+// others in dry run mode so nothing is changed by mistake.
+var DryRunOps = map[string]bool{
+	"deploy":               true,
+	"deploy canary":        true,
+	"deploy api stage":     true,
+	"deploy rollback":      true,
+	"upload code":          true,
+	"ensure log group":     true,
+	"update config":        true,
+	"update code":          true,
+	"pstore put":           true,
+	"pstore delete":        true,
+	"destroy":              true,
+	"alias create":         true,
+	"alias update":         true,
+	"concurrency schedule": true,
+	"concurrency set":      true,
+	"secrets put":          true,
+	"secrets rotate":       true,
+	"layer publish":        true,
+	"version publish":      true,
+	"url":                  true,
+	"esm create":           true,
+	"esm update":           true,
+	"esm pause":            true,
+	"esm resume":           true,
+	"tag function":         true,
+}
+
+// ReadOnlyMode is implemented by configs that can turn on read only mode,
+// like CmdConfig with --read-only
+type ReadOnlyMode interface {
+	IsReadOnlyMode() bool
+}
+
+func (c CmdConfig) IsReadOnlyMode() bool {
+	return c.IsReadOnly
+}
+
+// DryRunMode is implemented by configs that can turn on dry run mode, like
+// CmdConfig with --dry-run
+type DryRunMode interface {
+	IsDryRunMode() bool
+}
+
+func (c CmdConfig) IsDryRunMode() bool {
+	return c.DryRun
+}
+
+// readOnly turns on read only mode when the config asks for it. It is never
+// turned off, an Infra created with IsReadOnly set stays read only. Dry run
+// mode works the same way.
+func (i *Infra) readOnly(c interface{}) {
+	if m, ok := c.(ReadOnlyMode); ok && m.IsReadOnlyMode() {
+		i.IsReadOnly = true
+	}
+
+	if m, ok := c.(DryRunMode); ok && m.IsDryRunMode() {
+		i.IsDryRun = true
+	}
+}
+
+// Writable refuses op when the infra is read only. Every operation that
+// changes aws resources calls it first so production credentials can be
+// used to inspect without risk. A dry run changes nothing so it is allowed
+// in read only mode, for the operations in DryRunOps.
+func (i *Infra) Writable(op string) error {
+	if i.IsDryRun {
+		if !DryRunOps[op] {
+			return failure.InvalidParam("(%s) does not support --dry-run, nothing was changed", op)
+		}
+		return nil
+	}
+
+	if i.IsReadOnly {
+		return failure.Forbidden("(%s) changes aws resources and is refused in read only mode (--read-only)", op)
+	}
+
+	return nil
+}
+
+// DryRun prints the aws call that would change target, with what it would
+// change, and is true when the call must not be made:
+//
+//	if i.DryRun("DeleteParameter", path, "") {
+//		return nil
+//	}
+func (i *Infra) DryRun(call, target, change string) bool {
+	if !i.IsDryRun {
+		return false
+	}
+
+	line := fmt.Sprintf("[dry-run] %s (%s)", call, target)
+	if change != "" {
+		line += ": " + change
+	}
+	_ = i.DisplayError(line + "\n")
+
+	return true
+}
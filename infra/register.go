@@ -0,0 +1,81 @@
+package infra
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/rsb/failure"
+	"github.com/spf13/cobra"
+)
+
+// CommandRunner runs a command added with RegisterCommand. config has the
+// global flags and the bind given to RegisterCommand is filled before it
+// runs, ctx is done on an interrupt.
+type CommandRunner func(ctx context.Context, config CmdConfig, args []string) error
+
+// RegisterCommand adds a subcommand of the service, like `db migrate`, under
+// the infra command. The flags of bind, a pointer to a struct with conf tags,
+// are bound like the flags of the infra commands and it is processed with
+// the same viper and prefix, so the command shares --env, --target-account,
+// --read-only and the other global flags. The service is loaded from config
+// with i.LoadService. Call it after SetupCommands.
+// `in.RegisterCommand(&cobra.Command{Use: "seed <FEATURE>"}, &SeedBind{}, seed)`
+func (i *Infra) RegisterCommand(cmd *cobra.Command, bind interface{}, run CommandRunner) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	if cmd == nil {
+		return failure.InvalidParam("cmd is nil")
+	}
+
+	if run == nil {
+		return failure.InvalidParam("run is nil for (%s)", cmd.Name())
+	}
+
+	if bind != nil {
+		v := reflect.ValueOf(bind)
+		if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			return failure.InvalidParam("bind of (%s) must be a pointer to a struct, got (%T)", cmd.Name(), bind)
+		}
+	}
+
+	for _, c := range i.ParentCmd.Commands() {
+		if c.Name() == cmd.Name() {
+			return failure.InvalidParam("a command (%s) is already registered", cmd.Name())
+		}
+	}
+
+	cmd.RunE = func(c *cobra.Command, args []string) error {
+		var config CmdConfig
+		if err := i.Process(c, &config); err != nil {
+			return failure.Wrap(err, "i.Configure failed")
+		}
+
+		if bind != nil {
+			if err := Process(c, i.Viper, bind, i.Prefix...); err != nil {
+				return failure.Wrap(err, "Process failed for (%T)", bind)
+			}
+		}
+
+		ctx, stop := i.Context(c)
+		defer stop()
+
+		defer i.Step(c.Name())()
+		return run(ctx, config, args)
+	}
+
+	if cmd.ValidArgsFunction == nil && strings.Contains(cmd.Use, "FEATURE") {
+		cmd.ValidArgsFunction = i.CompleteFeatures
+	}
+	i.ParentCmd.AddCommand(cmd)
+
+	if bind != nil {
+		if err := Bind(cmd, i.Viper, bind); err != nil {
+			return failure.Wrap(err, "Bind failed for (%s)", cmd.Name())
+		}
+	}
+
+	return nil
+}
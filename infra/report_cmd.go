@@ -0,0 +1,240 @@
+package infra
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/cwmetrics"
+	"github.com/rsb/sls/lambda"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// GBSecondPriceX86 and GBSecondPriceARM are the on demand prices in usd
+	// of a GB-second of compute in us-east-1, RequestPrice is the price of
+	// one request. The free tier is not taken off.
+	GBSecondPriceX86 = 0.0000166667
+	GBSecondPriceARM = 0.0000133334
+	RequestPrice     = 0.0000002
+	// CostMonth is the month the invocations of the window are scaled to
+	CostMonth = 30 * 24 * time.Hour
+)
+
+func SetupReportCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.ReportCmd == nil {
+		in.ReportCmd = ReportCmd
+	}
+	in.ReportCmd.RunE = in.RunReport
+	in.ParentCmd.AddCommand(in.ReportCmd)
+
+	var rb ReportBind
+	if err := Bind(in.ReportCmd, in.Viper, &rb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.ReportCmd")
+	}
+
+	return nil
+}
+
+var ReportCmd = &cobra.Command{
+	Use:   "report [FEATURE]",
+	Short: "summarize the code size and limits and estimated monthly cost of the lambdas",
+	Args:  cobra.MaximumNArgs(1),
+}
+
+type ReportBind struct {
+	Window time.Duration `conf:"default:168h, cli:window, cli-u: How far back the invocations and durations are read (ex 24h)"`
+}
+
+type ReportConfig struct {
+	CmdConfig
+	ReportBind
+}
+
+// FeatureCostReport is the size and estimated cost of one feature. Duration
+// is the total in milliseconds over the window, MonthlyCost is in usd and
+// scales the window to a 30 day month.
+type FeatureCostReport struct {
+	Feature     string                   `json:"feature"`
+	Footprint   lambda.FunctionFootprint `json:"footprint"`
+	Invocations float64                  `json:"invocations"`
+	Duration    float64                  `json:"duration_ms"`
+	MonthlyCost float64                  `json:"monthly_cost"`
+	Error       string                   `json:"error,omitempty"`
+}
+
+// ServiceReport is the report of every feature, sorted by name, with the
+// sum of their estimated monthly cost
+type ServiceReport struct {
+	Window      string              `json:"window"`
+	Features    []FeatureCostReport `json:"features"`
+	MonthlyCost float64             `json:"monthly_cost"`
+}
+
+func (r ServiceReport) TableRows() ([]string, [][]string) {
+	header := []string{"FEATURE", "CODE SIZE", "MEMORY", "TIMEOUT", "ARCH", "INVOCATIONS", "MONTHLY COST"}
+	rows := make([][]string, 0, len(r.Features)+1)
+	for _, f := range r.Features {
+		if f.Error != "" {
+			rows = append(rows, []string{f.Feature, "-", "-", "-", "-", "-", f.Error})
+			continue
+		}
+
+		rows = append(rows, []string{
+			f.Feature,
+			byteSize(f.Footprint.CodeSize),
+			limitCell(f.Footprint.MemorySize),
+			limitCell(f.Footprint.Timeout),
+			f.Footprint.Architecture,
+			strconv.FormatFloat(f.Invocations, 'f', 0, 64),
+			costCell(f.MonthlyCost),
+		})
+	}
+	rows = append(rows, []string{"total", "", "", "", "", "", costCell(r.MonthlyCost)})
+
+	return header, rows
+}
+
+func costCell(usd float64) string {
+	return "$" + strconv.FormatFloat(usd, 'f', 2, 64)
+}
+
+func byteSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+
+	size, suffix := float64(n)/unit, "KB"
+	for _, s := range []string{"MB", "GB"} {
+		if size < unit {
+			break
+		}
+		size, suffix = size/unit, s
+	}
+
+	return strconv.FormatFloat(size, 'f', 1, 64) + " " + suffix
+}
+
+// RunReport runs `<service> infra report [FEATURE]` which shows the code
+// size, memory, timeout and architecture each feature is deployed with and
+// an estimate of its monthly cost from the invocations and durations of
+// --window. A feature that is not deployed is reported with its error.
+// `<service> infra report [FEATURE] [--window 24h] [--format table]`
+func (i *Infra) RunReport(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	api, ok := i.LambdaAPI.(LambdaFootprint)
+	if !ok {
+		return failure.System("i.LambdaAPI is not initialized or does not implement LambdaFootprint")
+	}
+
+	if i.MetricsAPI == nil {
+		return failure.System("i.MetricsAPI is not initialized")
+	}
+
+	var config ReportConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if config.Window <= 0 {
+		return failure.InvalidParam("--window (%s) must be positive", config.Window)
+	}
+
+	var features []sls.Feature
+	switch {
+	case len(args) > 0:
+		_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+		if err != nil {
+			return failure.Wrap(err, "i.LoadFeature failed")
+		}
+		features = append(features, feature)
+	default:
+		service, err := i.LoadService(config.CmdConfig)
+		if err != nil {
+			return failure.Wrap(err, "i.LoadService failed")
+		}
+		for _, f := range service.Features {
+			features = append(features, f)
+		}
+		sort.Slice(features, func(a, b int) bool {
+			return features[a].Name < features[b].Name
+		})
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	defer i.Step("report")()
+	end := time.Now()
+	start := end.Add(-config.Window)
+	report := ServiceReport{Window: config.Window.String(), Features: make([]FeatureCostReport, 0, len(features))}
+	for _, feature := range features {
+		f, err := i.FeatureCost(ctx, api, feature, start, end)
+		switch {
+		case failure.IsNotFound(err):
+			f.Error = "not deployed"
+		case err != nil:
+			return failure.Wrap(err, "i.FeatureCost failed (%s)", feature.Name)
+		}
+
+		report.MonthlyCost += f.MonthlyCost
+		report.Features = append(report.Features, f)
+	}
+
+	return i.DisplayFormatted(report)
+}
+
+// FeatureCost reads the footprint of the feature and its invocations and
+// total duration between start and end
+func (i *Infra) FeatureCost(ctx context.Context, api LambdaFootprint, feature sls.Feature, start, end time.Time) (FeatureCostReport, error) {
+	report := FeatureCostReport{Feature: feature.Name}
+	footprint, err := api.Footprint(ctx, feature.QualifiedName)
+	if err != nil {
+		return report, failure.Wrap(err, "api.Footprint failed")
+	}
+	report.Footprint = footprint
+
+	q := cwmetrics.LambdaFunctionQuery(cwmetrics.InvocationsMetric, feature.QualifiedName, start, end)
+	if report.Invocations, err = i.MetricsAPI.Sum(ctx, q); err != nil {
+		return report, failure.Wrap(err, "i.MetricsAPI.Sum failed (%s)", q.Name)
+	}
+
+	q = cwmetrics.LambdaFunctionQuery(cwmetrics.DurationMetric, feature.QualifiedName, start, end)
+	if report.Duration, err = i.MetricsAPI.Sum(ctx, q); err != nil {
+		return report, failure.Wrap(err, "i.MetricsAPI.Sum failed (%s)", q.Name)
+	}
+
+	report.MonthlyCost = EstimateMonthlyCost(footprint, report.Invocations, report.Duration, end.Sub(start))
+	return report, nil
+}
+
+// EstimateMonthlyCost is the cost in usd of the invocations and total
+// duration, in milliseconds, seen over window scaled to a CostMonth
+func EstimateMonthlyCost(f lambda.FunctionFootprint, invocations, durationMS float64, window time.Duration) float64 {
+	if window <= 0 {
+		return 0
+	}
+
+	price := GBSecondPriceX86
+	if f.Architecture == lambda.ARMArchitecture {
+		price = GBSecondPriceARM
+	}
+
+	gbSeconds := durationMS / 1000 * float64(f.MemorySize) / 1024
+	cost := gbSeconds*price + invocations*RequestPrice
+	cost *= float64(CostMonth) / float64(window)
+
+	return math.Round(cost*100) / 100
+}
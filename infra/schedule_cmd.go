@@ -0,0 +1,415 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/scheduler"
+	"github.com/rsb/sls/sts"
+	"github.com/spf13/cobra"
+)
+
+func SetupScheduleCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.ScheduleCmd == nil {
+		in.ScheduleCmd = ScheduleCmd
+	}
+	in.ParentCmd.AddCommand(in.ScheduleCmd)
+
+	if in.ScheduleListCmd == nil {
+		in.ScheduleListCmd = ScheduleListCmd
+	}
+	in.ScheduleListCmd.RunE = in.RunScheduleList
+	in.ScheduleCmd.AddCommand(in.ScheduleListCmd)
+
+	if in.SchedulePutCmd == nil {
+		in.SchedulePutCmd = SchedulePutCmd
+	}
+	in.SchedulePutCmd.RunE = in.RunSchedulePut
+	in.ScheduleCmd.AddCommand(in.SchedulePutCmd)
+
+	if in.ScheduleDeleteCmd == nil {
+		in.ScheduleDeleteCmd = ScheduleDeleteCmd
+	}
+	in.ScheduleDeleteCmd.RunE = in.RunScheduleDelete
+	in.ScheduleCmd.AddCommand(in.ScheduleDeleteCmd)
+
+	var lb ScheduleListBind
+	if err := Bind(in.ScheduleListCmd, in.Viper, &lb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.ScheduleListCmd")
+	}
+
+	var pb SchedulePutBind
+	if err := Bind(in.SchedulePutCmd, in.Viper, &pb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.SchedulePutCmd")
+	}
+
+	var db ScheduleDeleteBind
+	if err := Bind(in.ScheduleDeleteCmd, in.Viper, &db); err != nil {
+		return failure.Wrap(err, "Bind failed for in.ScheduleDeleteCmd")
+	}
+
+	return nil
+}
+
+var ScheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "manage the eventbridge schedules and scheduled rules that invoke a lambda",
+}
+
+var ScheduleListCmd = &cobra.Command{
+	Use:   "list <FEATURE>",
+	Short: "list the schedules of the group and the scheduled rules of the bus that target the lambda",
+	Args:  cobra.ExactArgs(1),
+}
+
+var SchedulePutCmd = &cobra.Command{
+	Use:   "put <FEATURE>",
+	Short: "create or replace a schedule, or with --rule a scheduled rule, that invokes the lambda",
+	Args:  cobra.ExactArgs(1),
+}
+
+var ScheduleDeleteCmd = &cobra.Command{
+	Use:   "delete <FEATURE>",
+	Short: "delete a schedule, or with --rule a scheduled rule, of the lambda",
+	Args:  cobra.ExactArgs(1),
+}
+
+type ScheduleListBind struct {
+	Group string `conf:"cli:group, cli-u: Schedule group (default the default group)"`
+	Bus   string `conf:"cli:bus, cli-u: Event bus of the scheduled rules (default the default bus)"`
+}
+
+type ScheduleListConfig struct {
+	CmdConfig
+	ScheduleListBind
+}
+
+type SchedulePutBind struct {
+	Name        string `conf:"cli:name, cli-u: Name of the schedule (default the qualified name of the lambda)"`
+	Group       string `conf:"cli:group, cli-u: Schedule group (default the default group)"`
+	Expression  string `conf:"cli:expression, cli-u: Schedule expression like cron(0 8 ? * MON-FRI *) or rate(5 minutes) or at(2024-01-31T18:00:00)"`
+	Timezone    string `conf:"cli:timezone, cli-u: Timezone of cron and at expressions (default UTC)"`
+	Role        string `conf:"cli:role, cli-u: Name or arn of the role the scheduler assumes to invoke the lambda"`
+	IsRule      bool   `conf:"cli:rule, cli-u: Put a scheduled eventbridge rule, which runs in UTC and needs no role, instead of a schedule"`
+	Bus         string `conf:"cli:bus, cli-u: Event bus of the rule (default the default bus)"`
+	Input       string `conf:"cli:input, cli-u: Json event the lambda is invoked with"`
+	Description string `conf:"cli:description, cli-u: Description of the schedule"`
+	IsDisabled  bool   `conf:"cli:disabled, cli-u: Create the schedule disabled"`
+}
+
+type SchedulePutConfig struct {
+	CmdConfig
+	SchedulePutBind
+}
+
+type ScheduleDeleteBind struct {
+	Name   string `conf:"cli:name, cli-u: Name of the schedule (default the qualified name of the lambda)"`
+	Group  string `conf:"cli:group, cli-u: Schedule group (default the default group)"`
+	IsRule bool   `conf:"cli:rule, cli-u: Delete a scheduled eventbridge rule and its targets instead of a schedule"`
+	Bus    string `conf:"cli:bus, cli-u: Event bus of the rule (default the default bus)"`
+}
+
+type ScheduleDeleteConfig struct {
+	CmdConfig
+	ScheduleDeleteBind
+}
+
+type ScheduleListReport struct {
+	Feature   string                     `json:"feature"`
+	Schedules []scheduler.ScheduleReport `json:"schedules"`
+}
+
+func (r ScheduleListReport) TableRows() ([]string, [][]string) {
+	header := []string{"FEATURE", "KIND", "NAME", "GROUP", "EXPRESSION", "TIMEZONE", "STATE", "TARGET"}
+	rows := make([][]string, 0, len(r.Schedules))
+	for _, s := range r.Schedules {
+		rows = append(rows, []string{r.Feature, s.Kind, s.Name, s.Group, s.Expression, s.Timezone, s.State, s.Target})
+	}
+
+	return header, rows
+}
+
+// RunScheduleList runs `<service> infra schedule list <FEATURE>` which shows
+// the schedules of the group and the scheduled rules of the bus that invoke
+// the lambda, including the ones terraform manages
+// `<service> infra schedule list <FEATURE> [--group jobs] [--bus default]`
+func (i *Infra) RunScheduleList(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	if i.SchedulerAPI == nil {
+		return failure.System("i.SchedulerAPI is not initialized")
+	}
+
+	var config ScheduleListConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	defer i.Step("schedule list")()
+	schedules, err := i.SchedulerAPI.FunctionSchedules(ctx, config.Group, feature.QualifiedName)
+	if err != nil {
+		return failure.Wrap(err, "i.SchedulerAPI.FunctionSchedules failed (%s)", feature.QualifiedName)
+	}
+
+	if api, ok := i.SchedulerAPI.(RuleManagement); ok {
+		rules, err := api.FunctionRules(ctx, config.Bus, feature.QualifiedName)
+		if err != nil {
+			return failure.Wrap(err, "api.FunctionRules failed (%s)", feature.QualifiedName)
+		}
+		schedules = append(schedules, rules...)
+	}
+
+	return i.DisplayFormatted(ScheduleListReport{Feature: feature.Name, Schedules: schedules})
+}
+
+// RunSchedulePut runs `<service> infra schedule put <FEATURE>` which creates
+// the schedule, or replaces it when it exists, so the lambda is invoked on
+// --expression. The expression is validated before anything is sent. With
+// --rule it puts a scheduled rule of --bus and allows the rule to invoke the
+// lambda instead.
+// `<service> infra schedule put <FEATURE> --expression "cron(0 3 * * ? *)" --role scheduler-invoke [--name nightly] [--input '{}']`
+// `<service> infra schedule put <FEATURE> --rule --expression "rate(5 minutes)" [--bus default]`
+func (i *Infra) RunSchedulePut(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	if i.SchedulerAPI == nil {
+		return failure.System("i.SchedulerAPI is not initialized")
+	}
+
+	var config SchedulePutConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if err := scheduler.ValidateExpression(config.Expression); err != nil {
+		return failure.Wrap(err, "--expression is not valid")
+	}
+
+	if config.IsRule {
+		if config.Timezone != "" {
+			return failure.InvalidParam("--timezone is not allowed with --rule, rules run in UTC")
+		}
+
+		if strings.HasPrefix(config.Expression, "at(") {
+			return failure.InvalidParam("--expression (%s) is not allowed with --rule, rules only accept cron(...) or rate(...)", config.Expression)
+		}
+	} else if config.Role == "" {
+		return failure.InvalidParam("--role is required, the scheduler assumes it to invoke the lambda")
+	}
+
+	if err := i.Writable("schedule put"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	service, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	if config.IsRule {
+		return i.putRule(ctx, service, feature, config)
+	}
+
+	functionARN, roleARN, err := i.scheduleARNs(ctx, service, feature, config.Role)
+	if err != nil {
+		return failure.Wrap(err, "i.scheduleARNs failed")
+	}
+
+	settings := scheduler.ScheduleSettings{
+		Name:        scheduleName(config.Name, feature),
+		Group:       config.Group,
+		Expression:  config.Expression,
+		Timezone:    config.Timezone,
+		Description: config.Description,
+		FunctionARN: functionARN,
+		RoleARN:     roleARN,
+		Input:       config.Input,
+		IsDisabled:  config.IsDisabled,
+	}
+
+	defer i.Step("schedule put")()
+	report, err := i.SchedulerAPI.PutSchedule(ctx, settings)
+	if err != nil {
+		return failure.Wrap(err, "i.SchedulerAPI.PutSchedule failed (%s)", settings.Name)
+	}
+
+	return i.DisplayFormatted(report)
+}
+
+// RunScheduleDelete runs `<service> infra schedule delete <FEATURE>` which
+// deletes the schedule, or with --rule the rule and its targets, once
+// confirmed, or with --yes
+// `<service> infra schedule delete <FEATURE> [--name nightly] [--group jobs] [--rule --bus default] [--yes]`
+func (i *Infra) RunScheduleDelete(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	if i.SchedulerAPI == nil {
+		return failure.System("i.SchedulerAPI is not initialized")
+	}
+
+	var config ScheduleDeleteConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if err := i.Writable("schedule delete"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	kind := scheduler.ScheduleKind
+	if config.IsRule {
+		kind = scheduler.RuleKind
+	}
+
+	name := scheduleName(config.Name, feature)
+	prompt := fmt.Sprintf("delete the %s (%s) of (%s)?", kind, name, feature.Name)
+	if err = i.RequireConfirm(config.CmdConfig, prompt); err != nil {
+		return failure.Wrap(err, "i.RequireConfirm failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	defer i.Step("schedule delete")()
+	if config.IsRule {
+		api, ok := i.SchedulerAPI.(RuleManagement)
+		if !ok {
+			return failure.System("i.SchedulerAPI does not implement RuleManagement")
+		}
+
+		if err = api.DeleteRule(ctx, config.Bus, name); err != nil {
+			return failure.Wrap(err, "api.DeleteRule failed (%s)", name)
+		}
+
+		return i.DisplayFormatted(map[string]string{"deleted": name})
+	}
+
+	if err = i.SchedulerAPI.DeleteSchedule(ctx, config.Group, name); err != nil {
+		return failure.Wrap(err, "i.SchedulerAPI.DeleteSchedule failed (%s)", name)
+	}
+
+	return i.DisplayFormatted(map[string]string{"deleted": name})
+}
+
+// putRule puts the scheduled rule of RunSchedulePut --rule and then allows
+// it to invoke the lambda, a rule assumes no role
+func (i *Infra) putRule(ctx context.Context, service *sls.MicroService, feature sls.Feature, config SchedulePutConfig) error {
+	api, ok := i.SchedulerAPI.(RuleManagement)
+	if !ok {
+		return failure.System("i.SchedulerAPI does not implement RuleManagement")
+	}
+
+	permission, ok := i.LambdaAPI.(LambdaRulePermission)
+	if !ok {
+		return failure.System("i.LambdaAPI is not initialized or does not implement LambdaRulePermission")
+	}
+
+	functionARN, err := i.featureFunctionARN(ctx, service, feature)
+	if err != nil {
+		return failure.Wrap(err, "i.featureFunctionARN failed")
+	}
+
+	settings := scheduler.RuleSettings{
+		Name:        scheduleName(config.Name, feature),
+		Bus:         config.Bus,
+		Expression:  config.Expression,
+		Description: config.Description,
+		FunctionARN: functionARN,
+		Input:       config.Input,
+		IsDisabled:  config.IsDisabled,
+	}
+
+	defer i.Step("schedule put")()
+	report, err := api.PutRule(ctx, settings)
+	if err != nil {
+		return failure.Wrap(err, "api.PutRule failed (%s)", settings.Name)
+	}
+
+	if err = permission.AllowRule(ctx, feature.QualifiedName, report.ARN); err != nil {
+		return failure.Wrap(err, "permission.AllowRule failed (%s)", report.ARN)
+	}
+
+	return i.DisplayFormatted(report)
+}
+
+func scheduleName(name string, feature sls.Feature) string {
+	if name != "" {
+		return name
+	}
+	return feature.QualifiedName
+}
+
+// scheduleARNs resolves the arn of the lambda and of the role the scheduler
+// assumes, a role given by name is in the account of the credentials
+func (i *Infra) scheduleARNs(ctx context.Context, service *sls.MicroService, feature sls.Feature, role string) (string, string, error) {
+	identity, region, err := i.functionLocation(ctx, service, feature)
+	if err != nil {
+		return "", "", failure.Wrap(err, "i.functionLocation failed")
+	}
+
+	functionARN := region.ARN("lambda", identity.Account, "function:"+feature.QualifiedName)
+	if strings.HasPrefix(role, "arn:") {
+		return functionARN, role, nil
+	}
+
+	return functionARN, sts.PartitionRoleARN(region.Partition(), identity.Account, role), nil
+}
+
+// featureFunctionARN is the arn of the lambda of the feature in the account
+// of the credentials
+func (i *Infra) featureFunctionARN(ctx context.Context, service *sls.MicroService, feature sls.Feature) (string, error) {
+	identity, region, err := i.functionLocation(ctx, service, feature)
+	if err != nil {
+		return "", failure.Wrap(err, "i.functionLocation failed")
+	}
+
+	return region.ARN("lambda", identity.Account, "function:"+feature.QualifiedName), nil
+}
+
+// functionLocation is the identity of the credentials and the region of the
+// aws config, or else of the service, the lambda of the feature is in
+func (i *Infra) functionLocation(ctx context.Context, service *sls.MicroService, feature sls.Feature) (sts.Identity, sls.Region, error) {
+	identity, err := i.callerIdentity(ctx)
+	if err != nil {
+		return identity, "", failure.Wrap(err, "i.callerIdentity failed")
+	}
+
+	region := service.Account.Region
+	if i.AWSConfig != nil && i.AWSConfig.Region != "" {
+		region = sls.Region(i.AWSConfig.Region)
+	}
+	if region.IsEmpty() {
+		return identity, "", failure.Config("no region is configured for the lambda arn of (%s)", feature.Name)
+	}
+
+	return identity, region, nil
+}
@@ -0,0 +1,413 @@
+package infra
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/secrets"
+	"github.com/spf13/cobra"
+)
+
+func SetupSecretsCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.SecretsCmd == nil {
+		in.SecretsCmd = SecretsCmd
+	}
+	in.ParentCmd.AddCommand(in.SecretsCmd)
+
+	if in.SecretsGetCmd == nil {
+		in.SecretsGetCmd = SecretsGetCmd
+	}
+	in.SecretsGetCmd.RunE = in.RunSecretsGet
+	in.SecretsCmd.AddCommand(in.SecretsGetCmd)
+
+	if in.SecretsPutCmd == nil {
+		in.SecretsPutCmd = SecretsPutCmd
+	}
+	in.SecretsPutCmd.RunE = in.RunSecretsPut
+	in.SecretsCmd.AddCommand(in.SecretsPutCmd)
+
+	if in.SecretsRotateCmd == nil {
+		in.SecretsRotateCmd = SecretsRotateCmd
+	}
+	in.SecretsRotateCmd.RunE = in.RunSecretsRotate
+	in.SecretsCmd.AddCommand(in.SecretsRotateCmd)
+
+	if in.SecretsListCmd == nil {
+		in.SecretsListCmd = SecretsListCmd
+	}
+	in.SecretsListCmd.RunE = in.RunSecretsList
+	in.SecretsCmd.AddCommand(in.SecretsListCmd)
+
+	var gb SecretsGetBind
+	if err := Bind(in.SecretsGetCmd, in.Viper, &gb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.SecretsGetCmd")
+	}
+
+	var pb SecretsPutBind
+	if err := Bind(in.SecretsPutCmd, in.Viper, &pb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.SecretsPutCmd")
+	}
+
+	var rb SecretsRotateBind
+	if err := Bind(in.SecretsRotateCmd, in.Viper, &rb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.SecretsRotateCmd")
+	}
+
+	var lb SecretsListBind
+	if err := Bind(in.SecretsListCmd, in.Viper, &lb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.SecretsListCmd")
+	}
+
+	return nil
+}
+
+var SecretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "manage the secrets manager secrets of the service, names are relative to the service unless they start with it",
+}
+
+var SecretsGetCmd = &cobra.Command{
+	Use:   "get <NAME>",
+	Short: "display the value of a secret",
+	Args:  cobra.ExactArgs(1),
+}
+
+var SecretsPutCmd = &cobra.Command{
+	Use:   "put <NAME> [VALUE]",
+	Short: "store a new current value of a secret, creating it when it does not exist",
+	Args:  cobra.RangeArgs(1, 2),
+}
+
+var SecretsRotateCmd = &cobra.Command{
+	Use:   "rotate <NAME>",
+	Short: "assign the rotation lambda and schedule of a secret and rotate it",
+	Args:  cobra.ExactArgs(1),
+}
+
+var SecretsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list the secrets of the service and their rotation",
+	Args:  cobra.NoArgs,
+}
+
+type SecretsGetBind struct {
+	Stage string `conf:"cli:stage, cli-u: Version stage to read (ex AWSPREVIOUS) (default AWSCURRENT)"`
+	IsRaw bool   `conf:"cli:raw, cli-u: Print only the value so it can be used in shell substitution"`
+}
+
+type SecretsGetConfig struct {
+	CmdConfig
+	SecretsGetBind
+}
+
+type SecretsPutBind struct {
+	FromFile    Filepath `conf:"cli:from-file, cli-u: Read the value from this file instead of the argument or stdin"`
+	Description string   `conf:"cli:description, cli-u: Description of a secret that is created"`
+	KMSKey      string   `conf:"cli:kms-key, cli-u: KMS key a secret that is created is encrypted with (default aws/secretsmanager)"`
+}
+
+type SecretsPutConfig struct {
+	CmdConfig
+	SecretsPutBind
+}
+
+type SecretsRotateBind struct {
+	Lambda     string `conf:"cli:lambda, cli-u: Feature or function arn that rotates the secret (default the one it rotates with)"`
+	Days       int64  `conf:"cli:days, cli-u: Rotate the secret every this many days"`
+	Schedule   string `conf:"cli:schedule, cli-u: Rotate the secret on this rate() or cron() expression instead of --days"`
+	Window     string `conf:"cli:window, cli-u: How long a rotation can take (ex 3h)"`
+	IsDeferred bool   `conf:"cli:deferred, cli-u: Only assign the rotation and let the schedule start it"`
+}
+
+type SecretsRotateConfig struct {
+	CmdConfig
+	SecretsRotateBind
+}
+
+type SecretsListBind struct {
+	IsAccount bool `conf:"cli:account, cli-u: List every secret of the account instead of the ones of the service"`
+}
+
+type SecretsListConfig struct {
+	CmdConfig
+	SecretsListBind
+}
+
+type SecretListReport []secrets.SecretReport
+
+func (r SecretListReport) TableRows() ([]string, [][]string) {
+	header := []string{"NAME", "ROTATING", "EVERY", "LAMBDA", "LAST ROTATED", "NEXT ROTATION"}
+	rows := make([][]string, 0, len(r))
+	for _, s := range r {
+		every := s.RotationSchedule
+		if s.RotationDays > 0 {
+			every = strconv.FormatInt(s.RotationDays, 10) + "d"
+		}
+		rows = append(rows, []string{s.Name, strconv.FormatBool(s.IsRotating), every, s.RotationLambda, s.LastRotated, s.NextRotation})
+	}
+
+	return header, rows
+}
+
+// RunSecretsGet runs `<service> infra secrets get <NAME>` which shows the
+// current value of the secret, or the value of --stage
+// `<service> infra secrets get db/credentials [--stage AWSPREVIOUS] [--raw]`
+func (i *Infra) RunSecretsGet(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	if i.SecretsAPI == nil {
+		return failure.System("i.SecretsAPI is not initialized")
+	}
+
+	var config SecretsGetConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	service, err := i.LoadService(config.CmdConfig)
+	if err != nil {
+		return failure.Wrap(err, "i.LoadService failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	name := serviceSecretName(service.Name.AppTitle(), args[0])
+	defer i.Step("secrets get")()
+	value, err := i.SecretsAPI.Value(ctx, name, config.Stage)
+	if err != nil {
+		return failure.Wrap(err, "secret (%s) could not be read", name)
+	}
+
+	if config.IsRaw {
+		if _, err = fmt.Fprintln(i.Stdout, value.Value); err != nil {
+			return failure.ToSystem(err, "fmt.Fprintln failed")
+		}
+		return nil
+	}
+
+	return i.DisplayFormatted(value)
+}
+
+// RunSecretsPut runs `<service> infra secrets put <NAME> [VALUE]` which
+// stores the value as the new current version of the secret. The value is
+// the argument, --from-file or else stdin, so credentials need not be
+// typed on the command line.
+// `<service> infra secrets put db/credentials --from-file creds.json [--description "orders db"]`
+func (i *Infra) RunSecretsPut(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	if i.SecretsAPI == nil {
+		return failure.System("i.SecretsAPI is not initialized")
+	}
+
+	var config SecretsPutConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if len(args) > 1 && !config.FromFile.IsEmpty() {
+		return failure.InvalidParam("the value is given as an argument and with --from-file, only one is allowed")
+	}
+
+	if err := i.Writable("secrets put"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	service, err := i.LoadService(config.CmdConfig)
+	if err != nil {
+		return failure.Wrap(err, "i.LoadService failed")
+	}
+
+	value, err := i.secretValue(config, args[1:])
+	if err != nil {
+		return failure.Wrap(err, "i.secretValue failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	name := serviceSecretName(service.Name.AppTitle(), args[0])
+	if i.DryRun("PutSecretValue", name, "") {
+		return i.DisplayFormatted(secrets.PutReport{Name: name})
+	}
+
+	settings := secrets.PutSettings{
+		Name:        name,
+		Value:       value,
+		Description: config.Description,
+		KMSKey:      config.KMSKey,
+	}
+
+	defer i.Step("secrets put")()
+	report, err := i.SecretsAPI.Put(ctx, settings)
+	if err != nil {
+		return failure.Wrap(err, "i.SecretsAPI.Put failed (%s)", name)
+	}
+
+	return i.DisplayFormatted(report)
+}
+
+// secretValue is the value of the argument, of --from-file or stdin, the
+// trailing newline of a file or stdin is removed
+func (i *Infra) secretValue(config SecretsPutConfig, args []string) (string, error) {
+	if len(args) > 0 {
+		return args[0], nil
+	}
+
+	var data []byte
+	var err error
+	switch {
+	case !config.FromFile.IsEmpty():
+		if data, err = os.ReadFile(config.FromFile.Path); err != nil {
+			return "", failure.ToSystem(err, "os.ReadFile failed (%s)", config.FromFile.Path)
+		}
+	case i.Stdin != nil:
+		if data, err = io.ReadAll(i.stdinReader()); err != nil {
+			return "", failure.ToSystem(err, "io.ReadAll failed for stdin")
+		}
+	}
+
+	value := strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+	if value == "" {
+		return "", failure.InvalidParam("the secret value is empty, give it as an argument or with --from-file or on stdin")
+	}
+
+	return value, nil
+}
+
+// RunSecretsRotate runs `<service> infra secrets rotate <NAME>` which
+// assigns the rotation lambda and schedule of the secret and starts a
+// rotation, unless --deferred. --lambda is a feature of the service or the
+// arn of any function, the function must allow secretsmanager.amazonaws.com
+// to invoke it.
+// `<service> infra secrets rotate db/credentials --lambda rotate_db --days 30 [--window 3h] [--deferred]`
+func (i *Infra) RunSecretsRotate(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	if i.SecretsAPI == nil {
+		return failure.System("i.SecretsAPI is not initialized")
+	}
+
+	var config SecretsRotateConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if err := i.Writable("secrets rotate"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	service, err := i.LoadService(config.CmdConfig)
+	if err != nil {
+		return failure.Wrap(err, "i.LoadService failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	lambdaARN := config.Lambda
+	if lambdaARN != "" && !strings.HasPrefix(lambdaARN, "arn:") {
+		_, feature, err := i.LoadFeature(config.CmdConfig, lambdaARN)
+		if err != nil {
+			return failure.Wrap(err, "i.LoadFeature failed, --lambda is not a feature or an arn")
+		}
+		if lambdaARN, err = i.featureFunctionARN(ctx, service, feature); err != nil {
+			return failure.Wrap(err, "i.featureFunctionARN failed")
+		}
+	}
+
+	settings := secrets.RotationSettings{
+		Name:       serviceSecretName(service.Name.AppTitle(), args[0]),
+		LambdaARN:  lambdaARN,
+		Days:       config.Days,
+		Schedule:   config.Schedule,
+		Window:     config.Window,
+		IsDeferred: config.IsDeferred,
+	}
+	if err = settings.Validate(); err != nil {
+		return failure.Wrap(err, "settings.Validate failed")
+	}
+
+	change := fmt.Sprintf("lambda (%s) days (%d) schedule (%s) immediately (%t)", lambdaARN, config.Days, config.Schedule, !config.IsDeferred)
+	if i.DryRun("RotateSecret", settings.Name, change) {
+		return i.DisplayFormatted(secrets.SecretReport{Name: settings.Name, RotationLambda: lambdaARN})
+	}
+
+	defer i.Step("secrets rotate")()
+	report, err := i.SecretsAPI.Rotate(ctx, settings)
+	if err != nil {
+		return failure.Wrap(err, "i.SecretsAPI.Rotate failed (%s)", settings.Name)
+	}
+
+	return i.DisplayFormatted(report)
+}
+
+// RunSecretsList runs `<service> infra secrets list` which shows the secrets
+// of the service, the ones named `<service>/...`, and how they rotate
+// `<service> infra secrets list [--account] [--format table]`
+func (i *Infra) RunSecretsList(cmd *cobra.Command, _ []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	if i.SecretsAPI == nil {
+		return failure.System("i.SecretsAPI is not initialized")
+	}
+
+	var config SecretsListConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	service, err := i.LoadService(config.CmdConfig)
+	if err != nil {
+		return failure.Wrap(err, "i.LoadService failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	prefix := service.Name.AppTitle() + "/"
+	if config.IsAccount {
+		prefix = ""
+	}
+
+	defer i.Step("secrets list")()
+	list, err := i.SecretsAPI.List(ctx, prefix)
+	if err != nil {
+		return failure.Wrap(err, "i.SecretsAPI.List failed (%s)", prefix)
+	}
+
+	return i.DisplayFormatted(SecretListReport(list))
+}
+
+// serviceSecretName is the name of a secret of the service,
+// `<app title>/<name>`, an arn or a name that already starts with the app
+// title is used as is
+func serviceSecretName(appTitle, name string) string {
+	if strings.HasPrefix(name, "arn:") {
+		return name
+	}
+
+	name = strings.TrimPrefix(name, "/")
+	if !strings.HasPrefix(name, appTitle+"/") {
+		name = appTitle + "/" + name
+	}
+
+	return name
+}
@@ -0,0 +1,128 @@
+package infra
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/ratelimit"
+	"github.com/rsb/sls/sqs"
+	"github.com/spf13/cobra"
+)
+
+func SetupSQSCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.SQSCmd == nil {
+		in.SQSCmd = SQSCmd
+	}
+	in.ParentCmd.AddCommand(in.SQSCmd)
+
+	if in.SQSRedriveCmd == nil {
+		in.SQSRedriveCmd = SQSRedriveCmd
+	}
+	in.SQSRedriveCmd.RunE = in.RunSQSRedrive
+	in.SQSCmd.AddCommand(in.SQSRedriveCmd)
+
+	var rb SQSRedriveBind
+	if err := Bind(in.SQSRedriveCmd, in.Viper, &rb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.SQSRedriveCmd")
+	}
+
+	return nil
+}
+
+var SQSCmd = &cobra.Command{
+	Use:   "sqs",
+	Short: "manage the sqs queues of the service",
+}
+
+var SQSRedriveCmd = &cobra.Command{
+	Use:   "redrive <DLQ> [SOURCE]",
+	Short: "move the messages of a dead letter queue back to its source queue",
+	Args:  cobra.RangeArgs(1, 2),
+}
+
+type SQSRedriveBind struct {
+	Max  int     `conf:"default:0, cli:max, cli-u: Most messages to move (default every message)"`
+	Rate float64 `conf:"default:5, cli:rate, cli-u: Batches of up to 10 messages moved per second (0 is unlimited)"`
+}
+
+type SQSRedriveConfig struct {
+	CmdConfig
+	SQSRedriveBind
+}
+
+// RunSQSRedrive runs `<service> infra sqs redrive <DLQ> [SOURCE]` which
+// moves the messages of the dead letter queue back to the source queue.
+// Queues are given by name or url, without SOURCE the dead letter queue
+// must be the dlq of exactly one queue.
+// `<service> infra sqs redrive <DLQ> [SOURCE] [--max 100] [--rate 5]`
+func (i *Infra) RunSQSRedrive(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	api, ok := i.QueueAPI.(QueueRedriving)
+	if !ok {
+		return failure.System("i.QueueAPI is not initialized or does not implement QueueRedriving")
+	}
+
+	var config SQSRedriveConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if err := i.Writable("sqs redrive"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	dlq, err := queueURL(ctx, api, args[0])
+	if err != nil {
+		return failure.Wrap(err, "queueURL failed for dlq (%s)", args[0])
+	}
+
+	var source string
+	if len(args) > 1 {
+		if source, err = queueURL(ctx, api, args[1]); err != nil {
+			return failure.Wrap(err, "queueURL failed for source (%s)", args[1])
+		}
+	} else {
+		sources, err := api.DeadLetterSources(ctx, dlq)
+		if err != nil {
+			return failure.Wrap(err, "api.DeadLetterSources failed (%s)", dlq)
+		}
+
+		if len(sources) != 1 {
+			return failure.InvalidParam("(%s) is the dlq of (%d) queues, give the SOURCE queue (%s)", args[0], len(sources), strings.Join(sources, ", "))
+		}
+		source = sources[0]
+	}
+
+	report, err := api.Redrive(ctx, sqs.RedriveSettings{
+		DeadLetterURL: dlq,
+		SourceURL:     source,
+		Max:           config.Max,
+		Limiter:       ratelimit.New(config.Rate, 1),
+	})
+	dErr := i.DisplayFormatted(report)
+	if err != nil {
+		return failure.Wrap(err, "api.Redrive failed (%s)", dlq)
+	}
+
+	return dErr
+}
+
+// queueURL accepts a queue url as is and resolves anything else as a name
+func queueURL(ctx context.Context, api QueueRedriving, queue string) (string, error) {
+	if strings.HasPrefix(queue, "https://") {
+		return queue, nil
+	}
+
+	return api.QueueURL(ctx, queue)
+}
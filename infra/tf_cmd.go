@@ -0,0 +1,382 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/spf13/cobra"
+)
+
+func SetupTFCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.TFCmd == nil {
+		in.TFCmd = TFCmd
+	}
+	in.ParentCmd.AddCommand(in.TFCmd)
+
+	var tb TFBind
+	if err := Bind(in.TFCmd, in.Viper, &tb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.TFCmd")
+	}
+
+	if in.TFPlanCmd == nil {
+		in.TFPlanCmd = TFPlanCmd
+	}
+	in.TFPlanCmd.RunE = in.RunTFPlan
+	in.TFCmd.AddCommand(in.TFPlanCmd)
+
+	if in.TFApplyCmd == nil {
+		in.TFApplyCmd = TFApplyCmd
+	}
+	in.TFApplyCmd.RunE = in.RunTFApply
+	in.TFCmd.AddCommand(in.TFApplyCmd)
+
+	var ab TFApplyBind
+	if err := Bind(in.TFApplyCmd, in.Viper, &ab); err != nil {
+		return failure.Wrap(err, "Bind failed for in.TFApplyCmd")
+	}
+
+	if in.TFDestroyCmd == nil {
+		in.TFDestroyCmd = TFDestroyCmd
+	}
+	in.TFDestroyCmd.RunE = in.RunTFDestroy
+	in.TFCmd.AddCommand(in.TFDestroyCmd)
+
+	return nil
+}
+
+var TFCmd = &cobra.Command{
+	Use:   "tf",
+	Short: "run terraform against the resources of the service",
+}
+
+var TFPlanCmd = &cobra.Command{
+	Use:   "plan [RESOURCE]",
+	Short: "init the resource and write its terraform plan to the plan file",
+	Args:  cobra.MaximumNArgs(1),
+}
+
+var TFApplyCmd = &cobra.Command{
+	Use:   "apply [RESOURCE]",
+	Short: "apply the terraform of the resource",
+	Args:  cobra.MaximumNArgs(1),
+}
+
+var TFDestroyCmd = &cobra.Command{
+	Use:   "destroy [RESOURCE]",
+	Short: "destroy everything the terraform of the resource manages",
+	Args:  cobra.MaximumNArgs(1),
+}
+
+type TFBind struct {
+	Binary        string   `conf:"global-flag, default:terraform, cli:tf-binary, cli-u: Path or name of the terraform binary"`
+	VarFiles      []string `conf:"global-flag, cli:var-file, cli-u: Comma separated tfvars files added to the ones of the resource"`
+	Vars          []string `conf:"global-flag, cli:var, cli-u: Comma separated KEY=VALUE terraform vars that override the resource vars"`
+	IsReconfigure bool     `conf:"global-flag, cli:reconfigure, cli-u: Ignore the saved backend config during init"`
+}
+
+type TFConfig struct {
+	CmdConfig
+	TFBind
+}
+
+type TFApplyBind struct {
+	IsFromPlan bool `conf:"cli:from-plan, cli-u: Apply the plan file written by tf plan instead of planning again"`
+}
+
+type TFApplyConfig struct {
+	TFConfig
+	TFApplyBind
+}
+
+// RunTFPlan runs `<service> infra tf plan [RESOURCE]` which inits the
+// resource with its backend and writes its plan to the plan file of the
+// resource. The terraform output is streamed to stdout.
+// `<service> infra tf plan [RESOURCE] [--var-file f.tfvars] [--var k=v]`
+func (i *Infra) RunTFPlan(cmd *cobra.Command, args []string) error {
+	var config TFConfig
+	tf, resource, err := i.terraform(cmd, args, &config, &config)
+	if err != nil {
+		return failure.Wrap(err, "i.terraform failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	if err = i.TFInit(ctx, tf, resource, config.TFBind); err != nil {
+		return failure.Wrap(err, "i.TFInit failed")
+	}
+
+	opts := []tfexec.PlanOption{tfexec.Out(resource.PlanFile)}
+	for _, f := range tfVarFiles(resource, config.TFBind) {
+		opts = append(opts, tfexec.VarFile(f))
+	}
+	for _, v := range tfVars(resource, config.TFBind) {
+		opts = append(opts, tfexec.Var(v))
+	}
+
+	defer i.Step("tf plan")()
+	hasChanges, err := tf.Plan(ctx, opts...)
+	if err != nil {
+		return failure.ToSystem(err, "tf.Plan failed (%s)", resource.Name)
+	}
+
+	if !hasChanges {
+		if err := i.Display(fmt.Sprintf("(%s) has no changes\n", resource.Name)); err != nil {
+			return failure.Wrap(err, "i.Display failed")
+		}
+	}
+
+	return nil
+}
+
+// RunTFApply runs `<service> infra tf apply [RESOURCE]`. With --from-plan
+// the plan file written by `tf plan` is applied as is, the vars were fixed
+// when it was planned.
+// `<service> infra tf apply [RESOURCE] [--from-plan] [--var k=v]`
+func (i *Infra) RunTFApply(cmd *cobra.Command, args []string) error {
+	var config TFApplyConfig
+	tf, resource, err := i.terraform(cmd, args, &config, &config.TFConfig)
+	if err != nil {
+		return failure.Wrap(err, "i.terraform failed")
+	}
+
+	if err = i.Writable("tf apply"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	if err = i.TFInit(ctx, tf, resource, config.TFBind); err != nil {
+		return failure.Wrap(err, "i.TFInit failed")
+	}
+
+	if config.IsFromPlan {
+		if _, err = os.Stat(resource.PlanFile); err != nil {
+			return failure.ToNotFound(err, "plan file (%s) does not exist, run tf plan first", resource.PlanFile)
+		}
+	}
+
+	if err = i.TFApply(ctx, tf, resource, config.TFBind, config.IsFromPlan); err != nil {
+		return failure.Wrap(err, "i.TFApply failed")
+	}
+
+	return nil
+}
+
+// TFApply applies the resource with its vars, or its plan file with fromPlan
+func (i *Infra) TFApply(ctx context.Context, tf *tfexec.Terraform, r sls.TFResource, b TFBind, fromPlan bool) error {
+	var opts []tfexec.ApplyOption
+	if fromPlan {
+		opts = append(opts, tfexec.DirOrPlan(r.PlanFile))
+	} else {
+		for _, f := range tfVarFiles(r, b) {
+			opts = append(opts, tfexec.VarFile(f))
+		}
+		for _, v := range tfVars(r, b) {
+			opts = append(opts, tfexec.Var(v))
+		}
+	}
+
+	defer i.Step("tf apply")()
+	if err := tf.Apply(ctx, opts...); err != nil {
+		return failure.ToSystem(err, "tf.Apply failed (%s)", r.Name)
+	}
+
+	return nil
+}
+
+// RunTFDestroy runs `<service> infra tf destroy [RESOURCE]` which asks for
+// confirmation, unless --yes, before destroying every resource in the state.
+// `<service> infra tf destroy [RESOURCE] [--yes]`
+func (i *Infra) RunTFDestroy(cmd *cobra.Command, args []string) error {
+	var config TFConfig
+	tf, resource, err := i.terraform(cmd, args, &config, &config)
+	if err != nil {
+		return failure.Wrap(err, "i.terraform failed")
+	}
+
+	if err = i.Writable("tf destroy"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	prompt := fmt.Sprintf("destroy everything terraform manages for (%s) in (%s)?", resource.Name, config.EnvName())
+	if err = i.RequireConfirm(config.CmdConfig, prompt); err != nil {
+		return failure.Wrap(err, "i.RequireConfirm failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	if err = i.TFInit(ctx, tf, resource, config.TFBind); err != nil {
+		return failure.Wrap(err, "i.TFInit failed")
+	}
+
+	var opts []tfexec.DestroyOption
+	for _, f := range tfVarFiles(resource, config.TFBind) {
+		opts = append(opts, tfexec.VarFile(f))
+	}
+	for _, v := range tfVars(resource, config.TFBind) {
+		opts = append(opts, tfexec.Var(v))
+	}
+
+	defer i.Step("tf destroy")()
+	if err = tf.Destroy(ctx, opts...); err != nil {
+		return failure.ToSystem(err, "tf.Destroy failed (%s)", resource.Name)
+	}
+
+	return nil
+}
+
+// terraform processes the config of a tf command, resolves its resource and
+// builds its terraform runner
+func (i *Infra) terraform(cmd *cobra.Command, args []string, c interface{}, tc *TFConfig) (*tfexec.Terraform, sls.TFResource, error) {
+	var resource sls.TFResource
+	if err := i.Validate(); err != nil {
+		return nil, resource, failure.Wrap(err, "i.Validate failed")
+	}
+
+	if err := i.Process(cmd, c); err != nil {
+		return nil, resource, failure.Wrap(err, "i.Configure failed")
+	}
+
+	service, err := i.LoadService(tc.CmdConfig)
+	if err != nil {
+		return nil, resource, failure.Wrap(err, "i.LoadService failed")
+	}
+
+	var name string
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	resource, err = i.TFResource(service, name)
+	if err != nil {
+		return nil, resource, failure.Wrap(err, "i.TFResource failed")
+	}
+
+	tf, err := i.NewTerraform(resource, tc.TFBind)
+	if err != nil {
+		return nil, resource, failure.Wrap(err, "i.NewTerraform failed")
+	}
+
+	return tf, resource, nil
+}
+
+// NewTerraform builds a terraform runner in the dir of the resource that
+// streams to the stdout and stderr of the infra
+func (i *Infra) NewTerraform(r sls.TFResource, b TFBind) (*tfexec.Terraform, error) {
+	binary, err := i.terraformBinary(b)
+	if err != nil {
+		return nil, failure.Wrap(err, "i.terraformBinary failed")
+	}
+
+	tf, err := tfexec.NewTerraform(r.Dir, binary)
+	if err != nil {
+		return nil, failure.ToSystem(err, "tfexec.NewTerraform failed (%s)", r.Dir)
+	}
+	tf.SetStdout(i.Stdout)
+	tf.SetStderr(i.Stderr)
+
+	return tf, nil
+}
+
+// TFResource finds the resource by name. The service resource is used when
+// name is empty, the global resources are only known when i.Terraform is set.
+func (i *Infra) TFResource(service *sls.MicroService, name string) (sls.TFResource, error) {
+	if name == "" || name == service.Resource.Name {
+		return service.Resource, nil
+	}
+
+	known := []string{service.Resource.Name}
+	if i.Terraform != nil {
+		global := i.Terraform.GlobalResources
+		if name == global.RemoteState.Name {
+			return global.RemoteState, nil
+		}
+
+		if r, ok := global.Config[name]; ok {
+			return r, nil
+		}
+
+		known = append(known, global.RemoteState.Name)
+		for k := range global.Config {
+			known = append(known, k)
+		}
+	}
+	sort.Strings(known)
+
+	return sls.TFResource{}, failure.NotFound("terraform resource (%s) is not one of (%v)", name, known)
+}
+
+// TFInit runs terraform init with the backend of the resource, the remote
+// state resource has none and keeps its state locally
+func (i *Infra) TFInit(ctx context.Context, tf *tfexec.Terraform, r sls.TFResource, b TFBind) error {
+	defer i.Step("tf init")()
+	opts := []tfexec.InitOption{tfexec.Reconfigure(b.IsReconfigure)}
+	if r.IsBackend() && r.Backend.Bucket != "" {
+		opts = append(opts,
+			tfexec.BackendConfig(fmt.Sprintf("bucket=%s", r.Backend.Bucket)),
+			tfexec.BackendConfig(fmt.Sprintf("key=%s", r.Backend.Key)),
+			tfexec.BackendConfig(fmt.Sprintf("region=%s", r.Backend.Region)),
+			tfexec.BackendConfig(fmt.Sprintf("dynamodb_table=%s", r.Backend.DynamoTable)),
+		)
+	}
+
+	if err := tf.Init(ctx, opts...); err != nil {
+		return failure.ToSystem(err, "tf.Init failed (%s)", r.Name)
+	}
+
+	return nil
+}
+
+// terraformBinary prefers the binary of i.Terraform, otherwise --tf-binary
+// is looked up in the PATH
+func (i *Infra) terraformBinary(b TFBind) (string, error) {
+	if i.Terraform != nil && i.Terraform.BinaryDir != "" {
+		name := i.Terraform.BinaryName
+		if name == "" {
+			name = sls.TerraformName
+		}
+		return filepath.Join(i.Terraform.BinaryDir, name), nil
+	}
+
+	path, err := exec.LookPath(b.Binary)
+	if err != nil {
+		return "", failure.ToNotFound(err, "terraform binary (%s) is not in the PATH", b.Binary)
+	}
+
+	return path, nil
+}
+
+func tfVarFiles(r sls.TFResource, b TFBind) []string {
+	return append(append([]string{}, r.VarFiles...), b.VarFiles...)
+}
+
+// tfVars are the vars of the resource sorted by name, then --var, a later
+// var of the same name wins
+func tfVars(r sls.TFResource, b TFBind) []string {
+	names := make([]string, 0, len(r.Vars))
+	for k := range r.Vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	result := make([]string, 0, len(names)+len(b.Vars))
+	for _, k := range names {
+		result = append(result, fmt.Sprintf("%s=%s", k, r.Vars[k]))
+	}
+
+	return append(result, b.Vars...)
+}
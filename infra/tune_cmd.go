@@ -0,0 +1,238 @@
+package infra
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/cwmetrics"
+	"github.com/rsb/sls/lambda"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// TimeoutHeadroom and MemoryHeadroom are multiplied with the peaks seen
+	// in the window, so a recommendation leaves room for a slower day
+	TimeoutHeadroom = 1.5
+	MemoryHeadroom  = 1.3
+	// MemoryStep is what the recommended memory size is rounded up to
+	MemoryStep = 64
+)
+
+func SetupTuneCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.TuneCmd == nil {
+		in.TuneCmd = TuneCmd
+	}
+	in.TuneCmd.RunE = in.RunTune
+	in.ParentCmd.AddCommand(in.TuneCmd)
+
+	var tb TuneBind
+	if err := Bind(in.TuneCmd, in.Viper, &tb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.TuneCmd")
+	}
+
+	return nil
+}
+
+var TuneCmd = &cobra.Command{
+	Use:   "tune [FEATURE]",
+	Short: "recommend the memory size and timeout of lambdas from their cloudwatch metrics",
+	Args:  cobra.MaximumNArgs(1),
+}
+
+type TuneBind struct {
+	IsApply bool          `conf:"cli:apply, cli-u: Update the lambdas with the recommended values"`
+	Window  time.Duration `conf:"default:168h, cli:window, cli-u: How far back the metrics are read (ex 24h)"`
+}
+
+type TuneConfig struct {
+	CmdConfig
+	TuneBind
+}
+
+// TuneReport compares the limits a feature is deployed with to the
+// recommended ones. PeakDuration is in milliseconds and PeakMemory in MB, a
+// metric without datapoints in the window keeps its current limit.
+type TuneReport struct {
+	Feature      string                `json:"feature"`
+	Current      lambda.FunctionLimits `json:"current"`
+	Recommended  lambda.FunctionLimits `json:"recommended"`
+	PeakDuration float64               `json:"peak_duration_ms"`
+	PeakMemory   float64               `json:"peak_memory_mb"`
+	IsApplied    bool                  `json:"is_applied"`
+}
+
+type TuneReports []TuneReport
+
+func (r TuneReports) TableRows() ([]string, [][]string) {
+	header := []string{"FEATURE", "MEMORY", "RECOMMENDED MEMORY", "TIMEOUT", "RECOMMENDED TIMEOUT", "APPLIED"}
+	rows := make([][]string, 0, len(r))
+	for _, t := range r {
+		rows = append(rows, []string{
+			t.Feature,
+			limitCell(t.Current.MemorySize),
+			limitCell(t.Recommended.MemorySize),
+			limitCell(t.Current.Timeout),
+			limitCell(t.Recommended.Timeout),
+			strconv.FormatBool(t.IsApplied),
+		})
+	}
+
+	return header, rows
+}
+
+func limitCell(v int32) string {
+	if v == 0 {
+		return "-"
+	}
+	return strconv.Itoa(int(v))
+}
+
+// RunTune runs `<service> infra tune [FEATURE]` which reads the peak
+// duration and memory of each feature over --window and recommends a
+// timeout and memory size with some headroom. Memory is only reported for
+// functions with the lambda insights extension. With --apply the lambdas are
+// updated, the env vars are left as they are.
+// `<service> infra tune [FEATURE] [--all] [--window 24h] [--apply]`
+func (i *Infra) RunTune(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	api, ok := i.LambdaAPI.(LambdaTuning)
+	if !ok {
+		return failure.System("i.LambdaAPI is not initialized or does not implement LambdaTuning")
+	}
+
+	if i.MetricsAPI == nil {
+		return failure.System("i.MetricsAPI is not initialized")
+	}
+
+	var config TuneConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if config.IsApply {
+		if err := i.Writable("tune --apply"); err != nil {
+			return failure.Wrap(err, "i.Writable failed")
+		}
+	}
+
+	var features []sls.Feature
+	switch {
+	case len(args) > 0:
+		_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+		if err != nil {
+			return failure.Wrap(err, "i.LoadFeature failed")
+		}
+		features = append(features, feature)
+	case config.IsAll:
+		service, err := i.LoadService(config.CmdConfig)
+		if err != nil {
+			return failure.Wrap(err, "i.LoadService failed")
+		}
+		for _, f := range service.Features {
+			features = append(features, f)
+		}
+		sort.Slice(features, func(a, b int) bool {
+			return features[a].Name < features[b].Name
+		})
+	default:
+		return failure.InvalidParam("a feature is required, or --all to tune every feature")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+	end := time.Now()
+	start := end.Add(-config.Window)
+	reports := make(TuneReports, 0, len(features))
+	for _, feature := range features {
+		report, err := i.TuneFeature(ctx, api, feature, start, end)
+		if err != nil {
+			return failure.Wrap(err, "i.TuneFeature failed (%s)", feature.Name)
+		}
+
+		if config.IsApply && report.Recommended != report.Current {
+			if _, err = api.UpdateLimits(ctx, feature.QualifiedName, report.Recommended); err != nil {
+				return failure.Wrap(err, "api.UpdateLimits failed (%s)", feature.Name)
+			}
+			report.IsApplied = true
+		}
+		reports = append(reports, report)
+	}
+
+	return i.DisplayFormatted(reports)
+}
+
+// TuneFeature reads the deployed limits and the peak metrics of the feature
+// between start and end
+func (i *Infra) TuneFeature(ctx context.Context, api LambdaTuning, feature sls.Feature, start, end time.Time) (TuneReport, error) {
+	report := TuneReport{Feature: feature.Name}
+	current, err := api.Limits(ctx, feature.QualifiedName)
+	if err != nil {
+		return report, failure.Wrap(err, "api.Limits failed")
+	}
+	report.Current = current
+	report.Recommended = current
+
+	q := cwmetrics.LambdaFunctionQuery(cwmetrics.DurationMetric, feature.QualifiedName, start, end)
+	duration, ok, err := i.MetricsAPI.Peak(ctx, q, "Maximum")
+	if err != nil {
+		return report, failure.Wrap(err, "i.MetricsAPI.Peak failed (%s)", q.Name)
+	}
+	if ok {
+		report.PeakDuration = duration
+		report.Recommended.Timeout = RecommendTimeout(duration)
+	}
+
+	q = cwmetrics.InsightsQuery(cwmetrics.UsedMemoryMetric, feature.QualifiedName, start, end)
+	memory, ok, err := i.MetricsAPI.Peak(ctx, q, "Maximum")
+	if err != nil {
+		return report, failure.Wrap(err, "i.MetricsAPI.Peak failed (%s)", q.Name)
+	}
+	if ok {
+		report.PeakMemory = memory
+		report.Recommended.MemorySize = RecommendMemory(memory)
+	}
+
+	return report, nil
+}
+
+// RecommendTimeout is the peak duration, in milliseconds, with headroom in
+// whole seconds
+func RecommendTimeout(peakMS float64) int32 {
+	seconds := int32(math.Ceil(peakMS * TimeoutHeadroom / 1000))
+	if seconds < 3 {
+		seconds = 3
+	}
+
+	if seconds > lambda.MaxTimeout {
+		seconds = lambda.MaxTimeout
+	}
+
+	return seconds
+}
+
+// RecommendMemory is the peak memory, in MB, with headroom rounded up to
+// the next MemoryStep
+func RecommendMemory(peakMB float64) int32 {
+	size := int32(math.Ceil(peakMB*MemoryHeadroom/MemoryStep)) * MemoryStep
+	if size < lambda.MinMemorySize {
+		size = lambda.MinMemorySize
+	}
+
+	if size > lambda.MaxMemorySize {
+		size = lambda.MaxMemorySize
+	}
+
+	return size
+}
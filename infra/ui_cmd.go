@@ -0,0 +1,353 @@
+package infra
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/cwlogs"
+	"github.com/rsb/sls/lambda"
+	"github.com/spf13/cobra"
+)
+
+const (
+	clearScreen = "\033[H\033[2J"
+	// UIStatusInterval is how often the status line of a running deploy is
+	// redrawn
+	UIStatusInterval = time.Second
+)
+
+func SetupUICmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.UICmd == nil {
+		in.UICmd = UICmd
+	}
+	in.UICmd.RunE = in.RunUI
+	in.ParentCmd.AddCommand(in.UICmd)
+
+	var ub UIBind
+	if err := Bind(in.UICmd, in.Viper, &ub); err != nil {
+		return failure.Wrap(err, "Bind failed for in.UICmd")
+	}
+
+	return nil
+}
+
+var UICmd = &cobra.Command{
+	Use:   "ui",
+	Short: "interactive terminal ui to browse features and inspect, deploy and tail them",
+	Args:  cobra.NoArgs,
+}
+
+type UIBind struct {
+	IsPlain  bool          `conf:"cli:plain, cli-u: Do not clear the screen between views"`
+	LogSince time.Duration `conf:"default:10m, cli:log-since, cli-u: How far back the log pane starts (ex 1h)"`
+}
+
+type UIConfig struct {
+	CmdConfig
+	UIBind
+}
+
+// RunUI runs `<service> infra ui` which lists the features of the service
+// and, for the one picked, shows its env vars and params, deploys it with a
+// live status line or tails its logs until enter is pressed. Every view is
+// built on InfraService, the same api other tools use.
+// `<service> infra ui [--plain] [--log-since 1h]`
+func (i *Infra) RunUI(cmd *cobra.Command, _ []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	var config UIConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	svc, err := NewInfraService(i, config.CmdConfig)
+	if err != nil {
+		return failure.Wrap(err, "NewInfraService failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	ui := UI{
+		Service: svc,
+		In:      i.stdinReader(),
+		Out:     i.Stdout,
+		Bind:    config.UIBind,
+	}
+
+	return ui.Run(ctx)
+}
+
+// UI is the terminal ui of `infra ui`, it reads one command per line from In
+type UI struct {
+	Service *InfraService
+	In      *bufio.Reader
+	Out     io.Writer
+	Bind    UIBind
+}
+
+// Run shows the feature list until q is entered, stdin is closed or ctx is
+// done
+func (u *UI) Run(ctx context.Context) error {
+	names := u.Service.FeatureNames()
+	if len(names) == 0 {
+		return failure.NotFound("service (%s) has no features", u.Service.Service.Name.AppTitle())
+	}
+
+	msg := ""
+	for ctx.Err() == nil {
+		u.clear()
+		u.printf("%s (%s)\n\n", u.Service.Service.Name.AppTitle(), u.Service.Config.EnvName())
+		for idx, name := range names {
+			feature, _ := u.Service.Feature(name)
+			u.printf("  %2d) %-40s %s\n", idx+1, name, feature.Trigger)
+		}
+		if msg != "" {
+			u.printf("\n%s\n", msg)
+			msg = ""
+		}
+
+		answer, ok := u.prompt("\nfeature number or name, q to quit")
+		if !ok || answer == "q" {
+			return nil
+		}
+
+		name, found := pickFeature(names, answer)
+		if !found {
+			msg = fmt.Sprintf("no feature (%s)", answer)
+			continue
+		}
+
+		if err := u.feature(ctx, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// feature is the view of one feature, it returns to the list on b
+func (u *UI) feature(ctx context.Context, name string) error {
+	for ctx.Err() == nil {
+		u.printf("\n[%s] e) env  p) params  d) deploy  c) deploy env vars  l) logs  b) back\n", name)
+		answer, ok := u.prompt("action")
+		if !ok {
+			return nil
+		}
+
+		var err error
+		switch answer {
+		case "e":
+			err = u.env(ctx, name)
+		case "p":
+			err = u.params(ctx, name)
+		case "d":
+			err = u.deploy(ctx, name, false)
+		case "c":
+			err = u.deploy(ctx, name, true)
+		case "l":
+			err = u.logs(ctx, name)
+		case "b", "":
+			return nil
+		default:
+			u.printf("unknown action (%s)\n", answer)
+		}
+
+		// a failed action is shown and the ui keeps running
+		if err != nil {
+			u.printf("error: %v\n", err)
+		}
+	}
+
+	return nil
+}
+
+func (u *UI) env(ctx context.Context, name string) error {
+	resolution, err := u.Service.FeatureEnv(ctx, name)
+	if err != nil {
+		return failure.Wrap(err, "u.Service.FeatureEnv failed")
+	}
+
+	u.clear()
+	u.printf("env vars of (%s)\n\n", name)
+	for _, n := range resolution.Names() {
+		v := resolution.Vars[n]
+		value := v.Value
+		if !v.IsSet {
+			value = "<not set>"
+		}
+		u.printf("  %-40s %-10s %s\n", n, v.Source, value)
+	}
+
+	return nil
+}
+
+func (u *UI) params(ctx context.Context, name string) error {
+	params, err := u.Service.ExportParams(ctx, name)
+	if err != nil {
+		return failure.Wrap(err, "u.Service.ExportParams failed")
+	}
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	u.clear()
+	u.printf("params of (%s)\n\n", name)
+	for _, k := range keys {
+		u.printf("  %-50s %s\n", k, params[k])
+	}
+
+	return nil
+}
+
+// deploy runs the deploy in the background and redraws its status line
+// every UIStatusInterval until it is done
+func (u *UI) deploy(ctx context.Context, name string, isEnvOnly bool) error {
+	what := "code"
+	if isEnvOnly {
+		what = "env vars"
+	}
+
+	prompt := fmt.Sprintf("deploy the %s of (%s) to env (%s)?", what, name, u.Service.Config.EnvName())
+	ok, err := u.Service.Infra.Confirm(u.Service.Config, prompt)
+	if err != nil {
+		return failure.Wrap(err, "Confirm failed")
+	}
+	if !ok {
+		return nil
+	}
+
+	b := DefaultDeployBind()
+	b.IsEnvOnly = isEnvOnly
+
+	type outcome struct {
+		result FeatureDeployResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	start := time.Now()
+	go func() {
+		result, err := u.Service.DeployFeature(ctx, name, b)
+		done <- outcome{result: result, err: err}
+	}()
+
+	ticker := time.NewTicker(UIStatusInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			u.printf("\r  deploying %s of (%s) %s ", what, name, time.Since(start).Truncate(time.Second))
+		case o := <-done:
+			elapsed := time.Since(start).Truncate(time.Millisecond)
+			if o.err != nil {
+				u.printf("\r  deploy of (%s) failed after %s\n", name, elapsed)
+				return o.err
+			}
+
+			u.printf("\r  deployed %s of (%s) in %s\n", what, name, elapsed)
+			for _, r := range []*lambda.FeatureUpdateReport{o.result.Code, o.result.Config} {
+				if r != nil {
+					u.printf("  %+v\n", *r)
+				}
+			}
+			return nil
+		}
+	}
+}
+
+// logs follows the log group of the feature until enter is pressed
+func (u *UI) logs(ctx context.Context, name string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	u.clear()
+	u.printf("logs of (%s), press enter to stop\n\n", name)
+
+	in := cwlogs.TailSettings{Since: u.Bind.LogSince, IsFollow: true}
+	done := make(chan error, 1)
+	go func() {
+		done <- u.Service.TailLogs(ctx, name, in, func(e cwlogs.LogEvent) error {
+			u.printf("%s %s", e.Timestamp.Format(time.RFC3339), e.Message)
+			if !strings.HasSuffix(e.Message, "\n") {
+				u.printf("\n")
+			}
+			return nil
+		})
+	}()
+
+	stopped := make(chan struct{})
+	go func() {
+		_, _ = u.In.ReadString('\n')
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		cancel()
+		if err := <-done; err != nil && ctx.Err() == nil {
+			return err
+		}
+		return nil
+	case err := <-done:
+		// the tail ended on its own, wait for the enter that closes the pane
+		if err != nil {
+			u.printf("error: %v\n", err)
+		}
+		<-stopped
+		return nil
+	}
+}
+
+func (u *UI) prompt(label string) (string, bool) {
+	u.printf("%s> ", label)
+	line, err := u.In.ReadString('\n')
+	if err != nil && line == "" {
+		return "", false
+	}
+
+	return strings.TrimSpace(line), true
+}
+
+func (u *UI) clear() {
+	if !u.Bind.IsPlain {
+		u.printf(clearScreen)
+	}
+}
+
+func (u *UI) printf(format string, a ...interface{}) {
+	_, _ = fmt.Fprintf(u.Out, format, a...)
+}
+
+// pickFeature matches a list number or a feature name
+func pickFeature(names []string, answer string) (string, bool) {
+	if n, err := strconv.Atoi(answer); err == nil {
+		if n < 1 || n > len(names) {
+			return "", false
+		}
+		return names[n-1], true
+	}
+
+	for _, name := range names {
+		if name == answer {
+			return name, true
+		}
+	}
+
+	return "", false
+}
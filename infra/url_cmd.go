@@ -0,0 +1,187 @@
+package infra
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/lambda"
+	"github.com/spf13/cobra"
+)
+
+func SetupURLCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.URLCmd == nil {
+		in.URLCmd = URLCmd
+	}
+	in.URLCmd.RunE = in.RunURL
+	in.ParentCmd.AddCommand(in.URLCmd)
+
+	var ub URLBind
+	if err := Bind(in.URLCmd, in.Viper, &ub); err != nil {
+		return failure.Wrap(err, "Bind failed for in.URLCmd")
+	}
+
+	return nil
+}
+
+var URLCmd = &cobra.Command{
+	Use:   "url <FEATURE>",
+	Short: "show, create or update the function url of a lambda",
+	Args:  cobra.ExactArgs(1),
+}
+
+// URLBind only changes what is given, an existing function url keeps the
+// settings whose flag was not set
+type URLBind struct {
+	Alias       string   `conf:"cli:alias, cli-u: Alias the function url is on (default the unqualified function)"`
+	AuthType    string   `conf:"cli:auth-type, cli-u: NONE for a public url or AWS_IAM for sigv4 signed requests (default AWS_IAM)"`
+	InvokeMode  string   `conf:"cli:invoke-mode, cli-u: BUFFERED or RESPONSE_STREAM for features built on furl (default BUFFERED)"`
+	CORS        []string `conf:"cli:cors, cli-u: Comma separated origins allowed by cors (ex https://app.example.com)"`
+	CORSMethods []string `conf:"cli:cors-methods, cli-u: Comma separated methods allowed by cors (ex GET)"`
+	CORSHeaders []string `conf:"cli:cors-headers, cli-u: Comma separated request headers allowed by cors"`
+	CORSMaxAge  int32    `conf:"cli:cors-max-age, cli-u: Seconds a browser caches the cors preflight response"`
+	IsNoCORS    bool     `conf:"cli:no-cors, cli-u: Remove the cors config of the function url"`
+}
+
+type URLConfig struct {
+	CmdConfig
+	URLBind
+}
+
+// urlFlags are the flags that change the function url
+var urlFlags = []string{"auth-type", "invoke-mode", "cors", "cors-methods", "cors-headers", "cors-max-age", "no-cors"}
+
+// URLReport is the function url of the lambda of a feature
+type URLReport struct {
+	Feature string `json:"feature"`
+	*lambda.URLReport
+}
+
+func (r URLReport) TableRows() ([]string, [][]string) {
+	header := []string{"FEATURE", "ALIAS", "URL", "AUTH", "INVOKE MODE", "CORS ORIGINS"}
+	var origins string
+	if r.CORS != nil {
+		origins = strings.Join(r.CORS.AllowOrigins, " ")
+	}
+
+	return header, [][]string{{r.Feature, r.Alias, r.URL, r.AuthType, r.InvokeMode, origins}}
+}
+
+// RunURL runs `<service> infra url <FEATURE>` which shows the function url
+// of the lambda. With any of the url flags the url is created when the
+// lambda has none or updated with only what the flags change. A NONE url
+// also gets the permission that lets anyone invoke it.
+// `<service> infra url <FEATURE> [--alias live] [--auth-type NONE|AWS_IAM] [--invoke-mode BUFFERED|RESPONSE_STREAM] [--cors https://a.com --cors-methods GET,POST | --no-cors]`
+func (i *Infra) RunURL(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	api, ok := i.LambdaAPI.(LambdaURLManagement)
+	if !ok {
+		return failure.System("i.LambdaAPI is not initialized or does not implement LambdaURLManagement")
+	}
+
+	var config URLConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	var changed []string
+	for _, flag := range urlFlags {
+		if cmd.Flags().Changed(flag) {
+			changed = append(changed, "--"+flag)
+		}
+	}
+
+	if config.IsNoCORS && (len(config.CORS) > 0 || len(config.CORSMethods) > 0 || len(config.CORSHeaders) > 0 || config.CORSMaxAge > 0) {
+		return failure.InvalidParam("--no-cors can not be combined with the other --cors flags")
+	}
+
+	settings := lambda.URLSettings{
+		Alias:      config.Alias,
+		AuthType:   strings.ToUpper(config.AuthType),
+		InvokeMode: strings.ToUpper(config.InvokeMode),
+		CORS:       config.corsSettings(),
+	}
+
+	_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+	settings.QualifiedName = feature.QualifiedName
+
+	if err = settings.Validate(); err != nil {
+		return failure.Wrap(err, "settings.Validate failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	current, err := api.FunctionURL(ctx, feature.QualifiedName, config.Alias)
+	if err != nil && !failure.IsNotFound(err) {
+		return failure.Wrap(err, "api.FunctionURL failed (%s)", feature.QualifiedName)
+	}
+
+	if len(changed) == 0 {
+		if current == nil {
+			return failure.NotFound("(%s) has no function url, use --auth-type to create one", feature.Name)
+		}
+		return i.DisplayFormatted(URLReport{Feature: feature.Name, URLReport: current})
+	}
+
+	if err = i.Writable("url"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	call := "UpdateFunctionUrlConfig"
+	if current == nil {
+		call = "CreateFunctionUrlConfig"
+	}
+	if i.DryRun(call, feature.QualifiedName, strings.Join(changed, ", ")) {
+		return nil
+	}
+
+	defer i.Step("url")()
+	var report *lambda.URLReport
+	if current == nil {
+		report, err = api.CreateFunctionURL(ctx, settings)
+	} else {
+		report, err = api.UpdateFunctionURL(ctx, settings)
+	}
+	if err != nil {
+		return failure.Wrap(err, "api.%s failed (%s)", call, feature.QualifiedName)
+	}
+
+	if report.AuthType == lambda.NoAuth {
+		if err = api.AllowPublicURL(ctx, feature.QualifiedName, config.Alias); err != nil {
+			return failure.Wrap(err, "api.AllowPublicURL failed (%s)", feature.QualifiedName)
+		}
+		_, _ = fmt.Fprintf(i.Stderr, "[infra] (%s) is public, anyone can invoke it\n", report.URL)
+	}
+
+	return i.DisplayFormatted(URLReport{Feature: feature.Name, URLReport: report})
+}
+
+// corsSettings is nil when no cors flag was given so the cors config is
+// kept, and empty with --no-cors so it is removed
+func (c URLConfig) corsSettings() *lambda.CORSSettings {
+	if c.IsNoCORS {
+		return &lambda.CORSSettings{}
+	}
+
+	if len(c.CORS) == 0 && len(c.CORSMethods) == 0 && len(c.CORSHeaders) == 0 && c.CORSMaxAge == 0 {
+		return nil
+	}
+
+	return &lambda.CORSSettings{
+		AllowOrigins: c.CORS,
+		AllowMethods: c.CORSMethods,
+		AllowHeaders: c.CORSHeaders,
+		MaxAge:       c.CORSMaxAge,
+	}
+}
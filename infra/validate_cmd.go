@@ -0,0 +1,213 @@
+package infra
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/rsb/conf"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/spf13/cobra"
+)
+
+func SetupValidateCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.ValidateCmd == nil {
+		in.ValidateCmd = ValidateCmd
+	}
+	in.ValidateCmd.RunE = in.RunValidate
+	in.ParentCmd.AddCommand(in.ValidateCmd)
+
+	var vb ValidateBind
+	if err := Bind(in.ValidateCmd, in.Viper, &vb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.ValidateCmd")
+	}
+
+	return nil
+}
+
+var ValidateCmd = &cobra.Command{
+	Use:   "validate [FEATURE]",
+	Short: "check the configuration of features against parameter store before a deploy",
+	Args:  cobra.MaximumNArgs(1),
+}
+
+type ValidateBind struct {
+	IsFailOnDefaults bool `conf:"cli:fail-on-defaults, cli-u: Fail when a var has no param and falls back on its default"`
+}
+
+type ValidateConfig struct {
+	CmdConfig
+	ValidateBind
+}
+
+// FeatureValidation is what `validate` found for one feature. Invalid is the
+// env var name to the reason its value does not parse, Defaults is the env
+// var name to the default value it silently falls back on.
+type FeatureValidation struct {
+	Feature  string            `json:"feature"`
+	Missing  []string          `json:"missing,omitempty"`
+	Invalid  map[string]string `json:"invalid,omitempty"`
+	Defaults map[string]string `json:"defaults,omitempty"`
+}
+
+func (v FeatureValidation) IsValid() bool {
+	return len(v.Missing) == 0 && len(v.Invalid) == 0
+}
+
+type ValidateReport []FeatureValidation
+
+func (r ValidateReport) TableRows() ([]string, [][]string) {
+	header := []string{"FEATURE", "VAR", "PROBLEM", "DETAIL"}
+	var rows [][]string
+	for _, v := range r {
+		for _, name := range v.Missing {
+			rows = append(rows, []string{v.Feature, name, "missing", "required and has no param"})
+		}
+
+		for _, name := range sortedKeys(v.Invalid) {
+			rows = append(rows, []string{v.Feature, name, "invalid", v.Invalid[name]})
+		}
+
+		for _, name := range sortedKeys(v.Defaults) {
+			rows = append(rows, []string{v.Feature, name, "default", v.Defaults[name]})
+		}
+	}
+
+	return header, rows
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// RunValidate runs `<service> infra validate [FEATURE]` which resolves the
+// configuration of each feature from parameter store, the same way deploy
+// does, and reports the required vars without a value, the values that do
+// not parse into their field and the defaults that will be used because no
+// param is set. It fails on missing or invalid vars, and on defaults with
+// --fail-on-defaults, so it can gate a deploy in ci.
+// `<service> infra validate [FEATURE] [--all] [--fail-on-defaults]`
+func (i *Infra) RunValidate(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	if i.PStoreAPI == nil {
+		return failure.System("i.PStoreAPI is not initialized")
+	}
+
+	var config ValidateConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	var service *sls.MicroService
+	var features []sls.Feature
+	switch {
+	case len(args) > 0:
+		s, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+		if err != nil {
+			return failure.Wrap(err, "i.LoadFeature failed")
+		}
+		service = s
+		features = append(features, feature)
+	case config.IsAll:
+		s, err := i.LoadService(config.CmdConfig)
+		if err != nil {
+			return failure.Wrap(err, "i.LoadService failed")
+		}
+		service = s
+		for _, f := range s.Features {
+			features = append(features, f)
+		}
+		sort.Slice(features, func(a, b int) bool {
+			return features[a].Name < features[b].Name
+		})
+	default:
+		return failure.InvalidParam("a feature is required, or --all to validate every feature")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+	report := make(ValidateReport, 0, len(features))
+	var failed []string
+	for _, feature := range features {
+		v, err := i.ValidateFeature(ctx, service.Name.AppTitle(), feature)
+		if err != nil {
+			return failure.Wrap(err, "i.ValidateFeature failed (%s)", feature.Name)
+		}
+		report = append(report, v)
+
+		if !v.IsValid() || (config.IsFailOnDefaults && len(v.Defaults) > 0) {
+			failed = append(failed, feature.Name)
+		}
+	}
+
+	if err := i.DisplayFormatted(report); err != nil {
+		return failure.Wrap(err, "i.DisplayFormatted failed")
+	}
+	if len(failed) > 0 {
+		return failure.InvalidState("(%s) failed validation", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// ValidateFeature resolves the vars of the feature with its defaults and
+// parameter store, then parses every value into its conf field
+func (i *Infra) ValidateFeature(ctx context.Context, appTitle string, feature sls.Feature) (FeatureValidation, error) {
+	result := FeatureValidation{
+		Feature:  feature.Name,
+		Invalid:  map[string]string{},
+		Defaults: map[string]string{},
+	}
+
+	resolver := NewEnvResolver(appTitle, i.PStoreAPI, PStoreStage).WithDefaults(IncludeDefaults)
+	resolution, err := resolver.Resolve(ctx, feature)
+	if err != nil && !failure.IsConfig(err) {
+		return result, failure.Wrap(err, "resolver.Resolve failed")
+	}
+
+	fields, err := envFields(feature.Conf)
+	if err != nil {
+		return result, failure.Wrap(err, "envFields failed")
+	}
+
+	for _, name := range resolution.Names() {
+		v := resolution.Vars[name]
+		field, ok := fields[name]
+		if !v.IsSet {
+			if ok && field.IsRequired() {
+				result.Missing = append(result.Missing, name)
+			}
+			continue
+		}
+
+		if v.Source == DefaultsStage {
+			result.Defaults[name] = v.Value
+		}
+
+		if !ok {
+			continue
+		}
+
+		// parse into a zero value of the field so the conf is not changed
+		target := reflect.New(field.ReflectValue.Type()).Elem()
+		if err = conf.ProcessField(v.Value, target); err != nil {
+			result.Invalid[name] = err.Error()
+		}
+	}
+
+	return result, nil
+}
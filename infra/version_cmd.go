@@ -0,0 +1,181 @@
+package infra
+
+import (
+	"fmt"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/lambda"
+	"github.com/spf13/cobra"
+)
+
+func SetupVersionCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.VersionCmd == nil {
+		in.VersionCmd = VersionCmd
+	}
+	in.ParentCmd.AddCommand(in.VersionCmd)
+
+	if in.VersionPublishCmd == nil {
+		in.VersionPublishCmd = VersionPublishCmd
+	}
+	in.VersionPublishCmd.RunE = in.RunVersionPublish
+	in.VersionCmd.AddCommand(in.VersionPublishCmd)
+
+	if in.VersionListCmd == nil {
+		in.VersionListCmd = VersionListCmd
+	}
+	in.VersionListCmd.RunE = in.RunVersionList
+	in.VersionCmd.AddCommand(in.VersionListCmd)
+
+	var pb VersionPublishBind
+	if err := Bind(in.VersionPublishCmd, in.Viper, &pb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.VersionPublishCmd")
+	}
+
+	return nil
+}
+
+var VersionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "publish and list the immutable versions of a lambda",
+}
+
+var VersionPublishCmd = &cobra.Command{
+	Use:   "publish <FEATURE>",
+	Short: "publish the deployed code of the lambda as a new version",
+	Args:  cobra.ExactArgs(1),
+}
+
+var VersionListCmd = &cobra.Command{
+	Use:   "list <FEATURE>",
+	Short: "list the published versions of the lambda, oldest first",
+	Args:  cobra.ExactArgs(1),
+}
+
+type VersionPublishBind struct {
+	Description string `conf:"cli:description, cli-u: Description of the version (defaults to the git sha of the service)"`
+	CodeSHA256  string `conf:"cli:code-sha256, cli-u: Only publish when the deployed code has this sha256"`
+}
+
+type VersionPublishConfig struct {
+	CmdConfig
+	VersionPublishBind
+}
+
+type VersionPublishReport struct {
+	Feature string                `json:"feature"`
+	Version *lambda.VersionReport `json:"version"`
+}
+
+func (r VersionPublishReport) TableRows() ([]string, [][]string) {
+	return VersionListReport{Feature: r.Feature, Versions: []lambda.VersionReport{*r.Version}}.TableRows()
+}
+
+type VersionListReport struct {
+	Feature  string                 `json:"feature"`
+	Versions []lambda.VersionReport `json:"versions"`
+}
+
+func (r VersionListReport) TableRows() ([]string, [][]string) {
+	header := []string{"FEATURE", "VERSION", "CODE SHA256", "CREATED", "DESCRIPTION"}
+	rows := make([][]string, 0, len(r.Versions))
+	for _, v := range r.Versions {
+		rows = append(rows, []string{r.Feature, v.Version, v.CodeSHA256, v.LastModified, v.Description})
+	}
+
+	return header, rows
+}
+
+// RunVersionPublish runs `<service> infra version publish <FEATURE>` which
+// publishes what is deployed to $LATEST as an immutable version, described
+// by the git sha of the service unless --description is given.
+// `<service> infra version publish <FEATURE> [--description "release 1.2"] [--code-sha256 SHA]`
+func (i *Infra) RunVersionPublish(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	api, ok := i.LambdaAPI.(LambdaVersionPublishing)
+	if !ok {
+		return failure.System("i.LambdaAPI is not initialized or does not implement LambdaVersionPublishing")
+	}
+
+	var config VersionPublishConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if err := i.Writable("version publish"); err != nil {
+		return failure.Wrap(err, "i.Writable failed")
+	}
+
+	service, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	description := config.Description
+	if description == "" {
+		if description, err = sls.GitHead(ctx, service.RootDir()); err != nil {
+			return failure.Wrap(err, "sls.GitHead failed, use --description outside of a git repo")
+		}
+	}
+
+	if i.DryRun("PublishVersion", feature.QualifiedName, fmt.Sprintf("described as (%s)", description)) {
+		return nil
+	}
+
+	defer i.Step("version publish")()
+	version, err := api.PublishVersion(ctx, feature.QualifiedName, description, config.CodeSHA256)
+	if err != nil {
+		return failure.Wrap(err, "api.PublishVersion failed (%s)", feature.QualifiedName)
+	}
+
+	return i.DisplayFormatted(VersionPublishReport{Feature: feature.Name, Version: version})
+}
+
+// RunVersionList runs `<service> infra version list <FEATURE>` which shows
+// the published versions of the lambda with their code sha256, so a version
+// can be picked for `alias update` or `deploy rollback`
+// `<service> infra version list <FEATURE>`
+func (i *Infra) RunVersionList(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	api, ok := i.LambdaAPI.(LambdaVersionPublishing)
+	if !ok {
+		return failure.System("i.LambdaAPI is not initialized or does not implement LambdaVersionPublishing")
+	}
+
+	var config CmdConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	_, feature, err := i.LoadFeature(config, args[0])
+	if err != nil {
+		return failure.Wrap(err, "i.LoadFeatureFromFirstArg")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	defer i.Step("version list")()
+	versions, err := api.Versions(ctx, feature.QualifiedName)
+	if err != nil {
+		return failure.Wrap(err, "api.Versions failed (%s)", feature.QualifiedName)
+	}
+	if versions == nil {
+		versions = []lambda.VersionReport{}
+	}
+
+	return i.DisplayFormatted(VersionListReport{Feature: feature.Name, Versions: versions})
+}
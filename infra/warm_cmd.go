@@ -0,0 +1,163 @@
+package infra
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/lambda"
+	"github.com/spf13/cobra"
+)
+
+func SetupWarmCmd(in *Infra) error {
+	if err := in.Validate(); err != nil {
+		return failure.Wrap(err, "in.Validate failed")
+	}
+
+	if in.WarmCmd == nil {
+		in.WarmCmd = WarmCmd
+	}
+	in.WarmCmd.RunE = in.RunWarm
+	in.ParentCmd.AddCommand(in.WarmCmd)
+
+	var wb WarmBind
+	if err := Bind(in.WarmCmd, in.Viper, &wb); err != nil {
+		return failure.Wrap(err, "Bind failed for in.WarmCmd")
+	}
+
+	return nil
+}
+
+var WarmCmd = &cobra.Command{
+	Use:   "warm [FEATURE]",
+	Short: "invoke the lambdas with the warmup payload to keep instances warm",
+	Args:  cobra.MaximumNArgs(1),
+}
+
+type WarmBind struct {
+	Concurrency int `conf:"default:1, cli:concurrency, cli-u: How many instances of each lambda are warmed at once"`
+}
+
+type WarmConfig struct {
+	CmdConfig
+	WarmBind
+}
+
+// RunWarm runs `<service> infra warm <FEATURE|--all>` which sends the
+// warmup control payload to each lambda --concurrency times at once, so that
+// many instances are kept warm. The runners answer it without running the
+// feature. The command fails when any invocation failed.
+// `<service> infra warm <FEATURE|--all> [--concurrency 5]`
+func (i *Infra) RunWarm(cmd *cobra.Command, args []string) error {
+	if err := i.Validate(); err != nil {
+		return failure.Wrap(err, "i.Validate failed")
+	}
+
+	if i.LambdaAPI == nil {
+		return failure.System("i.LambdaAPI is not initialized")
+	}
+
+	var config WarmConfig
+	if err := i.Process(cmd, &config); err != nil {
+		return failure.Wrap(err, "i.Configure failed")
+	}
+
+	if !config.IsAll && len(args) == 0 {
+		return failure.InvalidParam("a feature or --all is required")
+	}
+
+	if config.IsAll && len(args) > 0 {
+		return failure.InvalidParam("a feature (%s) can not be given with --all", args[0])
+	}
+
+	if config.Concurrency < 1 {
+		return failure.InvalidParam("--concurrency (%d) must be at least 1", config.Concurrency)
+	}
+
+	var features []sls.Feature
+	if config.IsAll {
+		service, err := i.LoadService(config.CmdConfig)
+		if err != nil {
+			return failure.Wrap(err, "i.LoadService failed")
+		}
+		for _, f := range service.Features {
+			features = append(features, f)
+		}
+		sort.Slice(features, func(a, b int) bool {
+			return features[a].Name < features[b].Name
+		})
+	} else {
+		_, feature, err := i.LoadFeature(config.CmdConfig, args[0])
+		if err != nil {
+			return failure.Wrap(err, "i.LoadFeature failed")
+		}
+		features = append(features, feature)
+	}
+
+	payload, err := sls.NewWarmupPayload().Marshal()
+	if err != nil {
+		return failure.Wrap(err, "sls.NewWarmupPayload().Marshal failed")
+	}
+
+	ctx, stop := i.Context(cmd)
+	defer stop()
+
+	defer i.Step("warm")()
+	summary := NewBulkReport("warm")
+	progress := i.StartProgress("warm", len(features))
+	for _, feature := range features {
+		start := time.Now()
+		err := i.WarmFeature(ctx, feature, payload, config.Concurrency)
+		summary.Record(feature.Name, start, err)
+		progress.Add(1, feature.Name)
+	}
+	progress.Done()
+
+	if err := i.DisplayFormatted(summary); err != nil {
+		return failure.Wrap(err, "i.DisplayFormatted failed")
+	}
+	if err = summary.Err(); err != nil {
+		return failure.Wrap(err, "(%d) features failed to warm", len(summary.Failed()))
+	}
+
+	return nil
+}
+
+// WarmFeature invokes the lambda with payload n times at once. Every
+// invocation is synchronous so the instances are busy at the same time and
+// lambda starts one for each.
+func (i *Infra) WarmFeature(ctx context.Context, feature sls.Feature, payload []byte, n int) error {
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for idx := 0; idx < n; idx++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			in := lambda.InvokePayload{QualifiedName: feature.QualifiedName, Payload: payload}
+			report, err := i.LambdaAPI.Invoke(ctx, in)
+			switch {
+			case err != nil:
+				errs[idx] = failure.Wrap(err, "i.LambdaAPI.Invoke failed (%s)", feature.QualifiedName)
+			case report.IsFunctionError():
+				errs[idx] = failure.System("feature (%s) returned a function error (%s)", feature.Name, report.FunctionError)
+			}
+		}(idx)
+	}
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+
+	if len(failed) > 0 {
+		return failure.Wrap(failure.Multiple(failed), "(%d) of (%d) warmup invocations failed", len(failed), n)
+	}
+
+	return nil
+}
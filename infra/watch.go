@@ -0,0 +1,149 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+)
+
+// WatchDebounce is how long `deploy --watch` waits for the changes to settle
+// before it rebuilds, editors and formatters write a file in several steps
+const WatchDebounce = 300 * time.Millisecond
+
+// WatchFeature deploys the feature, then redeploys its code every time a file
+// in its code dir changes until ctx is done. A failed build or deploy is
+// reported on stderr and the watch goes on, the next change retries it.
+// With one --target-account every deploy goes to that account.
+func (i *Infra) WatchFeature(ctx context.Context, service *sls.MicroService, feature sls.Feature, config DeployConfig) error {
+	if config.IsEnvOnly || config.Canary > 0 {
+		return failure.InvalidParam("--watch deploys code, it can not be combined with --env-only or --canary")
+	}
+
+	accounts := config.TargetAccountIDs()
+	if len(accounts) > 1 {
+		return failure.InvalidParam("--watch deploys into one account, (%d) --target-account were given", len(accounts))
+	}
+
+	if len(accounts) == 1 {
+		home := i.clients()
+		defer i.setClients(home)
+		if err := i.UseAccount(accounts[0], config.TargetRoleName()); err != nil {
+			return failure.Wrap(err, "i.UseAccount failed (%s)", accounts[0])
+		}
+	}
+
+	if err := i.ServeMetrics(ctx, config.CmdConfig); err != nil {
+		return failure.Wrap(err, "i.ServeMetrics failed")
+	}
+
+	settings := service.NewBuildSettings(feature)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return failure.ToSystem(err, "fsnotify.NewWatcher failed")
+	}
+	defer func() { _ = watcher.Close() }()
+
+	if err = watchDir(watcher, settings.CodeDir); err != nil {
+		return failure.Wrap(err, "watchDir failed (%s)", settings.CodeDir)
+	}
+
+	i.watchf("watching (%s) for (%s), ctrl-c to stop", settings.CodeDir, feature.Name)
+	result, err := i.DeployFeature(ctx, service, feature, settings, config)
+	i.watchDeployed(feature, result, err)
+
+	config.DeployBucket = config.S3Bucket(service)
+	timer := time.NewTimer(WatchDebounce)
+	timer.Stop()
+	var changed string
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.Errors:
+			i.watchf("watch error: %v", err)
+		case e := <-watcher.Events:
+			if !isWatchedChange(e) {
+				continue
+			}
+
+			// new dirs are not watched by fsnotify until they are added
+			if e.Has(fsnotify.Create) {
+				if info, err := os.Stat(e.Name); err == nil && info.IsDir() {
+					if err = watchDir(watcher, e.Name); err != nil {
+						i.watchf("watch error: %v", err)
+					}
+				}
+			}
+			changed = e.Name
+			timer.Reset(WatchDebounce)
+		case <-timer.C:
+			i.watchf("(%s) changed, deploying (%s)", changed, feature.Name)
+			result := FeatureDeployResult{Feature: feature.Name}
+			result.Code, err = i.DeployFeatureCode(ctx, service, feature, settings, config)
+			if err == nil {
+				err = i.publishDeploy(ctx, service, feature, result, config)
+			}
+			i.watchDeployed(feature, result, err)
+		}
+	}
+}
+
+func (i *Infra) watchDeployed(feature sls.Feature, result FeatureDeployResult, err error) {
+	if err != nil {
+		i.watchf("deploy of (%s) failed: %v", feature.Name, err)
+		return
+	}
+
+	if result.Code != nil {
+		if err = i.DisplayFormatted(result.Code); err != nil {
+			i.watchf("display of (%s) failed: %v", feature.Name, err)
+		}
+	}
+	i.watchf("deployed (%s)", feature.Name)
+}
+
+func (i *Infra) watchf(format string, a ...interface{}) {
+	_, _ = fmt.Fprintf(i.Stderr, "[watch] "+format+"\n", a...)
+}
+
+// watchDir adds dir and every dir below it to the watcher, hidden dirs like
+// .git are skipped
+func watchDir(w *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return failure.ToSystem(err, "filepath.WalkDir failed (%s)", path)
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		if err = w.Add(path); err != nil {
+			return failure.ToSystem(err, "w.Add failed (%s)", path)
+		}
+		return nil
+	})
+}
+
+// isWatchedChange drops chmod events and the temp and backup files of
+// editors
+func isWatchedChange(e fsnotify.Event) bool {
+	if e.Op == fsnotify.Chmod {
+		return false
+	}
+
+	name := filepath.Base(e.Name)
+	return !strings.HasPrefix(name, ".") && !strings.HasSuffix(name, "~")
+}
@@ -0,0 +1,162 @@
+// Package kms implements a kms client used to encrypt sensitive lambda
+// environment variables on deploy and decrypt them when a feature cold starts
+package kms
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsKMS "github.com/aws/aws-sdk-go-v2/service/kms"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	// LambdaFunctionNameCtxKey is the encryption context key the aws console
+	// uses when it encrypts lambda environment variables. We use the same key
+	// so values encrypted by either side can be decrypted by the other.
+	LambdaFunctionNameCtxKey = "LambdaFunctionName"
+	AWSLambdaFunctionNameVar = "AWS_LAMBDA_FUNCTION_NAME"
+)
+
+type AdapterAPI interface {
+	Encrypt(ctx context.Context, params *awsKMS.EncryptInput, optFns ...func(*awsKMS.Options)) (*awsKMS.EncryptOutput, error)
+	Decrypt(ctx context.Context, params *awsKMS.DecryptInput, optFns ...func(*awsKMS.Options)) (*awsKMS.DecryptOutput, error)
+}
+
+type Client struct {
+	api         AdapterAPI
+	retryPolicy *retry.Policy
+}
+
+func NewClientWithConfig(cfg aws.Config) *Client {
+	api := awsKMS.NewFromConfig(retry.NoSDKRetries(cfg))
+	return NewClient(api)
+}
+
+func NewClient(api AdapterAPI) *Client {
+	return &Client{api: api}
+}
+
+// RetryPolicy is the policy the client retries its calls with
+func (c *Client) RetryPolicy() retry.Policy {
+	if c.retryPolicy == nil {
+		return retry.Default()
+	}
+	return *c.retryPolicy
+}
+
+// SetRetryPolicy replaces retry.Default for the calls made by the client
+func (c *Client) SetRetryPolicy(p retry.Policy) {
+	c.retryPolicy = &p
+}
+
+// Encrypt will encrypt the plain text with the given kms key and return the
+// cipher text base64 encoded, which is safe to store as an env var value.
+// When functionName is not empty it is used as the encryption context.
+func (c *Client) Encrypt(ctx context.Context, keyARN, plain, functionName string) (string, error) {
+	if keyARN == "" {
+		return "", failure.InvalidParam("[keyARN] kms key is empty")
+	}
+
+	in := awsKMS.EncryptInput{
+		KeyId:             aws.String(keyARN),
+		Plaintext:         []byte(plain),
+		EncryptionContext: encryptionContext(functionName),
+	}
+
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.Encrypt, &in)
+	if err != nil {
+		return "", failure.ToSystem(err, "c.api.Encrypt failed (%s)", keyARN)
+	}
+
+	return base64.StdEncoding.EncodeToString(out.CiphertextBlob), nil
+}
+
+// Decrypt is the inverse of Encrypt. The functionName must match the one
+// used during encryption.
+func (c *Client) Decrypt(ctx context.Context, cipher, functionName string) (string, error) {
+	blob, err := base64.StdEncoding.DecodeString(cipher)
+	if err != nil {
+		return "", failure.ToInvalidParam(err, "base64.StdEncoding.DecodeString failed")
+	}
+
+	in := awsKMS.DecryptInput{
+		CiphertextBlob:    blob,
+		EncryptionContext: encryptionContext(functionName),
+	}
+
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.Decrypt, &in)
+	if err != nil {
+		return "", failure.ToSystem(err, "c.api.Decrypt failed")
+	}
+
+	return string(out.Plaintext), nil
+}
+
+// EncryptEnv returns a copy of vars where the values of the given names are
+// replaced with their encrypted form. Names not present in vars are ignored.
+func (c *Client) EncryptEnv(ctx context.Context, keyARN, functionName string, vars map[string]string, names ...string) (map[string]string, error) {
+	result := map[string]string{}
+	for k, v := range vars {
+		result[k] = v
+	}
+
+	for _, name := range names {
+		value, ok := vars[name]
+		if !ok {
+			continue
+		}
+
+		cipher, err := c.Encrypt(ctx, keyARN, value, functionName)
+		if err != nil {
+			return nil, failure.Wrap(err, "c.Encrypt failed (%s)", name)
+		}
+		result[name] = cipher
+	}
+
+	return result, nil
+}
+
+// DecryptEnv is designed to be called at cold start, before the feature's
+// configuration is processed. It replaces each named env var with its
+// decrypted value, using the running function's name as encryption context.
+func (c *Client) DecryptEnv(ctx context.Context, names ...string) error {
+	functionName := os.Getenv(AWSLambdaFunctionNameVar)
+	for _, name := range names {
+		cipher, ok := os.LookupEnv(name)
+		if !ok || cipher == "" {
+			continue
+		}
+
+		plain, err := c.Decrypt(ctx, cipher, functionName)
+		if err != nil {
+			return failure.Wrap(err, "c.Decrypt failed (%s)", name)
+		}
+
+		if err = os.Setenv(name, plain); err != nil {
+			return failure.ToSystem(err, "os.Setenv failed (%s)", name)
+		}
+	}
+
+	return nil
+}
+
+// DecryptEnvMust is the same as DecryptEnv except it will panic if it fails.
+// Useful in a lambda's main where there is nothing to return an error to.
+func DecryptEnvMust(ctx context.Context, cfg aws.Config, names ...string) {
+	if err := NewClientWithConfig(cfg).DecryptEnv(ctx, names...); err != nil {
+		panic(failure.Wrap(err, "DecryptEnv failed"))
+	}
+}
+
+func encryptionContext(functionName string) map[string]string {
+	if functionName == "" {
+		return nil
+	}
+
+	return map[string]string{LambdaFunctionNameCtxKey: functionName}
+}
@@ -0,0 +1,327 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsLambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	// LiveAlias is the alias production traffic is sent to
+	LiveAlias = "live"
+	// CanaryAlias is where a version is tried before it goes live
+	CanaryAlias = "canary"
+	// PreviousAlias keeps the version live pointed at before it last moved,
+	// it is what a quick rollback goes back to
+	PreviousAlias = "previous"
+)
+
+// AliasSettings is where an alias should send its invocations, Weights are
+// the same as in AliasReport. On an update a nil Weights keeps the routing
+// config of the alias and an empty map clears it. With a RevisionID the
+// update is refused when the alias changed since it was read.
+type AliasSettings struct {
+	QualifiedName string
+	Name          string
+	Version       string
+	Description   string
+	Weights       map[string]float64
+	RevisionID    string
+}
+
+func (s AliasSettings) Validate() error {
+	if s.QualifiedName == "" || s.Name == "" || s.Version == "" {
+		return failure.InvalidParam("qualifiedName (%s), alias (%s) and version (%s) are required", s.QualifiedName, s.Name, s.Version)
+	}
+
+	// lambda routes to one additional version at most
+	if len(s.Weights) > 1 {
+		return failure.InvalidParam("alias (%s) can route to one additional version, (%d) were given", s.Name, len(s.Weights))
+	}
+
+	for version, weight := range s.Weights {
+		if version == s.Version {
+			return failure.InvalidParam("alias (%s) points at version (%s), it can not also route to it", s.Name, version)
+		}
+		if weight <= 0 || weight >= 1 {
+			return failure.InvalidParam("weight (%g) of version (%s) must be > 0 and < 1", weight, version)
+		}
+	}
+
+	return nil
+}
+
+func (s AliasSettings) routing() *types.AliasRoutingConfiguration {
+	if s.Weights == nil {
+		return nil
+	}
+
+	return &types.AliasRoutingConfiguration{AdditionalVersionWeights: s.Weights}
+}
+
+// AliasReport is where an alias sends its invocations. Weights are the
+// share, from 0 to 1, of the invocations routed to other versions, the rest
+// goes to Version.
+type AliasReport struct {
+	QualifiedName string             `json:"qualified_name"`
+	Name          string             `json:"name"`
+	Version       string             `json:"version"`
+	Weights       map[string]float64 `json:"weights,omitempty"`
+	ARN           string             `json:"arn,omitempty"`
+	Description   string             `json:"description,omitempty"`
+	RevisionID    string             `json:"revision_id,omitempty"`
+	IsCreated     bool               `json:"is_created,omitempty"`
+}
+
+// toAliasReport converts the alias lambda returns, the get, create and
+// update outputs all carry the same fields
+func toAliasReport(qualifiedName string, a types.AliasConfiguration) AliasReport {
+	report := AliasReport{
+		QualifiedName: qualifiedName,
+		Name:          aws.ToString(a.Name),
+		Version:       aws.ToString(a.FunctionVersion),
+		ARN:           aws.ToString(a.AliasArn),
+		Description:   aws.ToString(a.Description),
+		RevisionID:    aws.ToString(a.RevisionId),
+	}
+	if a.RoutingConfig != nil && len(a.RoutingConfig.AdditionalVersionWeights) > 0 {
+		report.Weights = a.RoutingConfig.AdditionalVersionWeights
+	}
+
+	return report
+}
+
+// Alias reads where the alias of the function currently points
+func (c *Client) Alias(ctx context.Context, qualifiedName, alias string) (*AliasReport, error) {
+	if qualifiedName == "" || alias == "" {
+		return nil, failure.InvalidParam("qualifiedName (%s) and alias (%s) are required", qualifiedName, alias)
+	}
+
+	in := awsLambda.GetAliasInput{FunctionName: aws.String(qualifiedName), Name: aws.String(alias)}
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetAlias, &in)
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, failure.ToNotFound(err, "alias (%s) of (%s) does not exist", alias, qualifiedName)
+		}
+		return nil, failure.ToSystem(err, "c.api.GetAlias failed (%s, %s)", qualifiedName, alias)
+	}
+
+	report := toAliasReport(qualifiedName, types.AliasConfiguration{
+		Name:            aws.String(alias),
+		FunctionVersion: out.FunctionVersion,
+		AliasArn:        out.AliasArn,
+		Description:     out.Description,
+		RevisionId:      out.RevisionId,
+		RoutingConfig:   out.RoutingConfig,
+	})
+
+	return &report, nil
+}
+
+// ShiftTraffic keeps the alias on its current version and routes weight,
+// from 0 to 1, of its invocations to version. A missing alias is created
+// pointing at version, there is nothing to shift from.
+func (c *Client) ShiftTraffic(ctx context.Context, qualifiedName, alias, version string, weight float64) (*AliasReport, error) {
+	if weight < 0 || weight >= 1 {
+		return nil, failure.InvalidParam("[weight] (%g) must be >= 0 and < 1", weight)
+	}
+
+	current, err := c.Alias(ctx, qualifiedName, alias)
+	if failure.IsNotFound(err) {
+		return c.createAlias(ctx, qualifiedName, alias, version)
+	}
+	if err != nil {
+		return nil, failure.Wrap(err, "c.Alias failed")
+	}
+
+	if current.Version == version {
+		return current, nil
+	}
+
+	weights := map[string]float64{version: weight}
+	if err = c.updateAlias(ctx, qualifiedName, alias, current.Version, weights); err != nil {
+		return nil, failure.Wrap(err, "c.updateAlias failed")
+	}
+	current.Weights = weights
+
+	return current, nil
+}
+
+// PromoteAlias points the alias at version and drops its routing config,
+// every invocation goes to version
+func (c *Client) PromoteAlias(ctx context.Context, qualifiedName, alias, version string) (*AliasReport, error) {
+	if err := c.updateAlias(ctx, qualifiedName, alias, version, map[string]float64{}); err != nil {
+		return nil, failure.Wrap(err, "c.updateAlias failed")
+	}
+
+	return &AliasReport{QualifiedName: qualifiedName, Name: alias, Version: version}, nil
+}
+
+// ResetRouting drops the routing config of the alias, every invocation goes
+// back to the version the alias points at
+func (c *Client) ResetRouting(ctx context.Context, qualifiedName, alias string) (*AliasReport, error) {
+	current, err := c.Alias(ctx, qualifiedName, alias)
+	if err != nil {
+		return nil, failure.Wrap(err, "c.Alias failed")
+	}
+
+	if err = c.updateAlias(ctx, qualifiedName, alias, current.Version, map[string]float64{}); err != nil {
+		return nil, failure.Wrap(err, "c.updateAlias failed")
+	}
+	current.Weights = nil
+
+	return current, nil
+}
+
+// CreateAlias creates the alias pointing at s.Version, it fails with
+// failure.AlreadyExists when the function already has it
+func (c *Client) CreateAlias(ctx context.Context, s AliasSettings) (*AliasReport, error) {
+	if err := s.Validate(); err != nil {
+		return nil, failure.Wrap(err, "s.Validate failed")
+	}
+
+	in := awsLambda.CreateAliasInput{
+		FunctionName:    aws.String(s.QualifiedName),
+		Name:            aws.String(s.Name),
+		FunctionVersion: aws.String(s.Version),
+		RoutingConfig:   s.routing(),
+	}
+	if s.Description != "" {
+		in.Description = aws.String(s.Description)
+	}
+
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.CreateAlias, &in)
+	if err != nil {
+		var conflict *types.ResourceConflictException
+		if errors.As(err, &conflict) {
+			return nil, failure.ToAlreadyExists(err, "alias (%s) of (%s) already exists", s.Name, s.QualifiedName)
+		}
+		return nil, failure.ToSystem(err, "c.api.CreateAlias failed (%s, %s)", s.QualifiedName, s.Name)
+	}
+
+	report := toAliasReport(s.QualifiedName, types.AliasConfiguration{
+		Name:            aws.String(s.Name),
+		FunctionVersion: out.FunctionVersion,
+		AliasArn:        out.AliasArn,
+		Description:     out.Description,
+		RevisionId:      out.RevisionId,
+		RoutingConfig:   out.RoutingConfig,
+	})
+
+	return &report, nil
+}
+
+// UpdateAlias moves the alias to s.Version, see AliasSettings for how its
+// routing config is kept or replaced
+func (c *Client) UpdateAlias(ctx context.Context, s AliasSettings) (*AliasReport, error) {
+	if err := s.Validate(); err != nil {
+		return nil, failure.Wrap(err, "s.Validate failed")
+	}
+
+	in := awsLambda.UpdateAliasInput{
+		FunctionName:    aws.String(s.QualifiedName),
+		Name:            aws.String(s.Name),
+		FunctionVersion: aws.String(s.Version),
+		RoutingConfig:   s.routing(),
+	}
+	if s.Description != "" {
+		in.Description = aws.String(s.Description)
+	}
+	if s.RevisionID != "" {
+		in.RevisionId = aws.String(s.RevisionID)
+	}
+
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.UpdateAlias, &in)
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, failure.ToNotFound(err, "alias (%s) of (%s) does not exist", s.Name, s.QualifiedName)
+		}
+		var moved *types.PreconditionFailedException
+		if errors.As(err, &moved) {
+			return nil, failure.ToInvalidState(err, "alias (%s) of (%s) changed since revision (%s)", s.Name, s.QualifiedName, s.RevisionID)
+		}
+		return nil, failure.ToSystem(err, "c.api.UpdateAlias failed (%s, %s)", s.QualifiedName, s.Name)
+	}
+
+	report := toAliasReport(s.QualifiedName, types.AliasConfiguration{
+		Name:            aws.String(s.Name),
+		FunctionVersion: out.FunctionVersion,
+		AliasArn:        out.AliasArn,
+		Description:     out.Description,
+		RevisionId:      out.RevisionId,
+		RoutingConfig:   out.RoutingConfig,
+	})
+
+	return &report, nil
+}
+
+// ListAliases are every alias of the function, sorted by name
+func (c *Client) ListAliases(ctx context.Context, qualifiedName string) ([]AliasReport, error) {
+	if qualifiedName == "" {
+		return nil, failure.InvalidParam("[qualifiedName] is empty")
+	}
+
+	result := []AliasReport{}
+	in := awsLambda.ListAliasesInput{FunctionName: aws.String(qualifiedName)}
+	for {
+		out, err := retry.Call(ctx, c.RetryPolicy(), c.api.ListAliases, &in)
+		if err != nil {
+			var notFound *types.ResourceNotFoundException
+			if errors.As(err, &notFound) {
+				return nil, failure.ToNotFound(err, "function (%s) does not exist", qualifiedName)
+			}
+			return nil, failure.ToSystem(err, "c.api.ListAliases failed (%s)", qualifiedName)
+		}
+
+		for _, a := range out.Aliases {
+			result = append(result, toAliasReport(qualifiedName, a))
+		}
+
+		if out.NextMarker == nil {
+			break
+		}
+		in.Marker = out.NextMarker
+	}
+
+	sort.Slice(result, func(a, b int) bool { return result[a].Name < result[b].Name })
+
+	return result, nil
+}
+
+// updateAlias replaces the routing config, an empty map clears it
+func (c *Client) updateAlias(ctx context.Context, qualifiedName, alias, version string, weights map[string]float64) error {
+	in := awsLambda.UpdateAliasInput{
+		FunctionName:    aws.String(qualifiedName),
+		Name:            aws.String(alias),
+		FunctionVersion: aws.String(version),
+		RoutingConfig:   &types.AliasRoutingConfiguration{AdditionalVersionWeights: weights},
+	}
+
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.UpdateAlias, &in); err != nil {
+		return failure.ToSystem(err, "c.api.UpdateAlias failed (%s, %s)", qualifiedName, alias)
+	}
+
+	return nil
+}
+
+func (c *Client) createAlias(ctx context.Context, qualifiedName, alias, version string) (*AliasReport, error) {
+	in := awsLambda.CreateAliasInput{
+		FunctionName:    aws.String(qualifiedName),
+		Name:            aws.String(alias),
+		FunctionVersion: aws.String(version),
+	}
+
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.CreateAlias, &in); err != nil {
+		return nil, failure.ToSystem(err, "c.api.CreateAlias failed (%s, %s)", qualifiedName, alias)
+	}
+
+	return &AliasReport{QualifiedName: qualifiedName, Name: alias, Version: version, IsCreated: true}, nil
+}
@@ -0,0 +1,106 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsLambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	// MaxAsyncRetryAttempts is how many times lambda retries an async invoke
+	// that failed, it retries twice by default
+	MaxAsyncRetryAttempts = 2
+	MinAsyncEventAge      = 60 * time.Second
+	// MaxAsyncEventAge is the longest lambda keeps an async event, six hours
+	// by default
+	MaxAsyncEventAge = 6 * time.Hour
+)
+
+// AsyncInvokeSettings is how lambda handles the async invokes of a function
+// or of its Alias. It replaces the config the function has, a nil field is
+// the default of lambda and an empty destination is none. OnFailure and
+// OnSuccess are the arns of a queue, topic, event bus or function the
+// records of the invokes are sent to.
+type AsyncInvokeSettings struct {
+	Alias            string
+	OnFailure        string
+	OnSuccess        string
+	MaxRetryAttempts *int32
+	MaxEventAge      *time.Duration
+}
+
+func (s AsyncInvokeSettings) Validate() error {
+	if s.MaxRetryAttempts != nil && (*s.MaxRetryAttempts < 0 || *s.MaxRetryAttempts > MaxAsyncRetryAttempts) {
+		return failure.InvalidParam("[MaxRetryAttempts] (%d) must be from 0 to (%d)", *s.MaxRetryAttempts, MaxAsyncRetryAttempts)
+	}
+
+	if s.MaxEventAge != nil && (*s.MaxEventAge < MinAsyncEventAge || *s.MaxEventAge > MaxAsyncEventAge) {
+		return failure.InvalidParam("[MaxEventAge] (%s) must be from (%s) to (%s)", *s.MaxEventAge, MinAsyncEventAge, MaxAsyncEventAge)
+	}
+
+	for _, arn := range []string{s.OnFailure, s.OnSuccess} {
+		if arn != "" && !strings.HasPrefix(arn, "arn:") {
+			return failure.InvalidParam("destination (%s) is not an arn", arn)
+		}
+	}
+
+	return nil
+}
+
+func (s AsyncInvokeSettings) input(qualifiedName string) *awsLambda.PutFunctionEventInvokeConfigInput {
+	in := awsLambda.PutFunctionEventInvokeConfigInput{
+		FunctionName:         aws.String(qualifiedName),
+		MaximumRetryAttempts: s.MaxRetryAttempts,
+	}
+
+	if s.Alias != "" {
+		in.Qualifier = aws.String(s.Alias)
+	}
+
+	if s.MaxEventAge != nil {
+		in.MaximumEventAgeInSeconds = aws.Int32(int32(s.MaxEventAge.Seconds()))
+	}
+
+	if s.OnFailure != "" || s.OnSuccess != "" {
+		in.DestinationConfig = &types.DestinationConfig{}
+		if s.OnFailure != "" {
+			in.DestinationConfig.OnFailure = &types.OnFailure{Destination: aws.String(s.OnFailure)}
+		}
+		if s.OnSuccess != "" {
+			in.DestinationConfig.OnSuccess = &types.OnSuccess{Destination: aws.String(s.OnSuccess)}
+		}
+	}
+
+	return &in
+}
+
+// SetAsyncInvoke replaces the async invoke config of the function. The
+// execution role of the function needs to be allowed to send to the
+// destinations, lambda refuses the config otherwise.
+func (c *Client) SetAsyncInvoke(ctx context.Context, qualifiedName string, s AsyncInvokeSettings) error {
+	if err := s.Validate(); err != nil {
+		return failure.Wrap(err, "s.Validate failed")
+	}
+
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.PutFunctionEventInvokeConfig, s.input(qualifiedName)); err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return failure.ToNotFound(err, "function (%s) or its alias is not deployed", qualifiedName)
+		}
+
+		var invalid *types.InvalidParameterValueException
+		if errors.As(err, &invalid) {
+			return failure.ToInvalidParam(err, "lambda refused the destinations of (%s), can its role send to them?", qualifiedName)
+		}
+		return failure.ToSystem(err, "c.api.PutFunctionEventInvokeConfig failed (%s)", qualifiedName)
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package lambda
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/rsb/sls/retry"
+)
+
+// updatePolicy also retries ResourceConflict, lambda refuses to update or
+// publish a function while an earlier update is still being applied, which
+// usually takes seconds
+func (c *Client) updatePolicy() retry.Policy {
+	return c.RetryPolicy().
+		Or(retry.ErrorCodes("ResourceConflictException")).
+		WithDelay(time.Second, 10*time.Second).
+		WithMaxAttempts(10).
+		WithMaxElapsed(2 * time.Minute)
+}
+
+// BusyError is returned when lambda kept refusing to change a function that
+// was in the middle of an update after every retry. Cause is the raw aws
+// error.
+type BusyError struct {
+	Function string
+	Msg      string
+	Cause    error
+}
+
+func (e *BusyError) Error() string {
+	return fmt.Sprintf("%s: function (%s) is busy with another update: %v", e.Msg, e.Function, e.Cause)
+}
+
+func (e *BusyError) Unwrap() error {
+	return e.Cause
+}
+
+// IsBusy reports whether err is, or wraps, a BusyError
+func IsBusy(err error) bool {
+	var be *BusyError
+	return errors.As(err, &be)
+}
+
+// busyError is a BusyError when err is a ResourceConflict and nil otherwise
+func busyError(err error, qualifiedName, msg string, a ...interface{}) error {
+	var conflict *types.ResourceConflictException
+	if !errors.As(err, &conflict) {
+		return nil
+	}
+
+	return &BusyError{Function: qualifiedName, Msg: fmt.Sprintf(msg, a...), Cause: err}
+}
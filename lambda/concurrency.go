@@ -0,0 +1,187 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsLambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+// NoReservedConcurrency as the reserved concurrency of FeatureSettings
+// removes it, the function shares the unreserved concurrency of the account
+const NoReservedConcurrency int32 = -1
+
+// ProvisionedConcurrencySettings is how many environments lambda keeps
+// initialized for an alias. A zero Amount removes the provisioned config.
+type ProvisionedConcurrencySettings struct {
+	Alias  string
+	Amount int32
+}
+
+func (s ProvisionedConcurrencySettings) Validate() error {
+	if s.Alias == "" || s.Alias == LatestVersion {
+		return failure.InvalidParam("[Alias] (%s) must be an alias or a published version, lambda does not provision $LATEST", s.Alias)
+	}
+
+	if s.Amount < 0 {
+		return failure.InvalidParam("[Amount] (%d) must be >= 0", s.Amount)
+	}
+
+	return nil
+}
+
+// ConcurrencyReport is the concurrency of a function. Reserved is nil when
+// the function has none. The provisioned fields are of Alias and are only
+// set when it has a provisioned config, Status is IN_PROGRESS until the
+// environments are allocated.
+type ConcurrencyReport struct {
+	QualifiedName string `json:"qualified_name"`
+	Reserved      *int32 `json:"reserved,omitempty"`
+	Alias         string `json:"alias,omitempty"`
+	Requested     int32  `json:"requested,omitempty"`
+	Allocated     int32  `json:"allocated,omitempty"`
+	Available     int32  `json:"available,omitempty"`
+	Status        string `json:"status,omitempty"`
+	StatusReason  string `json:"status_reason,omitempty"`
+}
+
+// Concurrency reads the reserved concurrency of the function and, with an
+// alias, the provisioned concurrency of the alias
+func (c *Client) Concurrency(ctx context.Context, qualifiedName, alias string) (*ConcurrencyReport, error) {
+	if qualifiedName == "" {
+		return nil, failure.InvalidParam("qualifiedName is empty, the function name is required")
+	}
+
+	in := awsLambda.GetFunctionConcurrencyInput{FunctionName: aws.String(qualifiedName)}
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetFunctionConcurrency, &in)
+	if err != nil {
+		return nil, concurrencyError(err, qualifiedName, "c.api.GetFunctionConcurrency")
+	}
+
+	report := ConcurrencyReport{QualifiedName: qualifiedName, Reserved: out.ReservedConcurrentExecutions}
+	if alias == "" {
+		return &report, nil
+	}
+
+	pin := awsLambda.GetProvisionedConcurrencyConfigInput{FunctionName: aws.String(qualifiedName), Qualifier: aws.String(alias)}
+	pout, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetProvisionedConcurrencyConfig, &pin)
+	if err != nil {
+		var none *types.ProvisionedConcurrencyConfigNotFoundException
+		if errors.As(err, &none) {
+			return &report, nil
+		}
+		return nil, concurrencyError(err, qualifiedName, "c.api.GetProvisionedConcurrencyConfig")
+	}
+
+	report.Alias = alias
+	report.Requested = aws.ToInt32(pout.RequestedProvisionedConcurrentExecutions)
+	report.Allocated = aws.ToInt32(pout.AllocatedProvisionedConcurrentExecutions)
+	report.Available = aws.ToInt32(pout.AvailableProvisionedConcurrentExecutions)
+	report.Status = string(pout.Status)
+	report.StatusReason = aws.ToString(pout.StatusReason)
+
+	return &report, nil
+}
+
+// SetReservedConcurrency caps the concurrency of the function and reserves
+// it from the account, NoReservedConcurrency removes the reservation and 0
+// throttles every invocation
+func (c *Client) SetReservedConcurrency(ctx context.Context, qualifiedName string, amount int32) error {
+	if qualifiedName == "" {
+		return failure.InvalidParam("qualifiedName is empty, the function name is required")
+	}
+
+	if amount == NoReservedConcurrency {
+		in := awsLambda.DeleteFunctionConcurrencyInput{FunctionName: aws.String(qualifiedName)}
+		if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.DeleteFunctionConcurrency, &in); err != nil {
+			return concurrencyError(err, qualifiedName, "c.api.DeleteFunctionConcurrency")
+		}
+		return nil
+	}
+
+	if amount < 0 {
+		return failure.InvalidParam("reserved concurrency (%d) must be >= 0", amount)
+	}
+
+	in := awsLambda.PutFunctionConcurrencyInput{
+		FunctionName:                 aws.String(qualifiedName),
+		ReservedConcurrentExecutions: aws.Int32(amount),
+	}
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.PutFunctionConcurrency, &in); err != nil {
+		return concurrencyError(err, qualifiedName, "c.api.PutFunctionConcurrency")
+	}
+
+	return nil
+}
+
+// SetProvisionedConcurrency provisions s.Amount environments for the alias,
+// or removes the provisioned config when it is 0. Lambda allocates them in
+// the background, Concurrency reports the progress.
+func (c *Client) SetProvisionedConcurrency(ctx context.Context, qualifiedName string, s ProvisionedConcurrencySettings) error {
+	if qualifiedName == "" {
+		return failure.InvalidParam("qualifiedName is empty, the function name is required")
+	}
+
+	if err := s.Validate(); err != nil {
+		return failure.Wrap(err, "s.Validate failed")
+	}
+
+	if s.Amount == 0 {
+		in := awsLambda.DeleteProvisionedConcurrencyConfigInput{FunctionName: aws.String(qualifiedName), Qualifier: aws.String(s.Alias)}
+		if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.DeleteProvisionedConcurrencyConfig, &in); err != nil {
+			var none *types.ProvisionedConcurrencyConfigNotFoundException
+			if errors.As(err, &none) {
+				return nil
+			}
+			return concurrencyError(err, qualifiedName, "c.api.DeleteProvisionedConcurrencyConfig")
+		}
+		return nil
+	}
+
+	in := awsLambda.PutProvisionedConcurrencyConfigInput{
+		FunctionName:                    aws.String(qualifiedName),
+		Qualifier:                       aws.String(s.Alias),
+		ProvisionedConcurrentExecutions: aws.Int32(s.Amount),
+	}
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.PutProvisionedConcurrencyConfig, &in); err != nil {
+		return concurrencyError(err, qualifiedName, "c.api.PutProvisionedConcurrencyConfig")
+	}
+
+	return nil
+}
+
+// applyConcurrency sets the concurrency of the settings that are not nil
+func (c *Client) applyConcurrency(ctx context.Context, fs FeatureSettings) error {
+	if fs.ReservedConcurrency != nil {
+		if err := c.SetReservedConcurrency(ctx, fs.QualifiedName, *fs.ReservedConcurrency); err != nil {
+			return failure.Wrap(err, "c.SetReservedConcurrency failed")
+		}
+	}
+
+	if fs.ProvisionedConcurrency != nil {
+		if err := c.SetProvisionedConcurrency(ctx, fs.QualifiedName, *fs.ProvisionedConcurrency); err != nil {
+			return failure.Wrap(err, "c.SetProvisionedConcurrency failed")
+		}
+	}
+
+	return nil
+}
+
+func concurrencyError(err error, qualifiedName, call string) error {
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return failure.ToNotFound(err, "function (%s) or its alias is not deployed", qualifiedName)
+	}
+
+	// lambda keeps 100 of the account concurrency unreserved
+	var limit *types.InvalidParameterValueException
+	if errors.As(err, &limit) {
+		return failure.ToInvalidParam(err, "%s refused the concurrency of (%s)", call, qualifiedName)
+	}
+
+	return failure.ToSystem(err, "%s failed (%s)", call, qualifiedName)
+}
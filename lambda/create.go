@@ -0,0 +1,183 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsLambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+// DefaultRuntime is the os only runtime go binaries named bootstrap run on,
+// the sdk has no constant for it yet
+const DefaultRuntime = "provided.al2023"
+
+// FeatureCreateInput is a function created for the first time. The code is
+// the zip itself or, with S3Bucket, the s3 object it was uploaded to. An
+// empty Runtime is the DefaultRuntime, an empty Handler DefaultOutputName
+// and an empty Architecture X86Architecture. A zero MemorySize or Timeout
+// is left to lambda, 128 MB and 3 seconds, and an empty TracingMode is
+// PassThroughTracing.
+type FeatureCreateInput struct {
+	QualifiedName   string
+	Role            string
+	Runtime         string
+	Handler         string
+	Architecture    string
+	Description     string
+	MemorySize      int32
+	Timeout         int32
+	ZipFile         []byte
+	S3Bucket        string
+	S3Key           string
+	S3ObjectVersion string
+	EnvVars         map[string]string
+	KMSKeyARN       string
+	Tags            map[string]string
+	TracingMode     string
+	Publish         bool
+}
+
+func (in FeatureCreateInput) Validate() error {
+	if in.QualifiedName == "" {
+		return failure.InvalidParam("[QualifiedName] is empty, the function name is required")
+	}
+
+	if !strings.HasPrefix(in.Role, "arn:") {
+		return failure.InvalidParam("[Role] (%s) is not an arn, a function needs an execution role", in.Role)
+	}
+
+	if in.S3Bucket == "" && len(in.ZipFile) == 0 {
+		return failure.InvalidParam("[ZipFile] is empty, the code is required unless it is in [S3Bucket]")
+	}
+
+	if in.S3Bucket != "" && in.S3Key == "" {
+		return failure.InvalidParam("[S3Key] is empty, the object key is required with S3Bucket")
+	}
+
+	if in.Architecture != "" && in.Architecture != X86Architecture && in.Architecture != ARMArchitecture {
+		return failure.InvalidParam("[Architecture] (%s) must be %s or %s", in.Architecture, X86Architecture, ARMArchitecture)
+	}
+
+	if in.TracingMode != "" && in.TracingMode != ActiveTracing && in.TracingMode != PassThroughTracing {
+		return failure.InvalidParam("[TracingMode] (%s) must be %s or %s", in.TracingMode, ActiveTracing, PassThroughTracing)
+	}
+
+	limits := FunctionLimits{MemorySize: in.MemorySize, Timeout: in.Timeout}
+	if err := limits.Validate(); err != nil {
+		return failure.Wrap(err, "limits.Validate failed")
+	}
+
+	return nil
+}
+
+func (in FeatureCreateInput) input() *awsLambda.CreateFunctionInput {
+	runtime, handler, arch := in.Runtime, in.Handler, in.Architecture
+	if runtime == "" {
+		runtime = DefaultRuntime
+	}
+	if handler == "" {
+		handler = DefaultOutputName
+	}
+	if arch == "" {
+		arch = X86Architecture
+	}
+
+	code := types.FunctionCode{ZipFile: in.ZipFile}
+	if in.S3Bucket != "" {
+		code = types.FunctionCode{S3Bucket: aws.String(in.S3Bucket), S3Key: aws.String(in.S3Key)}
+		if in.S3ObjectVersion != "" {
+			code.S3ObjectVersion = aws.String(in.S3ObjectVersion)
+		}
+	}
+
+	result := awsLambda.CreateFunctionInput{
+		FunctionName:  aws.String(in.QualifiedName),
+		Role:          aws.String(in.Role),
+		Runtime:       types.Runtime(runtime),
+		Handler:       aws.String(handler),
+		Architectures: []types.Architecture{types.Architecture(arch)},
+		PackageType:   types.PackageTypeZip,
+		Code:          &code,
+		Publish:       in.Publish,
+		Tags:          in.Tags,
+	}
+
+	if in.Description != "" {
+		result.Description = aws.String(in.Description)
+	}
+	if in.MemorySize != 0 {
+		result.MemorySize = aws.Int32(in.MemorySize)
+	}
+	if in.Timeout != 0 {
+		result.Timeout = aws.Int32(in.Timeout)
+	}
+	if len(in.EnvVars) > 0 {
+		result.Environment = &types.Environment{Variables: in.EnvVars}
+	}
+	if in.KMSKeyARN != "" {
+		result.KMSKeyArn = aws.String(in.KMSKeyARN)
+	}
+	if in.TracingMode != "" {
+		result.TracingConfig = &types.TracingConfig{Mode: types.TracingMode(in.TracingMode)}
+	}
+
+	return &result
+}
+
+// Create creates the function. A function that already exists is reported
+// as AlreadyExists. Lambda creates it Pending, it can be invoked and updated
+// once it is Active.
+func (c *Client) Create(ctx context.Context, in FeatureCreateInput) (*FeatureUpdateReport, error) {
+	if err := in.Validate(); err != nil {
+		return nil, failure.Wrap(err, "in.Validate failed")
+	}
+
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.CreateFunction, in.input())
+	if err != nil {
+		var conflict *types.ResourceConflictException
+		if errors.As(err, &conflict) {
+			return nil, failure.ToAlreadyExists(err, "function (%s) already exists", in.QualifiedName)
+		}
+		return nil, failure.ToSystem(err, "c.api.CreateFunction failed (%s)", in.QualifiedName)
+	}
+
+	report := ToFeatureUpdateReportCreate(out)
+	return &report, nil
+}
+
+func ToFeatureUpdateReportCreate(i *awsLambda.CreateFunctionOutput) FeatureUpdateReport {
+	var r = FeatureUpdateReport{IsCreated: true}
+
+	if i == nil {
+		return r
+	}
+
+	r.CodeSize = i.CodeSize
+	r.LastUpdateStatus = string(i.LastUpdateStatus)
+	r.LastUpdateReasonCode = string(i.LastUpdateStatusReasonCode)
+	r.PackageType = string(i.PackageType)
+	r.State = string(i.State)
+	r.StateReasonCode = string(i.StateReasonCode)
+	r.CodeSHA256 = aws.ToString(i.CodeSha256)
+	r.Description = aws.ToString(i.Description)
+	r.LambdaARN = aws.ToString(i.FunctionArn)
+	r.LambdaName = aws.ToString(i.FunctionName)
+	r.LastModified = aws.ToString(i.LastModified)
+	r.LastUpdateReason = aws.ToString(i.LastUpdateStatusReason)
+	r.RevisionID = aws.ToString(i.RevisionId)
+	r.Role = aws.ToString(i.Role)
+	r.StateReason = aws.ToString(i.StateReason)
+	r.Timeout = aws.ToInt32(i.Timeout)
+	r.Version = aws.ToString(i.Version)
+
+	if i.Environment != nil && i.Environment.Error != nil && i.Environment.Error.Message != nil {
+		r.EnvError = failure.System(*i.Environment.Error.Message)
+	}
+
+	return r
+}
@@ -0,0 +1,81 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsLambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+// deletePolicy also retries ResourceConflict, lambda refuses to delete a
+// function while it is being created or updated, which takes seconds
+func (c *Client) deletePolicy() retry.Policy {
+	return c.RetryPolicy().
+		Or(retry.ErrorCodes("ResourceConflictException")).
+		WithDelay(time.Second, 5*time.Second).
+		WithMaxAttempts(10).
+		WithMaxElapsed(time.Minute)
+}
+
+// Delete removes the function with all of its published versions and
+// aliases. A function that does not exist is reported as NotFound so
+// callers tearing down an env can ignore it, one that is still being
+// updated once the retries give up is reported as InvalidState.
+func (c *Client) Delete(ctx context.Context, qualifiedName string) error {
+	if qualifiedName == "" {
+		return failure.InvalidParam("qualifiedName is empty, the function name is required")
+	}
+
+	in := awsLambda.DeleteFunctionInput{
+		FunctionName: aws.String(qualifiedName),
+	}
+
+	if _, err := retry.Call(ctx, c.deletePolicy(), c.api.DeleteFunction, &in); err != nil {
+		return deleteError(err, qualifiedName)
+	}
+
+	return nil
+}
+
+// DeleteVersion removes one published version of the function. $LATEST can
+// only go with the function itself, use Delete. A version an alias points
+// at is reported as InvalidState, lambda refuses to delete it.
+func (c *Client) DeleteVersion(ctx context.Context, qualifiedName, version string) error {
+	if qualifiedName == "" {
+		return failure.InvalidParam("qualifiedName is empty, the function name is required")
+	}
+
+	if version == "" || version == LatestVersion {
+		return failure.InvalidParam("version (%s) is not a published version, use Delete to remove the function", version)
+	}
+
+	in := awsLambda.DeleteFunctionInput{
+		FunctionName: aws.String(qualifiedName),
+		Qualifier:    aws.String(version),
+	}
+
+	if _, err := retry.Call(ctx, c.deletePolicy(), c.api.DeleteFunction, &in); err != nil {
+		return deleteError(err, qualifiedName+":"+version)
+	}
+
+	return nil
+}
+
+func deleteError(err error, name string) error {
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return failure.ToNotFound(err, "function (%s) is not deployed", name)
+	}
+
+	var conflict *types.ResourceConflictException
+	if errors.As(err, &conflict) {
+		return failure.ToInvalidState(err, "function (%s) is being updated or still in use, try again", name)
+	}
+
+	return failure.ToSystem(err, "c.api.DeleteFunction failed (%s)", name)
+}
@@ -0,0 +1,42 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsLambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+// EnvVars is the environment of the deployed function, as the runtime sees
+// it. Vars encrypted with a kms key are returned encrypted.
+func (c *Client) EnvVars(ctx context.Context, qualifiedName string) (map[string]string, error) {
+	if qualifiedName == "" {
+		return nil, failure.InvalidParam("qualifiedName is empty, the function name is required")
+	}
+
+	in := awsLambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(qualifiedName),
+	}
+
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetFunctionConfiguration, &in)
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, failure.ToNotFound(err, "function (%s) is not deployed", qualifiedName)
+		}
+		return nil, failure.ToSystem(err, "c.api.GetFunctionConfiguration failed (%s)", qualifiedName)
+	}
+
+	vars := map[string]string{}
+	if out.Environment != nil {
+		for k, v := range out.Environment.Variables {
+			vars[k] = v
+		}
+	}
+
+	return vars, nil
+}
@@ -0,0 +1,293 @@
+package lambda
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsLambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	SQSSource      = "sqs"
+	DynamoSource   = "dynamodb"
+	KinesisSource  = "kinesis"
+	MaxSQSBatch    = 10000
+	MaxStreamBatch = 10000
+	// MaxBatchingWindow is the longest lambda gathers records for a batch
+	MaxBatchingWindow = 300 * time.Second
+	// MaxFilters is how many filter patterns a mapping can have
+	MaxFilters = 5
+)
+
+// EventSourceType is sqs, dynamodb or kinesis for the arn of a queue, a
+// table stream or a kinesis stream, empty for the other sources
+func EventSourceType(sourceARN string) string {
+	parts := strings.SplitN(sourceARN, ":", 4)
+	if len(parts) < 3 {
+		return ""
+	}
+
+	switch parts[2] {
+	case SQSSource, DynamoSource, KinesisSource:
+		return parts[2]
+	default:
+		return ""
+	}
+}
+
+// EventSourceSettings is a mapping that invokes a function with the records
+// of a queue or stream, identified by UUID on an update. A nil field is left
+// to lambda on a create and as it is on an update. Filters are json filter
+// patterns, a record the function never sees unless one of them matches, an
+// empty Filters removes them on an update. StartingPosition is TRIM_HORIZON
+// or LATEST and is required to create a stream mapping.
+type EventSourceSettings struct {
+	QualifiedName     string
+	UUID              string
+	SourceARN         string
+	BatchSize         *int32
+	MaxBatchingWindow *time.Duration
+	Filters           []string
+	Enabled           *bool
+	StartingPosition  string
+}
+
+func (s EventSourceSettings) validate(isCreate bool) error {
+	if isCreate && (s.QualifiedName == "" || s.SourceARN == "") {
+		return failure.InvalidParam("[QualifiedName] (%s) and [SourceARN] (%s) are required to create a mapping", s.QualifiedName, s.SourceARN)
+	}
+
+	if !isCreate && s.UUID == "" {
+		return failure.InvalidParam("[UUID] is empty, it is required to update a mapping")
+	}
+
+	if s.BatchSize != nil && (*s.BatchSize < 1 || *s.BatchSize > MaxSQSBatch) {
+		return failure.InvalidParam("[BatchSize] (%d) must be from 1 to (%d)", *s.BatchSize, MaxSQSBatch)
+	}
+
+	if s.MaxBatchingWindow != nil && (*s.MaxBatchingWindow < 0 || *s.MaxBatchingWindow > MaxBatchingWindow) {
+		return failure.InvalidParam("[MaxBatchingWindow] (%s) must be from 0 to (%s)", *s.MaxBatchingWindow, MaxBatchingWindow)
+	}
+
+	if len(s.Filters) > MaxFilters {
+		return failure.InvalidParam("[Filters] (%d) are more than the (%d) lambda allows", len(s.Filters), MaxFilters)
+	}
+
+	for _, f := range s.Filters {
+		if !json.Valid([]byte(f)) {
+			return failure.InvalidParam("filter (%s) is not a json filter pattern", f)
+		}
+	}
+
+	source := EventSourceType(s.SourceARN)
+	if isCreate && (source == DynamoSource || source == KinesisSource) && s.StartingPosition == "" {
+		return failure.InvalidParam("[StartingPosition] is required by the stream (%s), TRIM_HORIZON or LATEST", s.SourceARN)
+	}
+
+	if s.StartingPosition != "" && source == SQSSource {
+		return failure.InvalidParam("[StartingPosition] only applies to streams, (%s) is a queue", s.SourceARN)
+	}
+
+	return nil
+}
+
+func (s EventSourceSettings) filterCriteria() *types.FilterCriteria {
+	if s.Filters == nil {
+		return nil
+	}
+
+	criteria := types.FilterCriteria{Filters: []types.Filter{}}
+	for _, f := range s.Filters {
+		criteria.Filters = append(criteria.Filters, types.Filter{Pattern: aws.String(f)})
+	}
+
+	return &criteria
+}
+
+func (s EventSourceSettings) window() *int32 {
+	if s.MaxBatchingWindow == nil {
+		return nil
+	}
+	return aws.Int32(int32(s.MaxBatchingWindow.Seconds()))
+}
+
+// EventSourceReport is a mapping of an event source to a function. State is
+// Enabled, Disabled or one of the states in between, like Disabling while
+// the pollers stop.
+type EventSourceReport struct {
+	UUID              string   `json:"uuid"`
+	FunctionARN       string   `json:"function_arn"`
+	SourceARN         string   `json:"source_arn"`
+	SourceType        string   `json:"source_type"`
+	State             string   `json:"state"`
+	StateReason       string   `json:"state_reason,omitempty"`
+	BatchSize         int32    `json:"batch_size"`
+	MaxBatchingWindow int32    `json:"max_batching_window_seconds"`
+	Filters           []string `json:"filters,omitempty"`
+	StartingPosition  string   `json:"starting_position,omitempty"`
+	LastModified      string   `json:"last_modified,omitempty"`
+}
+
+// IsEnabled is whether the mapping invokes the function, or is about to
+func (r EventSourceReport) IsEnabled() bool {
+	return r.State == "Enabled" || r.State == "Enabling" || r.State == "Creating"
+}
+
+func toEventSourceReport(c types.EventSourceMappingConfiguration) EventSourceReport {
+	report := EventSourceReport{
+		UUID:              aws.ToString(c.UUID),
+		FunctionARN:       aws.ToString(c.FunctionArn),
+		SourceARN:         aws.ToString(c.EventSourceArn),
+		SourceType:        EventSourceType(aws.ToString(c.EventSourceArn)),
+		State:             aws.ToString(c.State),
+		StateReason:       aws.ToString(c.StateTransitionReason),
+		BatchSize:         aws.ToInt32(c.BatchSize),
+		MaxBatchingWindow: aws.ToInt32(c.MaximumBatchingWindowInSeconds),
+		StartingPosition:  string(c.StartingPosition),
+	}
+
+	if c.LastModified != nil {
+		report.LastModified = c.LastModified.UTC().Format(time.RFC3339)
+	}
+
+	if c.FilterCriteria != nil {
+		for _, f := range c.FilterCriteria.Filters {
+			report.Filters = append(report.Filters, aws.ToString(f.Pattern))
+		}
+	}
+
+	return report
+}
+
+// EventSources are the mappings that invoke the function, sorted by source
+func (c *Client) EventSources(ctx context.Context, qualifiedName string) ([]EventSourceReport, error) {
+	if qualifiedName == "" {
+		return nil, failure.InvalidParam("qualifiedName is empty, the function name is required")
+	}
+
+	result := []EventSourceReport{}
+	in := awsLambda.ListEventSourceMappingsInput{FunctionName: aws.String(qualifiedName)}
+	for {
+		out, err := retry.Call(ctx, c.RetryPolicy(), c.api.ListEventSourceMappings, &in)
+		if err != nil {
+			return nil, esmError(err, qualifiedName, "c.api.ListEventSourceMappings")
+		}
+
+		for _, m := range out.EventSourceMappings {
+			result = append(result, toEventSourceReport(m))
+		}
+
+		if out.NextMarker == nil || *out.NextMarker == "" {
+			break
+		}
+		in.Marker = out.NextMarker
+	}
+
+	sort.Slice(result, func(a, b int) bool { return result[a].SourceARN < result[b].SourceARN })
+
+	return result, nil
+}
+
+// CreateEventSource maps the source to the function. Lambda creates the
+// mapping Creating and starts polling once it is Enabled, unless Enabled is
+// false.
+func (c *Client) CreateEventSource(ctx context.Context, s EventSourceSettings) (*EventSourceReport, error) {
+	if err := s.validate(true); err != nil {
+		return nil, failure.Wrap(err, "s.validate failed")
+	}
+
+	in := awsLambda.CreateEventSourceMappingInput{
+		FunctionName:                   aws.String(s.QualifiedName),
+		EventSourceArn:                 aws.String(s.SourceARN),
+		BatchSize:                      s.BatchSize,
+		MaximumBatchingWindowInSeconds: s.window(),
+		FilterCriteria:                 s.filterCriteria(),
+		Enabled:                        s.Enabled,
+		StartingPosition:               types.EventSourcePosition(s.StartingPosition),
+	}
+
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.CreateEventSourceMapping, &in)
+	if err != nil {
+		return nil, esmError(err, s.QualifiedName, "c.api.CreateEventSourceMapping")
+	}
+
+	report := toEventSourceReport(types.EventSourceMappingConfiguration{
+		UUID:                           out.UUID,
+		FunctionArn:                    out.FunctionArn,
+		EventSourceArn:                 out.EventSourceArn,
+		State:                          out.State,
+		StateTransitionReason:          out.StateTransitionReason,
+		BatchSize:                      out.BatchSize,
+		MaximumBatchingWindowInSeconds: out.MaximumBatchingWindowInSeconds,
+		FilterCriteria:                 out.FilterCriteria,
+		StartingPosition:               out.StartingPosition,
+		LastModified:                   out.LastModified,
+	})
+
+	return &report, nil
+}
+
+// UpdateEventSource changes the mapping s.UUID, see EventSourceSettings for
+// what is left as it is. Disabling a mapping stops the pollers, the records
+// stay in the source until it is enabled again or they expire.
+func (c *Client) UpdateEventSource(ctx context.Context, s EventSourceSettings) (*EventSourceReport, error) {
+	if err := s.validate(false); err != nil {
+		return nil, failure.Wrap(err, "s.validate failed")
+	}
+
+	in := awsLambda.UpdateEventSourceMappingInput{
+		UUID:                           aws.String(s.UUID),
+		BatchSize:                      s.BatchSize,
+		MaximumBatchingWindowInSeconds: s.window(),
+		FilterCriteria:                 s.filterCriteria(),
+		Enabled:                        s.Enabled,
+	}
+
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.UpdateEventSourceMapping, &in)
+	if err != nil {
+		return nil, esmError(err, s.UUID, "c.api.UpdateEventSourceMapping")
+	}
+
+	report := toEventSourceReport(types.EventSourceMappingConfiguration{
+		UUID:                           out.UUID,
+		FunctionArn:                    out.FunctionArn,
+		EventSourceArn:                 out.EventSourceArn,
+		State:                          out.State,
+		StateTransitionReason:          out.StateTransitionReason,
+		BatchSize:                      out.BatchSize,
+		MaximumBatchingWindowInSeconds: out.MaximumBatchingWindowInSeconds,
+		FilterCriteria:                 out.FilterCriteria,
+		StartingPosition:               out.StartingPosition,
+		LastModified:                   out.LastModified,
+	})
+
+	return &report, nil
+}
+
+func esmError(err error, name, call string) error {
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return failure.ToNotFound(err, "(%s) is not deployed or has no such mapping", name)
+	}
+
+	// a mapping can not change while it is being created, enabled or disabled
+	var inUse *types.ResourceInUseException
+	if errors.As(err, &inUse) {
+		return failure.ToInvalidState(err, "mapping of (%s) is changing state, try again", name)
+	}
+
+	var conflict *types.ResourceConflictException
+	if errors.As(err, &conflict) {
+		return failure.ToAlreadyExists(err, "(%s) is already mapped to the source", name)
+	}
+
+	return failure.ToSystem(err, "%s failed (%s)", call, name)
+}
@@ -0,0 +1,157 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsLambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+// FeatureInfo is the deployed state and configuration of a function.
+// CodeSHA256 is the base64 sha256 lambda computed of the code, MemorySize is
+// in MB and Timeout in seconds. ReservedConcurrency is nil when the function
+// has none. Env vars encrypted with a kms key are returned encrypted.
+type FeatureInfo struct {
+	Name                string            `json:"name"`
+	ARN                 string            `json:"arn"`
+	Version             string            `json:"version"`
+	Description         string            `json:"description,omitempty"`
+	State               string            `json:"state"`
+	StateReason         string            `json:"state_reason,omitempty"`
+	LastUpdateStatus    string            `json:"last_update_status"`
+	LastUpdateReason    string            `json:"last_update_reason,omitempty"`
+	LastModified        string            `json:"last_modified"`
+	RevisionID          string            `json:"revision_id"`
+	PackageType         string            `json:"package_type"`
+	Runtime             string            `json:"runtime,omitempty"`
+	Handler             string            `json:"handler,omitempty"`
+	Role                string            `json:"role"`
+	CodeSHA256          string            `json:"code_sha256"`
+	CodeSize            int64             `json:"code_size"`
+	MemorySize          int32             `json:"memory_size"`
+	EphemeralStorage    int32             `json:"ephemeral_storage,omitempty"`
+	Timeout             int32             `json:"timeout"`
+	Architecture        string            `json:"architecture"`
+	TracingMode         string            `json:"tracing_mode,omitempty"`
+	KMSKeyARN           string            `json:"kms_key_arn,omitempty"`
+	DeadLetterARN       string            `json:"dead_letter_arn,omitempty"`
+	Env                 map[string]string `json:"env"`
+	EnvError            string            `json:"env_error,omitempty"`
+	Layers              []string          `json:"layers,omitempty"`
+	Tags                map[string]string `json:"tags,omitempty"`
+	ReservedConcurrency *int32            `json:"reserved_concurrency,omitempty"`
+}
+
+// IsActive is whether the function can be invoked and updated, it is not
+// pending, failed or in the middle of an update
+func (f FeatureInfo) IsActive() bool {
+	return f.State == string(types.StateActive) && f.LastUpdateStatus != string(types.LastUpdateStatusInProgress)
+}
+
+// Function reads the configuration, tags and reserved concurrency of the
+// function. A function that does not exist is reported as NotFound.
+func (c *Client) Function(ctx context.Context, qualifiedName string) (*FeatureInfo, error) {
+	if qualifiedName == "" {
+		return nil, failure.InvalidParam("qualifiedName is empty, the function name is required")
+	}
+
+	in := awsLambda.GetFunctionInput{FunctionName: aws.String(qualifiedName)}
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetFunction, &in)
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, failure.ToNotFound(err, "function (%s) is not deployed", qualifiedName)
+		}
+		return nil, failure.ToSystem(err, "c.api.GetFunction failed (%s)", qualifiedName)
+	}
+
+	if out.Configuration == nil {
+		return nil, failure.System("c.api.GetFunction returned no configuration for (%s)", qualifiedName)
+	}
+
+	info := ToFeatureInfo(out.Configuration)
+	if len(out.Tags) > 0 {
+		info.Tags = out.Tags
+	}
+	if out.Concurrency != nil {
+		info.ReservedConcurrency = out.Concurrency.ReservedConcurrentExecutions
+	}
+
+	return &info, nil
+}
+
+// Exists is whether the function is deployed
+func (c *Client) Exists(ctx context.Context, qualifiedName string) (bool, error) {
+	_, err := c.Function(ctx, qualifiedName)
+	switch {
+	case err == nil:
+		return true, nil
+	case failure.IsNotFound(err):
+		return false, nil
+	default:
+		return false, failure.Wrap(err, "c.Function failed")
+	}
+}
+
+// ToFeatureInfo converts the configuration lambda returns for a function. A
+// function deployed without an architecture runs on x86_64.
+func ToFeatureInfo(cfg *types.FunctionConfiguration) FeatureInfo {
+	info := FeatureInfo{
+		Name:             aws.ToString(cfg.FunctionName),
+		ARN:              aws.ToString(cfg.FunctionArn),
+		Version:          aws.ToString(cfg.Version),
+		Description:      aws.ToString(cfg.Description),
+		State:            string(cfg.State),
+		StateReason:      aws.ToString(cfg.StateReason),
+		LastUpdateStatus: string(cfg.LastUpdateStatus),
+		LastUpdateReason: aws.ToString(cfg.LastUpdateStatusReason),
+		LastModified:     aws.ToString(cfg.LastModified),
+		RevisionID:       aws.ToString(cfg.RevisionId),
+		PackageType:      string(cfg.PackageType),
+		Runtime:          string(cfg.Runtime),
+		Handler:          aws.ToString(cfg.Handler),
+		Role:             aws.ToString(cfg.Role),
+		CodeSHA256:       aws.ToString(cfg.CodeSha256),
+		CodeSize:         cfg.CodeSize,
+		MemorySize:       aws.ToInt32(cfg.MemorySize),
+		Timeout:          aws.ToInt32(cfg.Timeout),
+		Architecture:     X86Architecture,
+		KMSKeyARN:        aws.ToString(cfg.KMSKeyArn),
+		Env:              map[string]string{},
+	}
+
+	if len(cfg.Architectures) > 0 {
+		info.Architecture = string(cfg.Architectures[0])
+	}
+
+	if cfg.EphemeralStorage != nil {
+		info.EphemeralStorage = aws.ToInt32(cfg.EphemeralStorage.Size)
+	}
+
+	if cfg.DeadLetterConfig != nil {
+		info.DeadLetterARN = aws.ToString(cfg.DeadLetterConfig.TargetArn)
+	}
+
+	if cfg.TracingConfig != nil {
+		info.TracingMode = string(cfg.TracingConfig.Mode)
+	}
+
+	if cfg.Environment != nil {
+		for k, v := range cfg.Environment.Variables {
+			info.Env[k] = v
+		}
+		if cfg.Environment.Error != nil {
+			info.EnvError = aws.ToString(cfg.Environment.Error.Message)
+		}
+	}
+
+	for _, l := range cfg.Layers {
+		info.Layers = append(info.Layers, aws.ToString(l.Arn))
+	}
+
+	return info
+}
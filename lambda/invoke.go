@@ -0,0 +1,92 @@
+package lambda
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsLambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	AsyncLambdaInvokeType = "Event"
+)
+
+type InvokePayload struct {
+	QualifiedName string
+	Qualifier     string
+	Payload       []byte
+	IsAsync       bool
+	IsTailLogs    bool
+}
+
+// InvokeReport is the result of an invocation. LogTail holds the last 4KB
+// of the feature's logs, decoded, when they were requested.
+type InvokeReport struct {
+	StatusCode      int32           `json:"status_code"`
+	FunctionError   string          `json:"function_error,omitempty"`
+	ExecutedVersion string          `json:"executed_version,omitempty"`
+	Payload         json.RawMessage `json:"payload,omitempty"`
+	LogTail         string          `json:"log_tail,omitempty"`
+}
+
+func (r InvokeReport) IsFunctionError() bool {
+	return r.FunctionError != ""
+}
+
+// Invoke calls the feature, synchronously unless IsAsync is set. The log
+// tail is only available for synchronous calls.
+func (c *Client) Invoke(ctx context.Context, p InvokePayload) (*InvokeReport, error) {
+	if p.QualifiedName == "" {
+		return nil, failure.InvalidParam("p.QualifiedName is empty, the function name is required")
+	}
+
+	in := awsLambda.InvokeInput{
+		FunctionName:   aws.String(p.QualifiedName),
+		InvocationType: types.InvocationType(DefaultLambdaInvokeType),
+		Payload:        p.Payload,
+	}
+
+	if p.Qualifier != "" {
+		in.Qualifier = aws.String(p.Qualifier)
+	}
+
+	if p.IsAsync {
+		in.InvocationType = types.InvocationType(AsyncLambdaInvokeType)
+	} else if p.IsTailLogs {
+		in.LogType = types.LogType(DefaultLambdaInvokeLogType)
+	}
+
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.Invoke, &in)
+	if err != nil {
+		return nil, failure.ToSystem(err, "c.api.Invoke failed (%s)", p.QualifiedName)
+	}
+
+	report := InvokeReport{
+		StatusCode:      out.StatusCode,
+		FunctionError:   aws.ToString(out.FunctionError),
+		ExecutedVersion: aws.ToString(out.ExecutedVersion),
+	}
+
+	if len(out.Payload) > 0 {
+		report.Payload = out.Payload
+		if !json.Valid(out.Payload) {
+			// keep the report marshalable when the feature returns plain text
+			report.Payload, _ = json.Marshal(string(out.Payload))
+		}
+	}
+
+	if out.LogResult != nil {
+		tail, err := base64.StdEncoding.DecodeString(*out.LogResult)
+		if err != nil {
+			return &report, failure.ToSystem(err, "base64.DecodeString failed for the log tail")
+		}
+		report.LogTail = string(tail)
+	}
+
+	return &report, nil
+}
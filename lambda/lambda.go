@@ -2,7 +2,9 @@ package lambda
 
 import (
 	"context"
-	"io/ioutil"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
 	"path/filepath"
 	"strings"
 
@@ -13,6 +15,7 @@ import (
 	awsLambda "github.com/aws/aws-sdk-go-v2/service/lambda"
 	"github.com/rsb/failure"
 	"github.com/rsb/sls"
+	"github.com/rsb/sls/retry"
 	"go.uber.org/zap"
 )
 
@@ -22,23 +25,77 @@ const (
 	DefaultBinaryZipName       = "deployment.zip"
 	DefaultLambdaInvokeType    = "RequestResponse"
 	DefaultLambdaInvokeLogType = "Tail"
+	// MaxDirectUploadSize is the largest zip UpdateFunctionCode accepts in
+	// the request, larger ones must be uploaded to s3 first
+	MaxDirectUploadSize = 50 * 1024 * 1024
+	// MaxLayers is how many layers lambda attaches to a function
+	MaxLayers = 5
 )
 
 func NewClientWithConfig(cfg aws.Config) *Client {
-	api := awsLambda.NewFromConfig(cfg)
+	api := awsLambda.NewFromConfig(retry.NoSDKRetries(cfg))
 	return NewClient(api)
 }
 
 type AdapterAPI interface {
 	UpdateFunctionCode(ctx context.Context, params *awsLambda.UpdateFunctionCodeInput, optFns ...func(*awsLambda.Options)) (*awsLambda.UpdateFunctionCodeOutput, error)
 	UpdateFunctionConfiguration(ctx context.Context, params *awsLambda.UpdateFunctionConfigurationInput, optFns ...func(*awsLambda.Options)) (*awsLambda.UpdateFunctionConfigurationOutput, error)
+	Invoke(ctx context.Context, params *awsLambda.InvokeInput, optFns ...func(*awsLambda.Options)) (*awsLambda.InvokeOutput, error)
+	GetFunctionConfiguration(ctx context.Context, params *awsLambda.GetFunctionConfigurationInput, optFns ...func(*awsLambda.Options)) (*awsLambda.GetFunctionConfigurationOutput, error)
+	GetFunction(ctx context.Context, params *awsLambda.GetFunctionInput, optFns ...func(*awsLambda.Options)) (*awsLambda.GetFunctionOutput, error)
+	ListVersionsByFunction(ctx context.Context, params *awsLambda.ListVersionsByFunctionInput, optFns ...func(*awsLambda.Options)) (*awsLambda.ListVersionsByFunctionOutput, error)
+	GetAlias(ctx context.Context, params *awsLambda.GetAliasInput, optFns ...func(*awsLambda.Options)) (*awsLambda.GetAliasOutput, error)
+	UpdateAlias(ctx context.Context, params *awsLambda.UpdateAliasInput, optFns ...func(*awsLambda.Options)) (*awsLambda.UpdateAliasOutput, error)
+	DeleteFunction(ctx context.Context, params *awsLambda.DeleteFunctionInput, optFns ...func(*awsLambda.Options)) (*awsLambda.DeleteFunctionOutput, error)
+	CreateAlias(ctx context.Context, params *awsLambda.CreateAliasInput, optFns ...func(*awsLambda.Options)) (*awsLambda.CreateAliasOutput, error)
+	ListAliases(ctx context.Context, params *awsLambda.ListAliasesInput, optFns ...func(*awsLambda.Options)) (*awsLambda.ListAliasesOutput, error)
+	PublishVersion(ctx context.Context, params *awsLambda.PublishVersionInput, optFns ...func(*awsLambda.Options)) (*awsLambda.PublishVersionOutput, error)
+	CreateFunction(ctx context.Context, params *awsLambda.CreateFunctionInput, optFns ...func(*awsLambda.Options)) (*awsLambda.CreateFunctionOutput, error)
+	ListFunctions(ctx context.Context, params *awsLambda.ListFunctionsInput, optFns ...func(*awsLambda.Options)) (*awsLambda.ListFunctionsOutput, error)
+	GetFunctionConcurrency(ctx context.Context, params *awsLambda.GetFunctionConcurrencyInput, optFns ...func(*awsLambda.Options)) (*awsLambda.GetFunctionConcurrencyOutput, error)
+	PutFunctionConcurrency(ctx context.Context, params *awsLambda.PutFunctionConcurrencyInput, optFns ...func(*awsLambda.Options)) (*awsLambda.PutFunctionConcurrencyOutput, error)
+	DeleteFunctionConcurrency(ctx context.Context, params *awsLambda.DeleteFunctionConcurrencyInput, optFns ...func(*awsLambda.Options)) (*awsLambda.DeleteFunctionConcurrencyOutput, error)
+	GetProvisionedConcurrencyConfig(ctx context.Context, params *awsLambda.GetProvisionedConcurrencyConfigInput, optFns ...func(*awsLambda.Options)) (*awsLambda.GetProvisionedConcurrencyConfigOutput, error)
+	PutProvisionedConcurrencyConfig(ctx context.Context, params *awsLambda.PutProvisionedConcurrencyConfigInput, optFns ...func(*awsLambda.Options)) (*awsLambda.PutProvisionedConcurrencyConfigOutput, error)
+	DeleteProvisionedConcurrencyConfig(ctx context.Context, params *awsLambda.DeleteProvisionedConcurrencyConfigInput, optFns ...func(*awsLambda.Options)) (*awsLambda.DeleteProvisionedConcurrencyConfigOutput, error)
+	PublishLayerVersion(ctx context.Context, params *awsLambda.PublishLayerVersionInput, optFns ...func(*awsLambda.Options)) (*awsLambda.PublishLayerVersionOutput, error)
+	GetFunctionUrlConfig(ctx context.Context, params *awsLambda.GetFunctionUrlConfigInput, optFns ...func(*awsLambda.Options)) (*awsLambda.GetFunctionUrlConfigOutput, error)
+	CreateFunctionUrlConfig(ctx context.Context, params *awsLambda.CreateFunctionUrlConfigInput, optFns ...func(*awsLambda.Options)) (*awsLambda.CreateFunctionUrlConfigOutput, error)
+	UpdateFunctionUrlConfig(ctx context.Context, params *awsLambda.UpdateFunctionUrlConfigInput, optFns ...func(*awsLambda.Options)) (*awsLambda.UpdateFunctionUrlConfigOutput, error)
+	AddPermission(ctx context.Context, params *awsLambda.AddPermissionInput, optFns ...func(*awsLambda.Options)) (*awsLambda.AddPermissionOutput, error)
+	ListEventSourceMappings(ctx context.Context, params *awsLambda.ListEventSourceMappingsInput, optFns ...func(*awsLambda.Options)) (*awsLambda.ListEventSourceMappingsOutput, error)
+	CreateEventSourceMapping(ctx context.Context, params *awsLambda.CreateEventSourceMappingInput, optFns ...func(*awsLambda.Options)) (*awsLambda.CreateEventSourceMappingOutput, error)
+	UpdateEventSourceMapping(ctx context.Context, params *awsLambda.UpdateEventSourceMappingInput, optFns ...func(*awsLambda.Options)) (*awsLambda.UpdateEventSourceMappingOutput, error)
+	TagResource(ctx context.Context, params *awsLambda.TagResourceInput, optFns ...func(*awsLambda.Options)) (*awsLambda.TagResourceOutput, error)
+	ListTags(ctx context.Context, params *awsLambda.ListTagsInput, optFns ...func(*awsLambda.Options)) (*awsLambda.ListTagsOutput, error)
+	PutFunctionEventInvokeConfig(ctx context.Context, params *awsLambda.PutFunctionEventInvokeConfigInput, optFns ...func(*awsLambda.Options)) (*awsLambda.PutFunctionEventInvokeConfigOutput, error)
 }
 
+// CodePayload is the new code of a function, either the zip itself or, with
+// S3Bucket, the s3 object it was uploaded to. Architecture is the one the
+// code is built for, X86Architecture or ARMArchitecture, an empty one keeps
+// the architecture of the function. It is the only way to change it,
+// UpdateFunctionConfiguration has no architecture.
 type CodePayload struct {
-	DryRun        bool
-	Publish       bool
-	QualifiedName string
-	ZipFile       []byte
+	DryRun          bool
+	Publish         bool
+	QualifiedName   string
+	ZipFile         []byte
+	S3Bucket        string
+	S3Key           string
+	S3ObjectVersion string
+	Architecture    string
+}
+
+func (cp CodePayload) IsS3() bool {
+	return cp.S3Bucket != ""
+}
+
+// ZipSHA256 is the sha256 of the zip encoded the way lambda reports the
+// CodeSha256 of a function, the same zip deployed again has the same sum
+func ZipSHA256(zip []byte) string {
+	sum := sha256.Sum256(zip)
+	return base64.StdEncoding.EncodeToString(sum[:])
 }
 
 type FeatureUpdateReport struct {
@@ -60,70 +117,263 @@ type FeatureUpdateReport struct {
 	StateReasonCode      string
 	Timeout              int32
 	Version              string
+	// IsCreated is whether the function was created rather than updated
+	IsCreated bool
+	// IsUnchanged is whether the update was skipped because the function
+	// already runs the code
+	IsUnchanged bool
 }
 
+// FeatureSettings is the configuration UpdateConfig applies, a nil field is
+// left as it is deployed. A nil EnvVars keeps the env vars and a nil Layers
+// the layers, an empty one removes them all. MemorySize is in MB and Timeout
+// in seconds. The architecture can only change with the code, see
+// CodePayload. Tags are added to the ones the function has, see Tag. An
+// empty DeadLetterARN removes the dead letter queue, AsyncInvoke replaces
+// the async invoke config, see SetAsyncInvoke. TracingMode is ActiveTracing
+// to have lambda sample and send the invokes to x-ray or PassThroughTracing.
 type FeatureSettings struct {
-	QualifiedName string
-	EnvVars       map[string]string
-	Timeout       *int32
-	Role          *string
+	QualifiedName          string
+	EnvVars                map[string]string
+	MemorySize             *int32
+	Timeout                *int32
+	Role                   *string
+	Handler                *string
+	Runtime                *string
+	Description            *string
+	Layers                 []string
+	KMSKeyARN              *string
+	ReservedConcurrency    *int32
+	ProvisionedConcurrency *ProvisionedConcurrencySettings
+	Tags                   map[string]string
+	DeadLetterARN          *string
+	AsyncInvoke            *AsyncInvokeSettings
+	TracingMode            *string
+}
+
+func (fs FeatureSettings) Validate() error {
+	if fs.QualifiedName == "" {
+		return failure.InvalidParam("[QualifiedName] is empty, the function name is required")
+	}
+
+	limits := FunctionLimits{MemorySize: aws.ToInt32(fs.MemorySize), Timeout: aws.ToInt32(fs.Timeout)}
+	if (fs.MemorySize != nil && limits.MemorySize == 0) || (fs.Timeout != nil && limits.Timeout == 0) {
+		return failure.InvalidParam("[MemorySize] and [Timeout] can not be 0, leave them nil to keep them")
+	}
+	if err := limits.Validate(); err != nil {
+		return failure.Wrap(err, "limits.Validate failed")
+	}
+
+	if len(fs.Layers) > MaxLayers {
+		return failure.InvalidParam("[Layers] (%d) are more than the (%d) lambda attaches to a function", len(fs.Layers), MaxLayers)
+	}
+
+	if fs.Role != nil && !strings.HasPrefix(*fs.Role, "arn:") {
+		return failure.InvalidParam("[Role] (%s) is not an arn", *fs.Role)
+	}
+
+	if fs.ProvisionedConcurrency != nil {
+		if err := fs.ProvisionedConcurrency.Validate(); err != nil {
+			return failure.Wrap(err, "fs.ProvisionedConcurrency.Validate failed")
+		}
+	}
+
+	if err := ValidateTags(fs.Tags); err != nil {
+		return failure.Wrap(err, "ValidateTags failed")
+	}
+
+	if fs.DeadLetterARN != nil && *fs.DeadLetterARN != "" && !strings.HasPrefix(*fs.DeadLetterARN, "arn:") {
+		return failure.InvalidParam("[DeadLetterARN] (%s) is not the arn of a queue or topic", *fs.DeadLetterARN)
+	}
+
+	if fs.TracingMode != nil && *fs.TracingMode != ActiveTracing && *fs.TracingMode != PassThroughTracing {
+		return failure.InvalidParam("[TracingMode] (%s) must be %s or %s", *fs.TracingMode, ActiveTracing, PassThroughTracing)
+	}
+
+	if fs.AsyncInvoke != nil {
+		if err := fs.AsyncInvoke.Validate(); err != nil {
+			return failure.Wrap(err, "fs.AsyncInvoke.Validate failed")
+		}
+	}
+
+	return nil
+}
+
+// hasConfig is whether there is anything to send to
+// UpdateFunctionConfiguration, concurrency, tags and async invokes have
+// their own calls
+func (fs FeatureSettings) hasConfig() bool {
+	return fs.EnvVars != nil ||
+		fs.MemorySize != nil ||
+		fs.Timeout != nil ||
+		fs.Role != nil ||
+		fs.Handler != nil ||
+		fs.Runtime != nil ||
+		fs.Description != nil ||
+		fs.Layers != nil ||
+		fs.KMSKeyARN != nil ||
+		fs.DeadLetterARN != nil ||
+		fs.TracingMode != nil
+}
+
+func (fs FeatureSettings) input() *awsLambda.UpdateFunctionConfigurationInput {
+	in := awsLambda.UpdateFunctionConfigurationInput{
+		FunctionName: aws.String(fs.QualifiedName),
+		MemorySize:   fs.MemorySize,
+		Timeout:      fs.Timeout,
+		Role:         fs.Role,
+		Handler:      fs.Handler,
+		Description:  fs.Description,
+		Layers:       fs.Layers,
+		KMSKeyArn:    fs.KMSKeyARN,
+	}
+
+	if fs.EnvVars != nil {
+		in.Environment = &types.Environment{Variables: fs.EnvVars}
+	}
+
+	if fs.Runtime != nil {
+		in.Runtime = types.Runtime(*fs.Runtime)
+	}
+
+	if fs.TracingMode != nil {
+		in.TracingConfig = &types.TracingConfig{Mode: types.TracingMode(*fs.TracingMode)}
+	}
+
+	if fs.DeadLetterARN != nil {
+		in.DeadLetterConfig = &types.DeadLetterConfig{TargetArn: fs.DeadLetterARN}
+	}
+
+	return &in
 }
 
 type Client struct {
-	api AdapterAPI
+	api         AdapterAPI
+	retryPolicy *retry.Policy
 }
 
 func NewClient(api AdapterAPI) *Client {
 	return &Client{api: api}
 }
 
+// RetryPolicy is the policy the client retries its calls with
+func (c *Client) RetryPolicy() retry.Policy {
+	if c.retryPolicy == nil {
+		return retry.Default()
+	}
+	return *c.retryPolicy
+}
+
+// SetRetryPolicy replaces retry.Default for the calls made by the client
+func (c *Client) SetRetryPolicy(p retry.Policy) {
+	c.retryPolicy = &p
+}
+
 func (c *Client) Compile(data sls.BuildSettings) (sls.BuildResult, error) {
+	return c.CompileContext(context.Background(), data)
+}
+
+// CompileContext is Compile, the go build is killed when ctx is done
+func (c *Client) CompileContext(ctx context.Context, data sls.BuildSettings) (sls.BuildResult, error) {
 	buildDir := data.BuildDir
 	binName := data.BinName
 	codeDir := data.CodeDir
 
 	result := sls.BuildResult{Settings: data, ZipName: DefaultBinaryZipName}
-	cmd, err := sls.NewGoBuildCmd(buildDir, binName, codeDir)
+	cmd, err := sls.NewGoArchBuildCmd(buildDir, binName, codeDir, data.Architecture)
 	if err != nil {
-		return result, failure.Wrap(err, "NewGoBuildCmd failed for (%s,%s,%s)", buildDir, binName, codeDir)
+		return result, failure.Wrap(err, "NewGoArchBuildCmd failed for (%s,%s,%s)", buildDir, binName, codeDir)
 	}
 
-	if err = cmd.Run(); err != nil {
-		return result, failure.Wrap(err, "could not build (%s) cmd.Run failed.", binName)
+	if err = cmd.Start(); err != nil {
+		return result, failure.Wrap(err, "could not build (%s) cmd.Start failed.", binName)
 	}
 
-	if data.SkipZipping {
-		return result, nil
+	kill := context.AfterFunc(ctx, func() {
+		_ = cmd.Process.Kill()
+	})
+	err = cmd.Wait()
+	kill()
+	if ctx.Err() != nil {
+		return result, failure.Wrap(ctx.Err(), "build of (%s) was cancelled", binName)
 	}
-
-	if data.ZipName != "" {
-		result.ZipName = data.ZipName
+	if err != nil {
+		return result, failure.Wrap(err, "could not build (%s) cmd.Wait failed.", binName)
 	}
 
 	binPath := filepath.Join(buildDir, binName)
+	if data.IsAnalyze {
+		analysis, err := sls.AnalyzeBinary(binPath)
+		if err != nil {
+			return result, failure.Wrap(err, "sls.AnalyzeBinary failed (%s)", binPath)
+		}
+		result.Analysis = &analysis
+	}
+
+	if data.SkipZipping {
+		return result, nil
+	}
 
-	zipFile := filepath.Join(buildDir, result.ZipName)
-	if err = sls.Zip(zipFile, binPath); err != nil {
-		return result, failure.Wrap(err, "sls.Zip failed for (%s, %s)", zipFile, binPath)
+	packager := data.Packager
+	if packager == nil {
+		if packager, err = sls.NewPackager(data.Packaging); err != nil {
+			return result, failure.Wrap(err, "sls.NewPackager failed")
+		}
 	}
 
-	zip, err := ioutil.ReadFile(zipFile)
+	pkg, err := packager.Package(data)
 	if err != nil {
-		return result, failure.ToSystem(err, "ioutil.ReadFile failed")
+		return result, failure.Wrap(err, "packager.Package failed (%s)", packager.Format())
+	}
+	result.Package = &pkg
+	if pkg.Format == sls.ZipFormat {
+		result.ZipName = pkg.Name
+		result.ZipData = pkg.Data
 	}
-	result.ZipData = zip
 
 	return result, nil
 }
 
+// UpdateCode replaces the code of the function. An update refused because
+// an earlier one is still being applied is retried, and reported as a
+// BusyError once the retries give up.
 func (c *Client) UpdateCode(ctx context.Context, cp CodePayload) (*FeatureUpdateReport, error) {
 	in := awsLambda.UpdateFunctionCodeInput{
 		FunctionName: aws.String(cp.QualifiedName),
-		ZipFile:      cp.ZipFile,
+		Publish:      cp.Publish,
+		DryRun:       cp.DryRun,
+	}
+
+	switch cp.Architecture {
+	case "":
+	case X86Architecture, ARMArchitecture:
+		in.Architectures = []types.Architecture{types.Architecture(cp.Architecture)}
+	default:
+		return nil, failure.InvalidParam("[Architecture] (%s) must be %s or %s", cp.Architecture, X86Architecture, ARMArchitecture)
 	}
 
-	out, err := c.api.UpdateFunctionCode(ctx, &in)
+	if cp.IsS3() {
+		if cp.S3Key == "" {
+			return nil, failure.InvalidParam("[S3Key] is empty, the object key is required with S3Bucket")
+		}
+		in.S3Bucket = aws.String(cp.S3Bucket)
+		in.S3Key = aws.String(cp.S3Key)
+		if cp.S3ObjectVersion != "" {
+			in.S3ObjectVersion = aws.String(cp.S3ObjectVersion)
+		}
+	} else {
+		in.ZipFile = cp.ZipFile
+	}
+
+	out, err := retry.Call(ctx, c.updatePolicy(), c.api.UpdateFunctionCode, &in)
 	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, failure.ToNotFound(err, "function (%s) is not deployed", cp.QualifiedName)
+		}
+		if busy := busyError(err, cp.QualifiedName, "c.api.UpdateFunctionCode failed"); busy != nil {
+			return nil, busy
+		}
 		return nil, failure.ToSystem(err, "c.api.UpdateFunctionCode failed")
 	}
 
@@ -131,18 +381,60 @@ func (c *Client) UpdateCode(ctx context.Context, cp CodePayload) (*FeatureUpdate
 	return &report, nil
 }
 
+// UpdateConfig applies the settings that are not nil. The concurrency, tags
+// and async invoke config are set once the configuration is updated. When
+// there is no configuration to update, the report only has the function
+// name. Like UpdateCode, a busy function is retried before a BusyError is
+// returned.
 func (c *Client) UpdateConfig(ctx context.Context, fs FeatureSettings) (*FeatureUpdateReport, error) {
-	in := awsLambda.UpdateFunctionConfigurationInput{
-		FunctionName: aws.String(fs.QualifiedName),
-		Environment:  &types.Environment{Variables: fs.EnvVars},
+	if err := fs.Validate(); err != nil {
+		return nil, failure.Wrap(err, "fs.Validate failed")
+	}
+
+	if !fs.hasConfig() {
+		if err := c.applyConcurrency(ctx, fs); err != nil {
+			return nil, failure.Wrap(err, "c.applyConcurrency failed")
+		}
+		if err := c.Tag(ctx, fs.QualifiedName, fs.Tags); err != nil {
+			return nil, failure.Wrap(err, "c.Tag failed")
+		}
+		if fs.AsyncInvoke != nil {
+			if err := c.SetAsyncInvoke(ctx, fs.QualifiedName, *fs.AsyncInvoke); err != nil {
+				return nil, failure.Wrap(err, "c.SetAsyncInvoke failed")
+			}
+		}
+		return &FeatureUpdateReport{LambdaName: fs.QualifiedName}, nil
 	}
 
-	out, err := c.api.UpdateFunctionConfiguration(ctx, &in)
+	out, err := retry.Call(ctx, c.updatePolicy(), c.api.UpdateFunctionConfiguration, fs.input())
 	if err != nil {
-		return nil, failure.ToSystem(err, "c.api.UpdateFunction")
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, failure.ToNotFound(err, "function (%s) is not deployed", fs.QualifiedName)
+		}
+		if busy := busyError(err, fs.QualifiedName, "c.api.UpdateFunctionConfiguration failed"); busy != nil {
+			return nil, busy
+		}
+		return nil, failure.ToSystem(err, "c.api.UpdateFunctionConfiguration failed (%s)", fs.QualifiedName)
 	}
 
 	report := ToFeatureUpdateReportConfig(out)
+	if err = c.applyConcurrency(ctx, fs); err != nil {
+		return &report, failure.Wrap(err, "c.applyConcurrency failed")
+	}
+
+	if len(fs.Tags) > 0 {
+		if err = c.tagARN(ctx, report.LambdaARN, fs.Tags); err != nil {
+			return &report, failure.Wrap(err, "c.tagARN failed")
+		}
+	}
+
+	if fs.AsyncInvoke != nil {
+		if err = c.SetAsyncInvoke(ctx, fs.QualifiedName, *fs.AsyncInvoke); err != nil {
+			return &report, failure.Wrap(err, "c.SetAsyncInvoke failed")
+		}
+	}
+
 	return &report, nil
 }
 
@@ -0,0 +1,112 @@
+package lambda
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsLambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+// LayerReport is a published version of a layer. VersionARN is what a
+// function is attached to, it is ARN with the version appended.
+type LayerReport struct {
+	Name               string   `json:"name"`
+	ARN                string   `json:"arn"`
+	VersionARN         string   `json:"version_arn"`
+	Version            int64    `json:"version"`
+	CodeSHA256         string   `json:"code_sha256"`
+	CodeSize           int64    `json:"code_size"`
+	CompatibleRuntimes []string `json:"compatible_runtimes,omitempty"`
+	CreatedDate        string   `json:"created_date"`
+}
+
+// PublishLayer publishes zip as a new version of the layer, the layer is
+// created by its first version. Lambda extracts the zip into /opt of the
+// functions the version is attached to. No compatibleRuntimes leaves the
+// layer usable by any runtime.
+func (c *Client) PublishLayer(ctx context.Context, name string, zip []byte, compatibleRuntimes []string) (*LayerReport, error) {
+	if name == "" {
+		return nil, failure.InvalidParam("name is empty, the layer name is required")
+	}
+
+	if len(zip) == 0 {
+		return nil, failure.InvalidParam("zip of layer (%s) is empty", name)
+	}
+
+	if len(zip) > MaxDirectUploadSize {
+		return nil, failure.InvalidParam("zip of layer (%s) is (%d) bytes, lambda accepts (%d) at most", name, len(zip), MaxDirectUploadSize)
+	}
+
+	in := awsLambda.PublishLayerVersionInput{
+		LayerName: aws.String(name),
+		Content:   &types.LayerVersionContentInput{ZipFile: zip},
+	}
+	for _, r := range compatibleRuntimes {
+		in.CompatibleRuntimes = append(in.CompatibleRuntimes, types.Runtime(r))
+	}
+
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.PublishLayerVersion, &in)
+	if err != nil {
+		return nil, failure.ToSystem(err, "c.api.PublishLayerVersion failed (%s)", name)
+	}
+
+	report := LayerReport{
+		Name:        name,
+		ARN:         aws.ToString(out.LayerArn),
+		VersionARN:  aws.ToString(out.LayerVersionArn),
+		Version:     out.Version,
+		CreatedDate: aws.ToString(out.CreatedDate),
+	}
+	if out.Content != nil {
+		report.CodeSHA256 = aws.ToString(out.Content.CodeSha256)
+		report.CodeSize = out.Content.CodeSize
+	}
+	for _, r := range out.CompatibleRuntimes {
+		report.CompatibleRuntimes = append(report.CompatibleRuntimes, string(r))
+	}
+
+	return &report, nil
+}
+
+// LayerARN is the arn of the layer of a layer version arn, the version
+// arn without its version
+func LayerARN(versionARN string) string {
+	idx := strings.LastIndex(versionARN, ":")
+	if idx < 0 {
+		return versionARN
+	}
+	return versionARN[:idx]
+}
+
+// AttachLayer is the layers of a function once versionARN is attached, an
+// attached version of the same layer is replaced in place so the order in
+// which lambda extracts the layers is kept. It is false when versionARN is
+// already attached.
+func AttachLayer(layers []string, versionARN string) ([]string, bool) {
+	result := make([]string, 0, len(layers)+1)
+	found := false
+	for _, l := range layers {
+		if l == versionARN {
+			return layers, false
+		}
+
+		if LayerARN(l) == LayerARN(versionARN) {
+			if !found {
+				result = append(result, versionARN)
+				found = true
+			}
+			continue
+		}
+		result = append(result, l)
+	}
+
+	if !found {
+		result = append(result, versionARN)
+	}
+
+	return result, true
+}
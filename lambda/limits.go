@@ -0,0 +1,151 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsLambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	MinMemorySize = 128
+	MaxMemorySize = 10240
+	MinTimeout    = 1
+	MaxTimeout    = 900
+
+	// X86Architecture and ARMArchitecture are the instruction sets a
+	// function runs on
+	X86Architecture = string(types.ArchitectureX8664)
+	ARMArchitecture = string(types.ArchitectureArm64)
+
+	// ActiveTracing and PassThroughTracing are the x-ray tracing modes, with
+	// PassThrough a function only traces invokes that arrive sampled
+	ActiveTracing      = string(types.TracingModeActive)
+	PassThroughTracing = string(types.TracingModePassThrough)
+)
+
+// GoArchArchitecture is the architecture a binary built for goarch runs on,
+// empty when goarch is empty or not one lambda runs
+func GoArchArchitecture(goarch string) string {
+	switch goarch {
+	case "amd64":
+		return X86Architecture
+	case "arm64":
+		return ARMArchitecture
+	default:
+		return ""
+	}
+}
+
+// FunctionLimits are the memory size in MB and the timeout in seconds of a
+// function
+type FunctionLimits struct {
+	MemorySize int32 `json:"memory_size"`
+	Timeout    int32 `json:"timeout"`
+}
+
+func (l FunctionLimits) Validate() error {
+	if l.MemorySize != 0 && (l.MemorySize < MinMemorySize || l.MemorySize > MaxMemorySize) {
+		return failure.InvalidParam("[MemorySize] (%d) must be from (%d) to (%d) MB", l.MemorySize, MinMemorySize, MaxMemorySize)
+	}
+
+	if l.Timeout != 0 && (l.Timeout < MinTimeout || l.Timeout > MaxTimeout) {
+		return failure.InvalidParam("[Timeout] (%d) must be from (%d) to (%d) seconds", l.Timeout, MinTimeout, MaxTimeout)
+	}
+
+	return nil
+}
+
+// Limits reads the memory size and timeout the function is deployed with
+func (c *Client) Limits(ctx context.Context, qualifiedName string) (FunctionLimits, error) {
+	if qualifiedName == "" {
+		return FunctionLimits{}, failure.InvalidParam("qualifiedName is empty, the function name is required")
+	}
+
+	in := awsLambda.GetFunctionConfigurationInput{FunctionName: aws.String(qualifiedName)}
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetFunctionConfiguration, &in)
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return FunctionLimits{}, failure.ToNotFound(err, "function (%s) is not deployed", qualifiedName)
+		}
+		return FunctionLimits{}, failure.ToSystem(err, "c.api.GetFunctionConfiguration failed (%s)", qualifiedName)
+	}
+
+	return FunctionLimits{
+		MemorySize: aws.ToInt32(out.MemorySize),
+		Timeout:    aws.ToInt32(out.Timeout),
+	}, nil
+}
+
+// UpdateLimits changes only the memory size and timeout of the function, a
+// zero value is left untouched
+func (c *Client) UpdateLimits(ctx context.Context, qualifiedName string, l FunctionLimits) (*FeatureUpdateReport, error) {
+	if err := l.Validate(); err != nil {
+		return nil, failure.Wrap(err, "l.Validate failed")
+	}
+
+	fs := FeatureSettings{QualifiedName: qualifiedName}
+	if l.MemorySize != 0 {
+		fs.MemorySize = aws.Int32(l.MemorySize)
+	}
+
+	if l.Timeout != 0 {
+		fs.Timeout = aws.Int32(l.Timeout)
+	}
+
+	report, err := c.UpdateConfig(ctx, fs)
+	if err != nil {
+		return nil, failure.Wrap(err, "c.UpdateConfig failed")
+	}
+
+	return report, nil
+}
+
+// FunctionFootprint is what a function is deployed with that its size and
+// cost depend on. CodeSize is in bytes, MemorySize and EphemeralStorage in MB
+// and Timeout in seconds.
+type FunctionFootprint struct {
+	CodeSize         int64  `json:"code_size"`
+	MemorySize       int32  `json:"memory_size"`
+	EphemeralStorage int32  `json:"ephemeral_storage"`
+	Timeout          int32  `json:"timeout"`
+	Architecture     string `json:"architecture"`
+}
+
+// Footprint reads the code size, limits and architecture of the function. A
+// function deployed without an architecture runs on x86_64.
+func (c *Client) Footprint(ctx context.Context, qualifiedName string) (FunctionFootprint, error) {
+	if qualifiedName == "" {
+		return FunctionFootprint{}, failure.InvalidParam("qualifiedName is empty, the function name is required")
+	}
+
+	in := awsLambda.GetFunctionConfigurationInput{FunctionName: aws.String(qualifiedName)}
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetFunctionConfiguration, &in)
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return FunctionFootprint{}, failure.ToNotFound(err, "function (%s) is not deployed", qualifiedName)
+		}
+		return FunctionFootprint{}, failure.ToSystem(err, "c.api.GetFunctionConfiguration failed (%s)", qualifiedName)
+	}
+
+	f := FunctionFootprint{
+		CodeSize:     out.CodeSize,
+		MemorySize:   aws.ToInt32(out.MemorySize),
+		Timeout:      aws.ToInt32(out.Timeout),
+		Architecture: X86Architecture,
+	}
+	if len(out.Architectures) > 0 {
+		f.Architecture = string(out.Architectures[0])
+	}
+	if out.EphemeralStorage != nil {
+		f.EphemeralStorage = aws.ToInt32(out.EphemeralStorage.Size)
+	}
+
+	return f, nil
+}
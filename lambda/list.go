@@ -0,0 +1,58 @@
+package lambda
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	awsLambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+// ListByPrefix pages through every function of the account and region and
+// returns the ones whose name starts with prefix, sorted by name. Use the
+// qualified name of a service and a dash, the features of the service are
+// named after it. ListFunctions returns no tags or reserved concurrency, with
+// tags the tags of every function with the prefix are read and only the
+// ones with all of tags are returned, their Tags set.
+func (c *Client) ListByPrefix(ctx context.Context, prefix string, tags map[string]string) ([]FeatureInfo, error) {
+	if prefix == "" {
+		return nil, failure.InvalidParam("prefix is empty, listing every function of the account is not supported")
+	}
+
+	result := []FeatureInfo{}
+	in := awsLambda.ListFunctionsInput{}
+	for {
+		out, err := retry.Call(ctx, c.RetryPolicy(), c.api.ListFunctions, &in)
+		if err != nil {
+			return nil, failure.ToSystem(err, "c.api.ListFunctions failed")
+		}
+
+		for idx := range out.Functions {
+			info := ToFeatureInfo(&out.Functions[idx])
+			if !strings.HasPrefix(info.Name, prefix) {
+				continue
+			}
+
+			if len(tags) > 0 {
+				if info.Tags, err = c.Tags(ctx, info.ARN); err != nil {
+					return nil, failure.Wrap(err, "c.Tags failed")
+				}
+				if !HasTags(info.Tags, tags) {
+					continue
+				}
+			}
+			result = append(result, info)
+		}
+
+		if out.NextMarker == nil || *out.NextMarker == "" {
+			break
+		}
+		in.Marker = out.NextMarker
+	}
+
+	sort.Slice(result, func(a, b int) bool { return result[a].Name < result[b].Name })
+
+	return result, nil
+}
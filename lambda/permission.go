@@ -0,0 +1,51 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsLambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+// AllowRule adds the permission an eventbridge rule needs to invoke the
+// function, eventbridge rules have no role of their own. The statement id
+// is derived from ruleARN so putting the same rule again is a no-op.
+func (c *Client) AllowRule(ctx context.Context, qualifiedName, ruleARN string) error {
+	if qualifiedName == "" {
+		return failure.InvalidParam("qualifiedName is empty, the function name is required")
+	}
+
+	idx := strings.Index(ruleARN, ":rule/")
+	if !strings.HasPrefix(ruleARN, "arn:") || idx < 0 {
+		return failure.InvalidParam("ruleARN (%s) is not the arn of an eventbridge rule", ruleARN)
+	}
+	statement := "EventBridge-" + strings.NewReplacer("/", "-", ".", "-").Replace(ruleARN[idx+len(":rule/"):])
+
+	in := awsLambda.AddPermissionInput{
+		FunctionName: aws.String(qualifiedName),
+		StatementId:  aws.String(statement),
+		Action:       aws.String("lambda:InvokeFunction"),
+		Principal:    aws.String("events.amazonaws.com"),
+		SourceArn:    aws.String(ruleARN),
+	}
+
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.AddPermission, &in); err != nil {
+		var conflict *types.ResourceConflictException
+		if errors.As(err, &conflict) {
+			return nil
+		}
+
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return failure.ToNotFound(err, "(%s) is not deployed", qualifiedName)
+		}
+		return failure.ToSystem(err, "c.api.AddPermission failed (%s)", qualifiedName)
+	}
+
+	return nil
+}
@@ -0,0 +1,236 @@
+package lambda
+
+import (
+	"context"
+
+	awsLambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/rsb/sls/ratelimit"
+)
+
+// LimitedAPI waits on a rate limiter before every call to api, use it to
+// cap the request rate of deploys across many features
+type LimitedAPI struct {
+	api     AdapterAPI
+	limiter *ratelimit.Limiter
+}
+
+func NewLimitedAPI(api AdapterAPI, l *ratelimit.Limiter) AdapterAPI {
+	return &LimitedAPI{api: api, limiter: l}
+}
+
+func (a *LimitedAPI) UpdateFunctionCode(ctx context.Context, params *awsLambda.UpdateFunctionCodeInput, optFns ...func(*awsLambda.Options)) (*awsLambda.UpdateFunctionCodeOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.UpdateFunctionCode(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) UpdateFunctionConfiguration(ctx context.Context, params *awsLambda.UpdateFunctionConfigurationInput, optFns ...func(*awsLambda.Options)) (*awsLambda.UpdateFunctionConfigurationOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.UpdateFunctionConfiguration(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) Invoke(ctx context.Context, params *awsLambda.InvokeInput, optFns ...func(*awsLambda.Options)) (*awsLambda.InvokeOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.Invoke(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) GetFunctionConfiguration(ctx context.Context, params *awsLambda.GetFunctionConfigurationInput, optFns ...func(*awsLambda.Options)) (*awsLambda.GetFunctionConfigurationOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.GetFunctionConfiguration(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) ListVersionsByFunction(ctx context.Context, params *awsLambda.ListVersionsByFunctionInput, optFns ...func(*awsLambda.Options)) (*awsLambda.ListVersionsByFunctionOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.ListVersionsByFunction(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) GetFunction(ctx context.Context, params *awsLambda.GetFunctionInput, optFns ...func(*awsLambda.Options)) (*awsLambda.GetFunctionOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.GetFunction(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) GetAlias(ctx context.Context, params *awsLambda.GetAliasInput, optFns ...func(*awsLambda.Options)) (*awsLambda.GetAliasOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.GetAlias(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) UpdateAlias(ctx context.Context, params *awsLambda.UpdateAliasInput, optFns ...func(*awsLambda.Options)) (*awsLambda.UpdateAliasOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.UpdateAlias(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) DeleteFunction(ctx context.Context, params *awsLambda.DeleteFunctionInput, optFns ...func(*awsLambda.Options)) (*awsLambda.DeleteFunctionOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.DeleteFunction(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) CreateAlias(ctx context.Context, params *awsLambda.CreateAliasInput, optFns ...func(*awsLambda.Options)) (*awsLambda.CreateAliasOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.CreateAlias(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) ListAliases(ctx context.Context, params *awsLambda.ListAliasesInput, optFns ...func(*awsLambda.Options)) (*awsLambda.ListAliasesOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.ListAliases(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) PublishVersion(ctx context.Context, params *awsLambda.PublishVersionInput, optFns ...func(*awsLambda.Options)) (*awsLambda.PublishVersionOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.PublishVersion(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) CreateFunction(ctx context.Context, params *awsLambda.CreateFunctionInput, optFns ...func(*awsLambda.Options)) (*awsLambda.CreateFunctionOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.CreateFunction(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) ListFunctions(ctx context.Context, params *awsLambda.ListFunctionsInput, optFns ...func(*awsLambda.Options)) (*awsLambda.ListFunctionsOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.ListFunctions(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) GetFunctionConcurrency(ctx context.Context, params *awsLambda.GetFunctionConcurrencyInput, optFns ...func(*awsLambda.Options)) (*awsLambda.GetFunctionConcurrencyOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.GetFunctionConcurrency(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) PutFunctionConcurrency(ctx context.Context, params *awsLambda.PutFunctionConcurrencyInput, optFns ...func(*awsLambda.Options)) (*awsLambda.PutFunctionConcurrencyOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.PutFunctionConcurrency(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) DeleteFunctionConcurrency(ctx context.Context, params *awsLambda.DeleteFunctionConcurrencyInput, optFns ...func(*awsLambda.Options)) (*awsLambda.DeleteFunctionConcurrencyOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.DeleteFunctionConcurrency(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) GetProvisionedConcurrencyConfig(ctx context.Context, params *awsLambda.GetProvisionedConcurrencyConfigInput, optFns ...func(*awsLambda.Options)) (*awsLambda.GetProvisionedConcurrencyConfigOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.GetProvisionedConcurrencyConfig(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) PutProvisionedConcurrencyConfig(ctx context.Context, params *awsLambda.PutProvisionedConcurrencyConfigInput, optFns ...func(*awsLambda.Options)) (*awsLambda.PutProvisionedConcurrencyConfigOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.PutProvisionedConcurrencyConfig(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) DeleteProvisionedConcurrencyConfig(ctx context.Context, params *awsLambda.DeleteProvisionedConcurrencyConfigInput, optFns ...func(*awsLambda.Options)) (*awsLambda.DeleteProvisionedConcurrencyConfigOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.DeleteProvisionedConcurrencyConfig(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) PublishLayerVersion(ctx context.Context, params *awsLambda.PublishLayerVersionInput, optFns ...func(*awsLambda.Options)) (*awsLambda.PublishLayerVersionOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.PublishLayerVersion(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) GetFunctionUrlConfig(ctx context.Context, params *awsLambda.GetFunctionUrlConfigInput, optFns ...func(*awsLambda.Options)) (*awsLambda.GetFunctionUrlConfigOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.GetFunctionUrlConfig(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) CreateFunctionUrlConfig(ctx context.Context, params *awsLambda.CreateFunctionUrlConfigInput, optFns ...func(*awsLambda.Options)) (*awsLambda.CreateFunctionUrlConfigOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.CreateFunctionUrlConfig(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) UpdateFunctionUrlConfig(ctx context.Context, params *awsLambda.UpdateFunctionUrlConfigInput, optFns ...func(*awsLambda.Options)) (*awsLambda.UpdateFunctionUrlConfigOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.UpdateFunctionUrlConfig(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) AddPermission(ctx context.Context, params *awsLambda.AddPermissionInput, optFns ...func(*awsLambda.Options)) (*awsLambda.AddPermissionOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.AddPermission(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) ListEventSourceMappings(ctx context.Context, params *awsLambda.ListEventSourceMappingsInput, optFns ...func(*awsLambda.Options)) (*awsLambda.ListEventSourceMappingsOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.ListEventSourceMappings(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) CreateEventSourceMapping(ctx context.Context, params *awsLambda.CreateEventSourceMappingInput, optFns ...func(*awsLambda.Options)) (*awsLambda.CreateEventSourceMappingOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.CreateEventSourceMapping(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) UpdateEventSourceMapping(ctx context.Context, params *awsLambda.UpdateEventSourceMappingInput, optFns ...func(*awsLambda.Options)) (*awsLambda.UpdateEventSourceMappingOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.UpdateEventSourceMapping(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) TagResource(ctx context.Context, params *awsLambda.TagResourceInput, optFns ...func(*awsLambda.Options)) (*awsLambda.TagResourceOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.TagResource(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) ListTags(ctx context.Context, params *awsLambda.ListTagsInput, optFns ...func(*awsLambda.Options)) (*awsLambda.ListTagsOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.ListTags(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) PutFunctionEventInvokeConfig(ctx context.Context, params *awsLambda.PutFunctionEventInvokeConfigInput, optFns ...func(*awsLambda.Options)) (*awsLambda.PutFunctionEventInvokeConfigOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.PutFunctionEventInvokeConfig(ctx, params, optFns...)
+}
@@ -0,0 +1,37 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsLambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+// FunctionRole is the arn of a function and of the execution role it runs as
+type FunctionRole struct {
+	FunctionARN string `json:"function_arn"`
+	RoleARN     string `json:"role_arn"`
+}
+
+// ExecutionRole reads the role the function runs as
+func (c *Client) ExecutionRole(ctx context.Context, qualifiedName string) (FunctionRole, error) {
+	if qualifiedName == "" {
+		return FunctionRole{}, failure.InvalidParam("qualifiedName is empty, the function name is required")
+	}
+
+	in := awsLambda.GetFunctionConfigurationInput{FunctionName: aws.String(qualifiedName)}
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetFunctionConfiguration, &in)
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return FunctionRole{}, failure.ToNotFound(err, "function (%s) is not deployed", qualifiedName)
+		}
+		return FunctionRole{}, failure.ToSystem(err, "c.api.GetFunctionConfiguration failed (%s)", qualifiedName)
+	}
+
+	return FunctionRole{FunctionARN: aws.ToString(out.FunctionArn), RoleARN: aws.ToString(out.Role)}, nil
+}
@@ -0,0 +1,106 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsLambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	// MaxTags is how many tags lambda allows on a function
+	MaxTags        = 50
+	MaxTagKeyLen   = 128
+	MaxTagValueLen = 256
+)
+
+// ValidateTags checks the tags against the limits of lambda. The aws: prefix
+// is reserved for the tags aws adds itself.
+func ValidateTags(tags map[string]string) error {
+	if len(tags) > MaxTags {
+		return failure.InvalidParam("(%d) tags are more than the (%d) lambda allows", len(tags), MaxTags)
+	}
+
+	for k, v := range tags {
+		if k == "" || len(k) > MaxTagKeyLen {
+			return failure.InvalidParam("tag key (%s) must be from 1 to (%d) characters", k, MaxTagKeyLen)
+		}
+
+		if strings.HasPrefix(strings.ToLower(k), "aws:") {
+			return failure.InvalidParam("tag key (%s) has the aws: prefix, it is reserved", k)
+		}
+
+		if len(v) > MaxTagValueLen {
+			return failure.InvalidParam("tag (%s) value is longer than (%d) characters", k, MaxTagValueLen)
+		}
+	}
+
+	return nil
+}
+
+// Tag adds the tags to the function, the tags it already has with other
+// keys are kept. TagResource only takes an arn, the function is read for it.
+func (c *Client) Tag(ctx context.Context, qualifiedName string, tags map[string]string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	if err := ValidateTags(tags); err != nil {
+		return failure.Wrap(err, "ValidateTags failed")
+	}
+
+	info, err := c.Function(ctx, qualifiedName)
+	if err != nil {
+		return failure.Wrap(err, "c.Function failed")
+	}
+
+	return c.tagARN(ctx, info.ARN, tags)
+}
+
+func (c *Client) tagARN(ctx context.Context, arn string, tags map[string]string) error {
+	in := awsLambda.TagResourceInput{Resource: aws.String(arn), Tags: tags}
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.TagResource, &in); err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return failure.ToNotFound(err, "function (%s) is not deployed", arn)
+		}
+		return failure.ToSystem(err, "c.api.TagResource failed (%s)", arn)
+	}
+
+	return nil
+}
+
+// Tags are the tags of the function with the arn
+func (c *Client) Tags(ctx context.Context, arn string) (map[string]string, error) {
+	in := awsLambda.ListTagsInput{Resource: aws.String(arn)}
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.ListTags, &in)
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, failure.ToNotFound(err, "function (%s) is not deployed", arn)
+		}
+		return nil, failure.ToSystem(err, "c.api.ListTags failed (%s)", arn)
+	}
+
+	if out.Tags == nil {
+		return map[string]string{}, nil
+	}
+
+	return out.Tags, nil
+}
+
+// HasTags is whether tags has every key of want with the same value
+func HasTags(tags, want map[string]string) bool {
+	for k, v := range want {
+		if got, ok := tags[k]; !ok || got != v {
+			return false
+		}
+	}
+
+	return true
+}
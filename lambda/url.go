@@ -0,0 +1,263 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsLambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	// IAMAuth urls only accept requests signed with sigv4 by a principal
+	// allowed to lambda:InvokeFunctionUrl, NoAuth urls accept any request
+	IAMAuth = string(types.FunctionUrlAuthTypeAwsIam)
+	NoAuth  = string(types.FunctionUrlAuthTypeNone)
+
+	// BufferedInvoke returns the response once the handler is done,
+	// StreamInvoke streams it as it is written, see the furl package
+	BufferedInvoke = string(types.InvokeModeBuffered)
+	StreamInvoke   = string(types.InvokeModeResponseStream)
+
+	// publicURLStatement is the statement id of the permission AllowPublicURL
+	// adds, lambda refuses the requests of a NONE url without it
+	publicURLStatement = "FunctionURLAllowPublicAccess"
+)
+
+// CORSSettings is the cors config lambda answers the preflight requests of
+// a function url with. MaxAge is in seconds.
+type CORSSettings struct {
+	AllowOrigins     []string `json:"allow_origins,omitempty"`
+	AllowMethods     []string `json:"allow_methods,omitempty"`
+	AllowHeaders     []string `json:"allow_headers,omitempty"`
+	ExposeHeaders    []string `json:"expose_headers,omitempty"`
+	AllowCredentials bool     `json:"allow_credentials,omitempty"`
+	MaxAge           int32    `json:"max_age,omitempty"`
+}
+
+func (s *CORSSettings) cors() *types.Cors {
+	if s == nil {
+		return nil
+	}
+
+	result := types.Cors{
+		AllowOrigins:     s.AllowOrigins,
+		AllowMethods:     s.AllowMethods,
+		AllowHeaders:     s.AllowHeaders,
+		ExposeHeaders:    s.ExposeHeaders,
+		AllowCredentials: aws.Bool(s.AllowCredentials),
+	}
+	if s.MaxAge > 0 {
+		result.MaxAge = aws.Int32(s.MaxAge)
+	}
+
+	return &result
+}
+
+func toCORSSettings(c *types.Cors) *CORSSettings {
+	if c == nil {
+		return nil
+	}
+
+	return &CORSSettings{
+		AllowOrigins:     c.AllowOrigins,
+		AllowMethods:     c.AllowMethods,
+		AllowHeaders:     c.AllowHeaders,
+		ExposeHeaders:    c.ExposeHeaders,
+		AllowCredentials: aws.ToBool(c.AllowCredentials),
+		MaxAge:           aws.ToInt32(c.MaxAge),
+	}
+}
+
+// URLSettings is the function url of a function, or of its Alias when it
+// is not empty. On an update an empty AuthType or InvokeMode and a nil CORS
+// are left as they are, an empty CORS removes the cors config.
+type URLSettings struct {
+	QualifiedName string
+	Alias         string
+	AuthType      string
+	InvokeMode    string
+	CORS          *CORSSettings
+}
+
+func (s URLSettings) Validate() error {
+	if s.QualifiedName == "" {
+		return failure.InvalidParam("[QualifiedName] is empty, the function name is required")
+	}
+
+	if s.AuthType != "" && s.AuthType != IAMAuth && s.AuthType != NoAuth {
+		return failure.InvalidParam("[AuthType] (%s) must be %s or %s", s.AuthType, IAMAuth, NoAuth)
+	}
+
+	if s.InvokeMode != "" && s.InvokeMode != BufferedInvoke && s.InvokeMode != StreamInvoke {
+		return failure.InvalidParam("[InvokeMode] (%s) must be %s or %s", s.InvokeMode, BufferedInvoke, StreamInvoke)
+	}
+
+	return nil
+}
+
+func (s URLSettings) qualifier() *string {
+	if s.Alias == "" {
+		return nil
+	}
+	return aws.String(s.Alias)
+}
+
+// URLReport is the function url of a function or of one of its aliases
+type URLReport struct {
+	QualifiedName string        `json:"qualified_name"`
+	Alias         string        `json:"alias,omitempty"`
+	URL           string        `json:"url"`
+	AuthType      string        `json:"auth_type"`
+	InvokeMode    string        `json:"invoke_mode"`
+	CORS          *CORSSettings `json:"cors,omitempty"`
+	CreationTime  string        `json:"creation_time"`
+	LastModified  string        `json:"last_modified,omitempty"`
+}
+
+// FunctionURL reads the function url of the function, or of the alias when
+// it is not empty. A function without one is reported as NotFound.
+func (c *Client) FunctionURL(ctx context.Context, qualifiedName, alias string) (*URLReport, error) {
+	s := URLSettings{QualifiedName: qualifiedName, Alias: alias}
+	if err := s.Validate(); err != nil {
+		return nil, failure.Wrap(err, "s.Validate failed")
+	}
+
+	in := awsLambda.GetFunctionUrlConfigInput{FunctionName: aws.String(qualifiedName), Qualifier: s.qualifier()}
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetFunctionUrlConfig, &in)
+	if err != nil {
+		return nil, urlError(err, s, "c.api.GetFunctionUrlConfig")
+	}
+
+	return &URLReport{
+		QualifiedName: qualifiedName,
+		Alias:         alias,
+		URL:           aws.ToString(out.FunctionUrl),
+		AuthType:      string(out.AuthType),
+		InvokeMode:    string(out.InvokeMode),
+		CORS:          toCORSSettings(out.Cors),
+		CreationTime:  aws.ToString(out.CreationTime),
+		LastModified:  aws.ToString(out.LastModifiedTime),
+	}, nil
+}
+
+// CreateFunctionURL creates the function url, an empty AuthType is IAMAuth
+// and an empty InvokeMode BufferedInvoke. It fails with AlreadyExists when
+// the function already has one.
+func (c *Client) CreateFunctionURL(ctx context.Context, s URLSettings) (*URLReport, error) {
+	if err := s.Validate(); err != nil {
+		return nil, failure.Wrap(err, "s.Validate failed")
+	}
+
+	if s.AuthType == "" {
+		s.AuthType = IAMAuth
+	}
+	if s.InvokeMode == "" {
+		s.InvokeMode = BufferedInvoke
+	}
+
+	in := awsLambda.CreateFunctionUrlConfigInput{
+		FunctionName: aws.String(s.QualifiedName),
+		Qualifier:    s.qualifier(),
+		AuthType:     types.FunctionUrlAuthType(s.AuthType),
+		InvokeMode:   types.InvokeMode(s.InvokeMode),
+		Cors:         s.CORS.cors(),
+	}
+
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.CreateFunctionUrlConfig, &in)
+	if err != nil {
+		return nil, urlError(err, s, "c.api.CreateFunctionUrlConfig")
+	}
+
+	return &URLReport{
+		QualifiedName: s.QualifiedName,
+		Alias:         s.Alias,
+		URL:           aws.ToString(out.FunctionUrl),
+		AuthType:      string(out.AuthType),
+		InvokeMode:    string(out.InvokeMode),
+		CORS:          toCORSSettings(out.Cors),
+		CreationTime:  aws.ToString(out.CreationTime),
+	}, nil
+}
+
+// UpdateFunctionURL changes the function url, see URLSettings for what is
+// left as it is
+func (c *Client) UpdateFunctionURL(ctx context.Context, s URLSettings) (*URLReport, error) {
+	if err := s.Validate(); err != nil {
+		return nil, failure.Wrap(err, "s.Validate failed")
+	}
+
+	in := awsLambda.UpdateFunctionUrlConfigInput{
+		FunctionName: aws.String(s.QualifiedName),
+		Qualifier:    s.qualifier(),
+		AuthType:     types.FunctionUrlAuthType(s.AuthType),
+		InvokeMode:   types.InvokeMode(s.InvokeMode),
+		Cors:         s.CORS.cors(),
+	}
+
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.UpdateFunctionUrlConfig, &in)
+	if err != nil {
+		return nil, urlError(err, s, "c.api.UpdateFunctionUrlConfig")
+	}
+
+	return &URLReport{
+		QualifiedName: s.QualifiedName,
+		Alias:         s.Alias,
+		URL:           aws.ToString(out.FunctionUrl),
+		AuthType:      string(out.AuthType),
+		InvokeMode:    string(out.InvokeMode),
+		CORS:          toCORSSettings(out.Cors),
+		CreationTime:  aws.ToString(out.CreationTime),
+		LastModified:  aws.ToString(out.LastModifiedTime),
+	}, nil
+}
+
+// AllowPublicURL adds the permission that lets anyone invoke a NoAuth
+// function url, it is kept when the function already has it
+func (c *Client) AllowPublicURL(ctx context.Context, qualifiedName, alias string) error {
+	s := URLSettings{QualifiedName: qualifiedName, Alias: alias}
+	if err := s.Validate(); err != nil {
+		return failure.Wrap(err, "s.Validate failed")
+	}
+
+	in := awsLambda.AddPermissionInput{
+		FunctionName:        aws.String(qualifiedName),
+		Qualifier:           s.qualifier(),
+		StatementId:         aws.String(publicURLStatement),
+		Action:              aws.String("lambda:InvokeFunctionUrl"),
+		Principal:           aws.String("*"),
+		FunctionUrlAuthType: types.FunctionUrlAuthTypeNone,
+	}
+
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.AddPermission, &in); err != nil {
+		var conflict *types.ResourceConflictException
+		if errors.As(err, &conflict) {
+			return nil
+		}
+		return urlError(err, s, "c.api.AddPermission")
+	}
+
+	return nil
+}
+
+func urlError(err error, s URLSettings, call string) error {
+	name := s.QualifiedName
+	if s.Alias != "" {
+		name += ":" + s.Alias
+	}
+
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return failure.ToNotFound(err, "(%s) is not deployed or has no function url", name)
+	}
+
+	var conflict *types.ResourceConflictException
+	if errors.As(err, &conflict) {
+		return failure.ToAlreadyExists(err, "(%s) already has a function url", name)
+	}
+
+	return failure.ToSystem(err, "%s failed (%s)", call, name)
+}
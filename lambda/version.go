@@ -0,0 +1,258 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsLambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	LatestVersion = "$LATEST"
+)
+
+// VersionReport is a published version of a function
+type VersionReport struct {
+	Version      string `json:"version"`
+	CodeSHA256   string `json:"code_sha256"`
+	Description  string `json:"description,omitempty"`
+	LastModified string `json:"last_modified"`
+}
+
+// RollbackSettings selects what to roll back. With an Alias the alias is
+// pointed at the version, otherwise the code of the version is deployed to
+// $LATEST. An empty Version rolls back to the version before the current one.
+type RollbackSettings struct {
+	QualifiedName string
+	Version       string
+	Alias         string
+}
+
+type RollbackReport struct {
+	QualifiedName string `json:"qualified_name"`
+	Alias         string `json:"alias,omitempty"`
+	FromVersion   string `json:"from_version"`
+	ToVersion     string `json:"to_version"`
+	CodeSHA256    string `json:"code_sha256"`
+}
+
+// Versions are the published versions of the function, oldest first.
+// $LATEST is left out since it is not a version you can go back to.
+func (c *Client) Versions(ctx context.Context, qualifiedName string) ([]VersionReport, error) {
+	versions, _, err := c.versions(ctx, qualifiedName)
+	if err != nil {
+		return nil, failure.Wrap(err, "c.versions failed")
+	}
+
+	return versions, nil
+}
+
+// versions also returns the code sha256 of $LATEST
+func (c *Client) versions(ctx context.Context, qualifiedName string) ([]VersionReport, string, error) {
+	if qualifiedName == "" {
+		return nil, "", failure.InvalidParam("qualifiedName is empty, the function name is required")
+	}
+
+	var result []VersionReport
+	var latest string
+	in := awsLambda.ListVersionsByFunctionInput{FunctionName: aws.String(qualifiedName)}
+	for {
+		out, err := retry.Call(ctx, c.RetryPolicy(), c.api.ListVersionsByFunction, &in)
+		if err != nil {
+			return nil, "", failure.ToSystem(err, "c.api.ListVersionsByFunction failed (%s)", qualifiedName)
+		}
+
+		for _, v := range out.Versions {
+			if aws.ToString(v.Version) == LatestVersion {
+				latest = aws.ToString(v.CodeSha256)
+				continue
+			}
+
+			result = append(result, VersionReport{
+				Version:      aws.ToString(v.Version),
+				CodeSHA256:   aws.ToString(v.CodeSha256),
+				Description:  aws.ToString(v.Description),
+				LastModified: aws.ToString(v.LastModified),
+			})
+		}
+
+		if out.NextMarker == nil || *out.NextMarker == "" {
+			break
+		}
+		in.Marker = out.NextMarker
+	}
+
+	sort.Slice(result, func(a, b int) bool {
+		return versionNumber(result[a].Version) < versionNumber(result[b].Version)
+	})
+
+	return result, latest, nil
+}
+
+// PublishVersion publishes the code and config of $LATEST as an immutable
+// version. With a codeSHA256 lambda refuses to publish when $LATEST holds
+// other code, so the version is the code that was meant to be published.
+func (c *Client) PublishVersion(ctx context.Context, qualifiedName, description, codeSHA256 string) (*VersionReport, error) {
+	if qualifiedName == "" {
+		return nil, failure.InvalidParam("qualifiedName is empty, the function name is required")
+	}
+
+	in := awsLambda.PublishVersionInput{FunctionName: aws.String(qualifiedName)}
+	if description != "" {
+		in.Description = aws.String(description)
+	}
+	if codeSHA256 != "" {
+		in.CodeSha256 = aws.String(codeSHA256)
+	}
+
+	out, err := retry.Call(ctx, c.updatePolicy(), c.api.PublishVersion, &in)
+	if err != nil {
+		if busy := busyError(err, qualifiedName, "c.api.PublishVersion failed"); busy != nil {
+			return nil, busy
+		}
+		var mismatch *types.InvalidParameterValueException
+		if codeSHA256 != "" && errors.As(err, &mismatch) {
+			return nil, failure.ToInvalidState(err, "publish of (%s) was rejected, $LATEST may not hold code (%s)", qualifiedName, codeSHA256)
+		}
+		return nil, failure.ToSystem(err, "c.api.PublishVersion failed (%s)", qualifiedName)
+	}
+
+	return &VersionReport{
+		Version:      aws.ToString(out.Version),
+		CodeSHA256:   aws.ToString(out.CodeSha256),
+		Description:  aws.ToString(out.Description),
+		LastModified: aws.ToString(out.LastModified),
+	}, nil
+}
+
+// Rollback flips the function, or its alias, back to a published version
+func (c *Client) Rollback(ctx context.Context, s RollbackSettings) (*RollbackReport, error) {
+	versions, latest, err := c.versions(ctx, s.QualifiedName)
+	if err != nil {
+		return nil, failure.Wrap(err, "c.versions failed")
+	}
+
+	report := RollbackReport{QualifiedName: s.QualifiedName, Alias: s.Alias, FromVersion: LatestVersion}
+	current := latest
+	if s.Alias != "" {
+		in := awsLambda.GetAliasInput{FunctionName: aws.String(s.QualifiedName), Name: aws.String(s.Alias)}
+		out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetAlias, &in)
+		if err != nil {
+			return nil, failure.ToSystem(err, "c.api.GetAlias failed (%s, %s)", s.QualifiedName, s.Alias)
+		}
+		report.FromVersion = aws.ToString(out.FunctionVersion)
+		current = ""
+	}
+
+	target, err := RollbackTarget(versions, report.FromVersion, current, s.Version)
+	if err != nil {
+		return nil, failure.Wrap(err, "RollbackTarget failed (%s)", s.QualifiedName)
+	}
+	report.ToVersion = target.Version
+	report.CodeSHA256 = target.CodeSHA256
+
+	if s.Alias != "" {
+		in := awsLambda.UpdateAliasInput{
+			FunctionName:    aws.String(s.QualifiedName),
+			Name:            aws.String(s.Alias),
+			FunctionVersion: aws.String(target.Version),
+		}
+		if _, err = retry.Call(ctx, c.RetryPolicy(), c.api.UpdateAlias, &in); err != nil {
+			return nil, failure.ToSystem(err, "c.api.UpdateAlias failed (%s, %s)", s.QualifiedName, s.Alias)
+		}
+		return &report, nil
+	}
+
+	zip, err := c.versionCode(ctx, s.QualifiedName, target.Version)
+	if err != nil {
+		return nil, failure.Wrap(err, "c.versionCode failed")
+	}
+
+	if _, err = c.UpdateCode(ctx, CodePayload{QualifiedName: s.QualifiedName, ZipFile: zip}); err != nil {
+		return nil, failure.Wrap(err, "c.UpdateCode failed")
+	}
+
+	return &report, nil
+}
+
+// RollbackTarget picks the version to roll back to. The requested version
+// must exist. Otherwise it is the newest version older than from, when from
+// is $LATEST it is the newest version whose code differs from currentSHA,
+// skipping the versions published from the current code.
+func RollbackTarget(versions []VersionReport, from, currentSHA, requested string) (VersionReport, error) {
+	if requested != "" {
+		for _, v := range versions {
+			if v.Version == requested {
+				return v, nil
+			}
+		}
+		return VersionReport{}, failure.NotFound("version (%s) is not published", requested)
+	}
+
+	for idx := len(versions) - 1; idx >= 0; idx-- {
+		v := versions[idx]
+		if from != LatestVersion {
+			if versionNumber(v.Version) < versionNumber(from) {
+				return v, nil
+			}
+			continue
+		}
+
+		if v.CodeSHA256 != currentSHA {
+			return v, nil
+		}
+	}
+
+	return VersionReport{}, failure.NotFound("no published version before (%s) to roll back to", from)
+}
+
+// versionCode downloads the deployment package of a published version
+func (c *Client) versionCode(ctx context.Context, qualifiedName, version string) ([]byte, error) {
+	in := awsLambda.GetFunctionInput{FunctionName: aws.String(qualifiedName), Qualifier: aws.String(version)}
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetFunction, &in)
+	if err != nil {
+		return nil, failure.ToSystem(err, "c.api.GetFunction failed (%s:%s)", qualifiedName, version)
+	}
+
+	if out.Code == nil || aws.ToString(out.Code.Location) == "" {
+		return nil, failure.InvalidState("(%s:%s) has no code location, only zip packages can be rolled back", qualifiedName, version)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, aws.ToString(out.Code.Location), nil)
+	if err != nil {
+		return nil, failure.ToSystem(err, "http.NewRequestWithContext failed")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, failure.ToSystem(err, "code download failed (%s:%s)", qualifiedName, version)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, failure.System("code download failed (%s:%s) with status (%d)", qualifiedName, version, resp.StatusCode)
+	}
+
+	zip, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, failure.ToSystem(err, "io.ReadAll failed")
+	}
+
+	return zip, nil
+}
+
+func versionNumber(v string) int {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return -1
+	}
+	return n
+}
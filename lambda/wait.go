@@ -0,0 +1,89 @@
+package lambda
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsLambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/rsb/failure"
+)
+
+const (
+	// DefaultUpdateTimeout is how long a deploy waits for lambda to apply
+	// an update before giving up
+	DefaultUpdateTimeout = 5 * time.Minute
+	maxWaitDelay         = 10 * time.Second
+)
+
+// WaitUntilUpdated blocks until the last update of the function is applied.
+// Lambda returns from UpdateFunctionCode and UpdateFunctionConfiguration
+// while the update is still InProgress and refuses the next update until it
+// is done. An update that failed is reported as InvalidState with the reason
+// lambda gives, one still in progress after timeout as Timeout.
+func (c *Client) WaitUntilUpdated(ctx context.Context, qualifiedName string, timeout time.Duration) error {
+	if qualifiedName == "" {
+		return failure.InvalidParam("qualifiedName is empty, the function name is required")
+	}
+
+	if timeout <= 0 {
+		timeout = DefaultUpdateTimeout
+	}
+
+	in := awsLambda.GetFunctionInput{FunctionName: aws.String(qualifiedName)}
+	waiter := awsLambda.NewFunctionUpdatedV2Waiter(c.api, func(o *awsLambda.FunctionUpdatedV2WaiterOptions) {
+		o.MaxDelay = maxWaitDelay
+	})
+
+	if err := waiter.Wait(ctx, &in, timeout); err != nil {
+		return c.waitError(ctx, err, qualifiedName, timeout)
+	}
+
+	return nil
+}
+
+// WaitUntilActive blocks until a function that was just created can be
+// invoked and updated, lambda creates functions Pending
+func (c *Client) WaitUntilActive(ctx context.Context, qualifiedName string, timeout time.Duration) error {
+	if qualifiedName == "" {
+		return failure.InvalidParam("qualifiedName is empty, the function name is required")
+	}
+
+	if timeout <= 0 {
+		timeout = DefaultUpdateTimeout
+	}
+
+	in := awsLambda.GetFunctionInput{FunctionName: aws.String(qualifiedName)}
+	waiter := awsLambda.NewFunctionActiveV2Waiter(c.api, func(o *awsLambda.FunctionActiveV2WaiterOptions) {
+		o.MaxDelay = maxWaitDelay
+	})
+
+	if err := waiter.Wait(ctx, &in, timeout); err != nil {
+		return c.waitError(ctx, err, qualifiedName, timeout)
+	}
+
+	return nil
+}
+
+// waitError reads the function again so a wait that ended in a failed state
+// can be told apart from one that ran out of time
+func (c *Client) waitError(ctx context.Context, err error, qualifiedName string, timeout time.Duration) error {
+	if ctx.Err() != nil {
+		return failure.ToTimeout(err, "wait for (%s) was canceled", qualifiedName)
+	}
+
+	info, ferr := c.Function(ctx, qualifiedName)
+	if ferr != nil {
+		return failure.ToSystem(err, "wait for (%s) failed", qualifiedName)
+	}
+
+	switch {
+	case info.LastUpdateStatus == string(types.LastUpdateStatusFailed):
+		return failure.ToInvalidState(err, "update of (%s) failed: %s", qualifiedName, info.LastUpdateReason)
+	case info.State == string(types.StateFailed):
+		return failure.ToInvalidState(err, "function (%s) failed: %s", qualifiedName, info.StateReason)
+	default:
+		return failure.ToTimeout(err, "(%s) is still (%s, %s) after (%s)", qualifiedName, info.State, info.LastUpdateStatus, timeout)
+	}
+}
@@ -0,0 +1,295 @@
+package sls
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rsb/failure"
+)
+
+const (
+	ZipFormat       = PackageFormat("zip")
+	ContainerFormat = PackageFormat("container")
+
+	DefaultLayerZipName  = "layer.zip"
+	DefaultDockerfile    = "Dockerfile"
+	DefaultContainerBase = "public.ecr.aws/lambda/provided:al2"
+	// LayerAssetsDir is where lambda extracts layers, assets packaged as a
+	// layer are read from /opt/<asset> at runtime
+	LayerAssetsDir = "/opt"
+)
+
+// PackageFormat is how a compiled feature is shipped to lambda
+type PackageFormat string
+
+func (f PackageFormat) String() string {
+	return string(f)
+}
+
+// PackageSettings controls how a feature is packaged after it compiles.
+// Lambda only accepts deflate zips, so zstandard is not an option for zip
+// packages and CompressionLevel is a deflate level: 1 (fastest) to 9
+// (smallest), 0 uses the deflate default. Assets are extra files or
+// directories, relative to the feature's code dir, shipped next to the
+// binary or, with IsAssetLayer, in their own layer zip so a code change does
+// not upload them again.
+type PackageSettings struct {
+	Format           PackageFormat
+	CompressionLevel int
+	Assets           []string
+	IsAssetLayer     bool
+}
+
+func (s PackageSettings) Validate() error {
+	if s.CompressionLevel < 0 || s.CompressionLevel > flate.BestCompression {
+		return failure.InvalidParam("compression level (%d) must be between 0 and %d", s.CompressionLevel, flate.BestCompression)
+	}
+
+	switch s.Format {
+	case "", ZipFormat, ContainerFormat:
+	default:
+		return failure.InvalidParam("package format (%s) is not supported", s.Format)
+	}
+
+	return nil
+}
+
+// WithPackaging returns a copy of the feature packaged with p
+func (l Feature) WithPackaging(p PackageSettings) Feature {
+	l.Packaging = p
+	return l
+}
+
+// Package is the output of a Packager. Zip packages have their bytes in Data,
+// container packages have a docker build context in Path and no Data.
+type Package struct {
+	Format    PackageFormat `json:"format"`
+	Name      string        `json:"name"`
+	Path      string        `json:"path"`
+	Data      []byte        `json:"-"`
+	LayerName string        `json:"layer_name,omitempty"`
+	LayerPath string        `json:"layer_path,omitempty"`
+	LayerData []byte        `json:"-"`
+}
+
+// Packager turns a compiled binary into something lambda can run. Every
+// packager shares the compile step, only what happens after it differs.
+type Packager interface {
+	Format() PackageFormat
+	Package(s BuildSettings) (Package, error)
+}
+
+// NewPackager is the packager for the format of the settings, zip when
+// none is set
+func NewPackager(s PackageSettings) (Packager, error) {
+	if err := s.Validate(); err != nil {
+		return nil, failure.Wrap(err, "s.Validate failed")
+	}
+
+	if s.Format == ContainerFormat {
+		return &ContainerPackager{Base: DefaultContainerBase}, nil
+	}
+
+	return &ZipPackager{Level: s.CompressionLevel}, nil
+}
+
+// ZipPackager writes the binary as `bootstrap` in a deflate zip
+type ZipPackager struct {
+	Level int
+}
+
+func (p *ZipPackager) Format() PackageFormat {
+	return ZipFormat
+}
+
+func (p *ZipPackager) Package(s BuildSettings) (Package, error) {
+	pkg := Package{Format: ZipFormat, Name: s.ZipName}
+	if pkg.Name == "" {
+		pkg.Name = DefaultBinaryZipName
+	}
+	pkg.Path = filepath.Join(s.BuildDir, pkg.Name)
+
+	entries := []zipEntry{{Name: DefaultOutputName, Path: s.BinPath, IsExec: true}}
+	assets, err := assetEntries(s.CodeDir, s.Packaging.Assets)
+	if err != nil {
+		return pkg, failure.Wrap(err, "assetEntries failed")
+	}
+
+	if s.Packaging.IsAssetLayer && len(assets) > 0 {
+		pkg.LayerName = DefaultLayerZipName
+		pkg.LayerPath = filepath.Join(s.BuildDir, pkg.LayerName)
+		if pkg.LayerData, err = writeZip(pkg.LayerPath, p.Level, assets); err != nil {
+			return pkg, failure.Wrap(err, "writeZip failed for the layer (%s)", pkg.LayerPath)
+		}
+	} else {
+		entries = append(entries, assets...)
+	}
+
+	if pkg.Data, err = writeZip(pkg.Path, p.Level, entries); err != nil {
+		return pkg, failure.Wrap(err, "writeZip failed (%s)", pkg.Path)
+	}
+
+	return pkg, nil
+}
+
+// ContainerPackager writes a docker build context, the binary, the assets
+// and a Dockerfile on the lambda base image, to `<build dir>/container`.
+// Building and pushing the image is left to docker.
+type ContainerPackager struct {
+	Base string
+}
+
+func (p *ContainerPackager) Format() PackageFormat {
+	return ContainerFormat
+}
+
+func (p *ContainerPackager) Package(s BuildSettings) (Package, error) {
+	pkg := Package{Format: ContainerFormat, Name: DefaultDockerfile, Path: filepath.Join(s.BuildDir, "container")}
+	if err := os.MkdirAll(pkg.Path, 0755); err != nil {
+		return pkg, failure.ToSystem(err, "os.MkdirAll failed (%s)", pkg.Path)
+	}
+
+	entries := []zipEntry{{Name: DefaultOutputName, Path: s.BinPath, IsExec: true}}
+	assets, err := assetEntries(s.CodeDir, s.Packaging.Assets)
+	if err != nil {
+		return pkg, failure.Wrap(err, "assetEntries failed")
+	}
+	entries = append(entries, assets...)
+
+	for _, e := range entries {
+		if err := copyEntry(pkg.Path, e); err != nil {
+			return pkg, failure.Wrap(err, "copyEntry failed (%s)", e.Name)
+		}
+	}
+
+	base := p.Base
+	if base == "" {
+		base = DefaultContainerBase
+	}
+
+	dockerfile := strings.Join([]string{
+		"FROM " + base,
+		"COPY . ${LAMBDA_TASK_ROOT}",
+		"COPY " + DefaultOutputName + " ${LAMBDA_RUNTIME_DIR}/" + DefaultOutputName,
+		`CMD ["` + DefaultOutputName + `"]`,
+		"",
+	}, "\n")
+	if err := os.WriteFile(filepath.Join(pkg.Path, DefaultDockerfile), []byte(dockerfile), 0644); err != nil {
+		return pkg, failure.ToSystem(err, "os.WriteFile failed for the Dockerfile")
+	}
+
+	return pkg, nil
+}
+
+type zipEntry struct {
+	Name   string
+	Path   string
+	IsExec bool
+}
+
+// assetEntries expands the assets, directories are walked, into entries
+// named by their path relative to codeDir
+func assetEntries(codeDir string, assets []string) ([]zipEntry, error) {
+	var entries []zipEntry
+	for _, asset := range assets {
+		root := filepath.Join(codeDir, asset)
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+
+			name, err := filepath.Rel(codeDir, path)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, zipEntry{Name: filepath.ToSlash(name), Path: path})
+			return nil
+		})
+		if err != nil {
+			return nil, failure.ToSystem(err, "filepath.WalkDir failed for asset (%s)", asset)
+		}
+	}
+
+	return entries, nil
+}
+
+func writeZip(zf string, level int, entries []zipEntry) ([]byte, error) {
+	if level == 0 {
+		level = flate.DefaultCompression
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, level)
+	})
+
+	for _, e := range entries {
+		data, err := os.ReadFile(e.Path)
+		if err != nil {
+			return nil, failure.ToSystem(err, "os.ReadFile failed for (%s)", e.Path)
+		}
+
+		var perm fs.FileMode = 0644
+		if e.IsExec {
+			perm = 0777
+		}
+
+		header := &zip.FileHeader{
+			CreatorVersion: 3 << 8, // indicated Unix
+			ExternalAttrs:  uint32(perm) << 16,
+			Name:           e.Name,
+			Method:         zip.Deflate,
+		}
+		writer, err := zw.CreateHeader(header)
+		if err != nil {
+			return nil, failure.ToSystem(err, "zw.CreateHeader failed")
+		}
+
+		if _, err := writer.Write(data); err != nil {
+			return nil, failure.ToSystem(err, "writer.Write failed")
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, failure.ToSystem(err, "zw.Close failed")
+	}
+
+	if err := os.WriteFile(zf, buf.Bytes(), 0644); err != nil {
+		return nil, failure.ToSystem(err, "os.WriteFile failed (%s)", zf)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func copyEntry(dir string, e zipEntry) error {
+	dst := filepath.Join(dir, filepath.FromSlash(e.Name))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return failure.ToSystem(err, "os.MkdirAll failed (%s)", filepath.Dir(dst))
+	}
+
+	data, err := os.ReadFile(e.Path)
+	if err != nil {
+		return failure.ToSystem(err, "os.ReadFile failed for (%s)", e.Path)
+	}
+
+	var perm fs.FileMode = 0644
+	if e.IsExec {
+		perm = 0755
+	}
+
+	if err := os.WriteFile(dst, data, perm); err != nil {
+		return failure.ToSystem(err, "os.WriteFile failed (%s)", dst)
+	}
+
+	return nil
+}
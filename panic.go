@@ -0,0 +1,113 @@
+package sls
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/rsb/failure"
+)
+
+const (
+	MaxPanicStackLines = 32
+)
+
+// PanicError is the error produced when a handler panics. It still satisfies
+// failure.IsPanic but also keeps the recovered value and the stack of the
+// goroutine that panicked. ID is a fingerprint of the recovered value and
+// the frame that panicked, so the same panic gets the same id across
+// invocations and can be searched for in the logs.
+type PanicError struct {
+	ID        string
+	Recovered string
+	Stack     []string
+	err       error
+}
+
+// NewPanicError converts the value returned from recover and the output of
+// debug.Stack into a PanicError
+func NewPanicError(r interface{}, stack []byte) *PanicError {
+	recovered := fmt.Sprintf("%v", r)
+	lines := TrimPanicStack(string(stack))
+
+	return &PanicError{
+		ID:        PanicFingerprint(recovered, lines),
+		Recovered: recovered,
+		Stack:     lines,
+		err:       failure.Panic("invocation (%s)", recovered),
+	}
+}
+
+func (e *PanicError) Error() string {
+	return e.err.Error()
+}
+
+func (e *PanicError) Unwrap() error {
+	return e.err
+}
+
+// PanicDetails finds the PanicError anywhere in the chain of err
+func PanicDetails(err error) (*PanicError, bool) {
+	var p *PanicError
+	if !errors.As(err, &p) {
+		return nil, false
+	}
+
+	return p, true
+}
+
+// TrimPanicStack removes the goroutine header and every frame up to and
+// including the call to panic, leaving the frame that panicked first. The
+// result is capped at MaxPanicStackLines.
+func TrimPanicStack(stack string) []string {
+	lines := strings.Split(strings.TrimSpace(stack), "\n")
+
+	start := 0
+	for i, line := range lines {
+		if strings.HasPrefix(line, "panic(") {
+			// skip the panic frame and its file line
+			start = i + 2
+		}
+	}
+
+	if start == 0 && len(lines) > 0 && strings.HasPrefix(lines[0], "goroutine ") {
+		start = 1
+	}
+
+	if start > len(lines) {
+		start = len(lines)
+	}
+
+	var result []string
+	for _, line := range lines[start:] {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		result = append(result, line)
+		if len(result) == MaxPanicStackLines {
+			break
+		}
+	}
+
+	return result
+}
+
+// PanicFingerprint is a short hash of the recovered value and the function
+// that panicked. The argument list is dropped from the frame because it
+// holds pointers that change on every invocation.
+func PanicFingerprint(recovered string, stack []string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(recovered))
+	if len(stack) > 0 {
+		frame := stack[0]
+		if idx := strings.LastIndex(frame, "("); idx > 0 {
+			frame = frame[:idx]
+		}
+		_, _ = h.Write([]byte(frame))
+	}
+
+	return fmt.Sprintf("panic-%08x", h.Sum32())
+}
@@ -0,0 +1,147 @@
+package sls
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/rsb/failure"
+)
+
+// ParamScope decides where the params of a feature live in parameter store
+type ParamScope string
+
+const (
+	// ServiceParamScope keeps params flat under the service, `/<service>/<KEY>`,
+	// every feature using KEY shares the same value. It is the default.
+	ServiceParamScope ParamScope = ""
+	// FeatureParamScope nests params under the feature, `/<service>/<feature>/<KEY>`,
+	// so two features can use the same env var with different values
+	FeatureParamScope ParamScope = "feature"
+)
+
+func (s ParamScope) String() string {
+	if s == ServiceParamScope {
+		return "service"
+	}
+	return string(s)
+}
+
+// WithParamScope returns a copy of the feature using scope for its params
+func (l Feature) WithParamScope(scope ParamScope) Feature {
+	l.ParamScope = scope
+	return l
+}
+
+func (l Feature) IsFeatureScoped() bool {
+	return l.ParamScope == FeatureParamScope
+}
+
+// ParamTitle is the path, without the leading slash, the feature's params
+// are stored under. It is used wherever the app title was used for a flat
+// service, ex `orders` or `orders/checkout`
+func (l Feature) ParamTitle(appTitle string) string {
+	if l.IsFeatureScoped() {
+		return fmt.Sprintf("%s/%s", appTitle, l.Name)
+	}
+	return appTitle
+}
+
+// ParamKey is the parameter store key of the env var name for the feature
+func (l Feature) ParamKey(appTitle, name string) string {
+	return fmt.Sprintf("/%s/%s", l.ParamTitle(appTitle), name)
+}
+
+// ParamMigration maps the flat service key of each of the feature's env vars
+// to its feature scoped key, it is the plan used to move a feature to
+// FeatureParamScope
+func (l Feature) ParamMigration(appTitle string) (map[string]string, error) {
+	if appTitle == "" {
+		return nil, failure.System("appTitle is empty. should be the terraform name of the mico-service")
+	}
+
+	if l.Conf == nil {
+		return nil, failure.System("[%s] feature.Conf is nil, not initialized", l.Name)
+	}
+
+	names, err := l.Conf.EnvNames()
+	if err != nil {
+		return nil, failure.Wrap(err, "l.Conf.EnvNames failed (%s)", l.Name)
+	}
+
+	flat := l.WithParamScope(ServiceParamScope)
+	scoped := l.WithParamScope(FeatureParamScope)
+
+	result := map[string]string{}
+	for _, name := range names {
+		result[flat.ParamKey(appTitle, name)] = scoped.ParamKey(appTitle, name)
+	}
+
+	return result, nil
+}
+
+// SharedParamKeys are the flat keys of the feature that other features of
+// the service scoped to the service still read. Those keys must survive a
+// migration of the feature.
+func (s *MicroService) SharedParamKeys(feature Feature) (map[string][]string, error) {
+	appTitle := s.Name.AppTitle()
+	migration, err := feature.ParamMigration(appTitle)
+	if err != nil {
+		return nil, failure.Wrap(err, "feature.ParamMigration failed")
+	}
+
+	result := map[string][]string{}
+	for name, other := range s.Features {
+		if name == feature.Name || other.IsFeatureScoped() || other.Conf == nil {
+			continue
+		}
+
+		names, err := other.Conf.EnvNames()
+		if err != nil {
+			return nil, failure.Wrap(err, "other.Conf.EnvNames failed (%s)", name)
+		}
+
+		for _, n := range names {
+			key := other.ParamKey(appTitle, n)
+			if _, ok := migration[key]; ok {
+				result[key] = append(result[key], name)
+			}
+		}
+	}
+
+	for key := range result {
+		sort.Strings(result[key])
+	}
+
+	return result, nil
+}
+
+// ReferencedParamKeys maps every parameter store key a feature of the
+// service reads to the features reading it, defaults included. Keys of a
+// feature without a Conf are unknown so it is an error.
+func (s *MicroService) ReferencedParamKeys() (map[string][]string, error) {
+	appTitle := s.Name.AppTitle()
+	result := map[string][]string{}
+	for name, feature := range s.Features {
+		if feature.Conf == nil {
+			return nil, failure.System("[%s] feature.Conf is nil, the params it reads are unknown", name)
+		}
+
+		excluded := feature.Conf.IsDefaultsExcluded()
+		feature.Conf.MarkDefaultsAsIncluded()
+		keys, err := feature.Conf.ParamNames(feature.ParamTitle(appTitle))
+		feature.Conf.SetExcludeDefaults(excluded)
+		if err != nil {
+			return nil, failure.Wrap(err, "feature.Conf.ParamNames failed (%s)", name)
+		}
+
+		for _, key := range keys {
+			result[key] = append(result[key], name)
+		}
+	}
+
+	for key := range result {
+		sort.Strings(result[key])
+	}
+
+	return result, nil
+}
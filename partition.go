@@ -0,0 +1,128 @@
+package sls
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rsb/failure"
+)
+
+const (
+	AWSPartition      = Partition("aws")
+	GovCloudPartition = Partition("aws-us-gov")
+	ChinaPartition    = Partition("aws-cn")
+
+	DefaultDNSSuffix = "amazonaws.com"
+	ChinaDNSSuffix   = "amazonaws.com.cn"
+)
+
+// Partition is the group of regions an AWS account lives in. Accounts,
+// credentials and ARNs never cross partitions, so every ARN and endpoint
+// must be built from the partition of the region it targets.
+type Partition string
+
+func (p Partition) String() string {
+	return string(p)
+}
+
+func (p Partition) IsEmpty() bool {
+	return p.String() == ""
+}
+
+func ToPartition(partition string) (Partition, error) {
+	switch partition {
+	case AWSPartition.String():
+		return AWSPartition, nil
+	case GovCloudPartition.String():
+		return GovCloudPartition, nil
+	case ChinaPartition.String():
+		return ChinaPartition, nil
+	default:
+		return "", failure.Validation("aws partition (%s) is not mapped", partition)
+	}
+}
+
+// DNSSuffix is the domain the service endpoints of the partition live under
+func (p Partition) DNSSuffix() string {
+	if p == ChinaPartition {
+		return ChinaDNSSuffix
+	}
+
+	return DefaultDNSSuffix
+}
+
+// ARN builds an arn in the partition, region and account are left empty for
+// global resources like iam roles
+//
+//	AWSPartition.ARN("iam", "", "123456789012", "role/deploy")
+//	arn:aws:iam::123456789012:role/deploy
+func (p Partition) ARN(service, region, account, resource string) string {
+	partition := p
+	if partition.IsEmpty() {
+		partition = AWSPartition
+	}
+
+	return fmt.Sprintf("arn:%s:%s:%s:%s:%s", partition, service, region, account, resource)
+}
+
+// Partition is the partition of the region, regions outside GovCloud and
+// China are in the standard partition
+func (r Region) Partition() Partition {
+	return RegionPartition(r.String())
+}
+
+// Endpoint is the https endpoint of service in the region, ex
+// `https://lambda.cn-north-1.amazonaws.com.cn`
+func (r Region) Endpoint(service string) string {
+	return fmt.Sprintf("https://%s.%s.%s", service, r, r.Partition().DNSSuffix())
+}
+
+// ARN builds an arn for a resource in the region using its partition
+func (r Region) ARN(service, account, resource string) string {
+	return r.Partition().ARN(service, r.String(), account, resource)
+}
+
+// RegionPartition is the partition of an aws region like us-gov-west-1
+func RegionPartition(region string) Partition {
+	switch {
+	case strings.HasPrefix(region, "us-gov-"):
+		return GovCloudPartition
+	case strings.HasPrefix(region, "cn-"):
+		return ChinaPartition
+	default:
+		return AWSPartition
+	}
+}
+
+// ARNPartition is the partition of an arn, ex `arn:aws-cn:iam::123:role/x`
+// is `aws-cn`
+func ARNPartition(arn string) (Partition, error) {
+	parts := strings.SplitN(arn, ":", 3)
+	if len(parts) < 3 || parts[0] != "arn" {
+		return "", failure.InvalidParam("(%s) is not an arn", arn)
+	}
+
+	p, err := ToPartition(parts[1])
+	if err != nil {
+		return "", failure.Wrap(err, "ToPartition failed")
+	}
+
+	return p, nil
+}
+
+// Partition is the partition of the prefix's region
+func (p Prefix) Partition() Partition {
+	return p.Region.Partition()
+}
+
+// ConsoleHost is the host of the aws web console of the partition
+func (p Partition) ConsoleHost() string {
+	switch p {
+	case GovCloudPartition:
+		return "console.amazonaws-us-gov.com"
+	case ChinaPartition:
+		return "console.amazonaws.cn"
+	default:
+		return "console.aws.amazon.com"
+	}
+}
@@ -0,0 +1,349 @@
+package sls
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rsb/failure"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ProjectFileName = "sls.yaml"
+	ProjectRCName   = ".slsrc"
+	// DefaultFeatureNaming is the qualified name of a feature when the
+	// project does not declare one, ex `ue1-dev-orders-apigw_checkout`
+	DefaultFeatureNaming = "{service}-{trigger}_{feature}"
+)
+
+// ProjectFiles are the names of a project file, in the order they are
+// looked for in a directory
+var ProjectFiles = []string{ProjectFileName, ProjectRCName}
+
+// ProjectConfig is the project file at the root of a service repo, either
+// sls.yaml or .slsrc, both are yaml. It declares what a ServiceConstructor
+// would otherwise hard code:
+//
+//	app: orders
+//	cli: orders
+//	region: us-east-1
+//	repo: {owner: rsb, name: orders, ref: main}
+//	layout: {build: build}
+//	naming: {feature: "{service}-{feature}"}
+//	defaults:
+//	  cmdconfig: {format: table}
+//	envs:
+//	  prod:
+//	    cmdconfig: {read-only: true}
+//	features:
+//	  checkout: {param_scope: feature, depends_on: ["table:orders"]}
+//
+// Defaults and Envs are flag values keyed like the viper config of the
+// infra commands, `<bind struct>.<flag>` in lower case.
+type ProjectConfig struct {
+	App      string                            `yaml:"app"`
+	CLI      string                            `yaml:"cli"`
+	Region   string                            `yaml:"region"`
+	Repo     ProjectRepo                       `yaml:"repo"`
+	Layout   ProjectLayout                     `yaml:"layout"`
+	Naming   ProjectNaming                     `yaml:"naming"`
+	Defaults map[string]interface{}            `yaml:"defaults"`
+	Envs     map[string]map[string]interface{} `yaml:"envs"`
+	Features map[string]FeatureOverride        `yaml:"features"`
+	// Path is the file the project was read from, its dir is the root dir
+	Path string `yaml:"-"`
+}
+
+type ProjectRepo struct {
+	Owner string `yaml:"owner"`
+	Name  string `yaml:"name"`
+	Ref   string `yaml:"ref"`
+}
+
+// ProjectLayout replaces the dirs of DefaultCodeLayout, empty keeps the
+// default. Build is used as is, the others are relative to the root dir.
+type ProjectLayout struct {
+	Lambdas   string `yaml:"lambdas"`
+	Infra     string `yaml:"infra"`
+	Terraform string `yaml:"terraform"`
+	Build     string `yaml:"build"`
+	CLI       string `yaml:"cli"`
+}
+
+// ProjectNaming is the template of the qualified name of every feature.
+// It can use {service}, the qualified name of the service, {prefix},
+// {region}, {env}, {app}, {trigger} and {feature}.
+type ProjectNaming struct {
+	Feature string `yaml:"feature"`
+}
+
+// FeatureOverride changes a feature found in the lambdas dir. Env is added
+// to the env of the feature and DependsOn are dependency ids like
+// `table:orders`.
+type FeatureOverride struct {
+	QualifiedName string            `yaml:"qualified_name"`
+	ParamScope    ParamScope        `yaml:"param_scope"`
+	BinaryName    string            `yaml:"binary"`
+	BinaryZipName string            `yaml:"zip"`
+	Env           map[string]string `yaml:"env"`
+	DependsOn     []string          `yaml:"depends_on"`
+}
+
+// FindProjectFile looks for a project file in dir and then in its parents,
+// up to the first dir with a .git entry, the root of the repo. An empty
+// path and no error means there is none.
+func FindProjectFile(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", failure.ToSystem(err, "filepath.Abs failed (%s)", dir)
+	}
+
+	for {
+		for _, name := range ProjectFiles {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path, nil
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil || parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+func LoadProjectConfig(path string) (*ProjectConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, failure.ToSystem(err, "os.ReadFile failed (%s)", path)
+	}
+
+	var p ProjectConfig
+	if err = yaml.Unmarshal(data, &p); err != nil {
+		return nil, failure.ToConfig(err, "project file (%s) is not valid yaml", path)
+	}
+
+	if p.Path, err = filepath.Abs(path); err != nil {
+		return nil, failure.ToSystem(err, "filepath.Abs failed (%s)", path)
+	}
+
+	if err = p.Validate(); err != nil {
+		return nil, failure.Wrap(err, "project file (%s) is not valid", path)
+	}
+
+	return &p, nil
+}
+
+func (p *ProjectConfig) Validate() error {
+	if p.App == "" {
+		return failure.Config("[app] is required, it is the app title of the service")
+	}
+
+	if p.Region != "" {
+		if _, err := ToRegion(p.Region); err != nil {
+			return failure.Wrap(err, "[region] is not valid")
+		}
+	}
+
+	for name, o := range p.Features {
+		switch o.ParamScope {
+		case ServiceParamScope, FeatureParamScope:
+		default:
+			return failure.Config("[features.%s.param_scope] (%s) must be empty or feature", name, o.ParamScope)
+		}
+
+		for _, id := range o.DependsOn {
+			if _, err := ParseDependency(id); err != nil {
+				return failure.Wrap(err, "[features.%s.depends_on] is not valid", name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// RootDir is the dir of the project file
+func (p *ProjectConfig) RootDir() string {
+	return filepath.Dir(p.Path)
+}
+
+// EnvDefaults are the flag values of env, Envs merged over Defaults
+func (p *ProjectConfig) EnvDefaults(env string) map[string]interface{} {
+	result := map[string]interface{}{}
+	mergeValues(result, p.Defaults)
+	mergeValues(result, p.Envs[env])
+	return result
+}
+
+func mergeValues(dst, src map[string]interface{}) {
+	for k, v := range src {
+		k = strings.ToLower(k)
+		sub, isMap := v.(map[string]interface{})
+		cur, isCurMap := dst[k].(map[string]interface{})
+		if isMap && isCurMap {
+			mergeValues(cur, sub)
+			continue
+		}
+
+		if isMap {
+			cur = map[string]interface{}{}
+			mergeValues(cur, sub)
+			v = cur
+		}
+		dst[k] = v
+	}
+}
+
+// NewMicroService builds the service of the project in env: the layout,
+// region and repo of the file, every feature found in the lambdas dir with
+// the naming convention and the overrides applied. A feature override for
+// a feature that is not in the lambdas dir is an error.
+func (p *ProjectConfig) NewMicroService(env string) (*MicroService, error) {
+	in := MicroServiceIn{
+		RootDir:   p.RootDir(),
+		Env:       env,
+		Region:    p.Region,
+		RepoOwner: p.Repo.Owner,
+		Repo:      p.Repo.Name,
+		RepoRef:   p.Repo.Ref,
+		App:       p.App,
+		CLI:       p.CLI,
+	}
+
+	service, err := NewMicroService(in)
+	if err != nil {
+		return nil, failure.Wrap(err, "NewMicroService failed")
+	}
+
+	if p.Region != "" {
+		if service.Name.Prefix, err = NewPrefix(p.Region, env); err != nil {
+			return nil, failure.Wrap(err, "NewPrefix failed")
+		}
+		service.Account.Region = service.Name.Prefix.Region
+	}
+
+	if p.Repo.Ref != "" {
+		service.Repo.RefName = p.Repo.Ref
+	}
+
+	l := p.Layout
+	for _, dir := range []struct {
+		dst   *string
+		value string
+	}{
+		{&service.Lambdas, l.Lambdas},
+		{&service.Infra, l.Infra},
+		{&service.Terraform, l.Terraform},
+		{&service.Build, l.Build},
+		{&service.CLI, l.CLI},
+	} {
+		if dir.value != "" {
+			*dir.dst = dir.value
+		}
+	}
+	service.Resource = NewTFResource(service.TerraformDir(), service.Name.Prefix, p.Repo.Name)
+
+	if err = service.LoadFeaturesFromFilesystem(); err != nil {
+		return nil, failure.Wrap(err, "service.LoadFeaturesFromFilesystem failed (%s)", service.LambdasDir())
+	}
+
+	for name, feature := range service.Features {
+		if feature, err = p.applyFeature(service, feature); err != nil {
+			return nil, failure.Wrap(err, "p.applyFeature failed (%s)", name)
+		}
+		service.Features[name] = feature
+	}
+
+	for name := range p.Features {
+		if _, ok := service.Features[name]; !ok {
+			return nil, failure.Config("[features.%s] is not a feature in (%s)", name, service.LambdasDir())
+		}
+	}
+
+	return service, nil
+}
+
+func (p *ProjectConfig) applyFeature(service *MicroService, feature Feature) (Feature, error) {
+	if p.Naming.Feature != "" {
+		feature.QualifiedName = p.FeatureName(service, feature)
+	}
+
+	o, ok := p.Features[feature.Name]
+	if !ok {
+		return feature, nil
+	}
+
+	if o.QualifiedName != "" {
+		feature.QualifiedName = o.QualifiedName
+	}
+	if o.BinaryName != "" {
+		feature.BinaryName = o.BinaryName
+	}
+	if o.BinaryZipName != "" {
+		feature.BinaryZipName = o.BinaryZipName
+	}
+	feature = feature.WithParamScope(o.ParamScope)
+
+	if len(o.Env) > 0 {
+		env := map[string]string{}
+		for k, v := range feature.Env {
+			env[k] = v
+		}
+		for k, v := range o.Env {
+			env[k] = v
+		}
+		feature.Env = env
+	}
+
+	for _, id := range o.DependsOn {
+		d, err := ParseDependency(id)
+		if err != nil {
+			return feature, failure.Wrap(err, "ParseDependency failed")
+		}
+		feature = feature.DependsOn(d)
+	}
+
+	return feature, nil
+}
+
+// FeatureName is the qualified name of the feature from the naming template
+// of the project, or DefaultFeatureNaming
+func (p *ProjectConfig) FeatureName(service *MicroService, feature Feature) string {
+	template := p.Naming.Feature
+	if template == "" {
+		template = DefaultFeatureNaming
+	}
+
+	return strings.NewReplacer(
+		"{service}", service.Name.QualifiedName(),
+		"{prefix}", service.Name.Prefix.String(),
+		"{region}", service.Name.Prefix.Region.String(),
+		"{env}", service.Name.Prefix.Env(),
+		"{app}", service.Name.AppTitle(),
+		"{trigger}", feature.Trigger.String(),
+		"{feature}", feature.Name,
+	).Replace(template)
+}
+
+// ParseDependency reads a dependency id, the inverse of Dependency.ID
+func ParseDependency(id string) (Dependency, error) {
+	kind, name, ok := strings.Cut(id, ":")
+	if !ok || name == "" {
+		return Dependency{}, failure.InvalidParam("dependency (%s) must be in the form of <kind>:<name>", id)
+	}
+
+	switch k := DependencyKind(kind); k {
+	case TableDependency, QueueDependency, TopicDependency, BucketDependency, ParamDependency, ServiceDependency:
+		return NewDependency(k, name), nil
+	default:
+		return Dependency{}, failure.InvalidParam("dependency (%s) has an unknown kind (%s)", id, kind)
+	}
+}
+
+func (p *ProjectConfig) String() string {
+	return fmt.Sprintf("%s (%s)", p.App, p.Path)
+}
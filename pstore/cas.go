@@ -0,0 +1,198 @@
+package pstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/retry"
+)
+
+// Condition is what the current param must look like for CompareAndPut to
+// write. Use IfAbsent, IfValue or IfVersion to build one.
+type Condition struct {
+	IsAbsent bool
+	Value    *string
+	Version  int64
+}
+
+// IfAbsent only writes when the param does not exist yet
+func IfAbsent() Condition {
+	return Condition{IsAbsent: true}
+}
+
+// IfValue only writes when the param currently holds value
+func IfValue(value string) Condition {
+	return Condition{Value: &value}
+}
+
+// IfVersion only writes when the param is currently at version
+func IfVersion(version int64) Condition {
+	return Condition{Version: version}
+}
+
+func (c Condition) IsMatch(exists bool, value string, version int64) bool {
+	switch {
+	case c.IsAbsent:
+		return !exists
+	case c.Version > 0:
+		return exists && version == c.Version
+	case c.Value != nil:
+		return exists && value == *c.Value
+	}
+
+	return false
+}
+
+// CASResult describes the write done by CompareAndPut
+type CASResult struct {
+	Key      string
+	Old      string
+	Version  int64
+	Attempts int
+}
+
+// ConflictError is returned by CompareAndPut when the param does not match
+// the condition or another writer changed it. Read is the version that was
+// read before the write, Written the version the put landed at, zero when
+// nothing was written. Cause is the aws error when the retries gave up.
+type ConflictError struct {
+	Key     string
+	Msg     string
+	Read    int64
+	Written int64
+	Cause   error
+}
+
+func (e *ConflictError) Error() string {
+	msg := fmt.Sprintf("%s: conflict (read version %d, written version %d)", e.Msg, e.Read, e.Written)
+	if e.Cause != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.Cause)
+	}
+	return msg
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Cause
+}
+
+// IsConflict reports whether err is, or wraps, a ConflictError
+func IsConflict(err error) bool {
+	var ce *ConflictError
+	return errors.As(err, &ce)
+}
+
+// CompareAndPut is an optimistic, best effort compare and set next to Put,
+// the param is only written when it matches cond. ssm has no conditional
+// writes, so creation relies on Overwrite=false failing with
+// ParameterAlreadyExists and an overwrite is checked afterwards by the
+// version PutParameter returns: any version other than the next one means
+// another writer got in between the read and the write. That lost update is
+// only detected once the other writer's value was already replaced, the
+// ConflictError has both versions so the caller can reconcile them.
+// ParameterAlreadyExists and the errors of the client's retry policy are
+// retried, re-reading the param each time. When the param already holds
+// value nothing is written.
+func (c *Client) CompareAndPut(ctx context.Context, key, value string, cond Condition) (CASResult, error) {
+	result := CASResult{Key: key}
+	if key == "" {
+		return result, failure.System("key is empty, a non empty key is required")
+	}
+
+	var read int64
+	policy := c.RetryPolicy().Or(isCASRetryable)
+	err := retry.Do(ctx, policy, func(ctx context.Context) error {
+		result.Attempts++
+
+		current, err := c.paramVersion(ctx, key)
+		exists := err == nil
+		if err != nil && !failure.IsNotFound(err) {
+			return failure.Wrap(err, "c.paramVersion failed")
+		}
+		read = current.Version
+
+		result.Old = aws.ToString(current.Value)
+		if exists && result.Old == value {
+			result.Version = current.Version
+			return nil
+		}
+
+		if !cond.IsMatch(exists, result.Old, current.Version) {
+			return &ConflictError{Key: key, Msg: fmt.Sprintf("param (%s) does not match the expected condition", key), Read: read}
+		}
+
+		in := ssm.PutParameterInput{
+			Name:      aws.String(key),
+			Type:      types.ParameterTypeString,
+			Value:     aws.String(value),
+			Overwrite: sls.BoolPtr(exists),
+			Tier:      types.ParameterTierStandard,
+		}
+		if exists {
+			in.Type = current.Type
+		}
+
+		out, err := c.api.PutParameter(ctx, &in)
+		if err != nil {
+			return err
+		}
+
+		if out != nil {
+			result.Version = out.Version
+		}
+
+		if result.Version != current.Version+1 {
+			// our write landed but another writer got in between the read
+			// and the write, their value was replaced
+			msg := fmt.Sprintf("param (%s) was changed by another writer during the put", key)
+			return &ConflictError{Key: key, Msg: msg, Read: read, Written: result.Version}
+		}
+
+		return nil
+	})
+
+	var apiErr smithy.APIError
+	switch {
+	case err == nil:
+		return result, nil
+	case isCASRetryable(err):
+		msg := fmt.Sprintf("param (%s) still conflicting after (%d) attempts", key, result.Attempts)
+		return result, &ConflictError{Key: key, Msg: msg, Read: read, Cause: err}
+	case errors.As(err, &apiErr):
+		return result, handleAPIError(err, "c.api.PutParameter failed (%s)", key)
+	}
+
+	return result, err
+}
+
+func (c *Client) paramVersion(ctx context.Context, key string) (types.Parameter, error) {
+	var result types.Parameter
+	in := ssm.GetParameterInput{
+		Name:           aws.String(key),
+		WithDecryption: sls.BoolPtr(c.IsEncrypted()),
+	}
+
+	out, err := c.api.GetParameter(ctx, &in)
+	if err != nil {
+		return result, handleAPIError(err, "c.api.GetParameter failed (%s)", key)
+	}
+
+	if out != nil && out.Parameter != nil {
+		result = *out.Parameter
+	}
+
+	return result, nil
+}
+
+func isCASRetryable(err error) bool {
+	var exists *types.ParameterAlreadyExists
+	var tooMany *types.TooManyUpdates
+
+	return errors.As(err, &exists) || errors.As(err, &tooMany)
+}
@@ -0,0 +1,159 @@
+package pstore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/rsb/sls/pstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockCASAPI keeps a single param in memory, PutErrors are returned in order
+// before the put is applied
+type MockCASAPI struct {
+	MockAPI
+	Exists    bool
+	Value     string
+	Version   int64
+	Bump      int64
+	PutErrors []error
+	Puts      int
+}
+
+func (m *MockCASAPI) GetParameter(_ context.Context, params *ssm.GetParameterInput, _ ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	if !m.Exists {
+		return nil, &types.ParameterNotFound{}
+	}
+
+	return &ssm.GetParameterOutput{
+		Parameter: &types.Parameter{
+			Name:    params.Name,
+			Type:    types.ParameterTypeString,
+			Value:   aws.String(m.Value),
+			Version: m.Version,
+		},
+	}, nil
+}
+
+func (m *MockCASAPI) PutParameter(_ context.Context, params *ssm.PutParameterInput, _ ...func(*ssm.Options)) (*ssm.PutParameterOutput, error) {
+	m.Puts++
+	if len(m.PutErrors) > 0 {
+		err := m.PutErrors[0]
+		m.PutErrors = m.PutErrors[1:]
+		return nil, err
+	}
+
+	if m.Exists && !aws.ToBool(params.Overwrite) {
+		return nil, &types.ParameterAlreadyExists{}
+	}
+
+	m.Exists = true
+	m.Value = aws.ToString(params.Value)
+	m.Version += 1 + m.Bump
+
+	return &ssm.PutParameterOutput{Version: m.Version}, nil
+}
+
+func TestClient_CompareAndPut(t *testing.T) {
+	tests := []struct {
+		name        string
+		api         MockCASAPI
+		cond        pstore.Condition
+		isErr       bool
+		isConflict  bool
+		read        int64
+		written     int64
+		expectedVal string
+		attempts    int
+	}{
+		{
+			name:        "create when absent",
+			api:         MockCASAPI{},
+			cond:        pstore.IfAbsent(),
+			expectedVal: "new",
+			attempts:    1,
+		},
+		{
+			name:        "absent condition fails when param exists",
+			api:         MockCASAPI{Exists: true, Value: "old", Version: 3},
+			cond:        pstore.IfAbsent(),
+			isErr:       true,
+			isConflict:  true,
+			read:        3,
+			expectedVal: "old",
+			attempts:    1,
+		},
+		{
+			name:        "value matches",
+			api:         MockCASAPI{Exists: true, Value: "old", Version: 3},
+			cond:        pstore.IfValue("old"),
+			expectedVal: "new",
+			attempts:    1,
+		},
+		{
+			name:        "version does not match",
+			api:         MockCASAPI{Exists: true, Value: "old", Version: 3},
+			cond:        pstore.IfVersion(2),
+			isErr:       true,
+			isConflict:  true,
+			read:        3,
+			expectedVal: "old",
+			attempts:    1,
+		},
+		{
+			name:        "retry on too many updates",
+			api:         MockCASAPI{Exists: true, Value: "old", Version: 3, PutErrors: []error{&types.TooManyUpdates{}}},
+			cond:        pstore.IfVersion(3),
+			expectedVal: "new",
+			attempts:    2,
+		},
+		{
+			name:        "concurrent writer detected by version",
+			api:         MockCASAPI{Exists: true, Value: "old", Version: 3, Bump: 1},
+			cond:        pstore.IfValue("old"),
+			isErr:       true,
+			isConflict:  true,
+			read:        3,
+			written:     5,
+			expectedVal: "new",
+			attempts:    1,
+		},
+		{
+			name:        "already holds the value",
+			api:         MockCASAPI{Exists: true, Value: "new", Version: 3},
+			cond:        pstore.IfAbsent(),
+			expectedVal: "new",
+			attempts:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := tt.api
+			client, err := pstore.NewClient(&api, false)
+			require.NoError(t, err, "pstore.NewClient is not expected to fail")
+
+			result, err := client.CompareAndPut(context.TODO(), "/app/KEY", "new", tt.cond)
+			if tt.isErr {
+				require.Error(t, err, "client.CompareAndPut is expected to fail")
+				assert.Equal(t, tt.isConflict, pstore.IsConflict(err))
+
+				var conflict *pstore.ConflictError
+				if tt.isConflict && assert.True(t, errors.As(err, &conflict)) {
+					assert.Equal(t, tt.read, conflict.Read)
+					assert.Equal(t, tt.written, conflict.Written)
+				}
+			} else {
+				require.NoError(t, err, "client.CompareAndPut is not expected to fail")
+			}
+
+			assert.Equal(t, tt.expectedVal, api.Value)
+			assert.Equal(t, tt.attempts, result.Attempts)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package pstore_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
+	"github.com/rsb/sls/pstore"
+	"github.com/rsb/sls/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockCollectAPI answers GetParameters like ssm does, rejecting more than
+// MaxCollectKeys names, reporting names starting with /missing as invalid,
+// failing the chunks with a name starting with /broken and throttling the
+// ones with a name starting with /throttled
+type MockCollectAPI struct {
+	MockAPI
+	mu    sync.Mutex
+	Calls int
+}
+
+func (m *MockCollectAPI) GetParameters(_ context.Context, params *ssm.GetParametersInput, _ ...func(*ssm.Options)) (*ssm.GetParametersOutput, error) {
+	m.mu.Lock()
+	m.Calls++
+	m.mu.Unlock()
+
+	if len(params.Names) > pstore.MaxCollectKeys {
+		return nil, fmt.Errorf("member must have length less than or equal to %d", pstore.MaxCollectKeys)
+	}
+
+	for _, name := range params.Names {
+		if strings.HasPrefix(name, "/broken") {
+			return nil, fmt.Errorf("access denied for %s", name)
+		}
+
+		if strings.HasPrefix(name, "/throttled") {
+			return nil, &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+		}
+	}
+
+	out := ssm.GetParametersOutput{}
+	for _, name := range params.Names {
+		if strings.HasPrefix(name, "/missing") {
+			out.InvalidParameters = append(out.InvalidParameters, name)
+			continue
+		}
+		out.Parameters = append(out.Parameters, types.Parameter{Name: aws.String(name), Value: aws.String("v" + name)})
+	}
+
+	return &out, nil
+}
+
+func TestChunkKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     []string
+		size     int
+		expected [][]string
+	}{
+		{name: "single chunk", keys: []string{"a", "b"}, size: 10, expected: [][]string{{"a", "b"}}},
+		{name: "exact chunks", keys: []string{"a", "b", "c", "d"}, size: 2, expected: [][]string{{"a", "b"}, {"c", "d"}}},
+		{name: "remainder and duplicates", keys: []string{"a", "b", "a", "c"}, size: 2, expected: [][]string{{"a", "b"}, {"c"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, pstore.ChunkKeys(tt.keys, tt.size))
+		})
+	}
+}
+
+func TestClient_Collect_Chunked(t *testing.T) {
+	var keys []string
+	for i := 0; i < 25; i++ {
+		keys = append(keys, fmt.Sprintf("/app/KEY_%02d", i))
+	}
+	keys = append(keys, "/missing/ONE", "/missing/TWO")
+
+	api := MockCollectAPI{}
+	client, err := pstore.NewClient(&api, false)
+	require.NoError(t, err, "pstore.NewClient is not expected to fail")
+	client.SetCollectConcurrency(2)
+
+	result, invalid, err := client.Collect(context.TODO(), keys...)
+	require.NoError(t, err, "client.Collect is not expected to fail")
+
+	assert.Equal(t, 3, api.Calls)
+	assert.Len(t, result, 25)
+	assert.Equal(t, "v/app/KEY_24", result["/app/KEY_24"])
+
+	sort.Strings(invalid)
+	assert.Equal(t, []string{"/missing/ONE", "/missing/TWO"}, invalid)
+}
+
+func TestClient_Collect_ReportsEveryFailedChunk(t *testing.T) {
+	var keys []string
+	for i := 0; i < 25; i++ {
+		keys = append(keys, fmt.Sprintf("/app/KEY_%02d", i))
+	}
+	keys[0] = "/broken/FIRST"
+	keys[24] = "/broken/LAST"
+
+	api := MockCollectAPI{}
+	client, err := pstore.NewClient(&api, false)
+	require.NoError(t, err, "pstore.NewClient is not expected to fail")
+
+	_, _, err = client.Collect(context.TODO(), keys...)
+	require.Error(t, err, "client.Collect is expected to fail")
+	assert.Contains(t, err.Error(), "/broken/FIRST")
+	assert.Contains(t, err.Error(), "/broken/LAST")
+	assert.Contains(t, err.Error(), "(2) of (3) chunks failed")
+}
+
+func TestClient_Collect_Throttled(t *testing.T) {
+	api := MockCollectAPI{}
+	client, err := pstore.NewClient(&api, false)
+	require.NoError(t, err, "pstore.NewClient is not expected to fail")
+	client.SetRetryPolicy(retry.NewPolicy().WithMaxAttempts(1))
+
+	_, _, err = client.Collect(context.TODO(), "/app/KEY", "/throttled/KEY")
+	require.Error(t, err, "client.Collect is expected to fail")
+	assert.True(t, pstore.IsThrottled(err), "expected a throttled error, got %v", err)
+	assert.Contains(t, err.Error(), "/throttled/KEY")
+}
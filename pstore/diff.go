@@ -0,0 +1,103 @@
+package pstore
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rsb/failure"
+)
+
+// PathReader is anything that can resolve a parameter hierarchy, both Client
+// and FileStore satisfy it
+type PathReader interface {
+	Path(ctx context.Context, path string, recursive ...bool) (map[string]string, error)
+}
+
+// Path identifies a parameter tree in a store, like `/my-app` in qa
+type Path struct {
+	Store PathReader
+	Name  string
+}
+
+type Change struct {
+	Left  string
+	Right string
+}
+
+// DiffResult holds keys relative to the paths that were compared.
+// Added are keys only in the right tree, Removed are keys only in the left
+// tree and Changed are keys in both with different values.
+type DiffResult struct {
+	Added   map[string]string
+	Removed map[string]string
+	Changed map[string]Change
+}
+
+func (d DiffResult) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff resolves both trees recursively and compares them by their keys
+// relative to each tree's path
+func Diff(ctx context.Context, left, right Path) (DiffResult, error) {
+	var result DiffResult
+	if left.Store == nil || right.Store == nil {
+		return result, failure.System("left and right stores are required")
+	}
+
+	l, err := left.Store.Path(ctx, left.Name)
+	if err != nil {
+		return result, failure.Wrap(err, "left.Store.Path failed (%s)", left.Name)
+	}
+
+	r, err := right.Store.Path(ctx, right.Name)
+	if err != nil {
+		return result, failure.Wrap(err, "right.Store.Path failed (%s)", right.Name)
+	}
+
+	return DiffMaps(StripPath(left.Name, l), StripPath(right.Name, r)), nil
+}
+
+// DiffMaps compares two sets of key/values
+func DiffMaps(left, right map[string]string) DiffResult {
+	result := DiffResult{
+		Added:   map[string]string{},
+		Removed: map[string]string{},
+		Changed: map[string]Change{},
+	}
+
+	for k, lv := range left {
+		rv, ok := right[k]
+		if !ok {
+			result.Removed[k] = lv
+			continue
+		}
+
+		if lv != rv {
+			result.Changed[k] = Change{Left: lv, Right: rv}
+		}
+	}
+
+	for k, rv := range right {
+		if _, ok := left[k]; !ok {
+			result.Added[k] = rv
+		}
+	}
+
+	return result
+}
+
+// StripPath removes the path prefix from every key
+func StripPath(path string, in map[string]string) map[string]string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	path = strings.TrimRight(path, "/") + "/"
+
+	out := map[string]string{}
+	for k, v := range in {
+		out[strings.TrimPrefix(k, path)] = v
+	}
+
+	return out
+}
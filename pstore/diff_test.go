@@ -0,0 +1,75 @@
+package pstore_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/rsb/sls/pstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffMaps(t *testing.T) {
+	tests := []struct {
+		name     string
+		left     map[string]string
+		right    map[string]string
+		expected pstore.DiffResult
+		isEmpty  bool
+	}{
+		{
+			name:  "identical trees",
+			left:  map[string]string{"FOO": "bar"},
+			right: map[string]string{"FOO": "bar"},
+			expected: pstore.DiffResult{
+				Added:   map[string]string{},
+				Removed: map[string]string{},
+				Changed: map[string]pstore.Change{},
+			},
+			isEmpty: true,
+		},
+		{
+			name:  "added removed and changed",
+			left:  map[string]string{"FOO": "bar", "OLD": "x", "SAME": "y"},
+			right: map[string]string{"FOO": "baz", "NEW": "z", "SAME": "y"},
+			expected: pstore.DiffResult{
+				Added:   map[string]string{"NEW": "z"},
+				Removed: map[string]string{"OLD": "x"},
+				Changed: map[string]pstore.Change{"FOO": {Left: "bar", Right: "baz"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := pstore.DiffMaps(tt.left, tt.right)
+			assert.Equal(t, tt.expected, result)
+			assert.Equal(t, tt.isEmpty, result.IsEmpty())
+		})
+	}
+}
+
+func TestDiff_RelativeToPath(t *testing.T) {
+	ctx := context.TODO()
+	store, err := pstore.NewFileStore(filepath.Join(t.TempDir(), "params.json"))
+	require.NoError(t, err, "pstore.NewFileStore is not expected to fail")
+
+	for k, v := range map[string]string{
+		"/qa-app/FOO":   "bar",
+		"/qa-app/ONLY":  "qa",
+		"/prod-app/FOO": "baz",
+	} {
+		_, err = store.Put(ctx, k, v)
+		require.NoError(t, err, "store.Put is not expected to fail")
+	}
+
+	left := pstore.Path{Store: store, Name: "qa-app"}
+	right := pstore.Path{Store: store, Name: "/prod-app"}
+	result, err := pstore.Diff(ctx, left, right)
+	require.NoError(t, err, "pstore.Diff is not expected to fail")
+
+	assert.Empty(t, result.Added)
+	assert.Equal(t, map[string]string{"ONLY": "qa"}, result.Removed)
+	assert.Equal(t, map[string]pstore.Change{"FOO": {Left: "bar", Right: "baz"}}, result.Changed)
+}
@@ -0,0 +1,348 @@
+package pstore
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/dotenv"
+)
+
+const (
+	DotenvExt = ".env"
+)
+
+// FileStore is a parameter store backed by a local file. It has the same
+// behavior as Client so the infra commands can be exercised without aws
+// credentials. Files ending in .env are stored in dotenv format, everything
+// else is stored as a json object.
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileStore creates the file store, the file is created when the first
+// param is written.
+func NewFileStore(path string) (*FileStore, error) {
+	if path == "" {
+		return nil, failure.System("path is empty, a file path is required")
+	}
+
+	return &FileStore{path: path}, nil
+}
+
+func (s *FileStore) FilePath() string {
+	return s.path
+}
+
+func (s *FileStore) IsDotenv() bool {
+	return strings.HasSuffix(s.path, DotenvExt) || filepath.Base(s.path) == DotenvExt
+}
+
+// Param will retrieve a single parameter. If it does not exist a NotFound
+// error is returned
+func (s *FileStore) Param(_ context.Context, key string) (string, error) {
+	if key == "" {
+		return "", failure.System("key is empty, a non empty key is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.load()
+	if err != nil {
+		return "", failure.Wrap(err, "s.load failed")
+	}
+
+	value, ok := data[key]
+	if !ok {
+		return "", failure.NotFound("param (%s) not found in (%s)", key, s.path)
+	}
+
+	return value, nil
+}
+
+// Path retrieves all params under path. When recursive is false only the
+// direct children of path are returned.
+func (s *FileStore) Path(_ context.Context, path string, recursive ...bool) (map[string]string, error) {
+	result := map[string]string{}
+	if path == "" {
+		return result, failure.System("path is empty")
+	}
+
+	isRecursive := true
+	if len(recursive) > 0 && recursive[0] == false {
+		isRecursive = false
+	}
+
+	path = strings.TrimRight(s.EnsurePathPrefix(path), "/") + "/"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.load()
+	if err != nil {
+		return result, failure.Wrap(err, "s.load failed")
+	}
+
+	for k, v := range data {
+		if !strings.HasPrefix(k, path) {
+			continue
+		}
+
+		if !isRecursive && strings.Contains(strings.TrimPrefix(k, path), "/") {
+			continue
+		}
+
+		result[k] = v
+	}
+
+	return result, nil
+}
+
+// Collect retrieves one or many params, keys that do not exist are returned
+// in the second result
+func (s *FileStore) Collect(_ context.Context, keys ...string) (map[string]string, []string, error) {
+	if len(keys) == 0 {
+		return nil, nil, failure.System("keys must have at least one key")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.load()
+	if err != nil {
+		return nil, nil, failure.Wrap(err, "s.load failed")
+	}
+
+	var invalid []string
+	result := map[string]string{}
+	for _, k := range keys {
+		value, ok := data[k]
+		if !ok {
+			invalid = append(invalid, k)
+			continue
+		}
+		result[k] = value
+	}
+
+	return result, invalid, nil
+}
+
+// Delete will remove a single param and return its old value. If the
+// parameter does not exist a NotFound error is returned
+func (s *FileStore) Delete(_ context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.load()
+	if err != nil {
+		return "", failure.Wrap(err, "s.load failed")
+	}
+
+	old, ok := data[key]
+	if !ok {
+		return "", failure.NotFound("param (%s) not found in (%s)", key, s.path)
+	}
+
+	delete(data, key)
+	if err = s.save(data); err != nil {
+		return old, failure.Wrap(err, "s.save failed")
+	}
+
+	return old, nil
+}
+
+// Put has the same semantics as Client.Put, existing params are only
+// replaced when overwrite is true.
+func (s *FileStore) Put(_ context.Context, key, value string, overwrite ...bool) (string, error) {
+	if key == "" {
+		return "", failure.System("key is empty, a non empty key is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.load()
+	if err != nil {
+		return "", failure.Wrap(err, "s.load failed")
+	}
+
+	old, exists := data[key]
+	if exists && old == value {
+		return old, nil
+	}
+
+	isOverwrite := len(overwrite) > 0 && overwrite[0] == true
+	if exists && !isOverwrite {
+		return old, failure.System("param (%s) exists but overwrite is false", key)
+	}
+
+	data[key] = value
+	if err = s.save(data); err != nil {
+		return old, failure.Wrap(err, "s.save failed")
+	}
+
+	return old, nil
+}
+
+// CopyPath copies every param under srcPath to the same relative key under
+// dstPath, existing params are skipped unless overwrite is true
+func (s *FileStore) CopyPath(_ context.Context, srcPath, dstPath string, overwrite bool) (CopyReport, error) {
+	report := NewCopyReport()
+	if srcPath == "" || dstPath == "" {
+		return report, failure.System("srcPath and dstPath are required")
+	}
+
+	srcPath = strings.TrimRight(s.EnsurePathPrefix(srcPath), "/")
+	dstPath = strings.TrimRight(s.EnsurePathPrefix(dstPath), "/")
+	if srcPath == dstPath {
+		return report, failure.InvalidParam("srcPath and dstPath are the same (%s)", srcPath)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.load()
+	if err != nil {
+		return report, failure.Wrap(err, "s.load failed")
+	}
+
+	for k, v := range data {
+		if !strings.HasPrefix(k, srcPath+"/") {
+			continue
+		}
+
+		dst := dstPath + strings.TrimPrefix(k, srcPath)
+		if _, exists := data[dst]; exists && !overwrite {
+			report.Skipped[dst] = k
+			continue
+		}
+
+		data[dst] = v
+		report.Copied[dst] = k
+	}
+
+	if err = s.save(data); err != nil {
+		return report, failure.Wrap(err, "s.save failed")
+	}
+
+	return report, nil
+}
+
+// ParamMeta is Param as a ParamRecord. A file has no versions, every param
+// is reported as a String at version 0 modified when the file was.
+func (s *FileStore) ParamMeta(ctx context.Context, key string) (ParamRecord, error) {
+	value, err := s.Param(ctx, key)
+	if err != nil {
+		return ParamRecord{}, failure.Wrap(err, "s.Param failed")
+	}
+
+	return s.record(key, value), nil
+}
+
+// PathRecords is Path as ParamRecords, see ParamMeta
+func (s *FileStore) PathRecords(ctx context.Context, path string, recursive ...bool) (map[string]ParamRecord, error) {
+	result := map[string]ParamRecord{}
+	params, err := s.Path(ctx, path, recursive...)
+	if err != nil {
+		return result, failure.Wrap(err, "s.Path failed")
+	}
+
+	for k, v := range params {
+		result[k] = s.record(k, v)
+	}
+
+	return result, nil
+}
+
+func (s *FileStore) record(key, value string) ParamRecord {
+	r := ParamRecord{Name: key, Value: value, Type: string(types.ParameterTypeString)}
+	if info, err := os.Stat(s.path); err == nil {
+		r.LastModified = info.ModTime()
+	}
+
+	return r
+}
+
+func (s *FileStore) EnsurePathPrefix(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return path
+}
+
+// Keys returns every key in the store sorted
+func (s *FileStore) Keys() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.load()
+	if err != nil {
+		return nil, failure.Wrap(err, "s.load failed")
+	}
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+func (s *FileStore) load() (map[string]string, error) {
+	data := map[string]string{}
+	file, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return data, nil
+		}
+		return nil, failure.ToSystem(err, "os.ReadFile failed (%s)", s.path)
+	}
+
+	if len(file) == 0 {
+		return data, nil
+	}
+
+	if s.IsDotenv() {
+		data, err = dotenv.Unmarshal(file)
+		if err != nil {
+			return nil, failure.Wrap(err, "dotenv.Unmarshal failed (%s)", s.path)
+		}
+		return data, nil
+	}
+
+	if err = json.Unmarshal(file, &data); err != nil {
+		return nil, failure.ToSystem(err, "json.Unmarshal failed (%s)", s.path)
+	}
+
+	return data, nil
+}
+
+func (s *FileStore) save(data map[string]string) error {
+	var out []byte
+	var err error
+
+	if s.IsDotenv() {
+		out = dotenv.Marshal(data)
+	} else {
+		out, err = json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return failure.ToSystem(err, "json.MarshalIndent failed")
+		}
+	}
+
+	if err = os.WriteFile(s.path, out, 0600); err != nil {
+		return failure.ToSystem(err, "os.WriteFile failed (%s)", s.path)
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package pstore_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/pstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{name: "json file", file: "params.json"},
+		{name: "dotenv file", file: "params.env"},
+	}
+
+	ctx := context.TODO()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := pstore.NewFileStore(filepath.Join(t.TempDir(), tt.file))
+			require.NoError(t, err, "pstore.NewFileStore is not expected to fail")
+
+			_, err = store.Put(ctx, "/app/FOO", "bar")
+			require.NoError(t, err, "store.Put is not expected to fail")
+
+			_, err = store.Put(ctx, "/app/nested/BIZ", "baz with \"quotes\" and $vars")
+			require.NoError(t, err, "store.Put is not expected to fail")
+
+			value, err := store.Param(ctx, "/app/FOO")
+			require.NoError(t, err, "store.Param is not expected to fail")
+			assert.Equal(t, "bar", value)
+
+			all, err := store.Path(ctx, "app")
+			require.NoError(t, err, "store.Path is not expected to fail")
+			assert.Equal(t, map[string]string{
+				"/app/FOO":        "bar",
+				"/app/nested/BIZ": "baz with \"quotes\" and $vars",
+			}, all)
+
+			direct, err := store.Path(ctx, "/app", false)
+			require.NoError(t, err, "store.Path is not expected to fail")
+			assert.Equal(t, map[string]string{"/app/FOO": "bar"}, direct)
+
+			result, invalid, err := store.Collect(ctx, "/app/FOO", "/app/MISSING")
+			require.NoError(t, err, "store.Collect is not expected to fail")
+			assert.Equal(t, map[string]string{"/app/FOO": "bar"}, result)
+			assert.Equal(t, []string{"/app/MISSING"}, invalid)
+
+			old, err := store.Delete(ctx, "/app/FOO")
+			require.NoError(t, err, "store.Delete is not expected to fail")
+			assert.Equal(t, "bar", old)
+
+			_, err = store.Param(ctx, "/app/FOO")
+			require.Error(t, err, "store.Param is expected to fail after delete")
+			assert.True(t, failure.IsNotFound(err))
+		})
+	}
+}
+
+func TestFileStore_Put_Overwrite(t *testing.T) {
+	ctx := context.TODO()
+	store, err := pstore.NewFileStore(filepath.Join(t.TempDir(), "params.json"))
+	require.NoError(t, err, "pstore.NewFileStore is not expected to fail")
+
+	_, err = store.Put(ctx, "/app/FOO", "bar")
+	require.NoError(t, err, "store.Put is not expected to fail")
+
+	_, err = store.Put(ctx, "/app/FOO", "bar")
+	require.NoError(t, err, "store.Put with the same value is a no-op")
+
+	_, err = store.Put(ctx, "/app/FOO", "other")
+	require.Error(t, err, "store.Put is expected to fail without overwrite")
+	assert.Contains(t, err.Error(), "exists but overwrite is false")
+
+	old, err := store.Put(ctx, "/app/FOO", "other", true)
+	require.NoError(t, err, "store.Put is not expected to fail with overwrite")
+	assert.Equal(t, "bar", old)
+}
+
+func TestFileStore_CopyPath(t *testing.T) {
+	ctx := context.TODO()
+	store, err := pstore.NewFileStore(filepath.Join(t.TempDir(), "params.json"))
+	require.NoError(t, err, "pstore.NewFileStore is not expected to fail")
+
+	for k, v := range map[string]string{
+		"/qa-app/FOO":      "bar",
+		"/qa-app/db/HOST":  "qa-host",
+		"/dev-app/FOO":     "keep",
+		"/qa-apple/IGNORE": "x",
+	} {
+		_, err = store.Put(ctx, k, v)
+		require.NoError(t, err, "store.Put is not expected to fail")
+	}
+
+	report, err := store.CopyPath(ctx, "qa-app", "/dev-app", false)
+	require.NoError(t, err, "store.CopyPath is not expected to fail")
+	assert.Equal(t, map[string]string{"/dev-app/db/HOST": "/qa-app/db/HOST"}, report.Copied)
+	assert.Equal(t, map[string]string{"/dev-app/FOO": "/qa-app/FOO"}, report.Skipped)
+
+	value, err := store.Param(ctx, "/dev-app/FOO")
+	require.NoError(t, err, "store.Param is not expected to fail")
+	assert.Equal(t, "keep", value)
+
+	report, err = store.CopyPath(ctx, "qa-app", "dev-app", true)
+	require.NoError(t, err, "store.CopyPath is not expected to fail")
+	assert.Len(t, report.Copied, 2)
+	assert.Empty(t, report.Skipped)
+
+	value, err = store.Param(ctx, "/dev-app/FOO")
+	require.NoError(t, err, "store.Param is not expected to fail")
+	assert.Equal(t, "bar", value)
+
+	_, err = store.CopyPath(ctx, "qa-app", "/qa-app/", true)
+	require.Error(t, err, "copying a path onto itself is expected to fail")
+}
@@ -0,0 +1,104 @@
+package pstore
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	ListSeparator = ","
+)
+
+// StringList is a slice that decodes from the comma separated value used by
+// the ssm StringList type. Configurable fields declared as StringList
+// round trip through the param store, env vars and the cli flags with empty
+// items and surrounding whitespace removed.
+type StringList []string
+
+// Decode implements the rsb/conf Decoder interface
+func (l *StringList) Decode(value string) error {
+	*l = SplitList(value)
+	return nil
+}
+
+func (l StringList) String() string {
+	return JoinList(l)
+}
+
+// SplitList splits a StringList value, items are trimmed and empty items
+// are dropped
+func SplitList(value string) []string {
+	result := []string{}
+	for _, item := range strings.Split(value, ListSeparator) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		result = append(result, item)
+	}
+
+	return result
+}
+
+// JoinList converts the items into a StringList value
+func JoinList(items []string) string {
+	return strings.Join(items, ListSeparator)
+}
+
+// ParamList will retrieve a single StringList parameter as `key`. Params
+// of type String are split the same way.
+func (c *Client) ParamList(ctx context.Context, key string) ([]string, error) {
+	value, err := c.Param(ctx, key)
+	if err != nil {
+		return nil, failure.Wrap(err, "c.Param failed")
+	}
+
+	return SplitList(value), nil
+}
+
+// PutList has the same semantics as Put but stores the param with the
+// StringList type. The old value is returned as a list.
+func (c *Client) PutList(ctx context.Context, key string, values []string, overwrite ...bool) ([]string, error) {
+	for _, v := range values {
+		if strings.Contains(v, ListSeparator) {
+			return nil, failure.InvalidParam("list item (%s) for (%s) can not contain (%s)", v, key, ListSeparator)
+		}
+	}
+
+	value := JoinList(values)
+	old, err := c.Param(ctx, key)
+	if err != nil && !failure.IsNotFound(err) {
+		return nil, failure.Wrap(err, "c.Param failed")
+	}
+
+	// if we found something and the values are the same, then nothing to do
+	if !failure.IsNotFound(err) && old == value {
+		return SplitList(old), nil
+	}
+
+	isOverwrite := len(overwrite) > 0 && overwrite[0] == true
+	if isOverwrite == false && old != "" {
+		return SplitList(old), failure.System("param (%s) exists but overwrite is false", key)
+	}
+
+	in := ssm.PutParameterInput{
+		Name:      aws.String(key),
+		Type:      types.ParameterTypeStringList,
+		Value:     aws.String(value),
+		Overwrite: sls.BoolPtr(isOverwrite),
+		Tier:      types.ParameterTierStandard,
+	}
+
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.PutParameter, &in); err != nil {
+		return SplitList(old), handleAPIError(err, "c.api.PutParameter failed (%s)", key)
+	}
+
+	return SplitList(old), nil
+}
@@ -0,0 +1,84 @@
+package pstore_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/rsb/conf"
+	"github.com/rsb/sls/pstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected []string
+	}{
+		{name: "empty value", value: "", expected: []string{}},
+		{name: "single item", value: "a", expected: []string{"a"}},
+		{name: "whitespace and empty items", value: " a, b ,,c ", expected: []string{"a", "b", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := pstore.SplitList(tt.value)
+			assert.Equal(t, tt.expected, result)
+			assert.Equal(t, pstore.JoinList(tt.expected), pstore.JoinList(result))
+		})
+	}
+}
+
+func TestStringList_Decode(t *testing.T) {
+	type spec struct {
+		Origins pstore.StringList `conf:"env:TEST_ALLOWED_ORIGINS"`
+	}
+
+	t.Setenv("TEST_ALLOWED_ORIGINS", "https://a.com, https://b.com")
+
+	var s spec
+	require.NoError(t, conf.ProcessEnv(&s), "conf.ProcessEnv is not expected to fail")
+	assert.Equal(t, pstore.StringList{"https://a.com", "https://b.com"}, s.Origins)
+	assert.Equal(t, "https://a.com,https://b.com", s.Origins.String())
+}
+
+func TestClient_ParamList(t *testing.T) {
+	api := MockAPI{
+		GetParamResponse: &ssm.GetParameterOutput{
+			Parameter: &types.Parameter{
+				Type:  types.ParameterTypeStringList,
+				Value: aws.String("a,b,c"),
+			},
+		},
+	}
+
+	client, err := pstore.NewClient(&api, false)
+	require.NoError(t, err, "pstore.NewClient is not expected to fail")
+
+	result, err := client.ParamList(context.TODO(), "/app/LIST")
+	require.NoError(t, err, "client.ParamList is not expected to fail")
+	assert.Equal(t, []string{"a", "b", "c"}, result)
+}
+
+func TestClient_PutList(t *testing.T) {
+	api := MockAPI{
+		GetParamError: &types.ParameterNotFound{},
+		PutResponse:   &ssm.PutParameterOutput{},
+	}
+
+	client, err := pstore.NewClient(&api, false)
+	require.NoError(t, err, "pstore.NewClient is not expected to fail")
+
+	_, err = client.PutList(context.TODO(), "/app/LIST", []string{"a", "b"})
+	require.NoError(t, err, "client.PutList is not expected to fail")
+	require.NotNil(t, api.PutInput)
+	assert.Equal(t, types.ParameterTypeStringList, api.PutInput.Type)
+	assert.Equal(t, "a,b", aws.ToString(api.PutInput.Value))
+
+	_, err = client.PutList(context.TODO(), "/app/LIST", []string{"a,b"})
+	require.Error(t, err, "items with a separator are expected to fail")
+}
@@ -0,0 +1,118 @@
+package pstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/retry"
+)
+
+// ParamRecord is a param value with the metadata ssm keeps about it
+type ParamRecord struct {
+	Name         string    `json:"name"`
+	Value        string    `json:"value"`
+	Type         string    `json:"type"`
+	Version      int64     `json:"version"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+func NewParamRecord(p types.Parameter) ParamRecord {
+	return ParamRecord{
+		Name:         aws.ToString(p.Name),
+		Value:        aws.ToString(p.Value),
+		Type:         string(p.Type),
+		Version:      p.Version,
+		LastModified: aws.ToTime(p.LastModifiedDate),
+	}
+}
+
+// RecordValues reduces records to the key/value map returned by Path
+func RecordValues(records map[string]ParamRecord) map[string]string {
+	result := map[string]string{}
+	for k, r := range records {
+		result[k] = r.Value
+	}
+
+	return result
+}
+
+// ParamMeta retrieves a single parameter with its version, type and last
+// modified date. If the parameter does not exist a NotFound error is returned
+func (c *Client) ParamMeta(ctx context.Context, key string) (ParamRecord, error) {
+	var result ParamRecord
+	if key == "" {
+		return result, failure.System("key is empty, a non empty key is required")
+	}
+
+	in := ssm.GetParameterInput{
+		Name:           aws.String(key),
+		WithDecryption: sls.BoolPtr(c.IsEncrypted()),
+	}
+
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetParameter, &in)
+	if err != nil {
+		return result, handleAPIError(err, "c.api.GetParameter failed (%s)", key)
+	}
+
+	if out != nil && out.Parameter != nil {
+		result = NewParamRecord(*out.Parameter)
+	}
+
+	return result, nil
+}
+
+// PathRecords is Path with the metadata of every param
+func (c *Client) PathRecords(ctx context.Context, path string, recursive ...bool) (map[string]ParamRecord, error) {
+	if path == "" {
+		return map[string]ParamRecord{}, failure.System("path is empty")
+	}
+
+	isRecursive := true
+	if len(recursive) > 0 && recursive[0] == false {
+		isRecursive = false
+	}
+
+	in := ssm.GetParametersByPathInput{
+		Path:           aws.String(c.EnsurePathPrefix(path)),
+		WithDecryption: sls.BoolPtr(c.IsEncrypted()),
+		Recursive:      sls.BoolPtr(isRecursive),
+	}
+
+	createPager := c.PathPagingConstructor()
+	if createPager == nil {
+		return nil, failure.System("c.PathPagingConstructor failed. closure is not initialized")
+	}
+
+	result, err := c.ResolvePathRecords(ctx, createPager(c.api, &in))
+	if err != nil {
+		return result, failure.Wrap(err, "c.ResolvePathRecords failed")
+	}
+
+	return result, nil
+}
+
+func (c *Client) ResolvePathRecords(ctx context.Context, pager PathPaging) (map[string]ParamRecord, error) {
+	var failed = &failure.Multi{}
+	var result = map[string]ParamRecord{}
+	for pager.HasMorePages() {
+		out, err := c.nextPage(ctx, pager)
+		if err != nil {
+			failed = failure.Append(failed, handleAPIError(err, "pager.NextPage failed"))
+			continue
+		}
+
+		for _, p := range out.Parameters {
+			if p.Name == nil || p.Value == nil {
+				continue
+			}
+			result[*p.Name] = NewParamRecord(p)
+		}
+	}
+
+	return result, failed.ErrorOrNil()
+}
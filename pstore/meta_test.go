@@ -0,0 +1,71 @@
+package pstore_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/pstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_ParamMeta(t *testing.T) {
+	modified := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	api := MockAPI{
+		GetParamResponse: &ssm.GetParameterOutput{
+			Parameter: &types.Parameter{
+				Name:             aws.String("/app/FOO"),
+				Value:            aws.String("bar"),
+				Type:             types.ParameterTypeSecureString,
+				Version:          4,
+				LastModifiedDate: aws.Time(modified),
+			},
+		},
+	}
+
+	c, err := pstore.NewClient(&api, true)
+	require.NoError(t, err, "pstore.NewClient is not expected to fail")
+
+	record, err := c.ParamMeta(context.TODO(), "/app/FOO")
+	require.NoError(t, err, "c.ParamMeta is not expected to fail")
+	assert.Equal(t, pstore.ParamRecord{
+		Name:         "/app/FOO",
+		Value:        "bar",
+		Type:         "SecureString",
+		Version:      4,
+		LastModified: modified,
+	}, record)
+
+	api.GetParamError = &types.ParameterNotFound{}
+	_, err = c.ParamMeta(context.TODO(), "/app/MISSING")
+	require.Error(t, err, "c.ParamMeta is expected to fail")
+	assert.True(t, failure.IsNotFound(err))
+}
+
+func TestClient_PathRecords(t *testing.T) {
+	pager := &MockPathPager{
+		Pages: []*ssm.GetParametersByPathOutput{
+			{Parameters: []types.Parameter{{Name: aws.String("/app/FOO"), Value: aws.String("bar"), Version: 2, Type: types.ParameterTypeString}}},
+			{Parameters: []types.Parameter{{Name: aws.String("/app/BIZ"), Value: aws.String("baz"), Version: 1, Type: types.ParameterTypeStringList}}},
+		},
+	}
+
+	api := MockAPI{}
+	c, err := pstore.NewClient(&api, false)
+	require.NoError(t, err, "pstore.NewClient is not expected to fail")
+	c.SetPathPagingConstructor(func(api pstore.AdapterAPI, in *ssm.GetParametersByPathInput) pstore.PathPaging {
+		return pager
+	})
+
+	records, err := c.PathRecords(context.TODO(), "app")
+	require.NoError(t, err, "c.PathRecords is not expected to fail")
+	require.Len(t, records, 2)
+	assert.Equal(t, int64(2), records["/app/FOO"].Version)
+	assert.Equal(t, "StringList", records["/app/BIZ"].Type)
+	assert.Equal(t, map[string]string{"/app/FOO": "bar", "/app/BIZ": "baz"}, pstore.RecordValues(records))
+}
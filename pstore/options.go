@@ -0,0 +1,53 @@
+package pstore
+
+import (
+	"time"
+
+	"github.com/rsb/sls/ratelimit"
+	"github.com/rsb/sls/retry"
+)
+
+// Option configures the Client created by NewClient
+type Option func(c *Client)
+
+// WithRetryPolicy replaces retry.Default for the calls made by the client
+func WithRetryPolicy(p retry.Policy) Option {
+	return func(c *Client) {
+		c.retryPolicy = &p
+	}
+}
+
+// WithMaxAttempts caps the attempts of every call, 1 disables retries. The
+// sdk retryer is off in NewClientWithConfig, so n is the number of requests
+// sent.
+func WithMaxAttempts(n int) Option {
+	return func(c *Client) {
+		p := c.RetryPolicy().WithMaxAttempts(n)
+		c.retryPolicy = &p
+	}
+}
+
+// WithBackoff sets the delay of the first retry and the most the delay can
+// grow to
+func WithBackoff(base, max time.Duration) Option {
+	return func(c *Client) {
+		p := c.RetryPolicy().WithDelay(base, max)
+		c.retryPolicy = &p
+	}
+}
+
+// WithRateLimiter makes every call wait on l, a nil limiter is unlimited
+func WithRateLimiter(l *ratelimit.Limiter) Option {
+	return func(c *Client) {
+		if l != nil {
+			c.api = NewLimitedAPI(c.api, l)
+		}
+	}
+}
+
+// WithCollectConcurrency is SetCollectConcurrency as an option
+func WithCollectConcurrency(n int) Option {
+	return func(c *Client) {
+		c.SetCollectConcurrency(n)
+	}
+}
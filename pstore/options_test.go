@@ -0,0 +1,140 @@
+package pstore_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/smithy-go"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/pstore"
+	"github.com/rsb/sls/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type CountingAPI struct {
+	MockAPI
+	GetParamCalls int
+}
+
+func (m *CountingAPI) GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	m.GetParamCalls++
+	return m.MockAPI.GetParameter(ctx, params, optFns...)
+}
+
+func TestNewClient_Options(t *testing.T) {
+	throttled := &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+
+	tests := []struct {
+		name     string
+		opts     []pstore.Option
+		calls    int
+		attempts int
+	}{
+		{
+			name:     "max attempts disables retries",
+			opts:     []pstore.Option{pstore.WithMaxAttempts(1)},
+			calls:    1,
+			attempts: 1,
+		},
+		{
+			name:     "max attempts with backoff",
+			opts:     []pstore.Option{pstore.WithMaxAttempts(3), pstore.WithBackoff(time.Millisecond, time.Millisecond)},
+			calls:    3,
+			attempts: 3,
+		},
+		{
+			name: "retry policy",
+			opts: []pstore.Option{pstore.WithRetryPolicy(retry.NewPolicy().
+				WithMaxAttempts(2).
+				WithDelay(time.Millisecond, time.Millisecond))},
+			calls:    2,
+			attempts: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := CountingAPI{MockAPI: MockAPI{GetParamError: throttled}}
+			client, err := pstore.NewClient(&api, false, tt.opts...)
+			require.NoError(t, err, "pstore.NewClient is not expected to fail")
+			assert.Equal(t, tt.attempts, client.RetryPolicy().MaxAttempts)
+
+			_, err = client.Param(context.TODO(), "/app/FOO")
+			require.Error(t, err)
+			assert.True(t, pstore.IsThrottled(err), "expected a throttled error, got %v", err)
+			assert.False(t, failure.IsNotFound(err))
+			assert.Contains(t, err.Error(), "throttled")
+			assert.Equal(t, tt.calls, api.GetParamCalls)
+		})
+	}
+}
+
+func TestNewClient_DefaultPolicy(t *testing.T) {
+	client, err := pstore.NewClient(&MockAPI{}, false)
+	require.NoError(t, err, "pstore.NewClient is not expected to fail")
+	assert.Equal(t, retry.Default().MaxAttempts, client.RetryPolicy().MaxAttempts)
+
+	client, err = pstore.NewClient(&MockAPI{}, false, pstore.WithCollectConcurrency(7), pstore.WithRateLimiter(nil))
+	require.NoError(t, err, "pstore.NewClient is not expected to fail")
+	assert.Equal(t, 7, client.CollectConcurrency())
+}
+
+// ThrottlingHTTP answers every request with a ThrottlingException
+type ThrottlingHTTP struct {
+	Requests int
+}
+
+func (h *ThrottlingHTTP) Do(r *http.Request) (*http.Response, error) {
+	h.Requests++
+	return &http.Response{
+		StatusCode: http.StatusBadRequest,
+		Header:     http.Header{"Content-Type": []string{"application/x-amz-json-1.1"}},
+		Body:       io.NopCloser(strings.NewReader(`{"__type":"ThrottlingException","message":"Rate exceeded"}`)),
+		Request:    r,
+	}, nil
+}
+
+func TestNewClientWithConfig_Attempts(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []pstore.Option
+		requests int
+	}{
+		{
+			name:     "max attempts disables retries",
+			opts:     []pstore.Option{pstore.WithMaxAttempts(1)},
+			requests: 1,
+		},
+		{
+			name:     "max attempts with backoff",
+			opts:     []pstore.Option{pstore.WithMaxAttempts(3), pstore.WithBackoff(time.Millisecond, time.Millisecond)},
+			requests: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpClient := ThrottlingHTTP{}
+			cfg := aws.Config{
+				Region:      "us-east-1",
+				Credentials: aws.AnonymousCredentials{},
+				HTTPClient:  &httpClient,
+			}
+
+			client, err := pstore.NewClientWithConfig(cfg, false, tt.opts...)
+			require.NoError(t, err, "pstore.NewClientWithConfig is not expected to fail")
+
+			_, err = client.Param(context.TODO(), "/app/FOO")
+			require.Error(t, err)
+			assert.True(t, pstore.IsThrottled(err), "expected a throttled error, got %v", err)
+			assert.Equal(t, tt.requests, httpClient.Requests, "the sdk is not expected to retry")
+		})
+	}
+}
@@ -5,17 +5,26 @@ package pstore
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/rsb/sls"
 	"strings"
+	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
 
 	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
 
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 )
 
+const (
+	// MaxCollectKeys is the most names a single GetParameters call accepts
+	MaxCollectKeys            = 10
+	DefaultCollectConcurrency = 4
+)
+
 type AdapterAPI interface {
 	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
 	GetParameters(ctx context.Context, params *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error)
@@ -34,8 +43,10 @@ type PathPaging interface {
 type PathPagingConstructor func(api AdapterAPI, in *ssm.GetParametersByPathInput) PathPaging
 
 type Client struct {
-	api         AdapterAPI
-	isEncrypted bool
+	api                AdapterAPI
+	isEncrypted        bool
+	collectConcurrency int
+	retryPolicy        *retry.Policy
 
 	pathPagingConstructor PathPagingConstructor
 }
@@ -49,9 +60,9 @@ func NewClientWithConfigMust(cfg aws.Config, isEncrypted bool) *Client {
 	return client
 }
 
-func NewClientWithConfig(cfg aws.Config, isEncrypted bool) (*Client, error) {
-	api := ssm.NewFromConfig(cfg)
-	client, err := NewClient(api, isEncrypted)
+func NewClientWithConfig(cfg aws.Config, isEncrypted bool, opts ...Option) (*Client, error) {
+	api := ssm.NewFromConfig(retry.NoSDKRetries(cfg))
+	client, err := NewClient(api, isEncrypted, opts...)
 	if err != nil {
 		return nil, failure.Wrap(err, "NewClient failed")
 	}
@@ -59,8 +70,9 @@ func NewClientWithConfig(cfg aws.Config, isEncrypted bool) (*Client, error) {
 	return client, nil
 }
 
-// NewClient simple constructor to inject private api and configuration values
-func NewClient(api AdapterAPI, isEncrypted bool) (*Client, error) {
+// NewClient simple constructor to inject private api and configuration values.
+// Without options the client uses retry.Default and no rate limit.
+func NewClient(api AdapterAPI, isEncrypted bool, opts ...Option) (*Client, error) {
 	if api == nil {
 		return nil, failure.System("api is nil, an initialized ssmiface.SSMAPI is required")
 	}
@@ -68,9 +80,14 @@ func NewClient(api AdapterAPI, isEncrypted bool) (*Client, error) {
 	client := Client{
 		api:                   api,
 		isEncrypted:           isEncrypted,
+		collectConcurrency:    DefaultCollectConcurrency,
 		pathPagingConstructor: newPathPaginator,
 	}
 
+	for _, opt := range opts {
+		opt(&client)
+	}
+
 	return &client, nil
 }
 
@@ -82,6 +99,32 @@ func (c *Client) IsEncrypted() bool {
 	return c.isEncrypted
 }
 
+// SetCollectConcurrency limits how many GetParameters calls Collect makes
+// at the same time, values below 1 are treated as 1
+func (c *Client) SetCollectConcurrency(n int) {
+	c.collectConcurrency = n
+}
+
+func (c *Client) CollectConcurrency() int {
+	if c.collectConcurrency < 1 {
+		return 1
+	}
+	return c.collectConcurrency
+}
+
+// RetryPolicy is the policy the client retries its calls with
+func (c *Client) RetryPolicy() retry.Policy {
+	if c.retryPolicy == nil {
+		return retry.Default()
+	}
+	return *c.retryPolicy
+}
+
+// SetRetryPolicy is WithRetryPolicy for a client that already exists
+func (c *Client) SetRetryPolicy(p retry.Policy) {
+	c.retryPolicy = &p
+}
+
 func (c *Client) PathPagingConstructor() PathPagingConstructor {
 	return c.pathPagingConstructor
 }
@@ -93,16 +136,22 @@ func (c *Client) SetPathPagingConstructor(fn PathPagingConstructor) {
 // Param will retrieve a single parameter as `key` returning the value always as a string.
 // If the parameter does not exist a NotFound error is returned
 func (c *Client) Param(ctx context.Context, key string) (string, error) {
+	return c.ParamWithDecryption(ctx, key, c.IsEncrypted())
+}
+
+// ParamWithDecryption is Param with the decryption of the client overridden,
+// without decryption a SecureString is returned as its ciphertext
+func (c *Client) ParamWithDecryption(ctx context.Context, key string, decrypt bool) (string, error) {
 	var result string
 	if key == "" {
 		return result, failure.System("key is empty, a non empty key is required")
 	}
 	in := ssm.GetParameterInput{
 		Name:           aws.String(key),
-		WithDecryption: sls.BoolPtr(c.IsEncrypted()),
+		WithDecryption: sls.BoolPtr(decrypt),
 	}
 
-	out, err := c.api.GetParameter(ctx, &in)
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetParameter, &in)
 	if err != nil {
 		// handleAPIError separates out the NotFound
 		return result, handleAPIError(err, "c.api.GetParameter failed (%s)", key)
@@ -166,48 +215,86 @@ func (c *Client) Path(ctx context.Context, path string, recursive ...bool) (map[
 	return result, nil
 }
 
+// ResolvePathPages reads every page into a key/value map, see
+// ResolvePathRecords to keep the param metadata
 func (c *Client) ResolvePathPages(ctx context.Context, pager PathPaging) (map[string]string, error) {
-	var failed = &failure.Multi{}
-	var result = map[string]string{}
-	for pager.HasMorePages() {
-		out, err := pager.NextPage(ctx)
-		if err != nil {
-			failed = failure.Append(failed, err)
-			continue
-		}
-
-		for _, p := range out.Parameters {
-			if p.Name == nil || p.Value == nil {
-				continue
-			}
-			result[*p.Name] = *p.Value
-		}
-	}
-
-	return result, failed.ErrorOrNil()
+	records, err := c.ResolvePathRecords(ctx, pager)
+	return RecordValues(records), err
 }
 
-// Collect retrieves one or many params regardless of hierarchy.
+// Collect retrieves one or many params regardless of hierarchy. GetParameters
+// only accepts MaxCollectKeys names so the keys are split into chunks which
+// are resolved concurrently, up to the client's collect concurrency. Every
+// chunk that failed is reported with its keys, not only the first one.
 // Note: a second array of strings will report on any invalid params that were sent
 func (c *Client) Collect(ctx context.Context, keys ...string) (map[string]string, []string, error) {
 	if len(keys) == 0 {
 		return nil, nil, failure.System("keys must have at least one key")
 	}
 
-	var names []string
+	chunks := ChunkKeys(keys, MaxCollectKeys)
+	type chunkResult struct {
+		params  map[string]string
+		invalid []string
+		err     error
+	}
+	results := make([]chunkResult, len(chunks))
 
-	for _, k := range keys {
-		names = append(names, k)
+	limit := c.CollectConcurrency()
+	if limit > len(chunks) {
+		limit = len(chunks)
+	}
+
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for idx, chunk := range chunks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int, chunk []string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			params, invalid, err := c.collectChunk(ctx, chunk)
+			results[idx] = chunkResult{params: params, invalid: invalid, err: err}
+		}(idx, chunk)
 	}
+	wg.Wait()
 
+	var errs []error
+	for _, r := range results {
+		if r.err != nil {
+			errs = append(errs, r.err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, nil, failure.Wrap(failure.Multiple(errs), "(%d) of (%d) chunks failed in c.collectChunk", len(errs), len(chunks))
+	}
+
+	var invalid []string
+	result := map[string]string{}
+	for _, r := range results {
+
+		for k, v := range r.params {
+			result[k] = v
+		}
+		invalid = append(invalid, r.invalid...)
+	}
+
+	return result, invalid, nil
+}
+
+func (c *Client) collectChunk(ctx context.Context, names []string) (map[string]string, []string, error) {
 	in := ssm.GetParametersInput{
 		Names:          names,
 		WithDecryption: sls.BoolPtr(c.IsEncrypted()),
 	}
 
-	out, err := c.api.GetParameters(ctx, &in)
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetParameters, &in)
 	if err != nil {
-		return nil, nil, failure.ToSystem(err, "c.api.GetParametersWithContext failed (%v)", keys)
+		return nil, nil, handleAPIError(err, "c.api.GetParameters failed (%v)", names)
 	}
 
 	var invalid []string
@@ -230,6 +317,36 @@ func (c *Client) Collect(ctx context.Context, keys ...string) (map[string]string
 	return result, invalid, nil
 }
 
+// ChunkKeys removes duplicate keys and splits them into chunks of at most
+// size keys, keeping their order
+func ChunkKeys(keys []string, size int) [][]string {
+	if size < 1 {
+		size = 1
+	}
+
+	var chunks [][]string
+	var chunk []string
+	seen := map[string]bool{}
+	for _, k := range keys {
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+
+		chunk = append(chunk, k)
+		if len(chunk) == size {
+			chunks = append(chunks, chunk)
+			chunk = nil
+		}
+	}
+
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
+
 // Delete will remove a single param from the store and return its old value.
 // If the parameter does not exist a NotFound error is returned
 func (c *Client) Delete(ctx context.Context, key string) (string, error) {
@@ -244,15 +361,16 @@ func (c *Client) Delete(ctx context.Context, key string) (string, error) {
 		Name: aws.String(key),
 	}
 
-	if _, err = c.api.DeleteParameter(ctx, &in); err != nil {
-		return result, failure.ToSystem(err, "c.api.DeleteParameter failed (%s)", key)
+	if _, err = retry.Call(ctx, c.RetryPolicy(), c.api.DeleteParameter, &in); err != nil {
+		return result, handleAPIError(err, "c.api.DeleteParameter failed (%s)", key)
 	}
 
 	return result, nil
 }
 
 // Put will check the existence of the parameter and only change them if they are
-// different, or it does not exist
+// different, or it does not exist. The read and the write are not atomic, use
+// CompareAndPut when concurrent writers are expected.
 func (c *Client) Put(ctx context.Context, key, value string, overwrite ...bool) (string, error) {
 	old, err := c.Param(ctx, key)
 	if err != nil && !failure.IsNotFound(err) {
@@ -281,13 +399,97 @@ func (c *Client) Put(ctx context.Context, key, value string, overwrite ...bool)
 		Tier:      types.ParameterTierStandard,
 	}
 
-	if _, err := c.api.PutParameter(ctx, &in); err != nil {
-		return old, failure.ToSystem(err, "c.api.PutParameterWithContext failed (%s)", key)
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.PutParameter, &in); err != nil {
+		return old, handleAPIError(err, "c.api.PutParameterWithContext failed (%s)", key)
 	}
 
 	return old, nil
 }
 
+// CopyReport maps every destination key to the source key it was copied from
+type CopyReport struct {
+	Copied  map[string]string
+	Skipped map[string]string
+}
+
+func NewCopyReport() CopyReport {
+	return CopyReport{Copied: map[string]string{}, Skipped: map[string]string{}}
+}
+
+// CopyPath copies every param under srcPath to the same relative key under
+// dstPath. Values are always decrypted on read and written back with the
+// original type, so SecureString params stay encrypted. Existing params are
+// skipped unless overwrite is true.
+func (c *Client) CopyPath(ctx context.Context, srcPath, dstPath string, overwrite bool) (CopyReport, error) {
+	return c.CopyPathRewrite(ctx, srcPath, dstPath, overwrite, nil)
+}
+
+// CopyPathRewrite is CopyPath with every value passed through rewrite before
+// it is written, a nil rewrite copies the values as is
+func (c *Client) CopyPathRewrite(ctx context.Context, srcPath, dstPath string, overwrite bool, rewrite func(value string) string) (CopyReport, error) {
+	report := NewCopyReport()
+	if srcPath == "" || dstPath == "" {
+		return report, failure.System("srcPath and dstPath are required")
+	}
+
+	srcPath = strings.TrimRight(c.EnsurePathPrefix(srcPath), "/")
+	dstPath = strings.TrimRight(c.EnsurePathPrefix(dstPath), "/")
+	if srcPath == dstPath {
+		return report, failure.InvalidParam("srcPath and dstPath are the same (%s)", srcPath)
+	}
+
+	in := ssm.GetParametersByPathInput{
+		Path:           aws.String(srcPath),
+		WithDecryption: sls.BoolPtr(true),
+		Recursive:      sls.BoolPtr(true),
+	}
+
+	createPager := c.PathPagingConstructor()
+	if createPager == nil {
+		return report, failure.System("c.PathPagingConstructor failed. closure is not initialized")
+	}
+	pager := createPager(c.api, &in)
+
+	for pager.HasMorePages() {
+		out, err := c.nextPage(ctx, pager)
+		if err != nil {
+			return report, handleAPIError(err, "pager.NextPage failed (%s)", srcPath)
+		}
+
+		for _, p := range out.Parameters {
+			if p.Name == nil || p.Value == nil {
+				continue
+			}
+
+			dst := dstPath + strings.TrimPrefix(*p.Name, srcPath)
+			value := *p.Value
+			if rewrite != nil {
+				value = rewrite(value)
+			}
+
+			put := ssm.PutParameterInput{
+				Name:      aws.String(dst),
+				Type:      p.Type,
+				Value:     aws.String(value),
+				Overwrite: sls.BoolPtr(overwrite),
+				Tier:      types.ParameterTierStandard,
+			}
+
+			if _, err = retry.Call(ctx, c.RetryPolicy(), c.api.PutParameter, &put); err != nil {
+				var exists *types.ParameterAlreadyExists
+				if errors.As(err, &exists) {
+					report.Skipped[dst] = *p.Name
+					continue
+				}
+				return report, handleAPIError(err, "c.api.PutParameter failed (%s -> %s)", *p.Name, dst)
+			}
+			report.Copied[dst] = *p.Name
+		}
+	}
+
+	return report, nil
+}
+
 func (c *Client) EnsurePathPrefix(path string) string {
 	if !strings.HasPrefix(path, "/") {
 		path = "/" + path
@@ -301,13 +503,44 @@ func handleAPIError(err error, msg string, a ...interface{}) error {
 		return nil
 	}
 
-	result := failure.ToSystem(err, msg, a...)
 	var pne *types.ParameterNotFound
-	if errors.As(err, &pne) {
-		result = failure.ToNotFound(err, msg, a...)
+	switch {
+	case errors.As(err, &pne):
+		return failure.ToNotFound(err, msg, a...)
+	case retry.IsThrottle(err):
+		return &ThrottledError{Msg: fmt.Sprintf(msg, a...), Cause: err}
 	}
 
-	return result
+	return failure.ToSystem(err, msg, a...)
+}
+
+// ThrottledError is returned when ssm kept rejecting calls for exceeding its
+// TPS limits after every retry. Cause is the raw aws error.
+type ThrottledError struct {
+	Msg   string
+	Cause error
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%s: throttled: %v", e.Msg, e.Cause)
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return e.Cause
+}
+
+// IsThrottled reports whether err is, or wraps, a ThrottledError
+func IsThrottled(err error) bool {
+	var te *ThrottledError
+	return errors.As(err, &te)
+}
+
+// nextPage retries a page of GetParametersByPath, the sdk paginator only
+// advances when a page succeeds
+func (c *Client) nextPage(ctx context.Context, pager PathPaging) (*ssm.GetParametersByPathOutput, error) {
+	return retry.DoValue(ctx, c.RetryPolicy(), func(ctx context.Context) (*ssm.GetParametersByPathOutput, error) {
+		return pager.NextPage(ctx)
+	})
 }
 
 func newPathPaginator(api AdapterAPI, in *ssm.GetParametersByPathInput) PathPaging {
@@ -239,6 +239,34 @@ func TestClient_Path_Failures(t *testing.T) {
 	}
 }
 
+func TestClient_ParamWithDecryption(t *testing.T) {
+	tests := []struct {
+		name        string
+		isEncrypted bool
+		decrypt     bool
+	}{
+		{name: "encrypted client reads the ciphertext", isEncrypted: true, decrypt: false},
+		{name: "plain client decrypts the value", isEncrypted: false, decrypt: true},
+	}
+
+	ctx := context.TODO()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := MockAPI{GetParamResponse: &ssm.GetParameterOutput{
+				Parameter: &types.Parameter{Value: aws.String("bar")},
+			}}
+			c, err := pstore.NewClient(&api, tt.isEncrypted)
+			require.NoError(t, err, "pstore.NewClient is not expected to fail")
+
+			value, err := c.ParamWithDecryption(ctx, "foo", tt.decrypt)
+			require.NoError(t, err, "c.ParamWithDecryption is not expected to fail")
+			assert.Equal(t, "bar", value)
+			require.NotNil(t, api.GetParamInput)
+			assert.Equal(t, tt.decrypt, aws.ToBool(api.GetParamInput.WithDecryption))
+		})
+	}
+}
+
 type MockAPI struct {
 	GetParamError     error
 	GetParamResponse  *ssm.GetParameterOutput
@@ -250,9 +278,12 @@ type MockAPI struct {
 	DeleteResponse    *ssm.DeleteParameterOutput
 	PutError          error
 	PutResponse       *ssm.PutParameterOutput
+	PutInput          *ssm.PutParameterInput
+	GetParamInput     *ssm.GetParameterInput
 }
 
 func (m *MockAPI) GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	m.GetParamInput = params
 	return m.GetParamResponse, m.GetParamError
 }
 func (m *MockAPI) GetParameters(ctx context.Context, params *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error) {
@@ -266,6 +297,7 @@ func (m *MockAPI) DeleteParameter(ctx context.Context, params *ssm.DeleteParamet
 }
 
 func (m *MockAPI) PutParameter(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error) {
+	m.PutInput = params
 	return m.PutResponse, m.PutError
 }
 
@@ -0,0 +1,54 @@
+package pstore
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/rsb/sls/ratelimit"
+)
+
+// LimitedAPI waits on a rate limiter before every call to api, use it to
+// cap the request rate of bulk param imports and copies
+type LimitedAPI struct {
+	api     AdapterAPI
+	limiter *ratelimit.Limiter
+}
+
+func NewLimitedAPI(api AdapterAPI, l *ratelimit.Limiter) AdapterAPI {
+	return &LimitedAPI{api: api, limiter: l}
+}
+
+func (a *LimitedAPI) GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.GetParameter(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) GetParameters(ctx context.Context, params *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.GetParameters(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) GetParametersByPath(ctx context.Context, params *ssm.GetParametersByPathInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.GetParametersByPath(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) DeleteParameter(ctx context.Context, params *ssm.DeleteParameterInput, optFns ...func(*ssm.Options)) (*ssm.DeleteParameterOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.DeleteParameter(ctx, params, optFns...)
+}
+
+func (a *LimitedAPI) PutParameter(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error) {
+	if err := a.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.PutParameter(ctx, params, optFns...)
+}
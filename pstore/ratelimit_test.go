@@ -0,0 +1,47 @@
+package pstore_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/rsb/sls/pstore"
+	"github.com/rsb/sls/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitedAPI_Param(t *testing.T) {
+	api := MockAPI{
+		GetParamResponse: &ssm.GetParameterOutput{
+			Parameter: &types.Parameter{Value: aws.String("bar")},
+		},
+	}
+
+	// 20 calls per second with no burst, so 3 calls need at least 100ms
+	limited := pstore.NewLimitedAPI(&api, ratelimit.New(20, 1))
+	client, err := pstore.NewClient(limited, false)
+	require.NoError(t, err, "pstore.NewClient is not expected to fail")
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		value, err := client.Param(context.TODO(), "/app/FOO")
+		require.NoError(t, err, "client.Param is not expected to fail")
+		assert.Equal(t, "bar", value)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+}
+
+func TestLimitedAPI_Unlimited(t *testing.T) {
+	api := MockAPI{PutResponse: &ssm.PutParameterOutput{}}
+	limited := pstore.NewLimitedAPI(&api, ratelimit.New(0, 0))
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	_, err := limited.PutParameter(ctx, &ssm.PutParameterInput{})
+	require.NoError(t, err, "a nil limiter is not expected to wait on ctx")
+}
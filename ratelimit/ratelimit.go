@@ -0,0 +1,139 @@
+// Package ratelimit caps the request rate of outbound aws calls. A Limiter
+// is a token bucket shared by everything that calls Wait on it, so a bulk
+// cli operation or a backfill can be slowed down without changing the code
+// that makes the calls. The pstore, lambda and dynamo packages have
+// LimitedAPI wrappers, any other sdk client can use WithLimiter.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/rsb/failure"
+)
+
+const (
+	MiddlewareID = "SLSRateLimit"
+)
+
+// Config is the conf friendly way to build a limiter. A Rate of 0
+// disables the limit.
+type Config struct {
+	Rate  float64 `conf:"env:SLS_AWS_RATE_LIMIT, default:0"`
+	Burst int     `conf:"env:SLS_AWS_RATE_BURST, default:1"`
+}
+
+// Limiter is a token bucket holding up to burst tokens refilled at rate
+// tokens per second. A nil Limiter never waits.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// New creates a limiter allowing rate calls per second with bursts of up
+// to burst calls. A rate of 0 or less returns nil, which is unlimited.
+func New(rate float64, burst int) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &Limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+func NewFromConfig(c Config) *Limiter {
+	return New(c.Rate, c.Burst)
+}
+
+func (l *Limiter) Rate() float64 {
+	if l == nil {
+		return 0
+	}
+	return l.rate
+}
+
+// reserve takes a token and returns how long the caller has to wait for it
+func (l *Limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// Wait blocks until a token is available or ctx is done
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	delay := l.reserve()
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return failure.ToTimeout(ctx.Err(), "ctx done while waiting for the rate limit")
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Middleware waits on the limiter before every attempt of an sdk operation
+func Middleware(l *Limiter) func(stack *middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		if l == nil {
+			return nil
+		}
+
+		m := middleware.InitializeMiddlewareFunc(MiddlewareID, func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			if err := l.Wait(ctx); err != nil {
+				return middleware.InitializeOutput{}, middleware.Metadata{}, err
+			}
+			return next.HandleInitialize(ctx, in)
+		})
+
+		return stack.Initialize.Add(m, middleware.Before)
+	}
+}
+
+// WithLimiter returns a copy of cfg whose clients wait on l before every
+// call, use it for clients that have no LimitedAPI wrapper
+func WithLimiter(cfg aws.Config, l *Limiter) aws.Config {
+	if l == nil {
+		return cfg
+	}
+
+	cfg = cfg.Copy()
+	cfg.APIOptions = append(cfg.APIOptions, Middleware(l))
+	return cfg
+}
@@ -31,6 +31,8 @@ const (
 	SaEast1      = Region("sa-east-1")
 	UsGovEast1   = Region("us-gov-east-1")
 	UsGovWest1   = Region("us-gov-west-1")
+	CnNorth1     = Region("cn-north-1")
+	CnNorthwest1 = Region("cn-northwest-1")
 )
 
 var DefaultRegion = Region("us-east-1")
@@ -101,6 +103,10 @@ func ToRegion(region string) (Region, error) {
 		r = UsGovEast1
 	case UsGovWest1.String(), UsGovWest1.Code():
 		r = UsGovWest1
+	case CnNorth1.String(), CnNorth1.Code():
+		r = CnNorth1
+	case CnNorthwest1.String(), CnNorthwest1.Code():
+		r = CnNorthwest1
 
 	default:
 		err = failure.Validation("aws region (%s) is not mapped", region)
@@ -110,11 +116,19 @@ func ToRegion(region string) (Region, error) {
 }
 
 // RegionCode takes an AWS region like us-east-1 and compresses into a smaller
-// code like (us-east-1) -> (use1) which is used in resource naming
+// code like (us-east-1) -> (use1) which is used in resource naming.
+// GovCloud regions keep the first letter of gov (us-gov-west-1) -> (usgw1)
+// and the china regions that would collide use two letters for the
+// direction (cn-northwest-1) -> (cnnw1).
 func RegionCode(region string) string {
 	parts := strings.Split(region, "-")
-	if len(parts) != 3 {
+	switch {
+	case len(parts) == 4 && parts[1] == "gov":
+		return fmt.Sprintf("%s%s%s%s", parts[0], string(parts[1][0]), string(parts[2][0]), parts[3])
+	case len(parts) != 3 || parts[1] == "":
 		return ""
+	case RegionPartition(region) == ChinaPartition && len(parts[1]) > len("north"):
+		return fmt.Sprintf("%s%s%s%s", parts[0], string(parts[1][0]), string(parts[1][len("north")]), parts[2])
 	}
 	return fmt.Sprintf("%s%s%s", parts[0], string(parts[1][0]), parts[2])
 }
@@ -0,0 +1,181 @@
+// Package restapi implements an api gateway management client used by the
+// infra commands to redeploy the stage of a rest api, so the changes made to
+// its apigw features take effect
+package restapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/apigateway"
+	"github.com/aws/aws-sdk-go-v2/service/apigateway/types"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+type AdapterAPI interface {
+	CreateDeployment(ctx context.Context, params *apigateway.CreateDeploymentInput, optFns ...func(*apigateway.Options)) (*apigateway.CreateDeploymentOutput, error)
+	FlushStageCache(ctx context.Context, params *apigateway.FlushStageCacheInput, optFns ...func(*apigateway.Options)) (*apigateway.FlushStageCacheOutput, error)
+	GetRestApis(ctx context.Context, params *apigateway.GetRestApisInput, optFns ...func(*apigateway.Options)) (*apigateway.GetRestApisOutput, error)
+}
+
+type Client struct {
+	api         AdapterAPI
+	retryPolicy *retry.Policy
+}
+
+func NewClientWithConfig(cfg aws.Config) *Client {
+	api := apigateway.NewFromConfig(retry.NoSDKRetries(cfg))
+	return NewClient(api)
+}
+
+func NewClient(api AdapterAPI) *Client {
+	return &Client{api: api}
+}
+
+// RetryPolicy is the policy the client retries its calls with
+func (c *Client) RetryPolicy() retry.Policy {
+	if c.retryPolicy == nil {
+		return retry.Default()
+	}
+	return *c.retryPolicy
+}
+
+// SetRetryPolicy replaces retry.Default for the calls made by the client
+func (c *Client) SetRetryPolicy(p retry.Policy) {
+	c.retryPolicy = &p
+}
+
+// StageSettings selects the stage to redeploy. RestAPI is the id or the
+// name of the api, with IsFlushCache the stage cache is flushed after the
+// deployment.
+type StageSettings struct {
+	RestAPI      string
+	Stage        string
+	Description  string
+	IsFlushCache bool
+}
+
+func (s StageSettings) Validate() error {
+	if s.RestAPI == "" {
+		return failure.InvalidParam("[RestAPI] rest api id or name is empty")
+	}
+
+	if s.Stage == "" {
+		return failure.InvalidParam("[Stage] stage name is empty")
+	}
+
+	return nil
+}
+
+type StageReport struct {
+	RestAPIID    string `json:"rest_api_id"`
+	Stage        string `json:"stage"`
+	DeploymentID string `json:"deployment_id"`
+	IsFlushed    bool   `json:"is_flushed"`
+}
+
+// DeployStage creates a new deployment of the rest api on the stage
+func (c *Client) DeployStage(ctx context.Context, s StageSettings) (*StageReport, error) {
+	if err := s.Validate(); err != nil {
+		return nil, failure.Wrap(err, "s.Validate failed")
+	}
+
+	id, err := c.RestAPIID(ctx, s.RestAPI)
+	if err != nil {
+		return nil, failure.Wrap(err, "c.RestAPIID failed")
+	}
+
+	in := apigateway.CreateDeploymentInput{
+		RestApiId: aws.String(id),
+		StageName: aws.String(s.Stage),
+	}
+	if s.Description != "" {
+		in.Description = aws.String(s.Description)
+	}
+
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.CreateDeployment, &in)
+	if err != nil {
+		return nil, handleAPIError(err, "c.api.CreateDeployment failed (%s, %s)", id, s.Stage)
+	}
+
+	report := StageReport{
+		RestAPIID:    id,
+		Stage:        s.Stage,
+		DeploymentID: aws.ToString(out.Id),
+	}
+
+	if s.IsFlushCache {
+		if err = c.FlushCache(ctx, id, s.Stage); err != nil {
+			return &report, failure.Wrap(err, "c.FlushCache failed")
+		}
+		report.IsFlushed = true
+	}
+
+	return &report, nil
+}
+
+// FlushCache invalidates every cached response of the stage
+func (c *Client) FlushCache(ctx context.Context, restAPIID, stage string) error {
+	in := apigateway.FlushStageCacheInput{
+		RestApiId: aws.String(restAPIID),
+		StageName: aws.String(stage),
+	}
+
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.FlushStageCache, &in); err != nil {
+		return handleAPIError(err, "c.api.FlushStageCache failed (%s, %s)", restAPIID, stage)
+	}
+
+	return nil
+}
+
+// RestAPIID resolves a rest api name to its id, anything that is already
+// the id of an api is returned as is. Names must be unique in the account.
+func (c *Client) RestAPIID(ctx context.Context, api string) (string, error) {
+	var matches []string
+	in := apigateway.GetRestApisInput{}
+	for {
+		out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetRestApis, &in)
+		if err != nil {
+			return "", handleAPIError(err, "c.api.GetRestApis failed")
+		}
+
+		for _, item := range out.Items {
+			id := aws.ToString(item.Id)
+			if id == api {
+				return id, nil
+			}
+
+			if aws.ToString(item.Name) == api {
+				matches = append(matches, id)
+			}
+		}
+
+		if out.Position == nil || aws.ToString(out.Position) == aws.ToString(in.Position) {
+			break
+		}
+		in.Position = out.Position
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", failure.NotFound("rest api (%s) does not exist", api)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", failure.InvalidParam("(%d) rest apis are named (%s), use the id (%s)", len(matches), api, strings.Join(matches, ", "))
+	}
+}
+
+// handleAPIError reports a missing api or stage as NotFound
+func handleAPIError(err error, msg string, a ...interface{}) error {
+	var notFound *types.NotFoundException
+	if errors.As(err, &notFound) {
+		return failure.ToNotFound(err, msg, a...)
+	}
+
+	return failure.ToSystem(err, msg, a...)
+}
@@ -0,0 +1,272 @@
+// Package retry is the retry with backoff used by every client in sls. A
+// Policy controls how many attempts are made, how long to wait between them
+// and which errors are worth retrying. Clients use Default so the behavior
+// can be tuned in one place with SetDefault. The sdk retryer is turned off
+// with NoSDKRetries, so a Policy is the only layer making attempts.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsRetry "github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	DefaultMaxAttempts = 5
+	DefaultBaseDelay   = 100 * time.Millisecond
+	DefaultMaxDelay    = 5 * time.Second
+	DefaultMaxElapsed  = 30 * time.Second
+	DefaultMultiplier  = 2.0
+	DefaultJitter      = 0.2
+)
+
+var (
+	mu            sync.RWMutex
+	defaultPolicy = NewPolicy()
+)
+
+// Observer is called every time an attempt failed and is about to be retried
+type Observer func(ctx context.Context, attempt int, err error)
+
+// Classifier reports whether err is worth another attempt
+type Classifier func(err error) bool
+
+// Policy is an exponential backoff. The delay for attempt n is
+// BaseDelay * Multiplier^(n-1) capped at MaxDelay, with up to Jitter (0-1)
+// of it randomized. Retries stop at MaxAttempts, when MaxElapsed has passed
+// or when Retryable rejects the error. A zero MaxElapsed has no limit.
+// Observer, when set, is notified of every retry, like the telemetry
+// recorder of an infra.
+type Policy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	MaxElapsed  time.Duration
+	Multiplier  float64
+	Jitter      float64
+	Retryable   Classifier
+	Observer    Observer
+}
+
+// NewPolicy is the default exponential policy retrying throttling and
+// transient errors
+func NewPolicy() Policy {
+	return Policy{
+		MaxAttempts: DefaultMaxAttempts,
+		BaseDelay:   DefaultBaseDelay,
+		MaxDelay:    DefaultMaxDelay,
+		MaxElapsed:  DefaultMaxElapsed,
+		Multiplier:  DefaultMultiplier,
+		Jitter:      DefaultJitter,
+		Retryable:   Any(IsThrottle, IsTransient),
+	}
+}
+
+// NoSDKRetries returns a copy of cfg with the sdk retryer turned off. Every
+// NewClientWithConfig uses it, otherwise each attempt of a Policy would be
+// retried again by the sdk.
+func NoSDKRetries(cfg aws.Config) aws.Config {
+	cfg.Retryer = func() aws.Retryer { return aws.NopRetryer{} }
+	cfg.RetryMaxAttempts = 0
+	return cfg
+}
+
+// Default returns the policy used by the sls clients
+func Default() Policy {
+	mu.RLock()
+	defer mu.RUnlock()
+	return defaultPolicy
+}
+
+// SetDefault replaces the policy used by the sls clients
+func SetDefault(p Policy) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultPolicy = p
+}
+
+func (p Policy) WithMaxAttempts(n int) Policy {
+	p.MaxAttempts = n
+	return p
+}
+
+func (p Policy) WithMaxElapsed(d time.Duration) Policy {
+	p.MaxElapsed = d
+	return p
+}
+
+func (p Policy) WithJitter(j float64) Policy {
+	p.Jitter = j
+	return p
+}
+
+func (p Policy) WithDelay(base, max time.Duration) Policy {
+	p.BaseDelay = base
+	p.MaxDelay = max
+	return p
+}
+
+func (p Policy) WithObserver(o Observer) Policy {
+	p.Observer = o
+	return p
+}
+
+// WithClassifier replaces what is retried with any of the classifiers
+func (p Policy) WithClassifier(c ...Classifier) Policy {
+	p.Retryable = Any(c...)
+	return p
+}
+
+// Or keeps what is already retried and adds the classifiers
+func (p Policy) Or(c ...Classifier) Policy {
+	if p.Retryable != nil {
+		c = append([]Classifier{p.Retryable}, c...)
+	}
+	p.Retryable = Any(c...)
+	return p
+}
+
+// Delay is the wait before the next attempt after attempt failed
+func (p Policy) Delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(p.BaseDelay) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		delay = delay - (delay * jitter * rand.Float64())
+	}
+
+	return time.Duration(delay)
+}
+
+func (p Policy) isRetryable(err error) bool {
+	if p.Retryable == nil {
+		return false
+	}
+	return p.Retryable(err)
+}
+
+// Do calls fn until it succeeds, the policy gives up or ctx is done. The last
+// error from fn is returned unchanged so callers can still classify it.
+func Do(ctx context.Context, p Policy, fn func(ctx context.Context) error) error {
+	_, err := DoValue(ctx, p, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+
+	return err
+}
+
+// DoValue is Do for functions that return a value
+func DoValue[T any](ctx context.Context, p Policy, fn func(ctx context.Context) (T, error)) (T, error) {
+	start := time.Now()
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var result T
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		result, err = fn(ctx)
+		if err == nil || ctx.Err() != nil || !p.isRetryable(err) || attempt == attempts {
+			return result, err
+		}
+
+		delay := p.Delay(attempt)
+		if p.MaxElapsed > 0 && time.Since(start)+delay > p.MaxElapsed {
+			return result, err
+		}
+
+		if p.Observer != nil {
+			p.Observer(ctx, attempt, err)
+		}
+		select {
+		case <-ctx.Done():
+			return result, err
+		case <-time.After(delay):
+		}
+	}
+
+	return result, err
+}
+
+// Call retries a single aws sdk operation, the method value and its input
+// are passed separately so the call site stays a one liner:
+//
+//	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetParameter, &in)
+func Call[In, Out, Opt any](ctx context.Context, p Policy, fn func(context.Context, In, ...Opt) (Out, error), in In, optFns ...Opt) (Out, error) {
+	return DoValue(ctx, p, func(ctx context.Context) (Out, error) {
+		return fn(ctx, in, optFns...)
+	})
+}
+
+// Any is retryable when one of the classifiers is
+func Any(classifiers ...Classifier) Classifier {
+	return func(err error) bool {
+		for _, c := range classifiers {
+			if c != nil && c(err) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ErrorCodes retries aws api errors with one of the codes
+func ErrorCodes(codes ...string) Classifier {
+	return func(err error) bool {
+		var apiErr smithy.APIError
+		if !errors.As(err, &apiErr) {
+			return false
+		}
+
+		for _, code := range codes {
+			if apiErr.ErrorCode() == code {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ThrottleCodes are the aws error codes that mean slow down
+var ThrottleCodes = []string{
+	"Throttling",
+	"ThrottlingException",
+	"ThrottledException",
+	"TooManyRequestsException",
+	"RequestLimitExceeded",
+	"ProvisionedThroughputExceededException",
+	"RequestThrottledException",
+	"TooManyUpdates",
+	"SlowDown",
+}
+
+// IsThrottle retries the aws throttling errors
+func IsThrottle(err error) bool {
+	return ErrorCodes(ThrottleCodes...)(err)
+}
+
+// IsTransient retries what the sdk standard retryer would, like 5xx
+// responses, request timeouts and connection errors
+func IsTransient(err error) bool {
+	return awsRetry.IsErrorRetryables(awsRetry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary
+}
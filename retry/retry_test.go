@@ -0,0 +1,234 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var throttled = &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+
+func TestPolicy_Delay(t *testing.T) {
+	p := retry.NewPolicy().
+		WithJitter(0).
+		WithDelay(100*time.Millisecond, time.Second)
+
+	tests := []struct {
+		attempt int
+		delay   time.Duration
+	}{
+		{attempt: 0, delay: 100 * time.Millisecond},
+		{attempt: 1, delay: 100 * time.Millisecond},
+		{attempt: 2, delay: 200 * time.Millisecond},
+		{attempt: 3, delay: 400 * time.Millisecond},
+		{attempt: 4, delay: 800 * time.Millisecond},
+		{attempt: 5, delay: time.Second},
+		{attempt: 10, delay: time.Second},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.delay, p.Delay(tt.attempt), "attempt (%d)", tt.attempt)
+	}
+
+	p.Multiplier = 0.5
+	assert.Equal(t, 100*time.Millisecond, p.Delay(3), "a multiplier below 1 keeps the base delay")
+}
+
+func TestPolicy_DelayJitter(t *testing.T) {
+	p := retry.NewPolicy().
+		WithJitter(0.5).
+		WithDelay(100*time.Millisecond, time.Second)
+
+	for n := 0; n < 100; n++ {
+		delay := p.Delay(2)
+		assert.GreaterOrEqual(t, delay, 100*time.Millisecond)
+		assert.LessOrEqual(t, delay, 200*time.Millisecond)
+	}
+}
+
+func TestDoValue_MaxAttempts(t *testing.T) {
+	p := retry.NewPolicy().
+		WithMaxAttempts(3).
+		WithDelay(time.Millisecond, time.Millisecond)
+
+	calls := 0
+	_, err := retry.DoValue(context.TODO(), p, func(ctx context.Context) (int, error) {
+		calls++
+		return 0, throttled
+	})
+	require.Error(t, err)
+	assert.Same(t, throttled, err, "the last error is returned unchanged")
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	result, err := retry.DoValue(context.TODO(), p, func(ctx context.Context) (int, error) {
+		calls++
+		if calls < 2 {
+			return 0, throttled
+		}
+		return 42, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 42, result)
+	assert.Equal(t, 2, calls)
+}
+
+func TestDoValue_NotRetryable(t *testing.T) {
+	p := retry.NewPolicy().WithDelay(time.Millisecond, time.Millisecond)
+
+	calls := 0
+	err := retry.Do(context.TODO(), p, func(ctx context.Context) error {
+		calls++
+		return failure.NotFound("param")
+	})
+	require.Error(t, err)
+	assert.True(t, failure.IsNotFound(err))
+	assert.Equal(t, 1, calls)
+}
+
+func TestDoValue_MaxElapsed(t *testing.T) {
+	p := retry.NewPolicy().
+		WithMaxAttempts(10).
+		WithJitter(0).
+		WithDelay(20*time.Millisecond, 20*time.Millisecond).
+		WithMaxElapsed(30 * time.Millisecond)
+
+	calls := 0
+	err := retry.Do(context.TODO(), p, func(ctx context.Context) error {
+		calls++
+		return throttled
+	})
+	require.Error(t, err)
+	assert.Equal(t, 2, calls, "the third attempt would start after MaxElapsed")
+}
+
+func TestDoValue_Canceled(t *testing.T) {
+	p := retry.NewPolicy().
+		WithMaxAttempts(10).
+		WithDelay(time.Hour, time.Hour).
+		WithMaxElapsed(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	start := time.Now()
+	_, err := retry.DoValue(ctx, p, func(ctx context.Context) (string, error) {
+		calls++
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+		}()
+		return "", throttled
+	})
+	require.Error(t, err)
+	assert.Same(t, throttled, err)
+	assert.Equal(t, 1, calls)
+	assert.Less(t, time.Since(start), time.Minute, "the wait is cut short when ctx is done")
+
+	calls = 0
+	err = retry.Do(ctx, p, func(ctx context.Context) error {
+		calls++
+		return throttled
+	})
+	require.Error(t, err)
+	assert.Equal(t, 1, calls, "a done ctx is not retried")
+}
+
+func TestClassifiers(t *testing.T) {
+	unavailable := &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}},
+		Err:      errors.New("service unavailable"),
+	}
+	badRequest := &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusBadRequest}},
+		Err:      errors.New("bad request"),
+	}
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	conflict := &smithy.GenericAPIError{Code: "ResourceConflictException", Message: "update in progress"}
+
+	tests := []struct {
+		name       string
+		err        error
+		throttle   bool
+		transient  bool
+		isDefault  bool
+		isConflict bool
+	}{
+		{name: "nil", err: nil},
+		{name: "throttle", err: throttled, throttle: true, transient: true, isDefault: true},
+		{name: "wrapped throttle", err: failure.Wrap(throttled, "c.api.GetParameter failed"), throttle: true, transient: true, isDefault: true},
+		{name: "too many updates", err: &smithy.GenericAPIError{Code: "TooManyUpdates"}, throttle: true, isDefault: true},
+		{name: "5xx", err: unavailable, transient: true, isDefault: true},
+		{name: "4xx", err: badRequest},
+		{name: "dial", err: dial, transient: true, isDefault: true},
+		{name: "conflict", err: conflict, isConflict: true},
+		{name: "other", err: errors.New("boom")},
+	}
+
+	isConflict := retry.ErrorCodes("ResourceConflictException")
+	p := retry.NewPolicy().
+		WithMaxAttempts(2).
+		WithDelay(time.Millisecond, time.Millisecond)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.throttle, retry.IsThrottle(tt.err), "IsThrottle")
+			assert.Equal(t, tt.transient, retry.IsTransient(tt.err), "IsTransient")
+			assert.Equal(t, tt.isConflict, isConflict(tt.err), "ErrorCodes")
+			assert.Equal(t, tt.throttle || tt.isConflict, retry.Any(retry.IsThrottle, isConflict)(tt.err), "Any")
+
+			for _, c := range []struct {
+				policy retry.Policy
+				want   bool
+			}{
+				{policy: p, want: tt.isDefault},
+				{policy: p.Or(isConflict), want: tt.isDefault || tt.isConflict},
+				{policy: p.WithClassifier(isConflict), want: tt.isConflict},
+			} {
+				calls := 0
+				_ = retry.Do(context.TODO(), c.policy, func(ctx context.Context) error {
+					calls++
+					return tt.err
+				})
+
+				want := 1
+				if c.want {
+					want = 2
+				}
+				assert.Equal(t, want, calls)
+			}
+		})
+	}
+}
+
+func TestDoValue_Observer(t *testing.T) {
+	var observed []int
+	p := retry.NewPolicy().
+		WithMaxAttempts(3).
+		WithDelay(time.Millisecond, time.Millisecond).
+		WithObserver(func(_ context.Context, attempt int, err error) {
+			assert.Same(t, throttled, err)
+			observed = append(observed, attempt)
+		})
+
+	err := retry.Do(context.TODO(), p.Or(retry.ErrorCodes("ResourceConflictException")), func(ctx context.Context) error {
+		return throttled
+	})
+	require.Error(t, err)
+	assert.Equal(t, []int{1, 2}, observed, "the observer is kept by the builders and not told of the last attempt")
+
+	observed = nil
+	_ = retry.Do(context.TODO(), p.WithObserver(nil), func(ctx context.Context) error {
+		return throttled
+	})
+	assert.Empty(t, observed, "each policy has its own observer")
+}
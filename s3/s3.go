@@ -0,0 +1,159 @@
+// Package s3 implements an s3 client used by the infra commands to upload
+// the code of features that are too large to be sent to lambda directly
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+type AdapterAPI interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+}
+
+type Client struct {
+	api         AdapterAPI
+	retryPolicy *retry.Policy
+}
+
+func NewClientWithConfig(cfg aws.Config) *Client {
+	api := s3.NewFromConfig(retry.NoSDKRetries(cfg))
+	return NewClient(api)
+}
+
+func NewClient(api AdapterAPI) *Client {
+	return &Client{api: api}
+}
+
+// RetryPolicy is the policy the client retries its calls with
+func (c *Client) RetryPolicy() retry.Policy {
+	if c.retryPolicy == nil {
+		return retry.Default()
+	}
+	return *c.retryPolicy
+}
+
+// SetRetryPolicy replaces retry.Default for the calls made by the client
+func (c *Client) SetRetryPolicy(p retry.Policy) {
+	c.retryPolicy = &p
+}
+
+// UploadSettings is one object to upload, ContentType defaults to
+// application/zip
+type UploadSettings struct {
+	Bucket      string
+	Key         string
+	Body        []byte
+	ContentType string
+}
+
+func (s UploadSettings) Validate() error {
+	if s.Bucket == "" {
+		return failure.InvalidParam("[Bucket] bucket name is empty")
+	}
+
+	if s.Key == "" {
+		return failure.InvalidParam("[Key] object key is empty")
+	}
+
+	if len(s.Body) == 0 {
+		return failure.InvalidParam("[Body] object (%s) is empty", s.Key)
+	}
+
+	return nil
+}
+
+// UploadReport is the uploaded object, VersionID is empty when the bucket is
+// not versioned
+type UploadReport struct {
+	Bucket    string `json:"bucket"`
+	Key       string `json:"key"`
+	VersionID string `json:"version_id,omitempty"`
+	ETag      string `json:"etag"`
+	Size      int64  `json:"size"`
+}
+
+// Upload puts the object in the bucket, replacing the one with the same key
+func (c *Client) Upload(ctx context.Context, s UploadSettings) (UploadReport, error) {
+	var report UploadReport
+	if err := s.Validate(); err != nil {
+		return report, failure.Wrap(err, "s.Validate failed")
+	}
+
+	contentType := s.ContentType
+	if contentType == "" {
+		contentType = "application/zip"
+	}
+
+	// the body is read again on every attempt, so each one gets a new reader
+	call := func(ctx context.Context, in *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		in.Body = bytes.NewReader(s.Body)
+		return c.api.PutObject(ctx, in, optFns...)
+	}
+
+	in := s3.PutObjectInput{
+		Bucket:        aws.String(s.Bucket),
+		Key:           aws.String(s.Key),
+		ContentType:   aws.String(contentType),
+		ContentLength: int64(len(s.Body)),
+	}
+
+	out, err := retry.Call(ctx, c.RetryPolicy(), call, &in)
+	if err != nil {
+		return report, handleAPIError(err, "c.api.PutObject failed (%s, %s)", s.Bucket, s.Key)
+	}
+
+	report = UploadReport{
+		Bucket:    s.Bucket,
+		Key:       s.Key,
+		VersionID: aws.ToString(out.VersionId),
+		ETag:      aws.ToString(out.ETag),
+		Size:      int64(len(s.Body)),
+	}
+
+	return report, nil
+}
+
+// Exists reports whether the object is in the bucket
+func (c *Client) Exists(ctx context.Context, bucket, key string) (bool, error) {
+	in := s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.HeadObject, &in); err != nil {
+		err = handleAPIError(err, "c.api.HeadObject failed (%s, %s)", bucket, key)
+		if failure.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ContentKey is the key of body under prefix named after its sha256, the
+// same code always lands on the same key
+func ContentKey(prefix string, body []byte, ext string) string {
+	sum := sha256.Sum256(body)
+	return prefix + "/" + hex.EncodeToString(sum[:]) + ext
+}
+
+// handleAPIError reports a missing bucket or object as NotFound
+func handleAPIError(err error, msg string, a ...interface{}) error {
+	var noBucket *types.NoSuchBucket
+	var noKey *types.NoSuchKey
+	var notFound *types.NotFound
+	if errors.As(err, &noBucket) || errors.As(err, &noKey) || errors.As(err, &notFound) {
+		return failure.ToNotFound(err, msg, a...)
+	}
+
+	return failure.ToSystem(err, msg, a...)
+}
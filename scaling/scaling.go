@@ -0,0 +1,251 @@
+// Package scaling implements an application auto scaling client used to
+// schedule provisioned concurrency for microservice features
+package scaling
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	DefaultTimezone = "UTC"
+)
+
+type AdapterAPI interface {
+	RegisterScalableTarget(ctx context.Context, params *applicationautoscaling.RegisterScalableTargetInput, optFns ...func(*applicationautoscaling.Options)) (*applicationautoscaling.RegisterScalableTargetOutput, error)
+	PutScheduledAction(ctx context.Context, params *applicationautoscaling.PutScheduledActionInput, optFns ...func(*applicationautoscaling.Options)) (*applicationautoscaling.PutScheduledActionOutput, error)
+	DeleteScheduledAction(ctx context.Context, params *applicationautoscaling.DeleteScheduledActionInput, optFns ...func(*applicationautoscaling.Options)) (*applicationautoscaling.DeleteScheduledActionOutput, error)
+	DescribeScheduledActions(ctx context.Context, params *applicationautoscaling.DescribeScheduledActionsInput, optFns ...func(*applicationautoscaling.Options)) (*applicationautoscaling.DescribeScheduledActionsOutput, error)
+}
+
+// ScalableTarget is the provisioned concurrency of a lambda alias
+type ScalableTarget struct {
+	FunctionName string
+	Alias        string
+	Min          int32
+	Max          int32
+}
+
+// ResourceID is the application auto scaling resource id for the alias
+func (t ScalableTarget) ResourceID() string {
+	return fmt.Sprintf("function:%s:%s", t.FunctionName, t.Alias)
+}
+
+// ScheduledAction sets the provisioned concurrency of a target to Min/Max
+// whenever Schedule fires. Schedule uses the auto scaling expression format
+// like `cron(0 8 ? * MON-FRI *)`
+type ScheduledAction struct {
+	Name     string
+	Target   ScalableTarget
+	Schedule string
+	Timezone string
+	Min      int32
+	Max      int32
+}
+
+func (a ScheduledAction) Validate() error {
+	if a.Name == "" {
+		return failure.InvalidParam("[Name] scheduled action name is empty")
+	}
+
+	if a.Target.FunctionName == "" || a.Target.Alias == "" {
+		return failure.InvalidParam("[Target] function name and alias are required (%s)", a.Name)
+	}
+
+	if !IsScheduleExpr(a.Schedule) {
+		return failure.InvalidParam("[Schedule] (%s) must be in the form of cron(...), rate(...) or at(...)", a.Schedule)
+	}
+
+	if a.Min < 0 || a.Max < a.Min {
+		return failure.InvalidParam("[Min, Max] (%d, %d) min must be >= 0 and <= max", a.Min, a.Max)
+	}
+
+	return nil
+}
+
+type ScheduleReport struct {
+	Name      string
+	ARN       string
+	Resource  string
+	Schedule  string
+	Timezone  string
+	Min       int32
+	Max       int32
+	CreatedAt string
+}
+
+type Client struct {
+	api         AdapterAPI
+	retryPolicy *retry.Policy
+}
+
+func NewClientWithConfig(cfg aws.Config) *Client {
+	api := applicationautoscaling.NewFromConfig(retry.NoSDKRetries(cfg))
+	return NewClient(api)
+}
+
+func NewClient(api AdapterAPI) *Client {
+	return &Client{api: api}
+}
+
+// RetryPolicy is the policy the client retries its calls with
+func (c *Client) RetryPolicy() retry.Policy {
+	if c.retryPolicy == nil {
+		return retry.Default()
+	}
+	return *c.retryPolicy
+}
+
+// SetRetryPolicy replaces retry.Default for the calls made by the client
+func (c *Client) SetRetryPolicy(p retry.Policy) {
+	c.retryPolicy = &p
+}
+
+// RegisterTarget registers the alias's provisioned concurrency with
+// application auto scaling, which is required before any schedule can be
+// attached to it.
+func (c *Client) RegisterTarget(ctx context.Context, t ScalableTarget) error {
+	in := applicationautoscaling.RegisterScalableTargetInput{
+		ResourceId:        aws.String(t.ResourceID()),
+		ScalableDimension: types.ScalableDimensionLambdaFunctionProvisionedConcurrency,
+		ServiceNamespace:  types.ServiceNamespaceLambda,
+		MinCapacity:       aws.Int32(t.Min),
+		MaxCapacity:       aws.Int32(t.Max),
+	}
+
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.RegisterScalableTarget, &in); err != nil {
+		return failure.ToSystem(err, "c.api.RegisterScalableTarget failed (%s)", t.ResourceID())
+	}
+
+	return nil
+}
+
+// PutSchedule creates or replaces the scheduled action
+func (c *Client) PutSchedule(ctx context.Context, a ScheduledAction) error {
+	if err := a.Validate(); err != nil {
+		return failure.Wrap(err, "a.Validate failed")
+	}
+
+	tz := a.Timezone
+	if tz == "" {
+		tz = DefaultTimezone
+	}
+
+	in := applicationautoscaling.PutScheduledActionInput{
+		ResourceId:          aws.String(a.Target.ResourceID()),
+		ScalableDimension:   types.ScalableDimensionLambdaFunctionProvisionedConcurrency,
+		ServiceNamespace:    types.ServiceNamespaceLambda,
+		ScheduledActionName: aws.String(a.Name),
+		Schedule:            aws.String(a.Schedule),
+		Timezone:            aws.String(tz),
+		ScalableTargetAction: &types.ScalableTargetAction{
+			MinCapacity: aws.Int32(a.Min),
+			MaxCapacity: aws.Int32(a.Max),
+		},
+	}
+
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.PutScheduledAction, &in); err != nil {
+		return failure.ToSystem(err, "c.api.PutScheduledAction failed (%s)", a.Name)
+	}
+
+	return nil
+}
+
+func (c *Client) DeleteSchedule(ctx context.Context, t ScalableTarget, name string) error {
+	in := applicationautoscaling.DeleteScheduledActionInput{
+		ResourceId:          aws.String(t.ResourceID()),
+		ScalableDimension:   types.ScalableDimensionLambdaFunctionProvisionedConcurrency,
+		ServiceNamespace:    types.ServiceNamespaceLambda,
+		ScheduledActionName: aws.String(name),
+	}
+
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.DeleteScheduledAction, &in); err != nil {
+		return failure.ToSystem(err, "c.api.DeleteScheduledAction failed (%s)", name)
+	}
+
+	return nil
+}
+
+// Schedules lists the scheduled actions attached to the target
+func (c *Client) Schedules(ctx context.Context, t ScalableTarget) ([]ScheduleReport, error) {
+	in := applicationautoscaling.DescribeScheduledActionsInput{
+		ResourceId:        aws.String(t.ResourceID()),
+		ScalableDimension: types.ScalableDimensionLambdaFunctionProvisionedConcurrency,
+		ServiceNamespace:  types.ServiceNamespaceLambda,
+	}
+
+	var result []ScheduleReport
+	pager := applicationautoscaling.NewDescribeScheduledActionsPaginator(c.api, &in)
+	for pager.HasMorePages() {
+		out, err := retry.DoValue(ctx, c.RetryPolicy(), func(ctx context.Context) (*applicationautoscaling.DescribeScheduledActionsOutput, error) {
+			return pager.NextPage(ctx)
+		})
+		if err != nil {
+			return result, failure.ToSystem(err, "pager.NextPage failed (%s)", t.ResourceID())
+		}
+
+		for _, a := range out.ScheduledActions {
+			result = append(result, ToScheduleReport(a))
+		}
+	}
+
+	return result, nil
+}
+
+func ToScheduleReport(a types.ScheduledAction) ScheduleReport {
+	r := ScheduleReport{}
+	if a.ScheduledActionName != nil {
+		r.Name = *a.ScheduledActionName
+	}
+
+	if a.ScheduledActionARN != nil {
+		r.ARN = *a.ScheduledActionARN
+	}
+
+	if a.ResourceId != nil {
+		r.Resource = *a.ResourceId
+	}
+
+	if a.Schedule != nil {
+		r.Schedule = *a.Schedule
+	}
+
+	if a.Timezone != nil {
+		r.Timezone = *a.Timezone
+	}
+
+	if a.ScalableTargetAction != nil {
+		if a.ScalableTargetAction.MinCapacity != nil {
+			r.Min = *a.ScalableTargetAction.MinCapacity
+		}
+		if a.ScalableTargetAction.MaxCapacity != nil {
+			r.Max = *a.ScalableTargetAction.MaxCapacity
+		}
+	}
+
+	if a.CreationTime != nil {
+		r.CreatedAt = a.CreationTime.String()
+	}
+
+	return r
+}
+
+// IsScheduleExpr does a shallow check that the expression uses one of the
+// formats application auto scaling understands.
+func IsScheduleExpr(expr string) bool {
+	for _, p := range []string{"cron(", "rate(", "at("} {
+		if strings.HasPrefix(expr, p) && strings.HasSuffix(expr, ")") {
+			return true
+		}
+	}
+
+	return false
+}
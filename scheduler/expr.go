@@ -0,0 +1,172 @@
+package scheduler
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rsb/failure"
+)
+
+// AtLayout is the time format of one time `at(...)` schedules
+const AtLayout = "2006-01-02T15:04:05"
+
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+	// special are the characters the field allows besides , - * /
+	special string
+}
+
+var cronFields = []cronField{
+	{name: "minutes", min: 0, max: 59},
+	{name: "hours", min: 0, max: 23},
+	{name: "day-of-month", min: 1, max: 31, special: "?LW"},
+	{name: "month", min: 1, max: 12, names: []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}},
+	{name: "day-of-week", min: 1, max: 7, names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}, special: "?L#"},
+	{name: "year", min: 1970, max: 2199},
+}
+
+// ValidateExpression checks a schedule expression the way eventbridge
+// scheduler reads it, one of
+//
+//	cron(0 8 ? * MON-FRI *)
+//	rate(5 minutes)
+//	at(2024-01-31T18:00:00)
+func ValidateExpression(expr string) error {
+	switch {
+	case strings.HasPrefix(expr, "cron(") && strings.HasSuffix(expr, ")"):
+		return ValidateCron(strings.TrimSuffix(strings.TrimPrefix(expr, "cron("), ")"))
+	case strings.HasPrefix(expr, "rate(") && strings.HasSuffix(expr, ")"):
+		return validateRate(strings.TrimSuffix(strings.TrimPrefix(expr, "rate("), ")"))
+	case strings.HasPrefix(expr, "at(") && strings.HasSuffix(expr, ")"):
+		at := strings.TrimSuffix(strings.TrimPrefix(expr, "at("), ")")
+		if _, err := time.Parse(AtLayout, at); err != nil {
+			return failure.InvalidParam("at(%s) must be a time like at(2024-01-31T18:00:00)", at)
+		}
+		return nil
+	default:
+		return failure.InvalidParam("schedule (%s) must be in the form of cron(...), rate(...) or at(...)", expr)
+	}
+}
+
+// ValidateCron checks the six fields of a cron expression, minutes hours
+// day-of-month month day-of-week year. One of day-of-month and day-of-week
+// must be ?, the scheduler does not allow both.
+func ValidateCron(cron string) error {
+	fields := strings.Fields(cron)
+	if len(fields) != len(cronFields) {
+		return failure.InvalidParam("cron(%s) has (%d) fields, it needs 6: minutes hours day-of-month month day-of-week year", cron, len(fields))
+	}
+
+	for idx, f := range cronFields {
+		if err := f.validate(fields[idx]); err != nil {
+			return failure.Wrap(err, "cron(%s) is not valid", cron)
+		}
+	}
+
+	if (fields[2] == "?") == (fields[4] == "?") {
+		return failure.InvalidParam("cron(%s) needs ? in exactly one of day-of-month and day-of-week", cron)
+	}
+
+	return nil
+}
+
+func (f cronField) validate(value string) error {
+	if value == "?" {
+		if !strings.Contains(f.special, "?") {
+			return failure.InvalidParam("%s (%s) can not be ?", f.name, value)
+		}
+		return nil
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		if err := f.validateItem(item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f cronField) validateItem(item string) error {
+	switch {
+	case item == "":
+		return failure.InvalidParam("%s has an empty value", f.name)
+	case item == "L" && strings.Contains(f.special, "L"):
+		return nil
+	case item == "LW" && strings.Contains(f.special, "W"):
+		return nil
+	case strings.HasSuffix(item, "W") && strings.Contains(f.special, "W"):
+		return f.validateValue(strings.TrimSuffix(item, "W"))
+	case strings.HasSuffix(item, "L") && strings.Contains(f.special, "L"):
+		return f.validateValue(strings.TrimSuffix(item, "L"))
+	case strings.Contains(item, "#") && strings.Contains(f.special, "#"):
+		day, nth, _ := strings.Cut(item, "#")
+		if n, err := strconv.Atoi(nth); err != nil || n < 1 || n > 5 {
+			return failure.InvalidParam("%s (%s) must be a day and a week from 1 to 5 like 2#1", f.name, item)
+		}
+		return f.validateValue(day)
+	}
+
+	rng, step, hasStep := strings.Cut(item, "/")
+	if hasStep {
+		if n, err := strconv.Atoi(step); err != nil || n < 1 {
+			return failure.InvalidParam("%s (%s) has an increment (%s) that is not a positive number", f.name, item, step)
+		}
+	}
+
+	if rng == "*" {
+		return nil
+	}
+
+	from, to, isRange := strings.Cut(rng, "-")
+	if err := f.validateValue(from); err != nil {
+		return err
+	}
+
+	if isRange {
+		if err := f.validateValue(to); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f cronField) validateValue(v string) error {
+	for _, name := range f.names {
+		if strings.EqualFold(v, name) {
+			return nil
+		}
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < f.min || n > f.max {
+		return failure.InvalidParam("%s (%s) must be from %d to %d", f.name, v, f.min, f.max)
+	}
+
+	return nil
+}
+
+func validateRate(rate string) error {
+	value, unit, ok := strings.Cut(strings.TrimSpace(rate), " ")
+	n, err := strconv.Atoi(value)
+	if !ok || err != nil || n < 1 {
+		return failure.InvalidParam("rate(%s) must be a positive number and a unit like rate(5 minutes)", rate)
+	}
+
+	singular := strings.TrimSuffix(unit, "s")
+	switch singular {
+	case "minute", "hour", "day":
+	default:
+		return failure.InvalidParam("rate(%s) unit (%s) must be minutes, hours or days", rate, unit)
+	}
+
+	if (n == 1) != (unit == singular) {
+		return failure.InvalidParam("rate(%s) must use (%s) for a value of 1 and (%ss) otherwise", rate, singular, singular)
+	}
+
+	return nil
+}
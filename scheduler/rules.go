@@ -0,0 +1,294 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsEvents "github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventTypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	DefaultBus = "default"
+	// RuleTargetID is the id of the lambda target PutRule adds to a rule
+	RuleTargetID = "lambda"
+)
+
+type RuleAPI interface {
+	PutRule(ctx context.Context, params *awsEvents.PutRuleInput, optFns ...func(*awsEvents.Options)) (*awsEvents.PutRuleOutput, error)
+	PutTargets(ctx context.Context, params *awsEvents.PutTargetsInput, optFns ...func(*awsEvents.Options)) (*awsEvents.PutTargetsOutput, error)
+	DescribeRule(ctx context.Context, params *awsEvents.DescribeRuleInput, optFns ...func(*awsEvents.Options)) (*awsEvents.DescribeRuleOutput, error)
+	ListRules(ctx context.Context, params *awsEvents.ListRulesInput, optFns ...func(*awsEvents.Options)) (*awsEvents.ListRulesOutput, error)
+	ListTargetsByRule(ctx context.Context, params *awsEvents.ListTargetsByRuleInput, optFns ...func(*awsEvents.Options)) (*awsEvents.ListTargetsByRuleOutput, error)
+	RemoveTargets(ctx context.Context, params *awsEvents.RemoveTargetsInput, optFns ...func(*awsEvents.Options)) (*awsEvents.RemoveTargetsOutput, error)
+	DeleteRule(ctx context.Context, params *awsEvents.DeleteRuleInput, optFns ...func(*awsEvents.Options)) (*awsEvents.DeleteRuleOutput, error)
+}
+
+// RuleSettings is a scheduled rule of an event bus invoking a function. An
+// empty Bus is the DefaultBus. Rules run in UTC and do not accept at(...)
+// expressions, the function needs a permission for events.amazonaws.com
+// instead of a role.
+type RuleSettings struct {
+	Name        string
+	Bus         string
+	Expression  string
+	Description string
+	FunctionARN string
+	Input       string
+	IsDisabled  bool
+}
+
+func (s RuleSettings) Validate() error {
+	if err := ValidateName(s.Name); err != nil {
+		return failure.Wrap(err, "[Name] is not valid")
+	}
+
+	if strings.HasPrefix(s.Expression, "at(") {
+		return failure.InvalidParam("[Expression] (%s) is not valid, rules only accept cron(...) or rate(...)", s.Expression)
+	}
+
+	if err := ValidateExpression(s.Expression); err != nil {
+		return failure.Wrap(err, "[Expression] is not valid")
+	}
+
+	if !strings.HasPrefix(s.FunctionARN, "arn:") {
+		return failure.InvalidParam("[FunctionARN] (%s) is not an arn", s.FunctionARN)
+	}
+
+	return nil
+}
+
+func (s RuleSettings) input() *awsEvents.PutRuleInput {
+	state := eventTypes.RuleStateEnabled
+	if s.IsDisabled {
+		state = eventTypes.RuleStateDisabled
+	}
+
+	in := awsEvents.PutRuleInput{
+		Name:               aws.String(s.Name),
+		EventBusName:       aws.String(busName(s.Bus)),
+		ScheduleExpression: aws.String(s.Expression),
+		State:              state,
+	}
+	if s.Description != "" {
+		in.Description = aws.String(s.Description)
+	}
+
+	return &in
+}
+
+func (s RuleSettings) target() eventTypes.Target {
+	target := eventTypes.Target{Id: aws.String(RuleTargetID), Arn: aws.String(s.FunctionARN)}
+	if s.Input != "" {
+		target.Input = aws.String(s.Input)
+	}
+
+	return target
+}
+
+// PutRule creates the scheduled rule, or replaces its settings when it
+// exists, and points its lambda target at the function. Other targets of the
+// rule are left as they are.
+func (c *Client) PutRule(ctx context.Context, s RuleSettings) (*ScheduleReport, error) {
+	if err := s.Validate(); err != nil {
+		return nil, failure.Wrap(err, "s.Validate failed")
+	}
+
+	in := s.input()
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.rules.PutRule, in); err != nil {
+		return nil, failure.ToSystem(err, "c.rules.PutRule failed (%s)", s.Name)
+	}
+
+	targets := awsEvents.PutTargetsInput{
+		Rule:         in.Name,
+		EventBusName: in.EventBusName,
+		Targets:      []eventTypes.Target{s.target()},
+	}
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.rules.PutTargets, &targets)
+	if err != nil {
+		return nil, failure.ToSystem(err, "c.rules.PutTargets failed (%s)", s.Name)
+	}
+
+	if out.FailedEntryCount > 0 && len(out.FailedEntries) > 0 {
+		failed := out.FailedEntries[0]
+		return nil, failure.System("target (%s) of rule (%s) was not put: %s", aws.ToString(failed.TargetId), s.Name, aws.ToString(failed.ErrorMessage))
+	}
+
+	report, err := c.Rule(ctx, aws.ToString(in.EventBusName), s.Name)
+	if err != nil {
+		return nil, failure.Wrap(err, "c.Rule failed")
+	}
+
+	return report, nil
+}
+
+// Rule reads one rule of the event bus with its lambda target, or its first
+// target when none of them is the RuleTargetID
+func (c *Client) Rule(ctx context.Context, bus, name string) (*ScheduleReport, error) {
+	bus = busName(bus)
+	in := awsEvents.DescribeRuleInput{Name: aws.String(name), EventBusName: aws.String(bus)}
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.rules.DescribeRule, &in)
+	if err != nil {
+		if isRuleNotFound(err) {
+			return nil, failure.ToNotFound(err, "rule (%s) is not in event bus (%s)", name, bus)
+		}
+		return nil, failure.ToSystem(err, "c.rules.DescribeRule failed (%s)", name)
+	}
+
+	targets, err := c.ruleTargets(ctx, bus, name)
+	if err != nil {
+		return nil, failure.Wrap(err, "c.ruleTargets failed")
+	}
+
+	r := ScheduleReport{
+		Kind:        RuleKind,
+		Name:        aws.ToString(out.Name),
+		Group:       aws.ToString(out.EventBusName),
+		ARN:         aws.ToString(out.Arn),
+		Expression:  aws.ToString(out.ScheduleExpression),
+		Timezone:    DefaultTimezone,
+		State:       string(out.State),
+		Description: aws.ToString(out.Description),
+	}
+
+	for idx, t := range targets {
+		if idx == 0 || aws.ToString(t.Id) == RuleTargetID {
+			r.Target = aws.ToString(t.Arn)
+			r.Input = aws.ToString(t.Input)
+		}
+	}
+
+	return &r, nil
+}
+
+// FunctionRules are the scheduled rules of the event bus with a target that
+// is the function, or one of its versions or aliases, sorted by name. Rules
+// matching an event pattern are not scheduled and are skipped.
+func (c *Client) FunctionRules(ctx context.Context, bus, functionName string) ([]ScheduleReport, error) {
+	bus = busName(bus)
+	in := awsEvents.ListRulesInput{EventBusName: aws.String(bus)}
+	result := []ScheduleReport{}
+	for {
+		out, err := retry.Call(ctx, c.RetryPolicy(), c.rules.ListRules, &in)
+		if err != nil {
+			return nil, failure.ToSystem(err, "c.rules.ListRules failed (%s)", bus)
+		}
+
+		for _, rule := range out.Rules {
+			if aws.ToString(rule.ScheduleExpression) == "" {
+				continue
+			}
+
+			name := aws.ToString(rule.Name)
+			targets, err := c.ruleTargets(ctx, bus, name)
+			if err != nil {
+				return nil, failure.Wrap(err, "c.ruleTargets failed")
+			}
+
+			for _, t := range targets {
+				if !IsFunctionTarget(aws.ToString(t.Arn), functionName) {
+					continue
+				}
+
+				result = append(result, ScheduleReport{
+					Kind:        RuleKind,
+					Name:        name,
+					Group:       bus,
+					ARN:         aws.ToString(rule.Arn),
+					Expression:  aws.ToString(rule.ScheduleExpression),
+					Timezone:    DefaultTimezone,
+					State:       string(rule.State),
+					Target:      aws.ToString(t.Arn),
+					Input:       aws.ToString(t.Input),
+					Description: aws.ToString(rule.Description),
+				})
+				break
+			}
+		}
+
+		if aws.ToString(out.NextToken) == "" {
+			break
+		}
+		in.NextToken = out.NextToken
+	}
+
+	sort.Slice(result, func(a, b int) bool {
+		return result[a].Name < result[b].Name
+	})
+
+	return result, nil
+}
+
+// DeleteRule removes every target of the rule and then the rule, eventbridge
+// does not delete a rule that still has targets
+func (c *Client) DeleteRule(ctx context.Context, bus, name string) error {
+	bus = busName(bus)
+	targets, err := c.ruleTargets(ctx, bus, name)
+	if err != nil {
+		return failure.Wrap(err, "c.ruleTargets failed")
+	}
+
+	if len(targets) > 0 {
+		remove := awsEvents.RemoveTargetsInput{Rule: aws.String(name), EventBusName: aws.String(bus)}
+		for _, t := range targets {
+			remove.Ids = append(remove.Ids, aws.ToString(t.Id))
+		}
+
+		out, err := retry.Call(ctx, c.RetryPolicy(), c.rules.RemoveTargets, &remove)
+		if err != nil {
+			return failure.ToSystem(err, "c.rules.RemoveTargets failed (%s)", name)
+		}
+
+		if out.FailedEntryCount > 0 && len(out.FailedEntries) > 0 {
+			failed := out.FailedEntries[0]
+			return failure.System("target (%s) of rule (%s) was not removed: %s", aws.ToString(failed.TargetId), name, aws.ToString(failed.ErrorMessage))
+		}
+	}
+
+	in := awsEvents.DeleteRuleInput{Name: aws.String(name), EventBusName: aws.String(bus)}
+	if _, err = retry.Call(ctx, c.RetryPolicy(), c.rules.DeleteRule, &in); err != nil {
+		return failure.ToSystem(err, "c.rules.DeleteRule failed (%s)", name)
+	}
+
+	return nil
+}
+
+func (c *Client) ruleTargets(ctx context.Context, bus, name string) ([]eventTypes.Target, error) {
+	in := awsEvents.ListTargetsByRuleInput{Rule: aws.String(name), EventBusName: aws.String(bus)}
+	var result []eventTypes.Target
+	for {
+		out, err := retry.Call(ctx, c.RetryPolicy(), c.rules.ListTargetsByRule, &in)
+		if err != nil {
+			if isRuleNotFound(err) {
+				return nil, failure.ToNotFound(err, "rule (%s) is not in event bus (%s)", name, bus)
+			}
+			return nil, failure.ToSystem(err, "c.rules.ListTargetsByRule failed (%s)", name)
+		}
+		result = append(result, out.Targets...)
+
+		if aws.ToString(out.NextToken) == "" {
+			break
+		}
+		in.NextToken = out.NextToken
+	}
+
+	return result, nil
+}
+
+func busName(bus string) string {
+	if bus == "" {
+		return DefaultBus
+	}
+	return bus
+}
+
+func isRuleNotFound(err error) bool {
+	var notFound *eventTypes.ResourceNotFoundException
+	return errors.As(err, &notFound)
+}
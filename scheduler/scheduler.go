@@ -0,0 +1,337 @@
+// Package scheduler implements a client of eventbridge scheduler and of the
+// scheduled eventbridge rules used to manage the schedules that invoke
+// microservice features
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsEvents "github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	awsScheduler "github.com/aws/aws-sdk-go-v2/service/scheduler"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler/types"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	DefaultGroup    = "default"
+	DefaultTimezone = "UTC"
+	EnabledState    = "ENABLED"
+	DisabledState   = "DISABLED"
+	// MaxNameLength is the longest name eventbridge scheduler, and the
+	// eventbridge rules, accept
+	MaxNameLength = 64
+
+	// ScheduleKind reports come from eventbridge scheduler, RuleKind reports
+	// from a scheduled rule of an event bus
+	ScheduleKind = "schedule"
+	RuleKind     = "rule"
+)
+
+type AdapterAPI interface {
+	CreateSchedule(ctx context.Context, params *awsScheduler.CreateScheduleInput, optFns ...func(*awsScheduler.Options)) (*awsScheduler.CreateScheduleOutput, error)
+	UpdateSchedule(ctx context.Context, params *awsScheduler.UpdateScheduleInput, optFns ...func(*awsScheduler.Options)) (*awsScheduler.UpdateScheduleOutput, error)
+	GetSchedule(ctx context.Context, params *awsScheduler.GetScheduleInput, optFns ...func(*awsScheduler.Options)) (*awsScheduler.GetScheduleOutput, error)
+	ListSchedules(ctx context.Context, params *awsScheduler.ListSchedulesInput, optFns ...func(*awsScheduler.Options)) (*awsScheduler.ListSchedulesOutput, error)
+	DeleteSchedule(ctx context.Context, params *awsScheduler.DeleteScheduleInput, optFns ...func(*awsScheduler.Options)) (*awsScheduler.DeleteScheduleOutput, error)
+}
+
+// ScheduleSettings is a schedule invoking a function. An empty Group is the
+// DefaultGroup and an empty Timezone is the DefaultTimezone.
+type ScheduleSettings struct {
+	Name        string
+	Group       string
+	Expression  string
+	Timezone    string
+	Description string
+	FunctionARN string
+	RoleARN     string
+	Input       string
+	IsDisabled  bool
+}
+
+func (s ScheduleSettings) Validate() error {
+	if err := ValidateName(s.Name); err != nil {
+		return failure.Wrap(err, "[Name] is not valid")
+	}
+
+	if err := ValidateExpression(s.Expression); err != nil {
+		return failure.Wrap(err, "[Expression] is not valid")
+	}
+
+	if s.Timezone != "" {
+		if _, err := time.LoadLocation(s.Timezone); err != nil {
+			return failure.InvalidParam("[Timezone] (%s) is not a timezone like America/New_York", s.Timezone)
+		}
+	}
+
+	if !strings.HasPrefix(s.FunctionARN, "arn:") {
+		return failure.InvalidParam("[FunctionARN] (%s) is not an arn", s.FunctionARN)
+	}
+
+	if !strings.HasPrefix(s.RoleARN, "arn:") {
+		return failure.InvalidParam("[RoleARN] (%s) is not an arn, the scheduler needs a role to invoke the function", s.RoleARN)
+	}
+
+	return nil
+}
+
+// ValidateName checks the name against the characters and length the
+// scheduler accepts
+func ValidateName(name string) error {
+	if name == "" || len(name) > MaxNameLength {
+		return failure.InvalidParam("schedule name (%s) must have 1 to %d characters", name, MaxNameLength)
+	}
+
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return failure.InvalidParam("schedule name (%s) can only have letters, digits and - _ .", name)
+		}
+	}
+
+	return nil
+}
+
+func (s ScheduleSettings) input() *awsScheduler.CreateScheduleInput {
+	state := types.ScheduleStateEnabled
+	if s.IsDisabled {
+		state = types.ScheduleStateDisabled
+	}
+
+	timezone := s.Timezone
+	if timezone == "" {
+		timezone = DefaultTimezone
+	}
+
+	target := types.Target{Arn: aws.String(s.FunctionARN), RoleArn: aws.String(s.RoleARN)}
+	if s.Input != "" {
+		target.Input = aws.String(s.Input)
+	}
+
+	in := awsScheduler.CreateScheduleInput{
+		Name:                       aws.String(s.Name),
+		GroupName:                  aws.String(groupName(s.Group)),
+		ScheduleExpression:         aws.String(s.Expression),
+		ScheduleExpressionTimezone: aws.String(timezone),
+		State:                      state,
+		FlexibleTimeWindow:         &types.FlexibleTimeWindow{Mode: types.FlexibleTimeWindowModeOff},
+		Target:                     &target,
+	}
+	if s.Description != "" {
+		in.Description = aws.String(s.Description)
+	}
+
+	return &in
+}
+
+// updateInput replaces every setting of the schedule with the ones of in
+func updateInput(in *awsScheduler.CreateScheduleInput) *awsScheduler.UpdateScheduleInput {
+	return &awsScheduler.UpdateScheduleInput{
+		Name:                       in.Name,
+		GroupName:                  in.GroupName,
+		Description:                in.Description,
+		ScheduleExpression:         in.ScheduleExpression,
+		ScheduleExpressionTimezone: in.ScheduleExpressionTimezone,
+		State:                      in.State,
+		FlexibleTimeWindow:         in.FlexibleTimeWindow,
+		Target:                     in.Target,
+	}
+}
+
+// ScheduleReport is a schedule or, when Kind is RuleKind, a scheduled rule
+// whose Group is the name of its event bus
+type ScheduleReport struct {
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	Group       string `json:"group"`
+	ARN         string `json:"arn"`
+	Expression  string `json:"expression"`
+	Timezone    string `json:"timezone"`
+	State       string `json:"state"`
+	Target      string `json:"target"`
+	Input       string `json:"input,omitempty"`
+	Description string `json:"description,omitempty"`
+	ModifiedAt  string `json:"modified_at,omitempty"`
+}
+
+func toScheduleReport(out *awsScheduler.GetScheduleOutput) ScheduleReport {
+	r := ScheduleReport{
+		Kind:        ScheduleKind,
+		Name:        aws.ToString(out.Name),
+		Group:       aws.ToString(out.GroupName),
+		ARN:         aws.ToString(out.Arn),
+		Expression:  aws.ToString(out.ScheduleExpression),
+		Timezone:    aws.ToString(out.ScheduleExpressionTimezone),
+		State:       string(out.State),
+		Description: aws.ToString(out.Description),
+	}
+
+	if out.Target != nil {
+		r.Target = aws.ToString(out.Target.Arn)
+		r.Input = aws.ToString(out.Target.Input)
+	}
+
+	if out.LastModificationDate != nil {
+		r.ModifiedAt = out.LastModificationDate.UTC().Format(time.RFC3339)
+	}
+
+	return r
+}
+
+type Client struct {
+	api         AdapterAPI
+	rules       RuleAPI
+	retryPolicy *retry.Policy
+}
+
+func NewClientWithConfig(cfg aws.Config) *Client {
+	cfg = retry.NoSDKRetries(cfg)
+	return NewClient(awsScheduler.NewFromConfig(cfg), awsEvents.NewFromConfig(cfg))
+}
+
+func NewClient(api AdapterAPI, rules RuleAPI) *Client {
+	return &Client{api: api, rules: rules}
+}
+
+// RetryPolicy is the policy the client retries its calls with
+func (c *Client) RetryPolicy() retry.Policy {
+	if c.retryPolicy == nil {
+		return retry.Default()
+	}
+	return *c.retryPolicy
+}
+
+// SetRetryPolicy replaces retry.Default for the calls made by the client
+func (c *Client) SetRetryPolicy(p retry.Policy) {
+	c.retryPolicy = &p
+}
+
+// PutSchedule creates the schedule, or replaces every setting of the
+// schedule when one with the name already exists in the group
+func (c *Client) PutSchedule(ctx context.Context, s ScheduleSettings) (*ScheduleReport, error) {
+	if err := s.Validate(); err != nil {
+		return nil, failure.Wrap(err, "s.Validate failed")
+	}
+
+	in := s.input()
+	_, err := retry.Call(ctx, c.RetryPolicy(), c.api.CreateSchedule, in)
+	if err != nil {
+		var conflict *types.ConflictException
+		if !errors.As(err, &conflict) {
+			return nil, failure.ToSystem(err, "c.api.CreateSchedule failed (%s)", s.Name)
+		}
+
+		if _, err = retry.Call(ctx, c.RetryPolicy(), c.api.UpdateSchedule, updateInput(in)); err != nil {
+			return nil, failure.ToSystem(err, "c.api.UpdateSchedule failed (%s)", s.Name)
+		}
+	}
+
+	report, err := c.Schedule(ctx, aws.ToString(in.GroupName), s.Name)
+	if err != nil {
+		return nil, failure.Wrap(err, "c.Schedule failed")
+	}
+
+	return report, nil
+}
+
+// Schedule reads one schedule of the group
+func (c *Client) Schedule(ctx context.Context, group, name string) (*ScheduleReport, error) {
+	group = groupName(group)
+	in := awsScheduler.GetScheduleInput{Name: aws.String(name), GroupName: aws.String(group)}
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetSchedule, &in)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, failure.ToNotFound(err, "schedule (%s) is not in group (%s)", name, group)
+		}
+		return nil, failure.ToSystem(err, "c.api.GetSchedule failed (%s)", name)
+	}
+
+	report := toScheduleReport(out)
+	return &report, nil
+}
+
+// FunctionSchedules are the schedules of the group that target the function,
+// or one of its versions or aliases, sorted by name
+func (c *Client) FunctionSchedules(ctx context.Context, group, functionName string) ([]ScheduleReport, error) {
+	group = groupName(group)
+	in := awsScheduler.ListSchedulesInput{GroupName: aws.String(group)}
+	result := []ScheduleReport{}
+	for {
+		out, err := retry.Call(ctx, c.RetryPolicy(), c.api.ListSchedules, &in)
+		if err != nil {
+			return nil, failure.ToSystem(err, "c.api.ListSchedules failed (%s)", group)
+		}
+
+		for _, s := range out.Schedules {
+			if s.Target == nil || !IsFunctionTarget(aws.ToString(s.Target.Arn), functionName) {
+				continue
+			}
+
+			report, err := c.Schedule(ctx, aws.ToString(s.GroupName), aws.ToString(s.Name))
+			if err != nil {
+				return nil, failure.Wrap(err, "c.Schedule failed")
+			}
+			result = append(result, *report)
+		}
+
+		if aws.ToString(out.NextToken) == "" {
+			break
+		}
+		in.NextToken = out.NextToken
+	}
+
+	sort.Slice(result, func(a, b int) bool {
+		return result[a].Name < result[b].Name
+	})
+
+	return result, nil
+}
+
+// DeleteSchedule removes the schedule from the group
+func (c *Client) DeleteSchedule(ctx context.Context, group, name string) error {
+	group = groupName(group)
+	in := awsScheduler.DeleteScheduleInput{Name: aws.String(name), GroupName: aws.String(group)}
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.DeleteSchedule, &in); err != nil {
+		if isNotFound(err) {
+			return failure.ToNotFound(err, "schedule (%s) is not in group (%s)", name, group)
+		}
+		return failure.ToSystem(err, "c.api.DeleteSchedule failed (%s)", name)
+	}
+
+	return nil
+}
+
+// IsFunctionTarget is whether arn is the lambda function, ex
+// `arn:aws:lambda:us-east-1:123456789012:function:name` or the same arn with
+// a version or alias after the name
+func IsFunctionTarget(arn, functionName string) bool {
+	idx := strings.Index(arn, ":function:")
+	if idx < 0 {
+		return false
+	}
+
+	name := arn[idx+len(":function:"):]
+	if qualifier := strings.IndexByte(name, ':'); qualifier >= 0 {
+		name = name[:qualifier]
+	}
+
+	return name == functionName
+}
+
+func groupName(group string) string {
+	if group == "" {
+		return DefaultGroup
+	}
+	return group
+}
+
+func isNotFound(err error) bool {
+	var notFound *types.ResourceNotFoundException
+	return errors.As(err, &notFound)
+}
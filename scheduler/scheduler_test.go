@@ -0,0 +1,244 @@
+package scheduler_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsEvents "github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventTypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	awsScheduler "github.com/aws/aws-sdk-go-v2/service/scheduler"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler/types"
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const functionARN = "arn:aws:lambda:us-east-1:123456789012:function:app-dev-report"
+
+// MockAPI keeps the schedules of every group by name
+type MockAPI struct {
+	Schedules map[string]*awsScheduler.GetScheduleOutput
+	Updates   int
+}
+
+func (m *MockAPI) CreateSchedule(_ context.Context, params *awsScheduler.CreateScheduleInput, _ ...func(*awsScheduler.Options)) (*awsScheduler.CreateScheduleOutput, error) {
+	if _, ok := m.Schedules[aws.ToString(params.Name)]; ok {
+		return nil, &types.ConflictException{Message: aws.String("schedule already exists")}
+	}
+
+	m.Schedules[aws.ToString(params.Name)] = &awsScheduler.GetScheduleOutput{
+		Name:                       params.Name,
+		GroupName:                  params.GroupName,
+		ScheduleExpression:         params.ScheduleExpression,
+		ScheduleExpressionTimezone: params.ScheduleExpressionTimezone,
+		State:                      params.State,
+		Target:                     params.Target,
+	}
+	return &awsScheduler.CreateScheduleOutput{}, nil
+}
+
+func (m *MockAPI) UpdateSchedule(_ context.Context, params *awsScheduler.UpdateScheduleInput, _ ...func(*awsScheduler.Options)) (*awsScheduler.UpdateScheduleOutput, error) {
+	m.Updates++
+	s := m.Schedules[aws.ToString(params.Name)]
+	s.ScheduleExpression = params.ScheduleExpression
+	s.State = params.State
+	s.Target = params.Target
+	return &awsScheduler.UpdateScheduleOutput{}, nil
+}
+
+func (m *MockAPI) GetSchedule(_ context.Context, params *awsScheduler.GetScheduleInput, _ ...func(*awsScheduler.Options)) (*awsScheduler.GetScheduleOutput, error) {
+	s, ok := m.Schedules[aws.ToString(params.Name)]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("schedule not found")}
+	}
+	return s, nil
+}
+
+func (m *MockAPI) ListSchedules(_ context.Context, _ *awsScheduler.ListSchedulesInput, _ ...func(*awsScheduler.Options)) (*awsScheduler.ListSchedulesOutput, error) {
+	var out awsScheduler.ListSchedulesOutput
+	for _, s := range m.Schedules {
+		out.Schedules = append(out.Schedules, types.ScheduleSummary{
+			Name:      s.Name,
+			GroupName: s.GroupName,
+			Target:    &types.TargetSummary{Arn: s.Target.Arn},
+		})
+	}
+	return &out, nil
+}
+
+func (m *MockAPI) DeleteSchedule(_ context.Context, params *awsScheduler.DeleteScheduleInput, _ ...func(*awsScheduler.Options)) (*awsScheduler.DeleteScheduleOutput, error) {
+	if _, ok := m.Schedules[aws.ToString(params.Name)]; !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("schedule not found")}
+	}
+	delete(m.Schedules, aws.ToString(params.Name))
+	return &awsScheduler.DeleteScheduleOutput{}, nil
+}
+
+// MockRuleAPI keeps the rules of one bus, targets are listed one per page
+type MockRuleAPI struct {
+	Rules   map[string]eventTypes.Rule
+	Targets map[string][]eventTypes.Target
+	Removed []string
+}
+
+func (m *MockRuleAPI) PutRule(_ context.Context, params *awsEvents.PutRuleInput, _ ...func(*awsEvents.Options)) (*awsEvents.PutRuleOutput, error) {
+	arn := "arn:aws:events:us-east-1:123456789012:rule/" + aws.ToString(params.Name)
+	m.Rules[aws.ToString(params.Name)] = eventTypes.Rule{
+		Arn:                aws.String(arn),
+		Name:               params.Name,
+		EventBusName:       params.EventBusName,
+		ScheduleExpression: params.ScheduleExpression,
+		State:              params.State,
+	}
+	return &awsEvents.PutRuleOutput{RuleArn: aws.String(arn)}, nil
+}
+
+func (m *MockRuleAPI) PutTargets(_ context.Context, params *awsEvents.PutTargetsInput, _ ...func(*awsEvents.Options)) (*awsEvents.PutTargetsOutput, error) {
+	m.Targets[aws.ToString(params.Rule)] = append(m.Targets[aws.ToString(params.Rule)], params.Targets...)
+	return &awsEvents.PutTargetsOutput{}, nil
+}
+
+func (m *MockRuleAPI) DescribeRule(_ context.Context, params *awsEvents.DescribeRuleInput, _ ...func(*awsEvents.Options)) (*awsEvents.DescribeRuleOutput, error) {
+	r, ok := m.Rules[aws.ToString(params.Name)]
+	if !ok {
+		return nil, &eventTypes.ResourceNotFoundException{Message: aws.String("rule not found")}
+	}
+	return &awsEvents.DescribeRuleOutput{
+		Arn:                r.Arn,
+		Name:               r.Name,
+		EventBusName:       r.EventBusName,
+		ScheduleExpression: r.ScheduleExpression,
+		EventPattern:       r.EventPattern,
+		State:              r.State,
+	}, nil
+}
+
+func (m *MockRuleAPI) ListRules(_ context.Context, _ *awsEvents.ListRulesInput, _ ...func(*awsEvents.Options)) (*awsEvents.ListRulesOutput, error) {
+	var out awsEvents.ListRulesOutput
+	for _, r := range m.Rules {
+		out.Rules = append(out.Rules, r)
+	}
+	return &out, nil
+}
+
+func (m *MockRuleAPI) ListTargetsByRule(_ context.Context, params *awsEvents.ListTargetsByRuleInput, _ ...func(*awsEvents.Options)) (*awsEvents.ListTargetsByRuleOutput, error) {
+	name := aws.ToString(params.Rule)
+	if _, ok := m.Rules[name]; !ok {
+		return nil, &eventTypes.ResourceNotFoundException{Message: aws.String("rule not found")}
+	}
+
+	idx := 0
+	if params.NextToken != nil {
+		idx = 1
+	}
+
+	var out awsEvents.ListTargetsByRuleOutput
+	if targets := m.Targets[name]; idx < len(targets) {
+		out.Targets = targets[idx : idx+1]
+		if idx+1 < len(targets) {
+			out.NextToken = aws.String("1")
+		}
+	}
+	return &out, nil
+}
+
+func (m *MockRuleAPI) RemoveTargets(_ context.Context, params *awsEvents.RemoveTargetsInput, _ ...func(*awsEvents.Options)) (*awsEvents.RemoveTargetsOutput, error) {
+	m.Removed = append(m.Removed, params.Ids...)
+	delete(m.Targets, aws.ToString(params.Rule))
+	return &awsEvents.RemoveTargetsOutput{}, nil
+}
+
+func (m *MockRuleAPI) DeleteRule(_ context.Context, params *awsEvents.DeleteRuleInput, _ ...func(*awsEvents.Options)) (*awsEvents.DeleteRuleOutput, error) {
+	delete(m.Rules, aws.ToString(params.Name))
+	return &awsEvents.DeleteRuleOutput{}, nil
+}
+
+func newClient() (*scheduler.Client, *MockAPI, *MockRuleAPI) {
+	api := MockAPI{Schedules: map[string]*awsScheduler.GetScheduleOutput{}}
+	rules := MockRuleAPI{Rules: map[string]eventTypes.Rule{}, Targets: map[string][]eventTypes.Target{}}
+	return scheduler.NewClient(&api, &rules), &api, &rules
+}
+
+func TestClient_PutSchedule(t *testing.T) {
+	c, api, _ := newClient()
+	s := scheduler.ScheduleSettings{
+		Name:        "nightly",
+		Expression:  "cron(0 3 * * ? *)",
+		FunctionARN: functionARN,
+		RoleARN:     "arn:aws:iam::123456789012:role/scheduler-invoke",
+	}
+
+	report, err := c.PutSchedule(context.TODO(), s)
+	require.NoError(t, err, "PutSchedule is not expected to fail")
+	assert.Equal(t, scheduler.ScheduleKind, report.Kind)
+	assert.Equal(t, scheduler.DefaultGroup, report.Group)
+	assert.Equal(t, scheduler.DefaultTimezone, report.Timezone)
+	assert.Equal(t, scheduler.EnabledState, report.State)
+	assert.Equal(t, functionARN, report.Target)
+	assert.Equal(t, 0, api.Updates)
+
+	s.Expression = "rate(1 hour)"
+	s.IsDisabled = true
+	report, err = c.PutSchedule(context.TODO(), s)
+	require.NoError(t, err, "an existing schedule is expected to be updated")
+	assert.Equal(t, 1, api.Updates)
+	assert.Equal(t, "rate(1 hour)", report.Expression)
+	assert.Equal(t, scheduler.DisabledState, report.State)
+
+	schedules, err := c.FunctionSchedules(context.TODO(), "", "app-dev-report")
+	require.NoError(t, err)
+	require.Len(t, schedules, 1)
+	assert.Equal(t, "nightly", schedules[0].Name)
+
+	require.NoError(t, c.DeleteSchedule(context.TODO(), "", "nightly"))
+	err = c.DeleteSchedule(context.TODO(), "", "nightly")
+	require.Error(t, err)
+	assert.True(t, failure.IsNotFound(err), "expected a not found error, got %v", err)
+}
+
+func TestClient_PutRule(t *testing.T) {
+	c, _, rules := newClient()
+	rules.Rules["orders"] = eventTypes.Rule{
+		Name:         aws.String("orders"),
+		EventBusName: aws.String(scheduler.DefaultBus),
+		EventPattern: aws.String(`{"source":["orders"]}`),
+	}
+	rules.Targets["orders"] = []eventTypes.Target{{Id: aws.String("lambda"), Arn: aws.String(functionARN)}}
+
+	s := scheduler.RuleSettings{
+		Name:        "nightly",
+		Expression:  "rate(5 minutes)",
+		FunctionARN: functionARN + ":live",
+		Input:       `{"job":"report"}`,
+	}
+
+	report, err := c.PutRule(context.TODO(), s)
+	require.NoError(t, err, "PutRule is not expected to fail")
+	assert.Equal(t, scheduler.RuleKind, report.Kind)
+	assert.Equal(t, scheduler.DefaultBus, report.Group)
+	assert.Equal(t, "arn:aws:events:us-east-1:123456789012:rule/nightly", report.ARN)
+	assert.Equal(t, functionARN+":live", report.Target)
+	assert.Equal(t, `{"job":"report"}`, report.Input)
+
+	rules.Targets["nightly"] = append([]eventTypes.Target{{Id: aws.String("queue"), Arn: aws.String("arn:aws:sqs:us-east-1:123456789012:jobs")}}, rules.Targets["nightly"]...)
+	found, err := c.FunctionRules(context.TODO(), "", "app-dev-report")
+	require.NoError(t, err)
+	require.Len(t, found, 1, "rules matching an event pattern are not scheduled")
+	assert.Equal(t, "nightly", found[0].Name)
+	assert.Equal(t, functionARN+":live", found[0].Target, "the target on the next page is found")
+
+	require.NoError(t, c.DeleteRule(context.TODO(), "", "nightly"))
+	assert.Equal(t, []string{"queue", scheduler.RuleTargetID}, rules.Removed, "every target is removed before the rule")
+	assert.NotContains(t, rules.Rules, "nightly")
+
+	err = c.DeleteRule(context.TODO(), "", "nightly")
+	require.Error(t, err)
+	assert.True(t, failure.IsNotFound(err), "expected a not found error, got %v", err)
+
+	s.Expression = "at(2024-01-31T18:00:00)"
+	_, err = c.PutRule(context.TODO(), s)
+	require.Error(t, err)
+	assert.True(t, failure.IsInvalidParam(err))
+}
@@ -0,0 +1,359 @@
+// Package secrets implements a secrets manager client used to manage the
+// secrets of microservices, like database credentials, and their rotation
+package secrets
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	sm "github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	CurrentStage  = "AWSCURRENT"
+	PreviousStage = "AWSPREVIOUS"
+	// MaxRotationDays is the longest rotation interval secrets manager accepts
+	MaxRotationDays = 1000
+)
+
+type AdapterAPI interface {
+	GetSecretValue(ctx context.Context, params *sm.GetSecretValueInput, optFns ...func(*sm.Options)) (*sm.GetSecretValueOutput, error)
+	PutSecretValue(ctx context.Context, params *sm.PutSecretValueInput, optFns ...func(*sm.Options)) (*sm.PutSecretValueOutput, error)
+	CreateSecret(ctx context.Context, params *sm.CreateSecretInput, optFns ...func(*sm.Options)) (*sm.CreateSecretOutput, error)
+	DescribeSecret(ctx context.Context, params *sm.DescribeSecretInput, optFns ...func(*sm.Options)) (*sm.DescribeSecretOutput, error)
+	RotateSecret(ctx context.Context, params *sm.RotateSecretInput, optFns ...func(*sm.Options)) (*sm.RotateSecretOutput, error)
+	ListSecrets(ctx context.Context, params *sm.ListSecretsInput, optFns ...func(*sm.Options)) (*sm.ListSecretsOutput, error)
+}
+
+type Client struct {
+	api         AdapterAPI
+	retryPolicy *retry.Policy
+}
+
+func NewClientWithConfig(cfg aws.Config) *Client {
+	api := sm.NewFromConfig(retry.NoSDKRetries(cfg))
+	return NewClient(api)
+}
+
+func NewClient(api AdapterAPI) *Client {
+	return &Client{api: api}
+}
+
+// RetryPolicy is the policy the client retries its calls with
+func (c *Client) RetryPolicy() retry.Policy {
+	if c.retryPolicy == nil {
+		return retry.Default()
+	}
+	return *c.retryPolicy
+}
+
+// SetRetryPolicy replaces retry.Default for the calls made by the client
+func (c *Client) SetRetryPolicy(p retry.Policy) {
+	c.retryPolicy = &p
+}
+
+// SecretReport describes a secret without its value. RotationDays is 0 when
+// the rotation uses a schedule expression instead.
+type SecretReport struct {
+	Name             string `json:"name"`
+	ARN              string `json:"arn"`
+	Description      string `json:"description,omitempty"`
+	KMSKey           string `json:"kms_key,omitempty"`
+	IsRotating       bool   `json:"is_rotating"`
+	RotationLambda   string `json:"rotation_lambda,omitempty"`
+	RotationDays     int64  `json:"rotation_days,omitempty"`
+	RotationSchedule string `json:"rotation_schedule,omitempty"`
+	LastChanged      string `json:"last_changed,omitempty"`
+	LastRotated      string `json:"last_rotated,omitempty"`
+	NextRotation     string `json:"next_rotation,omitempty"`
+}
+
+// SecretValue is a version of the value of a secret
+type SecretValue struct {
+	Name      string   `json:"name"`
+	ARN       string   `json:"arn"`
+	Value     string   `json:"value"`
+	VersionID string   `json:"version_id"`
+	Stages    []string `json:"stages"`
+}
+
+// PutSettings is a new value of a secret. The secret is created, with
+// Description and KMSKey, when it does not exist and otherwise given a new
+// current version, Description and KMSKey are then ignored.
+type PutSettings struct {
+	Name        string
+	Value       string
+	Description string
+	KMSKey      string
+}
+
+// PutReport is the version the value was stored as, IsCreated is true when
+// the secret did not exist before
+type PutReport struct {
+	Name      string `json:"name"`
+	ARN       string `json:"arn"`
+	VersionID string `json:"version_id"`
+	IsCreated bool   `json:"is_created"`
+}
+
+// RotationSettings assigns the rotation lambda of a secret. A secret rotates
+// every Days or on Schedule, a rate or cron expression, only one of them.
+// Window is how long a rotation can take, ex 3h. Unless IsDeferred the
+// secret is rotated right away.
+type RotationSettings struct {
+	Name       string
+	LambdaARN  string
+	Days       int64
+	Schedule   string
+	Window     string
+	IsDeferred bool
+}
+
+func (s RotationSettings) Validate() error {
+	if s.Name == "" {
+		return failure.InvalidParam("[Name] secret name is empty")
+	}
+
+	if s.LambdaARN != "" && !strings.HasPrefix(s.LambdaARN, "arn:") {
+		return failure.InvalidParam("[LambdaARN] (%s) is not an arn", s.LambdaARN)
+	}
+
+	if s.Days != 0 && s.Schedule != "" {
+		return failure.InvalidParam("[Days] and [Schedule] can not both be set, a secret rotates on one of them")
+	}
+
+	if s.Days < 0 || s.Days > MaxRotationDays {
+		return failure.InvalidParam("[Days] (%d) must be between 1 and %d", s.Days, MaxRotationDays)
+	}
+
+	if s.Schedule != "" && !strings.HasPrefix(s.Schedule, "rate(") && !strings.HasPrefix(s.Schedule, "cron(") {
+		return failure.InvalidParam("[Schedule] (%s) must be a rate() or cron() expression", s.Schedule)
+	}
+
+	return nil
+}
+
+func (s RotationSettings) rules() *types.RotationRulesType {
+	if s.Days == 0 && s.Schedule == "" && s.Window == "" {
+		return nil
+	}
+
+	rules := types.RotationRulesType{}
+	if s.Days > 0 {
+		rules.AutomaticallyAfterDays = aws.Int64(s.Days)
+	}
+	if s.Schedule != "" {
+		rules.ScheduleExpression = aws.String(s.Schedule)
+	}
+	if s.Window != "" {
+		rules.Duration = aws.String(s.Window)
+	}
+
+	return &rules
+}
+
+// Value reads the version of the secret in stage, empty is CurrentStage
+func (c *Client) Value(ctx context.Context, name, stage string) (*SecretValue, error) {
+	if name == "" {
+		return nil, failure.InvalidParam("name is empty, the secret name or arn is required")
+	}
+
+	in := sm.GetSecretValueInput{SecretId: aws.String(name)}
+	if stage != "" {
+		in.VersionStage = aws.String(stage)
+	}
+
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetSecretValue, &in)
+	if err != nil {
+		return nil, handleAPIError(err, "c.api.GetSecretValue failed (%s)", name)
+	}
+
+	value := aws.ToString(out.SecretString)
+	if out.SecretString == nil && out.SecretBinary != nil {
+		value = string(out.SecretBinary)
+	}
+
+	return &SecretValue{
+		Name:      aws.ToString(out.Name),
+		ARN:       aws.ToString(out.ARN),
+		Value:     value,
+		VersionID: aws.ToString(out.VersionId),
+		Stages:    out.VersionStages,
+	}, nil
+}
+
+// Put stores the value as the current version of the secret, creating the
+// secret when it does not exist
+func (c *Client) Put(ctx context.Context, s PutSettings) (*PutReport, error) {
+	if s.Name == "" {
+		return nil, failure.InvalidParam("[Name] secret name is empty")
+	}
+
+	in := sm.PutSecretValueInput{SecretId: aws.String(s.Name), SecretString: aws.String(s.Value)}
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.PutSecretValue, &in)
+	if err == nil {
+		return &PutReport{Name: aws.ToString(out.Name), ARN: aws.ToString(out.ARN), VersionID: aws.ToString(out.VersionId)}, nil
+	}
+
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		return nil, failure.ToSystem(err, "c.api.PutSecretValue failed (%s)", s.Name)
+	}
+
+	create := sm.CreateSecretInput{
+		Name:         aws.String(s.Name),
+		SecretString: aws.String(s.Value),
+	}
+	if s.Description != "" {
+		create.Description = aws.String(s.Description)
+	}
+	if s.KMSKey != "" {
+		create.KmsKeyId = aws.String(s.KMSKey)
+	}
+
+	created, err := retry.Call(ctx, c.RetryPolicy(), c.api.CreateSecret, &create)
+	if err != nil {
+		return nil, failure.ToSystem(err, "c.api.CreateSecret failed (%s)", s.Name)
+	}
+
+	return &PutReport{
+		Name:      aws.ToString(created.Name),
+		ARN:       aws.ToString(created.ARN),
+		VersionID: aws.ToString(created.VersionId),
+		IsCreated: true,
+	}, nil
+}
+
+// Describe reads the settings and rotation of the secret
+func (c *Client) Describe(ctx context.Context, name string) (*SecretReport, error) {
+	in := sm.DescribeSecretInput{SecretId: aws.String(name)}
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.DescribeSecret, &in)
+	if err != nil {
+		return nil, handleAPIError(err, "c.api.DescribeSecret failed (%s)", name)
+	}
+
+	report := toSecretReport(types.SecretListEntry{
+		ARN:               out.ARN,
+		Name:              out.Name,
+		Description:       out.Description,
+		KmsKeyId:          out.KmsKeyId,
+		RotationEnabled:   out.RotationEnabled,
+		RotationLambdaARN: out.RotationLambdaARN,
+		RotationRules:     out.RotationRules,
+		LastChangedDate:   out.LastChangedDate,
+		LastRotatedDate:   out.LastRotatedDate,
+		NextRotationDate:  out.NextRotationDate,
+	})
+
+	return &report, nil
+}
+
+// Rotate assigns the rotation lambda and rules of the secret and, unless
+// s.IsDeferred, starts a rotation. A zero LambdaARN keeps the lambda the
+// secret already rotates with, a secret that never rotated requires one.
+func (c *Client) Rotate(ctx context.Context, s RotationSettings) (*SecretReport, error) {
+	if err := s.Validate(); err != nil {
+		return nil, failure.Wrap(err, "s.Validate failed")
+	}
+
+	in := sm.RotateSecretInput{
+		SecretId:          aws.String(s.Name),
+		RotationRules:     s.rules(),
+		RotateImmediately: aws.Bool(!s.IsDeferred),
+	}
+	if s.LambdaARN != "" {
+		in.RotationLambdaARN = aws.String(s.LambdaARN)
+	}
+
+	if _, err := retry.Call(ctx, c.RetryPolicy(), c.api.RotateSecret, &in); err != nil {
+		return nil, handleAPIError(err, "c.api.RotateSecret failed (%s)", s.Name)
+	}
+
+	report, err := c.Describe(ctx, s.Name)
+	if err != nil {
+		return nil, failure.Wrap(err, "c.Describe failed")
+	}
+
+	return report, nil
+}
+
+// List describes the secrets whose name starts with prefix, sorted by name.
+// An empty prefix lists every secret of the account.
+func (c *Client) List(ctx context.Context, prefix string) ([]SecretReport, error) {
+	in := sm.ListSecretsInput{}
+	if prefix != "" {
+		in.Filters = []types.Filter{{Key: types.FilterNameStringTypeName, Values: []string{prefix}}}
+	}
+
+	result := []SecretReport{}
+	for {
+		out, err := retry.Call(ctx, c.RetryPolicy(), c.api.ListSecrets, &in)
+		if err != nil {
+			return nil, failure.ToSystem(err, "c.api.ListSecrets failed (%s)", prefix)
+		}
+
+		for _, s := range out.SecretList {
+			// the name filter matches words of the name, not only its start
+			if !strings.HasPrefix(aws.ToString(s.Name), prefix) {
+				continue
+			}
+			result = append(result, toSecretReport(s))
+		}
+
+		if aws.ToString(out.NextToken) == "" {
+			break
+		}
+		in.NextToken = out.NextToken
+	}
+
+	sort.Slice(result, func(a, b int) bool {
+		return result[a].Name < result[b].Name
+	})
+
+	return result, nil
+}
+
+func toSecretReport(s types.SecretListEntry) SecretReport {
+	r := SecretReport{
+		Name:           aws.ToString(s.Name),
+		ARN:            aws.ToString(s.ARN),
+		Description:    aws.ToString(s.Description),
+		KMSKey:         aws.ToString(s.KmsKeyId),
+		IsRotating:     aws.ToBool(s.RotationEnabled),
+		RotationLambda: aws.ToString(s.RotationLambdaARN),
+		LastChanged:    formatTime(s.LastChangedDate),
+		LastRotated:    formatTime(s.LastRotatedDate),
+		NextRotation:   formatTime(s.NextRotationDate),
+	}
+
+	if rules := s.RotationRules; rules != nil {
+		r.RotationDays = aws.ToInt64(rules.AutomaticallyAfterDays)
+		r.RotationSchedule = aws.ToString(rules.ScheduleExpression)
+	}
+
+	return r
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func handleAPIError(err error, msg string, a ...interface{}) error {
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return failure.ToNotFound(err, msg, a...)
+	}
+
+	return failure.ToSystem(err, msg, a...)
+}
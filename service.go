@@ -25,6 +25,7 @@ const (
 	DDBTrigger             = InvokeTrigger("ddb")
 	DDBStreamTrigger       = InvokeTrigger("ddb-stream")
 	DirectTrigger          = InvokeTrigger("direct")
+	FunctionURLTrigger     = InvokeTrigger("furl")
 	KinesisStreamTrigger   = InvokeTrigger("kinesis-stream")
 	SNSTrigger             = InvokeTrigger("sns")
 	SQSTrigger             = InvokeTrigger("sqs")
@@ -35,10 +36,13 @@ const (
 var (
 	APIGWProxyEvent      = reflect.TypeOf(events.APIGatewayProxyRequest{})
 	APIGWCustomAuthEvent = reflect.TypeOf(events.APIGatewayCustomAuthorizerRequest{})
+	AppSyncEvent         = reflect.TypeOf(AppSyncResolverEvent{})
+	AppSyncBatchEvent    = reflect.TypeOf([]AppSyncResolverEvent{})
 	CloudWatchEvent      = reflect.TypeOf(events.CloudWatchEvent{})
 	CloudWatchLogsEvent  = reflect.TypeOf(events.CloudwatchLogsEvent{})
 	DDBEvent             = reflect.TypeOf(events.DynamoDBEvent{})
 	DirectEvent          = reflect.TypeOf([]byte{})
+	FunctionURLEvent     = reflect.TypeOf(events.LambdaFunctionURLRequest{})
 	SNSEvent             = reflect.TypeOf(events.SNSEvent{})
 	SQSEvent             = reflect.TypeOf(events.SQSEvent{})
 	S3Event              = reflect.TypeOf(events.S3Event{})
@@ -60,12 +64,16 @@ func InvokeTriggerFromString(s string) (InvokeTrigger, error) {
 	switch strings.ToLower(s) {
 	case APIGWProxyTrigger.String():
 		t = APIGWProxyTrigger
+	case AppSyncTrigger.String():
+		t = AppSyncTrigger
 	case DDBTrigger.String():
 		t = DDBTrigger
 	case DDBStreamTrigger.String():
 		t = DDBStreamTrigger
 	case DirectTrigger.String():
 		t = DirectTrigger
+	case FunctionURLTrigger.String():
+		t = FunctionURLTrigger
 	case CognitoTrigger.String():
 		t = CognitoTrigger
 	case S3Trigger.String():
@@ -90,6 +98,8 @@ func InvokeTriggerFromEvent(t reflect.Type) (InvokeTrigger, error) {
 		it = APIGWProxyTrigger
 	case APIGWCustomAuthEvent:
 		it = APIGWCustomAuthTrigger
+	case AppSyncEvent, AppSyncBatchEvent:
+		it = AppSyncTrigger
 	case CloudWatchEvent:
 		it = CloudWatchEventTrigger
 	case CloudWatchLogsEvent:
@@ -98,6 +108,8 @@ func InvokeTriggerFromEvent(t reflect.Type) (InvokeTrigger, error) {
 		it = DDBTrigger
 	case DirectEvent:
 		it = DirectTrigger
+	case FunctionURLEvent:
+		it = FunctionURLTrigger
 	case SNSEvent:
 		it = SNSTrigger
 	case SQSEvent:
@@ -119,6 +131,9 @@ type Feature struct {
 	BinaryZipName string
 	Conf          Configurable
 	Env           map[string]string
+	Dependencies  []Dependency
+	ParamScope    ParamScope
+	Packaging     PackageSettings
 }
 
 func (l Feature) AddEnv(name, value string) {
@@ -276,11 +291,12 @@ func (s *MicroService) NewBuildSettings(feature Feature) BuildSettings {
 	binName := feature.BinaryName
 	buildDir := s.BuildDir()
 	return BuildSettings{
-		CodeDir:  filepath.Join(s.LambdasDir(), feature.CodeDir()),
-		BuildDir: s.BuildDir(),
-		BinName:  binName,
-		ZipName:  feature.BinaryZipName,
-		BinPath:  filepath.Join(buildDir, binName),
+		CodeDir:   filepath.Join(s.LambdasDir(), feature.CodeDir()),
+		BuildDir:  s.BuildDir(),
+		BinName:   binName,
+		ZipName:   feature.BinaryZipName,
+		BinPath:   filepath.Join(buildDir, binName),
+		Packaging: feature.Packaging,
 	}
 }
 func (s *MicroService) BuildFeatureCode(buildDir, binaryName, sourceDir string) (*CompileResult, error) {
@@ -509,10 +525,18 @@ type BuildSettings struct {
 	BinPath     string
 	SkipZipping bool
 	ZipName     string
+	IsAnalyze   bool
+	Packaging   PackageSettings
+	Packager    Packager
+	// Architecture is the GOARCH the binary is built for, amd64 or arm64.
+	// Empty is the GOARCH of the environment.
+	Architecture string
 }
 
 type BuildResult struct {
 	Settings BuildSettings
 	ZipName  string
 	ZipData  []byte
+	Analysis *BinaryReport
+	Package  *Package
 }
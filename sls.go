@@ -49,6 +49,12 @@ const (
 	NetworkingTF   = "networking"
 )
 
+// LambdaDeployBucketName is the bucket of the LambdaDeployTF resource in the
+// env of prefix, like `use1-qa-lambda-deploy-bucket`
+func LambdaDeployBucketName(prefix Prefix) string {
+	return fmt.Sprintf("%s-%s", prefix, LambdaDeployTF)
+}
+
 // Prefix represents our naming prefix, used when creating resources with
 // terraform. Every fender resource has a prefix to encode information about
 // it. The fender prefix is laid out as follows:
@@ -95,7 +101,7 @@ func (p Prefix) Env() string {
 	return p.EnvName
 }
 func (p Prefix) RegionCode() string {
-	return p.RegionCode()
+	return p.Region.Code()
 }
 
 func (p Prefix) AWSRegion() string {
@@ -0,0 +1,153 @@
+// Package slsctx carries the identity of the running feature (service, env,
+// region, feature name and build info) in the invocation context. Runners
+// resolve the Metadata once at startup and inject it into every invocation,
+// so handlers and libraries never have to re-read env vars for it.
+package slsctx
+
+import (
+	"context"
+	"os"
+	"runtime/debug"
+
+	"github.com/rsb/sls"
+)
+
+const (
+	metadataKey ctxKey = "slsMetadata"
+
+	ServiceEnvVar            = "SLS_SERVICE"
+	EnvEnvVar                = "SLS_ENV"
+	FeatureEnvVar            = "SLS_FEATURE"
+	TriggerEnvVar            = "SLS_TRIGGER"
+	VersionEnvVar            = "SLS_VERSION"
+	AWSRegionEnvVar          = "AWS_REGION"
+	AWSLambdaFunctionNameVar = "AWS_LAMBDA_FUNCTION_NAME"
+)
+
+// contextKey is an internal type used for context keys to restrict access
+// to the context values via the various Get methods.
+type ctxKey string
+
+type Metadata struct {
+	Service       string
+	Env           string
+	Region        string
+	Feature       string
+	QualifiedName string
+	Trigger       sls.InvokeTrigger
+	Version       string
+	Revision      string
+	GoVersion     string
+}
+
+// NewMetadata builds the metadata from the service layout, this is what the
+// infra commands use to know which values to deploy
+func NewMetadata(name sls.ServiceName, feature sls.Feature) Metadata {
+	return Metadata{
+		Service:       name.AppTitle(),
+		Env:           name.Env(),
+		Region:        name.AWSRegion(),
+		Feature:       feature.Name,
+		QualifiedName: feature.QualifiedName,
+		Trigger:       feature.Trigger,
+	}
+}
+
+// FromEnvironment resolves the metadata inside a running lambda. The
+// identity comes from the env vars written by EnvVars during deploy and the
+// build info is read from the binary itself.
+func FromEnvironment() Metadata {
+	m := Metadata{
+		Service:       os.Getenv(ServiceEnvVar),
+		Env:           os.Getenv(EnvEnvVar),
+		Region:        os.Getenv(AWSRegionEnvVar),
+		Feature:       os.Getenv(FeatureEnvVar),
+		QualifiedName: os.Getenv(AWSLambdaFunctionNameVar),
+		Trigger:       sls.InvokeTrigger(os.Getenv(TriggerEnvVar)),
+		Version:       os.Getenv(VersionEnvVar),
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return m
+	}
+
+	m.GoVersion = info.GoVersion
+	if m.Version == "" && info.Main.Version != "(devel)" {
+		m.Version = info.Main.Version
+	}
+
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			m.Revision = s.Value
+		}
+	}
+
+	return m
+}
+
+// EnvVars are the lambda env vars FromEnvironment depends on
+func (m Metadata) EnvVars() map[string]string {
+	vars := map[string]string{
+		ServiceEnvVar: m.Service,
+		EnvEnvVar:     m.Env,
+		FeatureEnvVar: m.Feature,
+		TriggerEnvVar: m.Trigger.String(),
+	}
+
+	if m.Version != "" {
+		vars[VersionEnvVar] = m.Version
+	}
+
+	return vars
+}
+
+// Set sets the feature metadata in the context.
+func Set(ctx context.Context, m Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey, m)
+}
+
+// Get gets the feature metadata from the context. Defaults to an empty Metadata if not set.
+func Get(ctx context.Context) Metadata {
+	val := ctx.Value(metadataKey)
+	m, ok := val.(Metadata)
+	if !ok {
+		m = Metadata{}
+	}
+	return m
+}
+
+// ServiceName gets the service app title from the context. Defaults to an empty string if not set.
+func ServiceName(ctx context.Context) string {
+	return Get(ctx).Service
+}
+
+// Env gets the application env from the context. Defaults to an empty string if not set.
+func Env(ctx context.Context) string {
+	return Get(ctx).Env
+}
+
+// Region gets the aws region from the context. Defaults to an empty string if not set.
+func Region(ctx context.Context) string {
+	return Get(ctx).Region
+}
+
+// FeatureName gets the feature name from the context. Defaults to an empty string if not set.
+func FeatureName(ctx context.Context) string {
+	return Get(ctx).Feature
+}
+
+// Trigger gets the feature's invoke trigger from the context. Defaults to an empty trigger if not set.
+func Trigger(ctx context.Context) sls.InvokeTrigger {
+	return Get(ctx).Trigger
+}
+
+// BuildVersion gets the build version from the context, falling back to the
+// vcs revision. Defaults to an empty string if not set.
+func BuildVersion(ctx context.Context) string {
+	m := Get(ctx)
+	if m.Version != "" {
+		return m.Version
+	}
+	return m.Revision
+}
@@ -0,0 +1,175 @@
+package sqs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/ratelimit"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	MaxBatchSize       = 10
+	DefaultWaitSeconds = 1
+)
+
+// RedriveSettings moves up to Max messages, 0 is every message, from the
+// DeadLetterURL back to the SourceURL. Limiter caps the batches per second,
+// a nil Limiter does not wait.
+type RedriveSettings struct {
+	DeadLetterURL string
+	SourceURL     string
+	Max           int
+	Limiter       *ratelimit.Limiter
+}
+
+func (s RedriveSettings) Validate() error {
+	if s.DeadLetterURL == "" {
+		return failure.InvalidParam("[DeadLetterURL] dead letter queue url is empty")
+	}
+
+	if s.SourceURL == "" {
+		return failure.InvalidParam("[SourceURL] source queue url is empty")
+	}
+
+	if s.DeadLetterURL == s.SourceURL {
+		return failure.InvalidParam("dead letter and source queue are the same (%s)", s.SourceURL)
+	}
+
+	if s.Max < 0 {
+		return failure.InvalidParam("[Max] (%d) must be >= 0", s.Max)
+	}
+
+	return nil
+}
+
+// RedriveReport counts the moved messages. Failed are the ids of the
+// messages the source queue refused, they stay in the dead letter queue.
+type RedriveReport struct {
+	DeadLetterURL string   `json:"dead_letter_url"`
+	SourceURL     string   `json:"source_url"`
+	Moved         int      `json:"moved"`
+	Failed        []string `json:"failed,omitempty"`
+}
+
+// Redrive receives the messages of the dead letter queue in batches, sends
+// them to the source queue and only then deletes them from the dead letter
+// queue, so a failure never loses a message. It stops once the dead letter
+// queue is empty or Max messages were moved.
+func (c *Client) Redrive(ctx context.Context, s RedriveSettings) (RedriveReport, error) {
+	report := RedriveReport{DeadLetterURL: s.DeadLetterURL, SourceURL: s.SourceURL}
+	if err := s.Validate(); err != nil {
+		return report, failure.Wrap(err, "s.Validate failed")
+	}
+
+	for s.Max == 0 || report.Moved < s.Max {
+		if err := s.Limiter.Wait(ctx); err != nil {
+			return report, failure.Wrap(err, "s.Limiter.Wait failed")
+		}
+
+		size := MaxBatchSize
+		if s.Max > 0 && s.Max-report.Moved < size {
+			size = s.Max - report.Moved
+		}
+
+		receive := sqs.ReceiveMessageInput{
+			QueueUrl:              aws.String(s.DeadLetterURL),
+			MaxNumberOfMessages:   int32(size),
+			WaitTimeSeconds:       DefaultWaitSeconds,
+			MessageAttributeNames: []string{"All"},
+		}
+
+		out, err := retry.Call(ctx, c.RetryPolicy(), c.api.ReceiveMessage, &receive)
+		if err != nil {
+			return report, handleAPIError(err, "c.api.ReceiveMessage failed (%s)", s.DeadLetterURL)
+		}
+
+		if len(out.Messages) == 0 {
+			break
+		}
+
+		moved, failed, err := c.moveBatch(ctx, s, out.Messages)
+		report.Moved += moved
+		report.Failed = append(report.Failed, failed...)
+		if err != nil {
+			return report, failure.Wrap(err, "c.moveBatch failed")
+		}
+
+		// every message of the batch was refused, receiving again would
+		// only see them once their visibility timeout ends
+		if moved == 0 {
+			break
+		}
+	}
+
+	return report, nil
+}
+
+func (c *Client) moveBatch(ctx context.Context, s RedriveSettings, messages []types.Message) (int, []string, error) {
+	entries := make([]types.SendMessageBatchRequestEntry, 0, len(messages))
+	byID := map[string]types.Message{}
+	for idx, m := range messages {
+		id := fmt.Sprintf("m%d", idx)
+		byID[id] = m
+		entries = append(entries, types.SendMessageBatchRequestEntry{
+			Id:                aws.String(id),
+			MessageBody:       m.Body,
+			MessageAttributes: m.MessageAttributes,
+		})
+	}
+
+	send := sqs.SendMessageBatchInput{
+		QueueUrl: aws.String(s.SourceURL),
+		Entries:  entries,
+	}
+
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.SendMessageBatch, &send)
+	if err != nil {
+		return 0, nil, handleAPIError(err, "c.api.SendMessageBatch failed (%s)", s.SourceURL)
+	}
+
+	var failed []string
+	for _, f := range out.Failed {
+		if m, ok := byID[aws.ToString(f.Id)]; ok {
+			failed = append(failed, aws.ToString(m.MessageId))
+		}
+	}
+
+	if len(out.Successful) == 0 {
+		return 0, failed, nil
+	}
+
+	deletes := make([]types.DeleteMessageBatchRequestEntry, 0, len(out.Successful))
+	for _, ok := range out.Successful {
+		m := byID[aws.ToString(ok.Id)]
+		deletes = append(deletes, types.DeleteMessageBatchRequestEntry{
+			Id:            ok.Id,
+			ReceiptHandle: m.ReceiptHandle,
+		})
+	}
+
+	del := sqs.DeleteMessageBatchInput{
+		QueueUrl: aws.String(s.DeadLetterURL),
+		Entries:  deletes,
+	}
+
+	delOut, err := retry.Call(ctx, c.RetryPolicy(), c.api.DeleteMessageBatch, &del)
+	if err != nil {
+		return 0, failed, handleAPIError(err, "c.api.DeleteMessageBatch failed (%s)", s.DeadLetterURL)
+	}
+
+	// a message that could not be deleted was still copied, it will be
+	// received again and sent twice
+	for _, f := range delOut.Failed {
+		if m, ok := byID[aws.ToString(f.Id)]; ok {
+			failed = append(failed, aws.ToString(m.MessageId))
+		}
+	}
+
+	return len(delOut.Successful), failed, nil
+}
@@ -0,0 +1,222 @@
+// Package sqs implements an sqs client used by microservice features to
+// retry messages with a growing delay and by the infra commands to move the
+// messages of a dead letter queue back to their source queue
+package sqs
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	// RetryAttemptAttribute is the message attribute counting how many times
+	// a message was scheduled for a retry
+	RetryAttemptAttribute = "sls-retry-attempt"
+	// MaxDelay is the longest DelaySeconds sqs accepts
+	MaxDelay             = 15 * time.Minute
+	DefaultRetryBase     = 10 * time.Second
+	DefaultRetryAttempts = 5
+)
+
+type AdapterAPI interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+	SendMessageBatch(ctx context.Context, params *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error)
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessageBatch(ctx context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error)
+	GetQueueUrl(ctx context.Context, params *sqs.GetQueueUrlInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error)
+	ListDeadLetterSourceQueues(ctx context.Context, params *sqs.ListDeadLetterSourceQueuesInput, optFns ...func(*sqs.Options)) (*sqs.ListDeadLetterSourceQueuesOutput, error)
+}
+
+type Client struct {
+	api         AdapterAPI
+	retryPolicy *retry.Policy
+}
+
+func NewClientWithConfig(cfg aws.Config) *Client {
+	api := sqs.NewFromConfig(retry.NoSDKRetries(cfg))
+	return NewClient(api)
+}
+
+func NewClient(api AdapterAPI) *Client {
+	return &Client{api: api}
+}
+
+// RetryPolicy is the policy the client retries its calls with
+func (c *Client) RetryPolicy() retry.Policy {
+	if c.retryPolicy == nil {
+		return retry.Default()
+	}
+	return *c.retryPolicy
+}
+
+// SetRetryPolicy replaces retry.Default for the calls made by the client
+func (c *Client) SetRetryPolicy(p retry.Policy) {
+	c.retryPolicy = &p
+}
+
+// QueueURL resolves the url of the queue with the given name
+func (c *Client) QueueURL(ctx context.Context, name string) (string, error) {
+	if name == "" {
+		return "", failure.InvalidParam("[name] queue name is empty")
+	}
+
+	in := sqs.GetQueueUrlInput{QueueName: aws.String(name)}
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetQueueUrl, &in)
+	if err != nil {
+		return "", handleAPIError(err, "c.api.GetQueueUrl failed (%s)", name)
+	}
+
+	return aws.ToString(out.QueueUrl), nil
+}
+
+// DeadLetterSources are the urls of the queues that use dlqURL as their dead
+// letter queue
+func (c *Client) DeadLetterSources(ctx context.Context, dlqURL string) ([]string, error) {
+	in := sqs.ListDeadLetterSourceQueuesInput{QueueUrl: aws.String(dlqURL)}
+
+	var result []string
+	for {
+		out, err := retry.Call(ctx, c.RetryPolicy(), c.api.ListDeadLetterSourceQueues, &in)
+		if err != nil {
+			return nil, handleAPIError(err, "c.api.ListDeadLetterSourceQueues failed (%s)", dlqURL)
+		}
+		result = append(result, out.QueueUrls...)
+
+		if out.NextToken == nil {
+			break
+		}
+		in.NextToken = out.NextToken
+	}
+
+	return result, nil
+}
+
+// RetryPolicy is the delay before each retry of a message, BaseDelay doubles
+// with every attempt and is capped at MaxDelay. After MaxAttempts the message
+// is left to the redrive policy of the queue.
+type RetryPolicy struct {
+	BaseDelay   time.Duration
+	MaxAttempts int
+}
+
+func NewRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		BaseDelay:   DefaultRetryBase,
+		MaxAttempts: DefaultRetryAttempts,
+	}
+}
+
+// Delay is the DelaySeconds of the given attempt, the first retry is 1
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := p.BaseDelay
+	for n := 1; n < attempt && delay < MaxDelay; n++ {
+		delay *= 2
+	}
+
+	if delay > MaxDelay {
+		delay = MaxDelay
+	}
+
+	return delay
+}
+
+// RetryAttempt is how many times the message was already retried
+func RetryAttempt(msg events.SQSMessage) int {
+	attr, ok := msg.MessageAttributes[RetryAttemptAttribute]
+	if !ok || attr.StringValue == nil {
+		return 0
+	}
+
+	attempt, err := strconv.Atoi(*attr.StringValue)
+	if err != nil {
+		return 0
+	}
+
+	return attempt
+}
+
+type RetryReport struct {
+	MessageID string
+	Attempt   int
+	Delay     time.Duration
+}
+
+// ScheduleRetry sends a copy of msg back to the queue with the delay of its
+// next attempt. The caller should then report the original as processed so
+// it is not also retried by the visibility timeout. Once the policy is
+// exhausted an InvalidState error is returned and nothing is sent, failing
+// the message lets the queue move it to its dead letter queue.
+func (c *Client) ScheduleRetry(ctx context.Context, queueURL string, msg events.SQSMessage, p RetryPolicy) (*RetryReport, error) {
+	if queueURL == "" {
+		return nil, failure.InvalidParam("[queueURL] queue url is empty")
+	}
+
+	attempt := RetryAttempt(msg) + 1
+	if p.MaxAttempts > 0 && attempt > p.MaxAttempts {
+		return nil, failure.InvalidState("message (%s) exhausted its (%d) retries", msg.MessageId, p.MaxAttempts)
+	}
+
+	delay := p.Delay(attempt)
+	attributes := messageAttributes(msg.MessageAttributes)
+	attributes[RetryAttemptAttribute] = types.MessageAttributeValue{
+		DataType:    aws.String("Number"),
+		StringValue: aws.String(strconv.Itoa(attempt)),
+	}
+
+	in := sqs.SendMessageInput{
+		QueueUrl:          aws.String(queueURL),
+		MessageBody:       aws.String(msg.Body),
+		DelaySeconds:      int32(delay / time.Second),
+		MessageAttributes: attributes,
+	}
+
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.SendMessage, &in)
+	if err != nil {
+		return nil, handleAPIError(err, "c.api.SendMessage failed (%s)", queueURL)
+	}
+
+	return &RetryReport{
+		MessageID: aws.ToString(out.MessageId),
+		Attempt:   attempt,
+		Delay:     delay,
+	}, nil
+}
+
+func messageAttributes(in map[string]events.SQSMessageAttribute) map[string]types.MessageAttributeValue {
+	result := make(map[string]types.MessageAttributeValue, len(in)+1)
+	for name, attr := range in {
+		result[name] = types.MessageAttributeValue{
+			DataType:         aws.String(attr.DataType),
+			StringValue:      attr.StringValue,
+			BinaryValue:      attr.BinaryValue,
+			StringListValues: attr.StringListValues,
+			BinaryListValues: attr.BinaryListValues,
+		}
+	}
+
+	return result
+}
+
+// handleAPIError reports a missing queue as NotFound
+func handleAPIError(err error, msg string, a ...interface{}) error {
+	var notFound *types.QueueDoesNotExist
+	if errors.As(err, &notFound) {
+		return failure.ToNotFound(err, msg, a...)
+	}
+
+	return failure.ToSystem(err, msg, a...)
+}
@@ -0,0 +1,144 @@
+// Package sts implements the sts client used to assume roles in other aws
+// accounts, so a cli running in a tooling account can operate on the
+// params and lambdas of the workload accounts
+package sts
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	awsSTS "github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"github.com/rsb/failure"
+	"github.com/rsb/sls"
+	"github.com/rsb/sls/retry"
+)
+
+const (
+	DefaultSessionName = "sls-cli"
+	DefaultDuration    = time.Hour
+	// DefaultRoleName is the role aws organizations creates in member accounts
+	DefaultRoleName = "OrganizationAccountAccessRole"
+)
+
+type AdapterAPI interface {
+	AssumeRole(ctx context.Context, params *awsSTS.AssumeRoleInput, optFns ...func(*awsSTS.Options)) (*awsSTS.AssumeRoleOutput, error)
+	GetCallerIdentity(ctx context.Context, params *awsSTS.GetCallerIdentityInput, optFns ...func(*awsSTS.Options)) (*awsSTS.GetCallerIdentityOutput, error)
+}
+
+type Client struct {
+	api         AdapterAPI
+	retryPolicy *retry.Policy
+}
+
+func NewClientWithConfig(cfg aws.Config) *Client {
+	api := awsSTS.NewFromConfig(retry.NoSDKRetries(cfg))
+	return NewClient(api)
+}
+
+func NewClient(api AdapterAPI) *Client {
+	return &Client{api: api}
+}
+
+// RetryPolicy is the policy the client retries its calls with
+func (c *Client) RetryPolicy() retry.Policy {
+	if c.retryPolicy == nil {
+		return retry.Default()
+	}
+	return *c.retryPolicy
+}
+
+// SetRetryPolicy replaces retry.Default for the calls made by the client
+func (c *Client) SetRetryPolicy(p retry.Policy) {
+	c.retryPolicy = &p
+}
+
+type Identity struct {
+	Account string `json:"account"`
+	ARN     string `json:"arn"`
+	UserID  string `json:"user_id"`
+}
+
+// CallerIdentity reports who the current credentials belong to
+func (c *Client) CallerIdentity(ctx context.Context) (Identity, error) {
+	var result Identity
+	out, err := retry.Call(ctx, c.RetryPolicy(), c.api.GetCallerIdentity, &awsSTS.GetCallerIdentityInput{})
+	if err != nil {
+		return result, failure.ToSystem(err, "c.api.GetCallerIdentity failed")
+	}
+
+	result.Account = aws.ToString(out.Account)
+	result.ARN = aws.ToString(out.Arn)
+	result.UserID = aws.ToString(out.UserId)
+	return result, nil
+}
+
+// AssumeRoleSettings describes the role to assume, only RoleARN is required
+type AssumeRoleSettings struct {
+	RoleARN     string
+	SessionName string
+	ExternalID  string
+	Duration    time.Duration
+}
+
+// RoleARN builds the arn of role in account in the standard partition, a
+// role that already is an arn is returned as is
+func RoleARN(account, role string) string {
+	return PartitionRoleARN(sls.AWSPartition, account, role)
+}
+
+// PartitionRoleARN is RoleARN for accounts in GovCloud or China
+func PartitionRoleARN(p sls.Partition, account, role string) string {
+	if strings.HasPrefix(role, "arn:") {
+		return role
+	}
+
+	if role == "" {
+		role = DefaultRoleName
+	}
+
+	return p.ARN("iam", "", account, "role/"+role)
+}
+
+// AccountFromARN is the account id of an arn, ex `arn:aws:iam::123:role/x`
+// is `123`
+func AccountFromARN(arn string) (string, error) {
+	parts := strings.Split(arn, ":")
+	if len(parts) < 6 || parts[0] != "arn" || parts[4] == "" {
+		return "", failure.InvalidParam("(%s) is not an arn with an account", arn)
+	}
+
+	return parts[4], nil
+}
+
+// AssumeRoleConfig returns a copy of cfg whose clients use the credentials
+// of the assumed role. The credentials are cached and refreshed before they
+// expire, nothing is called until the first client makes a request.
+func (c *Client) AssumeRoleConfig(cfg aws.Config, s AssumeRoleSettings) (aws.Config, error) {
+	if s.RoleARN == "" {
+		return cfg, failure.InvalidParam("s.RoleARN is empty, the role to assume is required")
+	}
+
+	provider := stscreds.NewAssumeRoleProvider(c.api, s.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = DefaultSessionName
+		if s.SessionName != "" {
+			o.RoleSessionName = s.SessionName
+		}
+
+		o.Duration = DefaultDuration
+		if s.Duration > 0 {
+			o.Duration = s.Duration
+		}
+
+		if s.ExternalID != "" {
+			o.ExternalID = aws.String(s.ExternalID)
+		}
+	})
+
+	cfg = cfg.Copy()
+	cfg.Credentials = aws.NewCredentialsCache(provider)
+	return cfg, nil
+}
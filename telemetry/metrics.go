@@ -0,0 +1,131 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	MetricsPath      = "/metrics"
+	MetricsNamespace = "sls"
+)
+
+// WriteMetrics writes the summary in the prometheus text exposition format.
+// Counters are totals since the recorder was created, durations are
+// summaries in seconds with a _sum and a _count.
+func (s Summary) WriteMetrics(w io.Writer) error {
+	var b strings.Builder
+	metric := func(name, kind, help string) {
+		b.WriteString(fmt.Sprintf("# HELP %s_%s %s\n", MetricsNamespace, name, help))
+		b.WriteString(fmt.Sprintf("# TYPE %s_%s %s\n", MetricsNamespace, name, kind))
+	}
+	sample := func(name, label, value string, v float64) {
+		if label == "" {
+			b.WriteString(fmt.Sprintf("%s_%s %g\n", MetricsNamespace, name, v))
+			return
+		}
+		b.WriteString(fmt.Sprintf("%s_%s{%s=%q} %g\n", MetricsNamespace, name, label, value, v))
+	}
+
+	metric("uptime_seconds", "gauge", "Seconds since the recorder started")
+	sample("uptime_seconds", "", "", s.Elapsed.Seconds())
+
+	metric("retries_total", "counter", "Retries made by the sdk and sls/retry")
+	sample("retries_total", "", "", float64(s.Retries))
+
+	names := make([]string, 0, len(s.Operations))
+	for name := range s.Operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	counters := []struct {
+		name  string
+		help  string
+		value func(OperationStats) int
+	}{
+		{"aws_calls_total", "Calls made to an aws operation", func(o OperationStats) int { return o.Calls }},
+		{"aws_attempts_total", "Attempts made by the sdk, retries included", func(o OperationStats) int { return o.Attempts }},
+		{"aws_retries_total", "Attempts retried by the sdk", func(o OperationStats) int { return o.Retries }},
+		{"aws_errors_total", "Calls that failed after every attempt", func(o OperationStats) int { return o.Errors }},
+	}
+	for _, c := range counters {
+		metric(c.name, "counter", c.help)
+		for _, name := range names {
+			sample(c.name, "operation", name, float64(c.value(s.Operations[name])))
+		}
+	}
+
+	metric("aws_call_duration_seconds", "summary", "Time spent in calls to an aws operation")
+	for _, name := range names {
+		op := s.Operations[name]
+		sample("aws_call_duration_seconds_sum", "operation", name, op.Duration.Seconds())
+		sample("aws_call_duration_seconds_count", "operation", name, float64(op.Calls))
+	}
+
+	steps := map[string]*OperationStats{}
+	var stepNames []string
+	for _, step := range s.Steps {
+		st, ok := steps[step.Name]
+		if !ok {
+			st = &OperationStats{}
+			steps[step.Name] = st
+			stepNames = append(stepNames, step.Name)
+		}
+		st.Calls++
+		st.Duration += step.Duration
+	}
+	sort.Strings(stepNames)
+
+	metric("step_duration_seconds", "summary", "Time spent in the steps of the commands")
+	for _, name := range stepNames {
+		sample("step_duration_seconds_sum", "step", name, steps[name].Duration.Seconds())
+		sample("step_duration_seconds_count", "step", name, float64(steps[name].Calls))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Handler serves the metrics of r, a nil recorder serves empty metrics
+func Handler(r *Recorder) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := r.Summary().WriteMetrics(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// Serve exposes the metrics of r on addr at MetricsPath until ctx is done.
+// The address is bound before Serve returns, so a port already in use is
+// reported right away, the server itself runs in the background.
+func Serve(ctx context.Context, addr string, r *Recorder) (net.Addr, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, Handler(r))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdown)
+	}()
+
+	// Serve only fails once the listener is closed, metrics are best effort
+	// and never stop the command they observe
+	go func() { _ = srv.Serve(l) }()
+
+	return l.Addr(), nil
+}
@@ -0,0 +1,244 @@
+// Package telemetry records where the time of a cli command goes: the
+// duration of each step, how many aws calls were made and how many of them
+// were retried. Recording is cheap, the infra commands only print the
+// Summary when asked to with --timing.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+)
+
+const (
+	CallMiddlewareID    = "SLSTelemetryCall"
+	AttemptMiddlewareID = "SLSTelemetryAttempt"
+)
+
+type StepTiming struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+}
+
+// OperationStats counts one aws operation. Attempts includes the retries
+// made by the sdk, Retries also includes the retries made by sls/retry.
+// Duration is the total time spent in the calls, retries included.
+type OperationStats struct {
+	Calls    int           `json:"calls"`
+	Attempts int           `json:"attempts"`
+	Retries  int           `json:"retries"`
+	Errors   int           `json:"errors"`
+	Duration time.Duration `json:"duration"`
+}
+
+type Summary struct {
+	Elapsed    time.Duration             `json:"elapsed"`
+	Steps      []StepTiming              `json:"steps"`
+	Operations map[string]OperationStats `json:"operations"`
+	Calls      int                       `json:"calls"`
+	Retries    int                       `json:"retries"`
+}
+
+// Recorder collects the timings of a single command. A nil Recorder
+// records nothing.
+type Recorder struct {
+	mu         sync.Mutex
+	start      time.Time
+	steps      []StepTiming
+	operations map[string]*OperationStats
+	retries    int
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		start:      time.Now(),
+		operations: map[string]*OperationStats{},
+	}
+}
+
+// Step starts timing name, the returned func stops it
+//
+//	defer r.Step("deploy code")()
+func (r *Recorder) Step(name string) func() {
+	if r == nil {
+		return func() {}
+	}
+
+	start := time.Now()
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.steps = append(r.steps, StepTiming{Name: name, Duration: time.Since(start)})
+	}
+}
+
+func (r *Recorder) op(name string) *OperationStats {
+	stats, ok := r.operations[name]
+	if !ok {
+		stats = &OperationStats{}
+		r.operations[name] = stats
+	}
+	return stats
+}
+
+// RecordCall counts one call to an aws operation, like `SSM.GetParameter`
+func (r *Recorder) RecordCall(operation string) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.op(operation).Calls++
+}
+
+// RecordResult records how long a call to an aws operation took and whether
+// it failed after every attempt
+func (r *Recorder) RecordResult(operation string, d time.Duration, err error) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := r.op(operation)
+	stats.Duration += d
+	if err != nil {
+		stats.Errors++
+	}
+}
+
+// RecordAttempt counts one attempt of an aws operation, every attempt after
+// the first attempt of a call is a retry
+func (r *Recorder) RecordAttempt(operation string, attempt int) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := r.op(operation)
+	stats.Attempts++
+	if attempt > 1 {
+		stats.Retries++
+		r.retries++
+	}
+}
+
+// ObserveRetry matches retry.Observer, it counts the retries made by
+// sls/retry on top of the sdk
+func (r *Recorder) ObserveRetry(_ context.Context, _ int, _ error) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retries++
+}
+
+func (r *Recorder) Summary() Summary {
+	if r == nil {
+		return Summary{Operations: map[string]OperationStats{}}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := Summary{
+		Elapsed:    time.Since(r.start),
+		Steps:      append([]StepTiming{}, r.steps...),
+		Operations: map[string]OperationStats{},
+		Retries:    r.retries,
+	}
+
+	for name, stats := range r.operations {
+		s.Operations[name] = *stats
+		s.Calls += stats.Calls
+	}
+
+	return s
+}
+
+// Write prints the summary as plain text
+func (s Summary) Write(w io.Writer) error {
+	lines := []string{fmt.Sprintf("[timing] total %s, %d aws calls, %d retries", s.Elapsed.Round(time.Millisecond), s.Calls, s.Retries)}
+	for _, step := range s.Steps {
+		lines = append(lines, fmt.Sprintf("[timing] step %-24s %s", step.Name, step.Duration.Round(time.Millisecond)))
+	}
+
+	names := make([]string, 0, len(s.Operations))
+	for name := range s.Operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		op := s.Operations[name]
+		lines = append(lines, fmt.Sprintf("[timing] aws  %-24s calls=%d attempts=%d retries=%d", name, op.Calls, op.Attempts, op.Retries))
+	}
+
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Middleware counts the calls and attempts of every sdk operation
+func Middleware(r *Recorder) func(stack *middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		if r == nil {
+			return nil
+		}
+
+		call := middleware.InitializeMiddlewareFunc(CallMiddlewareID, func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			op := operationName(ctx)
+			r.RecordCall(op)
+			start := time.Now()
+			out, md, err := next.HandleInitialize(ctx, in)
+			r.RecordResult(op, time.Since(start), err)
+			return out, md, err
+		})
+		// after the service metadata middleware so the operation is known
+		if err := stack.Initialize.Add(call, middleware.After); err != nil {
+			return err
+		}
+
+		// the sdk builds a new stack for every call, so attempt counts the
+		// attempts of a single call
+		var attempt int
+		count := middleware.FinalizeMiddlewareFunc(AttemptMiddlewareID, func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			attempt++
+			r.RecordAttempt(operationName(ctx), attempt)
+			return next.HandleFinalize(ctx, in)
+		})
+
+		// after the sdk retry middleware so every attempt passes through
+		return stack.Finalize.Add(count, middleware.After)
+	}
+}
+
+// WithRecorder returns a copy of cfg whose clients report to r
+func WithRecorder(cfg aws.Config, r *Recorder) aws.Config {
+	if r == nil {
+		return cfg
+	}
+
+	cfg = cfg.Copy()
+	cfg.APIOptions = append(cfg.APIOptions, Middleware(r))
+	return cfg
+}
+
+func operationName(ctx context.Context) string {
+	return fmt.Sprintf("%s.%s", awsmiddleware.GetServiceID(ctx), awsmiddleware.GetOperationName(ctx))
+}
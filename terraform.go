@@ -43,6 +43,7 @@ type TFResource struct {
 	Name      string
 	Backend   TFBackend
 	Vars      map[string]string
+	VarFiles  []string
 }
 
 func NewTFResource(rootDir string, prefix Prefix, label string) TFResource {
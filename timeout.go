@@ -2,9 +2,7 @@ package sls
 
 import (
 	"context"
-	"fmt"
 	"runtime/debug"
-	"strings"
 	"time"
 
 	"github.com/rsb/sls/logging"
@@ -48,13 +46,14 @@ func (t *Timeout) WithTimeConstraint(ctx context.Context, fn ConcreteHandlerFn)
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				err = failure.Panic("invocation")
+				p := NewPanicError(r, debug.Stack())
+				err = p
 
-				stack := strings.Split(string(debug.Stack()), "\n")
 				logging.GetInvocationLogger(ctx).With(
-					"recover", fmt.Sprintf("%v", r),
-					"stack", stack,
+					"recover", p.Recovered,
+					"stack", p.Stack,
 					"panic", true,
+					"panic_id", p.ID,
 				).Error(err)
 				completed <- struct{}{}
 			}